@@ -0,0 +1,257 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/toposcope/toposcope/internal/ingestion"
+	"github.com/toposcope/toposcope/pkg/client"
+	"github.com/toposcope/toposcope/pkg/extract"
+	"github.com/toposcope/toposcope/pkg/extract/gitdiff"
+	"github.com/toposcope/toposcope/pkg/extract/subgraph"
+	"github.com/toposcope/toposcope/pkg/graph"
+)
+
+func newBackfillCmd() *cobra.Command {
+	var opts backfillOpts
+
+	cmd := &cobra.Command{
+		Use:   "backfill",
+		Short: "Score past commits on the default branch to build a history",
+		Long: `Walks the first-parent history of the default branch from --since to its tip,
+extracts a snapshot at every --every'th commit, and scores each sampled commit
+against the one before it. Extraction runs in a separate git worktree, so the
+working tree is left alone, and snapshots already in the cache are reused. A
+commit that fails to extract is skipped, and the next one is scored against
+the last commit that succeeded.
+
+With --platform-url, each sampled commit is uploaded with its score, so a
+newly onboarded repository has a history chart straight away. The platform API
+key is read from TOPOSCOPE_API_KEY. Without it, scores are only cached locally
+for toposcope ui.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if opts.every < 1 {
+				return fmt.Errorf("--every must be at least 1")
+			}
+			return runBackfill(cmd.Context(), opts)
+		},
+	}
+
+	cmd.Flags().StringVar(&opts.since, "since", "", "Oldest commit to sample (required)")
+	cmd.Flags().IntVar(&opts.every, "every", 10, "Sample every n-th commit")
+	cmd.Flags().StringVar(&opts.branch, "branch", "", "Branch to walk (default: the default branch)")
+	cmd.Flags().StringVar(&opts.repoPath, "repo-path", "", "Path to repository root (default: detect workspace)")
+	cmd.Flags().StringVar(&opts.bazelPath, "bazel-path", "", "Path to bazel/bazelisk binary")
+	cmd.Flags().StringVar(&opts.bazelRC, "bazelrc", "", "Path to .bazelrc file")
+	cmd.Flags().BoolVar(&opts.useCQuery, "cquery", false, "Use cquery instead of query")
+	cmd.Flags().BoolVar(&opts.includeExternal, "include-external", false, "Retain external dependencies as one node per external repo")
+	cmd.Flags().BoolVar(&opts.normalize, "normalize", false, "Normalize scores by repository size before grading")
+	cmd.Flags().StringVar(&opts.platformURL, "platform-url", os.Getenv("TOPOSCOPE_URL"), "Toposcope platform URL to upload to (default: $TOPOSCOPE_URL)")
+	cmd.Flags().StringVar(&opts.repo, "repo", "", "Repository owner/name on the platform (default: from the origin remote)")
+	_ = cmd.MarkFlagRequired("since")
+
+	return cmd
+}
+
+type backfillOpts struct {
+	since           string
+	every           int
+	branch          string
+	repoPath        string
+	bazelPath       string
+	bazelRC         string
+	useCQuery       bool
+	includeExternal bool
+	normalize       bool
+	platformURL     string
+	repo            string
+}
+
+func runBackfill(ctx context.Context, opts backfillOpts) error {
+	wsRoot, err := resolveWorkspace(opts.repoPath)
+	if err != nil {
+		return err
+	}
+	cfg := loadConfig(wsRoot)
+	engine, err := newScoringEngine(wsRoot, cfg, opts.normalize)
+	if err != nil {
+		return err
+	}
+
+	var c *client.Client
+	var signer *scoreSigner
+	repo := firstNonEmpty(opts.repo, gitRemoteSlug(ctx, wsRoot))
+	if opts.platformURL != "" {
+		if os.Getenv("TOPOSCOPE_API_KEY") == "" {
+			return fmt.Errorf("TOPOSCOPE_API_KEY is not set")
+		}
+		if repo == "" {
+			return fmt.Errorf("cannot determine repository name from the origin remote; pass --repo")
+		}
+		if c, _, err = platformClient(ctx, opts.platformURL); err != nil {
+			return err
+		}
+		if signer, err = signerFromEnv(os.Getenv); err != nil {
+			return err
+		}
+	}
+
+	defaultBranch := detectDefaultBranch(wsRoot)
+	branch := firstNonEmpty(opts.branch, defaultBranch)
+	sinceSHA, err := gitRevParse(ctx, wsRoot, opts.since)
+	if err != nil {
+		return fmt.Errorf("resolving --since: %w", err)
+	}
+	history, err := gitRevList(ctx, wsRoot, "--first-parent", sinceSHA+".."+branch)
+	if err != nil {
+		return fmt.Errorf("listing commits: %w", err)
+	}
+	commits := ingestion.SampleCommits(append([]string{sinceSHA}, history...), opts.every)
+	fmt.Fprintf(os.Stderr, "Backfill: %d of %d commits on %s\n", len(commits), len(history)+1, branch)
+
+	ext := &subgraph.Extractor{
+		BazelPath:        bazelBinary(opts.bazelPath, cfg),
+		BazelRC:          bazelRCPath(wsRoot, opts.bazelRC, cfg),
+		UseCQuery:        opts.useCQuery || cfg.Extraction.UseCQuery,
+		EdgeAttributes:   extract.EdgeAttributes(cfg.Extraction.EdgeAttributes),
+		IncludeExternal:  opts.includeExternal || cfg.Extraction.IncludeExternal,
+		RepoMapping:      cfg.Extraction.RepoMapping,
+		Generated:        generatedPatterns(cfg),
+		LabelRewrites:    labelRewrites(cfg),
+		Limits:           extractionLimits(cfg),
+		OutputBase:       cfg.Extraction.OutputBaseDir(wsRoot, "backfill"),
+		KeepServer:       cfg.Extraction.KeepServer,
+		ShardParallelism: cfg.Extraction.ShardParallelism,
+		ProfileDir:       cfg.Extraction.ProfileDir,
+	}
+	rc := openRemoteCache(ctx, wsRoot, cfg, ext)
+	timeout := time.Duration(cfg.Extraction.Timeout) * time.Second
+	wt := &backfillWorktree{repo: wsRoot}
+	defer wt.remove(ext)
+
+	var baseSHA string
+	var baseSnap *graph.Snapshot
+	scored, failed := 0, 0
+	for i, sha := range commits {
+		short := sha[:minInt(7, len(sha))]
+		fmt.Fprintf(os.Stderr, "[%d/%d] %s\n", i+1, len(commits), short)
+
+		snap, err := rc.loadSnapshot(ctx, wsRoot, sha)
+		if err != nil {
+			if ext.WorkspacePath == "" {
+				if ext.WorkspacePath, err = wt.create(ctx); err != nil {
+					return fmt.Errorf("creating worktree: %w", err)
+				}
+			}
+			if snap, err = wt.extract(ctx, ext, sha, timeout); err != nil {
+				fmt.Fprintf(os.Stderr, "  Warning: skipping %s: %v\n", short, err)
+				failed++
+				continue
+			}
+			warnSkippedPackages(snap)
+			rc.saveSnapshot(ctx, wsRoot, sha, snap)
+		} else {
+			fmt.Fprintf(os.Stderr, "  Snapshot: cached\n")
+		}
+		snap.Branch = branch
+
+		req := &client.IngestRequest{
+			RepoFullName:  repo,
+			DefaultBranch: defaultBranch,
+			CommitSHA:     sha,
+			Branch:        branch,
+			CommittedAt:   gitCommitTime(ctx, wsRoot, sha),
+			Snapshot:      snap,
+		}
+		if baseSnap != nil {
+			delta := graph.ComputeDelta(baseSnap, snap)
+			detector := &gitdiff.Detector{WorkspacePath: wsRoot, Base: baseSnap}
+			if cd, err := detector.DetectChanges(ctx, extract.ChangeDetectionRequest{RepoPath: wsRoot, BaseSHA: baseSHA, HeadSHA: sha}); err == nil {
+				delta.ImpactedTargets = cd.ImpactedTargets
+				delta.Stats.ImpactedTargetCount = len(cd.ImpactedTargets)
+			}
+			result, err := engine.Score(delta, baseSnap, snap)
+			if err != nil {
+				return fmt.Errorf("scoring %s: %w", short, err)
+			}
+			fmt.Fprintf(os.Stderr, "  Score %.1f (grade %s) against %s\n", result.TotalScore, result.Grade, baseSHA[:minInt(7, len(baseSHA))])
+			saveScoreResult(wsRoot, baseSHA, sha, result)
+			req.Score, req.BaseSnapshot = result, baseSnap
+			scored++
+		}
+
+		if c != nil {
+			if err := signer.sign(req); err != nil {
+				return fmt.Errorf("signing %s: %w", short, err)
+			}
+			resp, err := c.Ingest(ctx, req)
+			if err != nil {
+				return fmt.Errorf("uploading %s: %w", short, err)
+			}
+			fmt.Fprintf(os.Stderr, "  Uploaded (snapshot %s)\n", resp.SnapshotID)
+		}
+		baseSHA, baseSnap = sha, snap
+	}
+
+	fmt.Fprintf(os.Stderr, "Backfill done: %d commits scored, %d skipped\n", scored, failed)
+	if scored == 0 && len(commits) > 1 {
+		return fmt.Errorf("no commits could be scored")
+	}
+	return nil
+}
+
+// backfillWorktree is a detached git worktree that backfill checks commits
+// out in. Reusing one worktree for every commit keeps a single Bazel output
+// base, so later extractions are incremental.
+type backfillWorktree struct {
+	repo string
+	dir  string
+}
+
+func (w *backfillWorktree) create(ctx context.Context) (string, error) {
+	dir, err := os.MkdirTemp("", "toposcope-backfill-*")
+	if err != nil {
+		return "", err
+	}
+	if err := gitRun(ctx, w.repo, "worktree", "add", "--detach", "--quiet", dir); err != nil {
+		os.RemoveAll(dir)
+		return "", err
+	}
+	w.dir = dir
+	return dir, nil
+}
+
+func (w *backfillWorktree) extract(ctx context.Context, ext *subgraph.Extractor, sha string, timeout time.Duration) (*graph.Snapshot, error) {
+	if err := gitRun(ctx, w.dir, "checkout", "--detach", "--quiet", sha); err != nil {
+		return nil, fmt.Errorf("checking out: %w", err)
+	}
+	fmt.Fprintf(os.Stderr, "  Extracting...\n")
+	return ext.ExtractFull(ctx, sha, timeout)
+}
+
+// remove deletes the worktree, if one was created.
+func (w *backfillWorktree) remove(ext *subgraph.Extractor) {
+	if w.dir == "" {
+		return
+	}
+	// Shut down the worktree's Bazel server so it doesn't outlive the run.
+	_ = ext.Shutdown(context.Background())
+	if err := gitRun(context.Background(), w.repo, "worktree", "remove", "--force", w.dir); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: removing worktree %s: %v\n", w.dir, err)
+	}
+}
+
+// gitRun runs a git command in dir, reporting its stderr in the error.
+func gitRun(ctx context.Context, dir string, args ...string) error {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("git %s: %w: %s", args[0], err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
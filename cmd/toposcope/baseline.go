@@ -0,0 +1,175 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/toposcope/toposcope/pkg/client"
+	"github.com/toposcope/toposcope/pkg/graph"
+)
+
+// resolveBaseline finds the snapshot HEAD should be scored against when no
+// base ref is given. The cached snapshot at the merge base of HEAD and the
+// default branch is preferred; otherwise the repository's baseline is
+// fetched from the platform, when one is configured, and cached locally.
+func resolveBaseline(ctx context.Context, wsRoot, platformURL string, rc *remoteCache) (string, *graph.Snapshot, error) {
+	branch := detectDefaultBranch(wsRoot)
+	for _, ref := range []string{"origin/" + branch, branch} {
+		sha, err := gitMergeBase(ctx, wsRoot, "HEAD", ref)
+		if err != nil {
+			continue
+		}
+		if snap, err := rc.loadSnapshot(ctx, wsRoot, sha); err == nil {
+			fmt.Fprintf(os.Stderr, "Baseline: cached snapshot at merge base with %s (%s)\n", ref, sha[:minInt(7, len(sha))])
+			return sha, snap, nil
+		}
+		break
+	}
+
+	if platformURL == "" {
+		return "", nil, fmt.Errorf("no cached snapshot at the merge base with %s; run `toposcope snapshot` there or pass --platform-url to fetch the recorded baseline", branch)
+	}
+
+	repo := gitRemoteSlug(ctx, wsRoot)
+	if repo == "" {
+		return "", nil, fmt.Errorf("cannot determine repository name from the origin remote")
+	}
+	sha, snap, err := fetchPlatformBaseline(ctx, platformURL, repo, wsRoot)
+	if err != nil {
+		return "", nil, fmt.Errorf("fetching baseline from platform: %w", err)
+	}
+	fmt.Fprintf(os.Stderr, "Baseline: %s platform baseline (%s)\n", repo, sha[:minInt(7, len(sha))])
+	saveCachedSnapshot(wsRoot, sha, snap)
+	return sha, snap, nil
+}
+
+// fetchPlatformBaseline looks up repo on the platform and returns its
+// baseline snapshot. Snapshots never change, so one already in wsRoot's
+// snapshot cache for the baseline commit is used instead of downloading it;
+// pass an empty wsRoot to always download.
+func fetchPlatformBaseline(ctx context.Context, platformURL, repo, wsRoot string) (string, *graph.Snapshot, error) {
+	c := client.FromEnv(platformURL)
+	r, err := c.FindRepo(ctx, repo)
+	if err != nil {
+		return "", nil, err
+	}
+	if r == nil {
+		return "", nil, fmt.Errorf("repository %s is not registered", repo)
+	}
+
+	baseline, err := c.GetBaseline(ctx, r.ID)
+	if err != nil {
+		return "", nil, err
+	}
+	if wsRoot != "" {
+		if snap, err := loadCachedSnapshot(wsRoot, baseline.CommitSHA); err == nil {
+			return baseline.CommitSHA, snap, nil
+		}
+	}
+	snap, err := c.GetSnapshot(ctx, baseline.SnapshotID)
+	if err != nil {
+		return "", nil, err
+	}
+	return baseline.CommitSHA, snap, nil
+}
+
+// branchMergeBase returns the commit to score head against for the base ref
+// given: the merge base of head and ref when ref names a branch, local or
+// remote-tracking, so changes that landed on the branch after head branched
+// off aren't blamed on head. Otherwise, and when there is no merge base, it
+// returns baseSHA, the commit ref resolves to.
+func branchMergeBase(ctx context.Context, dir, ref, baseSHA, headSHA string) string {
+	if !gitIsBranch(ctx, dir, ref) {
+		return baseSHA
+	}
+	mb, err := gitMergeBase(ctx, dir, ref, headSHA)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: no merge base of %s and head; comparing against the tip of %s\n", ref, ref)
+		return baseSHA
+	}
+	if mb != baseSHA {
+		fmt.Fprintf(os.Stderr, "Base: merge base of %s and head (%s; %s is at %s)\n", ref, shortSHA(mb), ref, shortSHA(baseSHA))
+	}
+	return mb
+}
+
+// gitIsBranch reports whether ref names a local or remote-tracking branch.
+func gitIsBranch(ctx context.Context, dir, ref string) bool {
+	cmd := exec.CommandContext(ctx, "git", "rev-parse", "--symbolic-full-name", ref)
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	if err != nil {
+		return false
+	}
+	full := strings.TrimSpace(string(out))
+	return strings.HasPrefix(full, "refs/heads/") || strings.HasPrefix(full, "refs/remotes/")
+}
+
+// virtualMerge creates the commit that merging headSHA into baseSHA would
+// make, without touching any branch or the working tree, and returns its
+// SHA. The commit has a fixed author and date, so the same merge always
+// gets the same SHA and its snapshot can be cached. It fails when the merge
+// conflicts, listing the conflicting files.
+func virtualMerge(ctx context.Context, dir, baseSHA, headSHA string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", "merge-tree", "--write-tree", "--name-only", "--no-messages", baseSHA, headSHA)
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	lines := strings.Split(strings.TrimSpace(string(out)), "\n")
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 && len(lines) > 1 {
+		return "", fmt.Errorf("%s does not merge cleanly into %s; conflicts in %s",
+			shortSHA(headSHA), shortSHA(baseSHA), strings.Join(lines[1:], ", "))
+	}
+	if err != nil {
+		return "", fmt.Errorf("git merge-tree (needs git 2.38 or later): %w%s", err, exitStderr(err))
+	}
+
+	cmd = exec.CommandContext(ctx, "git", "commit-tree", lines[0], "-p", baseSHA, "-p", headSHA,
+		"-m", fmt.Sprintf("Virtual merge of %s into %s", headSHA, baseSHA))
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(),
+		"GIT_AUTHOR_NAME=toposcope", "GIT_AUTHOR_EMAIL=toposcope@localhost", "GIT_AUTHOR_DATE=@0 +0000",
+		"GIT_COMMITTER_NAME=toposcope", "GIT_COMMITTER_EMAIL=toposcope@localhost", "GIT_COMMITTER_DATE=@0 +0000")
+	out, err = cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("git commit-tree: %w%s", err, exitStderr(err))
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// exitStderr returns ": " and the stderr of a failed command, if any.
+func exitStderr(err error) string {
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && len(bytes.TrimSpace(exitErr.Stderr)) > 0 {
+		return ": " + string(bytes.TrimSpace(exitErr.Stderr))
+	}
+	return ""
+}
+
+// gitMergeBase returns the best common ancestor of a and b.
+func gitMergeBase(ctx context.Context, dir, a, b string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", "merge-base", a, b)
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// gitRemoteSlug returns owner/name for the origin remote, or "" if there is
+// none.
+func gitRemoteSlug(ctx context.Context, dir string) string {
+	cmd := exec.CommandContext(ctx, "git", "remote", "get-url", "origin")
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	if err != nil {
+		return ""
+	}
+	return repoSlugFromURL(string(out))
+}
@@ -0,0 +1,243 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/toposcope/toposcope/pkg/bundle"
+	"github.com/toposcope/toposcope/pkg/config"
+	"github.com/toposcope/toposcope/pkg/graph"
+	"github.com/toposcope/toposcope/pkg/scoring"
+)
+
+func newBundleCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "bundle",
+		Short: "Move cached results between machines as a single archive",
+		Long: `Packs cached snapshots, deltas, and scores into a tar.gz bundle, and loads
+bundles into the Toposcope platform. Useful for CI runners without network
+access to the platform.`,
+	}
+
+	cmd.AddCommand(newBundleExportCmd(), newBundleImportCmd())
+
+	return cmd
+}
+
+func newBundleExportCmd() *cobra.Command {
+	var opts bundleExportOpts
+
+	cmd := &cobra.Command{
+		Use:   "export",
+		Short: "Write cached results for a range of commits to a bundle",
+		Long: `Collects the cached snapshots of the commits in --from..--to and every cached
+score whose head is in that range, along with the snapshots those scores
+compare against. Commits without a cached snapshot are skipped. Scores are
+signed when TOPOSCOPE_SIGNING_KEY or TOPOSCOPE_SIGNING_KEY_FILE is set.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runBundleExport(cmd.Context(), opts)
+		},
+	}
+
+	cmd.Flags().StringVar(&opts.repoPath, "repo-path", "", "Path to repository root (default: detect workspace)")
+	cmd.Flags().StringVar(&opts.from, "from", "", "Exclusive start of the commit range (required)")
+	cmd.Flags().StringVar(&opts.to, "to", "HEAD", "Inclusive end of the commit range")
+	cmd.Flags().StringVar(&opts.out, "out", "toposcope-bundle.tar.gz", "Bundle file to write")
+	cmd.Flags().StringVar(&opts.repo, "repo", "", "Repository owner/name recorded in the bundle (default: from the origin remote)")
+	_ = cmd.MarkFlagRequired("from")
+
+	return cmd
+}
+
+type bundleExportOpts struct {
+	repoPath string
+	from     string
+	to       string
+	out      string
+	repo     string
+}
+
+func runBundleExport(ctx context.Context, opts bundleExportOpts) error {
+	wsRoot, err := resolveWorkspace(opts.repoPath)
+	if err != nil {
+		return err
+	}
+
+	signer, err := signerFromEnv(os.Getenv)
+	if err != nil {
+		return err
+	}
+
+	shas, err := gitRevList(ctx, wsRoot, opts.from+".."+opts.to)
+	if err != nil {
+		return fmt.Errorf("listing commits: %w", err)
+	}
+	position := make(map[string]int, len(shas))
+	for i, sha := range shas {
+		position[sha] = i
+	}
+
+	b := &bundle.Bundle{
+		Repo:          firstNonEmpty(opts.repo, gitRemoteSlug(ctx, wsRoot)),
+		DefaultBranch: detectDefaultBranch(wsRoot),
+		CreatedAt:     time.Now().UTC(),
+	}
+	snaps := make(map[string]*graph.Snapshot)
+	addCommit := func(sha string) *graph.Snapshot {
+		if snap, ok := snaps[sha]; ok {
+			return snap
+		}
+		snap, err := loadCachedSnapshot(wsRoot, sha)
+		if err != nil {
+			return nil
+		}
+		snaps[sha] = snap
+		b.Commits = append(b.Commits, bundle.Commit{SHA: sha, CommittedAt: gitCommitTime(ctx, wsRoot, sha), Snapshot: snap})
+		return snap
+	}
+	for _, sha := range shas {
+		addCommit(sha)
+	}
+
+	scoreDir := config.ScoreDir(wsRoot)
+	entries, _ := os.ReadDir(scoreDir)
+	for _, e := range entries {
+		baseSHA, headSHA, ok := strings.Cut(strings.TrimSuffix(e.Name(), ".json"), "_")
+		if _, inRange := position[headSHA]; !ok || !inRange {
+			continue
+		}
+		base, head := addCommit(baseSHA), addCommit(headSHA)
+		if base == nil || head == nil {
+			fmt.Fprintf(os.Stderr, "Warning: skipping score %s: snapshots not cached\n", e.Name())
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(scoreDir, e.Name()))
+		if err != nil {
+			return fmt.Errorf("reading score: %w", err)
+		}
+		var score scoring.ScoreResult
+		if err := json.Unmarshal(data, &score); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: skipping invalid score %s: %v\n", e.Name(), err)
+			continue
+		}
+		change := bundle.Change{
+			BaseSHA: baseSHA,
+			HeadSHA: headSHA,
+			Delta:   graph.ComputeDelta(base, head),
+			Score:   &score,
+		}
+		if signer != nil {
+			change.SignedScore, err = signer.signStatement(&scoring.ScoreStatement{
+				Repo:          b.Repo,
+				CommitSHA:     headSHA,
+				SnapshotID:    graph.ContentID(head),
+				BaseCommitSHA: baseSHA,
+				Score:         &score,
+			})
+			if err != nil {
+				return fmt.Errorf("signing score %s: %w", e.Name(), err)
+			}
+			change.Score = nil
+		}
+		b.Changes = append(b.Changes, change)
+	}
+	sort.SliceStable(b.Changes, func(i, j int) bool {
+		return position[b.Changes[i].HeadSHA] < position[b.Changes[j].HeadSHA]
+	})
+
+	if len(b.Commits) == 0 {
+		return fmt.Errorf("no cached snapshots for %s..%s", opts.from, opts.to)
+	}
+
+	f, err := os.Create(opts.out)
+	if err != nil {
+		return err
+	}
+	if err := bundle.Write(f, b); err != nil {
+		f.Close()
+		return fmt.Errorf("writing bundle: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "Wrote %s: %d snapshots, %d scored changes\n", opts.out, len(b.Commits), len(b.Changes))
+	return nil
+}
+
+func newBundleImportCmd() *cobra.Command {
+	var platformURL string
+
+	cmd := &cobra.Command{
+		Use:   "import <bundle>",
+		Short: "Load a bundle into the Toposcope platform",
+		Long: `Uploads a bundle to POST /api/v1/bundles. The platform API key is read from
+TOPOSCOPE_API_KEY.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runBundleImport(cmd.Context(), args[0], platformURL)
+		},
+	}
+
+	cmd.Flags().StringVar(&platformURL, "platform-url", os.Getenv("TOPOSCOPE_URL"), "Toposcope platform URL (default: $TOPOSCOPE_URL)")
+
+	return cmd
+}
+
+func runBundleImport(ctx context.Context, path, platformURL string) error {
+	if platformURL == "" {
+		return fmt.Errorf("--platform-url or TOPOSCOPE_URL is required")
+	}
+	if os.Getenv("TOPOSCOPE_API_KEY") == "" {
+		return fmt.Errorf("TOPOSCOPE_API_KEY is not set")
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	// Check the bundle locally so a bad file fails before the upload.
+	if _, err := bundle.Read(bytes.NewReader(data)); err != nil {
+		return fmt.Errorf("invalid bundle: %w", err)
+	}
+
+	c, meta, err := platformClient(ctx, platformURL)
+	if err != nil {
+		return err
+	}
+	if meta != nil && meta.BundleFormat != 0 && meta.BundleFormat != bundle.FormatVersion {
+		upgrade := "toposcope"
+		if meta.BundleFormat < bundle.FormatVersion {
+			upgrade = "the platform"
+		}
+		return fmt.Errorf("the platform imports bundle format %d and this toposcope writes format %d; upgrade %s",
+			meta.BundleFormat, bundle.FormatVersion, upgrade)
+	}
+
+	resp, err := c.ImportBundle(ctx, data)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Imported %s: %d snapshots, %d deltas, %d scores\n", path, resp.Snapshots, resp.Deltas, resp.Scores)
+	return nil
+}
+
+// gitRevList returns the commits in a revision range, oldest first.
+func gitRevList(ctx context.Context, dir string, args ...string) ([]string, error) {
+	cmd := exec.CommandContext(ctx, "git", append([]string{"rev-list", "--reverse"}, args...)...)
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, err
+	}
+	return strings.Fields(string(out)), nil
+}
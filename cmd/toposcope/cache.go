@@ -0,0 +1,146 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/toposcope/toposcope/pkg/config"
+	"github.com/toposcope/toposcope/pkg/extract/bazeldiff"
+)
+
+func newCacheCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "cache",
+		Short: "Manage the local toposcope cache",
+		Long: `Inspects and cleans the per-workspace cache under the user cache dir
+(~/.cache/toposcope on Linux, %LocalAppData%\toposcope on Windows).`,
+	}
+
+	cmd.AddCommand(newCacheCleanCmd())
+
+	return cmd
+}
+
+func newCacheCleanCmd() *cobra.Command {
+	var (
+		repoPath  string
+		olderThan time.Duration
+		all       bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "clean",
+		Short: "Remove cached bazel-diff hashes (and optionally snapshots and scores)",
+		Long: `Removes cached bazel-diff hash files for the workspace. With --older-than, only
+files not used within that window are removed. With --all, cached snapshots and
+score results are removed too.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runCacheClean(cacheCleanOpts{
+				repoPath:  repoPath,
+				olderThan: olderThan,
+				all:       all,
+			})
+		},
+	}
+
+	cmd.Flags().StringVar(&repoPath, "repo-path", "", "Path to repository root (default: detect workspace)")
+	cmd.Flags().DurationVar(&olderThan, "older-than", 0, "Only remove files not used within this duration (e.g. 168h)")
+	cmd.Flags().BoolVar(&all, "all", false, "Also remove cached snapshots and score results")
+
+	return cmd
+}
+
+type cacheCleanOpts struct {
+	repoPath  string
+	olderThan time.Duration
+	all       bool
+}
+
+func runCacheClean(opts cacheCleanOpts) error {
+	wsRoot, err := resolveWorkspace(opts.repoPath)
+	if err != nil {
+		return err
+	}
+
+	dirs := []string{config.HashCacheDir(wsRoot)}
+	if opts.all {
+		dirs = append(dirs, config.SnapshotDir(wsRoot), config.ScoreDir(wsRoot))
+	}
+
+	var totalFiles int
+	var totalBytes int64
+	for _, dir := range dirs {
+		n, freed, err := cleanCacheDir(dir, opts.olderThan)
+		if err != nil {
+			return err
+		}
+		if n > 0 {
+			fmt.Fprintf(os.Stderr, "  %s: removed %d files (%s)\n", dir, n, formatBytes(freed))
+		}
+		totalFiles += n
+		totalBytes += freed
+	}
+
+	fmt.Fprintf(os.Stderr, "Removed %d files, freed %s\n", totalFiles, formatBytes(totalBytes))
+	return nil
+}
+
+// cleanCacheDir removes the .json files in dir, or only those last modified
+// before olderThan ago when olderThan > 0.
+func cleanCacheDir(dir string, olderThan time.Duration) (int, int64, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, 0, nil
+		}
+		return 0, 0, fmt.Errorf("reading %s: %w", dir, err)
+	}
+
+	cutoff := time.Now().Add(-olderThan)
+	var removed int
+	var freed int64
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".json") {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		if olderThan > 0 && !info.ModTime().Before(cutoff) {
+			continue
+		}
+		if err := os.Remove(filepath.Join(dir, e.Name())); err != nil {
+			return removed, freed, fmt.Errorf("removing cache file: %w", err)
+		}
+		removed++
+		freed += info.Size()
+	}
+
+	return removed, freed, nil
+}
+
+// hashCacheLimits converts the configured hash cache limits.
+func hashCacheLimits(cfg *config.Config) bazeldiff.CacheLimits {
+	return bazeldiff.CacheLimits{
+		MaxBytes: int64(cfg.Extraction.HashCacheMaxMB) << 20,
+		MaxAge:   time.Duration(cfg.Extraction.HashCacheTTLDays) * 24 * time.Hour,
+	}
+}
+
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
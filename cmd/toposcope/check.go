@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/toposcope/toposcope/pkg/graph"
+	"github.com/toposcope/toposcope/pkg/graphquery"
+)
+
+func newCheckCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "check",
+		Short: "Fast structural gates over cached snapshots",
+		Long: `Runs a single structural check between two cached snapshots and exits
+non-zero when it fails. Nothing is extracted, so bazel is not needed.`,
+	}
+
+	cmd.AddCommand(newCheckCyclesCmd())
+
+	return cmd
+}
+
+func newCheckCyclesCmd() *cobra.Command {
+	var (
+		repoPath  string
+		baseRef   string
+		headRef   string
+		outputFmt string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "cycles",
+		Short: "Fail when a change closes a new package cycle",
+		Long: `Checks whether any edge added between the base and head snapshots closes a
+new cycle in the package graph, and exits non-zero when one does. Cycles that
+already exist in base are not reported.
+
+Each ref may be a snapshot file path or a commit SHA in the snapshot cache.
+The head defaults to the latest cached snapshot, and the base to the cached
+snapshot at the merge base of HEAD and the default branch.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runCheckCycles(cmd.Context(), checkCyclesOpts{
+				repoPath:  repoPath,
+				baseRef:   baseRef,
+				headRef:   headRef,
+				outputFmt: outputFmt,
+			})
+		},
+	}
+
+	cmd.Flags().StringVar(&repoPath, "repo-path", "", "Path to repository root (default: detect workspace)")
+	cmd.Flags().StringVar(&baseRef, "base", "", "Base snapshot file or commit SHA (default: cached merge base)")
+	cmd.Flags().StringVar(&headRef, "head", "", "Head snapshot file or commit SHA (default: latest cached snapshot)")
+	cmd.Flags().StringVar(&outputFmt, "output", "text", "Output format: text or json")
+
+	return cmd
+}
+
+type checkCyclesOpts struct {
+	repoPath  string
+	baseRef   string
+	headRef   string
+	outputFmt string
+}
+
+// checkCyclesResult is the JSON output of check cycles.
+type checkCyclesResult struct {
+	BaseSHA string                    `json:"base_sha"`
+	HeadSHA string                    `json:"head_sha"`
+	Passed  bool                      `json:"passed"`
+	Cycles  []graphquery.PackageCycle `json:"cycles"`
+}
+
+func runCheckCycles(ctx context.Context, opts checkCyclesOpts) error {
+	switch opts.outputFmt {
+	case "json", "text", "":
+	default:
+		return fmt.Errorf("unknown output format %q (want text or json)", opts.outputFmt)
+	}
+
+	head, err := resolveReportSnapshot(opts.repoPath, opts.headRef)
+	if err != nil {
+		return fmt.Errorf("head: %w", err)
+	}
+	var base *graph.Snapshot
+	if opts.baseRef != "" {
+		if base, err = resolveReportSnapshot(opts.repoPath, opts.baseRef); err != nil {
+			return fmt.Errorf("base: %w", err)
+		}
+	} else {
+		wsRoot, err := resolveWorkspace(opts.repoPath)
+		if err != nil {
+			return err
+		}
+		if _, base, err = resolveBaseline(ctx, wsRoot, "", nil); err != nil {
+			return fmt.Errorf("base: %w", err)
+		}
+	}
+
+	delta := graph.ComputeDelta(base, head)
+	cycles := graphquery.NewPackageCycles(base, head, delta.AddedEdges)
+	result := checkCyclesResult{
+		BaseSHA: base.CommitSHA,
+		HeadSHA: head.CommitSHA,
+		Passed:  len(cycles) == 0,
+		Cycles:  cycles,
+	}
+	if result.Cycles == nil {
+		result.Cycles = []graphquery.PackageCycle{}
+	}
+
+	if opts.outputFmt == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(result); err != nil {
+			return fmt.Errorf("encoding JSON: %w", err)
+		}
+	} else {
+		printCheckCyclesText(os.Stdout, result)
+	}
+
+	if !result.Passed {
+		return fmt.Errorf("toposcope gate failed: %d new package cycle(s)", len(cycles))
+	}
+	return nil
+}
+
+func printCheckCyclesText(w io.Writer, r checkCyclesResult) {
+	short := func(sha string) string { return sha[:minInt(7, len(sha))] }
+	fmt.Fprintf(w, "Package cycles: %s -> %s\n", short(r.BaseSHA), short(r.HeadSHA))
+	if r.Passed {
+		fmt.Fprintf(w, "No new package cycles.\n")
+		return
+	}
+	for i, c := range r.Cycles {
+		fmt.Fprintf(w, "\n%d. %d packages: %s\n", i+1, len(c.Packages), strings.Join(c.Path, " -> "))
+		for _, e := range c.Edges {
+			fmt.Fprintf(w, "   closed by %s -> %s\n", e.From, e.To)
+		}
+	}
+}
@@ -0,0 +1,490 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/toposcope/toposcope/pkg/client"
+	"github.com/toposcope/toposcope/pkg/scoring"
+	"github.com/toposcope/toposcope/pkg/surface"
+)
+
+func newCICmd() *cobra.Command {
+	var (
+		baseRef         string
+		headRef         string
+		repoPath        string
+		bazelPath       string
+		bazelRC         string
+		useCQuery       bool
+		bazelDiffJar    string
+		normalize       bool
+		includeExternal bool
+		platformURL     string
+		failOn          string
+		comment         bool
+		buildEvents     string
+		executionLog    string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "ci",
+		Short: "Score, publish, and gate a change in one CI step",
+		Long: `Detects the CI provider (GitHub Actions, GitLab CI, Buildkite, Azure
+Pipelines), resolves the base and head commits, runs the score pipeline,
+uploads the results to the Toposcope platform, comments on the pull request,
+and exits non-zero when the grade fails the --fail-on gate.
+
+With a platform URL, the repository's settings on the platform apply: its
+boundaries, unless the repo config sets scoring.boundaries, and its fail_on
+grade, unless --fail-on is given.
+
+The platform API key is read from TOPOSCOPE_API_KEY. Pull request comments use
+GITHUB_TOKEN on GitHub Actions and GITLAB_TOKEN on GitLab CI; on Buildkite the
+summary is posted as a build annotation, and on Azure Pipelines as a build
+summary.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runCI(cmd.Context(), ciOpts{
+				score: scoreOpts{
+					baseRef:         baseRef,
+					headRef:         headRef,
+					repoPath:        repoPath,
+					bazelPath:       bazelPath,
+					bazelRC:         bazelRC,
+					useCQuery:       useCQuery,
+					bazelDiffJar:    bazelDiffJar,
+					normalize:       normalize,
+					includeExternal: includeExternal,
+					buildEvents:     buildEvents,
+					executionLog:    executionLog,
+				},
+				platformURL: platformURL,
+				failOn:      failOn,
+				failOnSet:   cmd.Flags().Changed("fail-on"),
+				comment:     comment,
+			})
+		},
+	}
+
+	cmd.Flags().StringVar(&baseRef, "base", "", "Base git ref (default: detected from the CI environment)")
+	cmd.Flags().StringVar(&headRef, "head", "", "Head git ref (default: detected from the CI environment)")
+	cmd.Flags().StringVar(&repoPath, "repo-path", "", "Path to repository root (default: detect workspace)")
+	cmd.Flags().StringVar(&bazelPath, "bazel-path", "", "Path to bazel/bazelisk binary")
+	cmd.Flags().StringVar(&bazelRC, "bazelrc", "", "Path to .bazelrc file")
+	cmd.Flags().BoolVar(&useCQuery, "cquery", false, "Use cquery instead of query")
+	cmd.Flags().StringVar(&bazelDiffJar, "bazel-diff-jar", "", "Path to bazel-diff.jar")
+	cmd.Flags().BoolVar(&normalize, "normalize", false, "Normalize the score by repository size before grading")
+	cmd.Flags().BoolVar(&includeExternal, "include-external", false, "Retain external dependencies as one node per external repo")
+	cmd.Flags().StringVar(&platformURL, "platform-url", os.Getenv("TOPOSCOPE_URL"), "Toposcope platform URL (default: $TOPOSCOPE_URL)")
+	cmd.Flags().StringVar(&failOn, "fail-on", "F", "Exit non-zero when the grade is this or worse (A-F, or none); overrides the repository's fail_on")
+	cmd.Flags().BoolVar(&comment, "comment", true, "Post the summary on the pull request")
+	cmd.Flags().StringVar(&buildEvents, "build-events", "", "Build event protocol JSON file from building head, for build durations")
+	cmd.Flags().StringVar(&executionLog, "execution-log", "", "JSON execution log from building head, for cache hit rates")
+
+	return cmd
+}
+
+type ciOpts struct {
+	score       scoreOpts
+	platformURL string
+	failOn      string
+	failOnSet   bool // --fail-on was given, overriding the repository's fail_on
+	comment     bool
+}
+
+// ciEnv describes the change under test as reported by the CI provider.
+type ciEnv struct {
+	Provider      string
+	Repo          string // owner/name
+	BaseRef       string
+	HeadSHA       string
+	Branch        string
+	DefaultBranch string
+	PRNumber      string
+
+	apiURL    string // provider API base URL, when known
+	projectID string // GitLab project ID
+}
+
+// IsPR reports whether the build is for a pull or merge request.
+func (e *ciEnv) IsPR() bool { return e.PRNumber != "" }
+
+func runCI(ctx context.Context, opts ciOpts) error {
+	if _, err := gradeRank(opts.failOn); err != nil {
+		return err
+	}
+
+	env := detectCIEnv(os.Getenv)
+	if opts.platformURL != "" && env.Repo != "" {
+		settings, err := platformRepoSettings(ctx, opts.platformURL, env.Repo)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: loading repository settings from platform: %v\n", err)
+		} else if settings != nil {
+			opts = applyRepoSettings(opts, settings)
+		}
+	}
+	failRank, err := gradeRank(opts.failOn)
+	if err != nil {
+		return fmt.Errorf("repository fail_on: %w", err)
+	}
+
+	opts.score.baseRef = firstNonEmpty(opts.score.baseRef, env.BaseRef)
+	opts.score.headRef = firstNonEmpty(opts.score.headRef, env.HeadSHA, "HEAD")
+	fmt.Fprintf(os.Stderr, "CI: %s, repo %s, base %s, head %s\n",
+		env.Provider, firstNonEmpty(env.Repo, "(unknown)"), opts.score.baseRef, opts.score.headRef)
+
+	run, err := scoreCommits(ctx, opts.score)
+	if err != nil {
+		return err
+	}
+	result := run.result
+	if err := (&surface.TerminalRenderer{}).Render(os.Stdout, result); err != nil {
+		return fmt.Errorf("rendering: %w", err)
+	}
+	writeStepSummary(result)
+
+	// Publishing is best effort: a platform or PR outage shouldn't mask the
+	// gate, which is what the pipeline actually depends on.
+	if opts.platformURL != "" {
+		if err := publishToPlatform(ctx, opts.platformURL, env, run); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: publishing to platform failed: %v\n", err)
+		}
+	}
+	if opts.comment {
+		if err := publishToPR(ctx, env, result); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: publishing PR summary failed: %v\n", err)
+		}
+	}
+
+	return checkGate(result, failRank, opts.failOn)
+}
+
+// checkGate returns an error when the result's grade is at or below the
+// gate's grade, of rank failRank. A negative rank disables the gate.
+func checkGate(result *scoring.ScoreResult, failRank int, failOn string) error {
+	if failRank < 0 {
+		return nil
+	}
+	if rank, _ := gradeRank(result.Grade); rank >= failRank {
+		return fmt.Errorf("toposcope gate failed: grade %s (score %.1f) is at or below %s",
+			result.Grade, result.TotalScore, strings.ToUpper(failOn))
+	}
+	return nil
+}
+
+// platformRepoSettings returns the platform's settings for repo, or nil if
+// the repository isn't registered yet.
+func platformRepoSettings(ctx context.Context, platformURL, repo string) (*client.RepoSettings, error) {
+	c, _, err := platformClient(ctx, platformURL)
+	if err != nil {
+		return nil, err
+	}
+	r, err := c.FindRepo(ctx, repo)
+	if err != nil || r == nil {
+		return nil, err
+	}
+	return c.GetRepoSettings(ctx, r.ID)
+}
+
+// applyRepoSettings applies the repository's boundaries, which the repo
+// config may override, and its fail_on grade unless --fail-on was given.
+func applyRepoSettings(opts ciOpts, settings *client.RepoSettings) ciOpts {
+	opts.score.boundaries = settings.Boundaries
+	if !opts.failOnSet && settings.FailOn != "" {
+		opts.failOn = settings.FailOn
+	}
+	return opts
+}
+
+// gradeRank orders grades from best (0) to worst. "none" returns -1.
+func gradeRank(grade string) (int, error) {
+	if strings.EqualFold(grade, "none") {
+		return -1, nil
+	}
+	i := strings.Index("ABCDF", strings.ToUpper(grade))
+	if len(grade) != 1 || i < 0 {
+		return 0, fmt.Errorf("invalid grade %q (want A, B, C, D, F, or none)", grade)
+	}
+	return i, nil
+}
+
+// detectCIEnv reads the CI provider's environment variables. When no
+// provider is detected it returns a "local" environment that scores
+// HEAD~1..HEAD.
+func detectCIEnv(getenv func(string) string) *ciEnv {
+	switch {
+	case strings.EqualFold(getenv("GITHUB_ACTIONS"), "true"):
+		env := &ciEnv{
+			Provider:      "github",
+			Repo:          getenv("GITHUB_REPOSITORY"),
+			HeadSHA:       getenv("GITHUB_SHA"),
+			Branch:        getenv("GITHUB_REF_NAME"),
+			DefaultBranch: githubDefaultBranch(getenv("GITHUB_EVENT_PATH")),
+			apiURL:        firstNonEmpty(getenv("GITHUB_API_URL"), "https://api.github.com"),
+		}
+		switch getenv("GITHUB_EVENT_NAME") {
+		case "pull_request", "pull_request_target":
+			// GITHUB_REF is refs/pull/<n>/merge.
+			parts := strings.Split(getenv("GITHUB_REF"), "/")
+			if len(parts) >= 3 && parts[1] == "pull" {
+				env.PRNumber = parts[2]
+			}
+			env.Branch = getenv("GITHUB_HEAD_REF")
+			env.BaseRef = "origin/" + getenv("GITHUB_BASE_REF")
+		default:
+			env.BaseRef = "HEAD~1"
+		}
+		return env
+
+	case strings.EqualFold(getenv("GITLAB_CI"), "true"):
+		env := &ciEnv{
+			Provider:      "gitlab",
+			Repo:          getenv("CI_PROJECT_PATH"),
+			HeadSHA:       getenv("CI_COMMIT_SHA"),
+			Branch:        getenv("CI_COMMIT_BRANCH"),
+			DefaultBranch: getenv("CI_DEFAULT_BRANCH"),
+			PRNumber:      getenv("CI_MERGE_REQUEST_IID"),
+			apiURL:        getenv("CI_API_V4_URL"),
+			projectID:     getenv("CI_PROJECT_ID"),
+		}
+		if env.IsPR() {
+			env.Branch = getenv("CI_MERGE_REQUEST_SOURCE_BRANCH_NAME")
+			env.BaseRef = firstNonEmpty(getenv("CI_MERGE_REQUEST_DIFF_BASE_SHA"),
+				"origin/"+getenv("CI_MERGE_REQUEST_TARGET_BRANCH_NAME"))
+		} else if before := getenv("CI_COMMIT_BEFORE_SHA"); before != "" && strings.Trim(before, "0") != "" {
+			env.BaseRef = before
+		} else {
+			env.BaseRef = "HEAD~1"
+		}
+		return env
+
+	case strings.EqualFold(getenv("BUILDKITE"), "true"):
+		env := &ciEnv{
+			Provider:      "buildkite",
+			Repo:          repoSlugFromURL(getenv("BUILDKITE_REPO")),
+			HeadSHA:       getenv("BUILDKITE_COMMIT"),
+			Branch:        getenv("BUILDKITE_BRANCH"),
+			DefaultBranch: getenv("BUILDKITE_PIPELINE_DEFAULT_BRANCH"),
+			BaseRef:       "HEAD~1",
+		}
+		if pr := getenv("BUILDKITE_PULL_REQUEST"); pr != "" && pr != "false" {
+			env.PRNumber = pr
+			env.BaseRef = "origin/" + getenv("BUILDKITE_PULL_REQUEST_BASE_BRANCH")
+		}
+		// Buildkite reports HEAD for builds triggered without a commit.
+		if env.HeadSHA == "HEAD" {
+			env.HeadSHA = ""
+		}
+		return env
+
+	// Azure Pipelines sets TF_BUILD=True, as do its Windows agents.
+	case strings.EqualFold(getenv("TF_BUILD"), "true"):
+		env := &ciEnv{
+			Provider: "azure",
+			Repo:     getenv("BUILD_REPOSITORY_NAME"),
+			HeadSHA:  getenv("BUILD_SOURCEVERSION"),
+			Branch:   strings.TrimPrefix(getenv("BUILD_SOURCEBRANCH"), "refs/heads/"),
+			BaseRef:  "HEAD~1",
+		}
+		// GitHub repositories report the PR number; Azure Repos the PR ID.
+		if pr := firstNonEmpty(getenv("SYSTEM_PULLREQUEST_PULLREQUESTNUMBER"), getenv("SYSTEM_PULLREQUEST_PULLREQUESTID")); pr != "" {
+			env.PRNumber = pr
+			env.Branch = strings.TrimPrefix(getenv("SYSTEM_PULLREQUEST_SOURCEBRANCH"), "refs/heads/")
+			env.BaseRef = "origin/" + strings.TrimPrefix(getenv("SYSTEM_PULLREQUEST_TARGETBRANCH"), "refs/heads/")
+		}
+		return env
+	}
+
+	return &ciEnv{Provider: "local", BaseRef: "HEAD~1", HeadSHA: "HEAD"}
+}
+
+// githubDefaultBranch reads repository.default_branch from the Actions event
+// payload, defaulting to main.
+func githubDefaultBranch(eventPath string) string {
+	if eventPath != "" {
+		if data, err := os.ReadFile(eventPath); err == nil {
+			var event struct {
+				Repository struct {
+					DefaultBranch string `json:"default_branch"`
+				} `json:"repository"`
+			}
+			if json.Unmarshal(data, &event) == nil && event.Repository.DefaultBranch != "" {
+				return event.Repository.DefaultBranch
+			}
+		}
+	}
+	return "main"
+}
+
+// repoSlugFromURL extracts owner/name from an SSH or HTTPS clone URL.
+func repoSlugFromURL(repoURL string) string {
+	s := strings.TrimSuffix(strings.TrimSpace(repoURL), ".git")
+	if i := strings.Index(s, "://"); i >= 0 {
+		s = s[i+3:]
+	}
+	s = strings.Replace(s, ":", "/", 1)
+	parts := strings.Split(strings.Trim(s, "/"), "/")
+	if len(parts) < 3 {
+		return ""
+	}
+	return parts[len(parts)-2] + "/" + parts[len(parts)-1]
+}
+
+// publishToPlatform uploads the snapshots and score to POST /api/v1/ingest,
+// signing the score if the runner has a signing key.
+func publishToPlatform(ctx context.Context, platformURL string, env *ciEnv, run *scoreRun) error {
+	if os.Getenv("TOPOSCOPE_API_KEY") == "" {
+		return fmt.Errorf("TOPOSCOPE_API_KEY is not set")
+	}
+	if env.Repo == "" {
+		return fmt.Errorf("repository name not detected from the CI environment")
+	}
+
+	signer, err := signerFromEnv(os.Getenv)
+	if err != nil {
+		return err
+	}
+	req := &client.IngestRequest{
+		RepoFullName:  env.Repo,
+		DefaultBranch: env.DefaultBranch,
+		CommitSHA:     run.headSHA,
+		Branch:        env.Branch,
+		CommittedAt:   gitCommitTime(ctx, run.wsRoot, run.headSHA),
+		Snapshot:      run.headSnap,
+		Score:         run.result,
+		BaseSnapshot:  run.baseSnap,
+	}
+	if pr, err := strconv.Atoi(env.PRNumber); err == nil {
+		req.PRNumber = &pr
+	}
+	if err := signer.sign(req); err != nil {
+		return err
+	}
+
+	c, _, err := platformClient(ctx, platformURL)
+	if err != nil {
+		return err
+	}
+	resp, err := c.Ingest(ctx, req)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Published to %s (snapshot %s, score %s)\n", platformURL, resp.SnapshotID, resp.ScoreID)
+	return nil
+}
+
+// platformClient returns a client for the platform at platformURL, with
+// credentials from the environment, after checking that the platform accepts
+// this version of toposcope. meta is nil for platforms that predate the
+// check.
+func platformClient(ctx context.Context, platformURL string) (c *client.Client, meta *client.Meta, err error) {
+	c = client.FromEnv(platformURL)
+	if meta, err = c.CheckCompatible(ctx); err != nil {
+		return nil, nil, err
+	}
+	return c, meta, nil
+}
+
+// publishToPR posts the markdown summary to the pull request (GitHub),
+// merge request (GitLab), build annotations (Buildkite), or build summary
+// (Azure Pipelines).
+func publishToPR(ctx context.Context, env *ciEnv, result *scoring.ScoreResult) error {
+	data := (&surface.CheckRunRenderer{}).BuildCheckRunData(result)
+
+	switch env.Provider {
+	case "github":
+		token := os.Getenv("GITHUB_TOKEN")
+		if !env.IsPR() || token == "" {
+			return nil
+		}
+		body, _ := json.Marshal(map[string]string{"body": data.Summary})
+		_, err := postJSON(ctx, fmt.Sprintf("%s/repos/%s/issues/%s/comments", env.apiURL, env.Repo, env.PRNumber),
+			map[string]string{"Authorization": "Bearer " + token, "Accept": "application/vnd.github+json"}, body)
+		return err
+
+	case "gitlab":
+		token := os.Getenv("GITLAB_TOKEN")
+		if !env.IsPR() || token == "" || env.apiURL == "" {
+			return nil
+		}
+		body, _ := json.Marshal(map[string]string{"body": data.Summary})
+		_, err := postJSON(ctx, fmt.Sprintf("%s/projects/%s/merge_requests/%s/notes",
+			env.apiURL, url.PathEscape(firstNonEmpty(env.projectID, env.Repo)), env.PRNumber),
+			map[string]string{"PRIVATE-TOKEN": token}, body)
+		return err
+
+	case "buildkite":
+		style := map[string]string{"success": "success", "neutral": "warning"}[data.Conclusion]
+		cmd := exec.CommandContext(ctx, "buildkite-agent", "annotate",
+			"--context", "toposcope", "--style", firstNonEmpty(style, "error"))
+		cmd.Stdin = strings.NewReader(data.Summary)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			return fmt.Errorf("buildkite-agent annotate: %w: %s", err, strings.TrimSpace(string(out)))
+		}
+
+	case "azure":
+		// The summary file must outlive the step, so it goes in the agent's
+		// temp directory, which the agent cleans up.
+		f, err := os.CreateTemp(firstNonEmpty(os.Getenv("AGENT_TEMPDIRECTORY"), os.TempDir()), "toposcope-*.md")
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		if _, err := f.WriteString(data.Summary); err != nil {
+			return err
+		}
+		fmt.Printf("##vso[task.uploadsummary]%s\n", f.Name())
+	}
+	return nil
+}
+
+// postJSON POSTs body and returns the response body, treating non-2xx
+// statuses as errors.
+func postJSON(ctx context.Context, endpoint string, headers map[string]string, body []byte) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Minute)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("POST %s: HTTP %d: %s", endpoint, resp.StatusCode, strings.TrimSpace(string(respBody)))
+	}
+	return respBody, nil
+}
+
+// gitCommitTime returns the committer date of sha in RFC3339, or "" if it
+// can't be read.
+func gitCommitTime(ctx context.Context, dir, sha string) string {
+	cmd := exec.CommandContext(ctx, "git", "show", "-s", "--format=%cI", sha)
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
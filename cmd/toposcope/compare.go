@@ -0,0 +1,135 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/toposcope/toposcope/pkg/graphquery"
+)
+
+func newCompareCmd() *cobra.Command {
+	var (
+		repoPath  string
+		top       int
+		outputFmt string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "compare <base> <head>",
+		Short: "Compare two cached snapshots structurally",
+		Long: `Loads two snapshots, each given as a snapshot file path or a commit SHA in
+the snapshot cache, and prints the biggest fan-in and fan-out changes, added
+and removed packages, and changed cross-boundary edge counts. Nothing is
+extracted, so bazel is not needed.`,
+		Args: cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runCompare(compareOpts{
+				repoPath:  repoPath,
+				baseRef:   args[0],
+				headRef:   args[1],
+				top:       top,
+				outputFmt: outputFmt,
+			})
+		},
+	}
+
+	cmd.Flags().StringVar(&repoPath, "repo-path", "", "Path to repository root (default: detect workspace)")
+	cmd.Flags().IntVar(&top, "top", 10, "Number of targets to list per degree ranking")
+	cmd.Flags().StringVar(&outputFmt, "output", "text", "Output format: text or json")
+
+	return cmd
+}
+
+type compareOpts struct {
+	repoPath  string
+	baseRef   string
+	headRef   string
+	top       int
+	outputFmt string
+}
+
+func runCompare(opts compareOpts) error {
+	base, err := resolveReportSnapshot(opts.repoPath, opts.baseRef)
+	if err != nil {
+		return fmt.Errorf("base: %w", err)
+	}
+	head, err := resolveReportSnapshot(opts.repoPath, opts.headRef)
+	if err != nil {
+		return fmt.Errorf("head: %w", err)
+	}
+
+	report := graphquery.Compare(base, head, opts.top)
+
+	switch opts.outputFmt {
+	case "json":
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(report); err != nil {
+			return fmt.Errorf("encoding JSON: %w", err)
+		}
+	case "text", "":
+		printComparisonText(os.Stdout, report)
+	default:
+		return fmt.Errorf("unknown output format %q (want text or json)", opts.outputFmt)
+	}
+
+	return nil
+}
+
+func printComparisonText(w io.Writer, report *graphquery.ComparisonReport) {
+	label := func(sha, id string) string {
+		if sha != "" {
+			return sha[:minInt(7, len(sha))]
+		}
+		return id
+	}
+	fmt.Fprintf(w, "Comparison: %s -> %s\n", label(report.BaseCommitSHA, report.BaseSnapshotID), label(report.HeadCommitSHA, report.HeadSnapshotID))
+	fmt.Fprintf(w, "  Nodes:    %d -> %d\n", report.BaseStats.NodeCount, report.HeadStats.NodeCount)
+	fmt.Fprintf(w, "  Edges:    %d -> %d\n", report.BaseStats.EdgeCount, report.HeadStats.EdgeCount)
+	fmt.Fprintf(w, "  Packages: %d -> %d\n", report.BaseStats.PackageCount, report.HeadStats.PackageCount)
+
+	for _, sec := range []struct {
+		title   string
+		entries []graphquery.DegreeChange
+	}{
+		{"Fan-in changes", report.FanIn},
+		{"Fan-out changes", report.FanOut},
+	} {
+		fmt.Fprintf(w, "\n%s:\n", sec.title)
+		if len(sec.entries) == 0 {
+			fmt.Fprintf(w, "  (none)\n")
+			continue
+		}
+		for i, e := range sec.entries {
+			fmt.Fprintf(w, "  %2d. %-60s %4d -> %-4d (%+d)\n", i+1, e.Key, e.Base, e.Head, e.Change)
+		}
+	}
+
+	for _, sec := range []struct {
+		title, mark string
+		pkgs        []string
+	}{
+		{"New packages", "+", report.AddedPackages},
+		{"Removed packages", "-", report.RemovedPackages},
+	} {
+		fmt.Fprintf(w, "\n%s:\n", sec.title)
+		if len(sec.pkgs) == 0 {
+			fmt.Fprintf(w, "  (none)\n")
+			continue
+		}
+		for _, p := range sec.pkgs {
+			fmt.Fprintf(w, "  %s %s\n", sec.mark, p)
+		}
+	}
+
+	fmt.Fprintf(w, "\nBoundary edge changes:\n")
+	if len(report.Boundaries) == 0 {
+		fmt.Fprintf(w, "  (none)\n")
+	}
+	for _, b := range report.Boundaries {
+		fmt.Fprintf(w, "  %-40s %4d -> %-4d (%+d)\n", b.From+" -> "+b.To, b.Base, b.Head, b.Change)
+	}
+}
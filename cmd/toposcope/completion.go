@@ -0,0 +1,128 @@
+package main
+
+import (
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/toposcope/toposcope/pkg/config"
+	"github.com/toposcope/toposcope/pkg/graph"
+	"github.com/toposcope/toposcope/pkg/scoring"
+)
+
+// maxLabelCompletions is how many labels a completion lists before it
+// completes one path segment at a time instead.
+const maxLabelCompletions = 100
+
+// completeTargets completes target labels from the snapshot named by the
+// command's --snapshot flag, or else the latest cached snapshot of the
+// workspace named by --repo-path.
+func completeTargets(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	snap := completionSnapshot(cmd)
+	if snap == nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	keys := make([]string, 0, len(snap.Nodes))
+	for key := range snap.Nodes {
+		keys = append(keys, key)
+	}
+	return completeLabels(keys, toComplete)
+}
+
+// completionSnapshot loads the snapshot to complete from. Completion runs on
+// every TAB, so failures are silent.
+func completionSnapshot(cmd *cobra.Command) *graph.Snapshot {
+	var repoPath, ref string
+	if f := cmd.Flags().Lookup("repo-path"); f != nil {
+		repoPath = f.Value.String()
+	}
+	if f := cmd.Flags().Lookup("snapshot"); f != nil {
+		ref = f.Value.String()
+	}
+	if ref != "" {
+		if _, err := os.Stat(ref); err == nil {
+			snap, _ := graph.LoadSnapshot(ref)
+			return snap
+		}
+	}
+	wsRoot, err := resolveWorkspace(repoPath)
+	if err != nil {
+		return nil
+	}
+	var snap *graph.Snapshot
+	if ref != "" {
+		snap, _ = loadCachedSnapshot(wsRoot, ref)
+	} else {
+		snap, _ = latestCachedSnapshot(wsRoot)
+	}
+	return snap
+}
+
+// completeLabels returns the labels among keys that start with toComplete.
+// When there are more than maxLabelCompletions, they are cut after the next
+// path segment or package ("//app/" or "//app/server:"), so each TAB goes
+// one level deeper, and the shell is told not to add a space.
+func completeLabels(keys []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	var matches []string
+	for _, key := range keys {
+		if strings.HasPrefix(key, toComplete) {
+			matches = append(matches, key)
+		}
+	}
+	sort.Strings(matches)
+	if len(matches) <= maxLabelCompletions {
+		return matches, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	seen := make(map[string]bool)
+	var cut []string
+	for _, key := range matches {
+		rest := key[len(toComplete):]
+		// Skip the "//" after a repository name or at the start.
+		skip := 0
+		if i := strings.Index(rest, "//"); i >= 0 && !strings.ContainsAny(rest[:i], "/:") {
+			skip = i + 2
+		}
+		if i := strings.IndexAny(rest[skip:], "/:"); i >= 0 {
+			key = toComplete + rest[:skip+i+1]
+		}
+		if !seen[key] {
+			seen[key] = true
+			cut = append(cut, key)
+		}
+	}
+	return cut, cobra.ShellCompDirectiveNoFileComp | cobra.ShellCompDirectiveNoSpace
+}
+
+// completeMetrics completes metric keys, described by their names.
+func completeMetrics(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	cfg := config.DefaultConfig()
+	var repoPath string
+	if f := cmd.Flags().Lookup("repo-path"); f != nil {
+		repoPath = f.Value.String()
+	}
+	if wsRoot, err := resolveWorkspace(repoPath); err == nil {
+		cfg = loadConfig(wsRoot)
+	}
+	var completions []string
+	add := func(key, name string) {
+		if key != "" && strings.HasPrefix(key, toComplete) {
+			completions = append(completions, key+"\t"+name)
+		}
+	}
+	metrics, err := configuredMetrics(cfg)
+	if err != nil {
+		metrics = scoring.DefaultMetrics()
+	}
+	for _, m := range metrics {
+		add(m.Key(), m.Name())
+	}
+	for _, em := range cfg.Scoring.ExternalMetrics {
+		add(em.Key, firstNonEmpty(em.Name, em.Key))
+	}
+	return completions, cobra.ShellCompDirectiveNoFileComp
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/toposcope/toposcope/pkg/config"
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	// configSets holds the root --set flags.
+	configSets []string
+	// configOverrides are the TOPOSCOPE_* environment overrides followed by
+	// the --set flags, so the flags win. loadConfig applies them.
+	configOverrides []config.Override
+)
+
+// parseConfigOverrides collects the overrides from environ and the --set
+// flags, and checks that each names a config key and has a value it takes.
+func parseConfigOverrides(environ, sets []string) ([]config.Override, error) {
+	overrides, err := config.EnvOverrides(environ)
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range sets {
+		o, err := config.ParseSet(s)
+		if err != nil {
+			return nil, err
+		}
+		overrides = append(overrides, o)
+	}
+	if err := config.Apply(config.DefaultConfig(), overrides); err != nil {
+		return nil, err
+	}
+	return overrides, nil
+}
+
+func newConfigCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "config",
+		Short: "Check the workspace's .toposcope/config.yaml",
+	}
+
+	cmd.AddCommand(newConfigValidateCmd())
+
+	return cmd
+}
+
+func newConfigValidateCmd() *cobra.Command {
+	var repoPath, file, outputFmt string
+
+	cmd := &cobra.Command{
+		Use:   "validate",
+		Short: "Validate the config file and print the effective config",
+		Long: `Checks .toposcope/config.yaml (or --file) for unknown keys, values of the
+wrong type, unknown weights and grades, bad glob patterns and regular
+expressions, and incomplete waivers, external metrics, and remote caches.
+
+Prints the effective config: the file merged over the defaults, with the
+TOPOSCOPE_* environment and --set overrides applied and every scoring weight
+and grade threshold resolved. Exits non-zero when there are
+problems. The schema of the file is published as schemas/config.schema.json
+(toposcope schema config).`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runConfigValidate(os.Stdout, repoPath, file, outputFmt, configOverrides)
+		},
+	}
+
+	cmd.Flags().StringVar(&repoPath, "repo-path", "", "Path to repository root (default: detect workspace)")
+	cmd.Flags().StringVar(&file, "file", "", "Config file to validate (default: the workspace's .toposcope/config.yaml)")
+	cmd.Flags().StringVar(&outputFmt, "output", "yaml", "Output format: yaml or json")
+
+	return cmd
+}
+
+type configValidation struct {
+	File     string           `json:"file,omitempty"` // empty when the workspace has no config file
+	Valid    bool             `json:"valid"`
+	Problems []config.Problem `json:"problems"`
+	Config   *config.Config   `json:"-"`
+}
+
+// MarshalJSON writes the config with its yaml key names.
+func (v *configValidation) MarshalJSON() ([]byte, error) {
+	type plain configValidation
+	data, err := yaml.Marshal(v.Config)
+	if err != nil {
+		return nil, err
+	}
+	var cfg map[string]any
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, err
+	}
+	return json.Marshal(struct {
+		*plain
+		Config map[string]any `json:"config"`
+	}{(*plain)(v), cfg})
+}
+
+func runConfigValidate(w io.Writer, repoPath, file, outputFmt string, overrides []config.Override) error {
+	if outputFmt != "yaml" && outputFmt != "json" {
+		return fmt.Errorf("unknown output format %q (want yaml or json)", outputFmt)
+	}
+	if file == "" {
+		wsRoot, err := resolveWorkspace(repoPath)
+		if err != nil {
+			return err
+		}
+		file = config.FindConfigFile(wsRoot)
+	}
+
+	v, err := validateConfigFile(file, overrides)
+	if err != nil {
+		return err
+	}
+
+	if outputFmt == "json" {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(v); err != nil {
+			return fmt.Errorf("encoding JSON: %w", err)
+		}
+	} else {
+		name := v.File
+		if name == "" {
+			name = "(defaults)"
+			fmt.Fprintln(os.Stderr, "No .toposcope/config.yaml; showing the defaults")
+		}
+		for _, p := range v.Problems {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", name, p)
+		}
+		if v.Valid {
+			enc := yaml.NewEncoder(w)
+			enc.SetIndent(2)
+			if err := enc.Encode(v.Config); err != nil {
+				return fmt.Errorf("encoding YAML: %w", err)
+			}
+			if err := enc.Close(); err != nil {
+				return fmt.Errorf("encoding YAML: %w", err)
+			}
+		}
+	}
+
+	if !v.Valid {
+		return fmt.Errorf("config validation failed: %d problem(s)", len(v.Problems))
+	}
+	return nil
+}
+
+// validateConfigFile validates the config file at path, or the defaults
+// when path is empty, with overrides applied. It includes the scoring
+// settings only the scoring engine can check. The config is resolved when
+// there are no problems.
+func validateConfigFile(path string, overrides []config.Override) (*configValidation, error) {
+	v := &configValidation{File: path, Problems: []config.Problem{}}
+	var data []byte
+	if path != "" {
+		var err error
+		if data, err = os.ReadFile(path); err != nil {
+			return nil, fmt.Errorf("reading config: %w", err)
+		}
+	}
+	cfg, problems, err := config.Validate(data, overrides...)
+	if err != nil {
+		return nil, err
+	}
+	v.Problems = append(v.Problems, problems...)
+
+	v.Problems = append(v.Problems, weightProblems(cfg.Scoring.Weights)...)
+	grades, err := gradeThresholds(cfg)
+	if err != nil {
+		v.Problems = append(v.Problems, config.Problem{Path: "scoring.grade_thresholds", Message: err.Error()})
+	}
+	v.Valid = len(v.Problems) == 0
+	if v.Valid {
+		// Resolve the weights and grades, so the output lists every one.
+		weights, _ := configuredWeights(cfg)
+		data, _ := json.Marshal(weights)
+		resolved := map[string]float64{}
+		_ = json.Unmarshal(data, &resolved)
+		cfg.Scoring.Weights = resolved
+		cfg.Scoring.GradeThresholds = map[string]float64{"A": grades.A, "B": grades.B, "C": grades.C, "D": grades.D}
+	}
+	v.Config = cfg
+	return v, nil
+}
+
+// weightProblems checks each of the scoring.weights overrides: that it names
+// a weight, fits its type, and has the weight's sign. Credits are negative
+// and every other weight and threshold is not.
+func weightProblems(weights map[string]float64) []config.Problem {
+	var problems []config.Problem
+	for _, key := range sortedKeys(weights) {
+		value := weights[key]
+		at := "scoring.weights." + key
+		if _, err := configuredWeights(&config.Config{Scoring: config.ScoringConfig{Weights: map[string]float64{key: value}}}); err != nil {
+			msg := "unknown weight"
+			if !strings.Contains(err.Error(), "unknown field") {
+				msg = "must be a whole number"
+			}
+			problems = append(problems, config.Problem{Path: at, Message: msg})
+			continue
+		}
+		if credit := strings.HasPrefix(key, "credit_"); credit && value > 0 {
+			problems = append(problems, config.Problem{Path: at, Message: "credits must not be positive"})
+		} else if !credit && value < 0 {
+			problems = append(problems, config.Problem{Path: at, Message: "must not be negative"})
+		}
+	}
+	return problems
+}
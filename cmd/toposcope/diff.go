@@ -11,54 +11,84 @@ import (
 	"github.com/toposcope/toposcope/pkg/config"
 	"github.com/toposcope/toposcope/pkg/extract"
 	"github.com/toposcope/toposcope/pkg/extract/bazeldiff"
+	"github.com/toposcope/toposcope/pkg/extract/gitdiff"
 	"github.com/toposcope/toposcope/pkg/extract/subgraph"
 	"github.com/toposcope/toposcope/pkg/graph"
 )
 
 func newDiffCmd() *cobra.Command {
 	var (
-		baseRef   string
-		headRef   string
-		repoPath  string
-		bazelPath string
-		bazelRC   string
-		useCQuery bool
+		baseRef         string
+		headRef         string
+		repoPath        string
+		bazelPath       string
+		bazelRC         string
+		useCQuery       bool
+		includeExternal bool
+		baseSnapshot    string
+		headSnapshot    string
+		mergeBase       bool
 	)
 
 	cmd := &cobra.Command{
 		Use:   "diff",
 		Short: "Compare two snapshots and compute a structural delta",
-		Long:  `Detects changed targets between two commits and computes structural differences.`,
+		Long: `Detects changed targets between two commits and computes structural differences.
+
+When --base names a branch, the head is compared with the merge base of the
+two rather than the branch tip. --merge-base=false compares against the tip.
+
+--base-snapshot and --head-snapshot take a snapshot file, or - to read one
+from stdin, such as the output of toposcope snapshot --output -. The side
+given is used as is instead of being extracted, and its commit comes from
+the snapshot.`,
 		RunE: func(cmd *cobra.Command, args []string) error {
+			if baseRef == "" && baseSnapshot == "" {
+				return fmt.Errorf(`required flag(s) "base" not set`)
+			}
+			if err := checkSnapshotInputs(cmd, baseRef, baseSnapshot, headSnapshot); err != nil {
+				return err
+			}
 			return runDiff(cmd.Context(), diffOpts{
-				baseRef:   baseRef,
-				headRef:   headRef,
-				repoPath:  repoPath,
-				bazelPath: bazelPath,
-				bazelRC:   bazelRC,
-				useCQuery: useCQuery,
+				baseRef:         baseRef,
+				headRef:         headRef,
+				repoPath:        repoPath,
+				bazelPath:       bazelPath,
+				bazelRC:         bazelRC,
+				useCQuery:       useCQuery,
+				includeExternal: includeExternal,
+				baseSnapshot:    baseSnapshot,
+				headSnapshot:    headSnapshot,
+				tipToTip:        !mergeBase,
 			})
 		},
 	}
 
-	cmd.Flags().StringVar(&baseRef, "base", "", "Base git ref (required)")
+	cmd.Flags().StringVar(&baseRef, "base", "", "Base git ref (required unless --base-snapshot)")
 	cmd.Flags().StringVar(&headRef, "head", "HEAD", "Head git ref")
 	cmd.Flags().StringVar(&repoPath, "repo-path", "", "Path to repository root (default: detect workspace)")
 	cmd.Flags().StringVar(&bazelPath, "bazel-path", "", "Path to bazel/bazelisk binary")
 	cmd.Flags().StringVar(&bazelRC, "bazelrc", "", "Path to .bazelrc file")
 	cmd.Flags().BoolVar(&useCQuery, "cquery", false, "Use cquery instead of query")
-	_ = cmd.MarkFlagRequired("base")
+	cmd.Flags().BoolVar(&includeExternal, "include-external", false, "Retain external dependencies as one node per external repo")
+	cmd.Flags().StringVar(&baseSnapshot, "base-snapshot", "", "Base snapshot file, or - for stdin, instead of extracting --base")
+	cmd.Flags().StringVar(&headSnapshot, "head-snapshot", "", "Head snapshot file, or - for stdin, instead of extracting --head")
+	cmd.Flags().BoolVar(&mergeBase, "merge-base", true, "Compare with the merge base with head when --base is a branch")
 
 	return cmd
 }
 
 type diffOpts struct {
-	baseRef   string
-	headRef   string
-	repoPath  string
-	bazelPath string
-	bazelRC   string
-	useCQuery bool
+	baseRef         string
+	headRef         string
+	repoPath        string
+	bazelPath       string
+	bazelRC         string
+	useCQuery       bool
+	includeExternal bool
+	baseSnapshot    string // a file or "-" for stdin, instead of baseRef
+	headSnapshot    string // likewise for headRef
+	tipToTip        bool   // compare with baseRef itself even when it is a branch
 }
 
 func runDiff(ctx context.Context, opts diffOpts) error {
@@ -68,68 +98,87 @@ func runDiff(ctx context.Context, opts diffOpts) error {
 	}
 
 	cfg := loadConfig(wsRoot)
-	bp := firstNonEmpty(opts.bazelPath, cfg.Extraction.BazelPath, "bazelisk")
-	brc := firstNonEmpty(opts.bazelRC, cfg.Extraction.BazelRC)
+	bp := bazelBinary(opts.bazelPath, cfg)
+	brc := bazelRCPath(wsRoot, opts.bazelRC, cfg)
 	cq := opts.useCQuery || cfg.Extraction.UseCQuery
+	ie := opts.includeExternal || cfg.Extraction.IncludeExternal
 
-	// Resolve git refs to SHAs
-	baseSHA, err := gitRevParse(ctx, wsRoot, opts.baseRef)
-	if err != nil {
+	// Resolve git refs to SHAs, or read the snapshots given instead.
+	var baseSHA, headSHA string
+	var baseSnap, headSnap *graph.Snapshot
+	if opts.baseSnapshot != "" {
+		if baseSnap, err = readSnapshotInput("base-snapshot", opts.baseSnapshot); err != nil {
+			return err
+		}
+		baseSHA = baseSnap.CommitSHA
+	} else if baseSHA, err = gitRevParse(ctx, wsRoot, opts.baseRef); err != nil {
 		return fmt.Errorf("resolving base ref: %w", err)
 	}
-	headSHA, err := gitRevParse(ctx, wsRoot, opts.headRef)
-	if err != nil {
+	if opts.headSnapshot != "" {
+		if headSnap, err = readSnapshotInput("head-snapshot", opts.headSnapshot); err != nil {
+			return err
+		}
+		headSHA = headSnap.CommitSHA
+	} else if headSHA, err = gitRevParse(ctx, wsRoot, opts.headRef); err != nil {
 		return fmt.Errorf("resolving head ref: %w", err)
 	}
 
+	if baseSnap == nil && !opts.tipToTip {
+		baseSHA = branchMergeBase(ctx, wsRoot, opts.baseRef, baseSHA, headSHA)
+	}
+
 	fmt.Fprintf(os.Stderr, "Computing diff: %s..%s\n", baseSHA[:minInt(7, len(baseSHA))], headSHA[:minInt(7, len(headSHA))])
 
 	cacheDir := config.HashCacheDir(wsRoot)
 	timeout := time.Duration(cfg.Extraction.Timeout) * time.Second
+	ext := &subgraph.Extractor{
+		WorkspacePath:    wsRoot,
+		BazelPath:        bp,
+		BazelRC:          brc,
+		UseCQuery:        cq,
+		EdgeAttributes:   extract.EdgeAttributes(cfg.Extraction.EdgeAttributes),
+		IncludeExternal:  ie,
+		RepoMapping:      cfg.Extraction.RepoMapping,
+		Generated:        generatedPatterns(cfg),
+		LabelRewrites:    labelRewrites(cfg),
+		Limits:           extractionLimits(cfg),
+		OutputBase:       cfg.Extraction.OutputBaseDir(wsRoot, ""),
+		KeepServer:       cfg.Extraction.KeepServer,
+		ShardParallelism: cfg.Extraction.ShardParallelism,
+		ProfileDir:       cfg.Extraction.ProfileDir,
+	}
+	rc := openRemoteCache(ctx, wsRoot, cfg, ext)
 
 	// Try to load cached snapshots
-	baseSnap, err := loadCachedSnapshot(wsRoot, baseSHA)
-	if err != nil {
+	if baseSnap == nil {
+		baseSnap, _ = rc.loadSnapshot(ctx, wsRoot, baseSHA)
+	}
+	if baseSnap == nil {
 		fmt.Fprintf(os.Stderr, "Extracting base snapshot...\n")
-		ext := &subgraph.Extractor{
-			WorkspacePath: wsRoot,
-			BazelPath:     bp,
-			BazelRC:       brc,
-			UseCQuery:     cq,
-		}
 		baseSnap, err = ext.ExtractFull(ctx, baseSHA, timeout)
 		if err != nil {
 			return fmt.Errorf("extracting base snapshot: %w", err)
 		}
-		saveCachedSnapshot(wsRoot, baseSHA, baseSnap)
+		warnSkippedPackages(baseSnap)
+		rc.saveSnapshot(ctx, wsRoot, baseSHA, baseSnap)
 	}
 
-	headSnap, err := loadCachedSnapshot(wsRoot, headSHA)
-	if err != nil {
+	if headSnap == nil {
+		headSnap, _ = rc.loadSnapshot(ctx, wsRoot, headSHA)
+	}
+	if headSnap == nil {
 		fmt.Fprintf(os.Stderr, "Extracting head snapshot...\n")
-		ext := &subgraph.Extractor{
-			WorkspacePath: wsRoot,
-			BazelPath:     bp,
-			BazelRC:       brc,
-			UseCQuery:     cq,
-		}
 		headSnap, err = ext.ExtractFull(ctx, headSHA, timeout)
 		if err != nil {
 			return fmt.Errorf("extracting head snapshot: %w", err)
 		}
-		saveCachedSnapshot(wsRoot, headSHA, headSnap)
+		warnSkippedPackages(headSnap)
+		rc.saveSnapshot(ctx, wsRoot, headSHA, headSnap)
 	}
 
-	// Run change detection for impacted targets
-	runner := &bazeldiff.Runner{
-		WorkspacePath: wsRoot,
-		BazelPath:     bp,
-		BazelRC:       brc,
-		UseCQuery:     cq,
-		CacheDir:      cacheDir,
-	}
-
-	cdResult, err := runner.DetectChanges(ctx, extract.ChangeDetectionRequest{
+	// Run change detection for impacted targets. The native detector only
+	// needs git and the base snapshot; bazel-diff covers what it can't.
+	cdReq := extract.ChangeDetectionRequest{
 		RepoPath:  wsRoot,
 		BaseSHA:   baseSHA,
 		HeadSHA:   headSHA,
@@ -137,9 +186,28 @@ func runDiff(ctx context.Context, opts diffOpts) error {
 		BazelRC:   brc,
 		UseCQuery: cq,
 		CacheDir:  cacheDir,
-	})
+	}
+	detector := &gitdiff.Detector{WorkspacePath: wsRoot, Base: baseSnap}
+	cdResult, err := detector.DetectChanges(ctx, cdReq)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Warning: bazel-diff change detection failed: %v\nFalling back to structural diff only.\n", err)
+		fmt.Fprintf(os.Stderr, "Native change detection unavailable (%v); trying bazel-diff.\n", err)
+		runner := &bazeldiff.Runner{
+			WorkspacePath: wsRoot,
+			BazelPath:     bp,
+			BazelRC:       brc,
+			UseCQuery:     cq,
+			CacheDir:      cacheDir,
+			CacheLimits:   hashCacheLimits(cfg),
+			OutputBase:    cfg.Extraction.OutputBaseDir(wsRoot, ""),
+			KeepServer:    cfg.Extraction.KeepServer,
+		}
+		present := rc.fetchHashes(ctx, runner.HashFilePath(baseSHA), runner.HashFilePath(headSHA))
+		cdResult, err = runner.DetectChanges(ctx, cdReq)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: bazel-diff change detection failed: %v\nFalling back to structural diff only.\n", err)
+		} else {
+			rc.pushHashes(ctx, present, cdResult.BaseHashFile, cdResult.HeadHashFile)
+		}
 	}
 
 	// Compute delta
@@ -160,6 +228,37 @@ func loadCachedSnapshot(wsRoot, sha string) (*graph.Snapshot, error) {
 	return graph.LoadSnapshot(path)
 }
 
+// latestCachedSnapshot loads the most recently written snapshot from the
+// snapshot cache directory.
+func latestCachedSnapshot(wsRoot string) (*graph.Snapshot, error) {
+	dir := config.SnapshotDir(wsRoot)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("reading snapshot cache: %w", err)
+	}
+
+	var latest string
+	var latestMod time.Time
+	for _, e := range entries {
+		if e.IsDir() || filepath.Ext(e.Name()) != ".json" {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		if latest == "" || info.ModTime().After(latestMod) {
+			latest = e.Name()
+			latestMod = info.ModTime()
+		}
+	}
+	if latest == "" {
+		return nil, fmt.Errorf("no cached snapshots in %s; run `toposcope snapshot` first", dir)
+	}
+
+	return graph.LoadSnapshot(filepath.Join(dir, latest))
+}
+
 func saveCachedSnapshot(wsRoot, sha string, snap *graph.Snapshot) {
 	path := filepath.Join(config.SnapshotDir(wsRoot), sha+".json")
 	if err := graph.SaveSnapshot(path, snap); err != nil {
@@ -0,0 +1,280 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"unicode/utf8"
+
+	"github.com/spf13/cobra"
+	"github.com/toposcope/toposcope/pkg/config"
+	"github.com/toposcope/toposcope/pkg/scoring"
+)
+
+// explainEvidence is how many evidence items the worked example lists.
+const explainEvidence = 5
+
+func newExplainCmd() *cobra.Command {
+	var repoPath, outputFmt string
+
+	cmd := &cobra.Command{
+		Use:   "explain <metric>",
+		Short: "Explain a scoring metric with the workspace's configuration",
+		Long: `Prints what a metric scores, the weights and thresholds it has with the
+workspace's resolved configuration, and a worked example from the most
+recent saved score: the metric's contribution, the findings behind it, and
+any settings that have changed since.
+
+The metric is named by key, e.g. fanout_increase, or by name. External
+metrics from config can be explained too.`,
+		Args:              cobra.ExactArgs(1),
+		ValidArgsFunction: completeMetrics,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if outputFmt != "text" && outputFmt != "json" {
+				return fmt.Errorf("explain: unknown --output %q (want text or json)", outputFmt)
+			}
+			wsRoot, err := resolveWorkspace(repoPath)
+			if err != nil {
+				return err
+			}
+			ex, err := explainMetric(wsRoot, loadConfig(wsRoot), args[0])
+			if err != nil {
+				return err
+			}
+			if outputFmt == "json" {
+				enc := json.NewEncoder(os.Stdout)
+				enc.SetIndent("", "  ")
+				return enc.Encode(ex)
+			}
+			printExplanation(os.Stdout, ex)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&repoPath, "repo-path", "", "Path to Bazel workspace root")
+	cmd.Flags().StringVar(&outputFmt, "output", "text", "Output format: text or json")
+
+	return cmd
+}
+
+type explanation struct {
+	Key         string         `json:"key"`
+	Name        string         `json:"name"`
+	Description string         `json:"description,omitempty"`
+	Config      map[string]any `json:"config,omitempty"`
+	Example     *explainResult `json:"example,omitempty"` // from the most recent saved score
+}
+
+type explainResult struct {
+	BaseCommit string                `json:"base_commit"`
+	HeadCommit string                `json:"head_commit"`
+	AnalyzedAt string                `json:"analyzed_at,omitempty"`
+	Grade      string                `json:"grade"`
+	TotalScore float64               `json:"total_score"`
+	Result     *scoring.MetricResult `json:"result,omitempty"` // nil when the score lacks the metric
+	Suppressed int                   `json:"suppressed,omitempty"`
+}
+
+// explainMetric looks up the metric named key or name among those cfg
+// configures and pairs it with its result in the latest score of wsRoot.
+func explainMetric(wsRoot string, cfg *config.Config, name string) (*explanation, error) {
+	metrics, err := configuredMetrics(cfg)
+	if err != nil {
+		return nil, err
+	}
+	metrics = append(metrics, externalMetrics(wsRoot, cfg)...)
+	var metric scoring.Metric
+	var keys []string
+	for _, m := range metrics {
+		keys = append(keys, m.Key())
+		if m.Key() == name || strings.EqualFold(m.Name(), name) {
+			metric = m
+		}
+	}
+	if metric == nil {
+		return nil, fmt.Errorf("explain: unknown metric %q (want one of %s)", name, strings.Join(keys, ", "))
+	}
+
+	ex := &explanation{Key: metric.Key(), Name: metric.Name()}
+	if d, ok := metric.(scoring.Describer); ok {
+		ex.Description = d.Description()
+	}
+	if c, ok := metric.(scoring.ConfigReporter); ok {
+		ex.Config = c.Config()
+	}
+
+	saved, err := latestScore(wsRoot)
+	if err != nil {
+		return nil, err
+	}
+	if saved == nil {
+		return ex, nil
+	}
+	ex.Example = &explainResult{
+		BaseCommit: saved.BaseCommit,
+		HeadCommit: saved.HeadCommit,
+		AnalyzedAt: saved.AnalyzedAt,
+		Grade:      saved.Grade,
+		TotalScore: saved.TotalScore,
+	}
+	for i := range saved.Breakdown {
+		if saved.Breakdown[i].Key == ex.Key {
+			ex.Example.Result = &saved.Breakdown[i]
+		}
+	}
+	for _, s := range saved.Suppressed {
+		if s.Metric == ex.Key {
+			ex.Example.Suppressed++
+		}
+	}
+	return ex, nil
+}
+
+type savedScore struct {
+	scoring.ScoreResult
+	AnalyzedAt string `json:"analyzed_at"`
+}
+
+// latestScore returns the most recently analyzed score saved for wsRoot, or
+// nil when there is none.
+func latestScore(wsRoot string) (*savedScore, error) {
+	dir := config.ScoreDir(wsRoot)
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading saved scores: %w", err)
+	}
+	var latest *savedScore
+	for _, e := range entries {
+		if !strings.HasSuffix(e.Name(), ".json") {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, e.Name()))
+		if err != nil {
+			continue
+		}
+		var s savedScore
+		if json.Unmarshal(data, &s) != nil {
+			continue
+		}
+		// analyzed_at is RFC 3339 in UTC, so it orders as a string.
+		if latest == nil || s.AnalyzedAt > latest.AnalyzedAt {
+			latest = &s
+		}
+	}
+	return latest, nil
+}
+
+func printExplanation(w io.Writer, ex *explanation) {
+	fmt.Fprintf(w, "%s (%s)\n\n", ex.Name, ex.Key)
+	if ex.Description != "" {
+		fmt.Fprintln(w, wrapText(ex.Description, 76, "  "))
+		fmt.Fprintln(w)
+	}
+
+	if len(ex.Config) > 0 {
+		fmt.Fprintln(w, "Configuration")
+		tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+		for _, k := range sortedKeys(ex.Config) {
+			fmt.Fprintf(tw, "  %s\t%s\n", k, formatConfigValue(ex.Config[k]))
+		}
+		tw.Flush()
+		fmt.Fprintln(w)
+	}
+
+	ep := ex.Example
+	if ep == nil {
+		fmt.Fprintln(w, "No saved scores in this workspace. Run toposcope score for a worked example.")
+		return
+	}
+	fmt.Fprintf(w, "Most recent score: %s..%s, grade %s (%.1f)", shortSHA(ep.BaseCommit), shortSHA(ep.HeadCommit), ep.Grade, ep.TotalScore)
+	if ep.AnalyzedAt != "" {
+		fmt.Fprintf(w, ", %s", ep.AnalyzedAt)
+	}
+	fmt.Fprintln(w)
+	mr := ep.Result
+	if mr == nil {
+		fmt.Fprintln(w, "  It was scored without this metric.")
+		return
+	}
+	fmt.Fprintf(w, "  Contribution: %+.1f (%s) from %d findings\n", mr.Contribution, mr.Severity, len(mr.Evidence))
+	for i, ev := range mr.Evidence {
+		if i == explainEvidence {
+			fmt.Fprintf(w, "    ... and %d more\n", len(mr.Evidence)-i)
+			break
+		}
+		fmt.Fprintf(w, "    - %s\n", ev.Summary)
+	}
+	if ep.Suppressed > 0 {
+		fmt.Fprintf(w, "  %d findings were waived and not scored.\n", ep.Suppressed)
+	}
+	if limit, ok := mr.Config["max_contribution"].(float64); ok && limit > 0 && mr.Contribution >= limit {
+		fmt.Fprintf(w, "  The contribution was capped at max_contribution (%s).\n", formatConfigValue(limit))
+	}
+	for _, k := range sortedKeys(mr.Config) {
+		if cur, ok := ex.Config[k]; ok && formatConfigValue(cur) != formatConfigValue(mr.Config[k]) {
+			fmt.Fprintf(w, "  It was scored with %s %s; the configuration now has %s.\n", k, formatConfigValue(mr.Config[k]), formatConfigValue(cur))
+		}
+	}
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// formatConfigValue formats a metric setting. Configured settings and ones
+// decoded from a saved score, where numbers are float64 and lists []any,
+// format the same.
+func formatConfigValue(v any) string {
+	switch v := v.(type) {
+	case float64:
+		return fmt.Sprintf("%g", v)
+	case []string:
+		if len(v) == 0 {
+			return "(none)"
+		}
+		return strings.Join(v, ", ")
+	case []any:
+		items := make([]string, len(v))
+		for i, item := range v {
+			items[i] = fmt.Sprint(item)
+		}
+		return formatConfigValue(items)
+	case nil:
+		return "(none)"
+	}
+	return fmt.Sprint(v)
+}
+
+// wrapText wraps s at width columns, prefixing each line with indent.
+func wrapText(s string, width int, indent string) string {
+	var b strings.Builder
+	line, n := indent, len(indent)
+	for _, word := range strings.Fields(s) {
+		w := utf8.RuneCountInString(word)
+		if n > len(indent) && n+1+w > width {
+			b.WriteString(line + "\n")
+			line, n = indent, len(indent)
+		}
+		if n > len(indent) {
+			line += " "
+			n++
+		}
+		line += word
+		n += w
+	}
+	b.WriteString(line)
+	return b.String()
+}
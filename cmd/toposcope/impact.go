@@ -0,0 +1,106 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/toposcope/toposcope/pkg/extract/subgraph"
+	"github.com/toposcope/toposcope/pkg/graph"
+	"github.com/toposcope/toposcope/pkg/graphquery"
+)
+
+// impactListed is how many direct deps and dependents the text output lists.
+const impactListed = 20
+
+func newImpactCmd() *cobra.Command {
+	var repoPath, snapshotRef, outputFmt string
+
+	cmd := &cobra.Command{
+		Use:   "impact <target>",
+		Short: "Show what a target depends on and what depends on it",
+		Long: `Looks up a target in the latest snapshot (or the one given by --snapshot) and
+prints its direct dependencies and dependents, with how many targets it reaches
+transitively in each direction. The transitive dependents are what a change to
+the target rebuilds.`,
+		Args: cobra.ExactArgs(1),
+		ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+			if len(args) > 0 {
+				return nil, cobra.ShellCompDirectiveNoFileComp
+			}
+			return completeTargets(cmd, args, toComplete)
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			snap, err := resolveReportSnapshot(repoPath, snapshotRef)
+			if err != nil {
+				return err
+			}
+			label := subgraph.NormalizeLabel(args[0])
+			detail := graphquery.NodeDetail(snap, label)
+			if detail == nil {
+				return fmt.Errorf("impact: %s is not in the snapshot", label)
+			}
+
+			switch outputFmt {
+			case "json":
+				enc := json.NewEncoder(os.Stdout)
+				enc.SetIndent("", "  ")
+				if err := enc.Encode(detail); err != nil {
+					return fmt.Errorf("encoding JSON: %w", err)
+				}
+			case "text", "":
+				printImpactText(os.Stdout, detail)
+			default:
+				return fmt.Errorf("unknown output format %q (want text or json)", outputFmt)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&repoPath, "repo-path", "", "Path to repository root (default: detect workspace)")
+	cmd.Flags().StringVar(&snapshotRef, "snapshot", "", "Snapshot file path or commit SHA (default: latest cached snapshot)")
+	cmd.Flags().StringVar(&outputFmt, "output", "text", "Output format: text or json")
+
+	return cmd
+}
+
+func printImpactText(w io.Writer, d *graphquery.NodeDetailResult) {
+	fmt.Fprintf(w, "%s (%s)\n", d.Node.Key, d.Node.Kind)
+	fmt.Fprintf(w, "  Deps:       %d direct, %d transitive\n", d.OutDegree, d.TransitiveDeps)
+	fmt.Fprintf(w, "  Dependents: %d direct, %d transitive\n", d.InDegree, d.TransitiveRDeps)
+
+	for _, sec := range []struct {
+		title string
+		keys  []string
+	}{
+		{"Deps", edgeEnds(d.Deps, false)},
+		{"Dependents", edgeEnds(d.RDeps, true)},
+	} {
+		if len(sec.keys) == 0 {
+			continue
+		}
+		fmt.Fprintf(w, "\n%s\n", sec.title)
+		for i, key := range sec.keys {
+			if i == impactListed {
+				fmt.Fprintf(w, "  ... and %d more\n", len(sec.keys)-i)
+				break
+			}
+			fmt.Fprintf(w, "  %s\n", key)
+		}
+	}
+}
+
+// edgeEnds returns the targets at the far end of edges: their sources when
+// from is set, else their destinations.
+func edgeEnds(edges []graph.Edge, from bool) []string {
+	keys := make([]string, len(edges))
+	for i, e := range edges {
+		keys[i] = e.To
+		if from {
+			keys[i] = e.From
+		}
+	}
+	return keys
+}
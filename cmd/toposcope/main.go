@@ -15,15 +15,39 @@ func main() {
 		Use:   "toposcope",
 		Short: "Structural intelligence for Bazel codebases",
 		Long: `Toposcope extracts build dependency graphs from Bazel, computes deltas
-between commits, and scores structural health.`,
+between commits, and scores structural health.
+
+Config comes from .toposcope/config.yaml, overridden by TOPOSCOPE_*
+environment variables (e.g. TOPOSCOPE_EXTRACTION_TIMEOUT=900), overridden in
+turn by --set key=value flags. Command flags such as --bazel win over all
+three.`,
 		Version: version,
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			var err error
+			configOverrides, err = parseConfigOverrides(os.Environ(), configSets)
+			return err
+		},
 	}
+	rootCmd.PersistentFlags().StringArrayVar(&configSets, "set", nil, "Override a config key, e.g. --set scoring.weights.fanout_weight=1 (repeatable)")
 
 	rootCmd.AddCommand(
 		newSnapshotCmd(),
 		newDiffCmd(),
+		newCompareCmd(),
 		newScoreCmd(),
+		newPlanCmd(),
+		newBackfillCmd(),
 		newUICmd(),
+		newReportCmd(),
+		newCheckCmd(),
+		newCacheCmd(),
+		newVerifyCmd(),
+		newBundleCmd(),
+		newCICmd(),
+		newSchemaCmd(),
+		newExplainCmd(),
+		newImpactCmd(),
+		newConfigCmd(),
 	)
 
 	if err := rootCmd.Execute(); err != nil {
@@ -1,7 +1,33 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
 	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/spf13/cobra"
+	"github.com/toposcope/toposcope/pkg/bundle"
+	"github.com/toposcope/toposcope/pkg/client"
+	"github.com/toposcope/toposcope/pkg/config"
+	"github.com/toposcope/toposcope/pkg/extract"
+	"github.com/toposcope/toposcope/pkg/extract/subgraph"
+	"github.com/toposcope/toposcope/pkg/graph"
+	"github.com/toposcope/toposcope/pkg/scoring"
 )
 
 func TestSnapshotCmdFlags(t *testing.T) {
@@ -15,7 +41,7 @@ func TestSnapshotCmdFlags(t *testing.T) {
 	}
 
 	// Test that flags exist
-	for _, flag := range []string{"repo-path", "scope", "output", "bazel-path", "bazelrc", "cquery"} {
+	for _, flag := range []string{"repo-path", "scope", "output", "bazel-path", "bazelrc", "cquery", "include-external", "gzip"} {
 		if f.Lookup(flag) == nil {
 			t.Errorf("missing flag: %s", flag)
 		}
@@ -33,13 +59,42 @@ func TestDiffCmdFlags(t *testing.T) {
 	}
 
 	// Test that base is required
-	for _, flag := range []string{"base", "head", "repo-path", "bazel-path", "bazelrc", "cquery"} {
+	for _, flag := range []string{"base", "head", "repo-path", "bazel-path", "bazelrc", "cquery", "include-external", "base-snapshot", "head-snapshot", "merge-base"} {
 		if f.Lookup(flag) == nil {
 			t.Errorf("missing flag: %s", flag)
 		}
 	}
 }
 
+func TestCompareCmdFlags(t *testing.T) {
+	cmd := newCompareCmd()
+	if err := cmd.Args(cmd, []string{"abc1234"}); err == nil {
+		t.Error("expected an error with one argument")
+	}
+	for _, flag := range []string{"repo-path", "top", "output"} {
+		if cmd.Flags().Lookup(flag) == nil {
+			t.Errorf("missing flag: %s", flag)
+		}
+	}
+}
+
+func TestBundleCmdFlags(t *testing.T) {
+	export := newBundleExportCmd()
+	for _, flag := range []string{"repo-path", "from", "to", "out", "repo"} {
+		if export.Flags().Lookup(flag) == nil {
+			t.Errorf("export: missing flag: %s", flag)
+		}
+	}
+	if to, _ := export.Flags().GetString("to"); to != "HEAD" {
+		t.Errorf("default to = %q, want HEAD", to)
+	}
+
+	imp := newBundleImportCmd()
+	if err := imp.Args(imp, nil); err == nil {
+		t.Error("import: expected an error without a bundle path")
+	}
+}
+
 func TestScoreCmdFlags(t *testing.T) {
 	cmd := newScoreCmd()
 	f := cmd.Flags()
@@ -50,13 +105,203 @@ func TestScoreCmdFlags(t *testing.T) {
 		t.Errorf("default output = %q, want text", outputFmt)
 	}
 
-	for _, flag := range []string{"base", "head", "repo-path", "bazel-path", "bazelrc", "cquery", "output"} {
+	for _, flag := range []string{"base", "head", "repo-path", "bazel-path", "bazelrc", "cquery", "output", "normalize", "include-external", "against-baseline", "platform-url", "build-events", "execution-log", "base-snapshot", "head-snapshot", "merge-base", "virtual-merge"} {
+		if f.Lookup(flag) == nil {
+			t.Errorf("missing flag: %s", flag)
+		}
+	}
+
+	cmd.SetArgs([]string{"--base", "main", "--against-baseline"})
+	cmd.SetOut(io.Discard)
+	cmd.SetErr(io.Discard)
+	if err := cmd.Execute(); err == nil {
+		t.Error("expected --base with --against-baseline to fail")
+	}
+
+	for _, args := range [][]string{
+		{"--base", "main", "--base-snapshot", "base.json"},
+		{"--base-snapshot", "-", "--head-snapshot", "-"},
+		{"--base", "main", "--head", "HEAD~1", "--head-snapshot", "head.json"},
+		{"--head-snapshot", "head.json"}, // no base
+	} {
+		cmd := newScoreCmd()
+		cmd.SetArgs(args)
+		cmd.SetOut(io.Discard)
+		cmd.SetErr(io.Discard)
+		if err := cmd.Execute(); err == nil {
+			t.Errorf("expected %q to fail", args)
+		}
+	}
+}
+
+func TestFetchPlatformBaseline(t *testing.T) {
+	t.Setenv("TOPOSCOPE_API_KEY", "k")
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/v2/repos", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-API-Key") != "k" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		_, _ = w.Write([]byte(`[{"id":"r1","full_name":"acme/mono"}]`))
+	})
+	mux.HandleFunc("GET /api/v2/repos/r1/baseline", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"snapshot_id":"s1","commit_sha":"abc1234def"}`))
+	})
+	downloads := 0
+	mux.HandleFunc("GET /api/v2/snapshots/s1", func(w http.ResponseWriter, r *http.Request) {
+		downloads++
+		_, _ = w.Write([]byte(`{"id":"s1","commit_sha":"abc1234def","nodes":{}}`))
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	sha, snap, err := fetchPlatformBaseline(context.Background(), srv.URL+"/", "Acme/Mono", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sha != "abc1234def" || snap.ID != "s1" {
+		t.Errorf("got %s, %s; want abc1234def, s1", sha, snap.ID)
+	}
+
+	if _, _, err := fetchPlatformBaseline(context.Background(), srv.URL, "acme/other", ""); err == nil {
+		t.Error("expected an error for an unregistered repository")
+	}
+
+	// A cached snapshot of the baseline commit is used without downloading.
+	wsRoot := t.TempDir()
+	saveCachedSnapshot(wsRoot, "abc1234def", snap)
+	downloads = 0
+	if _, _, err := fetchPlatformBaseline(context.Background(), srv.URL, "acme/mono", wsRoot); err != nil {
+		t.Fatal(err)
+	}
+	if downloads != 0 {
+		t.Errorf("downloaded the snapshot %d times, want a cache hit", downloads)
+	}
+}
+
+func TestReportOffendersCmdFlags(t *testing.T) {
+	cmd := newReportOffendersCmd()
+	f := cmd.Flags()
+
+	top, _ := f.GetInt("top")
+	if top != 10 {
+		t.Errorf("default top = %d, want 10", top)
+	}
+	outputFmt, _ := f.GetString("output")
+	if outputFmt != "text" {
+		t.Errorf("default output = %q, want text", outputFmt)
+	}
+
+	for _, flag := range []string{"repo-path", "snapshot", "top", "output", "include-tests"} {
 		if f.Lookup(flag) == nil {
 			t.Errorf("missing flag: %s", flag)
 		}
 	}
 }
 
+func TestReportConformanceCmdFlags(t *testing.T) {
+	cmd := newReportConformanceCmd()
+	f := cmd.Flags()
+
+	for _, flag := range []string{"repo-path", "snapshot", "baseline", "top", "output", "include-tests"} {
+		if f.Lookup(flag) == nil {
+			t.Errorf("missing flag: %s", flag)
+		}
+	}
+}
+
+func TestCheckCyclesCmdFlags(t *testing.T) {
+	cmd := newCheckCyclesCmd()
+	f := cmd.Flags()
+
+	for _, flag := range []string{"repo-path", "base", "head", "output"} {
+		if f.Lookup(flag) == nil {
+			t.Errorf("missing flag: %s", flag)
+		}
+	}
+}
+
+func TestCacheCleanCmdFlags(t *testing.T) {
+	cmd := newCacheCleanCmd()
+	f := cmd.Flags()
+
+	for _, flag := range []string{"repo-path", "older-than", "all"} {
+		if f.Lookup(flag) == nil {
+			t.Errorf("missing flag: %s", flag)
+		}
+	}
+}
+
+func TestCleanCacheDir(t *testing.T) {
+	dir := t.TempDir()
+	old := time.Now().Add(-48 * time.Hour)
+	for _, name := range []string{"old.json", "new.json", "keep.txt"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("{}"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Chtimes(filepath.Join(dir, "old.json"), old, old); err != nil {
+		t.Fatal(err)
+	}
+
+	n, _, err := cleanCacheDir(dir, 24*time.Hour)
+	if err != nil || n != 1 {
+		t.Fatalf("cleanCacheDir(24h) = %d, %v; want 1 file removed", n, err)
+	}
+	n, _, err = cleanCacheDir(dir, 0)
+	if err != nil || n != 1 {
+		t.Fatalf("cleanCacheDir(0) = %d, %v; want 1 file removed", n, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "keep.txt")); err != nil {
+		t.Error("expected non-cache files to be left alone")
+	}
+
+	if n, _, err := cleanCacheDir(filepath.Join(dir, "missing"), 0); err != nil || n != 0 {
+		t.Errorf("cleanCacheDir(missing) = %d, %v; want 0, nil", n, err)
+	}
+}
+
+func TestRunVerify(t *testing.T) {
+	dir := t.TempDir()
+	snap := &graph.Snapshot{
+		CommitSHA: "abc123",
+		Nodes:     map[string]*graph.Node{"//a:lib": {Key: "//a:lib"}},
+		Stats:     graph.SnapshotStats{NodeCount: 1},
+	}
+	snap.ID = graph.ContentID(snap)
+	if err := graph.SaveSnapshot(filepath.Join(dir, "abc123.json"), snap); err != nil {
+		t.Fatal(err)
+	}
+	if err := runVerify(dir, false); err != nil {
+		t.Fatalf("runVerify on a clean cache: %v", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "def456.json"), []byte(`{"id": "trunc`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := runVerify(dir, false); err == nil {
+		t.Error("expected an error for a corrupted snapshot")
+	}
+	if err := runVerify(dir, true); err != nil {
+		t.Fatalf("runVerify --delete: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "def456.json")); !os.IsNotExist(err) {
+		t.Error("expected the corrupted snapshot to be removed")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "abc123.json")); err != nil {
+		t.Error("expected the valid snapshot to be kept")
+	}
+}
+
+func TestFormatBytes(t *testing.T) {
+	tests := map[int64]string{512: "512 B", 2048: "2.0 KiB", 3 << 20: "3.0 MiB"}
+	for n, want := range tests {
+		if got := formatBytes(n); got != want {
+			t.Errorf("formatBytes(%d) = %q, want %q", n, got, want)
+		}
+	}
+}
+
 func TestFirstNonEmpty(t *testing.T) {
 	tests := []struct {
 		args []string
@@ -76,6 +321,48 @@ func TestFirstNonEmpty(t *testing.T) {
 	}
 }
 
+func TestBazelRCPath(t *testing.T) {
+	ws := filepath.Join(t.TempDir(), "ws")
+	cfg := config.DefaultConfig()
+	if rc := bazelRCPath(ws, "", cfg); rc != "" {
+		t.Errorf("no bazelrc: got %q", rc)
+	}
+
+	cfg.Extraction.BazelRC = "tools/ci.bazelrc"
+	if rc, want := bazelRCPath(ws, "", cfg), filepath.Join(ws, "tools", "ci.bazelrc"); rc != want {
+		t.Errorf("configured bazelrc = %q, want %q relative to the workspace", rc, want)
+	}
+
+	cwd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rc, want := bazelRCPath(ws, "local.bazelrc", cfg), filepath.Join(cwd, "local.bazelrc"); rc != want {
+		t.Errorf("flag bazelrc = %q, want %q relative to the working directory", rc, want)
+	}
+}
+
+func TestBazelBinary(t *testing.T) {
+	// Only bazel on PATH: the default bazelisk falls back to it.
+	bin := t.TempDir()
+	name := "bazel"
+	if runtime.GOOS == "windows" {
+		name += ".exe"
+	}
+	if err := os.WriteFile(filepath.Join(bin, name), []byte("#!/bin/sh\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin)
+
+	cfg := config.DefaultConfig()
+	if got := bazelBinary("", cfg); got != "bazel" {
+		t.Errorf("bazelBinary = %q, want the bazel on PATH", got)
+	}
+	if got := bazelBinary("/opt/bazelisk", cfg); got != "/opt/bazelisk" {
+		t.Errorf("bazelBinary with a flag = %q", got)
+	}
+}
+
 func TestMinInt(t *testing.T) {
 	if minInt(3, 5) != 3 {
 		t.Error("minInt(3, 5) should be 3")
@@ -87,3 +374,934 @@ func TestMinInt(t *testing.T) {
 		t.Error("minInt(3, 3) should be 3")
 	}
 }
+
+func TestGradeThresholds(t *testing.T) {
+	cfg := config.DefaultConfig()
+	got, err := gradeThresholds(cfg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != scoring.DefaultGradeThresholds() {
+		t.Errorf("gradeThresholds(default) = %+v, want defaults", got)
+	}
+
+	cfg.Scoring.GradeThresholds = map[string]float64{"A": 1, "b": 5}
+	got, err = gradeThresholds(cfg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got.A != 1 || got.B != 5 || got.C != 14 || got.D != 24 {
+		t.Errorf("gradeThresholds(override) = %+v, want A=1 B=5 C=14 D=24", got)
+	}
+
+	cfg.Scoring.GradeThresholds = map[string]float64{"E": 1}
+	if _, err := gradeThresholds(cfg); err == nil {
+		t.Error("expected error for unknown grade key")
+	}
+
+	cfg.Scoring.GradeThresholds = map[string]float64{"A": 30}
+	if _, err := gradeThresholds(cfg); err == nil {
+		t.Error("expected error for non-monotonic thresholds")
+	}
+}
+
+func TestRemoteCache(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	ctx := context.Background()
+	wsRoot := t.TempDir()
+
+	cfg := config.DefaultConfig()
+	ext := &subgraph.Extractor{}
+	if rc := openRemoteCache(ctx, wsRoot, cfg, ext); rc != nil {
+		t.Fatal("expected remote cache to be disabled by default")
+	}
+
+	cfg.RemoteCache = config.RemoteCacheConfig{Backend: "local", Path: t.TempDir(), Namespace: "ci"}
+	rc := openRemoteCache(ctx, wsRoot, cfg, ext)
+	if rc == nil {
+		t.Fatal("expected remote cache to be enabled")
+	}
+
+	if _, err := rc.loadSnapshot(ctx, wsRoot, "abc1234"); err == nil {
+		t.Fatal("expected a miss for an unknown commit")
+	}
+
+	snap := &graph.Snapshot{ID: "snap-1", CommitSHA: "abc1234", Nodes: map[string]*graph.Node{}}
+	rc.saveSnapshot(ctx, wsRoot, "abc1234", snap)
+
+	// Another worker with an empty local cache gets the remote copy.
+	if err := os.RemoveAll(config.SnapshotDir(wsRoot)); err != nil {
+		t.Fatal(err)
+	}
+	got, err := rc.loadSnapshot(ctx, wsRoot, "abc1234")
+	if err != nil || got.ID != "snap-1" {
+		t.Fatalf("loadSnapshot() = %v, %v; want remote hit", got, err)
+	}
+	if _, err := loadCachedSnapshot(wsRoot, "abc1234"); err != nil {
+		t.Error("expected remote hit to populate the local cache")
+	}
+
+	// A worker extracting with other options must not get this snapshot.
+	if err := os.RemoveAll(config.SnapshotDir(wsRoot)); err != nil {
+		t.Fatal(err)
+	}
+	other := openRemoteCache(ctx, wsRoot, cfg, &subgraph.Extractor{IncludeExternal: true})
+	if _, err := other.loadSnapshot(ctx, wsRoot, "abc1234"); err == nil {
+		t.Error("expected a miss for different extraction options")
+	}
+	if extractionOptions(&subgraph.Extractor{}) != extractionOptions(&subgraph.Extractor{EdgeAttributes: extract.DefaultEdgeAttributes}) {
+		t.Error("expected default edge attributes to share the unset key")
+	}
+
+	hashFile := filepath.Join(config.HashCacheDir(wsRoot), "abc1234-flags.json")
+	present := rc.fetchHashes(ctx, hashFile)
+	if present[hashFile] {
+		t.Fatal("expected hash file to be missing")
+	}
+	if err := os.MkdirAll(filepath.Dir(hashFile), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(hashFile, []byte(`{"//a:lib":"h"}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	rc.pushHashes(ctx, present, hashFile)
+
+	if err := os.Remove(hashFile); err != nil {
+		t.Fatal(err)
+	}
+	if present := rc.fetchHashes(ctx, hashFile); !present[hashFile] {
+		t.Error("expected hash file to be fetched from the remote cache")
+	}
+}
+
+func TestUICmdFlags(t *testing.T) {
+	t.Setenv("TOPOSCOPE_UI_TOKEN", "")
+	f := newUICmd().Flags()
+	if bind, _ := f.GetString("bind"); bind != "127.0.0.1" {
+		t.Errorf("default bind = %q, want 127.0.0.1", bind)
+	}
+	if token, _ := f.GetString("token"); token != "" {
+		t.Errorf("default token = %q, want empty", token)
+	}
+}
+
+func TestTokenAuth(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	h := corsMiddleware(tokenAuth("s3cret", ok))
+
+	for _, tc := range []struct {
+		method, auth string
+		want         int
+	}{
+		{"GET", "", http.StatusUnauthorized},
+		{"GET", "Bearer wrong", http.StatusUnauthorized},
+		{"GET", "Bearer s3cret", http.StatusOK},
+		{"OPTIONS", "", http.StatusOK},
+	} {
+		req := httptest.NewRequest(tc.method, "/api/repos", nil)
+		if tc.auth != "" {
+			req.Header.Set("Authorization", tc.auth)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != tc.want {
+			t.Errorf("%s with %q: status %d, want %d", tc.method, tc.auth, rec.Code, tc.want)
+		}
+	}
+
+	if !isLoopback("127.0.0.1") || !isLoopback("::1") || !isLoopback("localhost") || isLoopback("0.0.0.0") {
+		t.Error("isLoopback misclassified an address")
+	}
+}
+
+func TestCICmdFlags(t *testing.T) {
+	cmd := newCICmd()
+	f := cmd.Flags()
+
+	failOn, _ := f.GetString("fail-on")
+	if failOn != "F" {
+		t.Errorf("default fail-on = %q, want F", failOn)
+	}
+
+	for _, flag := range []string{"base", "head", "repo-path", "bazel-path", "bazelrc", "platform-url", "fail-on", "comment"} {
+		if f.Lookup(flag) == nil {
+			t.Errorf("missing flag: %s", flag)
+		}
+	}
+}
+
+func TestDetectCIEnv(t *testing.T) {
+	tests := []struct {
+		name string
+		vars map[string]string
+		want ciEnv
+	}{
+		{
+			name: "github pull request",
+			vars: map[string]string{
+				"GITHUB_ACTIONS": "true", "GITHUB_REPOSITORY": "acme/mono", "GITHUB_SHA": "abc",
+				"GITHUB_EVENT_NAME": "pull_request", "GITHUB_REF": "refs/pull/42/merge",
+				"GITHUB_BASE_REF": "main", "GITHUB_HEAD_REF": "feature",
+			},
+			want: ciEnv{Provider: "github", Repo: "acme/mono", BaseRef: "origin/main", HeadSHA: "abc",
+				Branch: "feature", DefaultBranch: "main", PRNumber: "42"},
+		},
+		{
+			name: "gitlab push",
+			vars: map[string]string{
+				"GITLAB_CI": "true", "CI_PROJECT_PATH": "acme/mono", "CI_COMMIT_SHA": "def",
+				"CI_COMMIT_BRANCH": "main", "CI_DEFAULT_BRANCH": "main", "CI_COMMIT_BEFORE_SHA": "0000000000",
+			},
+			want: ciEnv{Provider: "gitlab", Repo: "acme/mono", BaseRef: "HEAD~1", HeadSHA: "def",
+				Branch: "main", DefaultBranch: "main"},
+		},
+		{
+			name: "buildkite pull request",
+			vars: map[string]string{
+				"BUILDKITE": "true", "BUILDKITE_REPO": "git@github.com:acme/mono.git", "BUILDKITE_COMMIT": "123",
+				"BUILDKITE_BRANCH": "feature", "BUILDKITE_PULL_REQUEST": "7",
+				"BUILDKITE_PULL_REQUEST_BASE_BRANCH": "main", "BUILDKITE_PIPELINE_DEFAULT_BRANCH": "main",
+			},
+			want: ciEnv{Provider: "buildkite", Repo: "acme/mono", BaseRef: "origin/main", HeadSHA: "123",
+				Branch: "feature", DefaultBranch: "main", PRNumber: "7"},
+		},
+		{
+			name: "github on a windows runner",
+			vars: map[string]string{
+				"GITHUB_ACTIONS": "True", "GITHUB_REPOSITORY": "acme/mono", "GITHUB_SHA": "abc",
+				"GITHUB_EVENT_NAME": "push", "GITHUB_REF_NAME": "main",
+			},
+			want: ciEnv{Provider: "github", Repo: "acme/mono", BaseRef: "HEAD~1", HeadSHA: "abc",
+				Branch: "main", DefaultBranch: "main"},
+		},
+		{
+			name: "azure pipelines pull request",
+			vars: map[string]string{
+				"TF_BUILD": "True", "BUILD_REPOSITORY_NAME": "acme/mono", "BUILD_SOURCEVERSION": "456",
+				"BUILD_SOURCEBRANCH": "refs/pull/9/merge", "SYSTEM_PULLREQUEST_PULLREQUESTNUMBER": "9",
+				"SYSTEM_PULLREQUEST_SOURCEBRANCH": "refs/heads/feature/x", "SYSTEM_PULLREQUEST_TARGETBRANCH": "main",
+			},
+			want: ciEnv{Provider: "azure", Repo: "acme/mono", BaseRef: "origin/main", HeadSHA: "456",
+				Branch: "feature/x", PRNumber: "9"},
+		},
+		{
+			name: "azure pipelines push",
+			vars: map[string]string{
+				"TF_BUILD": "True", "BUILD_REPOSITORY_NAME": "mono", "BUILD_SOURCEVERSION": "789",
+				"BUILD_SOURCEBRANCH": "refs/heads/release/1.2",
+			},
+			want: ciEnv{Provider: "azure", Repo: "mono", BaseRef: "HEAD~1", HeadSHA: "789", Branch: "release/1.2"},
+		},
+		{
+			name: "local",
+			vars: map[string]string{},
+			want: ciEnv{Provider: "local", BaseRef: "HEAD~1", HeadSHA: "HEAD"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := detectCIEnv(func(k string) string { return tt.vars[k] })
+			got.apiURL, got.projectID = "", ""
+			if *got != tt.want {
+				t.Errorf("detectCIEnv() = %+v, want %+v", *got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGradeRank(t *testing.T) {
+	if r, _ := gradeRank("none"); r != -1 {
+		t.Errorf("gradeRank(none) = %d, want -1", r)
+	}
+	c, _ := gradeRank("c")
+	d, _ := gradeRank("D")
+	if c >= d {
+		t.Errorf("expected C to rank better than D (%d vs %d)", c, d)
+	}
+	if _, err := gradeRank("E"); err == nil {
+		t.Error("expected error for unknown grade")
+	}
+}
+
+func TestCIGateBoundaryViolation(t *testing.T) {
+	// Four new edges from services/orders into services/payments: one
+	// top-level directory, but two boundaries in the repository settings.
+	node := func(key, pkg string) *graph.Node { return &graph.Node{Key: key, Package: pkg} }
+	base := &graph.Snapshot{CommitSHA: "base", Nodes: map[string]*graph.Node{}}
+	head := &graph.Snapshot{CommitSHA: "head", Nodes: map[string]*graph.Node{}}
+	delta := &graph.Delta{}
+	for i := 0; i < 4; i++ {
+		from := node(fmt.Sprintf("//services/orders/m%d:lib", i), fmt.Sprintf("//services/orders/m%d", i))
+		to := node(fmt.Sprintf("//services/payments/m%d:lib", i), fmt.Sprintf("//services/payments/m%d", i))
+		for _, n := range []*graph.Node{from, to} {
+			base.Nodes[n.Key], head.Nodes[n.Key] = n, n
+		}
+		delta.AddedEdges = append(delta.AddedEdges, graph.Edge{From: from.Key, To: to.Key, Type: "COMPILE"})
+	}
+
+	opts := applyRepoSettings(ciOpts{failOn: "F"}, &client.RepoSettings{
+		Boundaries: []string{"services/orders", "services/payments"},
+		FailOn:     "B",
+	})
+	if opts.failOn != "B" {
+		t.Fatalf("failOn = %q, want the repository's B", opts.failOn)
+	}
+	if kept := applyRepoSettings(ciOpts{failOn: "D", failOnSet: true}, &client.RepoSettings{FailOn: "B"}); kept.failOn != "D" {
+		t.Errorf("failOn = %q, want --fail-on D to win", kept.failOn)
+	}
+	failRank, _ := gradeRank(opts.failOn)
+
+	gate := func(boundaries []string) error {
+		cfg := config.DefaultConfig()
+		cfg.Scoring.Boundaries = boundaries
+		metrics, err := configuredMetrics(cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		result, err := scoring.NewEngine(metrics...).Score(delta, base, head)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return checkGate(result, failRank, opts.failOn)
+	}
+	if err := gate(nil); err != nil {
+		t.Errorf("without boundaries the edges stay in one boundary, want a pass: %v", err)
+	}
+	if err := gate(opts.score.boundaries); err == nil {
+		t.Error("expected the boundary violations to fail the gate")
+	}
+}
+
+func TestConfiguredWaivers(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Scoring.Waivers = []config.WaiverConfig{
+		{Metric: "fanout_increase", Target: "//app/...", Reason: "split in progress", Expires: "2030-01-31"},
+		{Target: "//lib/legacy:*"},
+	}
+	got, err := configuredWaivers(cfg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(got) != 2 || got[1].Expires != nil {
+		t.Fatalf("configuredWaivers = %+v", got)
+	}
+	if want := time.Date(2030, 2, 1, 0, 0, 0, 0, time.UTC); !got[0].Expires.Equal(want) {
+		t.Errorf("Expires = %v, want end of 2030-01-31 (%v)", got[0].Expires, want)
+	}
+
+	cfg.Scoring.Waivers = []config.WaiverConfig{{Target: "//a:b", Expires: "01/31/2030"}}
+	if _, err := configuredWaivers(cfg); err == nil {
+		t.Error("expected error for malformed expiry")
+	}
+	cfg.Scoring.Waivers = []config.WaiverConfig{{Metric: "fanout_increase"}}
+	if _, err := configuredWaivers(cfg); err == nil {
+		t.Error("expected error for waiver without a target")
+	}
+}
+
+func TestBackfillCmdFlags(t *testing.T) {
+	cmd := newBackfillCmd()
+	for _, flag := range []string{"since", "every", "branch", "repo-path", "bazel-path", "bazelrc", "cquery", "include-external", "normalize", "platform-url", "repo"} {
+		if cmd.Flags().Lookup(flag) == nil {
+			t.Errorf("missing flag: %s", flag)
+		}
+	}
+
+	cmd.SetArgs([]string{"--since", "HEAD~5", "--every", "0"})
+	cmd.SetOut(io.Discard)
+	cmd.SetErr(io.Discard)
+	if err := cmd.Execute(); err == nil {
+		t.Error("expected --every 0 to fail")
+	}
+}
+
+func TestScoreModel(t *testing.T) {
+	head := &graph.Snapshot{
+		Nodes: map[string]*graph.Node{
+			"//app:server": {Key: "//app:server", Kind: "go_binary"},
+			"//lib:util":   {Key: "//lib:util", Kind: "go_library"},
+			"//lib:log":    {Key: "//lib:log", Kind: "go_library"},
+		},
+		Edges: []graph.Edge{
+			{From: "//app:server", To: "//lib:util", Type: "COMPILE"},
+			{From: "//lib:util", To: "//lib:log", Type: "COMPILE"},
+		},
+	}
+	result := &scoring.ScoreResult{
+		Grade: "C", TotalScore: 9.5,
+		Breakdown: []scoring.MetricResult{{
+			Key: "fanout_increase", Name: "Fan-out increase", Contribution: 4, Severity: scoring.SeverityMedium,
+			Evidence: []scoring.EvidenceItem{{Summary: "//lib:util gains 1 dep", From: "//lib:util"}},
+		}},
+		Hotspots: []scoring.Hotspot{{NodeKey: "//app:server", Reason: "high fan-out", ScoreContribution: 4}},
+	}
+	m := newScoreModel(result, head, "http://localhost:3000/")
+	key := func(s string) {
+		msg := tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(s)}
+		switch s {
+		case "enter":
+			msg = tea.KeyMsg{Type: tea.KeyEnter}
+		case "esc":
+			msg = tea.KeyMsg{Type: tea.KeyEsc}
+		}
+		m.Update(msg)
+	}
+
+	if view := m.View(); !strings.Contains(view, "Fan-out increase") || strings.Contains(view, "gains 1 dep") {
+		t.Errorf("collapsed view:\n%s", view)
+	}
+	key("enter") // expand the metric
+	if view := m.View(); !strings.Contains(view, "//lib:util gains 1 dep") {
+		t.Errorf("expanded view lacks the evidence:\n%s", view)
+	}
+
+	key("j")
+	key("enter") // drill into the evidence's node
+	if m.ego != "//lib:util" {
+		t.Fatalf("ego = %q, want //lib:util", m.ego)
+	}
+	view := m.View()
+	for _, want := range []string{"depends on (1)", "└── //lib:log", "depended on by (1)", "└── //app:server"} {
+		if !strings.Contains(view, want) {
+			t.Errorf("ego view lacks %q:\n%s", want, view)
+		}
+	}
+	key("esc")
+
+	key("j") // the hotspot
+	if m.rows[m.cursor].node != "//app:server" {
+		t.Errorf("cursor row = %+v, want the hotspot", m.rows[m.cursor])
+	}
+	if got, want := m.nodeURL("//app:server"), "http://localhost:3000/repos/local/graph?target=%2F%2Fapp%3Aserver"; got != want {
+		t.Errorf("nodeURL = %q, want %q", got, want)
+	}
+}
+
+func TestEgoTreeCycles(t *testing.T) {
+	snap := &graph.Snapshot{
+		Nodes: map[string]*graph.Node{"//a": {Key: "//a"}, "//b": {Key: "//b"}},
+		Edges: []graph.Edge{{From: "//a", To: "//b"}, {From: "//b", To: "//a"}},
+	}
+	tree := egoTree(snap, graph.NewIndex(snap), "//a", 5)
+	if strings.Count(tree, "//b") != 2 || !strings.Contains(tree, "(shown above)") {
+		t.Errorf("cycle not cut:\n%s", tree)
+	}
+}
+
+func TestExplainMetric(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	wsRoot := t.TempDir()
+	cfg := config.DefaultConfig()
+
+	ex, err := explainMetric(wsRoot, cfg, "Fanout increase")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ex.Key != "fanout_increase" || ex.Description == "" || ex.Config["cap_per_node"] != 10.0 || ex.Example != nil {
+		t.Errorf("explanation without scores = %+v", ex)
+	}
+	if _, err := explainMetric(wsRoot, cfg, "coupling"); err == nil || !strings.Contains(err.Error(), "fanout_increase") {
+		t.Errorf("unknown metric error = %v, want the metric keys listed", err)
+	}
+
+	var evidence []scoring.EvidenceItem
+	for i := 0; i < 7; i++ {
+		evidence = append(evidence, scoring.EvidenceItem{Summary: fmt.Sprintf("//app:t%d fanout 10 -> 12 (+2)", i)})
+	}
+	saveScoreResult(wsRoot, "aaaaaaaaaaaa", "bbbbbbbbbbbb", &scoring.ScoreResult{
+		Grade: "C", TotalScore: 7,
+		BaseCommit: "aaaaaaaaaaaa", HeadCommit: "bbbbbbbbbbbb",
+		Breakdown: []scoring.MetricResult{{
+			Key: "fanout_increase", Name: "Fanout increase", Contribution: 7, Severity: scoring.SeverityHigh,
+			Evidence: evidence,
+			Config:   map[string]any{"weight": 0.5, "cap_per_node": 5, "min_threshold": 10, "include_generated": false},
+		}},
+	})
+
+	ex, err = explainMetric(wsRoot, cfg, "fanout_increase")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	printExplanation(&out, ex)
+	for _, want := range []string{
+		"Fanout increase (fanout_increase)",
+		"  cap_per_node       10\n",
+		"Most recent score: aaaaaaa..bbbbbbb, grade C (7.0)",
+		"Contribution: +7.0 (HIGH) from 7 findings",
+		"- //app:t4 fanout",
+		"... and 2 more",
+		"scored with cap_per_node 5; the configuration now has 10.",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("explanation lacks %q:\n%s", want, out.String())
+		}
+	}
+	if strings.Contains(out.String(), "min_threshold 10;") {
+		t.Errorf("unchanged setting reported as changed:\n%s", out.String())
+	}
+}
+
+func TestCompleteLabels(t *testing.T) {
+	keys := []string{"//app/server:main", "//app/server:lib", "//app/web", "//lib/log", "@maven//:guava"}
+	got, dir := completeLabels(keys, "//app/")
+	if want := []string{"//app/server:lib", "//app/server:main", "//app/web"}; !slices.Equal(got, want) || dir&cobra.ShellCompDirectiveNoSpace != 0 {
+		t.Errorf("completeLabels(//app/) = %v, %v; want %v with a space", got, dir, want)
+	}
+
+	var many []string
+	for i := 0; i < maxLabelCompletions; i++ {
+		many = append(many, fmt.Sprintf("//app/server:t%d", i))
+	}
+	many = append(many, keys...)
+	for _, tc := range []struct {
+		prefix string
+		want   []string
+	}{
+		{"", []string{"//app/", "//lib/", "@maven//:"}},
+		{"//app/", []string{"//app/server:", "//app/web"}},
+	} {
+		got, dir := completeLabels(many, tc.prefix)
+		if !slices.Equal(got, tc.want) || dir&cobra.ShellCompDirectiveNoSpace == 0 {
+			t.Errorf("completeLabels(%q) = %v, %v; want %v without a space", tc.prefix, got, dir, tc.want)
+		}
+	}
+}
+
+func TestCompleteTargets(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	wsRoot := t.TempDir()
+	if err := os.WriteFile(filepath.Join(wsRoot, "MODULE.bazel"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	saveCachedSnapshot(wsRoot, "abc", &graph.Snapshot{
+		Nodes: map[string]*graph.Node{"//app:server": {Key: "//app:server"}, "//lib:util": {Key: "//lib:util"}},
+	})
+
+	cmd := newImpactCmd()
+	if err := cmd.Flags().Set("repo-path", wsRoot); err != nil {
+		t.Fatal(err)
+	}
+	got, _ := cmd.ValidArgsFunction(cmd, nil, "//app")
+	if want := []string{"//app:server"}; !slices.Equal(got, want) {
+		t.Errorf("completions = %v, want %v", got, want)
+	}
+	if got, _ := cmd.ValidArgsFunction(cmd, []string{"//app:server"}, "//"); len(got) != 0 {
+		t.Errorf("completed a second argument: %v", got)
+	}
+}
+
+func TestValidateConfigFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	write := func(s string) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(s), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	write(`scoring:
+  weights:
+    fanout_weight: 1.5
+    fanout: 2
+    fanout_min_threshold: 2.5
+    credit_max_total: 5
+  grade_thresholds:
+    E: 40
+`)
+	v, err := validateConfigFile(path, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var got []string
+	for _, p := range v.Problems {
+		got = append(got, p.Path+": "+p.Message)
+	}
+	want := []string{
+		"scoring.weights.credit_max_total: credits must not be positive",
+		"scoring.weights.fanout: unknown weight",
+		"scoring.weights.fanout_min_threshold: must be a whole number",
+		`scoring.grade_thresholds: invalid grade_thresholds key "E" (want A, B, C, or D)`,
+	}
+	if v.Valid || !slices.Equal(got, want) {
+		t.Errorf("problems = %q, want %q", got, want)
+	}
+
+	write("scoring:\n  weights:\n    fanout_weight: 1.5\n  grade_thresholds:\n    a: 5\n")
+	v, err = validateConfigFile(path, nil)
+	if err != nil || !v.Valid {
+		t.Fatalf("valid config: %+v, err %v", v, err)
+	}
+	w := v.Config.Scoring.Weights
+	if w["fanout_weight"] != 1.5 || w["blast_radius_weight"] != scoring.Defaults().BlastRadiusWeight {
+		t.Errorf("weights not resolved: %v", w)
+	}
+	if g := v.Config.Scoring.GradeThresholds; g["A"] != 5 || g["D"] != scoring.DefaultGradeThresholds().D {
+		t.Errorf("grade thresholds not resolved: %v", g)
+	}
+
+	var out bytes.Buffer
+	if err := runConfigValidate(&out, "", path, "json", nil); err != nil {
+		t.Fatalf("runConfigValidate: %v", err)
+	}
+	if !strings.Contains(out.String(), `"fanout_weight": 1.5`) || !strings.Contains(out.String(), `"remote_cache"`) {
+		t.Errorf("JSON output lacks the yaml keys:\n%s", out.String())
+	}
+}
+
+func TestConfigOverrides(t *testing.T) {
+	overrides, err := parseConfigOverrides(
+		[]string{"TOPOSCOPE_EXTRACTION_TIMEOUT=300", "TOPOSCOPE_URL=https://toposcope.example.com"},
+		[]string{"extraction.timeout=400", "scoring.weights.fanout_weight=2"},
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(overrides) != 3 || overrides[0].Source != "TOPOSCOPE_EXTRACTION_TIMEOUT" || overrides[1].Source != "--set" {
+		t.Fatalf("overrides = %+v, want the environment then --set", overrides)
+	}
+
+	wsRoot := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(wsRoot, ".toposcope"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(wsRoot, ".toposcope", "config.yaml"), []byte("extraction:\n  timeout: 120\n  bazel_path: bazel\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	configOverrides = overrides
+	t.Cleanup(func() { configOverrides = nil })
+	cfg := loadConfig(wsRoot)
+	if cfg.Extraction.Timeout != 400 || cfg.Extraction.BazelPath != "bazel" || cfg.Scoring.Weights["fanout_weight"] != 2 {
+		t.Errorf("loadConfig = %+v, %v", cfg.Extraction, cfg.Scoring.Weights)
+	}
+
+	if _, err := parseConfigOverrides(nil, []string{"scoring.fanout=2"}); err == nil {
+		t.Error("expected error for an unknown key")
+	}
+	if _, err := parseConfigOverrides([]string{"TOPOSCOPE_EXTRACTION_TIMEOUT=soon"}, nil); err == nil {
+		t.Error("expected error for a value of the wrong type")
+	}
+}
+
+func TestScoreSnapshotInputs(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	wsRoot := t.TempDir() // not a git repository; nothing needs checking out
+	if err := os.WriteFile(filepath.Join(wsRoot, "MODULE.bazel"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	base, err := graph.LoadSnapshot(filepath.Join("..", "..", "testdata", "snapshot_base.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	head, err := graph.LoadSnapshot(filepath.Join("..", "..", "testdata", "snapshot_head.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The base is a gzipped file, and the head is piped in on stdin.
+	basePath := filepath.Join(t.TempDir(), "base.json.gz")
+	if err := writeSnapshotOutput(basePath, base, true); err != nil {
+		t.Fatal(err)
+	}
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		_ = graph.WriteSnapshot(w, head, false)
+		w.Close()
+	}()
+	stdin := os.Stdin
+	os.Stdin = r
+	t.Cleanup(func() { os.Stdin = stdin })
+
+	run, err := scoreCommits(context.Background(), scoreOpts{repoPath: wsRoot, baseSnapshot: basePath, headSnapshot: "-"})
+	if err != nil {
+		t.Fatalf("scoreCommits: %v", err)
+	}
+	if run.baseSHA != base.CommitSHA || run.headSHA != head.CommitSHA || run.delta.Stats.AddedNodeCount != 3 {
+		t.Errorf("scored %s..%s with %+v", run.baseSHA, run.headSHA, run.delta.Stats)
+	}
+	if _, err := os.Stat(config.CacheDir(wsRoot)); !os.IsNotExist(err) {
+		t.Errorf("cache directory written to (%v)", err)
+	}
+
+	if _, err := readSnapshotInput("base-snapshot", filepath.Join(t.TempDir(), "missing.json")); err == nil || !strings.HasPrefix(err.Error(), "--base-snapshot: ") {
+		t.Errorf("missing file error = %v", err)
+	}
+}
+
+func TestScoreMatrix(t *testing.T) {
+	if got := uniqueRefs([]string{"v1.4.0", " main", "", "v1.4.0"}); !slices.Equal(got, []string{"v1.4.0", "main"}) {
+		t.Errorf("uniqueRefs = %q", got)
+	}
+
+	m := &scoreMatrix{
+		HeadCommit: "cccccccccccc",
+		Bases: []matrixEntry{
+			{Base: "v1.4.0", Result: &scoring.ScoreResult{
+				Grade: "D", TotalScore: 18.5, BaseCommit: "aaaaaaaaaaaa",
+				DeltaStats: scoring.DeltaStatsView{AddedNodes: 40, RemovedNodes: 2, AddedEdges: 120, RemovedEdges: 9},
+				Breakdown: []scoring.MetricResult{
+					{Key: "fanout_increase", Contribution: 6},
+					{Key: "blast_radius", Contribution: 11.5},
+					{Key: "cleanup_credits", Contribution: -1},
+				},
+			}},
+			{Base: "main", Result: &scoring.ScoreResult{Grade: "A", BaseCommit: "bbbbbbbbbbbb"}},
+		},
+	}
+	var buf bytes.Buffer
+	printScoreMatrix(&buf, m)
+	out := buf.String()
+	for _, want := range []string{"Scores for ccccccc against 2 bases", "v1.4.0  aaaaaaa  D      18.5   +40/-2  +120/-9  blast_radius (+11.5)", "main    bbbbbbb  A      0.0    +0/-0   +0/-0    -"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("table lacks %q:\n%s", want, out)
+		}
+	}
+
+	for _, args := range [][]string{
+		{"--base", "v1.4.0,main", "--interactive"},
+		{"--base", "v1.4.0", "--base", "main", "--head-snapshot", "-"},
+	} {
+		cmd := newScoreCmd()
+		cmd.SetArgs(args)
+		cmd.SetOut(io.Discard)
+		cmd.SetErr(io.Discard)
+		if err := cmd.Execute(); err == nil {
+			t.Errorf("expected %q to fail", args)
+		}
+	}
+}
+
+func TestBranchMergeBase(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+
+	dir := t.TempDir()
+	run := func(args ...string) string {
+		t.Helper()
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		cmd.Env = append(os.Environ(),
+			"GIT_AUTHOR_NAME=test", "GIT_AUTHOR_EMAIL=test@example.com",
+			"GIT_COMMITTER_NAME=test", "GIT_COMMITTER_EMAIL=test@example.com")
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+		return strings.TrimSpace(string(out))
+	}
+
+	// main: fork -> landed; feature: fork -> change.
+	run("init", "-q", "-b", "main")
+	run("commit", "-q", "--allow-empty", "-m", "fork")
+	fork := run("rev-parse", "HEAD")
+	run("tag", "v1.0")
+	run("checkout", "-q", "-b", "feature")
+	run("commit", "-q", "--allow-empty", "-m", "change")
+	head := run("rev-parse", "HEAD")
+	run("checkout", "-q", "main")
+	run("commit", "-q", "--allow-empty", "-m", "landed")
+	tip := run("rev-parse", "HEAD")
+	run("update-ref", "refs/remotes/origin/main", tip)
+
+	ctx := context.Background()
+	for _, tc := range []struct {
+		ref, sha, want string
+	}{
+		{"main", tip, fork},
+		{"origin/main", tip, fork},
+		{"v1.0", fork, fork}, // tags are compared as is
+		{tip, tip, tip},      // and so are commits
+	} {
+		if got := branchMergeBase(ctx, dir, tc.ref, tc.sha, head); got != tc.want {
+			t.Errorf("branchMergeBase(%s) = %s, want %s", tc.ref, got, tc.want)
+		}
+	}
+}
+
+func TestVirtualMerge(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+
+	dir := t.TempDir()
+	run := func(args ...string) string {
+		t.Helper()
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		cmd.Env = append(os.Environ(),
+			"GIT_AUTHOR_NAME=test", "GIT_AUTHOR_EMAIL=test@example.com",
+			"GIT_COMMITTER_NAME=test", "GIT_COMMITTER_EMAIL=test@example.com")
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+		return strings.TrimSpace(string(out))
+	}
+	write := func(name, content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		run("add", name)
+	}
+
+	// main: fork -> landed (adds a/BUILD); feature: fork -> change (adds b/BUILD).
+	run("init", "-q", "-b", "main")
+	write("BUILD", "# fork\n")
+	run("commit", "-q", "-m", "fork")
+	run("checkout", "-q", "-b", "feature")
+	write("b.BUILD", "# feature\n")
+	run("commit", "-q", "-m", "change")
+	head := run("rev-parse", "HEAD")
+	run("checkout", "-q", "main")
+	write("a.BUILD", "# main\n")
+	run("commit", "-q", "-m", "landed")
+	base := run("rev-parse", "HEAD")
+
+	ctx := context.Background()
+	merged, err := virtualMerge(ctx, dir, base, head)
+	if err != nil {
+		if strings.Contains(err.Error(), "needs git 2.38") {
+			t.Skipf("git merge-tree --write-tree not supported: %v", err)
+		}
+		t.Fatal(err)
+	}
+	if got := run("rev-list", "--parents", "-n", "1", merged); got != merged+" "+base+" "+head {
+		t.Errorf("parents of the merge = %q, want base and head", got)
+	}
+	if got := run("ls-tree", "--name-only", merged); got != "BUILD\na.BUILD\nb.BUILD" {
+		t.Errorf("merged tree = %q, want both sides' files", got)
+	}
+	if again, _ := virtualMerge(ctx, dir, base, head); again != merged {
+		t.Errorf("second merge = %s, want the same commit %s", again, merged)
+	}
+	if got := run("rev-parse", "HEAD"); got != base {
+		t.Errorf("HEAD moved to %s", got)
+	}
+
+	// Both sides change BUILD.
+	run("checkout", "-q", "feature")
+	write("BUILD", "# feature edit\n")
+	run("commit", "-q", "-m", "edit")
+	conflicting := run("rev-parse", "HEAD")
+	run("checkout", "-q", "main")
+	write("BUILD", "# main edit\n")
+	run("commit", "-q", "-m", "edit")
+	_, err = virtualMerge(ctx, dir, run("rev-parse", "HEAD"), conflicting)
+	if err == nil || !strings.Contains(err.Error(), "conflicts in BUILD") {
+		t.Errorf("conflicting merge: err = %v, want conflicts in BUILD", err)
+	}
+}
+
+func TestScoreSigner(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, _ := x509.MarshalPKCS8PrivateKey(priv)
+	keyFile := filepath.Join(t.TempDir(), "key.pem")
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if s, err := signerFromEnv(func(string) string { return "" }); s != nil || err != nil {
+		t.Errorf("no key: signer = %v, err = %v; want neither", s, err)
+	}
+	env := map[string]string{"TOPOSCOPE_SIGNING_KEY_FILE": keyFile, "RUNNER_NAME": "gh-runner-3"}
+	s, err := signerFromEnv(func(k string) string { return env[k] })
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	head := &graph.Snapshot{CommitSHA: "abc", Nodes: map[string]*graph.Node{}}
+	pr := 12
+	req := &client.IngestRequest{
+		RepoFullName: "acme/app", CommitSHA: "abc", PRNumber: &pr,
+		Snapshot: head, BaseSnapshot: &graph.Snapshot{CommitSHA: "aaa"},
+		Score: &scoring.ScoreResult{TotalScore: 7},
+	}
+	if err := s.sign(req); err != nil {
+		t.Fatal(err)
+	}
+	if req.Score != nil || req.SignedScore == nil {
+		t.Fatalf("sign left score %v, signed score %v", req.Score, req.SignedScore)
+	}
+	st, err := req.SignedScore.Verify([]ed25519.PublicKey{pub})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.Repo != "acme/app" || st.CommitSHA != "abc" || st.Score.TotalScore != 7 {
+		t.Errorf("signed statement = %+v", st)
+	}
+	if st.SnapshotID != graph.ContentID(head) || st.BaseCommitSHA != "aaa" || st.PRNumber == nil || *st.PRNumber != 12 {
+		t.Errorf("signed change = snapshot %q, base %q, PR %v", st.SnapshotID, st.BaseCommitSHA, st.PRNumber)
+	}
+	if st.Provenance.RunnerID != "gh-runner-3" || st.Provenance.CLIVersion != version || st.Provenance.SignedAt == "" {
+		t.Errorf("provenance = %+v", st.Provenance)
+	}
+
+	env["TOPOSCOPE_SIGNING_KEY"] = "not a key"
+	if _, err := signerFromEnv(func(k string) string { return env[k] }); err == nil {
+		t.Error("signerFromEnv accepted a bad key")
+	}
+}
+
+func TestBundleImportChecksPlatform(t *testing.T) {
+	var buf bytes.Buffer
+	if err := bundle.Write(&buf, &bundle.Bundle{Repo: "acme/app"}); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "app.bundle")
+	if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("TOPOSCOPE_API_KEY", "key")
+
+	for _, tc := range []struct {
+		name, meta string
+		want       string // substring of the error; "" for a successful import
+	}{
+		{"compatible", fmt.Sprintf(`{"api_version":"2.0.0","min_client_api_version":"2.0.0","bundle_format":%d}`, bundle.FormatVersion), ""},
+		{"old client", `{"server_version":"9.0.0","api_version":"2.4.0","min_client_api_version":"2.4.0","bundle_format":1}`, "upgrade toposcope"},
+		{"newer bundle format", fmt.Sprintf(`{"api_version":"2.0.0","min_client_api_version":"2.0.0","bundle_format":%d}`, bundle.FormatVersion+1), "upgrade toposcope"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			imported := false
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch r.URL.Path {
+				case "/api/v2/meta":
+					_, _ = w.Write([]byte(tc.meta))
+				case "/api/v2/bundles":
+					imported = true
+					_, _ = w.Write([]byte(`{"snapshots":0,"deltas":0,"scores":0}`))
+				default:
+					http.NotFound(w, r)
+				}
+			}))
+			defer srv.Close()
+
+			err := runBundleImport(context.Background(), path, srv.URL)
+			if tc.want == "" {
+				if err != nil || !imported {
+					t.Errorf("import: err = %v, imported = %v", err, imported)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Errorf("import: err = %v, want %q", err, tc.want)
+			}
+			if imported {
+				t.Error("bundle was uploaded to an incompatible platform")
+			}
+		})
+	}
+}
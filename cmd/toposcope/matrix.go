@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/toposcope/toposcope/pkg/scoring"
+)
+
+// scoreMatrix is the result of scoring one head against several bases.
+type scoreMatrix struct {
+	HeadCommit string        `json:"head_commit"`
+	Bases      []matrixEntry `json:"bases"`
+}
+
+type matrixEntry struct {
+	Base   string               `json:"base"` // the ref as given to --base
+	Result *scoring.ScoreResult `json:"result"`
+}
+
+// runScoreMatrix scores opts.headRef against each of bases in turn and
+// prints the scores side by side. The head is extracted once and then read
+// from the cache.
+func runScoreMatrix(ctx context.Context, opts scoreOpts, bases []string) error {
+	m := &scoreMatrix{}
+	for _, base := range bases {
+		o := opts
+		o.baseRef = base
+		run, err := scoreCommits(ctx, o)
+		if err != nil {
+			return fmt.Errorf("scoring against %s: %w", base, err)
+		}
+		m.HeadCommit = run.headSHA
+		m.Bases = append(m.Bases, matrixEntry{Base: base, Result: run.result})
+	}
+
+	switch opts.outputFmt {
+	case "json":
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(m); err != nil {
+			return fmt.Errorf("encoding JSON: %w", err)
+		}
+	default:
+		printScoreMatrix(os.Stdout, m)
+	}
+	return nil
+}
+
+func printScoreMatrix(w io.Writer, m *scoreMatrix) {
+	fmt.Fprintf(w, "Scores for %s against %d bases\n\n", shortSHA(m.HeadCommit), len(m.Bases))
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "BASE\tCOMMIT\tGRADE\tSCORE\tNODES\tEDGES\tTOP METRIC")
+	for _, e := range m.Bases {
+		r := e.Result
+		top := "-"
+		if mr := topMetric(r); mr != nil {
+			top = fmt.Sprintf("%s (%+.1f)", mr.Key, mr.Contribution)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%.1f\t+%d/-%d\t+%d/-%d\t%s\n",
+			e.Base, shortSHA(r.BaseCommit), r.Grade, r.TotalScore,
+			r.DeltaStats.AddedNodes, r.DeltaStats.RemovedNodes,
+			r.DeltaStats.AddedEdges, r.DeltaStats.RemovedEdges, top)
+	}
+	tw.Flush()
+}
+
+// topMetric returns the metric that added the most to r's score, or nil if
+// none added anything.
+func topMetric(r *scoring.ScoreResult) *scoring.MetricResult {
+	var top *scoring.MetricResult
+	for i := range r.Breakdown {
+		mr := &r.Breakdown[i]
+		if mr.Contribution > 0 && (top == nil || mr.Contribution > top.Contribution) {
+			top = mr
+		}
+	}
+	return top
+}
+
+// uniqueRefs returns refs without blanks and repeats, in order.
+func uniqueRefs(refs []string) []string {
+	seen := make(map[string]bool)
+	var out []string
+	for _, ref := range refs {
+		ref = strings.TrimSpace(ref)
+		if ref != "" && !seen[ref] {
+			seen[ref] = true
+			out = append(out, ref)
+		}
+	}
+	return out
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/toposcope/toposcope/pkg/surface"
+)
+
+func newPlanCmd() *cobra.Command {
+	var (
+		baseRef         string
+		headRef         string
+		repoPath        string
+		bazelPath       string
+		bazelRC         string
+		useCQuery       bool
+		bazelDiffJar    string
+		normalize       bool
+		includeExternal bool
+		againstBaseline bool
+		platformURL     string
+		outputFmt       string
+		steps           int
+	)
+
+	cmd := &cobra.Command{
+		Use:   "plan",
+		Short: "Propose refactorings that would improve a change's score",
+		Long: `Scores a change as score does, then proposes a sequence of refactorings that
+would lower the score: removing added edges that metrics flag, and splitting
+targets the change adds several dependents to. Steps are chosen greedily, each
+with the estimated score once it and every earlier step are applied.
+
+A split moves the new dependents onto a new, narrower target, and its estimate
+assumes that target needs none of the original's dependencies.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if againstBaseline && baseRef != "" {
+				return fmt.Errorf("--base and --against-baseline are mutually exclusive")
+			}
+			if baseRef == "" && !againstBaseline {
+				return fmt.Errorf(`required flag(s) "base" not set`)
+			}
+			return runPlan(cmd.Context(), planOpts{
+				score: scoreOpts{
+					baseRef:         baseRef,
+					headRef:         headRef,
+					repoPath:        repoPath,
+					bazelPath:       bazelPath,
+					bazelRC:         bazelRC,
+					useCQuery:       useCQuery,
+					bazelDiffJar:    bazelDiffJar,
+					normalize:       normalize,
+					includeExternal: includeExternal,
+					againstBaseline: againstBaseline,
+					platformURL:     platformURL,
+				},
+				outputFmt: outputFmt,
+				steps:     steps,
+			})
+		},
+	}
+
+	cmd.Flags().StringVar(&baseRef, "base", "", "Base git ref (required unless --against-baseline)")
+	cmd.Flags().StringVar(&headRef, "head", "HEAD", "Head git ref")
+	cmd.Flags().StringVar(&repoPath, "repo-path", "", "Path to repository root (default: detect workspace)")
+	cmd.Flags().StringVar(&bazelPath, "bazel-path", "", "Path to bazel/bazelisk binary")
+	cmd.Flags().StringVar(&bazelRC, "bazelrc", "", "Path to .bazelrc file")
+	cmd.Flags().BoolVar(&useCQuery, "cquery", false, "Use cquery instead of query")
+	cmd.Flags().StringVar(&bazelDiffJar, "bazel-diff-jar", "", "Path to bazel-diff.jar")
+	cmd.Flags().BoolVar(&normalize, "normalize", false, "Normalize the score by repository size before grading")
+	cmd.Flags().BoolVar(&includeExternal, "include-external", false, "Retain external dependencies as one node per external repo")
+	cmd.Flags().BoolVar(&againstBaseline, "against-baseline", false, "Plan against the recorded baseline instead of --base")
+	cmd.Flags().StringVar(&platformURL, "platform-url", os.Getenv("TOPOSCOPE_URL"), "Toposcope platform URL to fetch the baseline from (default: $TOPOSCOPE_URL)")
+	cmd.Flags().StringVar(&outputFmt, "output", "markdown", "Output format: markdown or json")
+	cmd.Flags().IntVar(&steps, "steps", 5, "Maximum number of steps to propose")
+
+	return cmd
+}
+
+type planOpts struct {
+	score     scoreOpts
+	outputFmt string
+	steps     int
+}
+
+func runPlan(ctx context.Context, opts planOpts) error {
+	if opts.outputFmt != "markdown" && opts.outputFmt != "json" {
+		return fmt.Errorf("unknown output format %q (want markdown or json)", opts.outputFmt)
+	}
+
+	run, err := scoreCommits(ctx, opts.score)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Planning refactorings...\n")
+	plan, err := run.engine.Plan(run.delta, run.baseSnap, run.headSnap, opts.steps)
+	if err != nil {
+		return fmt.Errorf("planning: %w", err)
+	}
+
+	if opts.outputFmt == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(plan); err != nil {
+			return fmt.Errorf("encoding JSON: %w", err)
+		}
+		return nil
+	}
+	if err := surface.RenderPlanMarkdown(os.Stdout, plan); err != nil {
+		return fmt.Errorf("rendering: %w", err)
+	}
+	return nil
+}
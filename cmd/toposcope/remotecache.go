@@ -0,0 +1,194 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/toposcope/toposcope/internal/ingestion"
+	"github.com/toposcope/toposcope/pkg/config"
+	"github.com/toposcope/toposcope/pkg/extract"
+	"github.com/toposcope/toposcope/pkg/extract/subgraph"
+	"github.com/toposcope/toposcope/pkg/graph"
+)
+
+// remoteCache shares cached snapshots and bazel-diff hash files through a
+// platform storage backend. A nil *remoteCache is valid and disabled, and all
+// remote failures degrade to cache misses.
+type remoteCache struct {
+	store     ingestion.StorageClient
+	namespace string
+	options   string // digest of the extraction options; see extractionOptions
+}
+
+// openRemoteCache returns the configured remote cache for snapshots
+// extracted by ext, or nil if none is configured or it cannot be opened.
+func openRemoteCache(ctx context.Context, wsRoot string, cfg *config.Config, ext *subgraph.Extractor) *remoteCache {
+	rc := cfg.RemoteCache
+	var store ingestion.StorageClient
+	var err error
+	switch rc.Backend {
+	case "":
+		return nil
+	case "s3":
+		store, err = ingestion.NewS3Storage(ctx, ingestion.S3Config{
+			Bucket:    rc.Bucket,
+			Region:    rc.Region,
+			Endpoint:  rc.Endpoint,
+			AccessKey: os.Getenv("AWS_ACCESS_KEY_ID"),
+			SecretKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		})
+	case "gcs":
+		store, err = ingestion.NewGCSStorage(ctx, rc.Bucket)
+	case "local":
+		store = ingestion.NewLocalStorage(rc.Path)
+	default:
+		err = fmt.Errorf("unknown backend %q (want s3, gcs, or local)", rc.Backend)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: remote cache disabled: %v\n", err)
+		return nil
+	}
+
+	return &remoteCache{
+		store:     store,
+		namespace: firstNonEmpty(rc.Namespace, config.RepoSlug(wsRoot)),
+		options:   extractionOptions(ext),
+	}
+}
+
+// extractionOptions digests the extractor options that change a snapshot's
+// content, with defaults filled in, so workers share snapshots only when
+// they extract them the same way.
+func extractionOptions(ext *subgraph.Extractor) string {
+	opts := struct {
+		UseCQuery       bool                    `json:"cquery"`
+		EdgeAttributes  map[string]string       `json:"edge_attributes"`
+		IncludeExternal bool                    `json:"include_external"`
+		RepoMapping     map[string]string       `json:"repo_mapping"`
+		Generated       graph.GeneratedPatterns `json:"generated"`
+		LabelRewrites   []subgraph.LabelRewrite `json:"label_rewrites"`
+	}{
+		UseCQuery:       ext.UseCQuery,
+		EdgeAttributes:  ext.EdgeAttributes,
+		IncludeExternal: ext.IncludeExternal,
+		RepoMapping:     ext.RepoMapping,
+		Generated:       graph.DefaultGeneratedPatterns,
+		LabelRewrites:   ext.LabelRewrites,
+	}
+	if opts.EdgeAttributes == nil {
+		opts.EdgeAttributes = extract.DefaultEdgeAttributes
+	}
+	if ext.Generated != nil {
+		opts.Generated = *ext.Generated
+	}
+	data, _ := json.Marshal(opts) // maps marshal with sorted keys
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:8])
+}
+
+// snapshotKey returns the remote object ID of the snapshot for sha.
+func (c *remoteCache) snapshotKey(sha string) string {
+	return sha + "-" + c.options
+}
+
+// loadSnapshot loads a snapshot from the local cache, falling back to the
+// remote cache. Remote hits are written to the local cache.
+func (c *remoteCache) loadSnapshot(ctx context.Context, wsRoot, sha string) (*graph.Snapshot, error) {
+	snap, err := loadCachedSnapshot(wsRoot, sha)
+	if err == nil || c == nil {
+		return snap, err
+	}
+
+	data, rerr := c.store.GetSnapshot(ctx, c.namespace, c.snapshotKey(sha))
+	if rerr != nil {
+		return nil, err
+	}
+	var remote graph.Snapshot
+	if rerr := json.Unmarshal(data, &remote); rerr != nil {
+		fmt.Fprintf(os.Stderr, "Warning: ignoring invalid remote snapshot %s: %v\n", sha, rerr)
+		return nil, err
+	}
+
+	fmt.Fprintf(os.Stderr, "  Snapshot %s: remote cache hit\n", sha[:minInt(7, len(sha))])
+	saveCachedSnapshot(wsRoot, sha, &remote)
+	return &remote, nil
+}
+
+// saveSnapshot writes a snapshot to the local cache and uploads it.
+func (c *remoteCache) saveSnapshot(ctx context.Context, wsRoot, sha string, snap *graph.Snapshot) {
+	saveCachedSnapshot(wsRoot, sha, snap)
+	c.uploadSnapshot(ctx, sha, snap)
+}
+
+// uploadSnapshot writes a snapshot to the remote cache only.
+func (c *remoteCache) uploadSnapshot(ctx context.Context, sha string, snap *graph.Snapshot) {
+	if c == nil {
+		return
+	}
+
+	data, err := json.Marshal(snap)
+	if err == nil {
+		err = c.store.PutSnapshot(ctx, c.namespace, c.snapshotKey(sha), data)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: uploading snapshot to remote cache: %v\n", err)
+	}
+}
+
+// hashKey maps a local hash cache path to its remote object ID. Hash files
+// share the snapshot keyspace under a "hashes/" prefix.
+func hashKey(path string) string {
+	return "hashes/" + strings.TrimSuffix(filepath.Base(path), ".json")
+}
+
+// fetchHashes downloads any of the given hash cache files missing locally.
+// It returns the set of paths that exist locally afterwards.
+func (c *remoteCache) fetchHashes(ctx context.Context, paths ...string) map[string]bool {
+	present := make(map[string]bool)
+	for _, path := range paths {
+		if _, err := os.Stat(path); err == nil {
+			present[path] = true
+			continue
+		}
+		if c == nil {
+			continue
+		}
+		data, err := c.store.GetSnapshot(ctx, c.namespace, hashKey(path))
+		if err != nil {
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			continue
+		}
+		if err := os.WriteFile(path, data, 0o644); err == nil {
+			present[path] = true
+		}
+	}
+	return present
+}
+
+// pushHashes uploads hash cache files that were not already present before
+// change detection ran.
+func (c *remoteCache) pushHashes(ctx context.Context, present map[string]bool, paths ...string) {
+	if c == nil {
+		return
+	}
+	for _, path := range paths {
+		if path == "" || present[path] {
+			continue
+		}
+		data, err := os.ReadFile(path)
+		if err == nil {
+			err = c.store.PutSnapshot(ctx, c.namespace, hashKey(path), data)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: uploading hashes to remote cache: %v\n", err)
+		}
+	}
+}
@@ -0,0 +1,353 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/toposcope/toposcope/pkg/config"
+	"github.com/toposcope/toposcope/pkg/graph"
+	"github.com/toposcope/toposcope/pkg/graphquery"
+)
+
+func newReportCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "report",
+		Short: "Generate architecture reports from snapshots",
+		Long:  `Analyzes a cached snapshot and produces reports for periodic architecture reviews.`,
+	}
+
+	cmd.AddCommand(newReportOffendersCmd())
+	cmd.AddCommand(newReportConformanceCmd())
+
+	return cmd
+}
+
+func newReportOffendersCmd() *cobra.Command {
+	var (
+		repoPath     string
+		snapshotRef  string
+		top          int
+		outputFmt    string
+		includeTests bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "offenders",
+		Short: "List the top targets by fan-in, fan-out, centrality, and cycles",
+		Long: `Analyzes the latest snapshot (or the one given by --snapshot) and prints the
+top N targets by fan-in, fan-out, PageRank centrality, and cycle participation.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runReportOffenders(reportOffendersOpts{
+				repoPath:     repoPath,
+				snapshotRef:  snapshotRef,
+				top:          top,
+				outputFmt:    outputFmt,
+				includeTests: includeTests,
+			})
+		},
+	}
+
+	cmd.Flags().StringVar(&repoPath, "repo-path", "", "Path to repository root (default: detect workspace)")
+	cmd.Flags().StringVar(&snapshotRef, "snapshot", "", "Snapshot file path or commit SHA (default: latest cached snapshot)")
+	cmd.Flags().IntVar(&top, "top", 10, "Number of targets to list per category")
+	cmd.Flags().StringVar(&outputFmt, "output", "text", "Output format: text, markdown, or json")
+	cmd.Flags().BoolVar(&includeTests, "include-tests", false, "Include test targets in the rankings")
+
+	return cmd
+}
+
+type reportOffendersOpts struct {
+	repoPath     string
+	snapshotRef  string
+	top          int
+	outputFmt    string
+	includeTests bool
+}
+
+func runReportOffenders(opts reportOffendersOpts) error {
+	snap, err := resolveReportSnapshot(opts.repoPath, opts.snapshotRef)
+	if err != nil {
+		return err
+	}
+
+	report := graphquery.TopOffenders(snap, opts.top, opts.includeTests)
+
+	switch opts.outputFmt {
+	case "json":
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(report); err != nil {
+			return fmt.Errorf("encoding JSON: %w", err)
+		}
+	case "markdown":
+		printOffendersMarkdown(os.Stdout, report)
+	case "text", "":
+		printOffendersText(os.Stdout, report)
+	default:
+		return fmt.Errorf("unknown output format %q (want text, markdown, or json)", opts.outputFmt)
+	}
+
+	return nil
+}
+
+// resolveReportSnapshot loads the snapshot a report should run against. ref
+// may be a path to a snapshot file or a commit SHA in the snapshot cache; when
+// empty, the most recently cached snapshot is used.
+func resolveReportSnapshot(repoPath, ref string) (*graph.Snapshot, error) {
+	if ref != "" {
+		if _, err := os.Stat(ref); err == nil {
+			snap, err := graph.LoadSnapshot(ref)
+			if err != nil {
+				return nil, fmt.Errorf("loading snapshot: %w", err)
+			}
+			return snap, nil
+		}
+	}
+
+	wsRoot, err := resolveWorkspace(repoPath)
+	if err != nil {
+		return nil, err
+	}
+
+	if ref == "" {
+		fmt.Fprintf(os.Stderr, "Using latest cached snapshot\n")
+		return latestCachedSnapshot(wsRoot)
+	}
+
+	snap, err := loadCachedSnapshot(wsRoot, ref)
+	if err != nil {
+		return nil, fmt.Errorf("no cached snapshot for %s: %w", ref, err)
+	}
+	return snap, nil
+}
+
+type offenderSection struct {
+	title   string
+	column  string
+	entries []graphquery.OffenderEntry
+	format  func(float64) string
+}
+
+func offenderSections(report *graphquery.OffendersReport) []offenderSection {
+	count := func(v float64) string { return fmt.Sprintf("%d", int(v)) }
+	return []offenderSection{
+		{"Fan-in (most depended upon)", "Dependents", report.FanIn, count},
+		{"Fan-out (most dependencies)", "Dependencies", report.FanOut, count},
+		{"Centrality (PageRank)", "Rank", report.Centrality, func(v float64) string { return fmt.Sprintf("%.4f", v) }},
+		{"Cycle participation", "Cycle size", report.Cycles, count},
+	}
+}
+
+func printOffendersText(w io.Writer, report *graphquery.OffendersReport) {
+	fmt.Fprintf(w, "Top offenders: %s\n", reportSnapshotLabel(report))
+	fmt.Fprintf(w, "  %d nodes, %d edges, %d cycles\n", report.NodeCount, report.EdgeCount, report.CycleCount)
+
+	for _, sec := range offenderSections(report) {
+		fmt.Fprintf(w, "\n%s:\n", sec.title)
+		if len(sec.entries) == 0 {
+			fmt.Fprintf(w, "  (none)\n")
+			continue
+		}
+		for i, e := range sec.entries {
+			fmt.Fprintf(w, "  %2d. %-60s %s\n", i+1, e.Key, sec.format(e.Value))
+		}
+	}
+}
+
+func printOffendersMarkdown(w io.Writer, report *graphquery.OffendersReport) {
+	fmt.Fprintf(w, "# Top offenders: %s\n\n", reportSnapshotLabel(report))
+	fmt.Fprintf(w, "%d nodes, %d edges, %d cycles\n", report.NodeCount, report.EdgeCount, report.CycleCount)
+
+	for _, sec := range offenderSections(report) {
+		fmt.Fprintf(w, "\n## %s\n\n", sec.title)
+		if len(sec.entries) == 0 {
+			fmt.Fprintf(w, "_None._\n")
+			continue
+		}
+		fmt.Fprintf(w, "| # | Target | Package | %s |\n", sec.column)
+		fmt.Fprintf(w, "|---|--------|---------|%s|\n", strings.Repeat("-", len(sec.column)+2))
+		for i, e := range sec.entries {
+			fmt.Fprintf(w, "| %d | `%s` | `%s` | %s |\n", i+1, e.Key, e.Package, sec.format(e.Value))
+		}
+	}
+}
+
+func reportSnapshotLabel(report *graphquery.OffendersReport) string {
+	if report.CommitSHA != "" {
+		return report.CommitSHA[:minInt(7, len(report.CommitSHA))]
+	}
+	return report.SnapshotID
+}
+
+func newReportConformanceCmd() *cobra.Command {
+	var (
+		repoPath     string
+		snapshotRef  string
+		baselineRef  string
+		top          int
+		outputFmt    string
+		includeTests bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "conformance",
+		Short: "Check the graph against declared boundaries and layers",
+		Long: `Checks every cross-package edge of the latest snapshot (or the one given by
+--snapshot) against the boundaries and layers in the scoring config. Prints the
+share of conforming edges, the packages with the most violations, packages that
+cluster with another boundary, and the change since a baseline snapshot.
+
+The baseline is --baseline, or else the cached snapshot at the merge base with
+the default branch when there is one.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runReportConformance(reportConformanceOpts{
+				repoPath:     repoPath,
+				snapshotRef:  snapshotRef,
+				baselineRef:  baselineRef,
+				top:          top,
+				outputFmt:    outputFmt,
+				includeTests: includeTests,
+			})
+		},
+	}
+
+	cmd.Flags().StringVar(&repoPath, "repo-path", "", "Path to repository root (default: detect workspace)")
+	cmd.Flags().StringVar(&snapshotRef, "snapshot", "", "Snapshot file path or commit SHA (default: latest cached snapshot)")
+	cmd.Flags().StringVar(&baselineRef, "baseline", "", "Baseline snapshot file path or commit SHA (default: cached snapshot at the merge base)")
+	cmd.Flags().IntVar(&top, "top", 10, "Number of violating packages to list")
+	cmd.Flags().StringVar(&outputFmt, "output", "text", "Output format: text, markdown, or json")
+	cmd.Flags().BoolVar(&includeTests, "include-tests", false, "Check edges from test targets too")
+
+	return cmd
+}
+
+type reportConformanceOpts struct {
+	repoPath     string
+	snapshotRef  string
+	baselineRef  string
+	top          int
+	outputFmt    string
+	includeTests bool
+}
+
+func runReportConformance(opts reportConformanceOpts) error {
+	switch opts.outputFmt {
+	case "json", "markdown", "text", "":
+	default:
+		return fmt.Errorf("unknown output format %q (want text, markdown, or json)", opts.outputFmt)
+	}
+
+	snap, err := resolveReportSnapshot(opts.repoPath, opts.snapshotRef)
+	if err != nil {
+		return err
+	}
+
+	cfg := config.DefaultConfig()
+	wsRoot, wsErr := resolveWorkspace(opts.repoPath)
+	if wsErr == nil {
+		cfg = loadConfig(wsRoot)
+	}
+	rules := graphquery.ConformanceRules{
+		Boundaries:   cfg.Scoring.Boundaries,
+		Layers:       cfg.Scoring.Layers,
+		IncludeTests: opts.includeTests,
+	}
+	report := graphquery.Conformance(snap, rules, opts.top)
+
+	var base *graph.Snapshot
+	if opts.baselineRef != "" {
+		if base, err = resolveReportSnapshot(opts.repoPath, opts.baselineRef); err != nil {
+			return fmt.Errorf("baseline: %w", err)
+		}
+	} else if wsErr == nil {
+		if _, b, err := resolveBaseline(context.Background(), wsRoot, "", nil); err == nil && b.CommitSHA != snap.CommitSHA {
+			base = b
+		}
+	}
+	if base != nil {
+		report.SetBaseline(graphquery.Conformance(base, rules, opts.top))
+	}
+
+	switch opts.outputFmt {
+	case "json":
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(report); err != nil {
+			return fmt.Errorf("encoding JSON: %w", err)
+		}
+	case "markdown":
+		printConformanceMarkdown(os.Stdout, report)
+	default:
+		printConformanceText(os.Stdout, report)
+	}
+
+	return nil
+}
+
+func conformanceLabel(sha, id string) string {
+	if sha != "" {
+		return sha[:minInt(7, len(sha))]
+	}
+	return id
+}
+
+func printConformanceText(w io.Writer, report *graphquery.ConformanceReport) {
+	fmt.Fprintf(w, "Conformance: %s\n", conformanceLabel(report.CommitSHA, report.SnapshotID))
+	fmt.Fprintf(w, "  %.2f%% of %d cross-package edges conform (%d layer, %d cycle violations)\n",
+		report.Percent, report.Edges, report.ByKind[graphquery.ViolationLayer], report.ByKind[graphquery.ViolationCycle])
+	if b := report.Baseline; b != nil {
+		fmt.Fprintf(w, "  %+.2f points since %s (%.2f%%, %d violations)\n", b.Change, conformanceLabel(b.CommitSHA, b.SnapshotID), b.Percent, b.Violations)
+	}
+
+	fmt.Fprintf(w, "\nTop violating packages:\n")
+	if len(report.TopPackages) == 0 {
+		fmt.Fprintf(w, "  (none)\n")
+	}
+	for i, p := range report.TopPackages {
+		fmt.Fprintf(w, "  %2d. %-60s %d\n", i+1, p.Package, p.Violations)
+	}
+
+	fmt.Fprintf(w, "\nPackages clustered with another boundary (modularity %.2f):\n", report.Modularity)
+	if len(report.Misplaced) == 0 {
+		fmt.Fprintf(w, "  (none)\n")
+	}
+	for _, m := range report.Misplaced {
+		fmt.Fprintf(w, "  %-60s %s -> %s\n", m.Package, m.Boundary, m.CommunityBoundary)
+	}
+}
+
+func printConformanceMarkdown(w io.Writer, report *graphquery.ConformanceReport) {
+	fmt.Fprintf(w, "# Conformance: %s\n\n", conformanceLabel(report.CommitSHA, report.SnapshotID))
+	fmt.Fprintf(w, "**%.2f%%** of %d cross-package edges conform (%d layer, %d cycle violations).\n",
+		report.Percent, report.Edges, report.ByKind[graphquery.ViolationLayer], report.ByKind[graphquery.ViolationCycle])
+	if b := report.Baseline; b != nil {
+		fmt.Fprintf(w, "\n%+.2f points since `%s` (%.2f%%, %d violations).\n", b.Change, conformanceLabel(b.CommitSHA, b.SnapshotID), b.Percent, b.Violations)
+	}
+
+	fmt.Fprintf(w, "\n## Top violating packages\n\n")
+	if len(report.TopPackages) == 0 {
+		fmt.Fprintf(w, "_None._\n")
+	} else {
+		fmt.Fprintf(w, "| # | Package | Boundary | Violations |\n")
+		fmt.Fprintf(w, "|---|---------|----------|------------|\n")
+		for i, p := range report.TopPackages {
+			fmt.Fprintf(w, "| %d | `%s` | %s | %d |\n", i+1, p.Package, p.Boundary, p.Violations)
+		}
+	}
+
+	fmt.Fprintf(w, "\n## Packages clustered with another boundary\n\n")
+	if len(report.Misplaced) == 0 {
+		fmt.Fprintf(w, "_None._\n")
+		return
+	}
+	fmt.Fprintf(w, "| Package | Boundary | Community | Community boundary |\n")
+	fmt.Fprintf(w, "|---------|----------|-----------|--------------------|\n")
+	for _, m := range report.Misplaced {
+		fmt.Fprintf(w, "| `%s` | %s | %d | %s |\n", m.Package, m.Boundary, m.Community, m.CommunityBoundary)
+	}
+}
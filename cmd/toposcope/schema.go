@@ -0,0 +1,70 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+	"github.com/toposcope/toposcope/pkg/jsonschema"
+)
+
+func newSchemaCmd() *cobra.Command {
+	var outDir string
+
+	cmd := &cobra.Command{
+		Use:   "schema [snapshot|delta|score|config]",
+		Short: "Print JSON Schemas for toposcope output",
+		Long: `Prints the JSON Schema for snapshots, deltas, score results, or the
+.toposcope/config.yaml file, generated from the Go types that produce them.
+With --out-dir, writes every schema to <dir>/<name>.schema.json instead.`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if outDir != "" {
+				return writeSchemas(outDir)
+			}
+			if len(args) == 0 {
+				return fmt.Errorf("schema: name a schema (snapshot, delta, score, or config) or pass --out-dir")
+			}
+			return printSchema(os.Stdout, args[0])
+		},
+	}
+
+	cmd.Flags().StringVar(&outDir, "out-dir", "", "Write all schemas to this directory")
+
+	return cmd
+}
+
+func printSchema(w io.Writer, name string) error {
+	s, err := jsonschema.For(name)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(s)
+}
+
+func writeSchemas(dir string) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("creating %s: %w", dir, err)
+	}
+	for _, c := range jsonschema.Canonical {
+		path := filepath.Join(dir, c.Name+".schema.json")
+		f, err := os.Create(path)
+		if err != nil {
+			return fmt.Errorf("writing schema: %w", err)
+		}
+		err = printSchema(f, c.Name)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return fmt.Errorf("writing %s: %w", path, err)
+		}
+		fmt.Fprintf(os.Stderr, "Wrote %s\n", path)
+	}
+	return nil
+}
@@ -1,18 +1,21 @@
 package main
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/toposcope/toposcope/pkg/config"
 	"github.com/toposcope/toposcope/pkg/extract"
 	"github.com/toposcope/toposcope/pkg/extract/bazeldiff"
+	"github.com/toposcope/toposcope/pkg/extract/gitdiff"
 	"github.com/toposcope/toposcope/pkg/extract/subgraph"
 	"github.com/toposcope/toposcope/pkg/graph"
 	"github.com/toposcope/toposcope/pkg/scoring"
@@ -21,78 +24,328 @@ import (
 
 func newScoreCmd() *cobra.Command {
 	var (
-		baseRef      string
-		headRef      string
-		repoPath     string
-		bazelPath    string
-		bazelRC      string
-		useCQuery    bool
-		outputFmt    string
-		bazelDiffJar string
+		baseRefs        []string
+		headRef         string
+		repoPath        string
+		bazelPath       string
+		bazelRC         string
+		useCQuery       bool
+		outputFmt       string
+		bazelDiffJar    string
+		normalize       bool
+		includeExternal bool
+		againstBaseline bool
+		platformURL     string
+		buildEvents     string
+		executionLog    string
+		interactive     bool
+		webURL          string
+		baseSnapshot    string
+		headSnapshot    string
+		mergeBase       bool
+		virtualMerge    bool
 	)
 
 	cmd := &cobra.Command{
 		Use:   "score",
 		Short: "Full structural health analysis pipeline",
-		Long:  `Runs change detection, subgraph extraction, delta computation, scoring, and rendering.`,
+		Long: `Runs change detection, subgraph extraction, delta computation, scoring, and rendering.
+
+When --base names a branch, the head is scored against the merge base of the
+two, where it branched off, rather than the branch tip, so changes that
+landed on the branch since aren't counted. --merge-base=false compares
+against the tip.
+
+With --virtual-merge, what gets scored is the result of merging the head
+into --base, as git merge-tree would, instead of the head commit. That is
+what lands after the merge, with whatever changed on the base since the
+head branched off. The merge is a commit outside any branch, and scoring
+fails if it conflicts.
+
+With --against-baseline, HEAD is scored against the recorded baseline instead
+of --base: the cached snapshot at the merge base with the default branch, or
+else the repository's baseline on the platform given by --platform-url. This
+is a quick check before pushing, as only HEAD needs extracting.
+
+With --interactive, the results open in a terminal UI: expand a metric to
+see its evidence, drill into a hotspot or evidence to see its dependencies
+and dependents as trees, and press o to open the target in the web UI at
+--web-url.
+
+--base-snapshot and --head-snapshot take a snapshot file, or - to read one
+from stdin, such as the output of toposcope snapshot --output -. The side
+given is used as is instead of being extracted, and its commit comes from
+the snapshot. Given snapshots aren't cached, and neither is the score.
+
+--base can be repeated, or given a comma-separated list, to score the head
+against each base, such as the last release tag and the default branch. The
+scores are printed side by side.`,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return runScore(cmd.Context(), scoreOpts{
-				baseRef:      baseRef,
-				headRef:      headRef,
-				repoPath:     repoPath,
-				bazelPath:    bazelPath,
-				bazelRC:      bazelRC,
-				useCQuery:    useCQuery,
-				outputFmt:    outputFmt,
-				bazelDiffJar: bazelDiffJar,
-			})
+			// The schema describes the output, so it doesn't need a change to score.
+			if outputFmt == "json-schema" {
+				return printSchema(os.Stdout, "score")
+			}
+			bases := uniqueRefs(baseRefs)
+			var baseRef string
+			if len(bases) > 0 {
+				baseRef = bases[0]
+			}
+			if againstBaseline && baseRef != "" {
+				return fmt.Errorf("--base and --against-baseline are mutually exclusive")
+			}
+			if againstBaseline && baseSnapshot != "" {
+				return fmt.Errorf("--base-snapshot and --against-baseline are mutually exclusive")
+			}
+			if baseRef == "" && baseSnapshot == "" && !againstBaseline {
+				return fmt.Errorf(`required flag(s) "base" not set`)
+			}
+			if err := checkSnapshotInputs(cmd, baseRef, baseSnapshot, headSnapshot); err != nil {
+				return err
+			}
+			if interactive && outputFmt != "text" {
+				return fmt.Errorf("--interactive and --output %s are mutually exclusive", outputFmt)
+			}
+			if interactive && (baseSnapshot == "-" || headSnapshot == "-") {
+				return fmt.Errorf("--interactive needs the terminal; it can't read a snapshot from stdin")
+			}
+			if virtualMerge && (againstBaseline || headSnapshot != "") {
+				return fmt.Errorf("--virtual-merge needs --base and a head commit; it can't be used with --against-baseline or --head-snapshot")
+			}
+			if len(bases) > 1 && interactive {
+				return fmt.Errorf("--interactive takes a single --base")
+			}
+			if len(bases) > 1 && headSnapshot == "-" {
+				return fmt.Errorf("scoring against several bases reads the head snapshot more than once; give --head-snapshot a file, not stdin")
+			}
+			opts := scoreOpts{
+				baseRef:         baseRef,
+				headRef:         headRef,
+				repoPath:        repoPath,
+				bazelPath:       bazelPath,
+				bazelRC:         bazelRC,
+				useCQuery:       useCQuery,
+				outputFmt:       outputFmt,
+				bazelDiffJar:    bazelDiffJar,
+				normalize:       normalize,
+				includeExternal: includeExternal,
+				againstBaseline: againstBaseline,
+				platformURL:     platformURL,
+				buildEvents:     buildEvents,
+				executionLog:    executionLog,
+				interactive:     interactive,
+				webURL:          webURL,
+				baseSnapshot:    baseSnapshot,
+				headSnapshot:    headSnapshot,
+				tipToTip:        !mergeBase,
+				virtualMerge:    virtualMerge,
+			}
+			if len(bases) > 1 {
+				return runScoreMatrix(cmd.Context(), opts, bases)
+			}
+			return runScore(cmd.Context(), opts)
 		},
 	}
 
-	cmd.Flags().StringVar(&baseRef, "base", "", "Base git ref (required)")
+	cmd.Flags().StringSliceVar(&baseRefs, "base", nil, "Base git ref, or several to compare (required unless --against-baseline or --base-snapshot)")
 	cmd.Flags().StringVar(&headRef, "head", "HEAD", "Head git ref")
 	cmd.Flags().StringVar(&repoPath, "repo-path", "", "Path to repository root (default: detect workspace)")
 	cmd.Flags().StringVar(&bazelPath, "bazel-path", "", "Path to bazel/bazelisk binary")
 	cmd.Flags().StringVar(&bazelRC, "bazelrc", "", "Path to .bazelrc file")
 	cmd.Flags().BoolVar(&useCQuery, "cquery", false, "Use cquery instead of query")
-	cmd.Flags().StringVar(&outputFmt, "output", "text", "Output format: text or json")
+	cmd.Flags().BoolVar(&includeExternal, "include-external", false, "Retain external dependencies as one node per external repo")
+	cmd.Flags().StringVar(&outputFmt, "output", "text", "Output format: text, json, or json-schema")
 	cmd.Flags().StringVar(&bazelDiffJar, "bazel-diff-jar", "", "Path to bazel-diff.jar")
-	_ = cmd.MarkFlagRequired("base")
+	cmd.Flags().BoolVar(&normalize, "normalize", false, "Normalize the score by repository size before grading")
+	cmd.Flags().BoolVar(&againstBaseline, "against-baseline", false, "Score HEAD against the recorded baseline instead of --base")
+	cmd.Flags().StringVar(&platformURL, "platform-url", os.Getenv("TOPOSCOPE_URL"), "Toposcope platform URL to fetch the baseline from (default: $TOPOSCOPE_URL)")
+	cmd.Flags().StringVar(&buildEvents, "build-events", "", "Build event protocol JSON file from building head, for build durations")
+	cmd.Flags().StringVar(&executionLog, "execution-log", "", "JSON execution log from building head, for cache hit rates")
+	cmd.Flags().BoolVarP(&interactive, "interactive", "i", false, "Browse the results in a terminal UI")
+	cmd.Flags().StringVar(&webURL, "web-url", firstNonEmpty(os.Getenv("TOPOSCOPE_WEB_URL"), "http://localhost:3000"), "Web UI that --interactive opens targets in (default: $TOPOSCOPE_WEB_URL)")
+	cmd.Flags().StringVar(&baseSnapshot, "base-snapshot", "", "Base snapshot file, or - for stdin, instead of extracting --base")
+	cmd.Flags().StringVar(&headSnapshot, "head-snapshot", "", "Head snapshot file, or - for stdin, instead of extracting --head")
+	cmd.Flags().BoolVar(&mergeBase, "merge-base", true, "Score against the merge base with head when --base is a branch")
+	cmd.Flags().BoolVar(&virtualMerge, "virtual-merge", false, "Score the result of merging head into --base instead of head")
 
 	return cmd
 }
 
 type scoreOpts struct {
-	baseRef      string
-	headRef      string
-	repoPath     string
-	bazelPath    string
-	bazelRC      string
-	useCQuery    bool
-	outputFmt    string
-	bazelDiffJar string
+	baseRef         string
+	headRef         string
+	repoPath        string
+	bazelPath       string
+	bazelRC         string
+	useCQuery       bool
+	outputFmt       string
+	bazelDiffJar    string
+	normalize       bool
+	includeExternal bool
+
+	// With againstBaseline set, baseRef is ignored and the base snapshot
+	// comes from resolveBaseline.
+	againstBaseline bool
+	platformURL     string
+
+	// boundaries apply when the config sets no scoring.boundaries; ci
+	// takes them from the platform's repository settings.
+	boundaries []string
+
+	// buildEvents is a --build_event_json_file from building head. Its
+	// durations annotate the head snapshot.
+	buildEvents string
+	// executionLog is a --execution_log_json_file from the same build. Its
+	// cache hits annotate the head snapshot.
+	executionLog string
+
+	// interactive shows the result in the terminal UI instead of printing
+	// it. Targets open in the web UI at webURL.
+	interactive bool
+	webURL      string
+
+	// baseSnapshot and headSnapshot name snapshot files, or "-" for stdin,
+	// to use instead of extracting baseRef and headRef.
+	baseSnapshot string
+	headSnapshot string
+
+	// tipToTip compares against the commit baseRef names even when it is
+	// a branch, instead of the merge base with headRef.
+	tipToTip bool
+
+	// virtualMerge scores the merge of headRef into baseRef against
+	// baseRef instead, the tree that would land.
+	virtualMerge bool
+}
+
+// scoreRun holds the outputs of the score pipeline.
+type scoreRun struct {
+	wsRoot   string
+	cfg      *config.Config
+	baseSHA  string
+	headSHA  string
+	baseSnap *graph.Snapshot
+	headSnap *graph.Snapshot
+	delta    *graph.Delta
+	engine   *scoring.Engine
+	result   *scoring.ScoreResult
 }
 
 func runScore(ctx context.Context, opts scoreOpts) error {
-	wsRoot, err := resolveWorkspace(opts.repoPath)
+	run, err := scoreCommits(ctx, opts)
 	if err != nil {
 		return err
 	}
+	result := run.result
+	if opts.interactive {
+		return runScoreTUI(run, opts.webURL)
+	}
+
+	// Render output
+	switch opts.outputFmt {
+	case "json":
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(result); err != nil {
+			return fmt.Errorf("encoding JSON: %w", err)
+		}
+	default:
+		renderer := &surface.TerminalRenderer{}
+		if err := renderer.Render(os.Stdout, result); err != nil {
+			return fmt.Errorf("rendering: %w", err)
+		}
+	}
+	writeStepSummary(result)
+
+	return nil
+}
+
+// writeStepSummary adds the report to the workflow run page when running
+// inside GitHub Actions.
+func writeStepSummary(result *scoring.ScoreResult) {
+	if ok, err := surface.WriteGitHubStepSummary(result); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	} else if ok {
+		fmt.Fprintf(os.Stderr, "Job summary written to $GITHUB_STEP_SUMMARY\n")
+	}
+}
+
+// scoreCommits runs change detection, extraction, delta computation, and
+// scoring for opts.baseRef..opts.headRef.
+func scoreCommits(ctx context.Context, opts scoreOpts) (*scoreRun, error) {
+	wsRoot, err := resolveWorkspace(opts.repoPath)
+	if err != nil {
+		return nil, err
+	}
 
 	cfg := loadConfig(wsRoot)
-	bp := firstNonEmpty(opts.bazelPath, cfg.Extraction.BazelPath, "bazelisk")
-	brc := firstNonEmpty(opts.bazelRC, cfg.Extraction.BazelRC)
+	if len(cfg.Scoring.Boundaries) == 0 {
+		cfg.Scoring.Boundaries = opts.boundaries
+	}
+	bp := bazelBinary(opts.bazelPath, cfg)
+	brc := bazelRCPath(wsRoot, opts.bazelRC, cfg)
 	cq := opts.useCQuery || cfg.Extraction.UseCQuery
+	ie := opts.includeExternal || cfg.Extraction.IncludeExternal
+	engine, err := newScoringEngine(wsRoot, cfg, opts.normalize)
+	if err != nil {
+		return nil, err
+	}
 	jarPath := firstNonEmpty(opts.bazelDiffJar, cfg.Extraction.BazelDiffJar, config.FindBazelDiffJar())
 
+	ext := &subgraph.Extractor{
+		WorkspacePath:    wsRoot,
+		BazelPath:        bp,
+		BazelRC:          brc,
+		UseCQuery:        cq,
+		EdgeAttributes:   extract.EdgeAttributes(cfg.Extraction.EdgeAttributes),
+		IncludeExternal:  ie,
+		RepoMapping:      cfg.Extraction.RepoMapping,
+		Generated:        generatedPatterns(cfg),
+		LabelRewrites:    labelRewrites(cfg),
+		Limits:           extractionLimits(cfg),
+		OutputBase:       cfg.Extraction.OutputBaseDir(wsRoot, ""),
+		KeepServer:       cfg.Extraction.KeepServer,
+		ShardParallelism: cfg.Extraction.ShardParallelism,
+		ProfileDir:       cfg.Extraction.ProfileDir,
+	}
+	rc := openRemoteCache(ctx, wsRoot, cfg, ext)
+
 	// Resolve git refs
-	baseSHA, err := gitRevParse(ctx, wsRoot, opts.baseRef)
-	if err != nil {
-		return fmt.Errorf("resolving base ref: %w", err)
+	var baseSHA, headSHA string
+	var baseSnap, headSnap *graph.Snapshot
+	if opts.baseSnapshot != "" {
+		if baseSnap, err = readSnapshotInput("base-snapshot", opts.baseSnapshot); err != nil {
+			return nil, err
+		}
+		baseSHA = baseSnap.CommitSHA
+	} else if opts.againstBaseline {
+		baseSHA, baseSnap, err = resolveBaseline(ctx, wsRoot, opts.platformURL, rc)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		baseSHA, err = gitRevParse(ctx, wsRoot, opts.baseRef)
+		if err != nil {
+			return nil, fmt.Errorf("resolving base ref: %w", err)
+		}
 	}
-	headSHA, err := gitRevParse(ctx, wsRoot, opts.headRef)
-	if err != nil {
-		return fmt.Errorf("resolving head ref: %w", err)
+	if opts.headSnapshot != "" {
+		if headSnap, err = readSnapshotInput("head-snapshot", opts.headSnapshot); err != nil {
+			return nil, err
+		}
+		headSHA = headSnap.CommitSHA
+	} else if headSHA, err = gitRevParse(ctx, wsRoot, opts.headRef); err != nil {
+		return nil, fmt.Errorf("resolving head ref: %w", err)
+	}
+
+	if opts.virtualMerge {
+		merged, err := virtualMerge(ctx, wsRoot, baseSHA, headSHA)
+		if err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(os.Stderr, "Head: virtual merge of %s into %s (%s)\n", shortSHA(headSHA), shortSHA(baseSHA), shortSHA(merged))
+		headSHA = merged
+	} else if baseSnap == nil && !opts.againstBaseline && !opts.tipToTip {
+		baseSHA = branchMergeBase(ctx, wsRoot, opts.baseRef, baseSHA, headSHA)
 	}
 
 	fmt.Fprintf(os.Stderr, "Scoring: %s..%s\n", baseSHA[:minInt(7, len(baseSHA))], headSHA[:minInt(7, len(headSHA))])
@@ -111,8 +364,12 @@ func runScore(ctx context.Context, opts scoreOpts) error {
 			BazelRC:          brc,
 			UseCQuery:        cq,
 			CacheDir:         cacheDir,
+			CacheLimits:      hashCacheLimits(cfg),
+			OutputBase:       cfg.Extraction.OutputBaseDir(wsRoot, ""),
+			KeepServer:       cfg.Extraction.KeepServer,
 		}
 
+		present := rc.fetchHashes(ctx, runner.HashFilePath(baseSHA), runner.HashFilePath(headSHA))
 		cdResult, err = runner.DetectChanges(ctx, extract.ChangeDetectionRequest{
 			RepoPath:  wsRoot,
 			BaseSHA:   baseSHA,
@@ -128,95 +385,131 @@ func runScore(ctx context.Context, opts scoreOpts) error {
 			cdResult = nil
 		} else {
 			fmt.Fprintf(os.Stderr, "  Found %d impacted targets\n", len(cdResult.ImpactedTargets))
+			rc.pushHashes(ctx, present, cdResult.BaseHashFile, cdResult.HeadHashFile)
 		}
 	} else {
-		fmt.Fprintf(os.Stderr, "Step 1/4: Change detection skipped (no bazel-diff.jar found)\n")
-		fmt.Fprintf(os.Stderr, "  Hint: download bazel-diff.jar or pass --bazel-diff-jar\n")
+		fmt.Fprintf(os.Stderr, "Step 1/4: Change detection via git diff (runs after base extraction)\n")
 	}
 
 	// Step 2: Extract snapshots
 	// We need to extract at both commits. This requires git checkout.
 	fmt.Fprintf(os.Stderr, "Step 2/4: Extracting snapshots...\n")
-	ext := &subgraph.Extractor{
-		WorkspacePath: wsRoot,
-		BazelPath:     bp,
-		BazelRC:       brc,
-		UseCQuery:     cq,
-	}
 
 	// Try to load cached snapshots first
-	baseSnap, _ := loadCachedSnapshot(wsRoot, baseSHA)
-	headSnap, _ := loadCachedSnapshot(wsRoot, headSHA)
+	if baseSnap == nil {
+		baseSnap, _ = rc.loadSnapshot(ctx, wsRoot, baseSHA)
+	}
+	if headSnap == nil {
+		headSnap, _ = rc.loadSnapshot(ctx, wsRoot, headSHA)
+	}
 
 	// Record current HEAD so we can restore after checkout.
 	// Prefer symbolic ref (branch name) over SHA to avoid detached HEAD.
-	origRef, err := gitSymbolicRef(ctx, wsRoot)
-	if err != nil {
-		origRef, err = gitRevParse(ctx, wsRoot, "HEAD")
+	// With both snapshots at hand there is nothing to check out.
+	var origRef string
+	if baseSnap == nil || headSnap == nil {
+		origRef, err = gitSymbolicRef(ctx, wsRoot)
 		if err != nil {
-			return fmt.Errorf("getting current HEAD: %w", err)
+			origRef, err = gitRevParse(ctx, wsRoot, "HEAD")
+			if err != nil {
+				return nil, fmt.Errorf("getting current HEAD: %w", err)
+			}
 		}
-	}
 
-	// Check if working tree is dirty
-	dirty, err := gitIsDirty(ctx, wsRoot)
-	if err != nil {
-		return fmt.Errorf("checking working tree: %w", err)
-	}
+		// Check if working tree is dirty
+		dirty, err := gitIsDirty(ctx, wsRoot)
+		if err != nil {
+			return nil, fmt.Errorf("checking working tree: %w", err)
+		}
 
-	needsCheckout := (baseSnap == nil && baseSHA != origRef) || (headSnap == nil && headSHA != origRef)
+		needsCheckout := (baseSnap == nil && baseSHA != origRef) || (headSnap == nil && headSHA != origRef)
 
-	if needsCheckout && dirty {
-		return fmt.Errorf("working tree has uncommitted changes; commit or stash them before scoring across commits")
+		if needsCheckout && dirty {
+			return nil, fmt.Errorf("working tree has uncommitted changes; commit or stash them before scoring across commits")
+		}
 	}
 
 	// Extract base snapshot
 	if baseSnap == nil {
-		fmt.Fprintf(os.Stderr, "  Extracting base (%s)...\n", baseSHA[:7])
+		fmt.Fprintf(os.Stderr, "  Extracting base (%s)...\n", shortSHA(baseSHA))
 		if baseSHA != origRef {
 			if err := gitCheckout(ctx, wsRoot, baseSHA); err != nil {
-				return fmt.Errorf("checking out base commit: %w", err)
+				return nil, fmt.Errorf("checking out base commit: %w", err)
 			}
 			defer func() { _ = gitCheckout(ctx, wsRoot, origRef) }() // restore on exit
 		}
 		baseSnap, err = ext.ExtractFull(ctx, baseSHA, timeout)
 		if err != nil {
-			return fmt.Errorf("extracting base snapshot: %w", err)
+			return nil, fmt.Errorf("extracting base snapshot: %w", err)
 		}
-		saveCachedSnapshot(wsRoot, baseSHA, baseSnap)
+		warnSkippedPackages(baseSnap)
+		rc.saveSnapshot(ctx, wsRoot, baseSHA, baseSnap)
 
 		// Checkout back to head for head extraction
 		if baseSHA != origRef {
 			if err := gitCheckout(ctx, wsRoot, origRef); err != nil {
-				return fmt.Errorf("restoring HEAD after base extraction: %w", err)
+				return nil, fmt.Errorf("restoring HEAD after base extraction: %w", err)
 			}
 		}
+	} else if opts.baseSnapshot != "" {
+		fmt.Fprintf(os.Stderr, "  Base (%s): from --base-snapshot\n", shortSHA(baseSHA))
 	} else {
-		fmt.Fprintf(os.Stderr, "  Base (%s): cached\n", baseSHA[:7])
+		fmt.Fprintf(os.Stderr, "  Base (%s): cached\n", shortSHA(baseSHA))
 	}
 
 	// Extract head snapshot
 	if headSnap == nil {
-		fmt.Fprintf(os.Stderr, "  Extracting head (%s)...\n", headSHA[:7])
+		fmt.Fprintf(os.Stderr, "  Extracting head (%s)...\n", shortSHA(headSHA))
 		if headSHA != origRef {
 			if err := gitCheckout(ctx, wsRoot, headSHA); err != nil {
-				return fmt.Errorf("checking out head commit: %w", err)
+				return nil, fmt.Errorf("checking out head commit: %w", err)
 			}
 			defer func() { _ = gitCheckout(ctx, wsRoot, origRef) }()
 		}
 		headSnap, err = ext.ExtractFull(ctx, headSHA, timeout)
 		if err != nil {
-			return fmt.Errorf("extracting head snapshot: %w", err)
+			return nil, fmt.Errorf("extracting head snapshot: %w", err)
 		}
-		saveCachedSnapshot(wsRoot, headSHA, headSnap)
+		warnSkippedPackages(headSnap)
+		rc.saveSnapshot(ctx, wsRoot, headSHA, headSnap)
 
 		if headSHA != origRef {
 			if err := gitCheckout(ctx, wsRoot, origRef); err != nil {
-				return fmt.Errorf("restoring HEAD after head extraction: %w", err)
+				return nil, fmt.Errorf("restoring HEAD after head extraction: %w", err)
 			}
 		}
+	} else if opts.headSnapshot != "" {
+		fmt.Fprintf(os.Stderr, "  Head (%s): from --head-snapshot\n", shortSHA(headSHA))
 	} else {
-		fmt.Fprintf(os.Stderr, "  Head (%s): cached\n", headSHA[:7])
+		fmt.Fprintf(os.Stderr, "  Head (%s): cached\n", shortSHA(headSHA))
+	}
+
+	if opts.buildEvents != "" {
+		if err := annotateBuildEvents(headSnap, opts.buildEvents); err != nil {
+			return nil, err
+		}
+	}
+	if opts.executionLog != "" {
+		if err := annotateExecutionLog(headSnap, opts.executionLog); err != nil {
+			return nil, err
+		}
+	}
+
+	// Without bazel-diff, map changed files onto the base snapshot instead.
+	if jarPath == "" {
+		detector := &gitdiff.Detector{WorkspacePath: wsRoot, Base: baseSnap}
+		cdResult, err = detector.DetectChanges(ctx, extract.ChangeDetectionRequest{
+			RepoPath: wsRoot,
+			BaseSHA:  baseSHA,
+			HeadSHA:  headSHA,
+		})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "  Warning: native change detection failed: %v\n", err)
+			fmt.Fprintf(os.Stderr, "  Hint: download bazel-diff.jar or pass --bazel-diff-jar\n")
+			cdResult = nil
+		} else {
+			fmt.Fprintf(os.Stderr, "  Found %d impacted targets (git diff)\n", len(cdResult.ImpactedTargets))
+		}
 	}
 
 	// Step 3: Compute delta
@@ -233,33 +526,226 @@ func runScore(ctx context.Context, opts scoreOpts) error {
 	// Step 4: Score
 	fmt.Fprintf(os.Stderr, "Step 4/4: Scoring...\n")
 
-	metrics := scoring.DefaultMetrics()
-	engine := scoring.NewEngine(metrics...)
-
 	result, err := engine.Score(delta, baseSnap, headSnap)
 	if err != nil {
-		return fmt.Errorf("scoring: %w", err)
+		return nil, fmt.Errorf("scoring: %w", err)
 	}
 
-	// Save result to disk for the UI server
-	saveScoreResult(wsRoot, baseSHA, headSHA, result)
+	// Save result to disk for the UI server, unless the snapshots were given.
+	if opts.baseSnapshot == "" && opts.headSnapshot == "" {
+		saveScoreResult(wsRoot, baseSHA, headSHA, result)
+	}
 
-	// Render output
-	switch opts.outputFmt {
-	case "json":
-		enc := json.NewEncoder(os.Stdout)
-		enc.SetIndent("", "  ")
-		if err := enc.Encode(result); err != nil {
-			return fmt.Errorf("encoding JSON: %w", err)
+	return &scoreRun{
+		wsRoot:   wsRoot,
+		cfg:      cfg,
+		baseSHA:  baseSHA,
+		headSHA:  headSHA,
+		baseSnap: baseSnap,
+		headSnap: headSnap,
+		delta:    delta,
+		engine:   engine,
+		result:   result,
+	}, nil
+}
+
+// annotateBuildEvents sets the durations in a build event protocol JSON file
+// on the nodes of snap.
+func annotateBuildEvents(snap *graph.Snapshot, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("opening build events: %w", err)
+	}
+	defer f.Close()
+	timings, err := graph.ParseBuildEvents(f)
+	if err != nil {
+		return err
+	}
+	n := graph.AnnotateTimings(snap, timings)
+	fmt.Fprintf(os.Stderr, "  Annotated %d targets with build durations\n", n)
+	return nil
+}
+
+// annotateExecutionLog sets the cache stats in a JSON execution log on the
+// nodes of snap.
+func annotateExecutionLog(snap *graph.Snapshot, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("opening execution log: %w", err)
+	}
+	defer f.Close()
+	stats, err := graph.ParseExecutionLog(f)
+	if err != nil {
+		return err
+	}
+	n := graph.AnnotateCacheStats(snap, stats)
+	fmt.Fprintf(os.Stderr, "  Annotated %d targets with cache hit rates\n", n)
+	return nil
+}
+
+// newScoringEngine builds the engine the repo config asks for: its metrics,
+// grade thresholds, waivers, scored edge types, normalization, and
+// confidence level.
+func newScoringEngine(wsRoot string, cfg *config.Config, normalize bool) (*scoring.Engine, error) {
+	grades, err := gradeThresholds(cfg)
+	if err != nil {
+		return nil, err
+	}
+	waivers, err := configuredWaivers(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	metrics, err := configuredMetrics(cfg)
+	if err != nil {
+		return nil, err
+	}
+	engine := scoring.NewEngine(append(metrics, externalMetrics(wsRoot, cfg)...)...)
+	engine.SetGradeThresholds(grades)
+	engine.SetWaivers(waivers)
+	engine.SetEdgeTypes(cfg.Scoring.EdgeTypes)
+	if normalize || cfg.Scoring.Normalization == string(scoring.NormalizationSize) {
+		engine.SetNormalization(scoring.NormalizationSize)
+	}
+	engine.SetConfidenceLevel(cfg.Scoring.ConfidenceLevel)
+	return engine, nil
+}
+
+// configuredWeights returns the default weights with the overrides in
+// scoring.weights applied. The keys are those of the score preview API.
+func configuredWeights(cfg *config.Config) (scoring.DefaultWeights, error) {
+	w := scoring.Defaults()
+	if len(cfg.Scoring.Weights) == 0 {
+		return w, nil
+	}
+	data, err := json.Marshal(cfg.Scoring.Weights)
+	if err != nil {
+		return w, fmt.Errorf("invalid weights: %w", err)
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&w); err != nil {
+		return w, fmt.Errorf("invalid weights: %w", err)
+	}
+	return w, nil
+}
+
+// configuredMetrics returns the default metrics with repo config applied.
+func configuredMetrics(cfg *config.Config) ([]scoring.Metric, error) {
+	w, err := configuredWeights(cfg)
+	if err != nil {
+		return nil, err
+	}
+	metrics := scoring.MetricsFromWeights(w)
+	for _, m := range metrics {
+		switch m := m.(type) {
+		case *scoring.ThirdPartyMetric:
+			m.Allow = cfg.Scoring.ThirdPartyAllow
+		case *scoring.CrossPackageMetric:
+			m.Boundaries = cfg.Scoring.Boundaries
+			m.CrossLanguage = cfg.Scoring.CrossLanguage
+		case *scoring.FanoutMetric:
+			m.IncludeGenerated = cfg.Scoring.IncludeGenerated
+		case *scoring.CentralityMetric:
+			m.IncludeGenerated = cfg.Scoring.IncludeGenerated
+		case *scoring.CreditsMetric:
+			m.CrossLanguage = cfg.Scoring.CrossLanguage
 		}
-	default:
-		renderer := &surface.TerminalRenderer{}
-		if err := renderer.Render(os.Stdout, result); err != nil {
-			return fmt.Errorf("rendering: %w", err)
+	}
+	return metrics, nil
+}
+
+// generatedPatterns returns the configured patterns for generated targets,
+// or nil for the defaults.
+func generatedPatterns(cfg *config.Config) *graph.GeneratedPatterns {
+	g := cfg.Extraction.Generated
+	if g == nil {
+		return nil
+	}
+	return &graph.GeneratedPatterns{Kinds: g.Kinds, Tags: g.Tags}
+}
+
+// extractionLimits returns the configured resource limits for bazel queries.
+func extractionLimits(cfg *config.Config) subgraph.ResourceLimits {
+	return subgraph.ResourceLimits{MemoryMB: cfg.Extraction.MaxMemoryMB, CPUSeconds: cfg.Extraction.MaxCPUSeconds}
+}
+
+// labelRewrites returns the configured label normalization rules.
+func labelRewrites(cfg *config.Config) []subgraph.LabelRewrite {
+	var rewrites []subgraph.LabelRewrite
+	for _, r := range cfg.Extraction.Normalize {
+		rewrites = append(rewrites, subgraph.LabelRewrite{Match: r.Match, Replace: r.Replace})
+	}
+	return rewrites
+}
+
+// externalMetrics builds the external-process metrics declared in config.
+func externalMetrics(wsRoot string, cfg *config.Config) []scoring.Metric {
+	var metrics []scoring.Metric
+	for _, em := range cfg.Scoring.ExternalMetrics {
+		if em.Key == "" || em.Command == "" {
+			fmt.Fprintf(os.Stderr, "Warning: skipping external metric with missing key or command\n")
+			continue
 		}
+		metrics = append(metrics, &scoring.ExternalMetric{
+			MetricKey:  em.Key,
+			MetricName: firstNonEmpty(em.Name, em.Key),
+			Command:    em.Command,
+			Args:       em.Args,
+			Dir:        wsRoot,
+			Timeout:    time.Duration(em.Timeout) * time.Second,
+		})
 	}
+	return metrics
+}
 
-	return nil
+// configuredWaivers converts the waivers in config for the scoring engine,
+// warning about ones that have expired.
+func configuredWaivers(cfg *config.Config) ([]scoring.Waiver, error) {
+	var waivers []scoring.Waiver
+	for i, wc := range cfg.Scoring.Waivers {
+		if wc.Target == "" {
+			return nil, fmt.Errorf("waivers[%d]: target is required", i)
+		}
+		w := scoring.Waiver{Metric: wc.Metric, Target: wc.Target, Reason: wc.Reason}
+		if wc.Expires != "" {
+			day, err := time.Parse("2006-01-02", wc.Expires)
+			if err != nil {
+				return nil, fmt.Errorf("waivers[%d]: invalid expires %q (want YYYY-MM-DD)", i, wc.Expires)
+			}
+			end := day.AddDate(0, 0, 1)
+			w.Expires = &end
+			if !w.Active(time.Now()) {
+				fmt.Fprintf(os.Stderr, "Warning: waiver for %s expired on %s\n", wc.Target, wc.Expires)
+			}
+		}
+		waivers = append(waivers, w)
+	}
+	return waivers, nil
+}
+
+// gradeThresholds applies any grade_thresholds overrides from config on top
+// of the default grade boundaries.
+func gradeThresholds(cfg *config.Config) (scoring.GradeThresholds, error) {
+	t := scoring.DefaultGradeThresholds()
+	for grade, bound := range cfg.Scoring.GradeThresholds {
+		switch strings.ToUpper(grade) {
+		case "A":
+			t.A = bound
+		case "B":
+			t.B = bound
+		case "C":
+			t.C = bound
+		case "D":
+			t.D = bound
+		default:
+			return t, fmt.Errorf("invalid grade_thresholds key %q (want A, B, C, or D)", grade)
+		}
+	}
+	if err := t.Validate(); err != nil {
+		return t, fmt.Errorf("invalid grade_thresholds: %w", err)
+	}
+	return t, nil
 }
 
 // saveScoreResult persists a score result to the score cache directory.
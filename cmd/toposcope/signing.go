@@ -0,0 +1,87 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/toposcope/toposcope/pkg/client"
+	"github.com/toposcope/toposcope/pkg/graph"
+	"github.com/toposcope/toposcope/pkg/scoring"
+)
+
+// scoreSigner signs the scores a runner uploads, so the platform can tell
+// they weren't altered on the way and record where they were computed.
+type scoreSigner struct {
+	key      ed25519.PrivateKey
+	runnerID string
+}
+
+// signerFromEnv returns the signer configured by TOPOSCOPE_SIGNING_KEY (a
+// PEM ed25519 private key) or TOPOSCOPE_SIGNING_KEY_FILE, or nil if neither
+// is set. The runner ID is TOPOSCOPE_RUNNER_ID, or else the CI provider's
+// runner or agent name, or the host name.
+func signerFromEnv(getenv func(string) string) (*scoreSigner, error) {
+	data := []byte(getenv("TOPOSCOPE_SIGNING_KEY"))
+	if path := getenv("TOPOSCOPE_SIGNING_KEY_FILE"); len(data) == 0 && path != "" {
+		var err error
+		if data, err = os.ReadFile(path); err != nil {
+			return nil, fmt.Errorf("reading signing key: %w", err)
+		}
+	}
+	if len(data) == 0 {
+		return nil, nil
+	}
+	key, err := scoring.ParseSigningKey(data)
+	if err != nil {
+		return nil, err
+	}
+	host, _ := os.Hostname()
+	return &scoreSigner{
+		key: key,
+		runnerID: firstNonEmpty(getenv("TOPOSCOPE_RUNNER_ID"),
+			getenv("RUNNER_NAME"),          // GitHub Actions
+			getenv("CI_RUNNER_ID"),         // GitLab
+			getenv("BUILDKITE_AGENT_NAME"), // Buildkite
+			getenv("AGENT_NAME"),           // Azure Pipelines
+			host),
+	}, nil
+}
+
+// sign replaces the score in req with a signed one, bound to the snapshots
+// and PR it is uploaded with. Requests without a score are left alone.
+func (s *scoreSigner) sign(req *client.IngestRequest) error {
+	if s == nil || req.Score == nil {
+		return nil
+	}
+	st := &scoring.ScoreStatement{
+		Repo:       req.RepoFullName,
+		CommitSHA:  req.CommitSHA,
+		SnapshotID: req.SnapshotID,
+		PRNumber:   req.PRNumber,
+		Score:      req.Score,
+	}
+	if req.Snapshot != nil {
+		st.SnapshotID = graph.ContentID(req.Snapshot)
+	}
+	if req.BaseSnapshot != nil {
+		st.BaseCommitSHA = req.BaseSnapshot.CommitSHA
+	}
+	signed, err := s.signStatement(st)
+	if err != nil {
+		return err
+	}
+	req.Score, req.SignedScore = nil, signed
+	return nil
+}
+
+// signStatement records the runner's provenance in st and signs it.
+func (s *scoreSigner) signStatement(st *scoring.ScoreStatement) (*scoring.SignedScore, error) {
+	st.Provenance = scoring.Provenance{
+		RunnerID:   s.runnerID,
+		CLIVersion: version,
+		SignedAt:   time.Now().UTC().Format(time.RFC3339),
+	}
+	return scoring.SignScore(s.key, st)
+}
@@ -18,47 +18,69 @@ import (
 
 func newSnapshotCmd() *cobra.Command {
 	var (
-		repoPath  string
-		scope     string
-		output    string
-		bazelPath string
-		bazelRC   string
-		useCQuery bool
+		repoPath        string
+		scope           string
+		output          string
+		bazelPath       string
+		bazelRC         string
+		useCQuery       bool
+		includeExternal bool
+		profileDir      string
+		compress        bool
 	)
 
 	cmd := &cobra.Command{
 		Use:   "snapshot",
 		Short: "Extract a graph snapshot from a Bazel workspace",
-		Long:  `Runs bazel query to extract the build dependency graph and saves a snapshot.`,
+		Long: `Runs bazel query to extract the build dependency graph and saves a snapshot.
+
+With --output -, the snapshot is written to stdout instead, and the cache is
+left alone, so it can be piped into diff or score:
+
+  toposcope snapshot --output - --gzip | toposcope score --base main --head-snapshot -`,
 		RunE: func(cmd *cobra.Command, args []string) error {
+			if compress && output == "" {
+				return fmt.Errorf("--gzip needs --output; cached snapshots are plain JSON")
+			}
 			return runSnapshot(cmd.Context(), snapshotOpts{
-				repoPath:  repoPath,
-				scope:     scope,
-				output:    output,
-				bazelPath: bazelPath,
-				bazelRC:   bazelRC,
-				useCQuery: useCQuery,
+				repoPath:        repoPath,
+				scope:           scope,
+				output:          output,
+				bazelPath:       bazelPath,
+				bazelRC:         bazelRC,
+				useCQuery:       useCQuery,
+				includeExternal: includeExternal,
+				profileDir:      profileDir,
+				compress:        compress,
 			})
 		},
 	}
 
 	cmd.Flags().StringVar(&repoPath, "repo-path", "", "Path to repository root (default: detect workspace)")
 	cmd.Flags().StringVar(&scope, "scope", "FULL", "Extraction scope: FULL or SCOPED")
-	cmd.Flags().StringVar(&output, "output", "", "Output path (default: ~/.cache/toposcope/<repo>/snapshots/<sha>.json)")
+	cmd.Flags().StringVar(&output, "output", "", "Output path, or - for stdout (default: <user cache dir>/toposcope/<repo>/snapshots/<sha>.json)")
 	cmd.Flags().StringVar(&bazelPath, "bazel-path", "", "Path to bazel/bazelisk binary")
 	cmd.Flags().StringVar(&bazelRC, "bazelrc", "", "Path to .bazelrc file")
 	cmd.Flags().BoolVar(&useCQuery, "cquery", false, "Use cquery instead of query")
+	cmd.Flags().BoolVar(&includeExternal, "include-external", false, "Retain external dependencies as one node per external repo")
+	cmd.Flags().StringVar(&profileDir, "profile", "", "Directory to keep bazel query profiles in, with phase timings added to the snapshot")
+	cmd.Flags().BoolVar(&compress, "gzip", false, "Gzip the snapshot written to --output")
 
 	return cmd
 }
 
 type snapshotOpts struct {
-	repoPath  string
-	scope     string
-	output    string
-	bazelPath string
-	bazelRC   string
-	useCQuery bool
+	repoPath        string
+	scope           string
+	output          string
+	bazelPath       string
+	bazelRC         string
+	useCQuery       bool
+	includeExternal bool
+	profileDir      string
+	// compress gzips the snapshot. It needs output, as the cache holds
+	// plain JSON.
+	compress bool
 }
 
 func runSnapshot(ctx context.Context, opts snapshotOpts) error {
@@ -70,8 +92,8 @@ func runSnapshot(ctx context.Context, opts snapshotOpts) error {
 
 	// Load config
 	cfg := loadConfig(wsRoot)
-	bazelPath := firstNonEmpty(opts.bazelPath, cfg.Extraction.BazelPath, "bazelisk")
-	bazelRC := firstNonEmpty(opts.bazelRC, cfg.Extraction.BazelRC)
+	bazelPath := bazelBinary(opts.bazelPath, cfg)
+	bazelRC := bazelRCPath(wsRoot, opts.bazelRC, cfg)
 
 	// Get current commit SHA
 	commitSHA, err := gitRevParse(ctx, wsRoot, "HEAD")
@@ -80,10 +102,20 @@ func runSnapshot(ctx context.Context, opts snapshotOpts) error {
 	}
 
 	ext := &subgraph.Extractor{
-		WorkspacePath: wsRoot,
-		BazelPath:     bazelPath,
-		BazelRC:       bazelRC,
-		UseCQuery:     opts.useCQuery || cfg.Extraction.UseCQuery,
+		WorkspacePath:    wsRoot,
+		BazelPath:        bazelPath,
+		BazelRC:          bazelRC,
+		UseCQuery:        opts.useCQuery || cfg.Extraction.UseCQuery,
+		EdgeAttributes:   extract.EdgeAttributes(cfg.Extraction.EdgeAttributes),
+		IncludeExternal:  opts.includeExternal || cfg.Extraction.IncludeExternal,
+		RepoMapping:      cfg.Extraction.RepoMapping,
+		Generated:        generatedPatterns(cfg),
+		LabelRewrites:    labelRewrites(cfg),
+		Limits:           extractionLimits(cfg),
+		OutputBase:       cfg.Extraction.OutputBaseDir(wsRoot, ""),
+		KeepServer:       cfg.Extraction.KeepServer,
+		ShardParallelism: cfg.Extraction.ShardParallelism,
+		ProfileDir:       firstNonEmpty(opts.profileDir, cfg.Extraction.ProfileDir),
 	}
 
 	scopeMode := extract.ScopeModeFull
@@ -115,19 +147,107 @@ func runSnapshot(ctx context.Context, opts snapshotOpts) error {
 		outPath = filepath.Join(config.SnapshotDir(wsRoot), commitSHA+".json")
 	}
 
-	if err := graph.SaveSnapshot(outPath, snap); err != nil {
+	if err := writeSnapshotOutput(outPath, snap, opts.compress); err != nil {
 		return fmt.Errorf("saving snapshot: %w", err)
 	}
 
-	fmt.Fprintf(os.Stderr, "Snapshot saved to %s\n", outPath)
+	// Share full snapshots with other CI workers; scoped ones aren't reusable.
+	if scopeMode == extract.ScopeModeFull {
+		openRemoteCache(ctx, wsRoot, cfg, ext).uploadSnapshot(ctx, commitSHA, snap)
+	}
+
+	if outPath == "-" {
+		fmt.Fprintf(os.Stderr, "Snapshot written to stdout\n")
+	} else {
+		fmt.Fprintf(os.Stderr, "Snapshot saved to %s\n", outPath)
+	}
 	fmt.Fprintf(os.Stderr, "  Nodes:    %d\n", snap.Stats.NodeCount)
 	fmt.Fprintf(os.Stderr, "  Edges:    %d\n", snap.Stats.EdgeCount)
 	fmt.Fprintf(os.Stderr, "  Packages: %d\n", snap.Stats.PackageCount)
 	fmt.Fprintf(os.Stderr, "  Duration: %dms\n", snap.Stats.ExtractionMs)
+	for _, p := range snap.Stats.Phases {
+		fmt.Fprintf(os.Stderr, "    %-34s %dms\n", p.Name, p.Ms)
+	}
+	warnSkippedPackages(snap)
+
+	return nil
+}
+
+// writeSnapshotOutput writes snap to path, or to stdout when path is "-".
+func writeSnapshotOutput(path string, snap *graph.Snapshot, compress bool) error {
+	if path == "-" {
+		return graph.WriteSnapshot(os.Stdout, snap, compress)
+	}
+	if !compress {
+		return graph.SaveSnapshot(path, snap)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("creating directory for snapshot: %w", err)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := graph.WriteSnapshot(f, snap, true); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// readSnapshotInput reads the snapshot a flag names: a file, or "-" for
+// stdin. Either may be gzip-compressed.
+func readSnapshotInput(flag, path string) (*graph.Snapshot, error) {
+	var snap *graph.Snapshot
+	var err error
+	if path == "-" {
+		snap, err = graph.ReadSnapshot(os.Stdin)
+	} else {
+		snap, err = graph.LoadSnapshot(path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("--%s: %w", flag, err)
+	}
+	if snap.CommitSHA == "" {
+		return nil, fmt.Errorf("--%s: snapshot has no commit_sha", flag)
+	}
+	return snap, nil
+}
 
+// checkSnapshotInputs checks the --base-snapshot and --head-snapshot flags
+// of diff and score against the git ref flags they replace.
+func checkSnapshotInputs(cmd *cobra.Command, baseRef, baseSnapshot, headSnapshot string) error {
+	if baseSnapshot != "" && baseRef != "" {
+		return fmt.Errorf("--base and --base-snapshot are mutually exclusive")
+	}
+	if headSnapshot != "" && cmd.Flags().Changed("head") {
+		return fmt.Errorf("--head and --head-snapshot are mutually exclusive")
+	}
+	if baseSnapshot == "-" && headSnapshot == "-" {
+		return fmt.Errorf("only one of --base-snapshot and --head-snapshot can read stdin")
+	}
 	return nil
 }
 
+// warnSkippedPackages tells the user when bazel failed to load some packages,
+// since the resulting graph (and anything scored from it) is incomplete.
+func warnSkippedPackages(snap *graph.Snapshot) {
+	skipped := snap.Stats.SkippedPackages
+	if len(skipped) == 0 {
+		return
+	}
+	fmt.Fprintf(os.Stderr, "Warning: %d package(s) failed to load; the graph for %s is incomplete:\n",
+		len(skipped), snap.CommitSHA[:minInt(7, len(snap.CommitSHA))])
+	const maxListed = 10
+	for i, pkg := range skipped {
+		if i == maxListed {
+			fmt.Fprintf(os.Stderr, "  ... and %d more\n", len(skipped)-maxListed)
+			break
+		}
+		fmt.Fprintf(os.Stderr, "  %s\n", pkg)
+	}
+}
+
 func resolveWorkspace(repoPath string) (string, error) {
 	if repoPath != "" {
 		abs, err := filepath.Abs(repoPath)
@@ -166,18 +286,49 @@ func gitRevParse(ctx context.Context, dir, ref string) (string, error) {
 }
 
 func loadConfig(wsRoot string) *config.Config {
-	cfgFile := config.FindConfigFile(wsRoot)
-	if cfgFile == "" {
-		return config.DefaultConfig()
-	}
-	cfg, err := config.Load(cfgFile)
+	cfg, err := config.Resolve(config.FindConfigFile(wsRoot), configOverrides)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Warning: failed to load config: %v\n", err)
-		return config.DefaultConfig()
+		// The overrides were checked against the defaults before the command ran.
+		cfg = config.DefaultConfig()
+		_ = config.Apply(cfg, configOverrides)
 	}
 	return cfg
 }
 
+// bazelBinary returns the bazel to run: the flag, else the configured one.
+// The default bazelisk falls back to bazel when only bazel is on PATH, as
+// with Windows installs that ship bazelisk as bazel.exe.
+func bazelBinary(flag string, cfg *config.Config) string {
+	bazel := firstNonEmpty(flag, cfg.Extraction.BazelPath, "bazelisk")
+	if bazel == "bazelisk" {
+		if _, err := exec.LookPath(bazel); err != nil {
+			if _, err := exec.LookPath("bazel"); err == nil {
+				return "bazel"
+			}
+		}
+	}
+	return bazel
+}
+
+// bazelRCPath returns the absolute path of the bazelrc to use, if any. The
+// flag is relative to the working directory and the configured one to the
+// workspace, while bazel resolves --bazelrc against the directory it runs
+// in, which differs for worktrees.
+func bazelRCPath(wsRoot, flag string, cfg *config.Config) string {
+	if flag != "" {
+		if abs, err := filepath.Abs(flag); err == nil {
+			return abs
+		}
+		return flag
+	}
+	rc := cfg.Extraction.BazelRC
+	if rc != "" && !filepath.IsAbs(rc) {
+		rc = filepath.Join(wsRoot, filepath.FromSlash(rc))
+	}
+	return rc
+}
+
 func firstNonEmpty(vals ...string) string {
 	for _, v := range vals {
 		if v != "" {
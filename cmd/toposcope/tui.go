@@ -0,0 +1,328 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"os/exec"
+	"runtime"
+	"sort"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/toposcope/toposcope/pkg/graph"
+	"github.com/toposcope/toposcope/pkg/scoring"
+)
+
+// maxTreeChildren caps the children listed per node of an ego tree.
+const maxTreeChildren = 25
+
+var (
+	tuiBold     = lipgloss.NewStyle().Bold(true)
+	tuiDim      = lipgloss.NewStyle().Faint(true)
+	tuiSelected = lipgloss.NewStyle().Reverse(true)
+	tuiSeverity = map[scoring.Severity]lipgloss.Style{
+		scoring.SeverityHigh:   lipgloss.NewStyle().Foreground(lipgloss.Color("1")),
+		scoring.SeverityMedium: lipgloss.NewStyle().Foreground(lipgloss.Color("3")),
+		scoring.SeverityLow:    lipgloss.NewStyle().Foreground(lipgloss.Color("6")),
+	}
+)
+
+// tuiRow is one selectable line of the results view.
+type tuiRow struct {
+	metric   int    // index into Breakdown, or -1
+	evidence int    // index into the metric's Evidence, or -1
+	hotspot  int    // index into Hotspots, or -1
+	node     string // node the row drills into and opens, if any
+}
+
+// scoreModel is the Bubble Tea model of `toposcope score --interactive`.
+type scoreModel struct {
+	result *scoring.ScoreResult
+	head   *graph.Snapshot
+	index  *graph.Index
+	webURL string // node links are webURL + /repos/local/graph?target=<node>
+
+	expanded map[int]bool // metrics whose evidence is shown
+	rows     []tuiRow
+	cursor   int
+	offset   int // first row shown
+	height   int
+
+	ego      string // node of the ego view; "" for the results view
+	egoDepth int
+	status   string
+}
+
+func newScoreModel(result *scoring.ScoreResult, head *graph.Snapshot, webURL string) *scoreModel {
+	m := &scoreModel{
+		result:   result,
+		head:     head,
+		index:    graph.NewIndex(head),
+		webURL:   strings.TrimRight(webURL, "/"),
+		expanded: make(map[int]bool),
+		height:   24,
+		egoDepth: 2,
+	}
+	m.buildRows()
+	return m
+}
+
+// buildRows lays out the breakdown, with the evidence of expanded metrics,
+// followed by the hotspots.
+func (m *scoreModel) buildRows() {
+	m.rows = m.rows[:0]
+	for i, mr := range m.result.Breakdown {
+		m.rows = append(m.rows, tuiRow{metric: i, evidence: -1, hotspot: -1})
+		if !m.expanded[i] {
+			continue
+		}
+		for j, ev := range mr.Evidence {
+			m.rows = append(m.rows, tuiRow{metric: i, evidence: j, hotspot: -1, node: ev.From})
+		}
+	}
+	for i, h := range m.result.Hotspots {
+		m.rows = append(m.rows, tuiRow{metric: -1, evidence: -1, hotspot: i, node: h.NodeKey})
+	}
+	m.cursor = max(0, min(m.cursor, len(m.rows)-1))
+}
+
+func (m *scoreModel) Init() tea.Cmd { return nil }
+
+type openedMsg struct{ err error }
+
+func (m *scoreModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.height = msg.Height
+	case openedMsg:
+		if msg.err != nil {
+			m.status = "open: " + msg.err.Error()
+		}
+	case tea.KeyMsg:
+		m.status = ""
+		if m.ego != "" {
+			return m, m.updateEgo(msg)
+		}
+		return m, m.updateResults(msg)
+	}
+	return m, nil
+}
+
+func (m *scoreModel) updateResults(msg tea.KeyMsg) tea.Cmd {
+	switch msg.String() {
+	case "q", "ctrl+c", "esc":
+		return tea.Quit
+	case "up", "k":
+		m.move(-1)
+	case "down", "j":
+		m.move(1)
+	case "pgup":
+		m.move(-m.pageSize())
+	case "pgdown":
+		m.move(m.pageSize())
+	case "enter", " ", "right", "l":
+		if len(m.rows) == 0 {
+			return nil
+		}
+		row := m.rows[m.cursor]
+		if row.metric >= 0 && row.evidence < 0 {
+			m.expanded[row.metric] = !m.expanded[row.metric]
+			m.buildRows()
+		} else if row.node != "" {
+			m.ego = row.node
+		}
+	case "left", "h":
+		if len(m.rows) > 0 && m.rows[m.cursor].metric >= 0 {
+			metric := m.rows[m.cursor].metric
+			m.expanded[metric] = false
+			m.buildRows()
+			for i, r := range m.rows {
+				if r.metric == metric {
+					m.cursor = i
+					break
+				}
+			}
+		}
+	case "o":
+		if len(m.rows) > 0 {
+			return m.open(m.rows[m.cursor].node)
+		}
+	}
+	return nil
+}
+
+func (m *scoreModel) updateEgo(msg tea.KeyMsg) tea.Cmd {
+	switch msg.String() {
+	case "q", "ctrl+c":
+		return tea.Quit
+	case "esc", "backspace", "left", "h":
+		m.ego = ""
+	case "+", "=":
+		m.egoDepth = min(m.egoDepth+1, 5)
+	case "-":
+		m.egoDepth = max(m.egoDepth-1, 1)
+	case "o":
+		return m.open(m.ego)
+	}
+	return nil
+}
+
+func (m *scoreModel) move(n int) {
+	m.cursor = max(0, min(m.cursor+n, len(m.rows)-1))
+	page := m.pageSize()
+	if m.cursor < m.offset {
+		m.offset = m.cursor
+	} else if m.cursor >= m.offset+page {
+		m.offset = m.cursor - page + 1
+	}
+}
+
+// pageSize is how many rows fit below the header and above the help and
+// status lines.
+func (m *scoreModel) pageSize() int { return max(m.height-7, 1) }
+
+// nodeURL returns the web UI page that explores node.
+func (m *scoreModel) nodeURL(node string) string {
+	return m.webURL + "/repos/local/graph?" + url.Values{"target": {node}}.Encode()
+}
+
+func (m *scoreModel) open(node string) tea.Cmd {
+	if node == "" {
+		m.status = "nothing to open on this row"
+		return nil
+	}
+	link := m.nodeURL(node)
+	m.status = "opening " + link
+	return func() tea.Msg { return openedMsg{err: openBrowser(link)} }
+}
+
+// openBrowser opens link with the platform's URL handler.
+func openBrowser(link string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("open", link)
+	case "windows":
+		cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", link)
+	default:
+		cmd = exec.Command("xdg-open", link)
+	}
+	return cmd.Start()
+}
+
+func (m *scoreModel) View() string {
+	var b strings.Builder
+	r := m.result
+	fmt.Fprintf(&b, "%s   %s\n", tuiBold.Render(fmt.Sprintf("Grade %s — Score %.1f", r.Grade, r.TotalScore)),
+		tuiDim.Render(fmt.Sprintf("%s..%s  +%d/-%d nodes  +%d/-%d edges",
+			shortSHA(r.BaseCommit), shortSHA(r.HeadCommit),
+			r.DeltaStats.AddedNodes, r.DeltaStats.RemovedNodes, r.DeltaStats.AddedEdges, r.DeltaStats.RemovedEdges)))
+	b.WriteString("\n")
+
+	if m.ego != "" {
+		tree := strings.Split(strings.TrimSuffix(egoTree(m.head, m.index, m.ego, m.egoDepth), "\n"), "\n")
+		if page := m.pageSize(); len(tree) > page {
+			tree = append(tree[:page-1], tuiDim.Render(fmt.Sprintf("… %d more lines; lower the depth with -", len(tree)-page+1)))
+		}
+		b.WriteString(strings.Join(tree, "\n") + "\n")
+		b.WriteString("\n" + tuiDim.Render(fmt.Sprintf("depth %d  +/- depth  o open in web UI  esc back  q quit", m.egoDepth)))
+	} else {
+		page := m.pageSize()
+		for i := m.offset; i < len(m.rows) && i < m.offset+page; i++ {
+			if m.rows[i].hotspot == 0 {
+				b.WriteString(tuiBold.Render("Hotspots") + "\n")
+			}
+			line := m.rowText(i)
+			if i == m.cursor {
+				line = tuiSelected.Render(line)
+			}
+			b.WriteString(line + "\n")
+		}
+		if len(m.rows) == 0 {
+			b.WriteString(tuiDim.Render("No findings.") + "\n")
+		}
+		b.WriteString("\n" + tuiDim.Render("↑/↓ move  enter expand/drill in  ← collapse  o open in web UI  q quit"))
+	}
+	if m.status != "" {
+		b.WriteString("\n" + m.status)
+	}
+	return b.String()
+}
+
+func (m *scoreModel) rowText(i int) string {
+	row := m.rows[i]
+	switch {
+	case row.hotspot >= 0:
+		h := m.result.Hotspots[row.hotspot]
+		return fmt.Sprintf("  %-50s %+6.1f  %s", h.NodeKey, h.ScoreContribution, tuiDim.Render(h.Reason))
+	case row.evidence >= 0:
+		ev := m.result.Breakdown[row.metric].Evidence[row.evidence]
+		return "      " + ev.Summary
+	default:
+		mr := m.result.Breakdown[row.metric]
+		marker := "▸"
+		if m.expanded[row.metric] {
+			marker = "▾"
+		}
+		sev := tuiSeverity[mr.Severity].Render(fmt.Sprintf("%-6s", mr.Severity))
+		return fmt.Sprintf("%s %-34s %+6.1f  %s  %s", marker, mr.Name, mr.Contribution, sev,
+			tuiDim.Render(fmt.Sprintf("%d evidence", len(mr.Evidence))))
+	}
+}
+
+func shortSHA(sha string) string { return sha[:min(7, len(sha))] }
+
+// egoTree renders the dependencies and dependents of node in snap, depth
+// levels deep, as ASCII trees. A node already shown is listed again but
+// not expanded.
+func egoTree(snap *graph.Snapshot, ix *graph.Index, node string, depth int) string {
+	var b strings.Builder
+	n := snap.Nodes[node]
+	if n == nil {
+		return node + "\n" + tuiDim.Render("  not in the head snapshot") + "\n"
+	}
+	b.WriteString(tuiBold.Render(node) + tuiDim.Render("  "+n.Kind) + "\n")
+	id, _ := ix.ID(node)
+	for _, side := range []struct {
+		title string
+		next  func(int32) []int32
+	}{{"depends on", ix.Deps}, {"depended on by", ix.RDeps}} {
+		fmt.Fprintf(&b, "\n%s (%d)\n", side.title, len(side.next(id)))
+		seen := map[int32]bool{id: true}
+		writeTree(&b, ix, side.next, id, "", depth, seen)
+	}
+	return b.String()
+}
+
+func writeTree(b *strings.Builder, ix *graph.Index, next func(int32) []int32, id int32, indent string, depth int, seen map[int32]bool) {
+	children := append([]int32(nil), next(id)...)
+	sort.Slice(children, func(i, j int) bool { return ix.Key(children[i]) < ix.Key(children[j]) })
+	shown := children[:min(len(children), maxTreeChildren)]
+	for i, c := range shown {
+		last := i == len(shown)-1 && len(children) == len(shown)
+		branch, childIndent := "├── ", indent+"│   "
+		if last {
+			branch, childIndent = "└── ", indent+"    "
+		}
+		label := ix.Key(c)
+		if seen[c] {
+			b.WriteString(indent + branch + label + tuiDim.Render(" (shown above)") + "\n")
+			continue
+		}
+		seen[c] = true
+		b.WriteString(indent + branch + label + "\n")
+		if depth > 1 {
+			writeTree(b, ix, next, c, childIndent, depth-1, seen)
+		}
+	}
+	if len(children) > len(shown) {
+		b.WriteString(indent + "└── " + tuiDim.Render(fmt.Sprintf("… %d more", len(children)-len(shown))) + "\n")
+	}
+}
+
+// runScoreTUI shows the results of run until the user quits.
+func runScoreTUI(run *scoreRun, webURL string) error {
+	_, err := tea.NewProgram(newScoreModel(run.result, run.headSnap, webURL), tea.WithAltScreen()).Run()
+	return err
+}
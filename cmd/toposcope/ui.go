@@ -1,14 +1,15 @@
 package main
 
 import (
+	"crypto/subtle"
 	"encoding/json"
 	"fmt"
+	"net"
 	"net/http"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"sort"
-	"strconv"
 	"strings"
 
 	"github.com/spf13/cobra"
@@ -21,6 +22,8 @@ func newUICmd() *cobra.Command {
 	var (
 		repoPath string
 		port     string
+		bind     string
+		token    string
 	)
 
 	cmd := &cobra.Command{
@@ -32,19 +35,26 @@ from the local cache. Point the Next.js web UI at this server.
 Usage:
   1. Start the API server:  toposcope ui --repo-path /path/to/repo
   2. In another terminal:   cd web && NEXT_PUBLIC_API_MODE=local pnpm dev
-  3. Open http://localhost:3000`,
+  3. Open http://localhost:3000
+
+The server only listens on 127.0.0.1 by default. To share it with teammates
+or a remote UI, pass --bind 0.0.0.0 together with --token (or set
+TOPOSCOPE_UI_TOKEN). Requests must then send "Authorization: Bearer <token>";
+start the web UI with NEXT_PUBLIC_API_TOKEN set to the same value.`,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return runUI(repoPath, port)
+			return runUI(repoPath, bind, port, token)
 		},
 	}
 
 	cmd.Flags().StringVar(&repoPath, "repo-path", "", "Path to repository root (default: detect workspace)")
 	cmd.Flags().StringVar(&port, "port", "7700", "Port to serve on")
+	cmd.Flags().StringVar(&bind, "bind", "127.0.0.1", "Address to listen on")
+	cmd.Flags().StringVar(&token, "token", os.Getenv("TOPOSCOPE_UI_TOKEN"), "Shared token required on every request (default: $TOPOSCOPE_UI_TOKEN)")
 
 	return cmd
 }
 
-func runUI(repoPath, port string) error {
+func runUI(repoPath, bind, port, token string) error {
 	wsRoot, err := resolveWorkspace(repoPath)
 	if err != nil {
 		return err
@@ -67,17 +77,61 @@ func runUI(repoPath, port string) error {
 	mux.HandleFunc("/api/repos", srv.handleRepos)
 	mux.HandleFunc("/api/repos/", srv.handleRepoRoutes)
 	mux.HandleFunc("/api/snapshots/", srv.handleSnapshots)
+	mux.Handle("/api/v2/", unversioned(mux))
 
-	// CORS middleware for Next.js dev server
-	handler := corsMiddleware(mux)
+	// CORS middleware for Next.js dev server. It wraps the token check so
+	// preflight requests, which carry no credentials, still succeed.
+	handler := corsMiddleware(tokenAuth(token, mux))
 
+	addr := net.JoinHostPort(bind, port)
 	fmt.Fprintf(os.Stderr, "Toposcope API server\n")
 	fmt.Fprintf(os.Stderr, "  Repo:       %s\n", wsRoot)
 	fmt.Fprintf(os.Stderr, "  Snapshots:  %s\n", snapDir)
-	fmt.Fprintf(os.Stderr, "  Listening:  http://localhost:%s\n", port)
+	fmt.Fprintf(os.Stderr, "  Listening:  http://%s\n", addr)
+	if token != "" {
+		fmt.Fprintf(os.Stderr, "  Auth:       shared token\n")
+	} else if !isLoopback(bind) {
+		fmt.Fprintf(os.Stderr, "\nWarning: listening on %s without --token; anyone who can reach this address can read your snapshots.\n", bind)
+	}
 	fmt.Fprintf(os.Stderr, "\nStart the web UI:  cd web && NEXT_PUBLIC_API_MODE=local pnpm dev\n")
 
-	return http.ListenAndServe(":"+port, handler)
+	return http.ListenAndServe(addr, handler)
+}
+
+// unversioned serves /api/v2/... requests with the /api/... routes. The web
+// UI calls the platform's v2 paths, and the local API is the same at both.
+func unversioned(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r2 := r.Clone(r.Context())
+		r2.URL.Path = "/api" + strings.TrimPrefix(r.URL.Path, "/api/v2")
+		r2.URL.RawPath = ""
+		next.ServeHTTP(w, r2)
+	})
+}
+
+// isLoopback reports whether bind only accepts local connections.
+func isLoopback(bind string) bool {
+	if bind == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(bind)
+	return ip != nil && ip.IsLoopback()
+}
+
+// tokenAuth returns next unchanged when token is empty. Otherwise every
+// request must present it as a bearer token.
+func tokenAuth(token string, next http.Handler) http.Handler {
+	if token == "" {
+		return next
+	}
+	want := []byte("Bearer " + token)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), want) != 1 {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
 }
 
 type localAPIServer struct {
@@ -306,12 +360,30 @@ func (s *localAPIServer) handleSnapshots(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	// /api/snapshots/{id}/sample?max_nodes=...&seed=...
+	if len(parts) >= 2 && parts[1] == "sample" {
+		s.handleSample(w, r, snapshotID)
+		return
+	}
+
 	// /api/snapshots/{id}/packages?hide_tests=true&hide_external=true&min_edge_weight=1
 	if len(parts) >= 2 && parts[1] == "packages" {
 		s.handlePackages(w, r, snapshotID)
 		return
 	}
 
+	// /api/snapshots/{id}/communities?hide_tests=true&min_edge_weight=1
+	if len(parts) >= 2 && parts[1] == "communities" {
+		s.handleCommunities(w, r, snapshotID)
+		return
+	}
+
+	// /api/snapshots/{id}/search?q=...&language=go&limit=50
+	if len(parts) >= 2 && parts[1] == "search" {
+		s.handleSearch(w, r, snapshotID)
+		return
+	}
+
 	// /api/snapshots/{id}/ego?target=...&depth=...&direction=...
 	if len(parts) >= 2 && parts[1] == "ego" {
 		s.handleEgo(w, r, snapshotID)
@@ -324,6 +396,18 @@ func (s *localAPIServer) handleSnapshots(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	// /api/snapshots/{id}/explain?from=...&to=...&max_cut=50
+	if len(parts) >= 2 && parts[1] == "explain" {
+		s.handleExplain(w, r, snapshotID)
+		return
+	}
+
+	// /api/snapshots/{id}/package-path?from=...&to=...&max_paths=10
+	if len(parts) >= 2 && parts[1] == "package-path" {
+		s.handlePackagePath(w, r, snapshotID)
+		return
+	}
+
 	// /api/snapshots/{id} — return full snapshot
 	s.handleGetSnapshot(w, r, snapshotID)
 }
@@ -341,6 +425,7 @@ func (s *localAPIServer) listSnapshots(w http.ResponseWriter, r *http.Request) {
 		Nodes     int    `json:"node_count"`
 		Edges     int    `json:"edge_count"`
 		Packages  int    `json:"package_count"`
+		Skipped   int    `json:"skipped_package_count,omitempty"`
 	}
 
 	var snaps []snapInfo
@@ -354,11 +439,12 @@ func (s *localAPIServer) listSnapshots(w http.ResponseWriter, r *http.Request) {
 			continue
 		}
 		snaps = append(snaps, snapInfo{
-			ID:        snap.ID,
+			ID:        sha, // files are named by commit, which findSnapshot looks up
 			CommitSHA: sha,
 			Nodes:     snap.Stats.NodeCount,
 			Edges:     snap.Stats.EdgeCount,
 			Packages:  snap.Stats.PackageCount,
+			Skipped:   len(snap.Stats.SkippedPackages),
 		})
 	}
 
@@ -381,23 +467,23 @@ func (s *localAPIServer) handleSubgraph(w http.ResponseWriter, r *http.Request,
 		return
 	}
 
-	roots := r.URL.Query()["root"]
-	depthStr := r.URL.Query().Get("depth")
-	depth := 2
-	if depthStr != "" {
-		_, _ = fmt.Sscanf(depthStr, "%d", &depth)
+	params, err := graphquery.ParseSubgraphParams(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
 	}
 
-	// If no roots specified, return the full graph (capped at 500 nodes for UI performance)
-	if len(roots) == 0 {
-		result := graphquery.CapGraph(snap, 500)
-		writeJSON(w, result)
+	writeJSON(w, graphquery.Subgraph(snap, params))
+}
+
+func (s *localAPIServer) handleSample(w http.ResponseWriter, r *http.Request, snapshotID string) {
+	snap := s.findSnapshot(snapshotID)
+	if snap == nil {
+		http.NotFound(w, r)
 		return
 	}
 
-	// BFS from roots to given depth
-	result := graphquery.ExtractSubgraph(snap, roots, depth)
-	writeJSON(w, result)
+	writeJSON(w, graphquery.Sample(snap, graphquery.ParseSampleParams(r.URL.Query())))
 }
 
 func (s *localAPIServer) handlePackages(w http.ResponseWriter, r *http.Request, snapshotID string) {
@@ -407,17 +493,27 @@ func (s *localAPIServer) handlePackages(w http.ResponseWriter, r *http.Request,
 		return
 	}
 
-	hideTests := r.URL.Query().Get("hide_tests") == "true"
-	hideExternal := r.URL.Query().Get("hide_external") == "true"
-	minEdgeWeight := 1
-	if v := r.URL.Query().Get("min_edge_weight"); v != "" {
-		if parsed, err := strconv.Atoi(v); err == nil && parsed > 0 {
-			minEdgeWeight = parsed
-		}
+	writeJSON(w, graphquery.Packages(snap, graphquery.ParsePackageParams(r.URL.Query())))
+}
+
+func (s *localAPIServer) handleCommunities(w http.ResponseWriter, r *http.Request, snapshotID string) {
+	snap := s.findSnapshot(snapshotID)
+	if snap == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	writeJSON(w, graphquery.Communities(snap, graphquery.ParsePackageParams(r.URL.Query())))
+}
+
+func (s *localAPIServer) handleSearch(w http.ResponseWriter, r *http.Request, snapshotID string) {
+	snap := s.findSnapshot(snapshotID)
+	if snap == nil {
+		http.NotFound(w, r)
+		return
 	}
 
-	result := graphquery.AggregatePackages(snap, hideTests, hideExternal, minEdgeWeight, 0)
-	writeJSON(w, result)
+	writeJSON(w, graphquery.Search(snap, graphquery.ParseSearchParams(r.URL.Query())))
 }
 
 func (s *localAPIServer) handleEgo(w http.ResponseWriter, r *http.Request, snapshotID string) {
@@ -427,54 +523,66 @@ func (s *localAPIServer) handleEgo(w http.ResponseWriter, r *http.Request, snaps
 		return
 	}
 
-	target := r.URL.Query().Get("target")
-	if target == "" {
-		http.Error(w, "target parameter required", http.StatusBadRequest)
+	params, err := graphquery.ParseEgoParams(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	depth := 2
-	if v := r.URL.Query().Get("depth"); v != "" {
-		if parsed, err := strconv.Atoi(v); err == nil && parsed > 0 {
-			depth = parsed
-		}
+	writeJSON(w, graphquery.Ego(snap, params))
+}
+
+func (s *localAPIServer) handleExplain(w http.ResponseWriter, r *http.Request, snapshotID string) {
+	snap := s.findSnapshot(snapshotID)
+	if snap == nil {
+		http.NotFound(w, r)
+		return
 	}
 
-	direction := r.URL.Query().Get("direction")
-	if direction == "" {
-		direction = "both"
+	params, err := graphquery.ParseExplainParams(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
 	}
 
-	result := graphquery.EgoGraph(snap, target, depth, direction, 0)
-	writeJSON(w, result)
+	writeJSON(w, graphquery.Explanation(snap, params))
 }
 
-func (s *localAPIServer) handlePath(w http.ResponseWriter, r *http.Request, snapshotID string) {
+func (s *localAPIServer) handlePackagePath(w http.ResponseWriter, r *http.Request, snapshotID string) {
 	snap := s.findSnapshot(snapshotID)
 	if snap == nil {
 		http.NotFound(w, r)
 		return
 	}
 
-	fromQ := r.URL.Query().Get("from")
-	toQ := r.URL.Query().Get("to")
-	if fromQ == "" || toQ == "" {
-		http.Error(w, "from and to parameters required", http.StatusBadRequest)
+	params, err := graphquery.ParsePackagePathParams(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	maxPaths := 10
-	if v := r.URL.Query().Get("max_paths"); v != "" {
-		if parsed, err := strconv.Atoi(v); err == nil && parsed > 0 {
-			maxPaths = parsed
-		}
+	writeJSON(w, graphquery.PackagePaths(snap, params))
+}
+
+func (s *localAPIServer) handlePath(w http.ResponseWriter, r *http.Request, snapshotID string) {
+	snap := s.findSnapshot(snapshotID)
+	if snap == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	params, err := graphquery.ParsePathParams(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
 	}
 
-	result := graphquery.FindPaths(snap, fromQ, toQ, maxPaths)
-	writeJSON(w, result)
+	writeJSON(w, graphquery.Paths(snap, params))
 }
 
-// findSnapshot looks up a snapshot by ID or commit SHA prefix.
+// findSnapshot looks up a snapshot by commit SHA or SHA prefix. Local
+// snapshots are stored as <sha>.json and listed under that ID, so no lookup
+// has to open every file.
 func (s *localAPIServer) findSnapshot(id string) *graph.Snapshot {
 	// Try exact SHA match first
 	path := filepath.Join(s.snapDir, id+".json")
@@ -533,7 +641,7 @@ func corsMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
 		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
 
 		if r.Method == "OPTIONS" {
 			w.WriteHeader(http.StatusOK)
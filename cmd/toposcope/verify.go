@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/toposcope/toposcope/pkg/config"
+	"github.com/toposcope/toposcope/pkg/graph"
+)
+
+func newVerifyCmd() *cobra.Command {
+	var (
+		repoPath string
+		remove   bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "verify",
+		Short: "Check cached snapshots for corruption",
+		Long: `Loads every cached snapshot for the workspace and checks that it parses, that
+its stats match its nodes and edges, and that its ID still matches its content.
+A corrupted snapshot would otherwise surface as a confusing diff. With --delete,
+corrupted snapshots are removed so the next run extracts them again.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			wsRoot, err := resolveWorkspace(repoPath)
+			if err != nil {
+				return err
+			}
+			return runVerify(config.SnapshotDir(wsRoot), remove)
+		},
+	}
+
+	cmd.Flags().StringVar(&repoPath, "repo-path", "", "Path to repository root (default: detect workspace)")
+	cmd.Flags().BoolVar(&remove, "delete", false, "Remove corrupted snapshots from the cache")
+
+	return cmd
+}
+
+func runVerify(dir string, remove bool) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			fmt.Fprintf(os.Stderr, "No cached snapshots in %s\n", dir)
+			return nil
+		}
+		return fmt.Errorf("reading snapshot cache: %w", err)
+	}
+
+	var checked, corrupt int
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".json") {
+			continue
+		}
+		checked++
+		path := filepath.Join(dir, e.Name())
+
+		var problems []string
+		snap, err := graph.LoadSnapshot(path)
+		if err != nil {
+			problems = []string{err.Error()}
+		} else {
+			problems = graph.Verify(snap)
+		}
+		if len(problems) == 0 {
+			continue
+		}
+
+		corrupt++
+		fmt.Fprintf(os.Stderr, "  %s: %s\n", e.Name(), strings.Join(problems, "; "))
+		if remove {
+			if err := os.Remove(path); err != nil {
+				return fmt.Errorf("removing %s: %w", path, err)
+			}
+		}
+	}
+
+	fmt.Fprintf(os.Stderr, "Checked %d snapshots, %d corrupted\n", checked, corrupt)
+	if corrupt > 0 && !remove {
+		return fmt.Errorf("%d corrupted snapshots in %s; rerun with --delete to remove them", corrupt, dir)
+	}
+	return nil
+}
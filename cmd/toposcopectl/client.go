@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// client calls the toposcoped API with operator credentials.
+type client struct {
+	baseURL string
+	apiKey  string
+	output  string
+	http    *http.Client
+}
+
+// do sends a request with an optional JSON body and decodes a JSON response
+// into out, if non-nil.
+func (c *client) do(ctx context.Context, method, path string, body, out any) error {
+	if c.baseURL == "" {
+		return fmt.Errorf("--url or TOPOSCOPE_URL is required")
+	}
+	if c.output != "text" && c.output != "json" {
+		return fmt.Errorf("unknown output format %q (want text or json)", c.output)
+	}
+
+	var reqBody io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reqBody = bytes.NewReader(data)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Minute)
+	defer cancel()
+	endpoint := strings.TrimRight(c.baseURL, "/") + path
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, reqBody)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.apiKey != "" {
+		req.Header.Set("X-API-Key", c.apiKey)
+	}
+	if tok := os.Getenv("TOPOSCOPE_ID_TOKEN"); tok != "" {
+		req.Header.Set("Authorization", "Bearer "+tok)
+	}
+
+	httpClient := c.http
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		var apiErr struct {
+			Error string `json:"error"`
+		}
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+		msg := strings.TrimSpace(string(data))
+		if json.Unmarshal(data, &apiErr) == nil && apiErr.Error != "" {
+			msg = apiErr.Error
+		}
+		return fmt.Errorf("%s %s: HTTP %d: %s", method, path, resp.StatusCode, msg)
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("%s %s: decoding response: %w", method, path, err)
+	}
+	return nil
+}
+
+// print writes v as indented JSON with --output json, and otherwise calls
+// text to render it.
+func (c *client) print(w io.Writer, v any, text func(w *tabwriter.Writer)) error {
+	if c.output == "json" {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	}
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	text(tw)
+	return tw.Flush()
+}
+
+type repo struct {
+	ID            string `json:"id"`
+	FullName      string `json:"full_name"`
+	DefaultBranch string `json:"default_branch"`
+}
+
+// resolveRepo accepts a repository ID or owner/name and returns the ID.
+func (c *client) resolveRepo(ctx context.Context, arg string) (string, error) {
+	if !strings.Contains(arg, "/") {
+		return arg, nil
+	}
+	var repos []repo
+	if err := c.do(ctx, http.MethodGet, "/api/v2/repos", nil, &repos); err != nil {
+		return "", err
+	}
+	for _, r := range repos {
+		if strings.EqualFold(r.FullName, arg) {
+			return r.ID, nil
+		}
+	}
+	return "", fmt.Errorf("repository %s not found", arg)
+}
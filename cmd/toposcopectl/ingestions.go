@@ -0,0 +1,124 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+type ingestion struct {
+	ID           string    `json:"id"`
+	TenantID     string    `json:"tenant_id"`
+	RepoID       string    `json:"repo_id"`
+	RepoFullName string    `json:"repo_full_name"`
+	CommitSHA    string    `json:"commit_sha"`
+	PRNumber     *int      `json:"pr_number,omitempty"`
+	Status       string    `json:"status"`
+	Error        *string   `json:"error,omitempty"`
+	SnapshotID   *string   `json:"snapshot_id,omitempty"`
+	DeltaID      *string   `json:"delta_id,omitempty"`
+	ScoreID      *string   `json:"score_id,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+func newIngestionsCmd(c *client) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "ingestions",
+		Short: "Inspect hosted ingestions",
+	}
+
+	var status, repoArg string
+	var limit int
+	listCmd := &cobra.Command{
+		Use:   "list",
+		Short: "List recent ingestions, failed ones by default",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			q := url.Values{"limit": {strconv.Itoa(limit)}}
+			if status != "all" {
+				q.Set("status", status)
+			}
+			if repoArg != "" {
+				repoID, err := c.resolveRepo(cmd.Context(), repoArg)
+				if err != nil {
+					return err
+				}
+				q.Set("repo_id", repoID)
+			}
+			var recs []ingestion
+			if err := c.do(cmd.Context(), http.MethodGet, "/api/v2/admin/ingestions?"+q.Encode(), nil, &recs); err != nil {
+				return err
+			}
+			return c.print(os.Stdout, recs, func(w *tabwriter.Writer) {
+				fmt.Fprintln(w, "ID\tREPOSITORY\tCOMMIT\tPR\tSTATUS\tUPDATED\tERROR")
+				for _, rec := range recs {
+					pr := "-"
+					if rec.PRNumber != nil {
+						pr = "#" + strconv.Itoa(*rec.PRNumber)
+					}
+					errMsg := ""
+					if rec.Error != nil {
+						errMsg = truncate(*rec.Error, 60)
+					}
+					fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", rec.ID, rec.RepoFullName, shortSHA(rec.CommitSHA), pr,
+						rec.Status, rec.UpdatedAt.Format(time.DateTime), errMsg)
+				}
+			})
+		},
+	}
+	listCmd.Flags().StringVar(&status, "status", "FAILED", "Status to list: QUEUED, RUNNING, COMPLETED, FAILED, or all")
+	listCmd.Flags().StringVar(&repoArg, "repo", "", "Only list this repository's ingestions (ID or owner/name)")
+	listCmd.Flags().IntVar(&limit, "limit", 50, "Maximum number of ingestions")
+	cmd.AddCommand(listCmd)
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "show <ingestion-id>",
+		Short: "Show one ingestion, including its full error",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var rec ingestion
+			if err := c.do(cmd.Context(), http.MethodGet, "/api/v2/admin/ingestions/"+url.PathEscape(args[0]), nil, &rec); err != nil {
+				return err
+			}
+			return c.print(os.Stdout, rec, func(w *tabwriter.Writer) {
+				fmt.Fprintf(w, "ID:\t%s\n", rec.ID)
+				fmt.Fprintf(w, "Repository:\t%s (%s)\n", rec.RepoFullName, rec.RepoID)
+				fmt.Fprintf(w, "Commit:\t%s\n", rec.CommitSHA)
+				if rec.PRNumber != nil {
+					fmt.Fprintf(w, "PR:\t#%d\n", *rec.PRNumber)
+				}
+				fmt.Fprintf(w, "Status:\t%s\n", rec.Status)
+				fmt.Fprintf(w, "Created:\t%s\n", rec.CreatedAt.Format(time.RFC3339))
+				fmt.Fprintf(w, "Updated:\t%s\n", rec.UpdatedAt.Format(time.RFC3339))
+				for _, f := range []struct {
+					name string
+					v    *string
+				}{{"Snapshot", rec.SnapshotID}, {"Delta", rec.DeltaID}, {"Score", rec.ScoreID}, {"Error", rec.Error}} {
+					if f.v != nil {
+						fmt.Fprintf(w, "%s:\t%s\n", f.name, *f.v)
+					}
+				}
+			})
+		},
+	})
+
+	return cmd
+}
+
+func shortSHA(sha string) string {
+	return sha[:min(7, len(sha))]
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n-3] + "..."
+}
@@ -0,0 +1,53 @@
+// Package main provides toposcopectl, the operator CLI for a toposcoped
+// deployment.
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+var version = "dev"
+
+func main() {
+	if err := newRootCmd().Execute(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func newRootCmd() *cobra.Command {
+	var c client
+
+	rootCmd := &cobra.Command{
+		Use:   "toposcopectl",
+		Short: "Operate a Toposcope platform deployment",
+		Long: `toposcopectl talks to a toposcoped server to inspect tenants, repositories,
+and ingestions, and to run maintenance: rescoring, garbage collection,
+baseline pinning, and API key rotation.
+
+It needs the service-wide API key (API_KEY on the server).`,
+		Version:       version,
+		SilenceUsage:  true,
+		SilenceErrors: true,
+	}
+
+	rootCmd.PersistentFlags().StringVar(&c.baseURL, "url", os.Getenv("TOPOSCOPE_URL"), "toposcoped URL (default: $TOPOSCOPE_URL)")
+	rootCmd.PersistentFlags().StringVar(&c.apiKey, "api-key", os.Getenv("TOPOSCOPE_API_KEY"), "Service-wide API key (default: $TOPOSCOPE_API_KEY)")
+	rootCmd.PersistentFlags().StringVarP(&c.output, "output", "o", "text", "Output format: text or json")
+
+	rootCmd.AddCommand(
+		newTenantsCmd(&c),
+		newReposCmd(&c),
+		newIngestionsCmd(&c),
+		newRescoreCmd(&c),
+		newGCCmd(&c),
+		newPurgeCmd(&c),
+		newBaselineCmd(&c),
+		newKeysCmd(&c),
+	)
+
+	return rootCmd
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+// runCtl runs toposcopectl against srv and returns its stdout.
+func runCtl(t *testing.T, srv *httptest.Server, args ...string) (string, error) {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+
+	cmd := newRootCmd()
+	cmd.SetArgs(append([]string{"--url", srv.URL, "--api-key", "secret"}, args...))
+	runErr := cmd.Execute()
+	w.Close()
+	out, _ := io.ReadAll(r)
+	return string(out), runErr
+}
+
+func TestIngestionsListDefaultsToFailed(t *testing.T) {
+	var gotQuery, gotKey string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotQuery, gotKey = r.URL.RawQuery, r.Header.Get("X-API-Key")
+		json.NewEncoder(w).Encode([]map[string]any{{
+			"id": "ing-1", "repo_full_name": "org/repo", "commit_sha": "abcdef123456",
+			"status": "FAILED", "error": "extract head snapshot: bazel exited 1",
+		}})
+	}))
+	defer srv.Close()
+
+	out, err := runCtl(t, srv, "ingestions", "list")
+	if err != nil {
+		t.Fatalf("ingestions list: %v", err)
+	}
+	if gotKey != "secret" {
+		t.Errorf("X-API-Key = %q, want secret", gotKey)
+	}
+	if gotQuery != "limit=50&status=FAILED" {
+		t.Errorf("query = %q, want limit=50&status=FAILED", gotQuery)
+	}
+	for _, want := range []string{"ing-1", "org/repo", "abcdef1", "bazel exited 1"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestBaselinePinResolvesRepoName(t *testing.T) {
+	var pinned map[string]string
+	var pinPath string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/api/v2/repos":
+			json.NewEncoder(w).Encode([]repo{{ID: "repo-1", FullName: "Org/Repo"}})
+		case r.Method == http.MethodPut:
+			pinPath = r.URL.Path
+			json.NewDecoder(r.Body).Decode(&pinned)
+			json.NewEncoder(w).Encode(map[string]any{"repo_id": "repo-1", "snapshot_id": pinned["snapshot_id"], "pinned": true})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	out, err := runCtl(t, srv, "baseline", "pin", "org/repo", "snap-9", "-o", "json")
+	if err != nil {
+		t.Fatalf("baseline pin: %v", err)
+	}
+	if pinPath != "/api/v2/admin/repos/repo-1/baseline/pin" {
+		t.Errorf("PUT path = %q", pinPath)
+	}
+	if pinned["snapshot_id"] != "snap-9" {
+		t.Errorf("snapshot_id = %q, want snap-9", pinned["snapshot_id"])
+	}
+	var resp map[string]any
+	if err := json.Unmarshal([]byte(out), &resp); err != nil || resp["pinned"] != true {
+		t.Errorf("json output = %q (%v), want pinned=true", out, err)
+	}
+}
+
+func TestClientReportsAPIError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(`{"error": "operator credentials required"}`))
+	}))
+	defer srv.Close()
+
+	_, err := runCtl(t, srv, "tenants", "list")
+	if err == nil || !strings.Contains(err.Error(), "HTTP 403: operator credentials required") {
+		t.Errorf("error = %v, want the API's message", err)
+	}
+}
+
+func TestGCFlags(t *testing.T) {
+	var body bytes.Buffer
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(&body, r.Body)
+		w.Write([]byte(`{"expired_ingestions": 2, "deleted_ingestions": 5, "expired_idempotency_keys": 3, "dry_run": true}`))
+	}))
+	defer srv.Close()
+
+	out, err := runCtl(t, srv, "gc", "--dry-run", "--retain-for", "0", "--stuck-after", "2h")
+	if err != nil {
+		t.Fatalf("gc: %v", err)
+	}
+	var req map[string]any
+	if err := json.Unmarshal(body.Bytes(), &req); err != nil {
+		t.Fatal(err)
+	}
+	if req["stuck_after"] != "2h0m0s" || req["retain_for"] != "0s" || req["dry_run"] != true {
+		t.Errorf("request = %v", req)
+	}
+	if !strings.Contains(out, "Would expire 2 stuck ingestions and delete 5 old records and 3 idempotency keys") {
+		t.Errorf("output = %q", out)
+	}
+}
+
+func TestPurgeDryRun(t *testing.T) {
+	var body bytes.Buffer
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v2/admin/purge" {
+			http.NotFound(w, r)
+			return
+		}
+		io.Copy(&body, r.Body)
+		w.Write([]byte(`{"repos": [{"id": "r1", "full_name": "acme/web", "deleted_at": "2026-01-02T00:00:00Z", "snapshots": 3, "deltas": 2, "bytes": 2097152}],
+			"tenants": ["t1"], "blobs_deleted": 0, "dry_run": true}`))
+	}))
+	defer srv.Close()
+
+	out, err := runCtl(t, srv, "purge", "--dry-run", "--retain-for", "168h")
+	if err != nil {
+		t.Fatalf("purge: %v", err)
+	}
+	var req map[string]any
+	if err := json.Unmarshal(body.Bytes(), &req); err != nil {
+		t.Fatal(err)
+	}
+	if req["retain_for"] != "168h0m0s" || req["dry_run"] != true {
+		t.Errorf("request = %v", req)
+	}
+	for _, want := range []string{"acme/web", "2026-01-02", "2.0", "Would purge 1 repositories and 1 tenants"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+func newGCCmd(c *client) *cobra.Command {
+	var stuckAfter, retainFor time.Duration
+	var dryRun bool
+
+	cmd := &cobra.Command{
+		Use:   "gc",
+		Short: "Expire stuck ingestions and delete old ingestion records",
+		Long: `Marks QUEUED or RUNNING ingestions that haven't progressed in --stuck-after as
+FAILED, and deletes finished ingestion records older than --retain-for and
+ingest idempotency keys older than a day. Snapshots, deltas, and scores are
+not touched.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			body := map[string]any{
+				"stuck_after": stuckAfter.String(),
+				"retain_for":  retainFor.String(),
+				"dry_run":     dryRun,
+			}
+			var resp struct {
+				ExpiredIngestions      int  `json:"expired_ingestions"`
+				DeletedIngestions      int  `json:"deleted_ingestions"`
+				ExpiredIdempotencyKeys int  `json:"expired_idempotency_keys"`
+				DryRun                 bool `json:"dry_run"`
+			}
+			if err := c.do(cmd.Context(), http.MethodPost, "/api/v2/admin/gc", body, &resp); err != nil {
+				return err
+			}
+			return c.print(os.Stdout, resp, func(w *tabwriter.Writer) {
+				if resp.DryRun {
+					fmt.Fprintf(w, "Would expire %d stuck ingestions and delete %d old records and %d idempotency keys\n",
+						resp.ExpiredIngestions, resp.DeletedIngestions, resp.ExpiredIdempotencyKeys)
+					return
+				}
+				fmt.Fprintf(w, "Expired %d stuck ingestions and deleted %d old records and %d idempotency keys\n",
+					resp.ExpiredIngestions, resp.DeletedIngestions, resp.ExpiredIdempotencyKeys)
+			})
+		},
+	}
+
+	cmd.Flags().DurationVar(&stuckAfter, "stuck-after", 6*time.Hour, "Expire queued or running ingestions idle this long (0 disables)")
+	cmd.Flags().DurationVar(&retainFor, "retain-for", 30*24*time.Hour, "Delete finished ingestion records older than this (0 disables)")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "Report what would change without changing it")
+
+	return cmd
+}
+
+func newPurgeCmd(c *client) *cobra.Command {
+	var retainFor time.Duration
+	var dryRun bool
+
+	cmd := &cobra.Command{
+		Use:   "purge",
+		Short: "Permanently remove deleted repositories and tenants",
+		Long: `Deletes the stored blobs and database rows of repositories and tenants that
+were deleted more than --retain-for ago. The server also does this on its own
+schedule (PURGE_INTERVAL); use --dry-run to see what is due.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			body := map[string]any{
+				"retain_for": retainFor.String(),
+				"dry_run":    dryRun,
+			}
+			var resp struct {
+				Repos []struct {
+					ID        string    `json:"id"`
+					FullName  string    `json:"full_name"`
+					DeletedAt time.Time `json:"deleted_at"`
+					Snapshots int       `json:"snapshots"`
+					Deltas    int       `json:"deltas"`
+					Bytes     int64     `json:"bytes"`
+				} `json:"repos"`
+				Tenants      []string `json:"tenants"`
+				BlobsDeleted int      `json:"blobs_deleted"`
+				Skipped      []string `json:"skipped"`
+				DryRun       bool     `json:"dry_run"`
+			}
+			if err := c.do(cmd.Context(), http.MethodPost, "/api/v2/admin/purge", body, &resp); err != nil {
+				return err
+			}
+			return c.print(os.Stdout, resp, func(w *tabwriter.Writer) {
+				if len(resp.Repos) > 0 {
+					fmt.Fprintln(w, "ID\tREPOSITORY\tDELETED\tSNAPSHOTS\tDELTAS\tMB")
+					for _, r := range resp.Repos {
+						fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%d\t%.1f\n", r.ID, r.FullName, r.DeletedAt.Format(time.DateOnly), r.Snapshots, r.Deltas, float64(r.Bytes)/(1<<20))
+					}
+				}
+				verb := "Purged"
+				if resp.DryRun {
+					verb = "Would purge"
+				}
+				fmt.Fprintf(w, "%s %d repositories and %d tenants\n", verb, len(resp.Repos), len(resp.Tenants))
+				if len(resp.Skipped) > 0 {
+					fmt.Fprintf(w, "Skipped %d repositories whose blobs could not be deleted; see the server log\n", len(resp.Skipped))
+				}
+			})
+		},
+	}
+
+	cmd.Flags().DurationVar(&retainFor, "retain-for", 30*24*time.Hour, "Only purge data deleted longer ago than this")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "Report what would be purged without deleting it")
+
+	return cmd
+}
+
+func newBaselineCmd(c *client) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "baseline",
+		Short: "Pin, unpin, or check drift of repository baselines",
+		Long: `A pinned baseline stays on its snapshot when new default-branch commits are
+ingested, e.g. to hold scoring steady across a large migration.`,
+	}
+
+	type baseline struct {
+		RepoID     string    `json:"repo_id"`
+		SnapshotID string    `json:"snapshot_id"`
+		Pinned     bool      `json:"pinned"`
+		UpdatedAt  time.Time `json:"updated_at"`
+	}
+	printBaseline := func(b baseline) error {
+		return c.print(os.Stdout, b, func(w *tabwriter.Writer) {
+			fmt.Fprintf(w, "Repository:\t%s\n", b.RepoID)
+			fmt.Fprintf(w, "Baseline:\t%s\n", b.SnapshotID)
+			fmt.Fprintf(w, "Pinned:\t%t\n", b.Pinned)
+		})
+	}
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "pin <repo> <snapshot-id>",
+		Short: "Set a repository's baseline and keep it there",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			repoID, err := c.resolveRepo(cmd.Context(), args[0])
+			if err != nil {
+				return err
+			}
+			var b baseline
+			path := "/api/v2/admin/repos/" + url.PathEscape(repoID) + "/baseline/pin"
+			if err := c.do(cmd.Context(), http.MethodPut, path, map[string]string{"snapshot_id": args[1]}, &b); err != nil {
+				return err
+			}
+			return printBaseline(b)
+		},
+	})
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "unpin <repo>",
+		Short: "Let default-branch ingests move the baseline again",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			repoID, err := c.resolveRepo(cmd.Context(), args[0])
+			if err != nil {
+				return err
+			}
+			var b baseline
+			path := "/api/v2/admin/repos/" + url.PathEscape(repoID) + "/baseline/pin"
+			if err := c.do(cmd.Context(), http.MethodDelete, path, nil, &b); err != nil {
+				return err
+			}
+			return printBaseline(b)
+		},
+	})
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "drifted",
+		Short: "List baselines the last drift check flagged and didn't refresh",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var drifted []struct {
+				FullName          string  `json:"full_name"`
+				BaselineCommitSHA string  `json:"baseline_commit_sha"`
+				HeadCommitSHA     string  `json:"head_commit_sha"`
+				Drift             float64 `json:"drift"`
+				CheckedAt         string  `json:"checked_at"`
+			}
+			if err := c.do(cmd.Context(), http.MethodGet, "/api/v2/admin/baselines/drifted", nil, &drifted); err != nil {
+				return err
+			}
+			return c.print(os.Stdout, drifted, func(w *tabwriter.Writer) {
+				fmt.Fprintln(w, "REPOSITORY\tDRIFT\tBASELINE\tDEFAULT BRANCH\tCHECKED")
+				for _, d := range drifted {
+					fmt.Fprintf(w, "%s\t%.1f%%\t%s\t%s\t%s\n", d.FullName, 100*d.Drift,
+						shortSHA(d.BaselineCommitSHA), shortSHA(d.HeadCommitSHA), d.CheckedAt)
+				}
+			})
+		},
+	})
+
+	return cmd
+}
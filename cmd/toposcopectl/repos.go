@@ -0,0 +1,153 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+)
+
+func newReposCmd(c *client) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "repos",
+		Short: "Inspect, delete, and restore repositories",
+	}
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "list",
+		Short: "List repositories across all tenants",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var repos []repo
+			if err := c.do(cmd.Context(), http.MethodGet, "/api/v2/repos", nil, &repos); err != nil {
+				return err
+			}
+			return c.print(os.Stdout, repos, func(w *tabwriter.Writer) {
+				fmt.Fprintln(w, "ID\tREPOSITORY\tDEFAULT BRANCH")
+				for _, r := range repos {
+					fmt.Fprintf(w, "%s\t%s\t%s\n", r.ID, r.FullName, r.DefaultBranch)
+				}
+			})
+		},
+	})
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "delete <repo>",
+		Short: "Delete a repository",
+		Long: `Marks a repository deleted and revokes its API keys. Its data is kept until
+the purge job removes it, so it can be restored until then.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			repoID, err := c.resolveRepo(cmd.Context(), args[0])
+			if err != nil {
+				return err
+			}
+			if err := c.do(cmd.Context(), http.MethodDelete, "/api/v2/repos/"+url.PathEscape(repoID), nil, nil); err != nil {
+				return err
+			}
+			fmt.Fprintf(os.Stdout, "Deleted repository %s\n", repoID)
+			return nil
+		},
+	})
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "restore <repo-id>",
+		Short: "Restore a deleted repository that has not been purged",
+		Long: `Restores a deleted repository by ID. Deleted repositories are not listed, so
+the ID is the one printed by "repos delete" or a purge dry run. Issue a new
+API key afterwards with "keys rotate".`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var r repo
+			path := "/api/v2/admin/repos/" + url.PathEscape(args[0]) + "/restore"
+			if err := c.do(cmd.Context(), http.MethodPost, path, nil, &r); err != nil {
+				return err
+			}
+			return c.print(os.Stdout, r, func(w *tabwriter.Writer) {
+				fmt.Fprintf(w, "Restored %s (%s)\n", r.FullName, r.ID)
+			})
+		},
+	})
+
+	return cmd
+}
+
+func newRescoreCmd(c *client) *cobra.Command {
+	var repoArg string
+
+	cmd := &cobra.Command{
+		Use:   "rescore",
+		Short: "Re-run scoring on stored scores",
+		Long: `Recomputes every stored score (or one repository's) with the server's current
+scoring engine and updates the rows in place.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			body := map[string]string{}
+			if repoArg != "" {
+				repoID, err := c.resolveRepo(cmd.Context(), repoArg)
+				if err != nil {
+					return err
+				}
+				body["repo_id"] = repoID
+			}
+			var resp struct {
+				Rescored      int `json:"rescored"`
+				Errors        int `json:"errors"`
+				ConfigChanged int `json:"config_changed"`
+			}
+			if err := c.do(cmd.Context(), http.MethodPost, "/api/v2/rescore", body, &resp); err != nil {
+				return err
+			}
+			return c.print(os.Stdout, resp, func(w *tabwriter.Writer) {
+				fmt.Fprintf(w, "Rescored %d scores (%d with a changed configuration), %d errors\n", resp.Rescored, resp.ConfigChanged, resp.Errors)
+			})
+		},
+	}
+
+	cmd.Flags().StringVar(&repoArg, "repo", "", "Only rescore this repository (ID or owner/name)")
+
+	return cmd
+}
+
+func newKeysCmd(c *client) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "keys",
+		Short: "Manage repository API keys",
+	}
+
+	var keepExisting bool
+	rotateCmd := &cobra.Command{
+		Use:   "rotate <repo>",
+		Short: "Issue a new API key for a repository and revoke the old ones",
+		Long: `Issues a new repository API key and prints it. The key is shown only once.
+Existing keys are revoked unless --keep-existing is set, which lets CI move
+to the new key before the old one is revoked with a second rotation.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			repoID, err := c.resolveRepo(cmd.Context(), args[0])
+			if err != nil {
+				return err
+			}
+			var resp struct {
+				RepoID  string `json:"repo_id"`
+				APIKey  string `json:"api_key"`
+				Revoked int    `json:"revoked"`
+			}
+			path := "/api/v2/admin/repos/" + url.PathEscape(repoID) + "/api-keys"
+			if err := c.do(cmd.Context(), http.MethodPost, path, map[string]bool{"keep_existing": keepExisting}, &resp); err != nil {
+				return err
+			}
+			return c.print(os.Stdout, resp, func(w *tabwriter.Writer) {
+				fmt.Fprintf(w, "New API key:\t%s\n", resp.APIKey)
+				fmt.Fprintf(w, "Revoked:\t%d\n", resp.Revoked)
+			})
+		},
+	}
+	rotateCmd.Flags().BoolVar(&keepExisting, "keep-existing", false, "Leave existing keys valid")
+	cmd.AddCommand(rotateCmd)
+
+	return cmd
+}
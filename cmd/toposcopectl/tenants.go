@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+func newTenantsCmd(c *client) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "tenants",
+		Short: "Inspect and delete tenants",
+	}
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "delete <tenant-id>",
+		Short: "Delete a tenant and all of its repositories",
+		Long: `Marks a tenant and its repositories deleted and revokes their API keys. The
+purge job removes their data once the retention window has passed.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := c.do(cmd.Context(), http.MethodDelete, "/api/v2/admin/tenants/"+url.PathEscape(args[0]), nil, nil); err != nil {
+				return err
+			}
+			fmt.Fprintf(os.Stdout, "Deleted tenant %s\n", args[0])
+			return nil
+		},
+	})
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "list",
+		Short: "List tenants and their repository counts",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var tenants []struct {
+				ID          string    `json:"id"`
+				DisplayName string    `json:"display_name"`
+				RepoCount   int       `json:"repo_count"`
+				CreatedAt   time.Time `json:"created_at"`
+			}
+			if err := c.do(cmd.Context(), http.MethodGet, "/api/v2/admin/tenants", nil, &tenants); err != nil {
+				return err
+			}
+			return c.print(os.Stdout, tenants, func(w *tabwriter.Writer) {
+				fmt.Fprintln(w, "ID\tNAME\tREPOS\tCREATED")
+				for _, t := range tenants {
+					fmt.Fprintf(w, "%s\t%s\t%d\t%s\n", t.ID, t.DisplayName, t.RepoCount, t.CreatedAt.Format(time.DateOnly))
+				}
+			})
+		},
+	})
+
+	var months int
+	usageCmd := &cobra.Command{
+		Use:   "usage <tenant-id>",
+		Short: "Show a tenant's storage, quota, and monthly usage",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var usage struct {
+				TenantID     string `json:"tenant_id"`
+				StorageBytes int64  `json:"storage_bytes"`
+				Quota        struct {
+					StorageBytes      int64 `json:"storage_bytes"`
+					MonthlyIngestions int64 `json:"monthly_ingestions"`
+				} `json:"quota"`
+				Months []struct {
+					Month        time.Time `json:"month"`
+					Ingestions   int64     `json:"ingestions"`
+					Snapshots    int64     `json:"snapshots"`
+					Deltas       int64     `json:"deltas"`
+					Scores       int64     `json:"scores"`
+					BytesWritten int64     `json:"bytes_written"`
+				} `json:"months"`
+			}
+			path := "/api/v2/tenants/" + url.PathEscape(args[0]) + "/usage?months=" + strconv.Itoa(months)
+			if err := c.do(cmd.Context(), http.MethodGet, path, nil, &usage); err != nil {
+				return err
+			}
+			return c.print(os.Stdout, usage, func(w *tabwriter.Writer) {
+				fmt.Fprintf(w, "Storage:\t%d bytes (quota %s)\n", usage.StorageBytes, limit(usage.Quota.StorageBytes))
+				fmt.Fprintf(w, "Ingestions:\tquota %s per month\n\n", limit(usage.Quota.MonthlyIngestions))
+				fmt.Fprintln(w, "MONTH\tINGESTIONS\tSNAPSHOTS\tDELTAS\tSCORES\tBYTES WRITTEN")
+				for _, m := range usage.Months {
+					fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%d\t%d\n", m.Month.Format("2006-01"), m.Ingestions, m.Snapshots, m.Deltas, m.Scores, m.BytesWritten)
+				}
+			})
+		},
+	}
+	usageCmd.Flags().IntVar(&months, "months", 12, "Months of history to show")
+	cmd.AddCommand(usageCmd)
+
+	return cmd
+}
+
+func limit(n int64) string {
+	if n == 0 {
+		return "unlimited"
+	}
+	return strconv.FormatInt(n, 10)
+}
@@ -5,40 +5,100 @@ package main
 
 import (
 	"context"
+	"crypto/subtle"
 	"database/sql"
 	"encoding/json"
+	"fmt"
 	"log"
 	"net/http"
 	"os"
 	"os/signal"
+	"sort"
 	"strconv"
 	"strings"
 	"syscall"
+	"time"
 
 	_ "github.com/lib/pq"
 
 	"github.com/toposcope/toposcope/internal/api"
 	"github.com/toposcope/toposcope/internal/ingestion"
 	"github.com/toposcope/toposcope/internal/platform"
+	"github.com/toposcope/toposcope/internal/surface"
 	"github.com/toposcope/toposcope/internal/tenant"
 	"github.com/toposcope/toposcope/internal/webhook"
+	"github.com/toposcope/toposcope/pkg/extract"
+	"github.com/toposcope/toposcope/pkg/extract/subgraph"
+	"github.com/toposcope/toposcope/pkg/graph"
+	"github.com/toposcope/toposcope/pkg/scoring"
 )
 
+// version is the build version, set with -ldflags "-X main.version=...".
+var version = "dev"
+
 type config struct {
 	Port             string
 	DatabaseURL      string
 	APIKey           string
 	CacheSize        int
+	SnapshotIndexDir string // memory-mapped snapshot indexes for graph queries; empty disables
 	StorageBackend   string // local | s3 | gcs
 	LocalStoragePath string
+	LocalStorageSync bool // fsync local blobs before acknowledging writes
 	S3Bucket         string
 	S3Region         string
 	S3Endpoint       string
+	S3SSE            string // AES256 | aws:kms
+	S3SSEKMSKeyID    string
+	S3StorageClass   string
+	S3PartSizeMB     int
+	S3MaxAttempts    int
 	GCSBucket        string
-	AuthMode         string // none | api-key | oidc-proxy
+	EncryptionKey    string        // base64 AES-256 key for blob envelope encryption
+	KMSKey           string        // AWS KMS key ARN or GCP KMS key name; overrides EncryptionKey
+	AuthMode         string        // none | api-key | oidc-proxy
+	TenantIsolation  bool          // reject API requests that don't identify a tenant
+	QuotaStorageMB   int           // default per-tenant stored-blob limit (0 = none)
+	QuotaIngestions  int           // default per-tenant monthly ingestion limit (0 = none)
+	PurgeInterval    time.Duration // how often deleted data is purged (0 = never)
+	PurgeRetention   time.Duration // how long deleted repos and tenants are kept
+	KeepPRScores     int           // most recent scores kept per PR (0 = all)
+	DriftInterval    time.Duration // how often baselines are checked for drift (0 = never)
+	DriftThreshold   float64       // drift ratio above which a baseline is flagged
+	DriftRefresh     bool          // replace drifted, unpinned baselines
+	BackfillWorkers  int           // concurrent backfill extractions on this instance (0 = none)
+	WebhookAttempts  int           // delivery attempts per score webhook
 	AutoMigrate      bool
 	MigrateOnly      bool
 	WebhookSecret    string
+	DispatchMode     string // inline | http | none
+	ProcessURL       string // worker endpoint for DispatchMode=http
+	ProcessToken     string // shared secret guarding /internal/process
+	WorkerCount      int
+	TenantWorkers    int // per-tenant concurrency limit for the inline pool (0 = none)
+	QueueSize        int
+	TenantQueueSize  int
+	GitHubAppID      int64
+	GitHubAppKey     string // PEM-encoded GitHub App private key
+	ExtractWorkDir   string
+	BazelPath        string
+	ExtractTimeout   time.Duration
+	ExtractRunner    string // local | kubernetes
+	BazelDiffJar     string
+	ExtractMemoryMB  int    // bazel server heap cap (0 = none)
+	ExtractCPUSecs   int    // per-query bazel time cap (0 = none)
+	OutputBaseDir    string // per-repository bazel output bases (empty = one per checkout)
+	ShardQueries     int    // concurrent per-tree queries for full extractions (0 = one query)
+	ProfileQueries   bool   // record bazel profile phases in snapshot stats
+	ScopedPRs        bool   // extract PR heads scoped to their impacted targets
+	ScopedRdepsDepth int
+	MaxScopedTargets int
+	K8sNamespace     string
+	K8sJobImage      string
+	K8sJobCPU        string
+	K8sJobMemory     string
+	K8sJobSecret     string
+	K8sJobSA         string
 }
 
 func loadConfig() config {
@@ -49,27 +109,109 @@ func loadConfig() config {
 		}
 	}
 
+	workerCount := 2
+	if v := os.Getenv("INGESTION_WORKERS"); v != "" {
+		if parsed, err := strconv.Atoi(v); err == nil && parsed > 0 {
+			workerCount = parsed
+		}
+	}
+
+	tenantWorkers := envInt("INGESTION_TENANT_CONCURRENCY", 0)
+	queueSize := envInt("INGESTION_QUEUE_SIZE", 100)
+	tenantQueueSize := envInt("INGESTION_TENANT_QUEUE_SIZE", 0)
+
+	appID, _ := strconv.ParseInt(os.Getenv("GITHUB_APP_ID"), 10, 64)
+	extractTimeout := 30 * time.Minute
+	if v := os.Getenv("EXTRACTION_TIMEOUT"); v != "" {
+		if parsed, err := time.ParseDuration(v); err == nil && parsed > 0 {
+			extractTimeout = parsed
+		}
+	}
+
 	return config{
 		Port:             envOrDefault("PORT", "8080"),
 		DatabaseURL:      envOrDefault("DATABASE_URL", "postgres://localhost:5432/toposcope?sslmode=disable"),
 		APIKey:           os.Getenv("API_KEY"),
 		CacheSize:        cacheSize,
+		SnapshotIndexDir: os.Getenv("SNAPSHOT_INDEX_DIR"),
 		StorageBackend:   envOrDefault("STORAGE_BACKEND", "local"),
 		LocalStoragePath: envOrDefault("LOCAL_STORAGE_PATH", "/tmp/toposcope-data"),
+		LocalStorageSync: os.Getenv("LOCAL_STORAGE_FSYNC") == "true",
 		S3Bucket:         os.Getenv("S3_BUCKET"),
 		S3Region:         os.Getenv("S3_REGION"),
 		S3Endpoint:       os.Getenv("S3_ENDPOINT"),
+		S3SSE:            os.Getenv("S3_SSE"),
+		S3SSEKMSKeyID:    os.Getenv("S3_SSE_KMS_KEY_ID"),
+		S3StorageClass:   os.Getenv("S3_STORAGE_CLASS"),
+		S3PartSizeMB:     envInt("S3_PART_SIZE_MB", 0),
+		S3MaxAttempts:    envInt("S3_MAX_ATTEMPTS", 0),
 		GCSBucket:        os.Getenv("GCS_BUCKET"),
+		EncryptionKey:    os.Getenv("STORAGE_ENCRYPTION_KEY"),
+		KMSKey:           os.Getenv("STORAGE_KMS_KEY"),
 		AuthMode:         envOrDefault("AUTH_MODE", "api-key"),
+		TenantIsolation:  os.Getenv("TENANT_ISOLATION") == "true",
+		QuotaStorageMB:   envInt("TENANT_QUOTA_STORAGE_MB", 0),
+		QuotaIngestions:  envInt("TENANT_QUOTA_MONTHLY_INGESTIONS", 0),
+		PurgeInterval:    envDuration("PURGE_INTERVAL", 24*time.Hour),
+		PurgeRetention:   envDuration("PURGE_RETENTION", 30*24*time.Hour),
+		KeepPRScores:     envInt("PR_KEEP_SCORES", 0),
+		DriftInterval:    envDuration("DRIFT_CHECK_INTERVAL", 0),
+		DriftThreshold:   envFloat("DRIFT_THRESHOLD", ingestion.DefaultDriftThreshold),
+		DriftRefresh:     os.Getenv("DRIFT_AUTO_REFRESH") == "true",
+		BackfillWorkers:  envInt("BACKFILL_WORKERS", 1),
+		WebhookAttempts:  envInt("WEBHOOK_MAX_ATTEMPTS", 3),
 		AutoMigrate:      os.Getenv("AUTO_MIGRATE") == "true",
 		MigrateOnly:      os.Getenv("MIGRATE_ONLY") == "true",
 		WebhookSecret:    os.Getenv("GITHUB_WEBHOOK_SECRET"),
+		DispatchMode:     envOrDefault("DISPATCH_MODE", "inline"),
+		ProcessURL:       os.Getenv("PROCESS_URL"),
+		ProcessToken:     os.Getenv("PROCESS_TOKEN"),
+		WorkerCount:      workerCount,
+		TenantWorkers:    tenantWorkers,
+		QueueSize:        queueSize,
+		TenantQueueSize:  tenantQueueSize,
+		GitHubAppID:      appID,
+		GitHubAppKey:     os.Getenv("GITHUB_APP_PRIVATE_KEY"),
+		ExtractWorkDir:   os.Getenv("EXTRACTION_WORKDIR"),
+		BazelPath:        envOrDefault("BAZEL_PATH", "bazelisk"),
+		ExtractTimeout:   extractTimeout,
+		ExtractRunner:    envOrDefault("EXTRACTION_RUNNER", "local"),
+		BazelDiffJar:     os.Getenv("BAZEL_DIFF_JAR"),
+		ExtractMemoryMB:  envInt("EXTRACTION_MAX_MEMORY_MB", 0),
+		ExtractCPUSecs:   envInt("EXTRACTION_MAX_CPU_SECONDS", 0),
+		OutputBaseDir:    os.Getenv("EXTRACTION_OUTPUT_BASE_DIR"),
+		ShardQueries:     envInt("EXTRACTION_SHARD_PARALLELISM", 0),
+		ProfileQueries:   os.Getenv("EXTRACTION_PROFILE") == "true",
+		ScopedPRs:        os.Getenv("PR_SCOPED_EXTRACTION") == "true",
+		ScopedRdepsDepth: envInt("PR_SCOPE_RDEPS_DEPTH", 2),
+		MaxScopedTargets: envInt("PR_SCOPE_MAX_TARGETS", ingestion.DefaultMaxScopedTargets),
+		K8sNamespace:     os.Getenv("K8S_NAMESPACE"),
+		K8sJobImage:      os.Getenv("K8S_JOB_IMAGE"),
+		K8sJobCPU:        os.Getenv("K8S_JOB_CPU"),
+		K8sJobMemory:     os.Getenv("K8S_JOB_MEMORY"),
+		K8sJobSecret:     os.Getenv("K8S_JOB_SECRET"),
+		K8sJobSA:         os.Getenv("K8S_JOB_SERVICE_ACCOUNT"),
 	}
 }
 
 func main() {
+	// `toposcoped openapi` prints the API description and exits.
+	if len(os.Args) > 1 && os.Args[1] == "openapi" {
+		os.Stdout.Write(api.OpenAPI())
+		return
+	}
+
 	cfg := loadConfig()
 
+	// Extraction jobs launched by the Kubernetes runner need storage and a
+	// GitHub App, but no database or HTTP server.
+	if os.Getenv("TOPOSCOPE_MODE") == "extract-job" {
+		if err := runExtractJob(context.Background(), cfg); err != nil {
+			log.Fatalf("extract job: %v", err)
+		}
+		return
+	}
+
 	db, err := sql.Open("postgres", cfg.DatabaseURL)
 	if err != nil {
 		log.Fatalf("open database: %v", err)
@@ -101,41 +243,92 @@ func main() {
 		log.Printf("FATAL: init storage: %v", err)
 		return
 	}
+	if err := pingStorage(context.Background(), storage); err != nil {
+		log.Printf("FATAL: %s storage is not usable: %v", cfg.StorageBackend, err)
+		return
+	}
 
 	// Initialize services
 	tenantSvc := tenant.NewService(db)
-	ingestionSvc := ingestion.NewService(db, tenantSvc, storage, nil, nil)
+	tenantSvc.DefaultQuota = tenant.Quota{
+		StorageBytes:      int64(cfg.QuotaStorageMB) << 20,
+		MonthlyIngestions: int64(cfg.QuotaIngestions),
+	}
+	extractor, err := initExtractor(cfg, storage)
+	if err != nil {
+		log.Printf("FATAL: init extractor: %v", err)
+		return
+	}
+	ingestionSvc := ingestion.NewService(db, tenantSvc, storage, extractor, engineScorer{scoring.NewEngine(scoring.DefaultMetrics()...)})
+	ingestionSvc.KeepPRScores = cfg.KeepPRScores
+	ingestionSvc.Webhooks = &ingestion.WebhookSender{MaxAttempts: cfg.WebhookAttempts}
+	if detector, ok := extractor.(ingestion.ImpactDetector); ok && cfg.ScopedPRs {
+		ingestionSvc.Impacts = detector
+		ingestionSvc.ScopedRdepsDepth = cfg.ScopedRdepsDepth
+		ingestionSvc.MaxScopedTargets = cfg.MaxScopedTargets
+	} else if cfg.ScopedPRs {
+		log.Println("PR_SCOPED_EXTRACTION needs the local extraction runner: PRs are extracted in full")
+	}
+	if cfg.GitHubAppID != 0 && cfg.GitHubAppKey != "" {
+		publisher, err := surface.NewGitHubPublisher(cfg.GitHubAppID, []byte(cfg.GitHubAppKey))
+		if err != nil {
+			log.Printf("FATAL: init GitHub App: %v", err)
+			return
+		}
+		ingestionSvc.Commits = &ingestion.GitHubCommits{Tokens: publisher}
+		ingestionSvc.Checks = publisher
+	}
 
 	// Initialize API handler
 	cache := api.NewSnapshotCache(cfg.CacheSize)
 	apiHandler := api.NewHandler(db, tenantSvc, ingestionSvc, cache)
+	apiHandler.IndexDir = cfg.SnapshotIndexDir
+	apiHandler.Version = version
 
 	// Set up HTTP routes
 	mux := http.NewServeMux()
 
+	dispatcher, closeDispatcher := initDispatcher(cfg, ingestionSvc)
+	defer closeDispatcher()
+	pool, _ := dispatcher.(*ingestion.WorkerPool)
+
 	// Conditionally register webhook handler
 	if cfg.WebhookSecret != "" {
-		webhookHandler := webhook.NewHandler([]byte(cfg.WebhookSecret), tenantSvc, ingestionSvc)
+		webhookHandler := webhook.NewHandler([]byte(cfg.WebhookSecret), tenantSvc, ingestionSvc, dispatcher)
 		mux.Handle("POST /v1/webhooks/github", webhookHandler)
 	}
 
-	mux.HandleFunc("POST /internal/process", processHandler(ingestionSvc))
+	mux.HandleFunc("POST /internal/process", processHandler(ingestionSvc, pool, cfg.ProcessToken))
+	if pool != nil {
+		mux.HandleFunc("GET /metrics", metricsHandler(pool))
+	}
 	mux.HandleFunc("GET /healthz", healthHandler(db))
 	mux.HandleFunc("GET /health", healthHandler(db))
+	mux.HandleFunc("GET /readyz", readyHandler(db, storage))
 
 	// Register API routes
 	apiHandler.RegisterRoutes(mux)
 
-	// Apply CORS middleware globally, auth middleware on write endpoints
-	authMiddleware := api.WriteAuth(api.AuthMode(cfg.AuthMode), cfg.APIKey)
+	// Apply CORS middleware globally, tenant resolution on API endpoints,
+	// and auth middleware on write endpoints
+	authMiddleware := api.WriteAuth(api.AuthMode(cfg.AuthMode), cfg.APIKey, tenantSvc.GetRepositoryByAPIKey)
+	scoped := api.ResolveTenant(api.AuthMode(cfg.AuthMode), cfg.APIKey, tenantSvc.GetRepositoryByAPIKey, cfg.TenantIsolation)(mux)
 	handler := api.CORS(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		isWrite := (r.Method == "POST" || r.Method == "PATCH" || r.Method == "DELETE") &&
-			strings.HasPrefix(r.URL.Path, "/api/")
-		if isWrite {
-			authMiddleware(mux).ServeHTTP(w, r)
+		if !strings.HasPrefix(r.URL.Path, "/api/") {
+			mux.ServeHTTP(w, r)
 			return
 		}
-		mux.ServeHTTP(w, r)
+		isWrite := r.Method == "POST" || r.Method == "PUT" || r.Method == "PATCH" || r.Method == "DELETE"
+		// GraphQL only serves queries, so a POST to it is a read.
+		if r.URL.Path == "/api/graphql" {
+			isWrite = false
+		}
+		// Operator endpoints need credentials even for reads.
+		if isWrite || strings.HasPrefix(r.URL.Path, "/api/v2/admin/") || strings.HasPrefix(r.URL.Path, "/api/v1/admin/") {
+			authMiddleware(scoped).ServeHTTP(w, r)
+			return
+		}
+		scoped.ServeHTTP(w, r)
 	}))
 
 	srv := &http.Server{
@@ -147,6 +340,21 @@ func main() {
 	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
 	defer stop()
 
+	if cfg.PurgeInterval > 0 {
+		go runPurgeLoop(ctx, ingestionSvc, cfg.PurgeInterval, cfg.PurgeRetention)
+	}
+	if cfg.DriftInterval > 0 && extractor != nil {
+		go runDriftLoop(ctx, ingestionSvc, cfg.DriftInterval, ingestion.DriftOptions{
+			Threshold:   cfg.DriftThreshold,
+			AutoRefresh: cfg.DriftRefresh,
+		})
+	}
+	if extractor != nil {
+		for i := 0; i < cfg.BackfillWorkers; i++ {
+			go ingestionSvc.RunBackfillWorker(ctx, ingestion.BackfillOptions{})
+		}
+	}
+
 	go func() {
 		log.Printf("starting toposcoped on :%s", cfg.Port)
 		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
@@ -162,30 +370,236 @@ func main() {
 }
 
 func initStorage(ctx context.Context, cfg config) (ingestion.StorageClient, error) {
+	var storage ingestion.StorageClient
 	switch cfg.StorageBackend {
 	case "s3":
-		return ingestion.NewS3Storage(ctx, ingestion.S3Config{
+		s3, err := ingestion.NewS3Storage(ctx, ingestion.S3Config{
 			Bucket:    cfg.S3Bucket,
 			Region:    cfg.S3Region,
 			Endpoint:  cfg.S3Endpoint,
 			AccessKey: os.Getenv("AWS_ACCESS_KEY_ID"),
 			SecretKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+
+			PartSize:             int64(cfg.S3PartSizeMB) << 20,
+			MaxAttempts:          cfg.S3MaxAttempts,
+			ServerSideEncryption: cfg.S3SSE,
+			SSEKMSKeyID:          cfg.S3SSEKMSKeyID,
+			StorageClass:         cfg.S3StorageClass,
 		})
+		if err != nil {
+			return nil, err
+		}
+		storage = s3
 	case "gcs":
-		return ingestion.NewGCSStorage(ctx, cfg.GCSBucket)
+		gcs, err := ingestion.NewGCSStorage(ctx, cfg.GCSBucket)
+		if err != nil {
+			return nil, err
+		}
+		storage = gcs
 	default: // "local"
-		return ingestion.NewLocalStorage(cfg.LocalStoragePath), nil
+		local := ingestion.NewLocalStorage(cfg.LocalStoragePath)
+		local.Sync = cfg.LocalStorageSync
+		storage = local
+	}
+
+	var keys ingestion.KeyWrapper
+	switch {
+	case cfg.KMSKey != "":
+		kms, err := ingestion.NewKMSKeyWrapper(ctx, cfg.KMSKey)
+		if err != nil {
+			return nil, fmt.Errorf("init kms: %w", err)
+		}
+		keys = kms
+	case cfg.EncryptionKey != "":
+		static, err := ingestion.NewStaticKeyWrapper(cfg.EncryptionKey)
+		if err != nil {
+			return nil, fmt.Errorf("STORAGE_ENCRYPTION_KEY: %w", err)
+		}
+		keys = static
+	default:
+		return storage, nil
+	}
+	return ingestion.NewEncryptedStorage(storage, keys), nil
+}
+
+// initExtractor returns the hosted extractor: Kubernetes Jobs when
+// EXTRACTION_RUNNER=kubernetes, otherwise in-process clone-and-extract. It
+// returns nil when neither is configured (uploads via /api/v2/ingest still
+// work).
+func initExtractor(cfg config, storage ingestion.StorageClient) (extract.Extractor, error) {
+	if cfg.ExtractRunner == "kubernetes" {
+		return initKubernetesRunner(cfg, storage)
+	}
+	executor, err := initCloneExecutor(cfg)
+	if executor == nil || err != nil {
+		return nil, err // avoid a non-nil interface holding a nil pointer
+	}
+	return executor, nil
+}
+
+func initCloneExecutor(cfg config) (*ingestion.CloneExecutor, error) {
+	if cfg.GitHubAppID == 0 || cfg.GitHubAppKey == "" {
+		log.Println("GITHUB_APP_ID/GITHUB_APP_PRIVATE_KEY not set: hosted extraction disabled")
+		return nil, nil
+	}
+	publisher, err := surface.NewGitHubPublisher(cfg.GitHubAppID, []byte(cfg.GitHubAppKey))
+	if err != nil {
+		return nil, err
+	}
+	return &ingestion.CloneExecutor{
+		Tokens:           publisher,
+		WorkDir:          cfg.ExtractWorkDir,
+		BazelPath:        cfg.BazelPath,
+		Timeout:          cfg.ExtractTimeout,
+		BazelDiffJar:     cfg.BazelDiffJar,
+		Limits:           subgraph.ResourceLimits{MemoryMB: cfg.ExtractMemoryMB, CPUSeconds: cfg.ExtractCPUSecs},
+		OutputBaseDir:    cfg.OutputBaseDir,
+		ShardParallelism: cfg.ShardQueries,
+		Profile:          cfg.ProfileQueries,
+	}, nil
+}
+
+func initKubernetesRunner(cfg config, storage ingestion.StorageClient) (*ingestion.KubernetesRunner, error) {
+	if cfg.K8sJobImage == "" {
+		return nil, fmt.Errorf("EXTRACTION_RUNNER=kubernetes requires K8S_JOB_IMAGE")
+	}
+	if cfg.StorageBackend == "local" {
+		return nil, fmt.Errorf("EXTRACTION_RUNNER=kubernetes requires shared storage (STORAGE_BACKEND=s3 or gcs)")
 	}
+	client, err := ingestion.NewInClusterKubeClient()
+	if err != nil {
+		return nil, err
+	}
+
+	// Non-secret settings the job needs; credentials (GITHUB_APP_PRIVATE_KEY,
+	// AWS keys, STORAGE_ENCRYPTION_KEY) come from K8S_JOB_SECRET.
+	env := map[string]string{
+		"STORAGE_BACKEND":    cfg.StorageBackend,
+		"BAZEL_PATH":         cfg.BazelPath,
+		"EXTRACTION_TIMEOUT": cfg.ExtractTimeout.String(),
+	}
+	for _, key := range []string{"S3_BUCKET", "S3_REGION", "S3_ENDPOINT", "S3_SSE", "S3_SSE_KMS_KEY_ID", "S3_STORAGE_CLASS", "S3_PART_SIZE_MB", "S3_MAX_ATTEMPTS", "GCS_BUCKET", "GITHUB_APP_ID", "STORAGE_KMS_KEY", "EXTRACTION_MAX_MEMORY_MB", "EXTRACTION_MAX_CPU_SECONDS", "EXTRACTION_SHARD_PARALLELISM", "EXTRACTION_PROFILE"} {
+		if v := os.Getenv(key); v != "" {
+			env[key] = v
+		}
+	}
+
+	return &ingestion.KubernetesRunner{
+		Client:         client,
+		Storage:        storage,
+		Namespace:      firstNonEmpty(cfg.K8sNamespace, ingestion.InClusterNamespace()),
+		Image:          cfg.K8sJobImage,
+		ServiceAccount: cfg.K8sJobSA,
+		CPU:            cfg.K8sJobCPU,
+		Memory:         cfg.K8sJobMemory,
+		SecretName:     cfg.K8sJobSecret,
+		Env:            env,
+		Timeout:        cfg.ExtractTimeout,
+	}, nil
 }
 
-func processHandler(svc *ingestion.Service) http.HandlerFunc {
+// runExtractJob is the entry point of a Kubernetes extraction job: it
+// extracts the request in EXTRACTION_REQUEST and stores the snapshot where
+// the runner expects it.
+func runExtractJob(ctx context.Context, cfg config) error {
+	var req extract.ExtractionRequest
+	if err := json.Unmarshal([]byte(os.Getenv(ingestion.EnvExtractionRequest)), &req); err != nil {
+		return fmt.Errorf("decode %s: %w", ingestion.EnvExtractionRequest, err)
+	}
+	snapshotID := os.Getenv(ingestion.EnvJobSnapshotID)
+	if snapshotID == "" {
+		return fmt.Errorf("%s not set", ingestion.EnvJobSnapshotID)
+	}
+
+	storage, err := initStorage(ctx, cfg)
+	if err != nil {
+		return fmt.Errorf("init storage: %w", err)
+	}
+	if err := pingStorage(ctx, storage); err != nil {
+		return fmt.Errorf("%s storage is not usable: %w", cfg.StorageBackend, err)
+	}
+	executor, err := initCloneExecutor(cfg)
+	if err != nil {
+		return err
+	}
+	if executor == nil {
+		return fmt.Errorf("GITHUB_APP_ID and GITHUB_APP_PRIVATE_KEY are required")
+	}
+
+	snap, err := executor.Extract(ctx, req)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(snap)
+	if err != nil {
+		return fmt.Errorf("marshal snapshot: %w", err)
+	}
+	if err := storage.PutSnapshot(ctx, ingestion.JobTenant, snapshotID, data); err != nil {
+		return fmt.Errorf("store snapshot: %w", err)
+	}
+	log.Printf("extracted %s@%s: %d nodes, %d edges", req.Repo, snap.CommitSHA, snap.Stats.NodeCount, snap.Stats.EdgeCount)
+	return nil
+}
+
+// engineScorer adapts scoring.Engine to ingestion.Scorer.
+type engineScorer struct {
+	engine *scoring.Engine
+}
+
+func (s engineScorer) Score(base, head *graph.Snapshot, delta *graph.Delta) (*scoring.ScoreResult, error) {
+	return s.engine.Score(delta, base, head)
+}
+
+// initDispatcher returns how webhook-created ingestions get processed, and a
+// function that drains in-flight work on shutdown.
+func initDispatcher(cfg config, svc *ingestion.Service) (ingestion.Dispatcher, func()) {
+	switch cfg.DispatchMode {
+	case "none":
+		log.Println("DISPATCH_MODE=none: webhook ingestions will stay queued")
+		return nil, func() {}
+	case "http":
+		if cfg.ProcessURL == "" {
+			log.Fatalf("DISPATCH_MODE=http requires PROCESS_URL")
+		}
+		return &ingestion.HTTPDispatcher{URL: cfg.ProcessURL, Token: cfg.ProcessToken}, func() {}
+	default: // "inline"
+		pool := ingestion.NewWorkerPool(svc.ProcessPR, ingestion.PoolConfig{
+			Workers:         cfg.WorkerCount,
+			TenantLimit:     cfg.TenantWorkers,
+			QueueSize:       cfg.QueueSize,
+			TenantQueueSize: cfg.TenantQueueSize,
+		})
+		return pool, pool.Close
+	}
+}
+
+// processHandler serves /internal/process. With a worker pool the ingestion
+// is queued under the pool's limits and the handler returns 202; otherwise
+// it is processed before responding.
+func processHandler(svc *ingestion.Service, pool *ingestion.WorkerPool, token string) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		presented := []byte(r.Header.Get("Authorization"))
+		if token != "" && subtle.ConstantTimeCompare(presented, []byte("Bearer "+token)) != 1 {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
 		var req ingestion.IngestionRequest
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 			http.Error(w, "invalid request body", http.StatusBadRequest)
 			return
 		}
 
+		if pool != nil {
+			if err := pool.Dispatch(r.Context(), req); err != nil {
+				http.Error(w, err.Error(), http.StatusServiceUnavailable)
+				return
+			}
+			w.WriteHeader(http.StatusAccepted)
+			_ = json.NewEncoder(w).Encode(map[string]string{"status": "queued"})
+			return
+		}
+
 		if err := svc.ProcessPR(r.Context(), req); err != nil {
 			log.Printf("process error: %v", err)
 			http.Error(w, "processing failed", http.StatusInternalServerError)
@@ -197,6 +611,41 @@ func processHandler(svc *ingestion.Service) http.HandlerFunc {
 	}
 }
 
+// metricsHandler exposes the worker pool's queue depth in the Prometheus
+// text format.
+func metricsHandler(pool *ingestion.WorkerPool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		stats := pool.Stats()
+		tenants := make([]string, 0, len(stats.Tenants))
+		for id := range stats.Tenants {
+			tenants = append(tenants, id)
+		}
+		sort.Strings(tenants)
+
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		fmt.Fprintln(w, "# HELP toposcope_ingestion_queued Ingestions waiting for a worker.")
+		fmt.Fprintln(w, "# TYPE toposcope_ingestion_queued gauge")
+		fmt.Fprintf(w, "toposcope_ingestion_queued %d\n", stats.Queued)
+		fmt.Fprintln(w, "# TYPE toposcope_ingestion_tenant_queued gauge")
+		for _, id := range tenants {
+			fmt.Fprintf(w, "toposcope_ingestion_tenant_queued{tenant=%q} %d\n", id, stats.Tenants[id].Queued)
+		}
+		fmt.Fprintln(w, "# HELP toposcope_ingestion_running Ingestions being processed.")
+		fmt.Fprintln(w, "# TYPE toposcope_ingestion_running gauge")
+		fmt.Fprintf(w, "toposcope_ingestion_running %d\n", stats.Running)
+		fmt.Fprintln(w, "# TYPE toposcope_ingestion_tenant_running gauge")
+		for _, id := range tenants {
+			fmt.Fprintf(w, "toposcope_ingestion_tenant_running{tenant=%q} %d\n", id, stats.Tenants[id].Running)
+		}
+		fmt.Fprintln(w, "# TYPE toposcope_ingestion_accepted_total counter")
+		fmt.Fprintf(w, "toposcope_ingestion_accepted_total %d\n", stats.Accepted)
+		fmt.Fprintln(w, "# TYPE toposcope_ingestion_rejected_total counter")
+		fmt.Fprintf(w, "toposcope_ingestion_rejected_total %d\n", stats.Rejected)
+		fmt.Fprintln(w, "# TYPE toposcope_ingestion_failed_total counter")
+		fmt.Fprintf(w, "toposcope_ingestion_failed_total %d\n", stats.Failed)
+	}
+}
+
 func healthHandler(db *sql.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if err := db.PingContext(r.Context()); err != nil {
@@ -208,9 +657,130 @@ func healthHandler(db *sql.DB) http.HandlerFunc {
 	}
 }
 
+// readyHandler reports whether the database and storage backend are both
+// reachable. Unlike /healthz, it fails when storage does, so load balancers
+// stop routing ingests to an instance that cannot store them.
+func readyHandler(db *sql.DB, storage ingestion.StorageClient) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		checks := map[string]string{"database": "ok", "storage": "ok"}
+		status := http.StatusOK
+		if err := db.PingContext(r.Context()); err != nil {
+			checks["database"] = err.Error()
+			status = http.StatusServiceUnavailable
+		}
+		if err := pingStorage(r.Context(), storage); err != nil {
+			checks["storage"] = err.Error()
+			status = http.StatusServiceUnavailable
+		}
+
+		resp := map[string]any{"status": "ok", "checks": checks}
+		if status != http.StatusOK {
+			resp["status"] = "unavailable"
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		_ = json.NewEncoder(w).Encode(resp)
+	}
+}
+
+// pingStorage pings storage with a bounded timeout.
+func pingStorage(ctx context.Context, storage ingestion.StorageClient) error {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	return storage.Ping(ctx)
+}
+
+// runPurgeLoop purges soft-deleted repositories and tenants older than
+// retention every interval until ctx is done.
+func runPurgeLoop(ctx context.Context, svc *ingestion.Service, interval, retention time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		res, err := svc.Purge(ctx, ingestion.PurgeOptions{RetainFor: retention})
+		if err != nil {
+			log.Printf("purge: %v", err)
+			continue
+		}
+		if len(res.Repos) > 0 || len(res.Tenants) > 0 || len(res.Skipped) > 0 {
+			log.Printf("purge: removed %d repositories, %d tenants, %d blobs; %d repositories skipped",
+				len(res.Repos), len(res.Tenants), res.BlobsDeleted, len(res.Skipped))
+		}
+	}
+}
+
+// runDriftLoop compares every installed repository's baseline with its
+// default branch each interval until ctx is cancelled.
+func runDriftLoop(ctx context.Context, svc *ingestion.Service, interval time.Duration, opts ingestion.DriftOptions) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		reports, err := svc.CheckDrift(ctx, opts)
+		if err != nil {
+			log.Printf("drift check: %v", err)
+			continue
+		}
+		for _, rep := range reports {
+			switch {
+			case rep.Refreshed:
+				log.Printf("drift check: %s baseline drifted %.1f%% from %s; refreshed to %s",
+					rep.FullName, 100*rep.Drift, rep.BaselineCommit, rep.HeadCommit)
+			case rep.Drifted:
+				log.Printf("drift check: %s baseline drifted %.1f%% from %s (default branch at %s)",
+					rep.FullName, 100*rep.Drift, rep.BaselineCommit, rep.HeadCommit)
+			}
+		}
+	}
+}
+
 func envOrDefault(key, defaultVal string) string {
 	if v := os.Getenv(key); v != "" {
 		return v
 	}
 	return defaultVal
 }
+
+func envInt(key string, defaultVal int) int {
+	if v := os.Getenv(key); v != "" {
+		if parsed, err := strconv.Atoi(v); err == nil && parsed >= 0 {
+			return parsed
+		}
+	}
+	return defaultVal
+}
+
+func envDuration(key string, defaultVal time.Duration) time.Duration {
+	if v := os.Getenv(key); v != "" {
+		if parsed, err := time.ParseDuration(v); err == nil && parsed >= 0 {
+			return parsed
+		}
+	}
+	return defaultVal
+}
+
+func envFloat(key string, defaultVal float64) float64 {
+	if v := os.Getenv(key); v != "" {
+		if parsed, err := strconv.ParseFloat(v, 64); err == nil && parsed >= 0 {
+			return parsed
+		}
+	}
+	return defaultVal
+}
+
+func firstNonEmpty(vals ...string) string {
+	for _, v := range vals {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -59,6 +59,18 @@ Service account name.
 {{- end }}
 {{- end }}
 
+{{/*
+Extraction job service account name.
+*/}}
+{{- define "toposcope.extractionServiceAccountName" -}}
+{{- $sa := .Values.extraction.job.serviceAccount }}
+{{- if $sa.create }}
+{{- default (printf "%s-extract" (include "toposcope.fullname" .)) $sa.name }}
+{{- else }}
+{{- default "default" $sa.name }}
+{{- end }}
+{{- end }}
+
 {{/*
 Database URL.
 */}}
@@ -2,7 +2,15 @@ package api
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+
+	"github.com/toposcope/toposcope/internal/ingestion"
+	"github.com/toposcope/toposcope/internal/tenant"
+	"github.com/toposcope/toposcope/pkg/scoring"
 )
 
 type updateRepoRequest struct {
@@ -11,6 +19,9 @@ type updateRepoRequest struct {
 
 func (h *Handler) handleUpdateRepo(w http.ResponseWriter, r *http.Request) {
 	repoID := r.PathValue("repoID")
+	if !h.authorizeRepo(w, r, repoID) {
+		return
+	}
 
 	var req updateRepoRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -28,16 +39,296 @@ func (h *Handler) handleUpdateRepo(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	writeJSON(w, http.StatusOK, map[string]string{"status": "updated"})
+	writeJSON(w, http.StatusOK, statusResponse{Status: "updated"})
+}
+
+// repoSettingsResponse is the JSON body for GET/PATCH /api/repos/{repoID}/settings.
+// Effective grade thresholds are always reported, falling back to defaults.
+type repoSettingsResponse struct {
+	GradeThresholds scoring.GradeThresholds `json:"grade_thresholds"`
+	Custom          bool                    `json:"custom"` // true if the repo overrides the defaults
+	Boundaries      []string                `json:"boundaries,omitempty"`
+	FailOn          string                  `json:"fail_on,omitempty"`
+	KeepPRScores    *int                    `json:"keep_pr_scores,omitempty"` // unset: server default
+	// ExtractionTimeout is in seconds; unset uses the server's timeout.
+	ExtractionTimeout int               `json:"extraction_timeout_seconds,omitempty"`
+	Webhooks          []webhookResponse `json:"webhooks,omitempty"`
+	// SigningKeys lists the IDs of the keys trusted to sign scores.
+	SigningKeys         []string `json:"signing_keys,omitempty"`
+	RequireSignedScores bool     `json:"require_signed_scores,omitempty"`
+}
+
+// webhookRequest is a webhook in a settings update. A nil Secret keeps the
+// secret stored for the URL; "" removes it.
+type webhookRequest struct {
+	URL    string  `json:"url"`
+	Secret *string `json:"secret,omitempty"`
+}
+
+// webhookResponse describes a configured webhook without revealing its
+// secret.
+type webhookResponse struct {
+	URL    string `json:"url"`
+	Signed bool   `json:"signed"`
+}
+
+type updateRepoSettingsRequest struct {
+	GradeThresholds *scoring.GradeThresholds `json:"grade_thresholds"`
+	Reset           bool                     `json:"reset"` // revert to default thresholds
+	KeepPRScores    *int                     `json:"keep_pr_scores"`
+	// ExtractionTimeout is in seconds; 0 reverts to the server's timeout.
+	ExtractionTimeout *int              `json:"extraction_timeout_seconds"`
+	Webhooks          *[]webhookRequest `json:"webhooks"` // replaces the list; [] removes every webhook
+	// SigningKeys are ed25519 public keys, PEM or base64. They replace the
+	// list; [] removes every key.
+	SigningKeys         *[]string `json:"signing_keys"`
+	RequireSignedScores *bool     `json:"require_signed_scores"`
+}
+
+func repoSettingsToResponse(settings *tenant.RepoSettings) repoSettingsResponse {
+	resp := repoSettingsResponse{
+		GradeThresholds:     settings.Grades(),
+		Custom:              settings.GradeThresholds != nil,
+		Boundaries:          settings.Boundaries,
+		FailOn:              settings.FailOn,
+		KeepPRScores:        settings.KeepPRScores,
+		ExtractionTimeout:   settings.ExtractionTimeout,
+		RequireSignedScores: settings.RequireSignedScores,
+	}
+	for _, key := range settings.VerifyKeys() {
+		resp.SigningKeys = append(resp.SigningKeys, scoring.KeyID(key))
+	}
+	for _, hook := range settings.Webhooks {
+		resp.Webhooks = append(resp.Webhooks, webhookResponse{URL: hook.URL, Signed: hook.Secret != ""})
+	}
+	return resp
+}
+
+// validateWebhook checks that a webhook URL is an absolute http(s) URL and
+// doesn't name an internal address outright. Hostnames are checked when
+// deliveries connect (see ingestion.NewWebhookClient), since what they
+// resolve to can change.
+func validateWebhook(hook webhookRequest) error {
+	u, err := url.Parse(hook.URL)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return fmt.Errorf("webhook url %q must be an absolute http or https URL", hook.URL)
+	}
+	host := u.Hostname()
+	if addr, err := netip.ParseAddr(host); (err == nil && ingestion.BlockedWebhookAddr(addr)) || strings.EqualFold(host, "localhost") {
+		return fmt.Errorf("webhook url %q must not point at an internal address", hook.URL)
+	}
+	return nil
+}
+
+// mergeWebhooks returns the webhooks of an update. A webhook that leaves out
+// its secret keeps the one stored for the same URL.
+func mergeWebhooks(stored []tenant.Webhook, update []webhookRequest) []tenant.Webhook {
+	secrets := make(map[string]string, len(stored))
+	for _, hook := range stored {
+		secrets[hook.URL] = hook.Secret
+	}
+	hooks := make([]tenant.Webhook, 0, len(update))
+	for _, hook := range update {
+		secret := secrets[hook.URL]
+		if hook.Secret != nil {
+			secret = *hook.Secret
+		}
+		hooks = append(hooks, tenant.Webhook{URL: hook.URL, Secret: secret})
+	}
+	return hooks
+}
+
+func (h *Handler) handleGetRepoSettings(w http.ResponseWriter, r *http.Request) {
+	repoID := r.PathValue("repoID")
+	if !h.authorizeRepo(w, r, repoID) {
+		return
+	}
+
+	settings, err := h.tenantSvc.GetRepoSettings(r.Context(), repoID)
+	if err != nil {
+		if strings.Contains(err.Error(), "no rows") {
+			writeError(w, http.StatusNotFound, "repository not found")
+		} else {
+			writeError(w, http.StatusInternalServerError, "failed to load settings: "+err.Error())
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusOK, repoSettingsToResponse(settings))
+}
+
+func (h *Handler) handleUpdateRepoSettings(w http.ResponseWriter, r *http.Request) {
+	repoID := r.PathValue("repoID")
+	if !h.authorizeRepo(w, r, repoID) {
+		return
+	}
+
+	var req updateRepoSettingsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body: "+err.Error())
+		return
+	}
+
+	settings, err := h.tenantSvc.GetRepoSettings(r.Context(), repoID)
+	if err != nil {
+		if strings.Contains(err.Error(), "no rows") {
+			writeError(w, http.StatusNotFound, "repository not found")
+		} else {
+			writeError(w, http.StatusInternalServerError, "failed to load settings: "+err.Error())
+		}
+		return
+	}
+
+	switch {
+	case req.Reset:
+		settings.GradeThresholds = nil
+	case req.GradeThresholds != nil:
+		if err := req.GradeThresholds.Validate(); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		settings.GradeThresholds = req.GradeThresholds
+	}
+	if req.KeepPRScores != nil {
+		if *req.KeepPRScores < 0 {
+			writeError(w, http.StatusBadRequest, "keep_pr_scores must not be negative")
+			return
+		}
+		settings.KeepPRScores = req.KeepPRScores
+	}
+	if req.ExtractionTimeout != nil {
+		if *req.ExtractionTimeout < 0 {
+			writeError(w, http.StatusBadRequest, "extraction_timeout_seconds must not be negative")
+			return
+		}
+		settings.ExtractionTimeout = *req.ExtractionTimeout
+	}
+	if req.Webhooks != nil {
+		for _, hook := range *req.Webhooks {
+			if err := validateWebhook(hook); err != nil {
+				writeError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+		}
+		settings.Webhooks = mergeWebhooks(settings.Webhooks, *req.Webhooks)
+	}
+	if req.SigningKeys != nil {
+		for _, key := range *req.SigningKeys {
+			if _, err := scoring.ParseVerifyKey(key); err != nil {
+				writeError(w, http.StatusBadRequest, "signing_keys: "+err.Error())
+				return
+			}
+		}
+		settings.SigningKeys = *req.SigningKeys
+	}
+	if req.RequireSignedScores != nil {
+		settings.RequireSignedScores = *req.RequireSignedScores
+	}
+	if settings.RequireSignedScores && len(settings.SigningKeys) == 0 {
+		writeError(w, http.StatusBadRequest, "require_signed_scores needs at least one signing key")
+		return
+	}
+
+	if err := h.tenantSvc.UpdateRepoSettings(r.Context(), repoID, settings); err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to update settings: "+err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, repoSettingsToResponse(settings))
 }
 
+type updateLabelsRequest struct {
+	Labels []string `json:"labels"`
+}
+
+type labelsResponse struct {
+	ID     string   `json:"id"`
+	Labels []string `json:"labels"`
+}
+
+// decodeLabels parses and normalizes a label update body, writing an error
+// response and returning false if it is invalid.
+func decodeLabels(w http.ResponseWriter, r *http.Request) (tenant.Labels, bool) {
+	var req updateLabelsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body: "+err.Error())
+		return nil, false
+	}
+	labels, err := tenant.NormalizeLabels(req.Labels)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return nil, false
+	}
+	return labels, true
+}
+
+// handleUpdateSnapshotLabels handles PATCH /api/snapshots/{snapshotID}/labels,
+// replacing the snapshot's labels.
+func (h *Handler) handleUpdateSnapshotLabels(w http.ResponseWriter, r *http.Request) {
+	snapshotID := r.PathValue("snapshotID")
+	if !h.authorizeSnapshot(w, r, snapshotID) {
+		return
+	}
+
+	labels, ok := decodeLabels(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.tenantSvc.SetSnapshotLabels(r.Context(), snapshotID, labels); err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			writeError(w, http.StatusNotFound, "snapshot not found")
+		} else {
+			writeError(w, http.StatusInternalServerError, "failed to update labels: "+err.Error())
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusOK, labelsResponse{ID: snapshotID, Labels: labels})
+}
+
+// handleUpdateScoreLabels handles PATCH /api/repos/{repoID}/scores/{scoreID}/labels,
+// replacing the score's labels.
+func (h *Handler) handleUpdateScoreLabels(w http.ResponseWriter, r *http.Request) {
+	repoID := r.PathValue("repoID")
+	scoreID := r.PathValue("scoreID")
+	if !h.authorizeScore(w, r, scoreID) {
+		return
+	}
+
+	labels, ok := decodeLabels(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.tenantSvc.SetScoreLabels(r.Context(), repoID, scoreID, labels); err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			writeError(w, http.StatusNotFound, "score not found")
+		} else {
+			writeError(w, http.StatusInternalServerError, "failed to update labels: "+err.Error())
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusOK, labelsResponse{ID: scoreID, Labels: labels})
+}
+
+// handleDeleteRepo soft-deletes a repository. Its data is removed by the
+// purge job once the retention window has passed.
 func (h *Handler) handleDeleteRepo(w http.ResponseWriter, r *http.Request) {
 	repoID := r.PathValue("repoID")
+	if !h.authorizeRepo(w, r, repoID) {
+		return
+	}
 
 	if err := h.tenantSvc.DeleteRepo(r.Context(), repoID); err != nil {
-		writeError(w, http.StatusInternalServerError, "failed to delete repository: "+err.Error())
+		if strings.Contains(err.Error(), "not found") {
+			writeError(w, http.StatusNotFound, "repository not found")
+		} else {
+			writeError(w, http.StatusInternalServerError, "failed to delete repository: "+err.Error())
+		}
 		return
 	}
 
-	writeJSON(w, http.StatusOK, map[string]string{"status": "deleted"})
+	writeJSON(w, http.StatusOK, statusResponse{Status: "deleted"})
 }
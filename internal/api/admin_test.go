@@ -0,0 +1,51 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/toposcope/toposcope/internal/tenant"
+)
+
+func TestValidateWebhook(t *testing.T) {
+	for url, ok := range map[string]bool{
+		"https://hooks.example.com/toposcope": true,
+		"http://10.0.0.5:8080/hook":           false,
+		"http://169.254.169.254/latest":       false,
+		"http://[::1]/hook":                   false,
+		"http://localhost:9000/hook":          false,
+		"ftp://hooks.example.com":             false,
+		"/relative":                           false,
+	} {
+		if err := validateWebhook(webhookRequest{URL: url}); (err == nil) != ok {
+			t.Errorf("validateWebhook(%s) = %v, want ok=%v", url, err, ok)
+		}
+	}
+}
+
+func TestMergeWebhooks(t *testing.T) {
+	stored := []tenant.Webhook{
+		{URL: "https://a.example.com", Secret: "sa"},
+		{URL: "https://b.example.com", Secret: "sb"},
+	}
+	newSecret, none := "sa2", ""
+	got := mergeWebhooks(stored, []webhookRequest{
+		{URL: "https://a.example.com"},                     // keeps sa
+		{URL: "https://b.example.com", Secret: &none},      // removes sb
+		{URL: "https://c.example.com", Secret: &newSecret}, // new
+		{URL: "https://d.example.com"},                     // new, unsigned
+	})
+	want := []tenant.Webhook{
+		{URL: "https://a.example.com", Secret: "sa"},
+		{URL: "https://b.example.com"},
+		{URL: "https://c.example.com", Secret: "sa2"},
+		{URL: "https://d.example.com"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("webhook %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
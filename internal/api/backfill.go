@@ -0,0 +1,113 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/toposcope/toposcope/internal/ingestion"
+)
+
+type createBackfillRequest struct {
+	// Commits are the commits to extract, oldest first. If omitted, the
+	// default branch's first-parent history since Since is listed from
+	// GitHub, which needs the GitHub App.
+	Commits []string `json:"commits,omitempty"`
+	Since   string   `json:"since,omitempty"` // RFC 3339
+	// Every keeps every n-th commit, always including the first and last.
+	Every       int `json:"every,omitempty"`
+	Concurrency int `json:"concurrency,omitempty"` // default 2, at most 16
+	PerHour     int `json:"per_hour,omitempty"`    // extractions started per hour; 0 = no limit
+}
+
+// handleCreateBackfill handles POST /api/v2/repos/{repoID}/backfills. It
+// queues a backfill job that extracts and scores sampled default-branch
+// history on the platform's extraction workers.
+func (h *Handler) handleCreateBackfill(w http.ResponseWriter, r *http.Request) {
+	repoID := r.PathValue("repoID")
+	if !h.authorizeRepo(w, r, repoID) {
+		return
+	}
+
+	var req createBackfillRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body: "+err.Error())
+		return
+	}
+	br := ingestion.BackfillRequest{
+		RepoID:      repoID,
+		Commits:     req.Commits,
+		Every:       req.Every,
+		Concurrency: req.Concurrency,
+		PerHour:     req.PerHour,
+	}
+	if len(req.Commits) == 0 {
+		since, err := time.Parse(time.RFC3339, req.Since)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "commits or since (RFC 3339) is required")
+			return
+		}
+		br.Since = since
+	}
+
+	job, err := h.ingestionSvc.CreateBackfill(r.Context(), br)
+	if errors.Is(err, ingestion.ErrBackfillActive) {
+		writeError(w, http.StatusConflict, err.Error())
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "failed to create backfill: "+err.Error())
+		return
+	}
+	writeJSON(w, http.StatusAccepted, job)
+}
+
+// handleListBackfills handles GET /api/v2/repos/{repoID}/backfills.
+func (h *Handler) handleListBackfills(w http.ResponseWriter, r *http.Request) {
+	repoID := r.PathValue("repoID")
+	if !h.authorizeRepo(w, r, repoID) {
+		return
+	}
+	jobs, err := h.ingestionSvc.ListBackfills(r.Context(), repoID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to list backfills: "+err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, jobs)
+}
+
+// handleGetBackfill handles GET /api/v2/repos/{repoID}/backfills/{jobID}.
+func (h *Handler) handleGetBackfill(w http.ResponseWriter, r *http.Request) {
+	repoID := r.PathValue("repoID")
+	if !h.authorizeRepo(w, r, repoID) {
+		return
+	}
+	job, err := h.ingestionSvc.GetBackfill(r.Context(), r.PathValue("jobID"))
+	if err != nil || job.RepoID != repoID {
+		writeError(w, http.StatusNotFound, "backfill not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, job)
+}
+
+// handleCancelBackfill handles DELETE /api/v2/repos/{repoID}/backfills/{jobID}.
+// Scores already stored are kept.
+func (h *Handler) handleCancelBackfill(w http.ResponseWriter, r *http.Request) {
+	repoID := r.PathValue("repoID")
+	if !h.authorizeRepo(w, r, repoID) {
+		return
+	}
+	ctx := r.Context()
+	jobID := r.PathValue("jobID")
+	if job, err := h.ingestionSvc.GetBackfill(ctx, jobID); err != nil || job.RepoID != repoID {
+		writeError(w, http.StatusNotFound, "backfill not found")
+		return
+	}
+	job, err := h.ingestionSvc.CancelBackfill(ctx, jobID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to cancel backfill: "+err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, job)
+}
@@ -0,0 +1,165 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/toposcope/toposcope/internal/ingestion"
+	"github.com/toposcope/toposcope/internal/tenant"
+	"github.com/toposcope/toposcope/pkg/bundle"
+	"github.com/toposcope/toposcope/pkg/graph"
+	"github.com/toposcope/toposcope/pkg/scoring"
+)
+
+// maxBundleBytes bounds the size of an uploaded bundle.
+const maxBundleBytes = 1 << 30
+
+type bundleImportResponse struct {
+	Snapshots   int               `json:"snapshots"`
+	Deltas      int               `json:"deltas"`
+	Scores      int               `json:"scores"`
+	SnapshotIDs map[string]string `json:"snapshot_ids"` // commit SHA -> snapshot ID
+}
+
+// handleImportBundle handles POST /api/v1/bundles. The body is a bundle
+// written by `toposcope bundle export`; its snapshots, deltas, and scores are
+// stored as if each commit had been ingested. Imports record history only:
+// the repository baseline is left unchanged.
+func (h *Handler) handleImportBundle(w http.ResponseWriter, r *http.Request) {
+	b, err := bundle.Read(http.MaxBytesReader(w, r.Body, maxBundleBytes))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid bundle: "+err.Error())
+		return
+	}
+	if b.Repo == "" {
+		writeError(w, http.StatusBadRequest, "bundle does not name a repository")
+		return
+	}
+
+	ctx := r.Context()
+	defaultBranch := b.DefaultBranch
+	tenantID, repoID, ok := h.resolveIngestRepo(w, r, b.Repo, &defaultBranch)
+	if !ok {
+		return
+	}
+
+	settings, err := h.tenantSvc.GetRepoSettings(ctx, repoID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to load repo settings: "+err.Error())
+		return
+	}
+	scores, err := verifyBundleScores(b, settings, time.Now())
+	if err != nil {
+		writeError(w, http.StatusForbidden, err.Error())
+		return
+	}
+	if !h.admitIngestion(w, r, tenantID, len(b.Commits)) {
+		return
+	}
+	grades := settings.Grades()
+
+	ingestFor := func(sha string) ingestion.IngestionRequest {
+		req := ingestion.IngestionRequest{
+			TenantID:     tenantID,
+			RepoID:       repoID,
+			RepoFullName: b.Repo,
+			CommitSHA:    sha,
+			BaseBranch:   defaultBranch,
+		}
+		if c := b.Commit(sha); c != nil && c.CommittedAt != "" {
+			if t, err := time.Parse(time.RFC3339, c.CommittedAt); err == nil {
+				req.CommittedAt = &t
+			}
+		}
+		return req
+	}
+
+	resp := bundleImportResponse{SnapshotIDs: make(map[string]string, len(b.Commits))}
+	for _, c := range b.Commits {
+		snap := c.Snapshot
+		snap.CommitSHA = c.SHA
+		if snap.ID == "" {
+			snap.ID = graph.ContentID(snap)
+		}
+		data, err := json.Marshal(snap)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "failed to marshal snapshot: "+err.Error())
+			return
+		}
+		id, err := h.ingestionSvc.StoreSnapshot(ctx, ingestFor(c.SHA), snap, data)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "failed to store snapshot: "+err.Error())
+			return
+		}
+		resp.SnapshotIDs[c.SHA] = id
+		resp.Snapshots++
+	}
+
+	for i, c := range b.Changes {
+		req := ingestFor(c.HeadSHA)
+		baseID, headID := resp.SnapshotIDs[c.BaseSHA], resp.SnapshotIDs[c.HeadSHA]
+
+		delta := c.Delta
+		if delta.ID == "" {
+			delta.ID = uuid.New().String()
+		}
+		delta.BaseSnapshotID, delta.HeadSnapshotID = baseID, headID
+		data, err := json.Marshal(delta)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "failed to marshal delta: "+err.Error())
+			return
+		}
+		deltaID, err := h.ingestionSvc.StoreDelta(ctx, req, delta, data)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "failed to store delta: "+err.Error())
+			return
+		}
+		resp.Deltas++
+
+		score := scores[i]
+		if score.score == nil {
+			continue
+		}
+		regrade(score.score, grades)
+		req.Provenance = score.provenance
+		if _, err := h.ingestionSvc.StoreScore(ctx, req, baseID, headID, deltaID, score.score); err != nil {
+			writeError(w, http.StatusInternalServerError, "failed to store score: "+err.Error())
+			return
+		}
+		resp.Scores++
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// bundleScore is a change's score as it will be stored.
+type bundleScore struct {
+	score      *scoring.ScoreResult // nil if the change has none
+	provenance *scoring.Provenance
+}
+
+// verifyBundleScores applies the repository's signing rules to the score of
+// every change, as ingest does for a single upload, and returns the scores
+// to store, one per change. It checks them all before anything is stored,
+// so a rejected bundle imports nothing.
+func verifyBundleScores(b *bundle.Bundle, settings *tenant.RepoSettings, now time.Time) ([]bundleScore, error) {
+	scores := make([]bundleScore, len(b.Changes))
+	for i, c := range b.Changes {
+		if c.Score == nil && c.SignedScore == nil {
+			continue
+		}
+		subject := scoreSubject{repo: b.Repo, commitSHA: c.HeadSHA, baseCommitSHA: c.BaseSHA}
+		if head := b.Commit(c.HeadSHA); head != nil {
+			subject.snapshotID = graph.ContentID(head.Snapshot)
+		}
+		score, provenance, err := verifyScore(settings, subject, c.Score, c.SignedScore, now)
+		if err != nil {
+			return nil, fmt.Errorf("score for %s: %w", c.HeadSHA, err)
+		}
+		scores[i] = bundleScore{score: score, provenance: provenance}
+	}
+	return scores, nil
+}
@@ -6,6 +6,7 @@ import (
 	"sync"
 
 	"github.com/toposcope/toposcope/pkg/graph"
+	"github.com/toposcope/toposcope/pkg/graphquery"
 )
 
 // SnapshotCache is a thread-safe LRU cache for loaded graph snapshots.
@@ -89,3 +90,46 @@ func (c *SnapshotCache) moveToEnd(id string) {
 		}
 	}
 }
+
+// layoutCache keeps laid-out package graphs, which take far longer to
+// compute than to aggregate, keyed by ETag. The oldest entry is evicted when
+// it is full.
+type layoutCache struct {
+	mu      sync.Mutex
+	maxSize int
+	entries map[string]*graphquery.PackageGraphResult
+	order   []string // oldest first
+}
+
+func newLayoutCache(maxSize int) *layoutCache {
+	return &layoutCache{
+		maxSize: maxSize,
+		entries: make(map[string]*graphquery.PackageGraphResult),
+	}
+}
+
+func (c *layoutCache) get(key string) *graphquery.PackageGraphResult {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.entries[key]
+}
+
+func (c *layoutCache) put(key string, result *graphquery.PackageGraphResult) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.entries[key]; !ok {
+		for len(c.entries) >= c.maxSize && len(c.order) > 0 {
+			delete(c.entries, c.order[0])
+			c.order = c.order[1:]
+		}
+		c.order = append(c.order, key)
+	}
+	c.entries[key] = result
+}
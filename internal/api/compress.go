@@ -0,0 +1,134 @@
+package api
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// compressed wraps a handler of large JSON responses (graphs) so it
+// compresses them with zstd or gzip, whichever the client prefers in
+// Accept-Encoding. Graph JSON shrinks about tenfold.
+func compressed(handle http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept-Encoding")
+		encoding := negotiateEncoding(r.Header.Get("Accept-Encoding"))
+		if encoding == "" {
+			handle(w, r)
+			return
+		}
+
+		// Each encoding is a different representation, so it gets its own
+		// strong ETag. Strip the suffix from the client's tags so the
+		// handler compares them against its own.
+		if inm := r.Header.Get("If-None-Match"); inm != "" {
+			r.Header.Set("If-None-Match", strings.ReplaceAll(inm, "-"+encoding+`"`, `"`))
+		}
+
+		cw := &compressWriter{ResponseWriter: w, encoding: encoding}
+		defer cw.close()
+		handle(cw, r)
+	}
+}
+
+// negotiateEncoding picks zstd or gzip from an Accept-Encoding header, or ""
+// to send the response uncompressed. Ties go to zstd.
+func negotiateEncoding(header string) string {
+	q := map[string]float64{}
+	for _, item := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(item), ";")
+		weight := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if f, err := strconv.ParseFloat(v, 64); err == nil {
+				weight = f
+			}
+		}
+		q[strings.ToLower(strings.TrimSpace(name))] = weight
+	}
+
+	best, bestQ := "", 0.0
+	for _, enc := range []string{"zstd", "gzip"} {
+		weight, ok := q[enc]
+		if !ok {
+			weight, ok = q["*"]
+		}
+		if ok && weight > bestQ {
+			best, bestQ = enc, weight
+		}
+	}
+	return best
+}
+
+var (
+	gzipWriters = sync.Pool{New: func() any {
+		gz, _ := gzip.NewWriterLevel(nil, gzip.DefaultCompression)
+		return gz
+	}}
+	zstdWriters = sync.Pool{New: func() any {
+		zw, _ := zstd.NewWriter(nil, zstd.WithEncoderConcurrency(1))
+		return zw
+	}}
+)
+
+// compressWriter compresses the body written through it, unless the
+// response has none.
+type compressWriter struct {
+	http.ResponseWriter
+	encoding    string
+	enc         io.WriteCloser
+	wroteHeader bool
+}
+
+func (c *compressWriter) WriteHeader(status int) {
+	if c.wroteHeader {
+		return
+	}
+	c.wroteHeader = true
+
+	h := c.Header()
+	if tag := h.Get("ETag"); strings.HasSuffix(tag, `"`) {
+		h.Set("ETag", strings.TrimSuffix(tag, `"`)+"-"+c.encoding+`"`)
+	}
+	if status != http.StatusNotModified && status != http.StatusNoContent && h.Get("Content-Encoding") == "" {
+		h.Set("Content-Encoding", c.encoding)
+		h.Del("Content-Length")
+		switch c.encoding {
+		case "zstd":
+			zw := zstdWriters.Get().(*zstd.Encoder)
+			zw.Reset(c.ResponseWriter)
+			c.enc = zw
+		case "gzip":
+			gz := gzipWriters.Get().(*gzip.Writer)
+			gz.Reset(c.ResponseWriter)
+			c.enc = gz
+		}
+	}
+	c.ResponseWriter.WriteHeader(status)
+}
+
+func (c *compressWriter) Write(p []byte) (int, error) {
+	if !c.wroteHeader {
+		c.WriteHeader(http.StatusOK)
+	}
+	if c.enc == nil {
+		return c.ResponseWriter.Write(p)
+	}
+	return c.enc.Write(p)
+}
+
+// close flushes the compressed stream and returns its encoder to the pool.
+func (c *compressWriter) close() {
+	switch enc := c.enc.(type) {
+	case *zstd.Encoder:
+		_ = enc.Close()
+		zstdWriters.Put(enc)
+	case *gzip.Writer:
+		_ = enc.Close()
+		gzipWriters.Put(enc)
+	}
+}
@@ -0,0 +1,90 @@
+package api
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+func TestNegotiateEncoding(t *testing.T) {
+	for header, want := range map[string]string{
+		"":                            "",
+		"identity":                    "",
+		"gzip":                        "gzip",
+		"gzip, deflate, br, zstd":     "zstd",
+		"zstd;q=0.5, gzip":            "gzip",
+		"GZIP;q=0.8":                  "gzip",
+		"*":                           "zstd",
+		"*;q=0.1, gzip;q=0":           "zstd",
+		"gzip;q=0, zstd;q=0":          "",
+		"br;q=1.0, gzip;q=0.2, *;q=0": "gzip",
+	} {
+		if got := negotiateEncoding(header); got != want {
+			t.Errorf("negotiateEncoding(%q) = %q, want %q", header, got, want)
+		}
+	}
+}
+
+func TestCompressed(t *testing.T) {
+	body := strings.Repeat(`{"key":"//app/web:server","kind":"go_library"},`, 200)
+	handle := compressed(func(w http.ResponseWriter, r *http.Request) {
+		etag := `"s1"`
+		if notModified(w, r, etag, revalidateCache) {
+			return
+		}
+		w.Header().Set("ETag", etag)
+		_, _ = io.WriteString(w, body)
+	})
+
+	decoders := map[string]func(io.Reader) (io.Reader, error){
+		"":     func(r io.Reader) (io.Reader, error) { return r, nil },
+		"gzip": func(r io.Reader) (io.Reader, error) { return gzip.NewReader(r) },
+		"zstd": func(r io.Reader) (io.Reader, error) { return zstd.NewReader(r) },
+	}
+	for encoding, decode := range decoders {
+		r := httptest.NewRequest("GET", "/api/v2/snapshots/s1", nil)
+		r.Header.Set("Accept-Encoding", encoding)
+		w := httptest.NewRecorder()
+		handle(w, r)
+
+		if got := w.Header().Get("Content-Encoding"); got != encoding {
+			t.Errorf("%q: Content-Encoding = %q", encoding, got)
+		}
+		if w.Header().Get("Vary") != "Accept-Encoding" {
+			t.Errorf("%q: Vary = %q", encoding, w.Header().Get("Vary"))
+		}
+		if encoding != "" && w.Body.Len() >= len(body)/5 {
+			t.Errorf("%q: %d bytes compressed from %d", encoding, w.Body.Len(), len(body))
+		}
+		dec, err := decode(w.Body)
+		if err != nil {
+			t.Fatalf("%q: %v", encoding, err)
+		}
+		got, err := io.ReadAll(dec)
+		if err != nil || string(got) != body {
+			t.Errorf("%q: decoded body differs (err %v)", encoding, err)
+		}
+
+		etag := w.Header().Get("ETag")
+		want := `"s1"`
+		if encoding != "" {
+			want = `"s1-` + encoding + `"`
+		}
+		if etag != want {
+			t.Errorf("%q: ETag = %s, want %s", encoding, etag, want)
+		}
+
+		r.Header.Set("If-None-Match", etag)
+		w = httptest.NewRecorder()
+		handle(w, r)
+		if w.Code != http.StatusNotModified || w.Body.Len() != 0 || w.Header().Get("Content-Encoding") != "" {
+			t.Errorf("%q revalidation: status %d, %d bytes, Content-Encoding %q",
+				encoding, w.Code, w.Body.Len(), w.Header().Get("Content-Encoding"))
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package api
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/toposcope/toposcope/internal/tenant"
+	"github.com/toposcope/toposcope/pkg/graph"
+	"github.com/toposcope/toposcope/pkg/graphquery"
+)
+
+// handleDeltaGraph handles GET /api/v1/deltas/{deltaID}/graph.
+// It returns the changed nodes and edges merged with their unchanged
+// neighborhood in the head snapshot, each annotated with a status, so the UI
+// can draw a before/after view without fetching both snapshots.
+func (h *Handler) handleDeltaGraph(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	deltaID := r.PathValue("deltaID")
+
+	row, err := h.tenantSvc.GetDeltaByID(ctx, deltaID)
+	if err != nil || !CallerFrom(ctx).Owns(row.TenantID) {
+		writeError(w, http.StatusNotFound, "delta not found")
+		return
+	}
+	head, err := h.tenantSvc.GetSnapshotByID(ctx, row.HeadSnapshotID)
+	if err != nil {
+		writeError(w, http.StatusNotFound, "head snapshot not found")
+		return
+	}
+	etag := queryETag(deltaVersion(row, head), r)
+	if notModified(w, r, etag, revalidateCache) {
+		return
+	}
+
+	result, err := h.deltaGraph(ctx, row, r.URL.Query())
+	if err != nil {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	writeCached(w, etag, revalidateCache, result)
+}
+
+// deltaVersion identifies the content behind a delta graph: the delta blob,
+// which gets a new ID whenever the pair is stored again, and the version of
+// the head snapshot it is drawn on.
+func deltaVersion(row *tenant.DeltaRow, head *tenant.SnapshotRow) string {
+	id := row.ID
+	if row.StorageRef != "" {
+		id = storageIDFromRef(row.StorageRef)
+	}
+	sum := sha256.Sum256([]byte(id + "\n" + snapshotVersion(head)))
+	return hex.EncodeToString(sum[:16])
+}
+
+// deltaGraph builds the delta graph of row, reading depth (default 1),
+// max_nodes (default 500), hide_tests, and hide_external from q.
+func (h *Handler) deltaGraph(ctx context.Context, row *tenant.DeltaRow, q url.Values) (*graphquery.DeltaGraphResult, error) {
+	head, err := h.loadSnapshot(ctx, row.HeadSnapshotID)
+	if err != nil {
+		return nil, fmt.Errorf("head snapshot not found")
+	}
+
+	delta, err := h.loadDelta(ctx, row)
+	if err != nil {
+		return nil, err
+	}
+
+	depth := 1
+	if v := q.Get("depth"); v != "" {
+		if parsed, err := strconv.Atoi(v); err == nil && parsed >= 0 {
+			depth = parsed
+		}
+	}
+	maxNodes := 500
+	if v := q.Get("max_nodes"); v != "" {
+		if parsed, err := strconv.Atoi(v); err == nil && parsed > 0 {
+			maxNodes = parsed
+		}
+	}
+	hideTests := q.Get("hide_tests") == "true"
+	hideExternal := q.Get("hide_external") == "true"
+
+	return graphquery.DeltaGraph(delta, head, depth, maxNodes, hideTests, hideExternal), nil
+}
+
+// loadDelta loads a stored delta blob, recomputing the delta from its
+// snapshots when the blob is missing or unreadable.
+func (h *Handler) loadDelta(ctx context.Context, row *tenant.DeltaRow) (*graph.Delta, error) {
+	data, err := h.ingestionSvc.Storage().GetDelta(ctx, row.TenantID, storageIDFromRef(row.StorageRef))
+	if err == nil {
+		var delta graph.Delta
+		if err = json.Unmarshal(data, &delta); err == nil {
+			return &delta, nil
+		}
+	}
+	log.Printf("delta %s: load delta failed (%v), recomputing from snapshots", row.ID, err)
+
+	base, err := h.loadSnapshot(ctx, row.BaseSnapshotID)
+	if err != nil {
+		return nil, fmt.Errorf("base snapshot not found")
+	}
+	head, err := h.loadSnapshot(ctx, row.HeadSnapshotID)
+	if err != nil {
+		return nil, fmt.Errorf("head snapshot not found")
+	}
+	return graph.ComputeDelta(base, head), nil
+}
@@ -0,0 +1,48 @@
+package api
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Routes from before /api/v2 keep working until LegacySunset. Responses
+// from them carry Deprecation (RFC 9745) and Sunset (RFC 8594) headers and
+// link to the /api/v2 route that replaces them.
+var (
+	LegacyDeprecated = time.Date(2026, time.October, 14, 0, 0, 0, 0, time.UTC)
+	LegacySunset     = time.Date(2027, time.April, 30, 0, 0, 0, 0, time.UTC)
+)
+
+// deprecated serves a legacy route with handle, adding deprecation headers
+// that point at successor, the pattern of the route's /api/v2 path.
+func deprecated(successor string, handle http.HandlerFunc) http.HandlerFunc {
+	deprecation := "@" + strconv.FormatInt(LegacyDeprecated.Unix(), 10)
+	sunset := LegacySunset.Format(http.TimeFormat)
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Deprecation", deprecation)
+		w.Header().Set("Sunset", sunset)
+		w.Header().Set("Link", "<"+expandPattern(successor, r)+`>; rel="successor-version"`)
+		handle(w, r)
+	}
+}
+
+// expandPattern fills the wildcards of a route pattern with r's path values.
+func expandPattern(pattern string, r *http.Request) string {
+	segs := strings.Split(pattern, "/")
+	for i, seg := range segs {
+		name, ok := strings.CutPrefix(seg, "{")
+		if !ok {
+			continue
+		}
+		name = strings.TrimSuffix(name, "}")
+		if rest, ok := strings.CutSuffix(name, "..."); ok {
+			segs[i] = r.PathValue(rest) // already a path
+		} else {
+			segs[i] = url.PathEscape(r.PathValue(name))
+		}
+	}
+	return strings.Join(segs, "/")
+}
@@ -0,0 +1,49 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+)
+
+func TestDeprecatedRouteHeaders(t *testing.T) {
+	mux := http.NewServeMux()
+	ok := func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusTeapot) }
+	mux.HandleFunc("GET /api/snapshots/{snapshotID}/nodes/{key...}", deprecated("/api/v2/snapshots/{snapshotID}/nodes/{key...}", ok))
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest("GET", "/api/snapshots/s%201/nodes/app/web:server", nil))
+	if rec.Code != http.StatusTeapot {
+		t.Fatalf("status = %d, want the wrapped handler's", rec.Code)
+	}
+	if got := rec.Header().Get("Link"); got != `</api/v2/snapshots/s%201/nodes/app/web:server>; rel="successor-version"` {
+		t.Errorf("Link = %q", got)
+	}
+	if got := rec.Header().Get("Sunset"); got != "Fri, 30 Apr 2027 00:00:00 GMT" {
+		t.Errorf("Sunset = %q", got)
+	}
+	if got := rec.Header().Get("Deprecation"); got != "@1791936000" {
+		t.Errorf("Deprecation = %q", got)
+	}
+}
+
+// A legacy route's successor link is built from its path values, so it
+// must capture every wildcard the v2 path has.
+func TestLegacyRoutesCoverWildcards(t *testing.T) {
+	wildcard := regexp.MustCompile(`\{(\w+)(\.\.\.)?\}`)
+	for _, rt := range (&Handler{}).routes() {
+		if rt.legacy == "" {
+			continue
+		}
+		have := map[string]bool{}
+		for _, m := range wildcard.FindAllStringSubmatch(rt.legacy, -1) {
+			have[m[1]] = true
+		}
+		for _, m := range wildcard.FindAllStringSubmatch(rt.path, -1) {
+			if !have[m[1]] {
+				t.Errorf("%s %s: legacy %s has no {%s}", rt.method, rt.path, rt.legacy, m[1])
+			}
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+
+	"github.com/toposcope/toposcope/internal/tenant"
+)
+
+type baselineDriftResponse struct {
+	RepoID            string  `json:"repo_id"`
+	FullName          string  `json:"full_name"`
+	BaselineCommitSHA string  `json:"baseline_commit_sha"`
+	HeadCommitSHA     string  `json:"head_commit_sha"`
+	AddedNodes        int     `json:"added_nodes"`
+	RemovedNodes      int     `json:"removed_nodes"`
+	AddedEdges        int     `json:"added_edges"`
+	RemovedEdges      int     `json:"removed_edges"`
+	Drift             float64 `json:"drift"`
+	Drifted           bool    `json:"drifted"`
+	Refreshed         bool    `json:"refreshed"`
+	CheckedAt         string  `json:"checked_at"`
+}
+
+func baselineDriftToResponse(d *tenant.BaselineDrift) baselineDriftResponse {
+	return baselineDriftResponse{
+		RepoID:            d.RepoID,
+		FullName:          d.FullName,
+		BaselineCommitSHA: d.BaselineCommitSHA,
+		HeadCommitSHA:     d.HeadCommitSHA,
+		AddedNodes:        d.AddedNodes,
+		RemovedNodes:      d.RemovedNodes,
+		AddedEdges:        d.AddedEdges,
+		RemovedEdges:      d.RemovedEdges,
+		Drift:             d.Drift,
+		Drifted:           d.Drifted,
+		Refreshed:         d.Refreshed,
+		CheckedAt:         d.CheckedAt.Format("2006-01-02T15:04:05Z"),
+	}
+}
+
+// handleBaselineDrift handles GET /api/repos/{repoID}/baseline/drift. It
+// returns the latest comparison of the repository's baseline with a fresh
+// extraction of its default branch.
+func (h *Handler) handleBaselineDrift(w http.ResponseWriter, r *http.Request) {
+	repoID := r.PathValue("repoID")
+	if !h.authorizeRepo(w, r, repoID) {
+		return
+	}
+	d, err := h.tenantSvc.GetBaselineDrift(r.Context(), repoID)
+	if errors.Is(err, sql.ErrNoRows) {
+		writeError(w, http.StatusNotFound, "baseline has not been checked for drift")
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to query baseline drift")
+		return
+	}
+	writeJSON(w, http.StatusOK, baselineDriftToResponse(d))
+}
+
+// handleListDriftedBaselines handles GET /api/v1/admin/baselines/drifted: the
+// repositories whose baseline was flagged by the last drift check and not
+// refreshed.
+func (h *Handler) handleListDriftedBaselines(w http.ResponseWriter, r *http.Request) {
+	if !h.requireOperator(w, r) {
+		return
+	}
+	drifted, err := h.tenantSvc.ListDriftedBaselines(r.Context())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to list drifted baselines: "+err.Error())
+		return
+	}
+	result := []baselineDriftResponse{}
+	for i := range drifted {
+		result = append(result, baselineDriftToResponse(&drifted[i]))
+	}
+	writeJSON(w, http.StatusOK, result)
+}
@@ -0,0 +1,82 @@
+package api
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// revalidateCache is the Cache-Control for responses that can change;
+// clients may keep them but must revalidate with If-None-Match. Snapshot and
+// delta responses use it too: re-ingesting a commit replaces what is stored
+// behind the same ID.
+const revalidateCache = "private, no-cache"
+
+// queryETag returns a strong ETag for a response determined by the stored
+// content, named by version, and the request's query string. Query parameters are compared
+// in canonical (sorted) order, so reordering them still hits the cache.
+func queryETag(version string, r *http.Request) string {
+	q := r.URL.Query().Encode()
+	if q == "" {
+		return `"` + version + `"`
+	}
+	sum := sha256.Sum256([]byte(version + "?" + q))
+	return `"` + version + "-" + hex.EncodeToString(sum[:8]) + `"`
+}
+
+// notModified reports whether the client's copy, named by If-None-Match, is
+// still etag. If so, it writes 304 Not Modified and the caller is done.
+func notModified(w http.ResponseWriter, r *http.Request, etag, cacheControl string) bool {
+	if !etagMatches(r.Header.Get("If-None-Match"), etag) {
+		return false
+	}
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", cacheControl)
+	w.WriteHeader(http.StatusNotModified)
+	return true
+}
+
+// etagMatches reports whether an If-None-Match header lists etag. The
+// comparison is weak, as RFC 9110 requires for If-None-Match.
+func etagMatches(header, etag string) bool {
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || strings.TrimPrefix(tag, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// writeCached writes a 200 JSON response carrying etag and cacheControl.
+func writeCached(w http.ResponseWriter, etag, cacheControl string, data any) {
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", cacheControl)
+	writeJSON(w, http.StatusOK, data)
+}
+
+// writeRevalidated writes a JSON response whose ETag is a hash of its body,
+// for responses that aren't fixed by their URL. A client sending the
+// current ETag gets 304 Not Modified instead of the body.
+func writeRevalidated(w http.ResponseWriter, r *http.Request, data any) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(data); err != nil {
+		writeError(w, http.StatusInternalServerError, "encode response: "+err.Error())
+		return
+	}
+	sum := sha256.Sum256(buf.Bytes())
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+	if notModified(w, r, etag, revalidateCache) {
+		return
+	}
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", revalidateCache)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(buf.Bytes())
+}
@@ -0,0 +1,139 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/toposcope/toposcope/internal/tenant"
+	"github.com/toposcope/toposcope/pkg/graph"
+)
+
+func TestQueryETag(t *testing.T) {
+	get := func(target string) *http.Request { return httptest.NewRequest("GET", target, nil) }
+
+	if got := queryETag("s1", get("/api/v2/snapshots/s1")); got != `"s1"` {
+		t.Errorf("no query: ETag = %s, want \"s1\"", got)
+	}
+	a := queryETag("s1", get("/api/v2/snapshots/s1/subgraph?depth=2&root=//a"))
+	b := queryETag("s1", get("/api/v2/snapshots/s1/subgraph?root=//a&depth=2"))
+	if a != b {
+		t.Errorf("reordered query: %s != %s", a, b)
+	}
+	if c := queryETag("s1", get("/api/v2/snapshots/s1/subgraph?depth=3&root=//a")); c == a {
+		t.Errorf("different query got the same ETag %s", c)
+	}
+	if d := queryETag("s2", get("/api/v2/snapshots/s2/subgraph?depth=2&root=//a")); d == a {
+		t.Errorf("different snapshot got the same ETag %s", d)
+	}
+}
+
+func TestNotModified(t *testing.T) {
+	for _, tc := range []struct {
+		header string
+		want   bool
+	}{
+		{"", false},
+		{`"s2"`, false},
+		{`"s1"`, true},
+		{`"s0", W/"s1"`, true},
+		{"*", true},
+	} {
+		r := httptest.NewRequest("GET", "/api/v2/snapshots/s1", nil)
+		if tc.header != "" {
+			r.Header.Set("If-None-Match", tc.header)
+		}
+		w := httptest.NewRecorder()
+		if got := notModified(w, r, `"s1"`, revalidateCache); got != tc.want {
+			t.Errorf("If-None-Match %q: notModified = %v, want %v", tc.header, got, tc.want)
+		}
+		if tc.want && (w.Code != http.StatusNotModified || w.Header().Get("ETag") != `"s1"`) {
+			t.Errorf("If-None-Match %q: status %d, ETag %q", tc.header, w.Code, w.Header().Get("ETag"))
+		}
+	}
+}
+
+func TestWriteRevalidated(t *testing.T) {
+	w := httptest.NewRecorder()
+	writeRevalidated(w, httptest.NewRequest("GET", "/x", nil), map[string]int{"n": 1})
+	etag := w.Header().Get("ETag")
+	if w.Code != http.StatusOK || etag == "" || w.Header().Get("Cache-Control") != revalidateCache {
+		t.Fatalf("status %d, ETag %q, Cache-Control %q", w.Code, etag, w.Header().Get("Cache-Control"))
+	}
+
+	r := httptest.NewRequest("GET", "/x", nil)
+	r.Header.Set("If-None-Match", etag)
+	w = httptest.NewRecorder()
+	writeRevalidated(w, r, map[string]int{"n": 1})
+	if w.Code != http.StatusNotModified || w.Body.Len() != 0 {
+		t.Errorf("unchanged body: status %d, %d bytes", w.Code, w.Body.Len())
+	}
+
+	w = httptest.NewRecorder()
+	writeRevalidated(w, r, map[string]int{"n": 2})
+	if w.Code != http.StatusOK || w.Header().Get("ETag") == etag {
+		t.Errorf("changed body: status %d, ETag %q", w.Code, w.Header().Get("ETag"))
+	}
+}
+
+func TestSnapshotVersion(t *testing.T) {
+	legacy := &tenant.SnapshotRow{ID: "s1", StorageRef: "snapshots/t1/blob1.json"}
+	if got := snapshotVersion(legacy); got != "blob1" {
+		t.Errorf("version without checksum = %q, want the blob ID", got)
+	}
+	first := &tenant.SnapshotRow{ID: "s1", StorageRef: "snapshots/t1/blob1.json", Checksum: "aaaa"}
+	reingested := &tenant.SnapshotRow{ID: "s1", StorageRef: "snapshots/t1/blob1.json", Checksum: "bbbb"}
+	if snapshotVersion(first) != "aaaa" {
+		t.Errorf("version = %q, want the checksum", snapshotVersion(first))
+	}
+	if snapshotCacheKey(first) == snapshotCacheKey(reingested) {
+		t.Error("re-ingested snapshot kept its cache key")
+	}
+
+	head := &tenant.SnapshotRow{ID: "h1", Checksum: "cccc"}
+	delta := &tenant.DeltaRow{ID: "d1", StorageRef: "deltas/t1/x.json"}
+	if deltaVersion(delta, head) == deltaVersion(&tenant.DeltaRow{ID: "d1", StorageRef: "deltas/t1/y.json"}, head) {
+		t.Error("delta version ignores a re-stored delta blob")
+	}
+	if deltaVersion(delta, head) == deltaVersion(delta, &tenant.SnapshotRow{ID: "h1", Checksum: "dddd"}) {
+		t.Error("delta version ignores a re-ingested head")
+	}
+}
+
+func TestOpenIndexRebuildsReingestedSnapshot(t *testing.T) {
+	dir := t.TempDir()
+	h := &Handler{IndexDir: dir, cache: NewSnapshotCache(0)}
+	snapshot := func(key string) *graph.Snapshot {
+		return &graph.Snapshot{Nodes: map[string]*graph.Node{key: {Key: key, Package: "//" + key[2:3]}}}
+	}
+	open := func(row *tenant.SnapshotRow, snap *graph.Snapshot) *graph.Compact {
+		t.Helper()
+		h.cache.Put(snapshotCacheKey(row), snap)
+		ix, err := h.openIndex(context.Background(), row)
+		if err != nil {
+			t.Fatalf("openIndex: %v", err)
+		}
+		t.Cleanup(func() { ix.Close() })
+		return ix
+	}
+
+	ix := open(&tenant.SnapshotRow{ID: "s1", Checksum: "aaaa"}, snapshot("//a:a"))
+	if _, ok := ix.ID("//a:a"); !ok {
+		t.Fatal("first index is missing //a:a")
+	}
+	ix = open(&tenant.SnapshotRow{ID: "s1", Checksum: "bbbb"}, snapshot("//b:b"))
+	if _, ok := ix.ID("//b:b"); !ok {
+		t.Error("index of the re-ingested snapshot is missing //b:b")
+	}
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*.idx"))
+	if len(files) != 1 || filepath.Base(files[0]) != "s1-bbbb.idx" {
+		t.Errorf("index files = %v, want only s1-bbbb.idx", files)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "s1-aaaa.idx")); !os.IsNotExist(err) {
+		t.Errorf("stale index kept: %v", err)
+	}
+}
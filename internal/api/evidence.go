@@ -0,0 +1,146 @@
+package api
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/toposcope/toposcope/pkg/graph"
+	"github.com/toposcope/toposcope/pkg/scoring"
+)
+
+// evidenceDetail is an evidence item with node metadata joined from the
+// head snapshot (or the base snapshot, for nodes removed by the change).
+type evidenceDetail struct {
+	scoring.EvidenceItem
+	FromNode *graph.Node `json:"from_node,omitempty"`
+	ToNode   *graph.Node `json:"to_node,omitempty"`
+}
+
+type metricEvidenceResponse struct {
+	Key          string           `json:"key"`
+	Name         string           `json:"name"`
+	Contribution float64          `json:"contribution"`
+	Severity     scoring.Severity `json:"severity"`
+	Evidence     []evidenceDetail `json:"evidence"`
+}
+
+type scoreEvidenceResponse struct {
+	ScoreID        string                   `json:"score_id"`
+	BaseSnapshotID string                   `json:"base_snapshot_id"`
+	HeadSnapshotID string                   `json:"head_snapshot_id"`
+	Metrics        []metricEvidenceResponse `json:"metrics"`
+}
+
+// handleScoreEvidence handles GET /api/v1/scores/{scoreID}/evidence?metric=...
+// It returns the complete evidence list for one metric (or all metrics when
+// metric is omitted), with each referenced node's metadata attached.
+func (h *Handler) handleScoreEvidence(w http.ResponseWriter, r *http.Request) {
+	scoreID := r.PathValue("scoreID")
+	metricKey := r.URL.Query().Get("metric")
+	ctx := r.Context()
+
+	sc, err := h.tenantSvc.GetScoreByID(ctx, scoreID)
+	if err != nil || !CallerFrom(ctx).Owns(sc.TenantID) {
+		writeError(w, http.StatusNotFound, "score not found")
+		return
+	}
+
+	var breakdown []scoring.MetricResult
+	if err := json.Unmarshal(sc.Breakdown, &breakdown); err != nil {
+		writeError(w, http.StatusInternalServerError, "invalid stored breakdown: "+err.Error())
+		return
+	}
+
+	head, err := h.loadSnapshot(ctx, sc.HeadSnapshotID)
+	if err != nil {
+		writeError(w, http.StatusNotFound, "head snapshot not found")
+		return
+	}
+
+	// Stored breakdowns hold the evidence as it was uploaded, which clients
+	// may have truncated. Rescore the stored delta for the full lists.
+	engine := scoring.NewEngine(scoring.DefaultMetrics()...)
+	full, err := h.previewScore(ctx, engine, sc.DeltaID, sc.BaseSnapshotID, sc.HeadSnapshotID)
+	if err != nil {
+		log.Printf("evidence %s: rescore delta (using stored evidence): %v", scoreID, err)
+	}
+	selected := selectEvidence(breakdown, full, metricKey)
+	if metricKey != "" && len(selected) == 0 {
+		writeError(w, http.StatusNotFound, "metric not found in score: "+metricKey)
+		return
+	}
+
+	// The base snapshot is only needed for nodes that no longer exist in head.
+	var base *graph.Snapshot
+	baseLoaded := false
+	lookup := func(key string) *graph.Node {
+		if key == "" {
+			return nil
+		}
+		if n := head.Nodes[key]; n != nil {
+			return n
+		}
+		if !baseLoaded {
+			baseLoaded = true
+			if base, err = h.loadSnapshot(ctx, sc.BaseSnapshotID); err != nil {
+				log.Printf("evidence %s: load base snapshot: %v", scoreID, err)
+			}
+		}
+		if base != nil {
+			return base.Nodes[key]
+		}
+		return nil
+	}
+
+	resp := scoreEvidenceResponse{
+		ScoreID:        sc.ID,
+		BaseSnapshotID: sc.BaseSnapshotID,
+		HeadSnapshotID: sc.HeadSnapshotID,
+		Metrics:        make([]metricEvidenceResponse, 0, len(selected)),
+	}
+	for _, mr := range selected {
+		me := metricEvidenceResponse{
+			Key:          mr.Key,
+			Name:         mr.Name,
+			Contribution: mr.Contribution,
+			Severity:     mr.Severity,
+			Evidence:     make([]evidenceDetail, 0, len(mr.Evidence)),
+		}
+		for _, ev := range mr.Evidence {
+			me.Evidence = append(me.Evidence, evidenceDetail{
+				EvidenceItem: ev,
+				FromNode:     lookup(ev.From),
+				ToNode:       lookup(ev.To),
+			})
+		}
+		resp.Metrics = append(resp.Metrics, me)
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// selectEvidence returns the stored metric results for metricKey (all of
+// them when it is empty), with each one's evidence replaced by the full list
+// from the rescored result. Metrics missing from full, such as external
+// metrics, keep their stored evidence. Contributions and severities are
+// always the stored ones, so they match the score.
+func selectEvidence(stored []scoring.MetricResult, full *scoring.ScoreResult, metricKey string) []scoring.MetricResult {
+	evidence := make(map[string][]scoring.EvidenceItem)
+	if full != nil {
+		for _, mr := range full.Breakdown {
+			evidence[mr.Key] = mr.Evidence
+		}
+	}
+	var selected []scoring.MetricResult
+	for _, mr := range stored {
+		if metricKey != "" && mr.Key != metricKey {
+			continue
+		}
+		if ev, ok := evidence[mr.Key]; ok {
+			mr.Evidence = ev
+		}
+		selected = append(selected, mr)
+	}
+	return selected
+}
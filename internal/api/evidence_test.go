@@ -0,0 +1,64 @@
+package api
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/toposcope/toposcope/pkg/scoring"
+)
+
+func TestSelectEvidence(t *testing.T) {
+	items := func(n int) []scoring.EvidenceItem {
+		var ev []scoring.EvidenceItem
+		for i := 0; i < n; i++ {
+			ev = append(ev, scoring.EvidenceItem{Type: scoring.EvidenceEdgeAdded, From: fmt.Sprintf("//a:%d", i), To: "//b:lib"})
+		}
+		return ev
+	}
+	stored := []scoring.MetricResult{
+		{Key: "fanout_increase", Contribution: 4, Severity: scoring.SeverityMedium, Evidence: items(1)},
+		{Key: "custom", Contribution: 1, Evidence: items(2)},
+	}
+	full := &scoring.ScoreResult{Breakdown: []scoring.MetricResult{
+		{Key: "fanout_increase", Contribution: 9, Severity: scoring.SeverityHigh, Evidence: items(5)},
+	}}
+
+	t.Run("truncated evidence is replaced by the full list", func(t *testing.T) {
+		got := selectEvidence(stored, full, "fanout_increase")
+		if len(got) != 1 {
+			t.Fatalf("got %d metrics, want 1", len(got))
+		}
+		if len(got[0].Evidence) != 5 {
+			t.Errorf("got %d evidence items, want the full 5", len(got[0].Evidence))
+		}
+		if got[0].Contribution != 4 || got[0].Severity != scoring.SeverityMedium {
+			t.Errorf("contribution and severity = %v/%s, want the stored 4/MEDIUM", got[0].Contribution, got[0].Severity)
+		}
+		if len(stored[0].Evidence) != 1 {
+			t.Error("stored breakdown was modified")
+		}
+	})
+
+	t.Run("metrics the rescore lacks keep stored evidence", func(t *testing.T) {
+		got := selectEvidence(stored, full, "")
+		if len(got) != 2 {
+			t.Fatalf("got %d metrics, want 2", len(got))
+		}
+		if len(got[1].Evidence) != 2 {
+			t.Errorf("custom metric has %d evidence items, want the stored 2", len(got[1].Evidence))
+		}
+	})
+
+	t.Run("no rescore falls back to stored evidence", func(t *testing.T) {
+		got := selectEvidence(stored, nil, "fanout_increase")
+		if len(got) != 1 || len(got[0].Evidence) != 1 {
+			t.Errorf("got %+v, want the stored metric", got)
+		}
+	})
+
+	t.Run("unknown metric", func(t *testing.T) {
+		if got := selectEvidence(stored, full, "missing"); len(got) != 0 {
+			t.Errorf("got %d metrics, want none", len(got))
+		}
+	})
+}
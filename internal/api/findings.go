@@ -0,0 +1,138 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/toposcope/toposcope/internal/tenant"
+	"github.com/toposcope/toposcope/pkg/scoring"
+)
+
+const (
+	findingNew      = "new"
+	findingExisting = "existing"
+)
+
+type findingSightingResponse struct {
+	ScoreID   string `json:"score_id"`
+	CommitSHA string `json:"commit_sha"`
+	PRNumber  *int   `json:"pr_number,omitempty"`
+	CreatedAt string `json:"created_at"`
+}
+
+type findingResponse struct {
+	Fingerprint string                   `json:"fingerprint"`
+	Metric      string                   `json:"metric"`
+	Type        scoring.EvidenceType     `json:"type"`
+	Summary     string                   `json:"summary"`
+	From        string                   `json:"from,omitempty"`
+	To          string                   `json:"to,omitempty"`
+	Status      string                   `json:"status"` // new or existing
+	FirstSeen   *findingSightingResponse `json:"first_seen,omitempty"`
+}
+
+type prFindingsResponse struct {
+	ScoreID   string            `json:"score_id"`
+	CommitSHA string            `json:"commit_sha"`
+	New       int               `json:"new"`
+	Existing  int               `json:"existing"`
+	Findings  []findingResponse `json:"findings"`
+}
+
+// handlePRFindings handles GET /api/repos/{repoID}/prs/{prNumber}/findings.
+// It lists the findings of the PR's latest score, each marked new or
+// existing: existing findings already appeared in an earlier score of the
+// repository (an earlier push of the PR, or another change), which is
+// reported as first_seen. ?status=new|existing filters the list.
+func (h *Handler) handlePRFindings(w http.ResponseWriter, r *http.Request) {
+	repoID := r.PathValue("repoID")
+	if !h.authorizeRepo(w, r, repoID) {
+		return
+	}
+	prNumber, err := strconv.Atoi(r.PathValue("prNumber"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid pr number")
+		return
+	}
+	status := r.URL.Query().Get("status")
+	if status != "" && status != findingNew && status != findingExisting {
+		writeError(w, http.StatusBadRequest, "status must be new or existing")
+		return
+	}
+
+	ctx := r.Context()
+	sc, err := h.tenantSvc.GetScoreByPR(ctx, repoID, prNumber)
+	if err != nil {
+		if strings.Contains(err.Error(), "no rows") {
+			writeError(w, http.StatusNotFound, "no score found for PR")
+		} else {
+			writeError(w, http.StatusInternalServerError, "failed to query score")
+		}
+		return
+	}
+
+	result := &scoring.ScoreResult{}
+	if err := json.Unmarshal(sc.Breakdown, &result.Breakdown); err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to decode score breakdown")
+		return
+	}
+	result.AssignFingerprints()
+
+	var fingerprints []string
+	for _, mr := range result.Breakdown {
+		for _, ev := range mr.Evidence {
+			fingerprints = append(fingerprints, ev.Fingerprint)
+		}
+	}
+	seen, err := h.tenantSvc.FirstSeen(ctx, repoID, sc.ID, fingerprints)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to query finding history")
+		return
+	}
+
+	resp := prFindingsResponse{ScoreID: sc.ID, CommitSHA: sc.CommitSHA, Findings: []findingResponse{}}
+	for _, f := range classifyFindings(result.Breakdown, seen) {
+		if f.Status == findingNew {
+			resp.New++
+		} else {
+			resp.Existing++
+		}
+		if status == "" || f.Status == status {
+			resp.Findings = append(resp.Findings, f)
+		}
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// classifyFindings marks each finding in breakdown new, or existing if seen
+// has an earlier sighting of its fingerprint. Evidence must carry
+// fingerprints.
+func classifyFindings(breakdown []scoring.MetricResult, seen map[string]tenant.FindingSighting) []findingResponse {
+	var out []findingResponse
+	for _, mr := range breakdown {
+		for _, ev := range mr.Evidence {
+			f := findingResponse{
+				Fingerprint: ev.Fingerprint,
+				Metric:      mr.Key,
+				Type:        ev.Type,
+				Summary:     ev.Summary,
+				From:        ev.From,
+				To:          ev.To,
+				Status:      findingNew,
+			}
+			if sg, ok := seen[ev.Fingerprint]; ok {
+				f.Status = findingExisting
+				f.FirstSeen = &findingSightingResponse{
+					ScoreID:   sg.ScoreID,
+					CommitSHA: sg.CommitSHA,
+					PRNumber:  sg.PRNumber,
+					CreatedAt: sg.CreatedAt.Format("2006-01-02T15:04:05Z"),
+				}
+			}
+			out = append(out, f)
+		}
+	}
+	return out
+}
@@ -0,0 +1,37 @@
+package api
+
+import (
+	"testing"
+	"time"
+
+	"github.com/toposcope/toposcope/internal/tenant"
+	"github.com/toposcope/toposcope/pkg/scoring"
+)
+
+func TestClassifyFindings(t *testing.T) {
+	breakdown := []scoring.MetricResult{{
+		Key: "cross_package_deps",
+		Evidence: []scoring.EvidenceItem{
+			{Summary: "//a -> //b", From: "//a:a", To: "//b:b", Fingerprint: "f1"},
+			{Summary: "//a -> //c", From: "//a:a", To: "//c:c", Fingerprint: "f2"},
+		},
+	}}
+	pr := 12
+	seen := map[string]tenant.FindingSighting{
+		"f2": {ScoreID: "s0", CommitSHA: "abc", PRNumber: &pr, CreatedAt: time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)},
+	}
+
+	got := classifyFindings(breakdown, seen)
+	if len(got) != 2 {
+		t.Fatalf("got %d findings, want 2", len(got))
+	}
+	if got[0].Status != findingNew || got[0].FirstSeen != nil {
+		t.Errorf("f1 = %+v, want new", got[0])
+	}
+	if got[1].Status != findingExisting || got[1].FirstSeen == nil || got[1].FirstSeen.ScoreID != "s0" || *got[1].FirstSeen.PRNumber != 12 {
+		t.Errorf("f2 = %+v, want existing since s0", got[1])
+	}
+	if got[1].Metric != "cross_package_deps" {
+		t.Errorf("metric = %q", got[1].Metric)
+	}
+}
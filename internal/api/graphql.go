@@ -0,0 +1,459 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/toposcope/toposcope/internal/graphql"
+	"github.com/toposcope/toposcope/internal/tenant"
+	"github.com/toposcope/toposcope/pkg/graphquery"
+)
+
+// snapshotMetaResponse is a snapshot's metadata, without its graph.
+type snapshotMetaResponse struct {
+	ID            string   `json:"id"`
+	RepoID        string   `json:"repo_id"`
+	CommitSHA     string   `json:"commit_sha"`
+	Branch        *string  `json:"branch,omitempty"`
+	NodeCount     int      `json:"node_count"`
+	EdgeCount     int      `json:"edge_count"`
+	PackageCount  int      `json:"package_count"`
+	ExtractionMs  int      `json:"extraction_ms"`
+	Labels        []string `json:"labels"`
+	CommitAuthor  string   `json:"commit_author,omitempty"`
+	CommitMessage string   `json:"commit_message,omitempty"`
+	CommittedAt   string   `json:"committed_at,omitempty"`
+	CreatedAt     string   `json:"created_at"`
+}
+
+func snapshotRowToMeta(sn *tenant.SnapshotRow) snapshotMetaResponse {
+	labels := []string(sn.Labels)
+	if labels == nil {
+		labels = []string{}
+	}
+	return snapshotMetaResponse{
+		ID:            sn.ID,
+		RepoID:        sn.RepoID,
+		CommitSHA:     sn.CommitSHA,
+		Branch:        sn.Branch,
+		NodeCount:     sn.NodeCount,
+		EdgeCount:     sn.EdgeCount,
+		PackageCount:  sn.PackageCount,
+		ExtractionMs:  sn.ExtractionMs,
+		Labels:        labels,
+		CommitAuthor:  sn.CommitAuthor,
+		CommitMessage: sn.CommitMessage,
+		CommittedAt:   formatOptionalTime(sn.CommittedAt),
+		CreatedAt:     sn.CreatedAt.Format("2006-01-02T15:04:05Z"),
+	}
+}
+
+// deltaMetaResponse is a delta's metadata, without its changes.
+type deltaMetaResponse struct {
+	ID             string `json:"id"`
+	RepoID         string `json:"repo_id"`
+	BaseSnapshotID string `json:"base_snapshot_id"`
+	HeadSnapshotID string `json:"head_snapshot_id"`
+	AddedNodes     int    `json:"added_nodes"`
+	RemovedNodes   int    `json:"removed_nodes"`
+	AddedEdges     int    `json:"added_edges"`
+	RemovedEdges   int    `json:"removed_edges"`
+	CreatedAt      string `json:"created_at"`
+
+	row *tenant.DeltaRow
+}
+
+// graphqlRequest, graphqlResponse, and graphqlError describe the GraphQL
+// bodies in the OpenAPI document under names of their own.
+type (
+	graphqlRequest  graphql.Request
+	graphqlResponse struct {
+		Data   any            `json:"data"`
+		Errors []graphqlError `json:"errors,omitempty"`
+	}
+	graphqlError graphql.Error
+)
+
+// gqlLoadCost is the GraphQL cost of a field that reads a snapshot or delta
+// from storage. Other fields cost 1, so a query can make at most
+// graphql.DefaultMaxCost/gqlLoadCost such reads.
+const gqlLoadCost = 50
+
+// graphqlSchema builds the schema served at /api/graphql. Object fields
+// not listed here are the properties of the type's REST representation,
+// under the same snake_case names.
+func (h *Handler) graphqlSchema() *graphql.Schema {
+	snapshot := &graphql.Object{Name: "Snapshot"}
+	delta := &graphql.Object{Name: "Delta"}
+	score := &graphql.Object{Name: "Score"}
+	repo := &graphql.Object{Name: "Repo"}
+
+	snapshotField := func(id func(any) string) *graphql.Field {
+		return &graphql.Field{Type: snapshot, Resolve: func(ctx context.Context, p graphql.Params) (any, error) {
+			if id(p.Source) == "" {
+				return nil, nil
+			}
+			return h.gqlSnapshot(ctx, id(p.Source))
+		}}
+	}
+	// graphQuery runs a graph query over the source snapshot, with the
+	// arguments taking the names and defaults of the REST query parameters.
+	graphQuery := func(run func(ctx context.Context, snapshotID string, q url.Values) (any, error)) *graphql.Field {
+		return &graphql.Field{Cost: gqlLoadCost, Resolve: func(ctx context.Context, p graphql.Params) (any, error) {
+			return run(ctx, p.Source.(snapshotMetaResponse).ID, argValues(p.Args))
+		}}
+	}
+
+	snapshot.Fields = map[string]*graphql.Field{
+		"graph": graphQuery(func(ctx context.Context, id string, _ url.Values) (any, error) {
+			return h.loadSnapshot(ctx, id)
+		}),
+		"subgraph": graphQuery(func(ctx context.Context, id string, q url.Values) (any, error) {
+			params, err := graphquery.ParseSubgraphParams(q)
+			if err != nil {
+				return nil, err
+			}
+			snap, err := h.loadSnapshot(ctx, id)
+			if err != nil {
+				return nil, err
+			}
+			return graphquery.Subgraph(snap, params), nil
+		}),
+		"sample": graphQuery(func(ctx context.Context, id string, q url.Values) (any, error) {
+			snap, err := h.loadSnapshot(ctx, id)
+			if err != nil {
+				return nil, err
+			}
+			return graphquery.Sample(snap, graphquery.ParseSampleParams(q)), nil
+		}),
+		"ego": graphQuery(func(ctx context.Context, id string, q url.Values) (any, error) {
+			params, err := graphquery.ParseEgoParams(q)
+			if err != nil {
+				return nil, err
+			}
+			snap, err := h.loadSnapshot(ctx, id)
+			if err != nil {
+				return nil, err
+			}
+			return graphquery.Ego(snap, params), nil
+		}),
+		"path": graphQuery(func(ctx context.Context, id string, q url.Values) (any, error) {
+			params, err := graphquery.ParsePathParams(q)
+			if err != nil {
+				return nil, err
+			}
+			snap, err := h.loadSnapshot(ctx, id)
+			if err != nil {
+				return nil, err
+			}
+			return graphquery.Paths(snap, params), nil
+		}),
+		"explain": graphQuery(func(ctx context.Context, id string, q url.Values) (any, error) {
+			params, err := graphquery.ParseExplainParams(q)
+			if err != nil {
+				return nil, err
+			}
+			snap, err := h.loadSnapshot(ctx, id)
+			if err != nil {
+				return nil, err
+			}
+			return graphquery.Explanation(snap, params), nil
+		}),
+		"package_path": graphQuery(func(ctx context.Context, id string, q url.Values) (any, error) {
+			params, err := graphquery.ParsePackagePathParams(q)
+			if err != nil {
+				return nil, err
+			}
+			snap, err := h.loadSnapshot(ctx, id)
+			if err != nil {
+				return nil, err
+			}
+			return graphquery.PackagePaths(snap, params), nil
+		}),
+		"packages": graphQuery(func(ctx context.Context, id string, q url.Values) (any, error) {
+			snap, err := h.loadSnapshot(ctx, id)
+			if err != nil {
+				return nil, err
+			}
+			return graphquery.Packages(snap, graphquery.ParsePackageParams(q)), nil
+		}),
+		"communities": graphQuery(func(ctx context.Context, id string, q url.Values) (any, error) {
+			snap, err := h.loadSnapshot(ctx, id)
+			if err != nil {
+				return nil, err
+			}
+			return graphquery.Communities(snap, graphquery.ParsePackageParams(q)), nil
+		}),
+		"search": graphQuery(func(ctx context.Context, id string, q url.Values) (any, error) {
+			snap, err := h.loadSnapshot(ctx, id)
+			if err != nil {
+				return nil, err
+			}
+			return graphquery.Search(snap, graphquery.ParseSearchParams(q)), nil
+		}),
+		"node": graphQuery(func(ctx context.Context, id string, q url.Values) (any, error) {
+			key := q.Get("key")
+			if key == "" {
+				return nil, fmt.Errorf("key argument required")
+			}
+			snap, err := h.loadSnapshot(ctx, id)
+			if err != nil {
+				return nil, err
+			}
+			if detail := graphquery.NodeDetail(snap, key); detail != nil {
+				return detail, nil
+			}
+			return nil, nil
+		}),
+	}
+
+	delta.Fields = map[string]*graphql.Field{
+		"base_snapshot": snapshotField(func(src any) string { return src.(deltaMetaResponse).BaseSnapshotID }),
+		"head_snapshot": snapshotField(func(src any) string { return src.(deltaMetaResponse).HeadSnapshotID }),
+		"changes": {Cost: gqlLoadCost, Resolve: func(ctx context.Context, p graphql.Params) (any, error) {
+			return h.loadDelta(ctx, p.Source.(deltaMetaResponse).row)
+		}},
+		"graph": {Cost: 2 * gqlLoadCost, Resolve: func(ctx context.Context, p graphql.Params) (any, error) {
+			return h.deltaGraph(ctx, p.Source.(deltaMetaResponse).row, argValues(p.Args))
+		}},
+	}
+
+	score.Fields = map[string]*graphql.Field{
+		"base_snapshot": snapshotField(func(src any) string { return src.(scoreResponse).BaseSnapshotID }),
+		"head_snapshot": snapshotField(func(src any) string { return src.(scoreResponse).HeadSnapshotID }),
+		"delta": {Type: delta, Resolve: func(ctx context.Context, p graphql.Params) (any, error) {
+			if id := p.Source.(scoreResponse).DeltaID; id != "" {
+				return h.gqlDelta(ctx, id)
+			}
+			return nil, nil
+		}},
+	}
+
+	repo.Fields = map[string]*graphql.Field{
+		"scores": {Type: score, Resolve: func(ctx context.Context, p graphql.Params) (any, error) {
+			label, _ := p.Args["label"].(string)
+			rows, err := h.tenantSvc.ListScoresByRepo(ctx, p.Source.(repoResponse).ID, label)
+			if err != nil {
+				return nil, err
+			}
+			if limit, ok := argInt(p.Args, "limit"); ok && limit >= 0 && limit < len(rows) {
+				rows = rows[:limit]
+			}
+			scores := []scoreResponse{}
+			for i := range rows {
+				scores = append(scores, scoreRowToResponse(&rows[i]))
+			}
+			return scores, nil
+		}},
+		"pr": {Type: score, Resolve: func(ctx context.Context, p graphql.Params) (any, error) {
+			number, ok := argInt(p.Args, "number")
+			if !ok {
+				return nil, fmt.Errorf("number argument required")
+			}
+			sc, err := h.tenantSvc.GetScoreByPR(ctx, p.Source.(repoResponse).ID, number)
+			if err != nil {
+				return nilIfNotFound(err)
+			}
+			return scoreRowToResponse(sc), nil
+		}},
+		"baseline": {Type: snapshot, Resolve: func(ctx context.Context, p graphql.Params) (any, error) {
+			sn, err := h.tenantSvc.GetBaselineSnapshot(ctx, p.Source.(repoResponse).ID)
+			if err != nil {
+				return nilIfNotFound(err)
+			}
+			return snapshotRowToMeta(sn), nil
+		}},
+		"drift": {Resolve: func(ctx context.Context, p graphql.Params) (any, error) {
+			d, err := h.tenantSvc.GetBaselineDrift(ctx, p.Source.(repoResponse).ID)
+			if err != nil {
+				return nilIfNotFound(err)
+			}
+			return baselineDriftToResponse(d), nil
+		}},
+	}
+
+	return &graphql.Schema{Query: &graphql.Object{Name: "Query", Fields: map[string]*graphql.Field{
+		"repos": {Type: repo, Resolve: func(ctx context.Context, _ graphql.Params) (any, error) {
+			return h.gqlRepos(ctx)
+		}},
+		"repo": {Type: repo, Resolve: func(ctx context.Context, p graphql.Params) (any, error) {
+			if id, _ := p.Args["id"].(string); id != "" {
+				r, err := h.tenantSvc.GetRepositoryByID(ctx, id)
+				if err != nil {
+					return nilIfNotFound(err)
+				}
+				if !CallerFrom(ctx).Owns(r.TenantID) {
+					return nil, nil
+				}
+				return repoResponse{ID: r.ID, FullName: r.FullName, DefaultBranch: r.DefaultBranch}, nil
+			}
+			name, _ := p.Args["full_name"].(string)
+			if name == "" {
+				return nil, fmt.Errorf("id or full_name argument required")
+			}
+			repos, err := h.gqlRepos(ctx)
+			if err != nil {
+				return nil, err
+			}
+			for _, r := range repos {
+				if r.FullName == name {
+					return r, nil
+				}
+			}
+			return nil, nil
+		}},
+		"snapshot": {Type: snapshot, Resolve: func(ctx context.Context, p graphql.Params) (any, error) {
+			id, _ := p.Args["id"].(string)
+			return h.gqlSnapshot(ctx, id)
+		}},
+		"score": {Type: score, Resolve: func(ctx context.Context, p graphql.Params) (any, error) {
+			id, _ := p.Args["id"].(string)
+			sc, err := h.tenantSvc.GetScoreByID(ctx, id)
+			if err != nil {
+				return nilIfNotFound(err)
+			}
+			if !CallerFrom(ctx).Owns(sc.TenantID) {
+				return nil, nil
+			}
+			return scoreRowToResponse(sc), nil
+		}},
+		"delta": {Type: delta, Resolve: func(ctx context.Context, p graphql.Params) (any, error) {
+			id, _ := p.Args["id"].(string)
+			return h.gqlDelta(ctx, id)
+		}},
+	}}}
+}
+
+// gqlRepos lists the repositories visible to the caller.
+func (h *Handler) gqlRepos(ctx context.Context) ([]repoResponse, error) {
+	var repos []tenant.Repository
+	var err error
+	if caller := CallerFrom(ctx); caller.Scoped() {
+		repos, err = h.tenantSvc.ListRepositories(ctx, caller.TenantID)
+	} else {
+		repos, err = h.tenantSvc.ListAllRepos(ctx)
+	}
+	if err != nil {
+		return nil, err
+	}
+	result := []repoResponse{}
+	for _, r := range repos {
+		result = append(result, repoResponse{ID: r.ID, FullName: r.FullName, DefaultBranch: r.DefaultBranch})
+	}
+	return result, nil
+}
+
+// gqlSnapshot returns a snapshot's metadata, or nil if it doesn't exist or
+// belongs to another tenant.
+func (h *Handler) gqlSnapshot(ctx context.Context, id string) (any, error) {
+	sn, err := h.tenantSvc.GetSnapshotByID(ctx, id)
+	if err != nil {
+		return nilIfNotFound(err)
+	}
+	if !CallerFrom(ctx).Owns(sn.TenantID) {
+		return nil, nil
+	}
+	return snapshotRowToMeta(sn), nil
+}
+
+// gqlDelta is gqlSnapshot for deltas.
+func (h *Handler) gqlDelta(ctx context.Context, id string) (any, error) {
+	d, err := h.tenantSvc.GetDeltaByID(ctx, id)
+	if err != nil {
+		return nilIfNotFound(err)
+	}
+	if !CallerFrom(ctx).Owns(d.TenantID) {
+		return nil, nil
+	}
+	return deltaMetaResponse{
+		ID:             d.ID,
+		RepoID:         d.RepoID,
+		BaseSnapshotID: d.BaseSnapshotID,
+		HeadSnapshotID: d.HeadSnapshotID,
+		AddedNodes:     d.AddedNodes,
+		RemovedNodes:   d.RemovedNodes,
+		AddedEdges:     d.AddedEdges,
+		RemovedEdges:   d.RemovedEdges,
+		CreatedAt:      d.CreatedAt.Format("2006-01-02T15:04:05Z"),
+		row:            d,
+	}, nil
+}
+
+// nilIfNotFound resolves a missing row to null, like the REST API's 404.
+func nilIfNotFound(err error) (any, error) {
+	if strings.Contains(err.Error(), "no rows") {
+		return nil, nil
+	}
+	return nil, err
+}
+
+func argInt(args map[string]any, name string) (int, bool) {
+	switch v := args[name].(type) {
+	case int:
+		return v, true
+	case float64: // from JSON variables
+		if v == float64(int(v)) {
+			return int(v), true
+		}
+	}
+	return 0, false
+}
+
+// argValues converts field arguments to query parameters: lists become
+// repeated parameters and null arguments are dropped.
+func argValues(args map[string]any) url.Values {
+	q := url.Values{}
+	var add func(name string, v any)
+	add = func(name string, v any) {
+		switch v := v.(type) {
+		case nil:
+		case []any:
+			for _, elem := range v {
+				add(name, elem)
+			}
+		case string:
+			q.Add(name, v)
+		case float64:
+			q.Add(name, strconv.FormatFloat(v, 'f', -1, 64))
+		default:
+			q.Add(name, fmt.Sprint(v))
+		}
+	}
+	for name, v := range args {
+		add(name, v)
+	}
+	return q
+}
+
+// handleGraphQL handles GET and POST /api/graphql. POST takes a JSON body
+// with query, variables, and operationName; GET takes them as query
+// parameters, with variables JSON-encoded. Only queries are supported.
+func (h *Handler) handleGraphQL(w http.ResponseWriter, r *http.Request) {
+	var req graphql.Request
+	if r.Method == http.MethodPost {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid request body: "+err.Error())
+			return
+		}
+	} else {
+		q := r.URL.Query()
+		req.Query = q.Get("query")
+		req.OperationName = q.Get("operationName")
+		if v := q.Get("variables"); v != "" {
+			if err := json.Unmarshal([]byte(v), &req.Variables); err != nil {
+				writeError(w, http.StatusBadRequest, "invalid variables: "+err.Error())
+				return
+			}
+		}
+	}
+	if req.Query == "" {
+		writeError(w, http.StatusBadRequest, "query is required")
+		return
+	}
+	writeJSON(w, http.StatusOK, h.gql.Execute(r.Context(), req))
+}
@@ -0,0 +1,25 @@
+package api
+
+import "testing"
+
+func TestArgValues(t *testing.T) {
+	q := argValues(map[string]any{
+		"root":    "//app:server",
+		"kinds":   []any{"go_library", "go_binary"},
+		"depth":   2,
+		"weight":  1.5,
+		"ignored": nil,
+	})
+	if got := q.Encode(); got != "depth=2&kinds=go_library&kinds=go_binary&root=%2F%2Fapp%3Aserver&weight=1.5" {
+		t.Errorf("argValues = %s", got)
+	}
+}
+
+func TestArgInt(t *testing.T) {
+	args := map[string]any{"literal": 3, "variable": 4.0, "fraction": 4.5, "text": "5"}
+	for name, want := range map[string]bool{"literal": true, "variable": true, "fraction": false, "text": false, "missing": false} {
+		if _, ok := argInt(args, name); ok != want {
+			t.Errorf("argInt(%s) ok = %v, want %v", name, ok, want)
+		}
+	}
+}
@@ -7,16 +7,29 @@ import (
 	"encoding/json"
 	"net/http"
 
+	"github.com/toposcope/toposcope/internal/graphql"
 	"github.com/toposcope/toposcope/internal/ingestion"
 	"github.com/toposcope/toposcope/internal/tenant"
+	"github.com/toposcope/toposcope/pkg/graph"
+	"github.com/toposcope/toposcope/pkg/graphquery"
 )
 
 // Handler is the top-level API handler for the hosted Toposcope service.
 type Handler struct {
 	db           *sql.DB
 	tenantSvc    *tenant.Service
+	owners       ownerLookup // tenantSvc, for the authorize checks
 	ingestionSvc *ingestion.Service
 	cache        *SnapshotCache
+	layouts      *layoutCache
+	gql          *graphql.Schema
+
+	// IndexDir, when set, holds a memory-mapped compact index per snapshot
+	// (see graph.Compact). Subgraph and ego queries walk the index instead
+	// of decoding the snapshot onto the heap. Indexes are built on first use.
+	IndexDir string
+	// Version is the server's build version, reported by GET /api/v2/meta.
+	Version string
 }
 
 // NewHandler creates a new API handler.
@@ -24,34 +37,238 @@ func NewHandler(db *sql.DB, tenantSvc *tenant.Service, ingestionSvc *ingestion.S
 	if cache == nil {
 		cache = NewSnapshotCacheFromEnv()
 	}
-	return &Handler{
+	h := &Handler{
 		db:           db,
 		tenantSvc:    tenantSvc,
+		owners:       tenantSvc,
 		ingestionSvc: ingestionSvc,
 		cache:        cache,
+		layouts:      newLayoutCache(100),
 	}
+	h.gql = h.graphqlSchema()
+	return h
 }
 
 // RegisterRoutes registers all API routes on the given ServeMux.
 func (h *Handler) RegisterRoutes(mux *http.ServeMux) {
-	// Write endpoints (auth-protected)
-	mux.HandleFunc("POST /api/v1/ingest", h.handleIngest)
-	mux.HandleFunc("POST /api/v1/snapshots", h.handleUploadSnapshot)
-	mux.HandleFunc("POST /api/v1/rescore", h.handleRescore)
-	mux.HandleFunc("PATCH /api/repos/{repoID}", h.handleUpdateRepo)
-	mux.HandleFunc("DELETE /api/repos/{repoID}", h.handleDeleteRepo)
+	for _, rt := range h.routes() {
+		mux.HandleFunc(rt.method+" "+rt.path, rt.handle)
+		if rt.legacy != "" {
+			mux.HandleFunc(rt.method+" "+rt.legacy, deprecated(rt.path, rt.handle))
+		}
+	}
+	mux.HandleFunc("GET /api/openapi.json", h.handleOpenAPI)
+}
+
+// route is an API endpoint, with the description of it published in the
+// OpenAPI document.
+type route struct {
+	method, path string
+	legacy       string // path before /api/v2, served with deprecation headers
+	handle       http.HandlerFunc
+	id           string // operationId
+	summary      string
+	// query lists query parameters as name[:type], with type one of
+	// integer, boolean, or array (of strings) and string by default. A
+	// trailing "!" marks a required parameter.
+	query        []string
+	request      any    // JSON request body; nil if there is none
+	optionalBody bool   // the request body may be omitted
+	body         string // content type of a non-JSON request body
+	status       int    // success status; default 200
+	response     any    // success response body
+}
+
+type statusResponse struct {
+	Status string `json:"status"`
+}
 
-	// Read endpoints
-	mux.HandleFunc("GET /api/repos", h.handleListRepos)
-	mux.HandleFunc("GET /api/repos/{repoID}/scores", h.handleListScores)
-	mux.HandleFunc("GET /api/repos/{repoID}/scores/{scoreID}", h.handleGetScore)
-	mux.HandleFunc("GET /api/repos/{repoID}/history", h.handleHistory)
-	mux.HandleFunc("GET /api/repos/{repoID}/prs/{prNumber}/impact", h.handlePRImpact)
-	mux.HandleFunc("GET /api/snapshots/{snapshotID}", h.handleGetSnapshot)
-	mux.HandleFunc("GET /api/snapshots/{snapshotID}/subgraph", h.handleSubgraph)
-	mux.HandleFunc("GET /api/snapshots/{snapshotID}/packages", h.handlePackages)
-	mux.HandleFunc("GET /api/snapshots/{snapshotID}/ego", h.handleEgo)
-	mux.HandleFunc("GET /api/snapshots/{snapshotID}/path", h.handlePath)
+type snapshotIDResponse struct {
+	SnapshotID string `json:"snapshot_id"`
+}
+
+var graphParams = []string{"root:array", "depth:integer", "max_nodes:integer", "page_token"}
+
+func (h *Handler) routes() []route {
+	return []route{
+		// Write endpoints (auth-protected)
+		{method: "POST", path: "/api/v2/ingest", legacy: "/api/v1/ingest", handle: h.handleIngest, id: "ingest",
+			summary: "Ingest a commit's snapshot and, for pull requests, its score",
+			request: ingestRequest{}, response: ingestResponse{}},
+		{method: "POST", path: "/api/v2/snapshots", legacy: "/api/v1/snapshots", handle: h.handleUploadSnapshot, id: "uploadSnapshot",
+			summary: "Upload a snapshot for a later ingest to reference",
+			request: graph.Snapshot{}, response: snapshotIDResponse{}},
+		{method: "POST", path: "/api/v2/bundles", legacy: "/api/v1/bundles", handle: h.handleImportBundle, id: "importBundle",
+			summary: "Import a bundle written by toposcope bundle export",
+			body:    "application/gzip", response: bundleImportResponse{}},
+		{method: "POST", path: "/api/v2/rescore", legacy: "/api/v1/rescore", handle: h.handleRescore, id: "rescore",
+			summary: "Recompute stored scores with the current scoring engine",
+			request: rescoreRequest{}, optionalBody: true, response: rescoreResponse{}},
+		{method: "POST", path: "/api/v2/repos", legacy: "/api/v1/repos", handle: h.handleCreateRepo, id: "createRepo",
+			summary: "Register a repository for CI ingest and issue its API key",
+			request: createRepoRequest{}, status: http.StatusCreated, response: createRepoResponse{}},
+		{method: "POST", path: "/api/v2/repos/{repoID}/scores/preview", legacy: "/api/v1/repos/{repoID}/scores/preview", handle: h.handlePreviewScores, id: "previewScores",
+			summary: "Rescore recent changes with a proposed configuration",
+			request: previewRequest{}, optionalBody: true, response: previewResponse{}},
+		{method: "POST", path: "/api/v2/admin/gc", legacy: "/api/v1/admin/gc", handle: h.handleGC, id: "collectGarbage",
+			summary: "Expire stuck ingestions and delete old ingestion records",
+			request: gcRequest{}, optionalBody: true, response: gcResponse{}},
+		{method: "PUT", path: "/api/v2/admin/repos/{repoID}/baseline/pin", legacy: "/api/v1/admin/repos/{repoID}/baseline", handle: h.handlePinBaseline, id: "pinBaseline",
+			summary: "Pin a repository's baseline to a snapshot",
+			request: pinBaselineRequest{}, response: pinBaselineResponse{}},
+		{method: "DELETE", path: "/api/v2/admin/repos/{repoID}/baseline/pin", legacy: "/api/v1/admin/repos/{repoID}/baseline/pin", handle: h.handleUnpinBaseline, id: "unpinBaseline",
+			summary:  "Unpin a repository's baseline",
+			response: pinBaselineResponse{}},
+		{method: "POST", path: "/api/v2/admin/repos/{repoID}/api-keys", legacy: "/api/v1/admin/repos/{repoID}/api-keys", handle: h.handleRotateRepoKey, id: "rotateRepoKey",
+			summary: "Issue a new repository API key",
+			request: rotateKeyRequest{}, optionalBody: true, response: rotateKeyResponse{}},
+		{method: "POST", path: "/api/v2/admin/repos/{repoID}/restore", legacy: "/api/v1/admin/repos/{repoID}/restore", handle: h.handleRestoreRepo, id: "restoreRepo",
+			summary:  "Restore a deleted repository",
+			response: repoResponse{}},
+		{method: "DELETE", path: "/api/v2/admin/tenants/{tenantID}", legacy: "/api/v1/admin/tenants/{tenantID}", handle: h.handleDeleteTenant, id: "deleteTenant",
+			summary:  "Delete a tenant and its repositories",
+			response: statusResponse{}},
+		{method: "POST", path: "/api/v2/admin/purge", legacy: "/api/v1/admin/purge", handle: h.handlePurge, id: "purge",
+			summary: "Permanently remove deleted repositories and tenants",
+			request: purgeRequest{}, optionalBody: true, response: purgeResponse{}},
+		{method: "PATCH", path: "/api/v2/repos/{repoID}", legacy: "/api/repos/{repoID}", handle: h.handleUpdateRepo, id: "updateRepo",
+			summary: "Change a repository's default branch",
+			request: updateRepoRequest{}, response: statusResponse{}},
+		{method: "DELETE", path: "/api/v2/repos/{repoID}", legacy: "/api/repos/{repoID}", handle: h.handleDeleteRepo, id: "deleteRepo",
+			summary:  "Delete a repository",
+			response: statusResponse{}},
+		{method: "PATCH", path: "/api/v2/repos/{repoID}/settings", legacy: "/api/repos/{repoID}/settings", handle: h.handleUpdateRepoSettings, id: "updateRepoSettings",
+			summary: "Update a repository's settings",
+			request: updateRepoSettingsRequest{}, response: repoSettingsResponse{}},
+		{method: "PATCH", path: "/api/v2/repos/{repoID}/scores/{scoreID}/labels", legacy: "/api/repos/{repoID}/scores/{scoreID}/labels", handle: h.handleUpdateScoreLabels, id: "updateScoreLabels",
+			summary: "Replace a score's labels",
+			request: updateLabelsRequest{}, response: labelsResponse{}},
+		{method: "POST", path: "/api/v2/repos/{repoID}/backfills", handle: h.handleCreateBackfill, id: "createBackfill",
+			summary: "Queue extraction and scoring of sampled default-branch history",
+			request: createBackfillRequest{}, status: http.StatusAccepted, response: ingestion.BackfillJob{}},
+		{method: "DELETE", path: "/api/v2/repos/{repoID}/backfills/{jobID}", handle: h.handleCancelBackfill, id: "cancelBackfill",
+			summary:  "Cancel a backfill",
+			response: ingestion.BackfillJob{}},
+		{method: "PATCH", path: "/api/v2/snapshots/{snapshotID}/labels", legacy: "/api/snapshots/{snapshotID}/labels", handle: h.handleUpdateSnapshotLabels, id: "updateSnapshotLabels",
+			summary: "Replace a snapshot's labels",
+			request: updateLabelsRequest{}, response: labelsResponse{}},
+
+		// Read endpoints
+		{method: "GET", path: "/api/v2/meta", legacy: "/api/v1/meta", handle: h.handleMeta, id: "getMeta",
+			summary:  "Get the server version and the client versions it accepts",
+			response: metaResponse{}},
+		{method: "GET", path: "/api/v2/repos", legacy: "/api/repos", handle: h.handleListRepos, id: "listRepos",
+			summary:  "List repositories",
+			response: []repoResponse{}},
+		{method: "GET", path: "/api/v2/repos/{repoID}/settings", legacy: "/api/repos/{repoID}/settings", handle: h.handleGetRepoSettings, id: "getRepoSettings",
+			summary:  "Get a repository's settings",
+			response: repoSettingsResponse{}},
+		{method: "GET", path: "/api/v2/repos/{repoID}/scores", legacy: "/api/repos/{repoID}/scores", handle: h.handleListScores, id: "listScores",
+			summary: "List a repository's scores, newest first",
+			query:   []string{"label"}, response: []scoreResponse{}},
+		{method: "GET", path: "/api/v2/repos/{repoID}/scores/{scoreID}", legacy: "/api/repos/{repoID}/scores/{scoreID}", handle: h.handleGetScore, id: "getScore",
+			summary:  "Get a score",
+			response: scoreResponse{}},
+		{method: "GET", path: "/api/v2/repos/{repoID}/history", legacy: "/api/repos/{repoID}/history", handle: h.handleHistory, id: "getHistory",
+			summary: "Get the default branch's score history",
+			query:   []string{"granularity", "order", "branch", "label"}, response: []historyEntry{}},
+		{method: "GET", path: "/api/v2/repos/{repoID}/baseline", legacy: "/api/repos/{repoID}/baseline", handle: h.handleGetBaseline, id: "getBaseline",
+			summary:  "Get the snapshot pull requests are scored against",
+			response: baselineResponse{}},
+		{method: "GET", path: "/api/v2/repos/{repoID}/baseline/drift", legacy: "/api/repos/{repoID}/baseline/drift", handle: h.handleBaselineDrift, id: "getBaselineDrift",
+			summary:  "Get the last drift check of a repository's baseline",
+			response: baselineDriftResponse{}},
+		{method: "GET", path: "/api/v2/repos/{repoID}/backfills", handle: h.handleListBackfills, id: "listBackfills",
+			summary:  "List a repository's backfills, newest first",
+			response: []ingestion.BackfillJob{}},
+		{method: "GET", path: "/api/v2/repos/{repoID}/backfills/{jobID}", handle: h.handleGetBackfill, id: "getBackfill",
+			summary:  "Get a backfill and its progress",
+			response: ingestion.BackfillJob{}},
+		{method: "GET", path: "/api/v2/scores/{scoreID}/evidence", legacy: "/api/v1/scores/{scoreID}/evidence", handle: h.handleScoreEvidence, id: "getScoreEvidence",
+			summary: "Get the evidence behind a score's metrics",
+			query:   []string{"metric"}, response: scoreEvidenceResponse{}},
+		{method: "GET", path: "/api/v2/scores/{scoreID}/plan", handle: h.handleScorePlan, id: "getScorePlan",
+			summary: "Propose refactorings that would lower a score",
+			query:   []string{"steps:integer", "format"}, response: planResponse{}},
+		{method: "GET", path: "/api/v2/repos/{repoID}/prs/{prNumber}/impact", legacy: "/api/repos/{repoID}/prs/{prNumber}/impact", handle: h.handlePRImpact, id: "getPRImpact",
+			summary:  "Get a pull request's latest score",
+			response: scoreResponse{}},
+		{method: "GET", path: "/api/v2/repos/{repoID}/prs/{prNumber}/findings", legacy: "/api/repos/{repoID}/prs/{prNumber}/findings", handle: h.handlePRFindings, id: "getPRFindings",
+			summary: "List a pull request's findings, new or existing",
+			query:   []string{"status"}, response: prFindingsResponse{}},
+		{method: "GET", path: "/api/v2/snapshots/{snapshotID}", legacy: "/api/snapshots/{snapshotID}", handle: compressed(h.handleGetSnapshot), id: "getSnapshot",
+			summary:  "Get a snapshot's graph",
+			response: graph.Snapshot{}},
+		{method: "GET", path: "/api/v2/snapshots/{snapshotID}/download-url", legacy: "/api/snapshots/{snapshotID}/download-url", handle: h.handleSnapshotDownloadURL, id: "getSnapshotDownloadURL",
+			summary: "Get a presigned download URL for a snapshot",
+			query:   []string{"ttl"}, response: downloadURLResponse{}},
+		{method: "GET", path: "/api/v2/snapshots/{snapshotID}/subgraph", legacy: "/api/snapshots/{snapshotID}/subgraph", handle: compressed(h.handleSubgraph), id: "getSubgraph",
+			summary: "Get the neighborhood of root targets",
+			query:   graphParams, response: graphquery.SubgraphResult{}},
+		{method: "GET", path: "/api/v2/snapshots/{snapshotID}/sample", handle: compressed(h.handleSample), id: "sampleGraph",
+			summary: "Get a degree-stratified sample of the graph for overviews",
+			query:   []string{"max_nodes:integer", "seed:integer"}, response: graphquery.SubgraphResult{}},
+		{method: "GET", path: "/api/v2/snapshots/{snapshotID}/packages", legacy: "/api/snapshots/{snapshotID}/packages", handle: compressed(h.handlePackages), id: "getPackageGraph",
+			summary: "Get the package-level graph",
+			query:   []string{"hide_external:boolean", "min_edge_weight:integer", "max_packages:integer", "language:array", "layout:boolean"}, response: graphquery.PackageGraphResult{}},
+		{method: "GET", path: "/api/v2/snapshots/{snapshotID}/communities", handle: compressed(h.handleCommunities), id: "getCommunities",
+			summary: "Detect package communities and compare them with top-level boundaries",
+			query:   []string{"hide_tests:boolean", "hide_external:boolean", "min_edge_weight:integer", "max_packages:integer", "language:array"}, response: graphquery.CommunityResult{}},
+		{method: "GET", path: "/api/v2/snapshots/{snapshotID}/search", handle: compressed(h.handleSearch), id: "searchTargets",
+			summary: "Search a snapshot's targets by label, rule class, and language",
+			query:   []string{"q", "kind:array", "language:array", "limit:integer"}, response: graphquery.SearchResult{}},
+		{method: "GET", path: "/api/v2/snapshots/{snapshotID}/ego", legacy: "/api/snapshots/{snapshotID}/ego", handle: compressed(h.handleEgo), id: "getEgoGraph",
+			summary: "Get the ego graph of a target",
+			query:   []string{"target!", "depth:integer", "direction", "max_nodes:integer", "page_token"}, response: graphquery.SubgraphResult{}},
+		{method: "GET", path: "/api/v2/snapshots/{snapshotID}/path", legacy: "/api/snapshots/{snapshotID}/path", handle: compressed(h.handlePath), id: "getPaths",
+			summary: "Find dependency paths between two targets",
+			query:   []string{"from!", "to!", "max_paths:integer"}, response: graphquery.PathResult{}},
+		{method: "POST", path: "/api/v2/snapshots/{snapshotID}/simulate", handle: h.handleSimulate, id: "simulateEdgeChanges",
+			summary: "Score hypothetical edge additions and removals",
+			request: graphquery.EdgeChanges{}, response: simulateResponse{}},
+		{method: "GET", path: "/api/v2/snapshots/{snapshotID}/explain", handle: compressed(h.handleExplain), id: "explainDependency",
+			summary: "Explain why one target depends on another, with a minimum edge cut",
+			query:   []string{"from!", "to!", "max_paths:integer", "max_cut:integer"}, response: graphquery.ExplainResult{}},
+		{method: "GET", path: "/api/v2/snapshots/{snapshotID}/package-path", handle: compressed(h.handlePackagePath), id: "getPackagePaths",
+			summary: "Find dependency paths between two packages",
+			query:   []string{"from!", "to!", "max_paths:integer", "hide_tests:boolean", "hide_external:boolean"}, response: graphquery.PackagePathResult{}},
+		{method: "GET", path: "/api/v2/snapshots/{snapshotID}/nodes/{key...}", legacy: "/api/snapshots/{snapshotID}/nodes/{key...}", handle: compressed(h.handleNodeDetail), id: "getNode",
+			summary:  "Get a target and its dependencies",
+			response: nodeDetailResponse{}},
+		{method: "GET", path: "/api/v2/deltas/{deltaID}/graph", legacy: "/api/v1/deltas/{deltaID}/graph", handle: compressed(h.handleDeltaGraph), id: "getDeltaGraph",
+			summary: "Get the graph of a change",
+			query:   []string{"depth:integer", "max_nodes:integer", "hide_external:boolean"}, response: graphquery.DeltaGraphResult{}},
+		{method: "GET", path: "/api/v2/tenants/{tenantID}/usage", legacy: "/api/v1/tenants/{tenantID}/usage", handle: h.handleTenantUsage, id: "getTenantUsage",
+			summary: "Get a tenant's usage and quotas",
+			query:   []string{"months:integer"}, response: usageResponse{}},
+		{method: "GET", path: "/api/v2/tenants/{tenantID}/scores", handle: h.handleOrgScores, id: "getOrgScores",
+			summary: "Summarize the latest scores of a tenant's repositories",
+			query:   []string{"label"}, response: orgScoresResponse{}},
+		{method: "GET", path: "/api/v2/tenants/{tenantID}/graph", handle: compressed(h.handleOrgGraph), id: "getOrgGraph",
+			summary: "Get the merged package graph of a tenant's repositories",
+			query:   []string{"hide_tests:boolean", "min_edge_weight:integer", "max_packages:integer", "language:array", "layout:boolean"}, response: graphquery.OrgGraphResult{}},
+		{method: "GET", path: "/api/v2/admin/tenants", legacy: "/api/v1/admin/tenants", handle: h.handleListTenants, id: "listTenants",
+			summary:  "List tenants",
+			response: []tenantResponse{}},
+		{method: "GET", path: "/api/v2/admin/ingestions", legacy: "/api/v1/admin/ingestions", handle: h.handleListIngestions, id: "listIngestions",
+			summary: "List ingestions, newest first",
+			query:   []string{"status", "repo_id", "limit:integer"}, response: []ingestionResponse{}},
+		{method: "GET", path: "/api/v2/admin/ingestions/{ingestionID}", legacy: "/api/v1/admin/ingestions/{ingestionID}", handle: h.handleGetIngestion, id: "getIngestion",
+			summary:  "Get an ingestion",
+			response: ingestionResponse{}},
+		{method: "GET", path: "/api/v2/admin/baselines/drifted", legacy: "/api/v1/admin/baselines/drifted", handle: h.handleListDriftedBaselines, id: "listDriftedBaselines",
+			summary:  "List baselines flagged by the last drift check",
+			response: []baselineDriftResponse{}},
+
+		// GraphQL reads over the same data; POST is accepted because most
+		// clients send queries that way.
+		{method: "GET", path: "/api/graphql", handle: h.handleGraphQL, id: "graphqlGet",
+			summary: "Run a GraphQL query",
+			query:   []string{"query!", "operationName", "variables"}, response: graphqlResponse{}},
+		{method: "POST", path: "/api/graphql", handle: h.handleGraphQL, id: "graphql",
+			summary: "Run a GraphQL query",
+			request: graphqlRequest{}, response: graphqlResponse{}},
+	}
 }
 
 func writeJSON(w http.ResponseWriter, status int, data any) {
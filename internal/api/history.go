@@ -0,0 +1,272 @@
+package api
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/toposcope/toposcope/internal/tenant"
+)
+
+// Mapping from score file metric keys to the UI metric keys.
+var metricKeyMap = map[string]string{
+	"cross_package_deps": "m1_fan_in",
+	"fanout_increase":    "m2_fan_out",
+	"centrality_penalty": "m3_dep_depth",
+	"blast_radius":       "m4_visibility",
+	"cleanup_credits":    "m5_cycle",
+}
+
+type historyEntry struct {
+	Date       string             `json:"date"` // day, or first day of the ISO week for granularity=week
+	CommitSHA  string             `json:"commit_sha"`
+	ScoreID    string             `json:"score_id,omitempty"`  // granularity=commit only
+	PRNumber   *int               `json:"pr_number,omitempty"` // granularity=commit only
+	CreatedAt  string             `json:"created_at,omitempty"`
+	Author     string             `json:"author,omitempty"`  // granularity=commit only
+	Message    string             `json:"message,omitempty"` // first line; granularity=commit only
+	TotalScore float64            `json:"total_score"`
+	Grade      string             `json:"grade"`
+	Count      int                `json:"count"`
+	Metrics    map[string]float64 `json:"metrics"`
+}
+
+// handleHistory handles GET /api/repos/{repoID}/history.
+//
+// By default it returns default-branch scores aggregated by day, oldest
+// first. ?branch= selects scores whose head snapshot is on that branch
+// instead. ?granularity=commit|day|week sets the bucket size; commit returns
+// one entry per score. With ?order=ancestry and granularity=commit, scores
+// are ordered so that each comes after the score whose head it was measured
+// against, which keeps the series straight when commit timestamps are wrong
+// (rebases, imported history, skewed CI clocks).
+func (h *Handler) handleHistory(w http.ResponseWriter, r *http.Request) {
+	repoID := r.PathValue("repoID")
+	if !h.authorizeRepo(w, r, repoID) {
+		return
+	}
+
+	q := r.URL.Query()
+	granularity := q.Get("granularity")
+	if granularity == "" {
+		granularity = "day"
+	}
+	if granularity != "commit" && granularity != "day" && granularity != "week" {
+		writeError(w, http.StatusBadRequest, "granularity must be commit, day, or week")
+		return
+	}
+	order := q.Get("order")
+	if order == "" {
+		order = "time"
+	}
+	if order != "time" && order != "ancestry" {
+		writeError(w, http.StatusBadRequest, "order must be time or ancestry")
+		return
+	}
+	if order == "ancestry" && granularity != "commit" {
+		writeError(w, http.StatusBadRequest, "order=ancestry requires granularity=commit")
+		return
+	}
+
+	var scores []tenant.ScoreRow
+	var err error
+	if branch := q.Get("branch"); branch != "" {
+		scores, err = h.tenantSvc.ListBranchScores(r.Context(), repoID, branch, q.Get("label"))
+	} else {
+		// Only show default branch scores in history (exclude PR analyses)
+		scores, err = h.tenantSvc.ListDefaultBranchScores(r.Context(), repoID, q.Get("label"))
+	}
+	if err != nil {
+		writeJSON(w, http.StatusOK, []historyEntry{})
+		return
+	}
+
+	// Grade with the repository's configured thresholds (defaults if unset).
+	settings, err := h.tenantSvc.GetRepoSettings(r.Context(), repoID)
+	if err != nil {
+		log.Printf("history %s: load repo settings: %v", repoID, err)
+	}
+	grades := settings.Grades()
+
+	if granularity == "commit" {
+		if order == "ancestry" {
+			scores = orderByAncestry(scores)
+		} else {
+			sort.SliceStable(scores, func(i, j int) bool { return scores[i].CreatedAt.Before(scores[j].CreatedAt) })
+		}
+		history := make([]historyEntry, 0, len(scores))
+		for _, sc := range scores {
+			metrics := make(map[string]float64)
+			addMetrics(metrics, sc.Breakdown)
+			history = append(history, historyEntry{
+				Date:       sc.CreatedAt.Format("2006-01-02"),
+				CommitSHA:  sc.CommitSHA,
+				ScoreID:    sc.ID,
+				PRNumber:   sc.PRNumber,
+				CreatedAt:  sc.CreatedAt.Format(time.RFC3339),
+				Author:     sc.CommitAuthor,
+				Message:    commitSubject(sc.CommitMessage),
+				TotalScore: sc.TotalScore,
+				Grade:      grades.Grade(sc.TotalScore),
+				Count:      1,
+				Metrics:    metrics,
+			})
+		}
+		writeJSON(w, http.StatusOK, history)
+		return
+	}
+
+	// Aggregate by period: for each one, compute max score and max metrics.
+	type periodAgg struct {
+		date      string
+		commitSHA string // commit with the highest score
+		maxScore  float64
+		count     int
+		metrics   map[string]float64
+	}
+
+	periodMap := make(map[string]*periodAgg)
+	var periodOrder []string
+
+	for _, sc := range scores {
+		date := historyPeriod(sc.CreatedAt, granularity)
+
+		agg, exists := periodMap[date]
+		if !exists {
+			agg = &periodAgg{
+				date:    date,
+				metrics: make(map[string]float64),
+			}
+			periodMap[date] = agg
+			periodOrder = append(periodOrder, date)
+		}
+		agg.count++
+
+		// Track the commit with the highest score for this period
+		if sc.TotalScore > agg.maxScore {
+			agg.maxScore = sc.TotalScore
+			agg.commitSHA = sc.CommitSHA
+		}
+		addMetrics(agg.metrics, sc.Breakdown)
+	}
+
+	// Sort by date ascending (oldest first for charts)
+	sort.Strings(periodOrder)
+
+	history := make([]historyEntry, 0, len(periodOrder))
+	for _, date := range periodOrder {
+		agg := periodMap[date]
+		history = append(history, historyEntry{
+			Date:       agg.date,
+			CommitSHA:  agg.commitSHA,
+			TotalScore: agg.maxScore,
+			Grade:      grades.Grade(agg.maxScore),
+			Count:      agg.count,
+			Metrics:    agg.metrics,
+		})
+	}
+
+	writeJSON(w, http.StatusOK, history)
+}
+
+// commitSubject returns the first line of a commit message.
+func commitSubject(msg string) string {
+	subject, _, _ := strings.Cut(msg, "\n")
+	return strings.TrimSpace(subject)
+}
+
+// historyPeriod returns the bucket t falls into: its date, or for weeks the
+// date of the Monday starting its ISO week.
+func historyPeriod(t time.Time, granularity string) string {
+	if granularity == "week" {
+		offset := (int(t.Weekday()) + 6) % 7 // days since Monday
+		t = t.AddDate(0, 0, -offset)
+	}
+	return t.Format("2006-01-02")
+}
+
+// addMetrics parses a score breakdown and keeps the largest absolute
+// contribution of each UI metric in metrics.
+func addMetrics(metrics map[string]float64, raw json.RawMessage) {
+	var breakdown []struct {
+		Key          string  `json:"key"`
+		Contribution float64 `json:"contribution"`
+	}
+	_ = json.Unmarshal(raw, &breakdown)
+
+	for _, b := range breakdown {
+		if uiKey, ok := metricKeyMap[b.Key]; ok {
+			abs := b.Contribution
+			if abs < 0 {
+				abs = -abs
+			}
+			if abs > metrics[uiKey] {
+				metrics[uiKey] = abs
+			}
+		}
+	}
+}
+
+// orderByAncestry sorts scores topologically: a score whose base snapshot is
+// another score's head comes after it. Scores not ordered by that relation
+// keep their timestamp order.
+func orderByAncestry(scores []tenant.ScoreRow) []tenant.ScoreRow {
+	sorted := append([]tenant.ScoreRow(nil), scores...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].CreatedAt.Before(sorted[j].CreatedAt) })
+
+	byHead := make(map[string][]int, len(sorted))
+	for i, sc := range sorted {
+		byHead[sc.HeadSnapshotID] = append(byHead[sc.HeadSnapshotID], i)
+	}
+	children := make([][]int, len(sorted))
+	indegree := make([]int, len(sorted))
+	for i, sc := range sorted {
+		for _, p := range byHead[sc.BaseSnapshotID] {
+			if p != i {
+				children[p] = append(children[p], i)
+				indegree[i]++
+			}
+		}
+	}
+
+	// Kahn's algorithm, always taking the earliest ready score so unrelated
+	// scores stay in timestamp order.
+	var ready []int
+	for i := range sorted {
+		if indegree[i] == 0 {
+			ready = append(ready, i)
+		}
+	}
+	out := make([]tenant.ScoreRow, 0, len(sorted))
+	done := make([]bool, len(sorted))
+	for len(out) < len(sorted) {
+		if len(ready) == 0 {
+			// A cycle means the links are inconsistent; fall back to time for
+			// the rest.
+			for i := range sorted {
+				if !done[i] {
+					ready = append(ready, i)
+					break
+				}
+			}
+		}
+		sort.Ints(ready)
+		i := ready[0]
+		ready = ready[1:]
+		if done[i] {
+			continue
+		}
+		done[i] = true
+		out = append(out, sorted[i])
+		for _, c := range children[i] {
+			indegree[c]--
+			if indegree[c] == 0 && !done[c] {
+				ready = append(ready, c)
+			}
+		}
+	}
+	return out
+}
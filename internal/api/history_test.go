@@ -0,0 +1,57 @@
+package api
+
+import (
+	"testing"
+	"time"
+
+	"github.com/toposcope/toposcope/internal/tenant"
+)
+
+func TestHistoryPeriod(t *testing.T) {
+	sunday := time.Date(2026, 10, 11, 23, 0, 0, 0, time.UTC)
+	monday := time.Date(2026, 10, 12, 1, 0, 0, 0, time.UTC)
+	if got := historyPeriod(sunday, "week"); got != "2026-10-05" {
+		t.Errorf("week of Sunday = %s, want 2026-10-05", got)
+	}
+	if got := historyPeriod(monday, "week"); got != "2026-10-12" {
+		t.Errorf("week of Monday = %s, want 2026-10-12", got)
+	}
+	if got := historyPeriod(sunday, "day"); got != "2026-10-11" {
+		t.Errorf("day = %s", got)
+	}
+}
+
+func TestOrderByAncestry(t *testing.T) {
+	t0 := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	// c was rebased and got an earlier timestamp than b, its parent.
+	scores := []tenant.ScoreRow{
+		{ID: "b", BaseSnapshotID: "s1", HeadSnapshotID: "s2", CreatedAt: t0.Add(2 * time.Hour)},
+		{ID: "c", BaseSnapshotID: "s2", HeadSnapshotID: "s3", CreatedAt: t0},
+		{ID: "a", BaseSnapshotID: "s0", HeadSnapshotID: "s1", CreatedAt: t0.Add(time.Hour)},
+		{ID: "x", BaseSnapshotID: "other", HeadSnapshotID: "s9", CreatedAt: t0.Add(30 * time.Minute)},
+	}
+	var got []string
+	for _, sc := range orderByAncestry(scores) {
+		got = append(got, sc.ID)
+	}
+	// x is unrelated and keeps its timestamp slot ahead of a.
+	want := []string{"x", "a", "b", "c"}
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("got %v, want %v", got, want)
+		}
+	}
+}
+
+func TestOrderByAncestryCycle(t *testing.T) {
+	scores := []tenant.ScoreRow{
+		{ID: "a", BaseSnapshotID: "s2", HeadSnapshotID: "s1"},
+		{ID: "b", BaseSnapshotID: "s1", HeadSnapshotID: "s2"},
+	}
+	if got := orderByAncestry(scores); len(got) != 2 {
+		t.Errorf("cycle: got %d scores, want 2", len(got))
+	}
+}
@@ -0,0 +1,102 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/toposcope/toposcope/internal/ingestion"
+)
+
+// IdempotencyKeyHeader carries a client-chosen key on POST /api/v2/ingest.
+// Retrying an ingest with the same key and body returns the original
+// response instead of storing the upload again.
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// replayedHeader marks a response replayed for an idempotency key.
+const replayedHeader = "Idempotent-Replayed"
+
+const maxIdempotencyKeyLen = 255
+
+// validateIdempotencyKey checks that a key, if set, is at most 255 printable
+// ASCII characters.
+func validateIdempotencyKey(key string) error {
+	if len(key) > maxIdempotencyKeyLen {
+		return fmt.Errorf("%s must be at most %d characters", IdempotencyKeyHeader, maxIdempotencyKeyLen)
+	}
+	for i := 0; i < len(key); i++ {
+		if key[i] < ' ' || key[i] > '~' {
+			return fmt.Errorf("%s must be printable ASCII", IdempotencyKeyHeader)
+		}
+	}
+	return nil
+}
+
+// claimIdempotencyKey claims key for this ingest. If an earlier ingest with
+// the key completed, its response is replayed, or if the key can't be used an
+// error is written; either way replayed is true and the ingest must not run.
+// Otherwise the ingest writes through rec, and rec.finish stores its response
+// for retries.
+func (h *Handler) claimIdempotencyKey(w http.ResponseWriter, r *http.Request, repoID, key, requestHash string) (rec *idempotentWriter, replayed bool) {
+	response, err := h.ingestionSvc.ClaimIdempotencyKey(r.Context(), repoID, key, requestHash)
+	switch {
+	case errors.Is(err, ingestion.ErrIdempotencyKeyInProgress):
+		writeError(w, http.StatusConflict, err.Error())
+		return nil, true
+	case errors.Is(err, ingestion.ErrIdempotencyKeyReused):
+		writeError(w, http.StatusUnprocessableEntity, err.Error())
+		return nil, true
+	case err != nil:
+		writeError(w, http.StatusInternalServerError, "failed to check idempotency key: "+err.Error())
+		return nil, true
+	case response != nil:
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set(replayedHeader, "true")
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write(response)
+		return nil, true
+	}
+	return &idempotentWriter{ResponseWriter: w, svc: h.ingestionSvc, repoID: repoID, key: key}, false
+}
+
+// idempotentWriter records an ingest's response so it can be replayed.
+type idempotentWriter struct {
+	http.ResponseWriter
+	svc         *ingestion.Service
+	repoID, key string
+	status      int
+	body        bytes.Buffer
+}
+
+func (w *idempotentWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *idempotentWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+// finish stores a successful response for the key, or releases the key after
+// a failure so a retry runs the ingest again.
+func (w *idempotentWriter) finish(ctx context.Context) {
+	ctx = context.WithoutCancel(ctx)
+	var err error
+	if w.status == http.StatusOK {
+		err = w.svc.CompleteIdempotencyKey(ctx, w.repoID, w.key, w.body.Bytes())
+	} else {
+		err = w.svc.ReleaseIdempotencyKey(ctx, w.repoID, w.key)
+	}
+	if err != nil {
+		log.Printf("ingest %s: %v", w.repoID, err)
+	}
+}
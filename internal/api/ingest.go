@@ -2,31 +2,46 @@ package api
 
 import (
 	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"log"
 	"net/http"
 	"strings"
 	"time"
 
-	"github.com/google/uuid"
 	"github.com/toposcope/toposcope/internal/ingestion"
+	"github.com/toposcope/toposcope/internal/tenant"
 	"github.com/toposcope/toposcope/pkg/graph"
 	"github.com/toposcope/toposcope/pkg/scoring"
 )
 
 // ingestRequest is the JSON body for POST /api/v1/ingest.
 type ingestRequest struct {
-	RepoFullName   string               `json:"repo_full_name"`
-	DefaultBranch  string               `json:"default_branch"`
-	CommitSHA      string               `json:"commit_sha"`
-	Branch         string               `json:"branch"`
-	CommittedAt    string               `json:"committed_at"` // RFC3339; if set, used as timestamp instead of now()
-	Snapshot       *graph.Snapshot      `json:"snapshot"`
-	Score          *scoring.ScoreResult `json:"score"`
+	RepoFullName  string               `json:"repo_full_name"`
+	DefaultBranch string               `json:"default_branch"`
+	CommitSHA     string               `json:"commit_sha"`
+	Branch        string               `json:"branch"`
+	PRNumber      *int                 `json:"pr_number"`    // the pull request the commit was scored for, if any
+	CommittedAt   string               `json:"committed_at"` // RFC3339; if set, used as timestamp instead of now()
+	Snapshot      *graph.Snapshot      `json:"snapshot"`
+	Score         *scoring.ScoreResult `json:"score"`
+	// SignedScore is the score signed by the runner that computed it. When
+	// set, score is ignored.
+	SignedScore    *scoring.SignedScore `json:"signed_score"`
 	BaseSnapshot   *graph.Snapshot      `json:"base_snapshot"`
 	SnapshotID     string               `json:"snapshot_id"`
 	BaseSnapshotID string               `json:"base_snapshot_id"`
+	// BuildEvents is a build event protocol JSON stream from building the
+	// head commit. Its target durations annotate the head snapshot.
+	BuildEvents string `json:"build_events"`
+	// ExecutionLog is bazel's JSON execution log from the same build. Its
+	// per-target cache hits annotate the head snapshot.
+	ExecutionLog string `json:"execution_log"`
 }
 
 type ingestResponse struct {
@@ -64,8 +79,9 @@ func (h *Handler) handleUploadSnapshot(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Generate a storage ID and store the blob
-	snapshotID := uuid.New().String()
+	// Derive the storage ID from the content, so re-uploading the same
+	// snapshot reuses its blob
+	snapshotID := graph.ContentID(&snap)
 	// Use a synthetic tenant ID for pre-upload; the actual tenant association
 	// happens when the ingest request references this snapshot.
 	if err := h.ingestionSvc.Storage().PutSnapshot(r.Context(), "_uploads", snapshotID, data); err != nil {
@@ -73,7 +89,7 @@ func (h *Handler) handleUploadSnapshot(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	writeJSON(w, http.StatusOK, map[string]string{"snapshot_id": snapshotID})
+	writeJSON(w, http.StatusOK, snapshotIDResponse{SnapshotID: snapshotID})
 }
 
 func (h *Handler) handleIngest(w http.ResponseWriter, r *http.Request) {
@@ -89,11 +105,24 @@ func (h *Handler) handleIngest(w http.ResponseWriter, r *http.Request) {
 		body = gz
 	}
 
+	// Hash the body as it is decoded, so a retry under the same
+	// Idempotency-Key can be told apart from a different request.
+	bodyHash := sha256.New()
+	body = io.TeeReader(body, bodyHash)
 	var req ingestRequest
 	if err := json.NewDecoder(body).Decode(&req); err != nil {
 		writeError(w, http.StatusBadRequest, "invalid request body: "+err.Error())
 		return
 	}
+	if _, err := io.Copy(io.Discard, body); err != nil {
+		writeError(w, http.StatusBadRequest, "failed to read body: "+err.Error())
+		return
+	}
+	idempotencyKey := r.Header.Get(IdempotencyKeyHeader)
+	if err := validateIdempotencyKey(idempotencyKey); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
 
 	// Reference mode: load snapshot from storage if snapshot_id is provided
 	ctx := r.Context()
@@ -129,20 +158,19 @@ func (h *Handler) handleIngest(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if req.DefaultBranch == "" {
-		req.DefaultBranch = "main"
+	tenantID, repoID, ok := h.resolveIngestRepo(w, r, req.RepoFullName, &req.DefaultBranch)
+	if !ok {
+		return
 	}
-
-	// Extract org name from repo full name (e.g., "org/repo" -> "org")
-	orgName := req.RepoFullName
-	if idx := strings.Index(req.RepoFullName, "/"); idx > 0 {
-		orgName = req.RepoFullName[:idx]
+	if idempotencyKey != "" {
+		rec, replayed := h.claimIdempotencyKey(w, r, repoID, idempotencyKey, hex.EncodeToString(bodyHash.Sum(nil)))
+		if replayed {
+			return
+		}
+		defer rec.finish(ctx)
+		w = rec
 	}
-
-	// Ensure tenant and repo exist
-	tenantID, repoID, err := h.tenantSvc.EnsureTenantAndRepo(ctx, orgName, req.RepoFullName, req.DefaultBranch)
-	if err != nil {
-		writeError(w, http.StatusInternalServerError, "failed to ensure tenant/repo: "+err.Error())
+	if !h.admitIngestion(w, r, tenantID, 1) {
 		return
 	}
 
@@ -151,9 +179,25 @@ func (h *Handler) handleIngest(w http.ResponseWriter, r *http.Request) {
 		RepoID:       repoID,
 		RepoFullName: req.RepoFullName,
 		CommitSHA:    req.CommitSHA,
+		PRNumber:     req.PRNumber,
 		BaseBranch:   req.DefaultBranch,
 	}
 
+	if req.Score != nil || req.SignedScore != nil {
+		settings, err := h.tenantSvc.GetRepoSettings(ctx, repoID)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "failed to load repo settings: "+err.Error())
+			return
+		}
+		if !verifyIngestScore(w, &req, settings, &ingReq) {
+			return
+		}
+		// Grade client-computed scores with the repository's configured
+		// thresholds so hosted grades are consistent regardless of the
+		// uploader's config.
+		regrade(req.Score, settings.Grades())
+	}
+
 	// Use commit time if provided
 	if req.CommittedAt != "" {
 		if t, err := time.Parse(time.RFC3339, req.CommittedAt); err == nil {
@@ -161,9 +205,49 @@ func (h *Handler) handleIngest(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	// Scoped extractions only cover the changed neighborhood. Overlay them onto
+	// the base (or the current baseline) so the delta doesn't report every
+	// target outside the scope as removed.
+	if req.Snapshot.Partial {
+		base := req.BaseSnapshot
+		if base == nil {
+			var baselineID string
+			if err := h.db.QueryRowContext(ctx,
+				`SELECT snapshot_id FROM baselines WHERE repo_id = $1`, repoID,
+			).Scan(&baselineID); err == nil {
+				if base, err = h.loadSnapshot(ctx, baselineID); err != nil {
+					log.Printf("warning: failed to load baseline for partial merge: %v", err)
+				}
+			}
+		}
+		if base != nil {
+			req.Snapshot = graph.MergeIntoBaseline(base, req.Snapshot)
+		}
+	}
+
+	if req.BuildEvents != "" {
+		timings, err := graph.ParseBuildEvents(strings.NewReader(req.BuildEvents))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid build_events: "+err.Error())
+			return
+		}
+		graph.AnnotateTimings(req.Snapshot, timings)
+	}
+	if req.ExecutionLog != "" {
+		stats, err := graph.ParseExecutionLog(strings.NewReader(req.ExecutionLog))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid execution_log: "+err.Error())
+			return
+		}
+		graph.AnnotateCacheStats(req.Snapshot, stats)
+	}
+
 	// Store the head snapshot
 	req.Snapshot.CommitSHA = req.CommitSHA
 	req.Snapshot.Branch = req.Branch
+	// The merge and annotations above change the content, so derive the ID
+	// from what is actually stored.
+	req.Snapshot.ID = graph.ContentID(req.Snapshot)
 
 	snapData, err := json.Marshal(req.Snapshot)
 	if err != nil {
@@ -198,7 +282,7 @@ func (h *Handler) handleIngest(w http.ResponseWriter, r *http.Request) {
 		resp.BaseSnapshotID = baseSnapshotID
 
 		// Compute and store delta
-		delta := computeDelta(req.BaseSnapshot, req.Snapshot)
+		delta := graph.ComputeDelta(req.BaseSnapshot, req.Snapshot)
 		delta.BaseSnapshotID = baseSnapshotID
 		delta.HeadSnapshotID = headSnapshotID
 
@@ -223,6 +307,7 @@ func (h *Handler) handleIngest(w http.ResponseWriter, r *http.Request) {
 				return
 			}
 			resp.ScoreID = scoreID
+			go h.ingestionSvc.NotifyScore(context.WithoutCancel(ctx), ingReq, scoreID, req.Score)
 		}
 	} else if req.Score != nil {
 		// Score without base snapshot: use empty IDs for base/delta
@@ -242,14 +327,17 @@ func (h *Handler) handleIngest(w http.ResponseWriter, r *http.Request) {
 				return
 			}
 			resp.ScoreID = scoreID
+			go h.ingestionSvc.NotifyScore(context.WithoutCancel(ctx), ingReq, scoreID, req.Score)
 		}
 	}
 
-	// Update baseline if this is a push to the default branch
+	// Update baseline if this is a push to the default branch, unless an
+	// operator has pinned it
 	if req.Branch == req.DefaultBranch {
 		_, err := h.db.ExecContext(ctx,
 			`INSERT INTO baselines (repo_id, snapshot_id) VALUES ($1, $2)
-			 ON CONFLICT (repo_id) DO UPDATE SET snapshot_id = $2, updated_at = now()`,
+			 ON CONFLICT (repo_id) DO UPDATE SET snapshot_id = $2, updated_at = now()
+			 WHERE NOT baselines.pinned`,
 			repoID, headSnapshotID,
 		)
 		if err != nil {
@@ -261,47 +349,142 @@ func (h *Handler) handleIngest(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, http.StatusOK, resp)
 }
 
-// computeDelta calculates the structural difference between two snapshots.
-func computeDelta(base, head *graph.Snapshot) *graph.Delta {
-	delta := &graph.Delta{}
-
-	for key, node := range head.Nodes {
-		if _, exists := base.Nodes[key]; !exists {
-			delta.AddedNodes = append(delta.AddedNodes, *node)
+// resolveIngestRepo returns the tenant and repository an upload for
+// fullName belongs to, filling in *defaultBranch if it is empty. Repositories
+// onboarded through POST /api/v1/repos ingest with their own key, which pins
+// the tenant and repo. Otherwise they are created on first ingest. On failure
+// the error has been written and ok is false.
+func (h *Handler) resolveIngestRepo(w http.ResponseWriter, r *http.Request, fullName string, defaultBranch *string) (tenantID, repoID string, ok bool) {
+	if repo, ok := KeyRepository(r.Context()); ok {
+		if repo.FullName != fullName {
+			writeError(w, http.StatusForbidden, "api key is not valid for "+fullName)
+			return "", "", false
 		}
-	}
-	for key, node := range base.Nodes {
-		if _, exists := head.Nodes[key]; !exists {
-			delta.RemovedNodes = append(delta.RemovedNodes, *node)
+		if *defaultBranch == "" {
+			*defaultBranch = repo.DefaultBranch
 		}
+		return repo.TenantID, repo.ID, true
 	}
 
-	baseEdges := make(map[string]graph.Edge)
-	for _, e := range base.Edges {
-		baseEdges[e.EdgeKey()] = e
+	if *defaultBranch == "" {
+		*defaultBranch = "main"
+	}
+	if !h.authorizeOrg(w, r, orgFromRepo(fullName)) {
+		return "", "", false
 	}
-	headEdges := make(map[string]graph.Edge)
-	for _, e := range head.Edges {
-		headEdges[e.EdgeKey()] = e
+	tenantID, repoID, err := h.tenantSvc.EnsureTenantAndRepo(r.Context(), orgFromRepo(fullName), fullName, *defaultBranch)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to ensure tenant/repo: "+err.Error())
+		return "", "", false
 	}
+	return tenantID, repoID, true
+}
 
-	for key, edge := range headEdges {
-		if _, exists := baseEdges[key]; !exists {
-			delta.AddedEdges = append(delta.AddedEdges, edge)
-		}
+// verifyIngestScore checks the signature of a signed score against the
+// repository's signing keys and replaces req.Score with the signed one,
+// recording its provenance in ingReq. Unsigned scores are rejected if the
+// repository requires signatures. On failure the error has been written and
+// ok is false.
+func verifyIngestScore(w http.ResponseWriter, req *ingestRequest, settings *tenant.RepoSettings, ingReq *ingestion.IngestionRequest) (ok bool) {
+	subject := scoreSubject{
+		repo:       req.RepoFullName,
+		commitSHA:  req.CommitSHA,
+		snapshotID: graph.ContentID(req.Snapshot),
+		prNumber:   req.PRNumber,
 	}
-	for key, edge := range baseEdges {
-		if _, exists := headEdges[key]; !exists {
-			delta.RemovedEdges = append(delta.RemovedEdges, edge)
+	if req.BaseSnapshot != nil {
+		subject.baseCommitSHA = req.BaseSnapshot.CommitSHA
+	}
+	score, provenance, err := verifyScore(settings, subject, req.Score, req.SignedScore, time.Now())
+	if err != nil {
+		writeError(w, http.StatusForbidden, err.Error())
+		return false
+	}
+	req.Score, ingReq.Provenance = score, provenance
+	return true
+}
+
+// signedScoreMaxAge is how long after signing a signed score is accepted.
+// Scores are signed just before upload, and bundles when they are exported,
+// so this leaves time to carry a bundle to the platform.
+const signedScoreMaxAge = 7 * 24 * time.Hour
+
+// signedScoreClockSkew is how far in the future a signing time may be, to
+// allow for runner clocks running ahead of the platform's.
+const signedScoreClockSkew = 5 * time.Minute
+
+// scoreSubject is the change an uploaded score is for. A signed score is
+// only accepted if its statement names the same change.
+type scoreSubject struct {
+	repo          string
+	commitSHA     string
+	snapshotID    string // graph.ContentID of the head snapshot as uploaded
+	baseCommitSHA string // empty without a base snapshot
+	prNumber      *int
+}
+
+// verifyScore applies a repository's signing rules to an uploaded score for
+// subject. It returns the score to store, taken from signed if that is set,
+// and its provenance, which is nil for unsigned scores. Signed scores must
+// name subject and have been signed within signedScoreMaxAge of now.
+func verifyScore(settings *tenant.RepoSettings, subject scoreSubject, score *scoring.ScoreResult, signed *scoring.SignedScore, now time.Time) (*scoring.ScoreResult, *scoring.Provenance, error) {
+	if signed == nil {
+		if settings.RequireSignedScores {
+			return nil, nil, errors.New("repository requires signed scores")
 		}
+		return score, nil, nil
 	}
+	st, err := signed.Verify(settings.VerifyKeys())
+	if err != nil {
+		return nil, nil, fmt.Errorf("rejected signed score: %w", err)
+	}
+	if st.Repo != subject.repo || st.CommitSHA != subject.commitSHA {
+		return nil, nil, fmt.Errorf("rejected signed score: it is for %s at %s", st.Repo, st.CommitSHA)
+	}
+	if st.SnapshotID != subject.snapshotID {
+		return nil, nil, fmt.Errorf("rejected signed score: it is for snapshot %q, not %q", st.SnapshotID, subject.snapshotID)
+	}
+	if st.BaseCommitSHA != subject.baseCommitSHA {
+		return nil, nil, fmt.Errorf("rejected signed score: it is against base %q, not %q", st.BaseCommitSHA, subject.baseCommitSHA)
+	}
+	if !samePR(st.PRNumber, subject.prNumber) {
+		return nil, nil, fmt.Errorf("rejected signed score: it is for %s, not %s", describePR(st.PRNumber), describePR(subject.prNumber))
+	}
+	signedAt, err := time.Parse(time.RFC3339, st.Provenance.SignedAt)
+	if err != nil {
+		return nil, nil, errors.New("rejected signed score: it has no valid signing time")
+	}
+	if age := now.Sub(signedAt); age > signedScoreMaxAge {
+		return nil, nil, fmt.Errorf("rejected signed score: it was signed %s ago, more than the %s allowed", age.Round(time.Minute), signedScoreMaxAge)
+	} else if age < -signedScoreClockSkew {
+		return nil, nil, fmt.Errorf("rejected signed score: it is signed in the future, at %s", st.Provenance.SignedAt)
+	}
+	return st.Score, &st.Provenance, nil
+}
 
-	delta.Stats = graph.DeltaStats{
-		AddedNodeCount:   len(delta.AddedNodes),
-		RemovedNodeCount: len(delta.RemovedNodes),
-		AddedEdgeCount:   len(delta.AddedEdges),
-		RemovedEdgeCount: len(delta.RemovedEdges),
+// samePR reports whether a and b name the same pull request, or both none.
+func samePR(a, b *int) bool {
+	if a == nil || b == nil {
+		return a == b
 	}
+	return *a == *b
+}
 
-	return delta
+// describePR names a pull request for an error message.
+func describePR(pr *int) string {
+	if pr == nil {
+		return "no PR"
+	}
+	return fmt.Sprintf("PR #%d", *pr)
+}
+
+// regrade recomputes a score's grade under the given thresholds, honoring
+// size normalization if the score was normalized.
+func regrade(result *scoring.ScoreResult, grades scoring.GradeThresholds) {
+	score := result.TotalScore
+	if result.Normalization != scoring.NormalizationNone {
+		score = result.NormalizedScore
+	}
+	result.GradeThresholds = grades
+	result.Grade = grades.Grade(score)
 }
@@ -0,0 +1,196 @@
+package api
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/toposcope/toposcope/internal/ingestion"
+	"github.com/toposcope/toposcope/internal/tenant"
+	"github.com/toposcope/toposcope/pkg/bundle"
+	"github.com/toposcope/toposcope/pkg/graph"
+	"github.com/toposcope/toposcope/pkg/scoring"
+)
+
+func TestVerifyIngestScore(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, otherPriv, _ := ed25519.GenerateKey(nil)
+	head := &graph.Snapshot{CommitSHA: "abc", Nodes: map[string]*graph.Node{"//app:lib": {Key: "//app:lib"}}}
+	base := &graph.Snapshot{CommitSHA: "aaa", Nodes: map[string]*graph.Node{}}
+	pr := 7
+	// sign signs a statement for the request below, changed by edit.
+	sign := func(key ed25519.PrivateKey, edit func(*scoring.ScoreStatement)) *scoring.SignedScore {
+		st := &scoring.ScoreStatement{
+			Repo: "acme/app", CommitSHA: "abc",
+			SnapshotID: graph.ContentID(head), BaseCommitSHA: "aaa", PRNumber: &pr,
+			Score:      &scoring.ScoreResult{TotalScore: 4, Grade: "B"},
+			Provenance: scoring.Provenance{RunnerID: "ci-1", CLIVersion: "1.2.3", SignedAt: time.Now().UTC().Format(time.RFC3339)},
+		}
+		if edit != nil {
+			edit(st)
+		}
+		signed, err := scoring.SignScore(key, st)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return signed
+	}
+	ago := func(d time.Duration) func(*scoring.ScoreStatement) {
+		return func(st *scoring.ScoreStatement) {
+			st.Provenance.SignedAt = time.Now().Add(-d).UTC().Format(time.RFC3339)
+		}
+	}
+	trusted := &tenant.RepoSettings{SigningKeys: []string{base64.StdEncoding.EncodeToString(pub)}}
+	required := &tenant.RepoSettings{SigningKeys: trusted.SigningKeys, RequireSignedScores: true}
+	unsigned := &scoring.ScoreResult{TotalScore: 99}
+
+	for _, tc := range []struct {
+		name     string
+		settings *tenant.RepoSettings
+		score    *scoring.ScoreResult
+		signed   *scoring.SignedScore
+		wantOK   bool
+	}{
+		{"unsigned", trusted, unsigned, nil, true},
+		{"unsigned but required", required, unsigned, nil, false},
+		{"signed", required, unsigned, sign(priv, nil), true},
+		{"signed a day ago", required, nil, sign(priv, ago(24*time.Hour)), true},
+		{"untrusted key", required, nil, sign(otherPriv, nil), false},
+		{"other commit", required, nil, sign(priv, func(st *scoring.ScoreStatement) { st.CommitSHA = "def" }), false},
+		{"other repo", required, nil, sign(priv, func(st *scoring.ScoreStatement) { st.Repo = "acme/lib" }), false},
+		{"other snapshot", required, nil, sign(priv, func(st *scoring.ScoreStatement) { st.SnapshotID = graph.ContentID(base) }), false},
+		{"other base", required, nil, sign(priv, func(st *scoring.ScoreStatement) { st.BaseCommitSHA = "bbb" }), false},
+		{"other PR", required, nil, sign(priv, func(st *scoring.ScoreStatement) { other := 8; st.PRNumber = &other }), false},
+		{"no PR", required, nil, sign(priv, func(st *scoring.ScoreStatement) { st.PRNumber = nil }), false},
+		{"stale", required, nil, sign(priv, ago(signedScoreMaxAge+time.Hour)), false},
+		{"from the future", required, nil, sign(priv, ago(-time.Hour)), false},
+		{"no signing time", required, nil, sign(priv, func(st *scoring.ScoreStatement) { st.Provenance.SignedAt = "" }), false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			req := &ingestRequest{RepoFullName: "acme/app", CommitSHA: "abc", PRNumber: &pr, Snapshot: head, BaseSnapshot: base, Score: tc.score, SignedScore: tc.signed}
+			var ingReq ingestion.IngestionRequest
+			rec := httptest.NewRecorder()
+			ok := verifyIngestScore(rec, req, tc.settings, &ingReq)
+			if ok != tc.wantOK {
+				t.Fatalf("ok = %v, want %v (%d %s)", ok, tc.wantOK, rec.Code, rec.Body)
+			}
+			if !ok {
+				if rec.Code != http.StatusForbidden {
+					t.Errorf("status = %d, want 403", rec.Code)
+				}
+				return
+			}
+			if tc.signed == nil {
+				if req.Score != unsigned || ingReq.Provenance != nil {
+					t.Errorf("unsigned score changed: %+v, provenance %+v", req.Score, ingReq.Provenance)
+				}
+				return
+			}
+			if req.Score.TotalScore != 4 {
+				t.Errorf("score = %v, want the signed score", req.Score.TotalScore)
+			}
+			if p := ingReq.Provenance; p == nil || p.KeyID != scoring.KeyID(pub) || p.RunnerID != "ci-1" || p.CLIVersion != "1.2.3" {
+				t.Errorf("provenance = %+v", p)
+			}
+		})
+	}
+}
+
+func TestVerifyBundleScores(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	commits := []bundle.Commit{
+		{SHA: "aaa", Snapshot: &graph.Snapshot{CommitSHA: "aaa", Nodes: map[string]*graph.Node{}}},
+		{SHA: "bbb", Snapshot: &graph.Snapshot{CommitSHA: "bbb", Nodes: map[string]*graph.Node{"//app:lib": {Key: "//app:lib"}}}},
+		{SHA: "ccc", Snapshot: &graph.Snapshot{CommitSHA: "ccc", Nodes: map[string]*graph.Node{}}},
+	}
+	signedAt := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	signed, err := scoring.SignScore(priv, &scoring.ScoreStatement{
+		Repo: "acme/app", CommitSHA: "bbb",
+		SnapshotID: graph.ContentID(commits[1].Snapshot), BaseCommitSHA: "aaa",
+		Score:      &scoring.ScoreResult{TotalScore: 4},
+		Provenance: scoring.Provenance{RunnerID: "ci-1", SignedAt: signedAt.Format(time.RFC3339)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := signedAt.Add(time.Hour)
+	keys := []string{base64.StdEncoding.EncodeToString(pub)}
+	required := &tenant.RepoSettings{SigningKeys: keys, RequireSignedScores: true}
+	b := func(changes ...bundle.Change) *bundle.Bundle {
+		return &bundle.Bundle{Repo: "acme/app", Commits: commits, Changes: changes}
+	}
+
+	scores, err := verifyBundleScores(b(
+		bundle.Change{BaseSHA: "aaa", HeadSHA: "bbb", SignedScore: signed},
+		bundle.Change{BaseSHA: "bbb", HeadSHA: "ccc"},
+	), required, now)
+	if err != nil {
+		t.Fatalf("signed bundle rejected: %v", err)
+	}
+	if scores[0].score.TotalScore != 4 || scores[0].provenance == nil || scores[0].provenance.RunnerID != "ci-1" {
+		t.Errorf("signed change = %+v", scores[0])
+	}
+	if scores[1].score != nil {
+		t.Errorf("change without a score got %+v", scores[1])
+	}
+
+	for name, c := range map[string]bundle.Change{
+		"unsigned":     {BaseSHA: "aaa", HeadSHA: "bbb", Score: &scoring.ScoreResult{TotalScore: 0}},
+		"other commit": {BaseSHA: "aaa", HeadSHA: "ccc", SignedScore: signed},
+		"other base":   {BaseSHA: "ccc", HeadSHA: "bbb", SignedScore: signed},
+	} {
+		if _, err := verifyBundleScores(b(c), required, now); err == nil {
+			t.Errorf("%s: bundle accepted although signatures are required", name)
+		}
+	}
+	if _, err := verifyBundleScores(b(bundle.Change{BaseSHA: "aaa", HeadSHA: "bbb", SignedScore: signed}), required, signedAt.Add(signedScoreMaxAge+time.Hour)); err == nil {
+		t.Error("bundle accepted with a stale signature")
+	}
+
+	unsigned := &scoring.ScoreResult{TotalScore: 9}
+	scores, err = verifyBundleScores(b(bundle.Change{HeadSHA: "bbb", Score: unsigned}), &tenant.RepoSettings{}, now)
+	if err != nil || scores[0].score != unsigned || scores[0].provenance != nil {
+		t.Errorf("unsigned score without a requirement = %+v, %v", scores, err)
+	}
+}
+
+func TestValidateIdempotencyKey(t *testing.T) {
+	for key, ok := range map[string]bool{
+		"":                                     true,
+		"9b2f6c1e-0c4e-4bd4-a2d1-3e0f5f0e7a11": true,
+		"ci/run 42":                            true,
+		strings.Repeat("k", 255):               true,
+		strings.Repeat("k", 256):               false,
+		"tab\there":                            false,
+		"naïve":                                false,
+	} {
+		if err := validateIdempotencyKey(key); (err == nil) != ok {
+			t.Errorf("validateIdempotencyKey(%q) = %v, want ok %v", key, err, ok)
+		}
+	}
+}
+
+func TestIdempotentWriterRecords(t *testing.T) {
+	rec := httptest.NewRecorder()
+	w := &idempotentWriter{ResponseWriter: rec}
+	writeJSON(w, http.StatusOK, ingestResponse{SnapshotID: "s1"})
+	if w.status != http.StatusOK || w.body.String() != rec.Body.String() {
+		t.Errorf("recorded %d %q, sent %d %q", w.status, w.body.String(), rec.Code, rec.Body.String())
+	}
+
+	w = &idempotentWriter{ResponseWriter: httptest.NewRecorder()}
+	writeError(w, http.StatusBadRequest, "bad")
+	if w.status != http.StatusBadRequest {
+		t.Errorf("status = %d, want 400", w.status)
+	}
+}
@@ -0,0 +1,35 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/toposcope/toposcope/pkg/bundle"
+)
+
+// MinClientAPIVersion is the oldest client API version whose payloads the
+// server still accepts. Raise it when an upload format changes in a way older
+// clients get wrong, so they fail with an upgrade message instead.
+const MinClientAPIVersion = "2.0.0"
+
+// metaResponse is the JSON body for GET /api/v2/meta.
+type metaResponse struct {
+	ServerVersion       string `json:"server_version"`
+	APIVersion          string `json:"api_version"`
+	MinClientAPIVersion string `json:"min_client_api_version"`
+	BundleFormat        int    `json:"bundle_format"` // the bundle layout POST /api/v2/bundles reads
+}
+
+// handleMeta reports the server's version and the clients it accepts, so
+// clients can check they are compatible before uploading.
+func (h *Handler) handleMeta(w http.ResponseWriter, r *http.Request) {
+	version := h.Version
+	if version == "" {
+		version = "dev"
+	}
+	writeJSON(w, http.StatusOK, metaResponse{
+		ServerVersion:       version,
+		APIVersion:          APIVersion,
+		MinClientAPIVersion: MinClientAPIVersion,
+		BundleFormat:        bundle.FormatVersion,
+	})
+}
@@ -1,6 +1,13 @@
 package api
 
-import "net/http"
+import (
+	"context"
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/toposcope/toposcope/internal/tenant"
+)
 
 // AuthMode controls how write endpoints are authenticated.
 type AuthMode string
@@ -15,8 +22,9 @@ const (
 func CORS(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PATCH, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, X-API-Key")
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, X-API-Key, If-None-Match, Idempotency-Key")
+		w.Header().Set("Access-Control-Expose-Headers", "ETag")
 
 		if r.Method == "OPTIONS" {
 			w.WriteHeader(http.StatusOK)
@@ -27,32 +35,69 @@ func CORS(next http.Handler) http.Handler {
 	})
 }
 
-// APIKeyAuth returns middleware that validates the X-API-Key header.
+// RepoKeyResolver returns the repository a repository API key was issued for.
+type RepoKeyResolver func(ctx context.Context, key string) (*tenant.Repository, error)
+
+// repoKeyPaths are the endpoints a repository API key may call. Everything
+// else, including onboarding new repositories, needs the service-wide key.
+var repoKeyPaths = map[string]bool{
+	"/api/v2/ingest":    true,
+	"/api/v2/snapshots": true,
+	"/api/v2/bundles":   true,
+	"/api/v1/ingest":    true,
+	"/api/v1/snapshots": true,
+	"/api/v1/bundles":   true,
+}
+
+type repoKeyContextKey struct{}
+
+// KeyRepository returns the repository the request authenticated as, if it
+// used a repository API key.
+func KeyRepository(ctx context.Context) (*tenant.Repository, bool) {
+	repo, ok := ctx.Value(repoKeyContextKey{}).(*tenant.Repository)
+	return repo, ok
+}
+
+// APIKeyAuth returns middleware that validates the X-API-Key header against
+// the service-wide key or, on ingest endpoints, a repository API key.
 // If key is empty, the middleware is a no-op (all requests pass through).
-func APIKeyAuth(key string) func(http.Handler) http.Handler {
+func APIKeyAuth(key string, repoKeys RepoKeyResolver) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		if key == "" {
 			return next
 		}
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			if r.Header.Get("X-API-Key") != key {
-				http.Error(w, "unauthorized", http.StatusUnauthorized)
+			presented := r.Header.Get("X-API-Key")
+			if keyMatches(presented, key) {
+				next.ServeHTTP(w, r)
 				return
 			}
-			next.ServeHTTP(w, r)
+			if repoKeys != nil && repoKeyPaths[r.URL.Path] && strings.HasPrefix(presented, tenant.RepoKeyPrefix) {
+				if repo, err := repoKeys(r.Context(), presented); err == nil {
+					next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), repoKeyContextKey{}, repo)))
+					return
+				}
+			}
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
 		})
 	}
 }
 
+// keyMatches reports whether a presented key equals the expected one, in
+// constant time so response timing doesn't reveal the key.
+func keyMatches(presented, key string) bool {
+	return subtle.ConstantTimeCompare([]byte(presented), []byte(key)) == 1
+}
+
 // WriteAuth returns middleware that protects write endpoints based on the configured auth mode.
-func WriteAuth(mode AuthMode, apiKey string) func(http.Handler) http.Handler {
+func WriteAuth(mode AuthMode, apiKey string, repoKeys RepoKeyResolver) func(http.Handler) http.Handler {
 	switch mode {
 	case AuthModeNone:
 		return func(next http.Handler) http.Handler { return next }
 	case AuthModeOIDC:
 		return OIDCProxyAuth
 	default: // api-key
-		return APIKeyAuth(apiKey)
+		return APIKeyAuth(apiKey, repoKeys)
 	}
 }
 
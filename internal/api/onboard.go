@@ -0,0 +1,121 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/toposcope/toposcope/internal/tenant"
+	"github.com/toposcope/toposcope/pkg/scoring"
+)
+
+// createRepoRequest is the JSON body for POST /api/v1/repos.
+type createRepoRequest struct {
+	RepoFullName    string                   `json:"repo_full_name"`
+	DefaultBranch   string                   `json:"default_branch"`
+	Boundaries      []string                 `json:"boundaries"`
+	GradeThresholds *scoring.GradeThresholds `json:"grade_thresholds"`
+	FailOn          string                   `json:"fail_on"`
+}
+
+type createRepoResponse struct {
+	ID            string               `json:"id"`
+	TenantID      string               `json:"tenant_id"`
+	FullName      string               `json:"full_name"`
+	DefaultBranch string               `json:"default_branch"`
+	Settings      repoSettingsResponse `json:"settings"`
+	// APIKey authorizes ingest for this repository only. It is shown once.
+	APIKey string `json:"api_key"`
+}
+
+// handleCreateRepo handles POST /api/v1/repos. It registers a repository for
+// CI-based ingest with its settings and issues a repository-scoped API key.
+func (h *Handler) handleCreateRepo(w http.ResponseWriter, r *http.Request) {
+	var req createRepoRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body: "+err.Error())
+		return
+	}
+
+	if req.RepoFullName == "" {
+		writeError(w, http.StatusBadRequest, "repo_full_name is required")
+		return
+	}
+	if req.DefaultBranch == "" {
+		req.DefaultBranch = "main"
+	}
+	if req.GradeThresholds != nil {
+		if err := req.GradeThresholds.Validate(); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+	if req.FailOn != "" && !validFailOn(req.FailOn) {
+		writeError(w, http.StatusBadRequest, "fail_on must be one of A, B, C, D, F, or none")
+		return
+	}
+
+	ctx := r.Context()
+	orgName := orgFromRepo(req.RepoFullName)
+	if !h.authorizeOrg(w, r, orgName) {
+		return
+	}
+	if t, err := h.tenantSvc.GetTenantByName(ctx, orgName); err == nil {
+		if _, err := h.tenantSvc.GetRepository(ctx, t.ID, req.RepoFullName); err == nil {
+			writeError(w, http.StatusConflict, "repository already registered")
+			return
+		}
+	}
+
+	tenantID, repoID, err := h.tenantSvc.EnsureTenantAndRepo(ctx, orgName, req.RepoFullName, req.DefaultBranch)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to create repository: "+err.Error())
+		return
+	}
+
+	failOn := strings.ToUpper(req.FailOn)
+	if failOn == "NONE" {
+		failOn = "none"
+	}
+	settings := &tenant.RepoSettings{
+		GradeThresholds: req.GradeThresholds,
+		Boundaries:      req.Boundaries,
+		FailOn:          failOn,
+	}
+	if err := h.tenantSvc.UpdateRepoSettings(ctx, repoID, settings); err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to save settings: "+err.Error())
+		return
+	}
+
+	key, err := h.tenantSvc.CreateRepoAPIKey(ctx, tenantID, repoID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to issue api key: "+err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, createRepoResponse{
+		ID:            repoID,
+		TenantID:      tenantID,
+		FullName:      req.RepoFullName,
+		DefaultBranch: req.DefaultBranch,
+		Settings:      repoSettingsToResponse(settings),
+		APIKey:        key,
+	})
+}
+
+// orgFromRepo returns the owner of a repository full name
+// (e.g., "org/repo" -> "org"), which names its tenant.
+func orgFromRepo(fullName string) string {
+	if idx := strings.Index(fullName, "/"); idx > 0 {
+		return fullName[:idx]
+	}
+	return fullName
+}
+
+func validFailOn(grade string) bool {
+	switch strings.ToUpper(grade) {
+	case "A", "B", "C", "D", "F", "NONE":
+		return true
+	}
+	return false
+}
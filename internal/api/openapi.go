@@ -0,0 +1,194 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/toposcope/toposcope/pkg/jsonschema"
+)
+
+// APIVersion is the version of the REST API in the OpenAPI document. Bump
+// the major version for changes that break existing clients.
+const APIVersion = "2.0.0"
+
+type openAPIDocument struct {
+	OpenAPI    string                           `json:"openapi"`
+	Info       openAPIInfo                      `json:"info"`
+	Paths      map[string]map[string]*operation `json:"paths"`
+	Components openAPIComponents                `json:"components"`
+}
+
+type openAPIInfo struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+type openAPIComponents struct {
+	Schemas         map[string]*jsonschema.Schema `json:"schemas"`
+	SecuritySchemes map[string]securityScheme     `json:"securitySchemes"`
+}
+
+type securityScheme struct {
+	Type   string `json:"type"`
+	Scheme string `json:"scheme,omitempty"`
+	In     string `json:"in,omitempty"`
+	Name   string `json:"name,omitempty"`
+}
+
+type operation struct {
+	OperationID string                `json:"operationId"`
+	Summary     string                `json:"summary"`
+	Parameters  []parameter           `json:"parameters,omitempty"`
+	RequestBody *requestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]response   `json:"responses"`
+	Security    []map[string][]string `json:"security,omitempty"`
+	Deprecated  bool                  `json:"deprecated,omitempty"`
+}
+
+type parameter struct {
+	Name     string             `json:"name"`
+	In       string             `json:"in"`
+	Required bool               `json:"required,omitempty"`
+	Schema   *jsonschema.Schema `json:"schema"`
+}
+
+type requestBody struct {
+	Required bool                 `json:"required"`
+	Content  map[string]mediaType `json:"content"`
+}
+
+type response struct {
+	Description string               `json:"description"`
+	Content     map[string]mediaType `json:"content,omitempty"`
+}
+
+type mediaType struct {
+	Schema *jsonschema.Schema `json:"schema"`
+}
+
+// errorResponse is the body of every error response.
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// credentials lists the ways to authenticate, any one of which suffices.
+var credentials = []map[string][]string{{"apiKey": {}}, {"bearer": {}}}
+
+// OpenAPI returns the OpenAPI 3.1 document describing the REST API, built
+// from the same route table that RegisterRoutes serves.
+func OpenAPI() []byte {
+	schemas := jsonschema.NewComponents("#/components/schemas/")
+	doc := openAPIDocument{
+		OpenAPI: "3.1.0",
+		Info:    openAPIInfo{Title: "Toposcope API", Version: APIVersion},
+		Paths:   make(map[string]map[string]*operation),
+	}
+	for _, rt := range (&Handler{}).routes() {
+		describe(&doc, schemas, rt, rt.path, rt.id)
+		if rt.legacy != "" {
+			op := describe(&doc, schemas, rt, rt.legacy, rt.id+"Legacy")
+			op.Deprecated = true
+			op.Summary += " (use " + rt.path + ")"
+		}
+	}
+
+	doc.Components = openAPIComponents{
+		Schemas: schemas.Defs(),
+		SecuritySchemes: map[string]securityScheme{
+			"apiKey": {Type: "apiKey", In: "header", Name: "X-API-Key"},
+			"bearer": {Type: "http", Scheme: "bearer"},
+		},
+	}
+
+	data, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		panic(err) // the document is built from plain values
+	}
+	return append(data, '\n')
+}
+
+// describe adds the operation serving rt at pattern to doc.
+func describe(doc *openAPIDocument, schemas *jsonschema.Components, rt route, pattern, id string) *operation {
+	op := &operation{
+		OperationID: id,
+		Summary:     rt.summary,
+		Responses: map[string]response{
+			"default": {Description: "Error", Content: jsonContent(schemas.Schema(errorResponse{}))},
+		},
+	}
+
+	path := strings.ReplaceAll(pattern, "...}", "}")
+	for _, seg := range strings.Split(path, "/") {
+		if name, ok := strings.CutPrefix(seg, "{"); ok {
+			op.Parameters = append(op.Parameters, parameter{
+				Name: strings.TrimSuffix(name, "}"), In: "path", Required: true,
+				Schema: &jsonschema.Schema{Type: "string"},
+			})
+		}
+	}
+	for _, q := range rt.query {
+		op.Parameters = append(op.Parameters, queryParameter(q))
+	}
+
+	switch {
+	case rt.body != "":
+		op.RequestBody = &requestBody{Required: true, Content: map[string]mediaType{
+			rt.body: {Schema: &jsonschema.Schema{Type: "string", Format: "binary"}},
+		}}
+	case rt.request != nil:
+		op.RequestBody = &requestBody{Required: !rt.optionalBody, Content: jsonContent(schemas.Schema(rt.request))}
+	}
+
+	status := rt.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	op.Responses[strconv.Itoa(status)] = response{
+		Description: http.StatusText(status),
+		Content:     jsonContent(schemas.Schema(rt.response)),
+	}
+
+	// Mirror the service's auth rule: writes and operator endpoints
+	// need credentials.
+	if (rt.method != "GET" && rt.path != "/api/graphql") || strings.HasPrefix(rt.path, "/api/v2/admin/") {
+		op.Security = credentials
+	}
+
+	if doc.Paths[path] == nil {
+		doc.Paths[path] = make(map[string]*operation)
+	}
+	doc.Paths[path][strings.ToLower(rt.method)] = op
+	return op
+}
+
+func queryParameter(spec string) parameter {
+	name, required := strings.CutSuffix(spec, "!")
+	name, typ, _ := strings.Cut(name, ":")
+	p := parameter{Name: name, In: "query", Required: required, Schema: &jsonschema.Schema{Type: "string"}}
+	switch typ {
+	case "integer", "boolean":
+		p.Schema.Type = typ
+	case "array":
+		p.Schema = &jsonschema.Schema{Type: "array", Items: &jsonschema.Schema{Type: "string"}}
+	}
+	return p
+}
+
+func jsonContent(s *jsonschema.Schema) map[string]mediaType {
+	return map[string]mediaType{"application/json": {Schema: s}}
+}
+
+var (
+	openAPIOnce sync.Once
+	openAPIDoc  []byte
+)
+
+// handleOpenAPI handles GET /api/openapi.json.
+func (h *Handler) handleOpenAPI(w http.ResponseWriter, r *http.Request) {
+	openAPIOnce.Do(func() { openAPIDoc = OpenAPI() })
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(openAPIDoc)
+}
@@ -0,0 +1,99 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/toposcope/toposcope/pkg/client"
+	"github.com/toposcope/toposcope/pkg/jsonschema"
+)
+
+// The checked-in document must match the route table; regenerate it with
+// `make schemas` after changing routes or response types.
+func TestOpenAPIUpToDate(t *testing.T) {
+	got, err := os.ReadFile(filepath.Join("..", "..", "schemas", "openapi.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, OpenAPI()) {
+		t.Error("schemas/openapi.json is stale; run `make schemas`")
+	}
+}
+
+func TestOpenAPIDescribesEveryRoute(t *testing.T) {
+	var doc openAPIDocument
+	if err := json.Unmarshal(OpenAPI(), &doc); err != nil {
+		t.Fatal(err)
+	}
+	ids := map[string]bool{}
+	for _, rt := range (&Handler{}).routes() {
+		op := doc.Paths[strings.ReplaceAll(rt.path, "...}", "}")][strings.ToLower(rt.method)]
+		if op == nil {
+			t.Errorf("%s %s is missing from the document", rt.method, rt.path)
+			continue
+		}
+		if ids[op.OperationID] {
+			t.Errorf("duplicate operationId %q", op.OperationID)
+		}
+		ids[op.OperationID] = true
+		if rt.legacy != "" {
+			if legacy := doc.Paths[strings.ReplaceAll(rt.legacy, "...}", "}")][strings.ToLower(rt.method)]; legacy == nil || !legacy.Deprecated {
+				t.Errorf("%s %s should be documented as deprecated", rt.method, rt.legacy)
+			}
+		}
+	}
+
+	ego := doc.Paths["/api/v2/snapshots/{snapshotID}/ego"]["get"]
+	var params []string
+	for _, p := range ego.Parameters {
+		params = append(params, p.In+":"+p.Name)
+		if p.Name == "target" && !p.Required {
+			t.Error("ego target should be required")
+		}
+	}
+	if strings.Join(params, ",") != "path:snapshotID,query:target,query:depth,query:direction,query:max_nodes,query:page_token" {
+		t.Errorf("ego parameters = %v", params)
+	}
+	if doc.Paths["/api/v2/ingest"]["post"].Security == nil || doc.Paths["/api/v2/repos"]["get"].Security != nil {
+		t.Error("only writes and operator endpoints should require credentials")
+	}
+}
+
+func TestClientMatchesAPIVersion(t *testing.T) {
+	if client.APIVersion != APIVersion {
+		t.Errorf("pkg/client targets API %s, the service serves %s", client.APIVersion, APIVersion)
+	}
+}
+
+// pkg/client declares its own copies of the response types; their JSON
+// fields must match the ones the service writes.
+func TestClientTypesMatchResponses(t *testing.T) {
+	for _, tc := range []struct{ client, api any }{
+		{client.Repo{}, repoResponse{}},
+		{client.Baseline{}, baselineResponse{}},
+		{client.Score{}, scoreResponse{}},
+		{client.DeltaStats{}, deltaStatsResponse{}},
+		{client.IngestRequest{}, ingestRequest{}},
+		{client.IngestResponse{}, ingestResponse{}},
+		{client.BundleImport{}, bundleImportResponse{}},
+	} {
+		got, want := jsonFields(tc.client), jsonFields(tc.api)
+		if got != want {
+			t.Errorf("%T fields = %s, want %s", tc.client, got, want)
+		}
+	}
+}
+
+func jsonFields(v any) string {
+	var names []string
+	for name := range jsonschema.Generate(v).Properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ",")
+}
@@ -0,0 +1,368 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/toposcope/toposcope/internal/ingestion"
+)
+
+// Operator endpoints live under /api/v1/admin/. They always require the
+// service-wide credentials (see WriteAuth) and are refused to callers scoped
+// to a tenant.
+
+// requireOperator writes a 403 and returns false if the caller is scoped to a
+// tenant.
+func (h *Handler) requireOperator(w http.ResponseWriter, r *http.Request) bool {
+	if CallerFrom(r.Context()).Scoped() {
+		writeError(w, http.StatusForbidden, "operator credentials required")
+		return false
+	}
+	return true
+}
+
+type tenantResponse struct {
+	ID                   string    `json:"id"`
+	DisplayName          string    `json:"display_name"`
+	GitHubInstallationID *int64    `json:"github_installation_id,omitempty"`
+	RepoCount            int       `json:"repo_count"`
+	CreatedAt            time.Time `json:"created_at"`
+}
+
+// handleListTenants handles GET /api/v1/admin/tenants.
+func (h *Handler) handleListTenants(w http.ResponseWriter, r *http.Request) {
+	if !h.requireOperator(w, r) {
+		return
+	}
+	tenants, err := h.tenantSvc.ListTenants(r.Context())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to list tenants: "+err.Error())
+		return
+	}
+	result := []tenantResponse{}
+	for _, t := range tenants {
+		result = append(result, tenantResponse{
+			ID:                   t.ID,
+			DisplayName:          t.DisplayName,
+			GitHubInstallationID: t.GitHubInstallationID,
+			RepoCount:            t.RepoCount,
+			CreatedAt:            t.CreatedAt,
+		})
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+type ingestionResponse struct {
+	ID           string    `json:"id"`
+	TenantID     string    `json:"tenant_id"`
+	RepoID       string    `json:"repo_id"`
+	RepoFullName string    `json:"repo_full_name"`
+	CommitSHA    string    `json:"commit_sha"`
+	PRNumber     *int      `json:"pr_number,omitempty"`
+	Status       string    `json:"status"`
+	Error        *string   `json:"error,omitempty"`
+	SnapshotID   *string   `json:"snapshot_id,omitempty"`
+	DeltaID      *string   `json:"delta_id,omitempty"`
+	ScoreID      *string   `json:"score_id,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+func ingestionToResponse(rec *ingestion.IngestionRecord) ingestionResponse {
+	return ingestionResponse{
+		ID:           rec.ID,
+		TenantID:     rec.TenantID,
+		RepoID:       rec.RepoID,
+		RepoFullName: rec.RepoFullName,
+		CommitSHA:    rec.CommitSHA,
+		PRNumber:     rec.PRNumber,
+		Status:       rec.Status,
+		Error:        rec.ErrorMessage,
+		SnapshotID:   rec.SnapshotID,
+		DeltaID:      rec.DeltaID,
+		ScoreID:      rec.ScoreID,
+		CreatedAt:    rec.CreatedAt,
+		UpdatedAt:    rec.UpdatedAt,
+	}
+}
+
+// handleListIngestions handles GET /api/v1/admin/ingestions. Optional
+// ?status=, ?repo_id=, and ?limit= filter the result.
+func (h *Handler) handleListIngestions(w http.ResponseWriter, r *http.Request) {
+	if !h.requireOperator(w, r) {
+		return
+	}
+	q := r.URL.Query()
+	f := ingestion.IngestionFilter{Status: q.Get("status"), RepoID: q.Get("repo_id")}
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			writeError(w, http.StatusBadRequest, "limit must be a positive integer")
+			return
+		}
+		f.Limit = min(n, 1000)
+	}
+
+	recs, err := h.ingestionSvc.ListIngestions(r.Context(), f)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to list ingestions: "+err.Error())
+		return
+	}
+	result := []ingestionResponse{}
+	for i := range recs {
+		result = append(result, ingestionToResponse(&recs[i]))
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+// handleGetIngestion handles GET /api/v1/admin/ingestions/{ingestionID}.
+func (h *Handler) handleGetIngestion(w http.ResponseWriter, r *http.Request) {
+	if !h.requireOperator(w, r) {
+		return
+	}
+	rec, err := h.ingestionSvc.GetIngestion(r.Context(), r.PathValue("ingestionID"))
+	if errors.Is(err, sql.ErrNoRows) {
+		writeError(w, http.StatusNotFound, "ingestion not found")
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to get ingestion: "+err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, ingestionToResponse(rec))
+}
+
+type gcRequest struct {
+	StuckAfter string `json:"stuck_after"` // duration; default 6h
+	RetainFor  string `json:"retain_for"`  // duration; default 720h (30 days)
+	DryRun     bool   `json:"dry_run"`
+}
+
+type gcResponse struct {
+	ingestion.GCResult
+	DryRun bool `json:"dry_run"`
+}
+
+// handleGC handles POST /api/v1/admin/gc: it fails ingestions stuck in
+// QUEUED or RUNNING and deletes finished ingestion records past retention.
+func (h *Handler) handleGC(w http.ResponseWriter, r *http.Request) {
+	if !h.requireOperator(w, r) {
+		return
+	}
+	req := gcRequest{StuckAfter: "6h", RetainFor: "720h"}
+	if r.ContentLength > 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid request body: "+err.Error())
+			return
+		}
+	}
+	var opts ingestion.GCOptions
+	for _, d := range []struct {
+		name  string
+		value string
+		dst   *time.Duration
+	}{
+		{"stuck_after", req.StuckAfter, &opts.StuckAfter},
+		{"retain_for", req.RetainFor, &opts.RetainFor},
+	} {
+		if d.value == "" {
+			continue
+		}
+		parsed, err := time.ParseDuration(d.value)
+		if err != nil || parsed < 0 {
+			writeError(w, http.StatusBadRequest, d.name+" must be a non-negative duration such as 6h")
+			return
+		}
+		*d.dst = parsed
+	}
+	opts.DryRun = req.DryRun
+
+	res, err := h.ingestionSvc.CollectGarbage(r.Context(), opts)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "gc failed: "+err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, gcResponse{GCResult: res, DryRun: opts.DryRun})
+}
+
+type pinBaselineRequest struct {
+	SnapshotID string `json:"snapshot_id"`
+}
+
+type pinBaselineResponse struct {
+	RepoID     string    `json:"repo_id"`
+	SnapshotID string    `json:"snapshot_id"`
+	Pinned     bool      `json:"pinned"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// handlePinBaseline handles PUT /api/v1/admin/repos/{repoID}/baseline. It
+// points the baseline at snapshot_id and pins it until unpinned.
+func (h *Handler) handlePinBaseline(w http.ResponseWriter, r *http.Request) {
+	if !h.requireOperator(w, r) {
+		return
+	}
+	var req pinBaselineRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body: "+err.Error())
+		return
+	}
+	if req.SnapshotID == "" {
+		writeError(w, http.StatusBadRequest, "snapshot_id is required")
+		return
+	}
+
+	b, err := h.tenantSvc.PinBaseline(r.Context(), r.PathValue("repoID"), req.SnapshotID)
+	if errors.Is(err, sql.ErrNoRows) {
+		writeError(w, http.StatusNotFound, "snapshot not found in repository")
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to pin baseline: "+err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, pinBaselineResponse{RepoID: b.RepoID, SnapshotID: b.SnapshotID, Pinned: b.Pinned, UpdatedAt: b.UpdatedAt})
+}
+
+// handleUnpinBaseline handles DELETE /api/v1/admin/repos/{repoID}/baseline/pin.
+func (h *Handler) handleUnpinBaseline(w http.ResponseWriter, r *http.Request) {
+	if !h.requireOperator(w, r) {
+		return
+	}
+	b, err := h.tenantSvc.UnpinBaseline(r.Context(), r.PathValue("repoID"))
+	if errors.Is(err, sql.ErrNoRows) {
+		writeError(w, http.StatusNotFound, "repository has no baseline")
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to unpin baseline: "+err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, pinBaselineResponse{RepoID: b.RepoID, SnapshotID: b.SnapshotID, Pinned: b.Pinned, UpdatedAt: b.UpdatedAt})
+}
+
+type rotateKeyRequest struct {
+	KeepExisting bool `json:"keep_existing"` // leave old keys valid for a staged rollout
+}
+
+type rotateKeyResponse struct {
+	RepoID  string `json:"repo_id"`
+	APIKey  string `json:"api_key"` // shown once
+	Revoked int    `json:"revoked"`
+}
+
+// handleRotateRepoKey handles POST /api/v1/admin/repos/{repoID}/api-keys.
+// It issues a new repository API key and, unless keep_existing is set,
+// revokes the old ones.
+func (h *Handler) handleRotateRepoKey(w http.ResponseWriter, r *http.Request) {
+	if !h.requireOperator(w, r) {
+		return
+	}
+	var req rotateKeyRequest
+	if r.ContentLength > 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid request body: "+err.Error())
+			return
+		}
+	}
+
+	ctx := r.Context()
+	repoID := r.PathValue("repoID")
+	if _, err := h.tenantSvc.GetRepositoryByID(ctx, repoID); err != nil {
+		writeError(w, http.StatusNotFound, "repository not found")
+		return
+	}
+	key, revoked, err := h.tenantSvc.RotateRepoAPIKey(ctx, repoID, !req.KeepExisting)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to rotate api key: "+err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, rotateKeyResponse{RepoID: repoID, APIKey: key, Revoked: revoked})
+}
+
+// handleDeleteTenant handles DELETE /api/v1/admin/tenants/{tenantID}. The
+// tenant and its repositories are soft-deleted; POST /api/v1/admin/purge
+// removes their data once the retention window has passed.
+func (h *Handler) handleDeleteTenant(w http.ResponseWriter, r *http.Request) {
+	if !h.requireOperator(w, r) {
+		return
+	}
+	if err := h.tenantSvc.DeleteTenant(r.Context(), r.PathValue("tenantID")); err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			writeError(w, http.StatusNotFound, "tenant not found")
+		} else {
+			writeError(w, http.StatusInternalServerError, "failed to delete tenant: "+err.Error())
+		}
+		return
+	}
+	writeJSON(w, http.StatusOK, statusResponse{Status: "deleted"})
+}
+
+// handleRestoreRepo handles POST /api/v1/admin/repos/{repoID}/restore. It
+// undoes a repository delete that has not been purged yet.
+func (h *Handler) handleRestoreRepo(w http.ResponseWriter, r *http.Request) {
+	if !h.requireOperator(w, r) {
+		return
+	}
+	repo, err := h.tenantSvc.RestoreRepo(r.Context(), r.PathValue("repoID"))
+	if errors.Is(err, sql.ErrNoRows) {
+		writeError(w, http.StatusNotFound, "no deleted repository with that ID, or its tenant is deleted")
+		return
+	}
+	if err != nil {
+		if strings.Contains(err.Error(), "duplicate") || strings.Contains(err.Error(), "unique") {
+			writeError(w, http.StatusConflict, "a repository with that name exists; delete it first")
+		} else {
+			writeError(w, http.StatusInternalServerError, "failed to restore repository: "+err.Error())
+		}
+		return
+	}
+	writeJSON(w, http.StatusOK, repoResponse{ID: repo.ID, FullName: repo.FullName, DefaultBranch: repo.DefaultBranch})
+}
+
+type purgeRequest struct {
+	RetainFor string `json:"retain_for"` // duration; default 720h (30 days)
+	DryRun    bool   `json:"dry_run"`
+}
+
+type purgeResponse struct {
+	ingestion.PurgeResult
+	DryRun bool `json:"dry_run"`
+}
+
+// handlePurge handles POST /api/v1/admin/purge: it permanently removes
+// repositories and tenants deleted more than retain_for ago, along with
+// their stored blobs.
+func (h *Handler) handlePurge(w http.ResponseWriter, r *http.Request) {
+	if !h.requireOperator(w, r) {
+		return
+	}
+	req := purgeRequest{RetainFor: "720h"}
+	if r.ContentLength > 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid request body: "+err.Error())
+			return
+		}
+	}
+	opts := ingestion.PurgeOptions{DryRun: req.DryRun}
+	if req.RetainFor != "" {
+		d, err := time.ParseDuration(req.RetainFor)
+		if err != nil || d < 0 {
+			writeError(w, http.StatusBadRequest, "retain_for must be a non-negative duration such as 720h")
+			return
+		}
+		opts.RetainFor = d
+	}
+
+	res, err := h.ingestionSvc.Purge(r.Context(), opts)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "purge failed: "+err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, purgeResponse{PurgeResult: res, DryRun: opts.DryRun})
+}
@@ -0,0 +1,165 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"sort"
+
+	"github.com/toposcope/toposcope/internal/tenant"
+	"github.com/toposcope/toposcope/pkg/graphquery"
+)
+
+// orgRecentScores is how many default-branch scores per repository the org
+// score summary averages.
+const orgRecentScores = 10
+
+type orgScoresResponse struct {
+	TenantID string `json:"tenant_id"`
+	// Repos are ordered worst latest score first. Repositories without a
+	// default-branch score come last.
+	Repos      []orgRepoScore `json:"repos"`
+	Scored     int            `json:"scored"` // repositories with a default-branch score
+	MeanScore  float64        `json:"mean_score"`
+	GradeCount map[string]int `json:"grade_count"` // repositories by latest grade
+}
+
+type orgRepoScore struct {
+	RepoID        string              `json:"repo_id"`
+	FullName      string              `json:"full_name"`
+	DefaultBranch string              `json:"default_branch"`
+	Latest        *orgScoreSummary    `json:"latest,omitempty"`
+	RecentMean    float64             `json:"recent_mean"` // over the last 10 default-branch scores
+	Baseline      *orgBaselineSummary `json:"baseline,omitempty"`
+}
+
+type orgScoreSummary struct {
+	ID         string  `json:"id"`
+	TotalScore float64 `json:"total_score"`
+	Grade      string  `json:"grade"`
+	CommitSHA  string  `json:"commit_sha"`
+	CreatedAt  string  `json:"created_at"`
+}
+
+type orgBaselineSummary struct {
+	SnapshotID string `json:"snapshot_id"`
+	CommitSHA  string `json:"commit_sha"`
+	NodeCount  int    `json:"node_count"`
+	EdgeCount  int    `json:"edge_count"`
+}
+
+// handleOrgScores handles GET /api/v2/tenants/{tenantID}/scores: the latest
+// default-branch score and baseline of every repository of the tenant, with
+// org-wide totals.
+func (h *Handler) handleOrgScores(w http.ResponseWriter, r *http.Request) {
+	tenantID := r.PathValue("tenantID")
+	if !CallerFrom(r.Context()).Owns(tenantID) {
+		writeError(w, http.StatusNotFound, "tenant not found")
+		return
+	}
+
+	ctx := r.Context()
+	repos, err := h.tenantSvc.ListRepositories(ctx, tenantID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to list repositories: "+err.Error())
+		return
+	}
+
+	resp := orgScoresResponse{
+		TenantID:   tenantID,
+		Repos:      []orgRepoScore{},
+		GradeCount: map[string]int{},
+	}
+	var total float64
+	for _, repo := range repos {
+		rs := orgRepoScore{RepoID: repo.ID, FullName: repo.FullName, DefaultBranch: repo.DefaultBranch}
+		scores, err := h.tenantSvc.ListDefaultBranchScores(ctx, repo.ID, r.URL.Query().Get("label"))
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "failed to list scores: "+err.Error())
+			return
+		}
+		if len(scores) > 0 {
+			latest := scores[0]
+			rs.Latest = &orgScoreSummary{
+				ID:         latest.ID,
+				TotalScore: latest.TotalScore,
+				Grade:      latest.Grade,
+				CommitSHA:  latest.CommitSHA,
+				CreatedAt:  latest.CreatedAt.Format("2006-01-02T15:04:05Z"),
+			}
+			recent := scores[:min(orgRecentScores, len(scores))]
+			for _, sc := range recent {
+				rs.RecentMean += sc.TotalScore
+			}
+			rs.RecentMean /= float64(len(recent))
+			resp.Scored++
+			resp.GradeCount[latest.Grade]++
+			total += latest.TotalScore
+		}
+		if sn, err := h.tenantSvc.GetBaselineSnapshot(ctx, repo.ID); err == nil {
+			rs.Baseline = &orgBaselineSummary{SnapshotID: sn.ID, CommitSHA: sn.CommitSHA, NodeCount: sn.NodeCount, EdgeCount: sn.EdgeCount}
+		}
+		resp.Repos = append(resp.Repos, rs)
+	}
+	if resp.Scored > 0 {
+		resp.MeanScore = total / float64(resp.Scored)
+	}
+	sort.SliceStable(resp.Repos, func(i, j int) bool {
+		a, b := resp.Repos[i].Latest, resp.Repos[j].Latest
+		if (a == nil) != (b == nil) {
+			return b == nil
+		}
+		return a != nil && a.TotalScore > b.TotalScore
+	})
+
+	writeRevalidated(w, r, resp)
+}
+
+// handleOrgGraph handles GET /api/v2/tenants/{tenantID}/graph: the merged
+// package graph of the baselines of every repository of the tenant, with
+// cross-repo edges from repo_mapping or inferred from external repository
+// names. It takes the package graph query parameters.
+func (h *Handler) handleOrgGraph(w http.ResponseWriter, r *http.Request) {
+	tenantID := r.PathValue("tenantID")
+	if !CallerFrom(r.Context()).Owns(tenantID) {
+		writeError(w, http.StatusNotFound, "tenant not found")
+		return
+	}
+
+	ctx := r.Context()
+	repos, err := h.tenantSvc.ListRepositories(ctx, tenantID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to list repositories: "+err.Error())
+		return
+	}
+
+	// The graph is fixed by the baselines, so they name the response.
+	var baselines []tenant.Repository
+	var snapshotIDs []string
+	h256 := sha256.New()
+	for _, repo := range repos {
+		sn, err := h.tenantSvc.GetBaselineSnapshot(ctx, repo.ID)
+		if err != nil {
+			continue
+		}
+		baselines = append(baselines, repo)
+		snapshotIDs = append(snapshotIDs, sn.ID)
+		h256.Write([]byte(repo.FullName + "=" + snapshotCacheKey(sn) + "\n"))
+	}
+	etag := queryETag(hex.EncodeToString(h256.Sum(nil)[:16]), r)
+	if notModified(w, r, etag, revalidateCache) {
+		return
+	}
+
+	orgRepos := make([]graphquery.OrgRepo, 0, len(baselines))
+	for i, repo := range baselines {
+		snap, err := h.loadSnapshot(ctx, snapshotIDs[i])
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "failed to load baseline of "+repo.FullName+": "+err.Error())
+			return
+		}
+		orgRepos = append(orgRepos, graphquery.OrgRepo{Repo: repo.FullName, Snapshot: snap})
+	}
+
+	writeCached(w, etag, revalidateCache, graphquery.OrgGraph(orgRepos, graphquery.ParsePackageParams(r.URL.Query())))
+}
@@ -0,0 +1,89 @@
+package api
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/toposcope/toposcope/pkg/scoring"
+	"github.com/toposcope/toposcope/pkg/surface"
+)
+
+// maxPlanSteps bounds the steps one plan request may ask for.
+const maxPlanSteps = 20
+
+type planResponse struct {
+	ScoreID string `json:"score_id"`
+	*scoring.RefactoringPlan
+}
+
+// handleScorePlan handles GET /api/v2/scores/{scoreID}/plan?steps=&format=.
+// It rescores the change behind a stored score with the default weights and
+// the repository's grades, and proposes refactorings that would lower it.
+// With format=markdown the plan is returned as a markdown report instead of
+// JSON.
+func (h *Handler) handleScorePlan(w http.ResponseWriter, r *http.Request) {
+	scoreID := r.PathValue("scoreID")
+	ctx := r.Context()
+
+	sc, err := h.tenantSvc.GetScoreByID(ctx, scoreID)
+	if err != nil || !CallerFrom(ctx).Owns(sc.TenantID) {
+		writeError(w, http.StatusNotFound, "score not found")
+		return
+	}
+
+	steps := 0
+	if v := r.URL.Query().Get("steps"); v != "" {
+		if steps, err = strconv.Atoi(v); err != nil || steps < 1 || steps > maxPlanSteps {
+			writeError(w, http.StatusBadRequest, "steps must be between 1 and "+strconv.Itoa(maxPlanSteps))
+			return
+		}
+	}
+	format := r.URL.Query().Get("format")
+	if format != "" && format != "json" && format != "markdown" {
+		writeError(w, http.StatusBadRequest, "format must be json or markdown")
+		return
+	}
+
+	row, err := h.tenantSvc.GetDeltaByID(ctx, sc.DeltaID)
+	if err != nil {
+		writeError(w, http.StatusNotFound, "delta not found")
+		return
+	}
+	delta, err := h.loadDelta(ctx, row)
+	if err != nil {
+		writeError(w, http.StatusNotFound, "delta not found")
+		return
+	}
+	base, err := h.loadSnapshot(ctx, sc.BaseSnapshotID)
+	if err != nil {
+		writeError(w, http.StatusNotFound, "base snapshot not found")
+		return
+	}
+	head, err := h.loadSnapshot(ctx, sc.HeadSnapshotID)
+	if err != nil {
+		writeError(w, http.StatusNotFound, "head snapshot not found")
+		return
+	}
+
+	engine := scoring.NewEngine(scoring.DefaultMetrics()...)
+	settings, err := h.tenantSvc.GetRepoSettings(ctx, sc.RepoID)
+	if err != nil {
+		log.Printf("plan %s: load repo settings (using defaults): %v", scoreID, err)
+	}
+	engine.SetGradeThresholds(settings.Grades())
+	plan, err := engine.Plan(delta, base, head, steps)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "planning failed: "+err.Error())
+		return
+	}
+
+	if format == "markdown" {
+		w.Header().Set("Content-Type", "text/markdown; charset=utf-8")
+		if err := surface.RenderPlanMarkdown(w, plan); err != nil {
+			log.Printf("plan %s: write response: %v", scoreID, err)
+		}
+		return
+	}
+	writeJSON(w, http.StatusOK, planResponse{ScoreID: sc.ID, RefactoringPlan: plan})
+}
@@ -0,0 +1,161 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/toposcope/toposcope/pkg/scoring"
+)
+
+const (
+	defaultPreviewLimit = 20
+	maxPreviewLimit     = 100
+)
+
+// previewRequest is a proposed scoring configuration. Weights are applied on
+// top of scoring.Defaults(), so only the weights being tuned need to be set;
+// grade thresholds default to the repository's.
+type previewRequest struct {
+	Weights         json.RawMessage          `json:"weights,omitempty"`
+	GradeThresholds *scoring.GradeThresholds `json:"grade_thresholds,omitempty"`
+	Normalization   scoring.Normalization    `json:"normalization,omitempty"`
+	Limit           int                      `json:"limit,omitempty"` // most recent scores to rescore (default 20, max 100)
+}
+
+type previewScore struct {
+	TotalScore float64 `json:"total_score"`
+	Grade      string  `json:"grade"`
+}
+
+type previewEntry struct {
+	ScoreID   string                 `json:"score_id"`
+	PRNumber  *int                   `json:"pr_number,omitempty"`
+	CommitSHA string                 `json:"commit_sha"`
+	CreatedAt string                 `json:"created_at"`
+	Current   previewScore           `json:"current"`
+	Preview   previewScore           `json:"preview"`
+	Change    float64                `json:"change"` // preview minus current total score
+	Breakdown []scoring.MetricResult `json:"breakdown"`
+}
+
+type previewResponse struct {
+	Config  scoring.ScoreConfig `json:"config"`
+	Scores  []previewEntry      `json:"scores"`
+	Skipped int                 `json:"skipped"` // scores whose snapshots or delta could not be loaded
+}
+
+// handlePreviewScores handles POST /api/v1/repos/{repoID}/scores/preview.
+// It rescores the repository's most recent deltas with a proposed
+// configuration and returns the would-be scores next to the stored ones.
+// Nothing is persisted.
+func (h *Handler) handlePreviewScores(w http.ResponseWriter, r *http.Request) {
+	repoID := r.PathValue("repoID")
+	if !h.authorizeRepo(w, r, repoID) {
+		return
+	}
+	ctx := r.Context()
+
+	var req previewRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid request body: "+err.Error())
+			return
+		}
+	}
+
+	weights := scoring.Defaults()
+	if len(req.Weights) > 0 {
+		dec := json.NewDecoder(bytes.NewReader(req.Weights))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&weights); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid weights: "+err.Error())
+			return
+		}
+	}
+
+	engine := scoring.NewEngine(scoring.MetricsFromWeights(weights)...)
+	if req.GradeThresholds != nil {
+		if err := req.GradeThresholds.Validate(); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid grade_thresholds: "+err.Error())
+			return
+		}
+		engine.SetGradeThresholds(*req.GradeThresholds)
+	} else {
+		settings, err := h.tenantSvc.GetRepoSettings(ctx, repoID)
+		if err != nil {
+			log.Printf("preview %s: load repo settings (using defaults): %v", repoID, err)
+		}
+		engine.SetGradeThresholds(settings.Grades())
+	}
+	switch req.Normalization {
+	case scoring.NormalizationNone, scoring.NormalizationSize:
+		engine.SetNormalization(req.Normalization)
+	default:
+		writeError(w, http.StatusBadRequest, "invalid normalization: "+string(req.Normalization))
+		return
+	}
+
+	limit := req.Limit
+	if limit <= 0 {
+		limit = defaultPreviewLimit
+	}
+	limit = min(limit, maxPreviewLimit)
+
+	scores, err := h.tenantSvc.ListScoresByRepo(ctx, repoID, "")
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "list scores: "+err.Error())
+		return
+	}
+	if len(scores) > limit {
+		scores = scores[:limit]
+	}
+
+	resp := previewResponse{Scores: []previewEntry{}}
+	for _, sc := range scores {
+		result, err := h.previewScore(ctx, engine, sc.DeltaID, sc.BaseSnapshotID, sc.HeadSnapshotID)
+		if err != nil {
+			log.Printf("preview %s: score %s: %v", repoID, sc.ID, err)
+			resp.Skipped++
+			continue
+		}
+		if len(resp.Scores) == 0 {
+			resp.Config = result.Config()
+		}
+		resp.Scores = append(resp.Scores, previewEntry{
+			ScoreID:   sc.ID,
+			PRNumber:  sc.PRNumber,
+			CommitSHA: sc.CommitSHA,
+			CreatedAt: sc.CreatedAt.Format(time.RFC3339),
+			Current:   previewScore{TotalScore: sc.TotalScore, Grade: sc.Grade},
+			Preview:   previewScore{TotalScore: result.TotalScore, Grade: result.Grade},
+			Change:    result.TotalScore - sc.TotalScore,
+			Breakdown: result.Breakdown,
+		})
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (h *Handler) previewScore(ctx context.Context, engine *scoring.Engine, deltaID, baseID, headID string) (*scoring.ScoreResult, error) {
+	row, err := h.tenantSvc.GetDeltaByID(ctx, deltaID)
+	if err != nil {
+		return nil, err
+	}
+	delta, err := h.loadDelta(ctx, row)
+	if err != nil {
+		return nil, err
+	}
+	base, err := h.loadSnapshot(ctx, baseID)
+	if err != nil {
+		return nil, err
+	}
+	head, err := h.loadSnapshot(ctx, headID)
+	if err != nil {
+		return nil, err
+	}
+	return engine.Score(delta, base, head)
+}
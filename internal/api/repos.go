@@ -3,9 +3,9 @@ package api
 import (
 	"encoding/json"
 	"net/http"
-	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/toposcope/toposcope/internal/tenant"
 )
@@ -36,7 +36,15 @@ type scoreResponse struct {
 	Breakdown        json.RawMessage     `json:"breakdown"`
 	Hotspots         json.RawMessage     `json:"hotspots"`
 	SuggestedActions json.RawMessage     `json:"suggested_actions"`
+	Config           json.RawMessage     `json:"config,omitempty"`
+	Provenance       json.RawMessage     `json:"provenance,omitempty"` // set when the score was uploaded signed
+	Labels           []string            `json:"labels"`
+	Superseded       int                 `json:"superseded,omitempty"`
 	DeltaStats       *deltaStatsResponse `json:"delta_stats,omitempty"`
+	DeltaSummary     json.RawMessage     `json:"delta_summary,omitempty"`
+	CommitAuthor     string              `json:"commit_author,omitempty"`
+	CommitMessage    string              `json:"commit_message,omitempty"`
+	CommittedAt      string              `json:"committed_at,omitempty"`
 	CreatedAt        string              `json:"created_at"`
 }
 
@@ -53,8 +61,18 @@ func scoreRowToResponse(sc *tenant.ScoreRow) scoreResponse {
 		Breakdown:        sc.Breakdown,
 		Hotspots:         sc.Hotspots,
 		SuggestedActions: sc.SuggestedActions,
+		Config:           sc.Config,
+		Provenance:       sc.Provenance,
+		Superseded:       sc.Superseded,
+		Labels:           sc.Labels,
+		CommitAuthor:     sc.CommitAuthor,
+		CommitMessage:    sc.CommitMessage,
+		CommittedAt:      formatOptionalTime(sc.CommittedAt),
 		CreatedAt:        sc.CreatedAt.Format("2006-01-02T15:04:05Z"),
 	}
+	if resp.Labels == nil {
+		resp.Labels = []string{}
+	}
 	if sc.DeltaID != "" {
 		resp.DeltaStats = &deltaStatsResponse{
 			ImpactedTargets: sc.AddedNodes + sc.RemovedNodes,
@@ -63,12 +81,28 @@ func scoreRowToResponse(sc *tenant.ScoreRow) scoreResponse {
 			AddedEdges:      sc.AddedEdges,
 			RemovedEdges:    sc.RemovedEdges,
 		}
+		resp.DeltaSummary = sc.DeltaSummary
 	}
 	return resp
 }
 
+// formatOptionalTime formats t like other API timestamps, or returns "" if
+// it is unset.
+func formatOptionalTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.UTC().Format("2006-01-02T15:04:05Z")
+}
+
 func (h *Handler) handleListRepos(w http.ResponseWriter, r *http.Request) {
-	repos, err := h.tenantSvc.ListAllRepos(r.Context())
+	var repos []tenant.Repository
+	var err error
+	if caller := CallerFrom(r.Context()); caller.Scoped() {
+		repos, err = h.tenantSvc.ListRepositories(r.Context(), caller.TenantID)
+	} else {
+		repos, err = h.tenantSvc.ListAllRepos(r.Context())
+	}
 	if err != nil {
 		writeJSON(w, http.StatusOK, []repoResponse{})
 		return
@@ -91,8 +125,11 @@ func (h *Handler) handleListRepos(w http.ResponseWriter, r *http.Request) {
 
 func (h *Handler) handleListScores(w http.ResponseWriter, r *http.Request) {
 	repoID := r.PathValue("repoID")
+	if !h.authorizeRepo(w, r, repoID) {
+		return
+	}
 
-	scores, err := h.tenantSvc.ListScoresByRepo(r.Context(), repoID)
+	scores, err := h.tenantSvc.ListScoresByRepo(r.Context(), repoID, r.URL.Query().Get("label"))
 	if err != nil {
 		writeJSON(w, http.StatusOK, []scoreResponse{})
 		return
@@ -113,7 +150,7 @@ func (h *Handler) handleGetScore(w http.ResponseWriter, r *http.Request) {
 	scoreID := r.PathValue("scoreID")
 
 	sc, err := h.tenantSvc.GetScoreByID(r.Context(), scoreID)
-	if err != nil {
+	if err != nil || !CallerFrom(r.Context()).Owns(sc.TenantID) {
 		writeError(w, http.StatusNotFound, "score not found")
 		return
 	}
@@ -121,122 +158,51 @@ func (h *Handler) handleGetScore(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, http.StatusOK, scoreRowToResponse(sc))
 }
 
-// Mapping from score file metric keys to the UI metric keys.
-var metricKeyMap = map[string]string{
-	"cross_package_deps": "m1_fan_in",
-	"fanout_increase":    "m2_fan_out",
-	"centrality_penalty": "m3_dep_depth",
-	"blast_radius":       "m4_visibility",
-	"cleanup_credits":    "m5_cycle",
+type baselineResponse struct {
+	SnapshotID    string  `json:"snapshot_id"`
+	CommitSHA     string  `json:"commit_sha"`
+	Branch        *string `json:"branch,omitempty"`
+	NodeCount     int     `json:"node_count"`
+	EdgeCount     int     `json:"edge_count"`
+	CommitAuthor  string  `json:"commit_author,omitempty"`
+	CommitMessage string  `json:"commit_message,omitempty"`
+	CommittedAt   string  `json:"committed_at,omitempty"`
+	CreatedAt     string  `json:"created_at"`
 }
 
-type historyEntry struct {
-	Date       string             `json:"date"`
-	CommitSHA  string             `json:"commit_sha"`
-	TotalScore float64            `json:"total_score"`
-	Grade      string             `json:"grade"`
-	Count      int                `json:"count"`
-	Metrics    map[string]float64 `json:"metrics"`
-}
-
-func gradeForScore(score float64) string {
-	switch {
-	case score < 5:
-		return "A"
-	case score < 15:
-		return "B"
-	case score < 30:
-		return "C"
-	case score < 50:
-		return "D"
-	default:
-		return "F"
-	}
-}
-
-func (h *Handler) handleHistory(w http.ResponseWriter, r *http.Request) {
+// handleGetBaseline returns the snapshot a repository's pull requests are
+// currently scored against. Fetch the graph itself from
+// /api/snapshots/{snapshot_id}.
+func (h *Handler) handleGetBaseline(w http.ResponseWriter, r *http.Request) {
 	repoID := r.PathValue("repoID")
-
-	// Only show default branch scores in history (exclude PR analyses)
-	scores, err := h.tenantSvc.ListDefaultBranchScores(r.Context(), repoID)
-	if err != nil {
-		writeJSON(w, http.StatusOK, []historyEntry{})
+	if !h.authorizeRepo(w, r, repoID) {
 		return
 	}
 
-	// Aggregate by date: for each day, compute max score and sum metrics.
-	type dayAgg struct {
-		date      string
-		commitSHA string // commit with the highest score
-		maxScore  float64
-		count     int
-		metrics   map[string]float64
-	}
-
-	dayMap := make(map[string]*dayAgg)
-	var dayOrder []string
-
-	for _, sc := range scores {
-		date := sc.CreatedAt.Format("2006-01-02")
-
-		agg, exists := dayMap[date]
-		if !exists {
-			agg = &dayAgg{
-				date:    date,
-				metrics: make(map[string]float64),
-			}
-			dayMap[date] = agg
-			dayOrder = append(dayOrder, date)
-		}
-		agg.count++
-
-		// Track the commit with the highest score for this day
-		if sc.TotalScore > agg.maxScore {
-			agg.maxScore = sc.TotalScore
-			agg.commitSHA = sc.CommitSHA
-		}
-
-		// Parse breakdown and accumulate max metric values per day
-		var breakdown []struct {
-			Key          string  `json:"key"`
-			Contribution float64 `json:"contribution"`
-		}
-		_ = json.Unmarshal(sc.Breakdown, &breakdown)
-
-		for _, b := range breakdown {
-			if uiKey, ok := metricKeyMap[b.Key]; ok {
-				abs := b.Contribution
-				if abs < 0 {
-					abs = -abs
-				}
-				if abs > agg.metrics[uiKey] {
-					agg.metrics[uiKey] = abs
-				}
-			}
-		}
-	}
-
-	// Sort by date ascending (oldest first for charts)
-	sort.Strings(dayOrder)
-
-	history := make([]historyEntry, 0, len(dayOrder))
-	for _, date := range dayOrder {
-		agg := dayMap[date]
-		history = append(history, historyEntry{
-			Date:       agg.date,
-			CommitSHA:  agg.commitSHA,
-			TotalScore: agg.maxScore,
-			Grade:      gradeForScore(agg.maxScore),
-			Count:      agg.count,
-			Metrics:    agg.metrics,
-		})
+	sn, err := h.tenantSvc.GetBaselineSnapshot(r.Context(), repoID)
+	if err != nil {
+		writeError(w, http.StatusNotFound, "baseline not found")
+		return
 	}
 
-	writeJSON(w, http.StatusOK, history)
+	writeJSON(w, http.StatusOK, baselineResponse{
+		SnapshotID:    sn.ID,
+		CommitSHA:     sn.CommitSHA,
+		Branch:        sn.Branch,
+		NodeCount:     sn.NodeCount,
+		EdgeCount:     sn.EdgeCount,
+		CommitAuthor:  sn.CommitAuthor,
+		CommitMessage: sn.CommitMessage,
+		CommittedAt:   formatOptionalTime(sn.CommittedAt),
+		CreatedAt:     sn.CreatedAt.Format("2006-01-02T15:04:05Z"),
+	})
 }
 
 func (h *Handler) handlePRImpact(w http.ResponseWriter, r *http.Request) {
 	repoID := r.PathValue("repoID")
+	if !h.authorizeRepo(w, r, repoID) {
+		return
+	}
 	prStr := r.PathValue("prNumber")
 	prNumber, err := strconv.Atoi(prStr)
 	if err != nil {
@@ -2,10 +2,14 @@ package api
 
 import (
 	"encoding/json"
+	"fmt"
 	"log"
 	"net/http"
 	"path"
+	"reflect"
+	"strings"
 
+	"github.com/toposcope/toposcope/internal/ingestion"
 	"github.com/toposcope/toposcope/pkg/graph"
 	"github.com/toposcope/toposcope/pkg/scoring"
 )
@@ -17,6 +21,10 @@ type rescoreRequest struct {
 type rescoreResponse struct {
 	Rescored int `json:"rescored"`
 	Errors   int `json:"errors"`
+	// ConfigChanged counts rescored rows whose scoring configuration differs
+	// from the one they were stored with. Rows stored before configurations
+	// were recorded are not counted.
+	ConfigChanged int `json:"config_changed"`
 }
 
 // handleRescore re-runs the scoring engine on all existing score rows.
@@ -37,16 +45,25 @@ func (h *Handler) handleRescore(w http.ResponseWriter, r *http.Request) {
 	// The storage_ref format is "{kind}/{tenant_id}/{object_id}.json", so we
 	// extract the object_id to pass to the storage client.
 	query := `
-		SELECT s.id, s.tenant_id,
-			bs.storage_ref, hs.storage_ref, d.storage_ref
+		SELECT s.id, s.tenant_id, s.repo_id,
+			bs.storage_ref, COALESCE(bs.checksum, ''), hs.storage_ref, COALESCE(hs.checksum, ''),
+			d.storage_ref, s.config
 		FROM scores s
 		JOIN snapshots bs ON bs.id = s.base_snapshot_id
 		JOIN snapshots hs ON hs.id = s.head_snapshot_id
 		JOIN deltas d ON d.id = s.delta_id`
+	var conds []string
 	var args []any
 	if req.RepoID != "" {
-		query += ` WHERE s.repo_id = $1`
 		args = append(args, req.RepoID)
+		conds = append(conds, fmt.Sprintf("s.repo_id = $%d", len(args)))
+	}
+	if caller := CallerFrom(ctx); caller.Scoped() {
+		args = append(args, caller.TenantID)
+		conds = append(conds, fmt.Sprintf("s.tenant_id = $%d", len(args)))
+	}
+	if len(conds) > 0 {
+		query += ` WHERE ` + strings.Join(conds, " AND ")
 	}
 	query += ` ORDER BY s.created_at ASC`
 
@@ -60,14 +77,18 @@ func (h *Handler) handleRescore(w http.ResponseWriter, r *http.Request) {
 	type scoreRow struct {
 		ID              string
 		TenantID        string
+		RepoID          string
 		BaseStorageRef  string
+		BaseChecksum    string
 		HeadStorageRef  string
+		HeadChecksum    string
 		DeltaStorageRef string
+		Config          []byte
 	}
 	var scoreRows []scoreRow
 	for rows.Next() {
 		var sr scoreRow
-		if err := rows.Scan(&sr.ID, &sr.TenantID, &sr.BaseStorageRef, &sr.HeadStorageRef, &sr.DeltaStorageRef); err != nil {
+		if err := rows.Scan(&sr.ID, &sr.TenantID, &sr.RepoID, &sr.BaseStorageRef, &sr.BaseChecksum, &sr.HeadStorageRef, &sr.HeadChecksum, &sr.DeltaStorageRef, &sr.Config); err != nil {
 			writeError(w, http.StatusInternalServerError, "scan score row: "+err.Error())
 			return
 		}
@@ -80,6 +101,7 @@ func (h *Handler) handleRescore(w http.ResponseWriter, r *http.Request) {
 
 	engine := scoring.NewEngine(scoring.DefaultMetrics()...)
 	resp := rescoreResponse{}
+	grades := make(map[string]scoring.GradeThresholds) // per-repo thresholds
 
 	for _, sr := range scoreRows {
 		baseID := storageIDFromRef(sr.BaseStorageRef)
@@ -93,6 +115,11 @@ func (h *Handler) handleRescore(w http.ResponseWriter, r *http.Request) {
 			resp.Errors++
 			continue
 		}
+		if err := ingestion.VerifyChecksum(baseData, sr.BaseChecksum); err != nil {
+			log.Printf("rescore %s: base snapshot: %v", sr.ID, err)
+			resp.Errors++
+			continue
+		}
 		var base graph.Snapshot
 		if err := json.Unmarshal(baseData, &base); err != nil {
 			log.Printf("rescore %s: unmarshal base snapshot: %v", sr.ID, err)
@@ -107,6 +134,11 @@ func (h *Handler) handleRescore(w http.ResponseWriter, r *http.Request) {
 			resp.Errors++
 			continue
 		}
+		if err := ingestion.VerifyChecksum(headData, sr.HeadChecksum); err != nil {
+			log.Printf("rescore %s: head snapshot: %v", sr.ID, err)
+			resp.Errors++
+			continue
+		}
 		var head graph.Snapshot
 		if err := json.Unmarshal(headData, &head); err != nil {
 			log.Printf("rescore %s: unmarshal head snapshot: %v", sr.ID, err)
@@ -120,19 +152,27 @@ func (h *Handler) handleRescore(w http.ResponseWriter, r *http.Request) {
 			deltaData, err := h.ingestionSvc.Storage().GetDelta(ctx, sr.TenantID, deltaID)
 			if err != nil {
 				log.Printf("rescore %s: load delta failed (%v), recomputing from snapshots", sr.ID, err)
-				recomputed := computeDelta(&base, &head)
+				recomputed := graph.ComputeDelta(&base, &head)
 				delta = *recomputed
 			} else if err := json.Unmarshal(deltaData, &delta); err != nil {
 				log.Printf("rescore %s: unmarshal delta failed (%v), recomputing from snapshots", sr.ID, err)
-				recomputed := computeDelta(&base, &head)
+				recomputed := graph.ComputeDelta(&base, &head)
 				delta = *recomputed
 			}
 		} else {
-			recomputed := computeDelta(&base, &head)
+			recomputed := graph.ComputeDelta(&base, &head)
 			delta = *recomputed
 		}
 
-		// Re-score
+		// Re-score with the repository's grade thresholds
+		if _, ok := grades[sr.RepoID]; !ok {
+			settings, err := h.tenantSvc.GetRepoSettings(ctx, sr.RepoID)
+			if err != nil {
+				log.Printf("rescore %s: load repo settings (using defaults): %v", sr.ID, err)
+			}
+			grades[sr.RepoID] = settings.Grades()
+		}
+		engine.SetGradeThresholds(grades[sr.RepoID])
 		result, err := engine.Score(&delta, &base, &head)
 		if err != nil {
 			log.Printf("rescore %s: score: %v", sr.ID, err)
@@ -148,6 +188,9 @@ func (h *Handler) handleRescore(w http.ResponseWriter, r *http.Request) {
 		}
 
 		resp.Rescored++
+		if sr.Config != nil && !sameConfig(sr.Config, result.Config()) {
+			resp.ConfigChanged++
+		}
 	}
 
 	writeJSON(w, http.StatusOK, resp)
@@ -160,3 +203,17 @@ func storageIDFromRef(ref string) string {
 	ext := path.Ext(base)            // ".json"
 	return base[:len(base)-len(ext)] // "{id}"
 }
+
+// sameConfig reports whether a stored score config matches cfg. Both sides
+// are compared as decoded JSON, since JSONB does not preserve formatting.
+func sameConfig(stored []byte, cfg scoring.ScoreConfig) bool {
+	encoded, err := json.Marshal(cfg)
+	if err != nil {
+		return false
+	}
+	var a, b any
+	if json.Unmarshal(stored, &a) != nil || json.Unmarshal(encoded, &b) != nil {
+		return false
+	}
+	return reflect.DeepEqual(a, b)
+}
@@ -0,0 +1,73 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/toposcope/toposcope/pkg/graph"
+	"github.com/toposcope/toposcope/pkg/graphquery"
+	"github.com/toposcope/toposcope/pkg/scoring"
+)
+
+// maxSimulatedEdges bounds the edge changes in one simulation.
+const maxSimulatedEdges = 1000
+
+type simulateResponse struct {
+	*graphquery.Simulation
+	// Score is the changes scored as if they were a pull request against
+	// the snapshot, with the default weights and the repository's grades.
+	Score *scoring.ScoreResult `json:"score"`
+}
+
+// handleSimulate handles POST /api/v2/snapshots/{snapshotID}/simulate. It
+// applies hypothetical edge additions and removals to the snapshot and
+// returns the resulting score and structural changes, so a refactoring can
+// be evaluated before it is written. Nothing is persisted.
+func (h *Handler) handleSimulate(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	snapshotID := r.PathValue("snapshotID")
+
+	row, err := h.tenantSvc.GetSnapshotByID(ctx, snapshotID)
+	if err != nil || !CallerFrom(ctx).Owns(row.TenantID) {
+		writeError(w, http.StatusNotFound, "snapshot not found")
+		return
+	}
+
+	var req graphquery.EdgeChanges
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body: "+err.Error())
+		return
+	}
+	if n := len(req.Add) + len(req.Remove); n == 0 || n > maxSimulatedEdges {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("between 1 and %d edge changes required", maxSimulatedEdges))
+		return
+	}
+
+	snap, err := h.loadSnapshot(ctx, snapshotID)
+	if err != nil {
+		writeError(w, http.StatusNotFound, "snapshot not found")
+		return
+	}
+
+	sim, err := graphquery.Simulate(snap, req)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	engine := scoring.NewEngine(scoring.DefaultMetrics()...)
+	settings, err := h.tenantSvc.GetRepoSettings(ctx, row.RepoID)
+	if err != nil {
+		log.Printf("simulate %s: load repo settings (using defaults): %v", snapshotID, err)
+	}
+	engine.SetGradeThresholds(settings.Grades())
+	score, err := engine.Score(graph.ComputeDelta(snap, sim.Head), snap, sim.Head)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "scoring failed: "+err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, simulateResponse{Simulation: sim, Score: score})
+}
@@ -3,169 +3,520 @@ package api
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io/fs"
+	"log"
 	"net/http"
+	"os"
 	"path"
-	"strconv"
+	"path/filepath"
 	"strings"
+	"time"
 
+	"github.com/toposcope/toposcope/internal/ingestion"
+	"github.com/toposcope/toposcope/internal/tenant"
 	"github.com/toposcope/toposcope/pkg/graph"
 	"github.com/toposcope/toposcope/pkg/graphquery"
+	"github.com/toposcope/toposcope/pkg/scoring"
 )
 
 // loadSnapshot loads a snapshot by ID, checking the cache first,
 // then falling back to DB metadata lookup + storage client.
 func (h *Handler) loadSnapshot(ctx context.Context, snapshotID string) (*graph.Snapshot, error) {
-	// Check cache
-	if snap := h.cache.Get(snapshotID); snap != nil {
+	row, err := h.tenantSvc.GetSnapshotByID(ctx, snapshotID)
+	if err != nil {
+		return nil, fmt.Errorf("snapshot metadata: %w", err)
+	}
+	return h.loadSnapshotRow(ctx, row)
+}
+
+// loadSnapshotRow loads the snapshot stored for row. The cache is keyed by
+// the stored content, since re-ingesting a commit replaces the blob behind
+// the same row.
+func (h *Handler) loadSnapshotRow(ctx context.Context, row *tenant.SnapshotRow) (*graph.Snapshot, error) {
+	key := snapshotCacheKey(row)
+	if snap := h.cache.Get(key); snap != nil {
 		return snap, nil
 	}
 
-	// Look up metadata
-	snapshotRow, err := h.tenantSvc.GetSnapshotByID(ctx, snapshotID)
+	snap, err := h.fetchSnapshot(ctx, row)
 	if err != nil {
-		return nil, fmt.Errorf("snapshot metadata: %w", err)
+		return nil, err
 	}
 
-	// Extract the blob ID from storage_ref (format: "snapshots/{tenantID}/{blobID}.json").
-	// The blob ID may differ from the DB-generated snapshot UUID.
-	blobID := snapshotID
-	if snapshotRow.StorageRef != "" {
-		base := path.Base(snapshotRow.StorageRef)
-		blobID = strings.TrimSuffix(base, ".json")
-	}
+	// Cache it
+	h.cache.Put(key, snap)
 
-	// Load from storage
-	data, err := h.ingestionSvc.Storage().GetSnapshot(ctx, snapshotRow.TenantID, blobID)
+	return snap, nil
+}
+
+// fetchSnapshot reads and decodes the snapshot stored for row, bypassing
+// the cache.
+func (h *Handler) fetchSnapshot(ctx context.Context, row *tenant.SnapshotRow) (*graph.Snapshot, error) {
+	data, err := h.ingestionSvc.Storage().GetSnapshot(ctx, row.TenantID, snapshotBlobID(row))
 	if err != nil {
 		return nil, fmt.Errorf("load snapshot blob: %w", err)
 	}
+	if err := ingestion.VerifyChecksum(data, row.Checksum); err != nil {
+		return nil, fmt.Errorf("snapshot %s: %w", row.ID, err)
+	}
 
 	var snap graph.Snapshot
 	if err := json.Unmarshal(data, &snap); err != nil {
 		return nil, fmt.Errorf("unmarshal snapshot: %w", err)
 	}
+	return &snap, nil
+}
 
-	// Cache it
-	h.cache.Put(snapshotID, &snap)
+// openIndex maps the compact index of a snapshot from IndexDir, building it
+// from storage the first time. Indexes are named by the snapshot's version,
+// so a re-ingested snapshot gets a new one; building it removes the old. The
+// snapshot is decoded only to build the index and is not cached. The caller
+// closes the index.
+func (h *Handler) openIndex(ctx context.Context, row *tenant.SnapshotRow) (*graph.Compact, error) {
+	key := snapshotCacheKey(row)
+	path := filepath.Join(h.IndexDir, key+".idx")
+	if c, err := graph.OpenCompact(path); err == nil {
+		return c, nil
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		log.Printf("snapshot index %s: %v; rebuilding", row.ID, err)
+	}
 
-	return &snap, nil
+	snap := h.cache.Get(key)
+	if snap == nil {
+		var err error
+		if snap, err = h.fetchSnapshot(ctx, row); err != nil {
+			return nil, err
+		}
+	}
+	if err := graph.WriteCompact(path, snap); err != nil {
+		return nil, fmt.Errorf("build snapshot index: %w", err)
+	}
+	stale, _ := filepath.Glob(filepath.Join(h.IndexDir, row.ID+"*.idx"))
+	for _, p := range stale {
+		if p != path {
+			_ = os.Remove(p)
+		}
+	}
+	return graph.OpenCompact(path)
+}
+
+// snapshotETag authorizes the caller for a snapshot and returns its row and
+// the ETag of a response computed from its stored content and the query
+// string. If the snapshot isn't found it writes 404 and ok is false.
+func (h *Handler) snapshotETag(w http.ResponseWriter, r *http.Request, snapshotID string) (row *tenant.SnapshotRow, etag string, ok bool) {
+	row, err := h.tenantSvc.GetSnapshotByID(r.Context(), snapshotID)
+	if err != nil || !CallerFrom(r.Context()).Owns(row.TenantID) {
+		writeError(w, http.StatusNotFound, "snapshot not found")
+		return nil, "", false
+	}
+	return row, queryETag(snapshotVersion(row), r), true
+}
+
+// snapshotVersion identifies the content stored for a snapshot row: the
+// blob's checksum, or its blob ID for rows stored before checksums were
+// recorded. Re-ingesting a commit keeps the row ID but changes its version.
+func snapshotVersion(row *tenant.SnapshotRow) string {
+	if row.Checksum != "" {
+		return row.Checksum
+	}
+	return snapshotBlobID(row)
+}
+
+// snapshotCacheKey names a snapshot row's current content in the snapshot
+// cache and IndexDir.
+func snapshotCacheKey(row *tenant.SnapshotRow) string {
+	return row.ID + "-" + snapshotVersion(row)
+}
+
+// snapshotBlobID extracts the blob ID from storage_ref (format:
+// "snapshots/{tenantID}/{blobID}.json"). The blob ID may differ from the
+// DB-generated snapshot UUID.
+func snapshotBlobID(row *tenant.SnapshotRow) string {
+	if row.StorageRef == "" {
+		return row.ID
+	}
+	return strings.TrimSuffix(path.Base(row.StorageRef), ".json")
 }
 
 func (h *Handler) handleGetSnapshot(w http.ResponseWriter, r *http.Request) {
 	snapshotID := r.PathValue("snapshotID")
+	row, etag, ok := h.snapshotETag(w, r, snapshotID)
+	if !ok || notModified(w, r, etag, revalidateCache) {
+		return
+	}
 
-	snap, err := h.loadSnapshot(r.Context(), snapshotID)
+	snap, err := h.loadSnapshotRow(r.Context(), row)
 	if err != nil {
 		writeError(w, http.StatusNotFound, "snapshot not found")
 		return
 	}
 
-	writeJSON(w, http.StatusOK, snap)
+	writeCached(w, etag, revalidateCache, snap)
 }
 
-func (h *Handler) handleSubgraph(w http.ResponseWriter, r *http.Request) {
+const (
+	defaultDownloadURLTTL = 15 * time.Minute
+	maxDownloadURLTTL     = time.Hour
+)
+
+type downloadURLResponse struct {
+	URL       string `json:"url"`
+	ExpiresAt string `json:"expires_at"`
+}
+
+// handleSnapshotDownloadURL handles GET /api/snapshots/{snapshotID}/download-url?ttl=...
+// It returns a time-limited URL for fetching the snapshot blob directly from
+// object storage, so large graphs don't stream through the service.
+func (h *Handler) handleSnapshotDownloadURL(w http.ResponseWriter, r *http.Request) {
 	snapshotID := r.PathValue("snapshotID")
+	if !h.authorizeSnapshot(w, r, snapshotID) {
+		return
+	}
+
+	signer, ok := h.ingestionSvc.Storage().(ingestion.URLSigner)
+	if !ok {
+		writeError(w, http.StatusNotImplemented, "storage backend does not support signed URLs")
+		return
+	}
+
+	ttl := defaultDownloadURLTTL
+	if v := r.URL.Query().Get("ttl"); v != "" {
+		parsed, err := time.ParseDuration(v)
+		if err != nil || parsed <= 0 {
+			writeError(w, http.StatusBadRequest, "invalid ttl: "+v)
+			return
+		}
+		ttl = min(parsed, maxDownloadURLTTL)
+	}
 
-	snap, err := h.loadSnapshot(r.Context(), snapshotID)
+	row, err := h.tenantSvc.GetSnapshotByID(r.Context(), snapshotID)
 	if err != nil {
 		writeError(w, http.StatusNotFound, "snapshot not found")
 		return
 	}
 
-	roots := r.URL.Query()["root"]
-	depthStr := r.URL.Query().Get("depth")
-	depth := 2
-	if depthStr != "" {
-		_, _ = fmt.Sscanf(depthStr, "%d", &depth)
+	url, err := signer.SignedSnapshotURL(r.Context(), row.TenantID, snapshotBlobID(row), ttl)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to sign url: "+err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, downloadURLResponse{
+		URL:       url,
+		ExpiresAt: time.Now().Add(ttl).UTC().Format(time.RFC3339),
+	})
+}
+
+func (h *Handler) handleSubgraph(w http.ResponseWriter, r *http.Request) {
+	snapshotID := r.PathValue("snapshotID")
+	row, etag, ok := h.snapshotETag(w, r, snapshotID)
+	if !ok || notModified(w, r, etag, revalidateCache) {
+		return
+	}
+
+	params, err := graphquery.ParseSubgraphParams(r.URL.Query())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
 	}
 
-	if len(roots) == 0 {
-		result := graphquery.CapGraph(snap, 500)
-		writeJSON(w, http.StatusOK, result)
+	if h.IndexDir != "" {
+		ix, err := h.openIndex(r.Context(), row)
+		if err != nil {
+			writeError(w, http.StatusNotFound, "snapshot not found")
+			return
+		}
+		defer ix.Close()
+		result, err := graphquery.SubgraphCompact(ix, params)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "query snapshot index: "+err.Error())
+			return
+		}
+		writeCached(w, etag, revalidateCache, result)
 		return
 	}
 
-	result := graphquery.ExtractSubgraph(snap, roots, depth)
-	writeJSON(w, http.StatusOK, result)
+	snap, err := h.loadSnapshotRow(r.Context(), row)
+	if err != nil {
+		writeError(w, http.StatusNotFound, "snapshot not found")
+		return
+	}
+
+	writeCached(w, etag, revalidateCache, graphquery.Subgraph(snap, params))
 }
 
 func (h *Handler) handlePackages(w http.ResponseWriter, r *http.Request) {
 	snapshotID := r.PathValue("snapshotID")
+	row, etag, ok := h.snapshotETag(w, r, snapshotID)
+	if !ok || notModified(w, r, etag, revalidateCache) {
+		return
+	}
 
-	snap, err := h.loadSnapshot(r.Context(), snapshotID)
+	params := graphquery.ParsePackageParams(r.URL.Query())
+	if params.Layout {
+		if result := h.layouts.get(etag); result != nil {
+			writeCached(w, etag, revalidateCache, result)
+			return
+		}
+	}
+
+	snap, err := h.loadSnapshotRow(r.Context(), row)
 	if err != nil {
 		writeError(w, http.StatusNotFound, "snapshot not found")
 		return
 	}
 
-	hideTests := r.URL.Query().Get("hide_tests") == "true"
-	hideExternal := r.URL.Query().Get("hide_external") == "true"
-	minEdgeWeight := 1
-	if v := r.URL.Query().Get("min_edge_weight"); v != "" {
-		if parsed, err := strconv.Atoi(v); err == nil && parsed > 0 {
-			minEdgeWeight = parsed
-		}
+	result := graphquery.Packages(snap, params)
+	if params.Layout {
+		h.layouts.put(etag, result)
 	}
+	writeCached(w, etag, revalidateCache, result)
+}
 
-	result := graphquery.AggregatePackages(snap, hideTests, hideExternal, minEdgeWeight, 0)
-	writeJSON(w, http.StatusOK, result)
+func (h *Handler) handleCommunities(w http.ResponseWriter, r *http.Request) {
+	snapshotID := r.PathValue("snapshotID")
+	row, etag, ok := h.snapshotETag(w, r, snapshotID)
+	if !ok || notModified(w, r, etag, revalidateCache) {
+		return
+	}
+
+	snap, err := h.loadSnapshotRow(r.Context(), row)
+	if err != nil {
+		writeError(w, http.StatusNotFound, "snapshot not found")
+		return
+	}
+
+	writeCached(w, etag, revalidateCache, graphquery.Communities(snap, graphquery.ParsePackageParams(r.URL.Query())))
 }
 
-func (h *Handler) handleEgo(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) handleSample(w http.ResponseWriter, r *http.Request) {
 	snapshotID := r.PathValue("snapshotID")
+	row, etag, ok := h.snapshotETag(w, r, snapshotID)
+	if !ok || notModified(w, r, etag, revalidateCache) {
+		return
+	}
 
-	snap, err := h.loadSnapshot(r.Context(), snapshotID)
+	snap, err := h.loadSnapshotRow(r.Context(), row)
 	if err != nil {
 		writeError(w, http.StatusNotFound, "snapshot not found")
 		return
 	}
 
-	target := r.URL.Query().Get("target")
-	if target == "" {
-		writeError(w, http.StatusBadRequest, "target parameter required")
+	writeCached(w, etag, revalidateCache, graphquery.Sample(snap, graphquery.ParseSampleParams(r.URL.Query())))
+}
+
+func (h *Handler) handleEgo(w http.ResponseWriter, r *http.Request) {
+	snapshotID := r.PathValue("snapshotID")
+	row, etag, ok := h.snapshotETag(w, r, snapshotID)
+	if !ok || notModified(w, r, etag, revalidateCache) {
 		return
 	}
 
-	depth := 2
-	if v := r.URL.Query().Get("depth"); v != "" {
-		if parsed, err := strconv.Atoi(v); err == nil && parsed > 0 {
-			depth = parsed
+	params, err := graphquery.ParseEgoParams(r.URL.Query())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if h.IndexDir != "" {
+		ix, err := h.openIndex(r.Context(), row)
+		if err != nil {
+			writeError(w, http.StatusNotFound, "snapshot not found")
+			return
 		}
+		defer ix.Close()
+		result, err := graphquery.EgoCompact(ix, params)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "query snapshot index: "+err.Error())
+			return
+		}
+		writeCached(w, etag, revalidateCache, result)
+		return
 	}
 
-	direction := r.URL.Query().Get("direction")
-	if direction == "" {
-		direction = "both"
+	snap, err := h.loadSnapshotRow(r.Context(), row)
+	if err != nil {
+		writeError(w, http.StatusNotFound, "snapshot not found")
+		return
 	}
 
-	result := graphquery.EgoGraph(snap, target, depth, direction, 0)
-	writeJSON(w, http.StatusOK, result)
+	writeCached(w, etag, revalidateCache, graphquery.Ego(snap, params))
 }
 
 func (h *Handler) handlePath(w http.ResponseWriter, r *http.Request) {
 	snapshotID := r.PathValue("snapshotID")
+	row, etag, ok := h.snapshotETag(w, r, snapshotID)
+	if !ok || notModified(w, r, etag, revalidateCache) {
+		return
+	}
+
+	snap, err := h.loadSnapshotRow(r.Context(), row)
+	if err != nil {
+		writeError(w, http.StatusNotFound, "snapshot not found")
+		return
+	}
+
+	params, err := graphquery.ParsePathParams(r.URL.Query())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	writeCached(w, etag, revalidateCache, graphquery.Paths(snap, params))
+}
+
+func (h *Handler) handleExplain(w http.ResponseWriter, r *http.Request) {
+	snapshotID := r.PathValue("snapshotID")
+	row, etag, ok := h.snapshotETag(w, r, snapshotID)
+	if !ok || notModified(w, r, etag, revalidateCache) {
+		return
+	}
+
+	snap, err := h.loadSnapshotRow(r.Context(), row)
+	if err != nil {
+		writeError(w, http.StatusNotFound, "snapshot not found")
+		return
+	}
+
+	params, err := graphquery.ParseExplainParams(r.URL.Query())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	writeCached(w, etag, revalidateCache, graphquery.Explanation(snap, params))
+}
+
+func (h *Handler) handlePackagePath(w http.ResponseWriter, r *http.Request) {
+	snapshotID := r.PathValue("snapshotID")
+	row, etag, ok := h.snapshotETag(w, r, snapshotID)
+	if !ok || notModified(w, r, etag, revalidateCache) {
+		return
+	}
 
-	snap, err := h.loadSnapshot(r.Context(), snapshotID)
+	snap, err := h.loadSnapshotRow(r.Context(), row)
 	if err != nil {
 		writeError(w, http.StatusNotFound, "snapshot not found")
 		return
 	}
 
-	fromQ := r.URL.Query().Get("from")
-	toQ := r.URL.Query().Get("to")
-	if fromQ == "" || toQ == "" {
-		writeError(w, http.StatusBadRequest, "from and to parameters required")
+	params, err := graphquery.ParsePackagePathParams(r.URL.Query())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	writeCached(w, etag, revalidateCache, graphquery.PackagePaths(snap, params))
+}
+
+func (h *Handler) handleSearch(w http.ResponseWriter, r *http.Request) {
+	snapshotID := r.PathValue("snapshotID")
+	row, etag, ok := h.snapshotETag(w, r, snapshotID)
+	if !ok || notModified(w, r, etag, revalidateCache) {
+		return
+	}
+
+	snap, err := h.loadSnapshotRow(r.Context(), row)
+	if err != nil {
+		writeError(w, http.StatusNotFound, "snapshot not found")
 		return
 	}
 
-	maxPaths := 10
-	if v := r.URL.Query().Get("max_paths"); v != "" {
-		if parsed, err := strconv.Atoi(v); err == nil && parsed > 0 {
-			maxPaths = parsed
+	writeCached(w, etag, revalidateCache, graphquery.Search(snap, graphquery.ParseSearchParams(r.URL.Query())))
+}
+
+// maxNodeEvidenceScores bounds how many recent scores handleNodeDetail scans
+// for evidence.
+const maxNodeEvidenceScores = 20
+
+// nodeEvidenceRef points at an evidence item or hotspot that mentions a node.
+type nodeEvidenceRef struct {
+	ScoreID   string                `json:"score_id"`
+	PRNumber  *int                  `json:"pr_number,omitempty"`
+	CommitSHA string                `json:"commit_sha"`
+	CreatedAt string                `json:"created_at"`
+	MetricKey string                `json:"metric_key,omitempty"`
+	Evidence  *scoring.EvidenceItem `json:"evidence,omitempty"`
+	Hotspot   *scoring.Hotspot      `json:"hotspot,omitempty"`
+}
+
+type nodeDetailResponse struct {
+	*graphquery.NodeDetailResult
+	Evidence []nodeEvidenceRef `json:"evidence"`
+}
+
+// handleNodeDetail handles GET /api/snapshots/{snapshotID}/nodes/{key...}.
+// The key must be URL-encoded, since target labels contain slashes. Besides
+// the node's neighborhood, it lists evidence and hotspots that reference the
+// node in the repository's most recent scores. New scores change that list,
+// so the response is revalidated rather than cached outright.
+func (h *Handler) handleNodeDetail(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	snapshotID := r.PathValue("snapshotID")
+	key := r.PathValue("key")
+
+	row, err := h.tenantSvc.GetSnapshotByID(ctx, snapshotID)
+	if err != nil || !CallerFrom(ctx).Owns(row.TenantID) {
+		writeError(w, http.StatusNotFound, "snapshot not found")
+		return
+	}
+
+	snap, err := h.loadSnapshot(ctx, snapshotID)
+	if err != nil {
+		writeError(w, http.StatusNotFound, "snapshot not found")
+		return
+	}
+
+	detail := graphquery.NodeDetail(snap, key)
+	if detail == nil {
+		writeError(w, http.StatusNotFound, "node not found: "+key)
+		return
+	}
+
+	resp := nodeDetailResponse{NodeDetailResult: detail, Evidence: []nodeEvidenceRef{}}
+
+	scores, err := h.tenantSvc.ListScoresByRepo(ctx, row.RepoID, "")
+	if err != nil {
+		log.Printf("node detail %s: list scores: %v", snapshotID, err)
+	}
+	if len(scores) > maxNodeEvidenceScores {
+		scores = scores[:maxNodeEvidenceScores]
+	}
+	for _, sc := range scores {
+		ref := nodeEvidenceRef{
+			ScoreID:   sc.ID,
+			PRNumber:  sc.PRNumber,
+			CommitSHA: sc.CommitSHA,
+			CreatedAt: sc.CreatedAt.Format(time.RFC3339),
+		}
+
+		var breakdown []scoring.MetricResult
+		if err := json.Unmarshal(sc.Breakdown, &breakdown); err == nil {
+			for _, mr := range breakdown {
+				for _, ev := range mr.Evidence {
+					if ev.From == key || ev.To == key {
+						item := ref
+						item.MetricKey = mr.Key
+						item.Evidence = &ev
+						resp.Evidence = append(resp.Evidence, item)
+					}
+				}
+			}
+		}
+
+		var hotspots []scoring.Hotspot
+		if err := json.Unmarshal(sc.Hotspots, &hotspots); err == nil {
+			for _, hs := range hotspots {
+				if hs.NodeKey == key {
+					item := ref
+					item.Hotspot = &hs
+					resp.Evidence = append(resp.Evidence, item)
+				}
+			}
 		}
 	}
 
-	result := graphquery.FindPaths(snap, fromQ, toQ, maxPaths)
-	writeJSON(w, http.StatusOK, result)
+	writeRevalidated(w, r, resp)
 }
@@ -0,0 +1,142 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/toposcope/toposcope/internal/tenant"
+)
+
+// TenantHeader carries the caller's tenant ID when an OIDC proxy has
+// authenticated the request. It is ignored in other auth modes.
+const TenantHeader = "X-Toposcope-Tenant"
+
+// Caller identifies whose data a request may access.
+type Caller struct {
+	// TenantID restricts the caller to one tenant. Empty means every
+	// tenant: the service-wide API key, or isolation turned off.
+	TenantID string
+}
+
+// Owns reports whether the caller may access data belonging to tenantID.
+func (c Caller) Owns(tenantID string) bool {
+	return c.TenantID == "" || c.TenantID == tenantID
+}
+
+// Scoped reports whether the caller is restricted to a single tenant.
+func (c Caller) Scoped() bool {
+	return c.TenantID != ""
+}
+
+type callerContextKey struct{}
+
+// CallerFrom returns the caller resolved by ResolveTenant. Requests that did
+// not pass through it are unrestricted.
+func CallerFrom(ctx context.Context) Caller {
+	c, _ := ctx.Value(callerContextKey{}).(Caller)
+	return c
+}
+
+// ResolveTenant returns middleware that determines the caller's tenant:
+//
+//   - the service-wide API key may access every tenant;
+//   - a repository API key is scoped to the repository's tenant;
+//   - in oidc-proxy mode, the proxy-set TenantHeader names the tenant.
+//
+// When isolate is true, requests with none of these are rejected. Otherwise
+// they are unrestricted, which suits single-tenant deployments.
+func ResolveTenant(mode AuthMode, apiKey string, repoKeys RepoKeyResolver, isolate bool) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var caller Caller
+			presented := r.Header.Get("X-API-Key")
+			switch {
+			case apiKey != "" && keyMatches(presented, apiKey):
+				// Unrestricted.
+			case repoKeys != nil && strings.HasPrefix(presented, tenant.RepoKeyPrefix):
+				repo, err := repoKeys(r.Context(), presented)
+				if err != nil {
+					http.Error(w, "unauthorized", http.StatusUnauthorized)
+					return
+				}
+				caller.TenantID = repo.TenantID
+			case mode == AuthModeOIDC && r.Header.Get(TenantHeader) != "":
+				caller.TenantID = r.Header.Get(TenantHeader)
+			case isolate:
+				http.Error(w, "unauthorized: tenant credentials required", http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), callerContextKey{}, caller)))
+		})
+	}
+}
+
+// ownerLookup is the part of tenant.Service the authorize checks use to
+// find who owns a resource.
+type ownerLookup interface {
+	GetRepositoryByID(ctx context.Context, repoID string) (*tenant.Repository, error)
+	GetSnapshotByID(ctx context.Context, snapshotID string) (*tenant.SnapshotRow, error)
+	GetScoreByID(ctx context.Context, scoreID string) (*tenant.ScoreRow, error)
+	GetTenantByName(ctx context.Context, name string) (*tenant.Tenant, error)
+}
+
+// authorizeRepo reports whether the caller may access repoID, writing a 404
+// if not so that other tenants' repositories are indistinguishable from
+// missing ones.
+func (h *Handler) authorizeRepo(w http.ResponseWriter, r *http.Request, repoID string) bool {
+	caller := CallerFrom(r.Context())
+	if !caller.Scoped() {
+		return true
+	}
+	repo, err := h.owners.GetRepositoryByID(r.Context(), repoID)
+	if err != nil || !caller.Owns(repo.TenantID) {
+		writeError(w, http.StatusNotFound, "repository not found")
+		return false
+	}
+	return true
+}
+
+// authorizeSnapshot is authorizeRepo for snapshots. It checks the database
+// row, since loadSnapshot may serve the snapshot from cache.
+func (h *Handler) authorizeSnapshot(w http.ResponseWriter, r *http.Request, snapshotID string) bool {
+	caller := CallerFrom(r.Context())
+	if !caller.Scoped() {
+		return true
+	}
+	sn, err := h.owners.GetSnapshotByID(r.Context(), snapshotID)
+	if err != nil || !caller.Owns(sn.TenantID) {
+		writeError(w, http.StatusNotFound, "snapshot not found")
+		return false
+	}
+	return true
+}
+
+// authorizeScore is authorizeRepo for scores.
+func (h *Handler) authorizeScore(w http.ResponseWriter, r *http.Request, scoreID string) bool {
+	caller := CallerFrom(r.Context())
+	if !caller.Scoped() {
+		return true
+	}
+	sc, err := h.owners.GetScoreByID(r.Context(), scoreID)
+	if err != nil || !caller.Owns(sc.TenantID) {
+		writeError(w, http.StatusNotFound, "score not found")
+		return false
+	}
+	return true
+}
+
+// authorizeOrg reports whether a scoped caller may create or write to
+// repositories under orgName, which must name the caller's own tenant.
+func (h *Handler) authorizeOrg(w http.ResponseWriter, r *http.Request, orgName string) bool {
+	caller := CallerFrom(r.Context())
+	if !caller.Scoped() {
+		return true
+	}
+	t, err := h.owners.GetTenantByName(r.Context(), orgName)
+	if err != nil || !caller.Owns(t.ID) {
+		writeError(w, http.StatusForbidden, "not authorized for "+orgName)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,204 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/toposcope/toposcope/internal/tenant"
+)
+
+var errNotFound = errors.New("not found")
+
+// fakeOwners serves ownerLookup from maps of resource ID to tenant ID.
+type fakeOwners struct {
+	repos, snapshots, scores, tenants map[string]string
+}
+
+func (f fakeOwners) GetRepositoryByID(_ context.Context, id string) (*tenant.Repository, error) {
+	if t, ok := f.repos[id]; ok {
+		return &tenant.Repository{ID: id, TenantID: t}, nil
+	}
+	return nil, errNotFound
+}
+
+func (f fakeOwners) GetSnapshotByID(_ context.Context, id string) (*tenant.SnapshotRow, error) {
+	if t, ok := f.snapshots[id]; ok {
+		return &tenant.SnapshotRow{ID: id, TenantID: t}, nil
+	}
+	return nil, errNotFound
+}
+
+func (f fakeOwners) GetScoreByID(_ context.Context, id string) (*tenant.ScoreRow, error) {
+	if t, ok := f.scores[id]; ok {
+		return &tenant.ScoreRow{ID: id, TenantID: t}, nil
+	}
+	return nil, errNotFound
+}
+
+func (f fakeOwners) GetTenantByName(_ context.Context, name string) (*tenant.Tenant, error) {
+	if id, ok := f.tenants[name]; ok {
+		return &tenant.Tenant{ID: id}, nil
+	}
+	return nil, errNotFound
+}
+
+func fakeRepoKeys(_ context.Context, key string) (*tenant.Repository, error) {
+	switch key {
+	case "tsk_acme":
+		return &tenant.Repository{ID: "repo-acme", TenantID: "acme"}, nil
+	case "tsk_globex":
+		return &tenant.Repository{ID: "repo-globex", TenantID: "globex"}, nil
+	}
+	return nil, errNotFound
+}
+
+func TestResolveTenant(t *testing.T) {
+	for _, tc := range []struct {
+		name       string
+		mode       AuthMode
+		isolate    bool
+		key        string
+		tenantHdr  string
+		wantStatus int
+		wantTenant string
+	}{
+		{name: "service key", mode: AuthModeAPIKey, isolate: true, key: "secret", wantStatus: 200},
+		{name: "repo key", mode: AuthModeAPIKey, isolate: true, key: "tsk_acme", wantStatus: 200, wantTenant: "acme"},
+		{name: "unknown repo key", mode: AuthModeAPIKey, key: "tsk_nope", wantStatus: 401},
+		{name: "wrong key, isolated", mode: AuthModeAPIKey, isolate: true, key: "guess", wantStatus: 401},
+		{name: "no credentials, isolated", mode: AuthModeAPIKey, isolate: true, wantStatus: 401},
+		{name: "no credentials, single tenant", mode: AuthModeAPIKey, wantStatus: 200},
+		{name: "proxy tenant header", mode: AuthModeOIDC, isolate: true, tenantHdr: "acme", wantStatus: 200, wantTenant: "acme"},
+		{name: "tenant header outside oidc mode", mode: AuthModeAPIKey, isolate: true, tenantHdr: "acme", wantStatus: 401},
+		{name: "repo key beats tenant header", mode: AuthModeOIDC, isolate: true, key: "tsk_acme", tenantHdr: "globex", wantStatus: 200, wantTenant: "acme"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var got *Caller
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				c := CallerFrom(r.Context())
+				got = &c
+			})
+			req := httptest.NewRequest("GET", "/api/v2/repos", nil)
+			if tc.key != "" {
+				req.Header.Set("X-API-Key", tc.key)
+			}
+			if tc.tenantHdr != "" {
+				req.Header.Set(TenantHeader, tc.tenantHdr)
+			}
+			rec := httptest.NewRecorder()
+			ResolveTenant(tc.mode, "secret", fakeRepoKeys, tc.isolate)(next).ServeHTTP(rec, req)
+
+			if rec.Code != tc.wantStatus {
+				t.Fatalf("status = %d, want %d", rec.Code, tc.wantStatus)
+			}
+			if tc.wantStatus != 200 {
+				if got != nil {
+					t.Error("rejected request reached the handler")
+				}
+				return
+			}
+			if got == nil {
+				t.Fatal("handler not called")
+			}
+			if got.TenantID != tc.wantTenant {
+				t.Errorf("tenant = %q, want %q", got.TenantID, tc.wantTenant)
+			}
+		})
+	}
+}
+
+func TestAPIKeyAuthRepoKeyPaths(t *testing.T) {
+	for _, tc := range []struct {
+		name, path, key string
+		wantStatus      int
+	}{
+		{"repo key ingest", "/api/v2/ingest", "tsk_acme", 200},
+		{"repo key snapshots", "/api/v2/snapshots", "tsk_acme", 200},
+		{"repo key bundles", "/api/v2/bundles", "tsk_acme", 200},
+		{"repo key legacy ingest", "/api/v1/ingest", "tsk_acme", 200},
+		{"repo key rescore", "/api/v2/rescore", "tsk_acme", 401},
+		{"repo key register repo", "/api/v2/repos", "tsk_acme", 401},
+		{"repo key repo settings", "/api/v2/repos/repo-acme/settings", "tsk_acme", 401},
+		{"repo key admin", "/api/v2/admin/gc", "tsk_acme", 401},
+		{"repo key path prefix", "/api/v2/ingest/extra", "tsk_acme", 401},
+		{"unknown repo key", "/api/v2/ingest", "tsk_nope", 401},
+		{"service key admin", "/api/v2/admin/gc", "secret", 200},
+		{"no key", "/api/v2/ingest", "", 401},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var repo *tenant.Repository
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				repo, _ = KeyRepository(r.Context())
+			})
+			req := httptest.NewRequest("POST", tc.path, nil)
+			if tc.key != "" {
+				req.Header.Set("X-API-Key", tc.key)
+			}
+			rec := httptest.NewRecorder()
+			APIKeyAuth("secret", fakeRepoKeys)(next).ServeHTTP(rec, req)
+
+			if rec.Code != tc.wantStatus {
+				t.Fatalf("status = %d, want %d", rec.Code, tc.wantStatus)
+			}
+			if tc.wantStatus == 200 && tc.key != "secret" && (repo == nil || repo.ID != "repo-acme") {
+				t.Errorf("key repository = %+v, want repo-acme", repo)
+			}
+		})
+	}
+}
+
+func TestAuthorize(t *testing.T) {
+	h := &Handler{owners: fakeOwners{
+		repos:     map[string]string{"repo-acme": "acme", "repo-globex": "globex"},
+		snapshots: map[string]string{"snap-acme": "acme", "snap-globex": "globex"},
+		scores:    map[string]string{"score-acme": "acme", "score-globex": "globex"},
+		tenants:   map[string]string{"acme": "acme", "globex": "globex"},
+	}}
+	checks := map[string]func(http.ResponseWriter, *http.Request, string) bool{
+		"repo":     h.authorizeRepo,
+		"snapshot": h.authorizeSnapshot,
+		"score":    h.authorizeScore,
+		"org":      h.authorizeOrg,
+	}
+
+	for _, tc := range []struct {
+		name       string
+		check      string
+		caller     Caller
+		id         string
+		wantStatus int // 0 means allowed
+	}{
+		{name: "own repo", check: "repo", caller: Caller{TenantID: "acme"}, id: "repo-acme"},
+		{name: "other tenant's repo", check: "repo", caller: Caller{TenantID: "acme"}, id: "repo-globex", wantStatus: 404},
+		{name: "missing repo", check: "repo", caller: Caller{TenantID: "acme"}, id: "repo-nope", wantStatus: 404},
+		{name: "unscoped repo", check: "repo", id: "repo-globex"},
+		{name: "own snapshot", check: "snapshot", caller: Caller{TenantID: "acme"}, id: "snap-acme"},
+		{name: "other tenant's snapshot", check: "snapshot", caller: Caller{TenantID: "acme"}, id: "snap-globex", wantStatus: 404},
+		{name: "missing snapshot", check: "snapshot", caller: Caller{TenantID: "acme"}, id: "snap-nope", wantStatus: 404},
+		{name: "unscoped snapshot", check: "snapshot", id: "snap-nope"},
+		{name: "own score", check: "score", caller: Caller{TenantID: "acme"}, id: "score-acme"},
+		{name: "other tenant's score", check: "score", caller: Caller{TenantID: "acme"}, id: "score-globex", wantStatus: 404},
+		{name: "missing score", check: "score", caller: Caller{TenantID: "acme"}, id: "score-nope", wantStatus: 404},
+		{name: "own org", check: "org", caller: Caller{TenantID: "acme"}, id: "acme"},
+		{name: "other org", check: "org", caller: Caller{TenantID: "acme"}, id: "globex", wantStatus: 403},
+		{name: "unknown org", check: "org", caller: Caller{TenantID: "acme"}, id: "initech", wantStatus: 403},
+		{name: "unscoped org", check: "org", id: "initech"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/", nil)
+			req = req.WithContext(context.WithValue(req.Context(), callerContextKey{}, tc.caller))
+			rec := httptest.NewRecorder()
+
+			ok := checks[tc.check](rec, req, tc.id)
+			if ok != (tc.wantStatus == 0) {
+				t.Fatalf("allowed = %v, want %v", ok, tc.wantStatus == 0)
+			}
+			if tc.wantStatus != 0 && rec.Code != tc.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tc.wantStatus)
+			}
+		})
+	}
+}
@@ -0,0 +1,88 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/toposcope/toposcope/internal/tenant"
+)
+
+// maxUsageMonths bounds the history returned by the usage endpoint.
+const maxUsageMonths = 36
+
+type usageResponse struct {
+	TenantID     string         `json:"tenant_id"`
+	StorageBytes int64          `json:"storage_bytes"`
+	Quota        tenant.Quota   `json:"quota"` // zero fields are unlimited
+	Months       []tenant.Usage `json:"months"`
+}
+
+// handleTenantUsage handles GET /api/v1/tenants/{tenantID}/usage: the
+// tenant's stored bytes, quota, and monthly ingestion counts, newest month
+// first. ?months= limits the history (default 12).
+func (h *Handler) handleTenantUsage(w http.ResponseWriter, r *http.Request) {
+	tenantID := r.PathValue("tenantID")
+	if !CallerFrom(r.Context()).Owns(tenantID) {
+		writeError(w, http.StatusNotFound, "tenant not found")
+		return
+	}
+
+	months := 12
+	if v := r.URL.Query().Get("months"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			writeError(w, http.StatusBadRequest, "months must be a positive integer")
+			return
+		}
+		months = min(n, maxUsageMonths)
+	}
+
+	ctx := r.Context()
+	quota, err := h.tenantSvc.GetQuota(ctx, tenantID)
+	if errors.Is(err, sql.ErrNoRows) {
+		writeError(w, http.StatusNotFound, "tenant not found")
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to load quota: "+err.Error())
+		return
+	}
+	storage, err := h.tenantSvc.StorageBytes(ctx, tenantID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to load storage usage: "+err.Error())
+		return
+	}
+	usage, err := h.tenantSvc.ListUsage(ctx, tenantID, months)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to load usage: "+err.Error())
+		return
+	}
+	if usage == nil {
+		usage = []tenant.Usage{}
+	}
+
+	writeJSON(w, http.StatusOK, usageResponse{
+		TenantID:     tenantID,
+		StorageBytes: storage,
+		Quota:        quota,
+		Months:       usage,
+	})
+}
+
+// admitIngestion counts n ingestions against the tenant's quota, writing a
+// 429 if the tenant is over quota. On failure the error has been written and
+// ok is false.
+func (h *Handler) admitIngestion(w http.ResponseWriter, r *http.Request, tenantID string, n int) bool {
+	err := h.ingestionSvc.BeginIngestion(r.Context(), tenantID, n)
+	switch {
+	case errors.Is(err, tenant.ErrQuotaExceeded):
+		writeError(w, http.StatusTooManyRequests, err.Error())
+		return false
+	case err != nil:
+		writeError(w, http.StatusInternalServerError, "failed to check quota: "+err.Error())
+		return false
+	}
+	return true
+}
@@ -0,0 +1,513 @@
+package graphql
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Schema is the root of a query schema.
+type Schema struct {
+	Query *Object
+	// MaxCost is the budget of each query: the sum of the Cost of every
+	// resolver call it makes. A query that goes over it fails as a whole.
+	// 0 uses DefaultMaxCost.
+	MaxCost int
+}
+
+// Object is an object type. Fields holds the fields that need a resolver,
+// typically because they take arguments or load related data. Any other
+// field is read from the JSON encoding of the source value, so an object
+// exposes every property of its JSON form.
+type Object struct {
+	Name   string
+	Fields map[string]*Field
+}
+
+// Field is a resolved field of an object type.
+type Field struct {
+	// Type is the object type of the resolved value, or of its elements if
+	// it is a slice. Nil means the value is returned as JSON, with any
+	// sub-selection applied to its JSON properties.
+	Type    *Object
+	Resolve func(ctx context.Context, p Params) (any, error)
+	// Cost is what each call to Resolve charges to the query's budget. 0
+	// costs 1.
+	Cost int
+}
+
+// Params are the inputs of a field resolver.
+type Params struct {
+	Source any            // the value of the enclosing object
+	Args   map[string]any // arguments with variables substituted
+}
+
+// Request is a GraphQL request.
+type Request struct {
+	Query         string         `json:"query"`
+	OperationName string         `json:"operationName,omitempty"`
+	Variables     map[string]any `json:"variables,omitempty"`
+}
+
+// Response is a GraphQL response.
+type Response struct {
+	Data   any     `json:"data"`
+	Errors []Error `json:"errors,omitempty"`
+}
+
+// Error is a GraphQL error. Path locates the field that failed.
+type Error struct {
+	Message string `json:"message"`
+	Path    []any  `json:"path,omitempty"`
+}
+
+// MaxDepth bounds how deeply selections may nest.
+const MaxDepth = 15
+
+// DefaultMaxCost is the query budget of a Schema that doesn't set MaxCost.
+const DefaultMaxCost = 1000
+
+// Execute parses and runs req against s. Errors in individual fields are
+// reported in the response and set the field to null; a request that can't
+// be run at all has no data.
+func (s *Schema) Execute(ctx context.Context, req Request) *Response {
+	doc, err := Parse(req.Query)
+	if err != nil {
+		return &Response{Errors: []Error{{Message: err.Error()}}}
+	}
+	op, err := doc.operation(req.OperationName)
+	if err != nil {
+		return &Response{Errors: []Error{{Message: err.Error()}}}
+	}
+	if op.Kind != "query" {
+		return &Response{Errors: []Error{{Message: op.Kind + " operations are not supported"}}}
+	}
+
+	vars := map[string]any{}
+	for _, def := range op.Variables {
+		if v, ok := req.Variables[def.Name]; ok {
+			vars[def.Name] = v
+		} else if def.Default != nil {
+			vars[def.Name] = constValue(def.Default)
+		}
+	}
+	ex := &executor{doc: doc, vars: vars, budget: s.MaxCost}
+	if ex.budget <= 0 {
+		ex.budget = DefaultMaxCost
+	}
+	data := ex.object(ctx, s.Query, nil, op.Selection, nil)
+	if ex.spent > ex.budget {
+		return &Response{Errors: []Error{{Message: fmt.Sprintf("query exceeds the cost budget of %d", ex.budget)}}}
+	}
+	return &Response{Data: data, Errors: ex.errors}
+}
+
+func (d *Document) operation(name string) (*Operation, error) {
+	if name == "" {
+		if len(d.Operations) > 1 {
+			return nil, fmt.Errorf("operationName is required when the document has several operations")
+		}
+		return d.Operations[0], nil
+	}
+	for _, op := range d.Operations {
+		if op.Name == name {
+			return op, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown operation %q", name)
+}
+
+type executor struct {
+	doc    *Document
+	vars   map[string]any
+	errors []Error
+	budget int // cost allowed for the query
+	spent  int // cost of the resolver calls so far
+}
+
+// charge adds the cost of a call to f's resolver to the query, reporting
+// whether it is within budget. Once over, nothing more is resolved.
+func (ex *executor) charge(f *Field) bool {
+	if ex.spent > ex.budget {
+		return false
+	}
+	ex.spent += max(f.Cost, 1)
+	return ex.spent <= ex.budget
+}
+
+func (ex *executor) fail(path []any, format string, args ...any) {
+	ex.errors = append(ex.errors, Error{Message: fmt.Sprintf(format, args...), Path: path})
+}
+
+// collected is the selections of one response key, merged across fragments.
+type collected struct {
+	key    string
+	fields []*FieldNode
+}
+
+// collect flattens sel into the fields to report for an object of type
+// typeName (empty for untyped JSON), in selection order.
+func (ex *executor) collect(sel []Selection, typeName string, out []collected, visited map[string]bool) ([]collected, error) {
+	for _, s := range sel {
+		include, err := ex.included(s.Directives)
+		if err != nil {
+			return nil, err
+		}
+		if !include {
+			continue
+		}
+		switch {
+		case s.Field != nil:
+			key := s.Field.ResponseKey()
+			found := false
+			for i := range out {
+				if out[i].key == key {
+					out[i].fields = append(out[i].fields, s.Field)
+					found = true
+					break
+				}
+			}
+			if !found {
+				out = append(out, collected{key: key, fields: []*FieldNode{s.Field}})
+			}
+		case s.Spread != "":
+			if visited[s.Spread] {
+				continue
+			}
+			frag, ok := ex.doc.Fragments[s.Spread]
+			if !ok {
+				return nil, fmt.Errorf("unknown fragment %q", s.Spread)
+			}
+			visited[s.Spread] = true
+			if !typeMatches(frag.TypeCondition, typeName) {
+				continue
+			}
+			if out, err = ex.collect(frag.Selection, typeName, out, visited); err != nil {
+				return nil, err
+			}
+		case s.Inline != nil:
+			if !typeMatches(s.Inline.TypeCondition, typeName) {
+				continue
+			}
+			if out, err = ex.collect(s.Inline.Selection, typeName, out, visited); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return out, nil
+}
+
+// typeMatches reports whether a fragment with condition applies. Untyped
+// JSON values match every condition.
+func typeMatches(condition, typeName string) bool {
+	return condition == "" || typeName == "" || condition == typeName
+}
+
+func (ex *executor) included(dirs []Directive) (bool, error) {
+	for _, d := range dirs {
+		if d.Name != "skip" && d.Name != "include" {
+			continue
+		}
+		v, ok := ex.value(d.Arguments["if"]).(bool)
+		if !ok {
+			return false, fmt.Errorf("@%s requires a Boolean if argument", d.Name)
+		}
+		if (d.Name == "skip") == v {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// object resolves sel on source, a value of type typ.
+func (ex *executor) object(ctx context.Context, typ *Object, source any, sel []Selection, path []any) any {
+	if len(path) > MaxDepth {
+		ex.fail(path, "query is nested more than %d levels deep", MaxDepth)
+		return nil
+	}
+	fields, err := ex.collect(sel, typ.Name, nil, map[string]bool{})
+	if err != nil {
+		ex.fail(path, "%v", err)
+		return nil
+	}
+
+	var generic any
+	var genericErr error
+	genericDone := false
+	out := &orderedMap{}
+	for _, c := range fields {
+		f := c.fields[0]
+		fieldPath := extend(path, c.key)
+		subSel := mergedSelection(c.fields)
+
+		if f.Name == "__typename" {
+			out.set(c.key, typ.Name)
+			continue
+		}
+		if field, ok := typ.Fields[f.Name]; ok {
+			if !ex.charge(field) {
+				return nil
+			}
+			args := make(map[string]any, len(f.Arguments))
+			for name, v := range f.Arguments {
+				args[name] = ex.value(v)
+			}
+			v, err := field.Resolve(ctx, Params{Source: source, Args: args})
+			if err != nil {
+				ex.fail(fieldPath, "%v", err)
+				out.set(c.key, nil)
+				continue
+			}
+			out.set(c.key, ex.complete(ctx, field.Type, v, subSel, fieldPath))
+			continue
+		}
+
+		if !genericDone {
+			generic, genericErr = toJSONValue(source)
+			genericDone = true
+		}
+		if genericErr != nil {
+			ex.fail(fieldPath, "%v", genericErr)
+			out.set(c.key, nil)
+			continue
+		}
+		m, _ := generic.(map[string]any)
+		v, ok := m[f.Name]
+		if !ok && !knownField(source, f.Name) {
+			ex.fail(fieldPath, "cannot query field %q on type %s", f.Name, typ.Name)
+			out.set(c.key, nil)
+			continue
+		}
+		out.set(c.key, ex.project(v, subSel, fieldPath))
+	}
+	return out
+}
+
+// extend returns a copy of path with elem appended, so sibling fields
+// never share a backing array.
+func extend(path []any, elem any) []any {
+	out := make([]any, len(path)+1)
+	copy(out, path)
+	out[len(path)] = elem
+	return out
+}
+
+func mergedSelection(fields []*FieldNode) []Selection {
+	if len(fields) == 1 {
+		return fields[0].Selection
+	}
+	var sel []Selection
+	for _, f := range fields {
+		sel = append(sel, f.Selection...)
+	}
+	return sel
+}
+
+// complete shapes a resolved value: lists element by element, values of an
+// object type through their fields, and anything else as JSON.
+func (ex *executor) complete(ctx context.Context, typ *Object, v any, sel []Selection, path []any) any {
+	if isNil(v) {
+		return nil
+	}
+	if typ == nil {
+		generic, err := toJSONValue(v)
+		if err != nil {
+			ex.fail(path, "%v", err)
+			return nil
+		}
+		return ex.project(generic, sel, path)
+	}
+	if len(sel) == 0 {
+		ex.fail(path, "field of type %s must have a selection of subfields", typ.Name)
+		return nil
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Slice || rv.Kind() == reflect.Array {
+		list := make([]any, rv.Len())
+		for i := range list {
+			list[i] = ex.complete(ctx, typ, rv.Index(i).Interface(), sel, extend(path, i))
+		}
+		return list
+	}
+	return ex.object(ctx, typ, v, sel, path)
+}
+
+// project applies sel to a JSON value. Without a selection the whole value
+// is returned.
+func (ex *executor) project(v any, sel []Selection, path []any) any {
+	if len(sel) == 0 || v == nil {
+		return v
+	}
+	if len(path) > MaxDepth {
+		ex.fail(path, "query is nested more than %d levels deep", MaxDepth)
+		return nil
+	}
+	switch v := v.(type) {
+	case []any:
+		list := make([]any, len(v))
+		for i, elem := range v {
+			list[i] = ex.project(elem, sel, extend(path, i))
+		}
+		return list
+	case map[string]any:
+		fields, err := ex.collect(sel, "", nil, map[string]bool{})
+		if err != nil {
+			ex.fail(path, "%v", err)
+			return nil
+		}
+		out := &orderedMap{}
+		for _, c := range fields {
+			out.set(c.key, ex.project(v[c.fields[0].Name], mergedSelection(c.fields), extend(path, c.key)))
+		}
+		return out
+	default:
+		ex.fail(path, "cannot select subfields of a scalar value")
+		return nil
+	}
+}
+
+// value substitutes variables into an argument value.
+func (ex *executor) value(v Value) any {
+	switch v := v.(type) {
+	case Variable:
+		return ex.vars[string(v)]
+	case EnumValue:
+		return string(v)
+	case []Value:
+		list := make([]any, len(v))
+		for i, elem := range v {
+			list[i] = ex.value(elem)
+		}
+		return list
+	case map[string]Value:
+		obj := make(map[string]any, len(v))
+		for k, elem := range v {
+			obj[k] = ex.value(elem)
+		}
+		return obj
+	default:
+		return v
+	}
+}
+
+func constValue(v Value) any {
+	return (&executor{}).value(v)
+}
+
+func isNil(v any) bool {
+	if v == nil {
+		return true
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Pointer, reflect.Map, reflect.Slice, reflect.Interface:
+		return rv.IsNil()
+	}
+	return false
+}
+
+// toJSONValue converts v to its JSON form: maps, slices, strings, float64s,
+// bools, and nil.
+func toJSONValue(v any) (any, error) {
+	switch v.(type) {
+	case nil, map[string]any, []any, string, float64, bool:
+		return v, nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("encode value: %w", err)
+	}
+	var out any
+	if err := json.Unmarshal(data, &out); err != nil {
+		return nil, fmt.Errorf("decode value: %w", err)
+	}
+	return out, nil
+}
+
+// knownField reports whether name may be read from source's JSON form even
+// though the encoding lacks it: source is a JSON object, or a struct with a
+// property of that name that was omitted because it was empty.
+func knownField(source any, name string) bool {
+	if source == nil {
+		return false
+	}
+	t := reflect.TypeOf(source)
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return t.Kind() == reflect.Map
+	}
+	return structHasJSONField(t, name)
+}
+
+func structHasJSONField(t reflect.Type, name string) bool {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		jsonName, _, _ := strings.Cut(tag, ",")
+		if jsonName == "" && f.Anonymous {
+			ft := f.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct && structHasJSONField(ft, name) {
+				return true
+			}
+			continue
+		}
+		if jsonName == "" {
+			jsonName = f.Name
+		}
+		if jsonName == name {
+			return true
+		}
+	}
+	return false
+}
+
+// orderedMap is a JSON object that keeps its keys in insertion order, as
+// GraphQL requires responses to follow the order of the selection.
+type orderedMap struct {
+	keys []string
+	vals map[string]any
+}
+
+func (m *orderedMap) set(key string, v any) {
+	if m.vals == nil {
+		m.vals = map[string]any{}
+	}
+	if _, ok := m.vals[key]; !ok {
+		m.keys = append(m.keys, key)
+	}
+	m.vals[key] = v
+}
+
+func (m *orderedMap) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, k := range m.keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		kb, err := json.Marshal(k)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(kb)
+		buf.WriteByte(':')
+		vb, err := json.Marshal(m.vals[k])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(vb)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,161 @@
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+type testRepo struct {
+	ID       string `json:"id"`
+	FullName string `json:"full_name"`
+	Branch   string `json:"branch,omitempty"`
+}
+
+type testScore struct {
+	Grade     string           `json:"grade"`
+	Breakdown []map[string]any `json:"breakdown"`
+}
+
+func testSchema() *Schema {
+	score := &Object{Name: "Score"}
+	repo := &Object{Name: "Repo", Fields: map[string]*Field{
+		"scores": {Type: score, Resolve: func(_ context.Context, p Params) (any, error) {
+			limit, _ := p.Args["limit"].(int)
+			all := []testScore{
+				{Grade: "A", Breakdown: []map[string]any{{"key": "fanout", "contribution": 1.5}}},
+				{Grade: "C"},
+			}
+			if limit > 0 && limit < len(all) {
+				all = all[:limit]
+			}
+			return all, nil
+		}},
+		"broken": {Resolve: func(context.Context, Params) (any, error) {
+			return nil, fmt.Errorf("storage unavailable")
+		}},
+	}}
+	return &Schema{Query: &Object{Name: "Query", Fields: map[string]*Field{
+		"repo": {Type: repo, Resolve: func(_ context.Context, p Params) (any, error) {
+			if p.Args["id"] != "r1" {
+				return (*testRepo)(nil), nil
+			}
+			return &testRepo{ID: "r1", FullName: "org/repo"}, nil
+		}},
+	}}}
+}
+
+func run(t *testing.T, query string, vars map[string]any) (string, []Error) {
+	t.Helper()
+	resp := testSchema().Execute(context.Background(), Request{Query: query, Variables: vars})
+	data, err := json.Marshal(resp.Data)
+	if err != nil {
+		t.Fatalf("marshal data: %v", err)
+	}
+	return string(data), resp.Errors
+}
+
+func TestExecute(t *testing.T) {
+	got, errs := run(t, `
+		query Repo($id: ID!, $limit: Int = 1) {
+			repo(id: $id) {
+				full_name
+				__typename
+				...Fields
+				latest: scores(limit: $limit) { grade breakdown { contribution key } }
+				skipped: id @skip(if: true)
+			}
+		}
+		fragment Fields on Repo { id branch }`,
+		map[string]any{"id": "r1"})
+	if len(errs) != 0 {
+		t.Fatalf("errors: %+v", errs)
+	}
+	want := `{"repo":{"full_name":"org/repo","__typename":"Repo","id":"r1","branch":null,` +
+		`"latest":[{"grade":"A","breakdown":[{"contribution":1.5,"key":"fanout"}]}]}}`
+	if got != want {
+		t.Errorf("data =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestExecuteNullAndWholeValues(t *testing.T) {
+	got, errs := run(t, `{ missing: repo(id: "nope") { id } repo(id: "r1") { scores { breakdown } } }`, nil)
+	if len(errs) != 0 {
+		t.Fatalf("errors: %+v", errs)
+	}
+	want := `{"missing":null,"repo":{"scores":[{"breakdown":[{"contribution":1.5,"key":"fanout"}]},{"breakdown":null}]}}`
+	if got != want {
+		t.Errorf("data = %s, want %s", got, want)
+	}
+}
+
+func TestExecuteFieldErrors(t *testing.T) {
+	got, errs := run(t, `{ repo(id: "r1") { id broken nope scores } }`, nil)
+	if got != `{"repo":{"id":"r1","broken":null,"nope":null,"scores":null}}` {
+		t.Errorf("data = %s", got)
+	}
+	var msgs []string
+	for _, e := range errs {
+		msgs = append(msgs, fmt.Sprintf("%v: %s", e.Path, e.Message))
+	}
+	want := []string{
+		"[repo broken]: storage unavailable",
+		`[repo nope]: cannot query field "nope" on type Repo`,
+		"[repo scores]: field of type Score must have a selection of subfields",
+	}
+	if strings.Join(msgs, "\n") != strings.Join(want, "\n") {
+		t.Errorf("errors =\n%s\nwant\n%s", strings.Join(msgs, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestExecuteRejects(t *testing.T) {
+	for _, tc := range []struct{ query, want string }{
+		{`{ repo(id: "r1") { id `, "unexpected end of document"},
+		{`mutation { repo(id: "r1") { id } }`, "mutation operations are not supported"},
+		{`query A { repo { id } } query B { repo { id } }`, "operationName is required"},
+		{`{ repo(id: "r1") { ...Nope } }`, `unknown fragment "Nope"`},
+		{`{ nope }`, `cannot query field "nope" on type Query`},
+	} {
+		resp := testSchema().Execute(context.Background(), Request{Query: tc.query})
+		if len(resp.Errors) == 0 || !strings.Contains(resp.Errors[0].Message, tc.want) {
+			t.Errorf("%s: errors = %+v, want %q", tc.query, resp.Errors, tc.want)
+		}
+	}
+}
+
+func TestExecuteCostBudget(t *testing.T) {
+	schema := testSchema()
+	schema.MaxCost = 5
+	schema.Query.Fields["repo"].Type.Fields["scores"].Cost = 2
+
+	// repo 1 + scores 2 + repo 1 = 4
+	resp := schema.Execute(context.Background(), Request{Query: `{ a: repo(id: "r1") { scores { grade } } b: repo(id: "r1") { id } }`})
+	if len(resp.Errors) != 0 || resp.Data == nil {
+		t.Fatalf("within budget: errors = %+v", resp.Errors)
+	}
+
+	// repo 1 + scores 2 + repo 1 + scores 2 = 6
+	resp = schema.Execute(context.Background(), Request{Query: `{ a: repo(id: "r1") { scores { grade } } b: repo(id: "r1") { scores { grade } } }`})
+	if resp.Data != nil || len(resp.Errors) != 1 || !strings.Contains(resp.Errors[0].Message, "cost budget of 5") {
+		t.Errorf("over budget: data = %v, errors = %+v", resp.Data, resp.Errors)
+	}
+}
+
+func TestParseValues(t *testing.T) {
+	doc, err := Parse(`{ f(a: -1, b: 2.5e1, c: "x\né", d: [true, null, ENUM], e: {k: """block "quoted" """}) }`)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	args := doc.Operations[0].Selection[0].Field.Arguments
+	if args["a"] != -1 || args["b"] != 25.0 || args["c"] != "x\né" {
+		t.Errorf("scalars = %#v %#v %#v", args["a"], args["b"], args["c"])
+	}
+	if list := args["d"].([]Value); len(list) != 3 || list[0] != true || list[1] != nil || list[2] != EnumValue("ENUM") {
+		t.Errorf("list = %#v", args["d"])
+	}
+	if obj := args["e"].(map[string]Value); obj["k"] != `block "quoted" ` {
+		t.Errorf("object = %#v", obj)
+	}
+}
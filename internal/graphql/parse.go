@@ -0,0 +1,687 @@
+// Package graphql executes read-only GraphQL queries against a schema of
+// resolver functions. It implements the query language (operations,
+// variables, aliases, fragments, and @skip/@include) but not mutations,
+// subscriptions, or introspection.
+package graphql
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Document is a parsed GraphQL document.
+type Document struct {
+	Operations []*Operation
+	Fragments  map[string]*Fragment
+}
+
+// Operation is a query, mutation, or subscription definition.
+type Operation struct {
+	Kind      string // "query", "mutation", or "subscription"
+	Name      string
+	Variables []VariableDef
+	Selection []Selection
+}
+
+// VariableDef declares an operation variable.
+type VariableDef struct {
+	Name    string
+	Type    string
+	Default Value // nil if none
+}
+
+// Fragment is a named fragment definition.
+type Fragment struct {
+	Name          string
+	TypeCondition string
+	Selection     []Selection
+}
+
+// Selection is a field, a fragment spread, or an inline fragment.
+type Selection struct {
+	Field *FieldNode
+	// Spread names a fragment; set for fragment spreads.
+	Spread string
+	// Inline is set for inline fragments.
+	Inline *Fragment
+
+	Directives []Directive
+}
+
+// FieldNode is a field selection.
+type FieldNode struct {
+	Alias     string
+	Name      string
+	Arguments map[string]Value
+	Selection []Selection
+}
+
+// ResponseKey returns the key the field's value is reported under.
+func (f *FieldNode) ResponseKey() string {
+	if f.Alias != "" {
+		return f.Alias
+	}
+	return f.Name
+}
+
+// Directive is a directive applied to a selection.
+type Directive struct {
+	Name      string
+	Arguments map[string]Value
+}
+
+// Value is an argument value: nil (null), bool, int, float64, string,
+// EnumValue, Variable, []Value, or map[string]Value.
+type Value any
+
+// EnumValue is an unquoted enum value.
+type EnumValue string
+
+// Variable refers to an operation variable.
+type Variable string
+
+// Parse parses a GraphQL document.
+func Parse(src string) (*Document, error) {
+	p := &parser{lex: lexer{src: src}}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	doc := &Document{Fragments: map[string]*Fragment{}}
+	for p.tok.kind != tokEOF {
+		switch {
+		case p.tok.is(tokPunct, "{"):
+			sel, err := p.selectionSet()
+			if err != nil {
+				return nil, err
+			}
+			doc.Operations = append(doc.Operations, &Operation{Kind: "query", Selection: sel})
+		case p.tok.is(tokName, "query"), p.tok.is(tokName, "mutation"), p.tok.is(tokName, "subscription"):
+			op, err := p.operation()
+			if err != nil {
+				return nil, err
+			}
+			doc.Operations = append(doc.Operations, op)
+		case p.tok.is(tokName, "fragment"):
+			frag, err := p.fragment()
+			if err != nil {
+				return nil, err
+			}
+			if _, dup := doc.Fragments[frag.Name]; dup {
+				return nil, fmt.Errorf("fragment %q is defined more than once", frag.Name)
+			}
+			doc.Fragments[frag.Name] = frag
+		default:
+			return nil, p.unexpected()
+		}
+	}
+	if len(doc.Operations) == 0 {
+		return nil, fmt.Errorf("document has no operations")
+	}
+	return doc, nil
+}
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokPunct
+	tokName
+	tokInt
+	tokFloat
+	tokString
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+func (t token) is(kind tokenKind, text string) bool {
+	return t.kind == kind && t.text == text
+}
+
+type lexer struct {
+	src string
+	pos int
+}
+
+// skipIgnored skips whitespace, commas, comments, and byte order marks.
+func (l *lexer) skipIgnored() {
+	for l.pos < len(l.src) {
+		switch c := l.src[l.pos]; {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == ',':
+			l.pos++
+		case c == '#':
+			for l.pos < len(l.src) && l.src[l.pos] != '\n' {
+				l.pos++
+			}
+		case strings.HasPrefix(l.src[l.pos:], "\uFEFF"):
+			l.pos += len("\uFEFF")
+		default:
+			return
+		}
+	}
+}
+
+func (l *lexer) next() (token, error) {
+	l.skipIgnored()
+	if l.pos >= len(l.src) {
+		return token{kind: tokEOF, pos: l.pos}, nil
+	}
+	start := l.pos
+	c := l.src[l.pos]
+	switch {
+	case strings.ContainsRune("!$()[]{}:=@|&", rune(c)):
+		l.pos++
+		return token{kind: tokPunct, text: string(c), pos: start}, nil
+	case c == '.':
+		if strings.HasPrefix(l.src[l.pos:], "...") {
+			l.pos += 3
+			return token{kind: tokPunct, text: "...", pos: start}, nil
+		}
+	case c == '_' || isLetter(c):
+		for l.pos < len(l.src) && (l.src[l.pos] == '_' || isLetter(l.src[l.pos]) || isDigit(l.src[l.pos])) {
+			l.pos++
+		}
+		return token{kind: tokName, text: l.src[start:l.pos], pos: start}, nil
+	case c == '-' || isDigit(c):
+		return l.number()
+	case c == '"':
+		if strings.HasPrefix(l.src[l.pos:], `"""`) {
+			return l.blockString()
+		}
+		return l.string()
+	}
+	return token{}, fmt.Errorf("syntax error at offset %d: unexpected character %q", start, c)
+}
+
+func (l *lexer) number() (token, error) {
+	start := l.pos
+	if l.src[l.pos] == '-' {
+		l.pos++
+	}
+	digits := func() int {
+		n := 0
+		for l.pos < len(l.src) && isDigit(l.src[l.pos]) {
+			l.pos++
+			n++
+		}
+		return n
+	}
+	if digits() == 0 {
+		return token{}, fmt.Errorf("syntax error at offset %d: invalid number", start)
+	}
+	kind := tokInt
+	if l.pos < len(l.src) && l.src[l.pos] == '.' {
+		l.pos++
+		kind = tokFloat
+		if digits() == 0 {
+			return token{}, fmt.Errorf("syntax error at offset %d: invalid number", start)
+		}
+	}
+	if l.pos < len(l.src) && (l.src[l.pos] == 'e' || l.src[l.pos] == 'E') {
+		l.pos++
+		kind = tokFloat
+		if l.pos < len(l.src) && (l.src[l.pos] == '+' || l.src[l.pos] == '-') {
+			l.pos++
+		}
+		if digits() == 0 {
+			return token{}, fmt.Errorf("syntax error at offset %d: invalid number", start)
+		}
+	}
+	return token{kind: kind, text: l.src[start:l.pos], pos: start}, nil
+}
+
+func (l *lexer) string() (token, error) {
+	start := l.pos
+	l.pos++ // opening quote
+	var sb strings.Builder
+	for l.pos < len(l.src) {
+		c := l.src[l.pos]
+		switch {
+		case c == '"':
+			l.pos++
+			return token{kind: tokString, text: sb.String(), pos: start}, nil
+		case c == '\n' || c == '\r':
+			return token{}, fmt.Errorf("syntax error at offset %d: unterminated string", start)
+		case c == '\\':
+			if l.pos+1 >= len(l.src) {
+				return token{}, fmt.Errorf("syntax error at offset %d: unterminated string", start)
+			}
+			esc := l.src[l.pos+1]
+			l.pos += 2
+			switch esc {
+			case '"', '\\', '/':
+				sb.WriteByte(esc)
+			case 'b':
+				sb.WriteByte('\b')
+			case 'f':
+				sb.WriteByte('\f')
+			case 'n':
+				sb.WriteByte('\n')
+			case 'r':
+				sb.WriteByte('\r')
+			case 't':
+				sb.WriteByte('\t')
+			case 'u':
+				if l.pos+4 > len(l.src) {
+					return token{}, fmt.Errorf("syntax error at offset %d: invalid unicode escape", l.pos)
+				}
+				r, err := strconv.ParseUint(l.src[l.pos:l.pos+4], 16, 32)
+				if err != nil {
+					return token{}, fmt.Errorf("syntax error at offset %d: invalid unicode escape", l.pos)
+				}
+				sb.WriteRune(rune(r))
+				l.pos += 4
+			default:
+				return token{}, fmt.Errorf("syntax error at offset %d: invalid escape \\%c", l.pos-2, esc)
+			}
+		default:
+			r, size := utf8.DecodeRuneInString(l.src[l.pos:])
+			sb.WriteRune(r)
+			l.pos += size
+		}
+	}
+	return token{}, fmt.Errorf("syntax error at offset %d: unterminated string", start)
+}
+
+// blockString reads a """block string""". Common indentation is not
+// removed; the value is the raw text with \""" unescaped.
+func (l *lexer) blockString() (token, error) {
+	start := l.pos
+	l.pos += 3
+	var sb strings.Builder
+	for l.pos < len(l.src) {
+		rest := l.src[l.pos:]
+		switch {
+		case strings.HasPrefix(rest, `\"""`):
+			sb.WriteString(`"""`)
+			l.pos += 4
+		case strings.HasPrefix(rest, `"""`):
+			l.pos += 3
+			return token{kind: tokString, text: sb.String(), pos: start}, nil
+		default:
+			sb.WriteByte(l.src[l.pos])
+			l.pos++
+		}
+	}
+	return token{}, fmt.Errorf("syntax error at offset %d: unterminated block string", start)
+}
+
+func isLetter(c byte) bool { return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') }
+func isDigit(c byte) bool  { return c >= '0' && c <= '9' }
+
+type parser struct {
+	lex lexer
+	tok token
+}
+
+func (p *parser) advance() error {
+	tok, err := p.lex.next()
+	if err != nil {
+		return err
+	}
+	p.tok = tok
+	return nil
+}
+
+func (p *parser) unexpected() error {
+	if p.tok.kind == tokEOF {
+		return fmt.Errorf("syntax error: unexpected end of document")
+	}
+	return fmt.Errorf("syntax error at offset %d: unexpected %q", p.tok.pos, p.tok.text)
+}
+
+func (p *parser) expect(text string) error {
+	if !p.tok.is(tokPunct, text) {
+		return p.unexpected()
+	}
+	return p.advance()
+}
+
+func (p *parser) name() (string, error) {
+	if p.tok.kind != tokName {
+		return "", p.unexpected()
+	}
+	name := p.tok.text
+	return name, p.advance()
+}
+
+func (p *parser) operation() (*Operation, error) {
+	op := &Operation{Kind: p.tok.text}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	if p.tok.kind == tokName {
+		op.Name = p.tok.text
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+	}
+	if p.tok.is(tokPunct, "(") {
+		vars, err := p.variableDefs()
+		if err != nil {
+			return nil, err
+		}
+		op.Variables = vars
+	}
+	if _, err := p.directives(); err != nil {
+		return nil, err
+	}
+	sel, err := p.selectionSet()
+	if err != nil {
+		return nil, err
+	}
+	op.Selection = sel
+	return op, nil
+}
+
+func (p *parser) variableDefs() ([]VariableDef, error) {
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+	var defs []VariableDef
+	for !p.tok.is(tokPunct, ")") {
+		if err := p.expect("$"); err != nil {
+			return nil, err
+		}
+		name, err := p.name()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(":"); err != nil {
+			return nil, err
+		}
+		typ, err := p.typeRef()
+		if err != nil {
+			return nil, err
+		}
+		def := VariableDef{Name: name, Type: typ}
+		if p.tok.is(tokPunct, "=") {
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+			if def.Default, err = p.value(true); err != nil {
+				return nil, err
+			}
+		}
+		if _, err := p.directives(); err != nil {
+			return nil, err
+		}
+		defs = append(defs, def)
+	}
+	return defs, p.advance()
+}
+
+func (p *parser) typeRef() (string, error) {
+	var typ string
+	if p.tok.is(tokPunct, "[") {
+		if err := p.advance(); err != nil {
+			return "", err
+		}
+		inner, err := p.typeRef()
+		if err != nil {
+			return "", err
+		}
+		if err := p.expect("]"); err != nil {
+			return "", err
+		}
+		typ = "[" + inner + "]"
+	} else {
+		name, err := p.name()
+		if err != nil {
+			return "", err
+		}
+		typ = name
+	}
+	if p.tok.is(tokPunct, "!") {
+		typ += "!"
+		if err := p.advance(); err != nil {
+			return "", err
+		}
+	}
+	return typ, nil
+}
+
+func (p *parser) fragment() (*Fragment, error) {
+	if err := p.advance(); err != nil { // "fragment"
+		return nil, err
+	}
+	name, err := p.name()
+	if err != nil {
+		return nil, err
+	}
+	if name == "on" {
+		return nil, fmt.Errorf("syntax error: fragment cannot be named \"on\"")
+	}
+	if !p.tok.is(tokName, "on") {
+		return nil, p.unexpected()
+	}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	cond, err := p.name()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := p.directives(); err != nil {
+		return nil, err
+	}
+	sel, err := p.selectionSet()
+	if err != nil {
+		return nil, err
+	}
+	return &Fragment{Name: name, TypeCondition: cond, Selection: sel}, nil
+}
+
+func (p *parser) selectionSet() ([]Selection, error) {
+	if err := p.expect("{"); err != nil {
+		return nil, err
+	}
+	var sels []Selection
+	for !p.tok.is(tokPunct, "}") {
+		sel, err := p.selection()
+		if err != nil {
+			return nil, err
+		}
+		sels = append(sels, sel)
+	}
+	if len(sels) == 0 {
+		return nil, fmt.Errorf("syntax error at offset %d: empty selection set", p.tok.pos)
+	}
+	return sels, p.advance()
+}
+
+func (p *parser) selection() (Selection, error) {
+	if p.tok.is(tokPunct, "...") {
+		if err := p.advance(); err != nil {
+			return Selection{}, err
+		}
+		if p.tok.kind == tokName && p.tok.text != "on" {
+			name := p.tok.text
+			if err := p.advance(); err != nil {
+				return Selection{}, err
+			}
+			dirs, err := p.directives()
+			return Selection{Spread: name, Directives: dirs}, err
+		}
+		frag := &Fragment{}
+		if p.tok.is(tokName, "on") {
+			if err := p.advance(); err != nil {
+				return Selection{}, err
+			}
+			cond, err := p.name()
+			if err != nil {
+				return Selection{}, err
+			}
+			frag.TypeCondition = cond
+		}
+		dirs, err := p.directives()
+		if err != nil {
+			return Selection{}, err
+		}
+		if frag.Selection, err = p.selectionSet(); err != nil {
+			return Selection{}, err
+		}
+		return Selection{Inline: frag, Directives: dirs}, nil
+	}
+
+	f := &FieldNode{}
+	name, err := p.name()
+	if err != nil {
+		return Selection{}, err
+	}
+	if p.tok.is(tokPunct, ":") {
+		if err := p.advance(); err != nil {
+			return Selection{}, err
+		}
+		f.Alias = name
+		if name, err = p.name(); err != nil {
+			return Selection{}, err
+		}
+	}
+	f.Name = name
+	if p.tok.is(tokPunct, "(") {
+		if f.Arguments, err = p.arguments(); err != nil {
+			return Selection{}, err
+		}
+	}
+	dirs, err := p.directives()
+	if err != nil {
+		return Selection{}, err
+	}
+	if p.tok.is(tokPunct, "{") {
+		if f.Selection, err = p.selectionSet(); err != nil {
+			return Selection{}, err
+		}
+	}
+	return Selection{Field: f, Directives: dirs}, nil
+}
+
+func (p *parser) arguments() (map[string]Value, error) {
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+	args := map[string]Value{}
+	for !p.tok.is(tokPunct, ")") {
+		name, err := p.name()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(":"); err != nil {
+			return nil, err
+		}
+		v, err := p.value(false)
+		if err != nil {
+			return nil, err
+		}
+		if _, dup := args[name]; dup {
+			return nil, fmt.Errorf("argument %q is given more than once", name)
+		}
+		args[name] = v
+	}
+	return args, p.advance()
+}
+
+func (p *parser) directives() ([]Directive, error) {
+	var dirs []Directive
+	for p.tok.is(tokPunct, "@") {
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		name, err := p.name()
+		if err != nil {
+			return nil, err
+		}
+		d := Directive{Name: name}
+		if p.tok.is(tokPunct, "(") {
+			if d.Arguments, err = p.arguments(); err != nil {
+				return nil, err
+			}
+		}
+		dirs = append(dirs, d)
+	}
+	return dirs, nil
+}
+
+// value parses an argument value. Constant values (variable defaults) may
+// not reference variables.
+func (p *parser) value(constant bool) (Value, error) {
+	tok := p.tok
+	switch tok.kind {
+	case tokInt:
+		n, err := strconv.Atoi(tok.text)
+		if err != nil {
+			return nil, fmt.Errorf("invalid Int %s", tok.text)
+		}
+		return n, p.advance()
+	case tokFloat:
+		f, err := strconv.ParseFloat(tok.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid Float %s", tok.text)
+		}
+		return f, p.advance()
+	case tokString:
+		return tok.text, p.advance()
+	case tokName:
+		var v Value
+		switch tok.text {
+		case "true":
+			v = true
+		case "false":
+			v = false
+		case "null":
+			v = nil
+		default:
+			v = EnumValue(tok.text)
+		}
+		return v, p.advance()
+	case tokPunct:
+		switch tok.text {
+		case "$":
+			if constant {
+				return nil, fmt.Errorf("syntax error at offset %d: variable in constant value", tok.pos)
+			}
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+			name, err := p.name()
+			return Variable(name), err
+		case "[":
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+			list := []Value{}
+			for !p.tok.is(tokPunct, "]") {
+				v, err := p.value(constant)
+				if err != nil {
+					return nil, err
+				}
+				list = append(list, v)
+			}
+			return list, p.advance()
+		case "{":
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+			obj := map[string]Value{}
+			for !p.tok.is(tokPunct, "}") {
+				name, err := p.name()
+				if err != nil {
+					return nil, err
+				}
+				if err := p.expect(":"); err != nil {
+					return nil, err
+				}
+				if obj[name], err = p.value(constant); err != nil {
+					return nil, err
+				}
+			}
+			return obj, p.advance()
+		}
+	}
+	return nil, p.unexpected()
+}
@@ -0,0 +1,563 @@
+package ingestion
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/toposcope/toposcope/pkg/extract"
+	"github.com/toposcope/toposcope/pkg/graph"
+)
+
+// Backfill job and commit statuses. A commit is PENDING until a worker
+// claims it, RUNNING while it is extracted, EXTRACTED once its snapshot is
+// stored, and DONE once it is scored against the commit before it.
+const (
+	BackfillRunning   = "RUNNING"
+	BackfillCompleted = "COMPLETED"
+	BackfillCancelled = "CANCELLED"
+	BackfillFailed    = "FAILED" // a commit could not be scored; see BackfillJob.Error
+
+	backfillPending   = "PENDING"
+	backfillExtracted = "EXTRACTED"
+	backfillDone      = "DONE"
+	backfillFailed    = "FAILED"
+)
+
+const (
+	// MaxBackfillCommits bounds the sampled commits in one backfill job.
+	MaxBackfillCommits = 500
+	// MaxBackfillConcurrency bounds a job's concurrent extractions.
+	MaxBackfillConcurrency = 16
+	// backfillAttempts is how often a commit is tried before it is skipped.
+	backfillAttempts = 3
+)
+
+// ErrBackfillActive is returned by CreateBackfill when the repository
+// already has a running backfill.
+var ErrBackfillActive = errors.New("repository already has a running backfill")
+
+// BackfillRequest describes a backfill job to create.
+type BackfillRequest struct {
+	RepoID string
+	// Commits are the commits to extract, oldest first. If empty, the
+	// first-parent history of the default branch since Since is listed
+	// from GitHub.
+	Commits []string
+	Since   time.Time
+	// Every keeps every n-th commit, always including the first and last.
+	Every int
+	// Concurrency bounds the job's concurrent extractions (default 2).
+	Concurrency int
+	// PerHour bounds the extractions the job starts per hour (0 = no limit).
+	PerHour int
+}
+
+// BackfillJob is a backfill job and its progress.
+type BackfillJob struct {
+	ID          string    `json:"id"`
+	RepoID      string    `json:"repo_id"`
+	Branch      string    `json:"branch"`
+	Status      string    `json:"status"`
+	Concurrency int       `json:"concurrency"`
+	PerHour     int       `json:"per_hour"`
+	Total       int       `json:"total"`
+	Pending     int       `json:"pending"`
+	Running     int       `json:"running"`
+	Extracted   int       `json:"extracted"` // snapshots stored, reused ones included
+	Reused      int       `json:"reused"`    // snapshots that already existed
+	Scored      int       `json:"scored"`
+	Failed      int       `json:"failed"`
+	Error       string    `json:"error,omitempty"` // why the job failed
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// BackfillOptions controls RunBackfillWorker.
+type BackfillOptions struct {
+	// PollInterval is how long an idle worker waits before looking for
+	// work again (default 10s).
+	PollInterval time.Duration
+	// StaleAfter is how long a commit may stay RUNNING before another
+	// worker retries it, in case its worker died (default 2h).
+	StaleAfter time.Duration
+}
+
+// CommitLister lists the history of a branch for repositories the GitHub
+// App is installed on.
+type CommitLister interface {
+	// ListCommits returns the first-parent history of branch since the
+	// given time, oldest first.
+	ListCommits(ctx context.Context, installationID int64, repo, branch string, since time.Time) ([]string, error)
+}
+
+// SampleCommits keeps every n-th commit of an oldest-first list, always
+// including the first and the last.
+func SampleCommits(commits []string, every int) []string {
+	if every <= 1 || len(commits) == 0 {
+		return commits
+	}
+	var sampled []string
+	for i := 0; i < len(commits); i += every {
+		sampled = append(sampled, commits[i])
+	}
+	if last := commits[len(commits)-1]; sampled[len(sampled)-1] != last {
+		sampled = append(sampled, last)
+	}
+	return sampled
+}
+
+// CreateBackfill creates a backfill job for the commits req selects on the
+// repository's default branch. The job is run by RunBackfillWorker, on any
+// instance of the service that runs one.
+func (s *Service) CreateBackfill(ctx context.Context, req BackfillRequest) (*BackfillJob, error) {
+	var r IngestionRequest
+	var installationID sql.NullInt64
+	err := s.db.QueryRowContext(ctx,
+		`SELECT r.tenant_id, r.full_name, r.default_branch, t.github_installation_id
+		 FROM repositories r JOIN tenants t ON t.id = r.tenant_id
+		 WHERE r.id = $1 AND r.deleted_at IS NULL`,
+		req.RepoID,
+	).Scan(&r.TenantID, &r.RepoFullName, &r.BaseBranch, &installationID)
+	if err != nil {
+		return nil, fmt.Errorf("get repository: %w", err)
+	}
+
+	commits := req.Commits
+	if len(commits) == 0 {
+		lister, ok := s.Commits.(CommitLister)
+		if !ok || !installationID.Valid {
+			return nil, fmt.Errorf("listing commits needs the GitHub App to be installed on %s; pass the commits instead", r.RepoFullName)
+		}
+		if commits, err = lister.ListCommits(ctx, installationID.Int64, r.RepoFullName, r.BaseBranch, req.Since); err != nil {
+			return nil, fmt.Errorf("list commits: %w", err)
+		}
+	}
+	commits = SampleCommits(commits, req.Every)
+	if len(commits) == 0 {
+		return nil, fmt.Errorf("no commits to backfill")
+	}
+	if len(commits) > MaxBackfillCommits {
+		return nil, fmt.Errorf("%d commits selected; at most %d are allowed, so sample with a larger every", len(commits), MaxBackfillCommits)
+	}
+	concurrency := req.Concurrency
+	if concurrency <= 0 {
+		concurrency = 2
+	}
+	concurrency = min(concurrency, MaxBackfillConcurrency)
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var id string
+	err = tx.QueryRowContext(ctx,
+		`INSERT INTO backfill_jobs (tenant_id, repo_id, branch, concurrency, per_hour)
+		 VALUES ($1, $2, $3, $4, $5)
+		 ON CONFLICT (repo_id) WHERE status = 'RUNNING' DO NOTHING
+		 RETURNING id`,
+		r.TenantID, req.RepoID, r.BaseBranch, concurrency, max(req.PerHour, 0),
+	).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrBackfillActive
+	}
+	if err != nil {
+		return nil, fmt.Errorf("insert backfill job: %w", err)
+	}
+	for i, sha := range commits {
+		if _, err := tx.ExecContext(ctx,
+			`INSERT INTO backfill_commits (job_id, position, commit_sha) VALUES ($1, $2, $3)`,
+			id, i, sha,
+		); err != nil {
+			return nil, fmt.Errorf("insert backfill commit: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("commit backfill job: %w", err)
+	}
+	return s.GetBackfill(ctx, id)
+}
+
+const backfillJobQuery = `
+	SELECT j.id, j.repo_id, j.branch, j.status, j.concurrency, j.per_hour, COALESCE(j.error_message, ''), j.created_at, j.updated_at,
+	       count(c.*),
+	       count(*) FILTER (WHERE c.status = 'PENDING'),
+	       count(*) FILTER (WHERE c.status = 'RUNNING'),
+	       count(*) FILTER (WHERE c.status IN ('EXTRACTED', 'DONE')),
+	       count(*) FILTER (WHERE c.reused),
+	       count(*) FILTER (WHERE c.score_id IS NOT NULL),
+	       count(*) FILTER (WHERE c.status = 'FAILED')
+	FROM backfill_jobs j LEFT JOIN backfill_commits c ON c.job_id = j.id`
+
+func scanBackfillJob(row interface{ Scan(...any) error }) (*BackfillJob, error) {
+	var j BackfillJob
+	err := row.Scan(&j.ID, &j.RepoID, &j.Branch, &j.Status, &j.Concurrency, &j.PerHour, &j.Error, &j.CreatedAt, &j.UpdatedAt,
+		&j.Total, &j.Pending, &j.Running, &j.Extracted, &j.Reused, &j.Scored, &j.Failed)
+	if err != nil {
+		return nil, err
+	}
+	return &j, nil
+}
+
+// GetBackfill returns a backfill job and its progress.
+func (s *Service) GetBackfill(ctx context.Context, id string) (*BackfillJob, error) {
+	j, err := scanBackfillJob(s.db.QueryRowContext(ctx, backfillJobQuery+` WHERE j.id = $1 GROUP BY j.id`, id))
+	if err != nil {
+		return nil, fmt.Errorf("get backfill job: %w", err)
+	}
+	return j, nil
+}
+
+// ListBackfills returns a repository's backfill jobs, newest first.
+func (s *Service) ListBackfills(ctx context.Context, repoID string) ([]BackfillJob, error) {
+	rows, err := s.db.QueryContext(ctx, backfillJobQuery+` WHERE j.repo_id = $1 GROUP BY j.id ORDER BY j.created_at DESC`, repoID)
+	if err != nil {
+		return nil, fmt.Errorf("list backfill jobs: %w", err)
+	}
+	defer rows.Close()
+	jobs := []BackfillJob{}
+	for rows.Next() {
+		j, err := scanBackfillJob(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan backfill job: %w", err)
+		}
+		jobs = append(jobs, *j)
+	}
+	return jobs, rows.Err()
+}
+
+// CancelBackfill stops a running backfill job. Extractions already running
+// finish, but nothing further is started or scored.
+func (s *Service) CancelBackfill(ctx context.Context, id string) (*BackfillJob, error) {
+	if _, err := s.db.ExecContext(ctx,
+		`UPDATE backfill_jobs SET status = $1, updated_at = now() WHERE id = $2 AND status = $3`,
+		BackfillCancelled, id, BackfillRunning,
+	); err != nil {
+		return nil, fmt.Errorf("cancel backfill job: %w", err)
+	}
+	return s.GetBackfill(ctx, id)
+}
+
+// backfillCommit is a claimed commit of a backfill job.
+type backfillCommit struct {
+	jobID    string
+	position int
+	sha      string
+	attempts int
+	charged  bool // the extraction has been counted toward the quota
+	req      IngestionRequest
+}
+
+// RunBackfillWorker extracts backfill commits until ctx is done. Workers
+// on every instance claim commits from the same table, so running more
+// instances, or more workers, spreads a job across runners; each job's
+// concurrency and hourly limits hold across all of them.
+func (s *Service) RunBackfillWorker(ctx context.Context, opts BackfillOptions) {
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = 10 * time.Second
+	}
+	if opts.StaleAfter <= 0 {
+		opts.StaleAfter = 2 * time.Hour
+	}
+	for {
+		c, err := s.claimBackfillCommit(ctx, opts.StaleAfter)
+		if err != nil && ctx.Err() == nil {
+			log.Printf("backfill: claim commit: %v", err)
+		}
+		if c == nil {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(opts.PollInterval):
+			}
+			continue
+		}
+		s.runBackfillCommit(ctx, c)
+	}
+}
+
+// claimBackfillCommit marks the next commit of a running job as RUNNING and
+// returns it, or nil if no job may start an extraction now. The job row is
+// locked while claiming, so its limits hold across concurrent workers.
+func (s *Service) claimBackfillCommit(ctx context.Context, staleAfter time.Duration) (*backfillCommit, error) {
+	var c backfillCommit
+	var installationID sql.NullInt64
+	err := s.db.QueryRowContext(ctx,
+		`WITH next AS (
+		   SELECT c.job_id, c.position
+		   FROM backfill_commits c JOIN backfill_jobs j ON j.id = c.job_id
+		   WHERE j.status = 'RUNNING'
+		     AND (c.status = 'PENDING' OR (c.status = 'RUNNING' AND c.started_at < now() - make_interval(secs => $1)))
+		     AND (SELECT count(*) FROM backfill_commits r
+		          WHERE r.job_id = j.id AND r.status = 'RUNNING' AND r.started_at >= now() - make_interval(secs => $1)) < j.concurrency
+		     AND (j.per_hour = 0 OR (SELECT count(*) FROM backfill_commits r
+		          WHERE r.job_id = j.id AND r.started_at > now() - interval '1 hour') < j.per_hour)
+		   ORDER BY j.created_at, c.position
+		   LIMIT 1
+		   FOR UPDATE OF j, c SKIP LOCKED
+		 )
+		 UPDATE backfill_commits c SET status = 'RUNNING', started_at = now(), attempts = c.attempts + 1
+		 FROM next, backfill_jobs j, repositories r, tenants t
+		 WHERE c.job_id = next.job_id AND c.position = next.position
+		   AND j.id = c.job_id AND r.id = j.repo_id AND t.id = r.tenant_id
+		 RETURNING c.job_id, c.position, c.commit_sha, c.attempts, c.charged, j.tenant_id, j.repo_id, r.full_name, j.branch, t.github_installation_id`,
+		staleAfter.Seconds(),
+	).Scan(&c.jobID, &c.position, &c.sha, &c.attempts, &c.charged,
+		&c.req.TenantID, &c.req.RepoID, &c.req.RepoFullName, &c.req.BaseBranch, &installationID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	c.req.CommitSHA = c.sha
+	c.req.InstallationID = installationID.Int64
+	return &c, nil
+}
+
+// runBackfillCommit stores a snapshot of the claimed commit, reusing one the
+// repository already has, then scores whatever the job can now score.
+func (s *Service) runBackfillCommit(ctx context.Context, c *backfillCommit) {
+	snapshotID, reused, err := s.backfillSnapshot(ctx, c)
+	if err != nil {
+		status := backfillPending
+		if c.attempts >= backfillAttempts {
+			status = backfillFailed
+		}
+		log.Printf("backfill %s: %s of %s (attempt %d): %v", c.jobID, c.sha, c.req.RepoFullName, c.attempts, err)
+		if _, uerr := s.db.ExecContext(ctx,
+			`UPDATE backfill_commits SET status = $1, error_message = $2 WHERE job_id = $3 AND position = $4`,
+			status, err.Error(), c.jobID, c.position,
+		); uerr != nil {
+			log.Printf("backfill %s: record failure: %v", c.jobID, uerr)
+		}
+		if status == backfillFailed {
+			s.scoreBackfill(ctx, c.jobID)
+		}
+		return
+	}
+
+	if _, err := s.db.ExecContext(ctx,
+		`UPDATE backfill_commits SET status = $1, snapshot_id = $2, reused = $3, error_message = NULL
+		 WHERE job_id = $4 AND position = $5`,
+		backfillExtracted, snapshotID, reused, c.jobID, c.position,
+	); err != nil {
+		log.Printf("backfill %s: record snapshot of %s: %v", c.jobID, c.sha, err)
+		return
+	}
+	s.scoreBackfill(ctx, c.jobID)
+}
+
+func (s *Service) backfillSnapshot(ctx context.Context, c *backfillCommit) (id string, reused bool, err error) {
+	err = s.db.QueryRowContext(ctx,
+		`SELECT id FROM snapshots WHERE repo_id = $1 AND commit_sha = $2`,
+		c.req.RepoID, c.sha,
+	).Scan(&id)
+	if err == nil {
+		return id, true, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return "", false, fmt.Errorf("look up snapshot: %w", err)
+	}
+
+	if s.extractor == nil {
+		return "", false, fmt.Errorf("no extractor configured; hosted extraction is unavailable")
+	}
+	// Retries extract the same commit, so it counts toward the quota once.
+	if !c.charged {
+		if err := s.BeginIngestion(ctx, c.req.TenantID, 1); err != nil {
+			return "", false, err
+		}
+		if _, err := s.db.ExecContext(ctx,
+			`UPDATE backfill_commits SET charged = true WHERE job_id = $1 AND position = $2`, c.jobID, c.position,
+		); err != nil {
+			return "", false, fmt.Errorf("record quota charge: %w", err)
+		}
+		c.charged = true
+	}
+	start := time.Now()
+	snap, err := s.extractor.Extract(ctx, extract.ExtractionRequest{
+		CommitSHA: c.sha,
+		Scope: extract.ExtractionScope{
+			Mode: extract.ScopeModeFull,
+		},
+		Repo:           c.req.RepoFullName,
+		InstallationID: c.req.InstallationID,
+	})
+	if err != nil {
+		return "", false, fmt.Errorf("extract: %w", err)
+	}
+	snap.Stats.ExtractionMs = int(time.Since(start).Milliseconds())
+	snap.Branch = c.req.BaseBranch
+
+	data, err := json.Marshal(snap)
+	if err != nil {
+		return "", false, fmt.Errorf("marshal snapshot: %w", err)
+	}
+	if id, err = s.StoreSnapshot(ctx, c.req, snap, data); err != nil {
+		return "", false, err
+	}
+	s.recordCommit(ctx, c.req, id, c.sha)
+	return id, false, nil
+}
+
+// scoreBackfill scores, in order, each extracted commit of a job against the
+// last commit before it that didn't fail, as far as the extractions allow,
+// and completes the job once every commit is done or failed. A pair that
+// can't be scored fails the job, since every later score depends on it.
+// The job row is locked throughout, so each pair is scored once.
+func (s *Service) scoreBackfill(ctx context.Context, jobID string) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Printf("backfill %s: begin transaction: %v", jobID, err)
+		return
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var req IngestionRequest
+	var status string
+	if err := tx.QueryRowContext(ctx,
+		`SELECT tenant_id, repo_id, branch, status FROM backfill_jobs WHERE id = $1 FOR UPDATE`, jobID,
+	).Scan(&req.TenantID, &req.RepoID, &req.BaseBranch, &status); err != nil {
+		log.Printf("backfill %s: lock job: %v", jobID, err)
+		return
+	}
+	if status != BackfillRunning {
+		return
+	}
+
+	type row struct {
+		position   int
+		sha        string
+		status     string
+		snapshotID sql.NullString
+	}
+	rows, err := tx.QueryContext(ctx,
+		`SELECT position, commit_sha, status, snapshot_id FROM backfill_commits WHERE job_id = $1 ORDER BY position`, jobID)
+	if err != nil {
+		log.Printf("backfill %s: list commits: %v", jobID, err)
+		return
+	}
+	var commits []row
+	for rows.Next() {
+		var r row
+		if err := rows.Scan(&r.position, &r.sha, &r.status, &r.snapshotID); err != nil {
+			rows.Close()
+			log.Printf("backfill %s: scan commit: %v", jobID, err)
+			return
+		}
+		commits = append(commits, r)
+	}
+	rows.Close()
+
+	var prevID string
+	var jobErr *string
+	finished := true
+	for _, c := range commits {
+		if c.status == backfillFailed {
+			continue
+		}
+		if c.status == backfillDone {
+			prevID = c.snapshotID.String
+			continue
+		}
+		if c.status != backfillExtracted {
+			finished = false
+			break
+		}
+
+		var scoreID *string
+		if prevID != "" && prevID != c.snapshotID.String {
+			req.CommitSHA = c.sha
+			id, err := s.scoreBackfillPair(ctx, req, prevID, c.snapshotID.String)
+			if err != nil {
+				log.Printf("backfill %s: score %s: %v", jobID, c.sha, err)
+				msg := fmt.Sprintf("score %s: %v", c.sha, err)
+				status, jobErr, finished = BackfillFailed, &msg, false
+				break
+			}
+			scoreID = &id
+		}
+		if _, err := tx.ExecContext(ctx,
+			`UPDATE backfill_commits SET status = $1, score_id = $2 WHERE job_id = $3 AND position = $4`,
+			backfillDone, scoreID, jobID, c.position,
+		); err != nil {
+			log.Printf("backfill %s: record score of %s: %v", jobID, c.sha, err)
+			return
+		}
+		prevID = c.snapshotID.String
+	}
+
+	if finished {
+		status = BackfillCompleted
+	}
+	if _, err := tx.ExecContext(ctx,
+		`UPDATE backfill_jobs SET status = $1, error_message = $2, updated_at = now() WHERE id = $3`, status, jobErr, jobID,
+	); err != nil {
+		log.Printf("backfill %s: update job: %v", jobID, err)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		log.Printf("backfill %s: commit: %v", jobID, err)
+	}
+}
+
+// scoreBackfillPair scores the head snapshot against the base snapshot,
+// reusing a score the repository already has for the pair. The score is
+// dated at the head commit so it lands in the right place in the history.
+// Score webhooks are not sent for backfilled scores.
+func (s *Service) scoreBackfillPair(ctx context.Context, req IngestionRequest, baseID, headID string) (string, error) {
+	var scoreID string
+	err := s.db.QueryRowContext(ctx,
+		`SELECT id FROM scores WHERE base_snapshot_id = $1 AND head_snapshot_id = $2 AND pr_number IS NULL LIMIT 1`,
+		baseID, headID,
+	).Scan(&scoreID)
+	if err == nil {
+		return scoreID, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return "", fmt.Errorf("look up score: %w", err)
+	}
+	if s.scorer == nil {
+		return "", fmt.Errorf("no scorer configured")
+	}
+
+	var committedAt time.Time
+	if err := s.db.QueryRowContext(ctx,
+		`SELECT COALESCE(committed_at, created_at) FROM snapshots WHERE id = $1`, headID,
+	).Scan(&committedAt); err != nil {
+		return "", fmt.Errorf("get head snapshot: %w", err)
+	}
+	req.CommittedAt = &committedAt
+
+	base, err := s.loadSnapshot(ctx, req.TenantID, baseID)
+	if err != nil {
+		return "", fmt.Errorf("load base snapshot: %w", err)
+	}
+	head, err := s.loadSnapshot(ctx, req.TenantID, headID)
+	if err != nil {
+		return "", fmt.Errorf("load head snapshot: %w", err)
+	}
+
+	delta := graph.ComputeDelta(base, head)
+	delta.BaseSnapshotID, delta.HeadSnapshotID = baseID, headID
+	deltaData, err := json.Marshal(delta)
+	if err != nil {
+		return "", fmt.Errorf("marshal delta: %w", err)
+	}
+	deltaID, err := s.StoreDelta(ctx, req, delta, deltaData)
+	if err != nil {
+		return "", err
+	}
+	result, err := s.scorer.Score(base, head, delta)
+	if err != nil {
+		return "", fmt.Errorf("score: %w", err)
+	}
+	return s.StoreScore(ctx, req, baseID, headID, deltaID, result)
+}
@@ -0,0 +1,97 @@
+package ingestion
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSampleCommits(t *testing.T) {
+	commits := []string{"a", "b", "c", "d", "e", "f", "g"}
+	tests := []struct {
+		every int
+		want  string
+	}{
+		{1, "abcdefg"},
+		{2, "aceg"},
+		{3, "adg"},
+		{4, "aeg"},
+		{10, "ag"},
+	}
+	for _, tt := range tests {
+		if got := strings.Join(SampleCommits(commits, tt.every), ""); got != tt.want {
+			t.Errorf("SampleCommits(every=%d) = %q, want %q", tt.every, got, tt.want)
+		}
+	}
+	if got := SampleCommits([]string{"a"}, 5); len(got) != 1 {
+		t.Errorf("SampleCommits of one commit = %v", got)
+	}
+}
+
+func TestFirstParentChain(t *testing.T) {
+	// d merges c (a side branch off a) into b; e is unrelated to the chain.
+	listed := []listedCommit{
+		commit("d", "b", "c"),
+		commit("c", "a"),
+		commit("b", "a"),
+		commit("e", "x"),
+		commit("a", "z"), // z is older than since, so not listed
+	}
+	if got := strings.Join(firstParentChain(listed), ""); got != "abd" {
+		t.Errorf("firstParentChain = %q, want %q", got, "abd")
+	}
+	if got := firstParentChain(nil); got != nil {
+		t.Errorf("firstParentChain(nil) = %v", got)
+	}
+}
+
+func TestGitHubListCommits(t *testing.T) {
+	var query url.Values
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/repos/acme/app/commits" {
+			http.NotFound(w, r)
+			return
+		}
+		query = r.URL.Query()
+		// The first page is full, so a second one is requested.
+		if r.URL.Query().Get("page") == "1" {
+			var b strings.Builder
+			b.WriteString("[")
+			for i := 100; i > 0; i-- {
+				fmt.Fprintf(&b, `{"sha":"c%d","parents":[{"sha":"c%d"}]},`, i, i-1)
+			}
+			w.Write([]byte(strings.TrimSuffix(b.String(), ",") + "]"))
+			return
+		}
+		w.Write([]byte(`[{"sha":"c0","parents":[]}]`))
+	}))
+	defer srv.Close()
+
+	g := &GitHubCommits{Tokens: staticTokens("tok"), APIURL: srv.URL}
+	since := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	commits, err := g.ListCommits(context.Background(), 7, "acme/app", "main", since)
+	if err != nil {
+		t.Fatalf("ListCommits: %v", err)
+	}
+	if len(commits) != 101 || commits[0] != "c0" || commits[100] != "c100" {
+		t.Errorf("ListCommits returned %d commits, %v ... %v", len(commits), commits[0], commits[len(commits)-1])
+	}
+	if query.Get("sha") != "main" || query.Get("since") != "2024-01-01T00:00:00Z" {
+		t.Errorf("query = %v", query)
+	}
+}
+
+func commit(sha string, parents ...string) listedCommit {
+	c := listedCommit{SHA: sha}
+	for _, p := range parents {
+		c.Parents = append(c.Parents, struct {
+			SHA string `json:"sha"`
+		}{p})
+	}
+	return c
+}
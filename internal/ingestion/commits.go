@@ -0,0 +1,202 @@
+package ingestion
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	neturl "net/url"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// maxCommitMessage bounds the commit message stored with a snapshot.
+const maxCommitMessage = 4 << 10
+
+// CommitInfo is the metadata of a commit as reported by GitHub.
+type CommitInfo struct {
+	Author      string
+	Message     string
+	CommittedAt time.Time
+}
+
+// CommitFetcher looks up commit metadata for repositories the GitHub App is
+// installed on.
+type CommitFetcher interface {
+	Commit(ctx context.Context, installationID int64, repo, sha string) (*CommitInfo, error)
+}
+
+// GitHubCommits implements CommitFetcher with the GitHub REST API,
+// authenticating with installation tokens.
+type GitHubCommits struct {
+	Tokens     TokenSource
+	APIURL     string // default: https://api.github.com
+	HTTPClient *http.Client
+}
+
+// Commit fetches the author, message, and commit time of sha in repo
+// ("owner/name").
+func (g *GitHubCommits) Commit(ctx context.Context, installationID int64, repo, sha string) (*CommitInfo, error) {
+	url := fmt.Sprintf("%s/repos/%s/commits/%s", firstNonEmpty(g.APIURL, "https://api.github.com"), repo, sha)
+	var body struct {
+		Commit struct {
+			Author struct {
+				Name string    `json:"name"`
+				Date time.Time `json:"date"`
+			} `json:"author"`
+			Committer struct {
+				Date time.Time `json:"date"`
+			} `json:"committer"`
+			Message string `json:"message"`
+		} `json:"commit"`
+		Author *struct {
+			Login string `json:"login"`
+		} `json:"author"` // nil if the commit email isn't linked to an account
+	}
+	if err := g.get(ctx, installationID, url, &body); err != nil {
+		return nil, fmt.Errorf("get commit %s: %w", sha, err)
+	}
+
+	info := &CommitInfo{
+		Author:      body.Commit.Author.Name,
+		Message:     truncateMessage(strings.TrimSpace(body.Commit.Message)),
+		CommittedAt: body.Commit.Committer.Date,
+	}
+	if info.Author == "" && body.Author != nil {
+		info.Author = body.Author.Login
+	}
+	if info.CommittedAt.IsZero() {
+		info.CommittedAt = body.Commit.Author.Date
+	}
+	return info, nil
+}
+
+// maxListedCommits bounds the commits ListCommits reads from GitHub.
+const maxListedCommits = 10000
+
+// listedCommit is a commit and its parents, as GitHub lists them.
+type listedCommit struct {
+	SHA     string `json:"sha"`
+	Parents []struct {
+		SHA string `json:"sha"`
+	} `json:"parents"`
+}
+
+// ListCommits implements CommitLister. GitHub lists every commit reachable
+// from the branch, merged ones included, so the first-parent chain is
+// followed from the tip.
+func (g *GitHubCommits) ListCommits(ctx context.Context, installationID int64, repo, branch string, since time.Time) ([]string, error) {
+	var listed []listedCommit
+	for page := 1; len(listed) < maxListedCommits; page++ {
+		url := fmt.Sprintf("%s/repos/%s/commits?sha=%s&since=%s&per_page=100&page=%d",
+			firstNonEmpty(g.APIURL, "https://api.github.com"), repo,
+			neturl.QueryEscape(branch), since.UTC().Format(time.RFC3339), page)
+		var batch []listedCommit
+		if err := g.get(ctx, installationID, url, &batch); err != nil {
+			return nil, fmt.Errorf("list commits of %s: %w", branch, err)
+		}
+		listed = append(listed, batch...)
+		if len(batch) < 100 {
+			break
+		}
+	}
+	return firstParentChain(listed), nil
+}
+
+// firstParentChain follows first parents from the first (newest) listed
+// commit while they are listed, and returns the chain oldest first.
+func firstParentChain(listed []listedCommit) []string {
+	if len(listed) == 0 {
+		return nil
+	}
+	byID := make(map[string]*listedCommit, len(listed))
+	for i := range listed {
+		byID[listed[i].SHA] = &listed[i]
+	}
+	var chain []string
+	for c := &listed[0]; c != nil; {
+		chain = append(chain, c.SHA)
+		if len(c.Parents) == 0 {
+			break
+		}
+		c = byID[c.Parents[0].SHA]
+	}
+	slices.Reverse(chain)
+	return chain
+}
+
+// get fetches url with an installation token and decodes the JSON response
+// into v.
+func (g *GitHubCommits) get(ctx context.Context, installationID int64, url string, v any) error {
+	token, err := g.Tokens.InstallationToken(ctx, installationID)
+	if err != nil {
+		return fmt.Errorf("get installation token: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("Authorization", "token "+token)
+	req.Header.Set("Accept", "application/vnd.github+json")
+
+	client := g.HTTPClient
+	if client == nil {
+		client = &http.Client{Timeout: 30 * time.Second}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("github API error %d: %s", resp.StatusCode, string(body))
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("decode response: %w", err)
+	}
+	return nil
+}
+
+// truncateMessage shortens msg to at most maxCommitMessage bytes without
+// splitting a UTF-8 sequence.
+func truncateMessage(msg string) string {
+	if len(msg) <= maxCommitMessage {
+		return msg
+	}
+	msg = msg[:maxCommitMessage]
+	for len(msg) > 0 && !utf8.ValidString(msg) {
+		msg = msg[:len(msg)-1]
+	}
+	return msg
+}
+
+// recordCommit stores GitHub's metadata for sha with a snapshot. It only
+// applies to installed repositories and is best-effort: failures are logged
+// and the snapshot just shows the bare SHA.
+func (s *Service) recordCommit(ctx context.Context, req IngestionRequest, snapshotID, sha string) {
+	if s.Commits == nil || req.InstallationID == 0 || sha == "" {
+		return
+	}
+	info, err := s.Commits.Commit(ctx, req.InstallationID, req.RepoFullName, sha)
+	if err != nil {
+		log.Printf("fetch commit %s of %s: %v", sha, req.RepoFullName, err)
+		return
+	}
+	var committedAt *time.Time
+	if !info.CommittedAt.IsZero() {
+		committedAt = &info.CommittedAt
+	}
+	if _, err := s.db.ExecContext(ctx,
+		`UPDATE snapshots SET commit_author = $1, commit_message = $2, committed_at = $3 WHERE id = $4`,
+		nilIfEmpty(info.Author), nilIfEmpty(info.Message), committedAt, snapshotID,
+	); err != nil {
+		log.Printf("record commit %s of %s: %v", sha, req.RepoFullName, err)
+	}
+}
@@ -0,0 +1,77 @@
+package ingestion
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+type staticTokens string
+
+func (t staticTokens) InstallationToken(context.Context, int64) (string, error) {
+	return string(t), nil
+}
+
+func TestGitHubCommits(t *testing.T) {
+	var path, auth string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path, auth = r.URL.Path, r.Header.Get("Authorization")
+		w.Write([]byte(`{
+			"commit": {
+				"author": {"name": "Ada Lovelace", "date": "2024-03-01T09:00:00Z"},
+				"committer": {"date": "2024-03-02T10:30:00Z"},
+				"message": "Split the parser package\n\nMoves lexing into its own package.\n"
+			},
+			"author": {"login": "ada"}
+		}`))
+	}))
+	defer srv.Close()
+
+	g := &GitHubCommits{Tokens: staticTokens("tok"), APIURL: srv.URL}
+	info, err := g.Commit(context.Background(), 7, "acme/app", "abc123")
+	if err != nil {
+		t.Fatalf("Commit: %v", err)
+	}
+	if path != "/repos/acme/app/commits/abc123" {
+		t.Errorf("path = %q", path)
+	}
+	if auth != "token tok" {
+		t.Errorf("Authorization = %q", auth)
+	}
+	if info.Author != "Ada Lovelace" {
+		t.Errorf("Author = %q", info.Author)
+	}
+	if info.Message != "Split the parser package\n\nMoves lexing into its own package." {
+		t.Errorf("Message = %q", info.Message)
+	}
+	if want := time.Date(2024, 3, 2, 10, 30, 0, 0, time.UTC); !info.CommittedAt.Equal(want) {
+		t.Errorf("CommittedAt = %v, want %v (committer date)", info.CommittedAt, want)
+	}
+}
+
+func TestGitHubCommitsError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"message":"Not Found"}`, http.StatusNotFound)
+	}))
+	defer srv.Close()
+
+	g := &GitHubCommits{Tokens: staticTokens("tok"), APIURL: srv.URL}
+	if _, err := g.Commit(context.Background(), 7, "acme/app", "missing"); err == nil {
+		t.Error("expected error for 404")
+	}
+}
+
+func TestTruncateMessage(t *testing.T) {
+	msg := strings.Repeat("a", maxCommitMessage-1) + "é"
+	got := truncateMessage(msg)
+	if len(got) > maxCommitMessage || !utf8.ValidString(got) {
+		t.Errorf("truncateMessage returned %d bytes, valid=%v", len(got), utf8.ValidString(got))
+	}
+	if short := "fix build"; truncateMessage(short) != short {
+		t.Errorf("short message changed")
+	}
+}
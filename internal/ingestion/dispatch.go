@@ -0,0 +1,263 @@
+package ingestion
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ErrQueueFull is returned by WorkerPool.Dispatch when the queue is at capacity.
+var ErrQueueFull = errors.New("ingestion queue is full")
+
+// Dispatcher hands a queued ingestion off for processing. Implementations
+// must return promptly; processing happens asynchronously.
+type Dispatcher interface {
+	Dispatch(ctx context.Context, req IngestionRequest) error
+}
+
+// HTTPDispatcher posts ingestion requests to a processing endpoint, normally
+// POST /internal/process on a worker deployment of toposcoped.
+type HTTPDispatcher struct {
+	URL        string
+	Token      string // sent as a bearer token; must match the worker's PROCESS_TOKEN
+	HTTPClient *http.Client
+}
+
+// Dispatch sends req to the processing endpoint. It only waits for the
+// endpoint to accept the request, up to the client timeout.
+func (d *HTTPDispatcher) Dispatch(ctx context.Context, req IngestionRequest) error {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return fmt.Errorf("marshal ingestion request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, d.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("create dispatch request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if d.Token != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+d.Token)
+	}
+
+	client := d.HTTPClient
+	if client == nil {
+		client = &http.Client{Timeout: 30 * time.Second}
+	}
+	resp, err := client.Do(httpReq)
+	if err != nil {
+		return fmt.Errorf("dispatch to %s: %w", d.URL, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 4<<10))
+		return fmt.Errorf("dispatch to %s: status %d: %s", d.URL, resp.StatusCode, string(respBody))
+	}
+	return nil
+}
+
+// PoolConfig bounds a WorkerPool.
+type PoolConfig struct {
+	Workers         int // global concurrency (default 1)
+	TenantLimit     int // max concurrent ingestions per tenant (0 = no limit)
+	QueueSize       int // max queued ingestions across tenants (default 100)
+	TenantQueueSize int // max queued ingestions per tenant (0 = no limit)
+}
+
+// WorkerPool processes ingestions in-process with bounded concurrency.
+// Each tenant has its own FIFO queue, and idle workers take from tenants
+// round-robin, so a burst from one tenant delays only that tenant's work.
+type WorkerPool struct {
+	process func(context.Context, IngestionRequest) error
+	cfg     PoolConfig
+
+	mu       sync.Mutex
+	cond     *sync.Cond
+	tenants  map[string]*tenantQueue
+	order    []string // tenants with queued work, in round-robin order
+	next     int
+	queued   int
+	running  int
+	accepted uint64
+	rejected uint64
+	failed   uint64
+	closed   bool
+	wg       sync.WaitGroup
+}
+
+type tenantQueue struct {
+	pending []IngestionRequest
+	running int
+}
+
+// QueueStats is a point-in-time view of a WorkerPool.
+type QueueStats struct {
+	Queued   int
+	Running  int
+	Accepted uint64 // requests accepted by Dispatch since start
+	Rejected uint64 // requests refused with ErrQueueFull since start
+	Failed   uint64 // ingestions whose processing returned an error
+	Tenants  map[string]TenantQueueStats
+}
+
+// TenantQueueStats is the queue state of one tenant.
+type TenantQueueStats struct {
+	Queued  int
+	Running int
+}
+
+// NewWorkerPool starts cfg.Workers goroutines that call process for each
+// dispatched request. Typically process is (*Service).ProcessPR.
+func NewWorkerPool(process func(context.Context, IngestionRequest) error, cfg PoolConfig) *WorkerPool {
+	if cfg.Workers < 1 {
+		cfg.Workers = 1
+	}
+	if cfg.QueueSize < 1 {
+		cfg.QueueSize = 100
+	}
+	p := &WorkerPool{
+		process: process,
+		cfg:     cfg,
+		tenants: make(map[string]*tenantQueue),
+	}
+	p.cond = sync.NewCond(&p.mu)
+	for i := 0; i < cfg.Workers; i++ {
+		p.wg.Add(1)
+		go p.run()
+	}
+	return p
+}
+
+// Dispatch enqueues req without blocking. The request context is not used
+// for processing, since it ends when the webhook response is written.
+func (p *WorkerPool) Dispatch(_ context.Context, req IngestionRequest) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.closed {
+		return errors.New("worker pool is closed")
+	}
+	tq := p.tenants[req.TenantID]
+	if p.queued >= p.cfg.QueueSize ||
+		(tq != nil && p.cfg.TenantQueueSize > 0 && len(tq.pending) >= p.cfg.TenantQueueSize) {
+		p.rejected++
+		return ErrQueueFull
+	}
+
+	if tq == nil {
+		tq = &tenantQueue{}
+		p.tenants[req.TenantID] = tq
+	}
+	if len(tq.pending) == 0 {
+		p.order = append(p.order, req.TenantID)
+	}
+	tq.pending = append(tq.pending, req)
+	p.queued++
+	p.accepted++
+	p.cond.Signal()
+	return nil
+}
+
+// Stats reports queue depth and in-flight work, overall and per tenant.
+func (p *WorkerPool) Stats() QueueStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	stats := QueueStats{
+		Queued:   p.queued,
+		Running:  p.running,
+		Accepted: p.accepted,
+		Rejected: p.rejected,
+		Failed:   p.failed,
+		Tenants:  make(map[string]TenantQueueStats, len(p.tenants)),
+	}
+	for id, tq := range p.tenants {
+		stats.Tenants[id] = TenantQueueStats{Queued: len(tq.pending), Running: tq.running}
+	}
+	return stats
+}
+
+// Close stops accepting work and waits for queued ingestions to finish.
+func (p *WorkerPool) Close() {
+	p.mu.Lock()
+	p.closed = true
+	p.cond.Broadcast()
+	p.mu.Unlock()
+	p.wg.Wait()
+}
+
+func (p *WorkerPool) run() {
+	defer p.wg.Done()
+	for {
+		p.mu.Lock()
+		req, ok := p.take()
+		for !ok {
+			if p.closed && p.queued == 0 {
+				p.mu.Unlock()
+				return
+			}
+			p.cond.Wait()
+			req, ok = p.take()
+		}
+		p.mu.Unlock()
+
+		err := p.process(context.Background(), req)
+		if err != nil {
+			log.Printf("ingestion for %s@%s failed: %v", req.RepoFullName, req.CommitSHA, err)
+		}
+
+		p.mu.Lock()
+		if err != nil {
+			p.failed++
+		}
+		p.running--
+		tq := p.tenants[req.TenantID]
+		tq.running--
+		if tq.running == 0 && len(tq.pending) == 0 {
+			delete(p.tenants, req.TenantID)
+		}
+		// The tenant may have been at its limit with work still queued.
+		p.cond.Broadcast()
+		p.mu.Unlock()
+	}
+}
+
+// take pops the next request from the first tenant, in round-robin order,
+// that is under its concurrency limit. p.mu must be held.
+func (p *WorkerPool) take() (IngestionRequest, bool) {
+	for n := 0; n < len(p.order); n++ {
+		i := (p.next + n) % len(p.order)
+		id := p.order[i]
+		tq := p.tenants[id]
+		if p.cfg.TenantLimit > 0 && tq.running >= p.cfg.TenantLimit {
+			continue
+		}
+
+		req := tq.pending[0]
+		tq.pending = tq.pending[1:]
+		tq.running++
+		p.queued--
+		p.running++
+		if len(tq.pending) == 0 {
+			p.order = append(p.order[:i], p.order[i+1:]...)
+			p.next = i
+		} else {
+			p.next = i + 1
+		}
+		if len(p.order) > 0 {
+			p.next %= len(p.order)
+		} else {
+			p.next = 0
+		}
+		return req, true
+	}
+	return IngestionRequest{}, false
+}
@@ -0,0 +1,201 @@
+package ingestion
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestHTTPDispatcher(t *testing.T) {
+	var got IngestionRequest
+	var auth string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			http.Error(w, "bad body", http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	pr := 7
+	d := &HTTPDispatcher{URL: srv.URL, Token: "s3cret"}
+	req := IngestionRequest{TenantID: "t1", RepoID: "r1", CommitSHA: "abc", PRNumber: &pr, InstallationID: 42}
+	if err := d.Dispatch(context.Background(), req); err != nil {
+		t.Fatalf("Dispatch: %v", err)
+	}
+	if auth != "Bearer s3cret" {
+		t.Errorf("Authorization = %q, want bearer token", auth)
+	}
+	if got.CommitSHA != "abc" || got.PRNumber == nil || *got.PRNumber != 7 || got.InstallationID != 42 {
+		t.Errorf("worker received %+v", got)
+	}
+}
+
+func TestHTTPDispatcherErrorStatus(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+	}))
+	defer srv.Close()
+
+	d := &HTTPDispatcher{URL: srv.URL}
+	if err := d.Dispatch(context.Background(), IngestionRequest{}); err == nil {
+		t.Error("expected error for non-2xx response")
+	}
+}
+
+func TestWorkerPool(t *testing.T) {
+	var mu sync.Mutex
+	seen := map[string]bool{}
+	pool := NewWorkerPool(func(_ context.Context, req IngestionRequest) error {
+		mu.Lock()
+		defer mu.Unlock()
+		seen[req.CommitSHA] = true
+		return nil
+	}, PoolConfig{Workers: 2, QueueSize: 10})
+
+	for _, sha := range []string{"a", "b", "c"} {
+		if err := pool.Dispatch(context.Background(), IngestionRequest{CommitSHA: sha}); err != nil {
+			t.Fatalf("Dispatch(%s): %v", sha, err)
+		}
+	}
+	pool.Close()
+
+	if len(seen) != 3 {
+		t.Errorf("processed %v, want a, b, and c", seen)
+	}
+}
+
+func TestWorkerPoolQueueFull(t *testing.T) {
+	block := make(chan struct{})
+	started := make(chan struct{}, 2)
+	pool := NewWorkerPool(func(context.Context, IngestionRequest) error {
+		started <- struct{}{}
+		<-block
+		return nil
+	}, PoolConfig{Workers: 1, QueueSize: 1})
+
+	_ = pool.Dispatch(context.Background(), IngestionRequest{CommitSHA: "running"})
+	<-started
+	if err := pool.Dispatch(context.Background(), IngestionRequest{CommitSHA: "queued"}); err != nil {
+		t.Fatalf("Dispatch(queued): %v", err)
+	}
+	if err := pool.Dispatch(context.Background(), IngestionRequest{CommitSHA: "dropped"}); !errors.Is(err, ErrQueueFull) {
+		t.Errorf("Dispatch(dropped) = %v, want ErrQueueFull", err)
+	}
+
+	close(block)
+	pool.Close()
+}
+
+func TestWorkerPoolFairness(t *testing.T) {
+	block := make(chan struct{})
+	started := make(chan struct{}, 1)
+	var mu sync.Mutex
+	var order []string
+	pool := NewWorkerPool(func(_ context.Context, req IngestionRequest) error {
+		if req.CommitSHA == "first" {
+			started <- struct{}{}
+			<-block
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		order = append(order, req.TenantID+"/"+req.CommitSHA)
+		return nil
+	}, PoolConfig{Workers: 1, QueueSize: 10})
+
+	// Hold the only worker while a storm from tenant a and one request from
+	// tenant b queue up behind it.
+	_ = pool.Dispatch(context.Background(), IngestionRequest{TenantID: "a", CommitSHA: "first"})
+	<-started
+	for _, sha := range []string{"1", "2", "3"} {
+		_ = pool.Dispatch(context.Background(), IngestionRequest{TenantID: "a", CommitSHA: sha})
+	}
+	_ = pool.Dispatch(context.Background(), IngestionRequest{TenantID: "b", CommitSHA: "1"})
+
+	stats := pool.Stats()
+	if stats.Queued != 4 || stats.Running != 1 || stats.Tenants["a"].Queued != 3 || stats.Tenants["b"].Queued != 1 {
+		t.Errorf("Stats() = %+v", stats)
+	}
+
+	close(block)
+	pool.Close()
+
+	want := []string{"a/first", "a/1", "b/1", "a/2", "a/3"}
+	if len(order) != len(want) {
+		t.Fatalf("processed %v, want %v", order, want)
+	}
+	for i := range want {
+		if order[i] != want[i] {
+			t.Fatalf("processed %v, want %v", order, want)
+		}
+	}
+}
+
+func TestWorkerPoolTenantLimit(t *testing.T) {
+	var mu sync.Mutex
+	running := map[string]int{}
+	maxRunning := map[string]int{}
+	pool := NewWorkerPool(func(_ context.Context, req IngestionRequest) error {
+		mu.Lock()
+		running[req.TenantID]++
+		if running[req.TenantID] > maxRunning[req.TenantID] {
+			maxRunning[req.TenantID] = running[req.TenantID]
+		}
+		mu.Unlock()
+		time.Sleep(5 * time.Millisecond)
+		mu.Lock()
+		running[req.TenantID]--
+		mu.Unlock()
+		return nil
+	}, PoolConfig{Workers: 4, TenantLimit: 1, QueueSize: 20})
+
+	for i := 0; i < 5; i++ {
+		_ = pool.Dispatch(context.Background(), IngestionRequest{TenantID: "a"})
+		_ = pool.Dispatch(context.Background(), IngestionRequest{TenantID: "b"})
+	}
+	pool.Close()
+
+	if maxRunning["a"] != 1 || maxRunning["b"] != 1 {
+		t.Errorf("max concurrent per tenant = %v, want 1 each", maxRunning)
+	}
+	if s := pool.Stats(); s.Accepted != 10 || s.Queued != 0 || s.Running != 0 || len(s.Tenants) != 0 {
+		t.Errorf("Stats() after Close = %+v", s)
+	}
+}
+
+func TestWorkerPoolTenantQueueFull(t *testing.T) {
+	block := make(chan struct{})
+	started := make(chan struct{}, 1)
+	pool := NewWorkerPool(func(_ context.Context, req IngestionRequest) error {
+		if req.CommitSHA == "running" {
+			started <- struct{}{}
+			<-block
+		}
+		return nil
+	}, PoolConfig{Workers: 1, QueueSize: 10, TenantQueueSize: 1})
+
+	_ = pool.Dispatch(context.Background(), IngestionRequest{TenantID: "a", CommitSHA: "running"})
+	<-started
+	if err := pool.Dispatch(context.Background(), IngestionRequest{TenantID: "a"}); err != nil {
+		t.Fatalf("Dispatch(a): %v", err)
+	}
+	if err := pool.Dispatch(context.Background(), IngestionRequest{TenantID: "a"}); !errors.Is(err, ErrQueueFull) {
+		t.Errorf("second queued Dispatch(a) = %v, want ErrQueueFull", err)
+	}
+	if err := pool.Dispatch(context.Background(), IngestionRequest{TenantID: "b"}); err != nil {
+		t.Errorf("Dispatch(b) = %v, want accepted", err)
+	}
+	if s := pool.Stats(); s.Rejected != 1 {
+		t.Errorf("Rejected = %d, want 1", s.Rejected)
+	}
+
+	close(block)
+	pool.Close()
+}
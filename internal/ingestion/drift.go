@@ -0,0 +1,209 @@
+package ingestion
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/toposcope/toposcope/pkg/extract"
+	"github.com/toposcope/toposcope/pkg/graph"
+)
+
+// DefaultDriftThreshold is the drift above which a baseline is flagged when
+// DriftOptions doesn't set one.
+const DefaultDriftThreshold = 0.05
+
+// DriftOptions controls CheckDrift.
+type DriftOptions struct {
+	// Threshold is the drift ratio above which a baseline is flagged.
+	// 0 uses DefaultDriftThreshold.
+	Threshold float64
+	// AutoRefresh replaces a drifted baseline with the fresh extraction,
+	// unless the baseline is pinned.
+	AutoRefresh bool
+}
+
+// DriftReport is the outcome of checking one repository's baseline.
+type DriftReport struct {
+	RepoID         string    `json:"repo_id"`
+	FullName       string    `json:"full_name"`
+	BaselineCommit string    `json:"baseline_commit_sha"`
+	HeadCommit     string    `json:"head_commit_sha"`
+	AddedNodes     int       `json:"added_nodes"`
+	RemovedNodes   int       `json:"removed_nodes"`
+	AddedEdges     int       `json:"added_edges"`
+	RemovedEdges   int       `json:"removed_edges"`
+	Drift          float64   `json:"drift"`
+	Drifted        bool      `json:"drifted"`
+	Refreshed      bool      `json:"refreshed"`
+	CheckedAt      time.Time `json:"checked_at"`
+}
+
+// DriftRatio measures how far head has moved from base: the nodes and edges
+// added or removed by delta, relative to the size of base. An empty base
+// counts any change as full drift.
+func DriftRatio(base *graph.Snapshot, delta *graph.Delta) float64 {
+	changed := delta.Stats.AddedNodeCount + delta.Stats.RemovedNodeCount +
+		delta.Stats.AddedEdgeCount + delta.Stats.RemovedEdgeCount
+	size := len(base.Nodes) + len(base.Edges)
+	if size == 0 {
+		if changed > 0 {
+			return 1
+		}
+		return 0
+	}
+	return float64(changed) / float64(size)
+}
+
+type driftTarget struct {
+	req        IngestionRequest
+	snapshotID string
+	pinned     bool
+}
+
+// CheckDrift compares the baseline of every installed repository with a
+// fresh extraction of its default branch and records the result. Baselines
+// only move on ingests of the default branch, so skipped or failed push
+// deliveries leave them behind; a baseline whose drift exceeds
+// opts.Threshold is flagged and, with opts.AutoRefresh, replaced. A
+// repository that can't be checked is logged and skipped. Checks are the
+// service's own work, so they don't count toward tenant ingestion quotas.
+func (s *Service) CheckDrift(ctx context.Context, opts DriftOptions) ([]DriftReport, error) {
+	if s.extractor == nil {
+		return nil, fmt.Errorf("no extractor configured; hosted extraction is unavailable")
+	}
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT r.id, r.tenant_id, r.full_name, r.default_branch, t.github_installation_id, b.snapshot_id, b.pinned
+		 FROM repositories r
+		 JOIN tenants t ON t.id = r.tenant_id
+		 JOIN baselines b ON b.repo_id = r.id
+		 WHERE r.deleted_at IS NULL AND t.deleted_at IS NULL AND t.github_installation_id IS NOT NULL
+		 ORDER BY r.full_name`,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("list baselines: %w", err)
+	}
+	var targets []driftTarget
+	for rows.Next() {
+		var t driftTarget
+		if err := rows.Scan(&t.req.RepoID, &t.req.TenantID, &t.req.RepoFullName, &t.req.BaseBranch,
+			&t.req.InstallationID, &t.snapshotID, &t.pinned); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("scan baseline: %w", err)
+		}
+		targets = append(targets, t)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("list baselines: %w", err)
+	}
+
+	reports := []DriftReport{}
+	for _, t := range targets {
+		rep, err := s.checkRepoDrift(ctx, t, opts)
+		if err != nil {
+			log.Printf("drift check %s: %v", t.req.RepoFullName, err)
+			continue
+		}
+		reports = append(reports, *rep)
+	}
+	return reports, nil
+}
+
+func (s *Service) checkRepoDrift(ctx context.Context, t driftTarget, opts DriftOptions) (*DriftReport, error) {
+	threshold := opts.Threshold
+	if threshold <= 0 {
+		threshold = DefaultDriftThreshold
+	}
+
+	base, err := s.loadSnapshot(ctx, t.req.TenantID, t.snapshotID)
+	if err != nil {
+		return nil, fmt.Errorf("load baseline snapshot: %w", err)
+	}
+
+	start := time.Now()
+	head, err := s.extractor.Extract(ctx, extract.ExtractionRequest{
+		Scope: extract.ExtractionScope{
+			Mode: extract.ScopeModeFull,
+		},
+		Repo:           t.req.RepoFullName,
+		Ref:            t.req.BaseBranch,
+		InstallationID: t.req.InstallationID,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("extract %s: %w", t.req.BaseBranch, err)
+	}
+	head.Stats.ExtractionMs = int(time.Since(start).Milliseconds())
+	head.Branch = t.req.BaseBranch
+
+	rep := &DriftReport{
+		RepoID:         t.req.RepoID,
+		FullName:       t.req.RepoFullName,
+		BaselineCommit: base.CommitSHA,
+		HeadCommit:     head.CommitSHA,
+	}
+	if head.CommitSHA != base.CommitSHA {
+		delta := graph.ComputeDelta(base, head)
+		rep.AddedNodes = delta.Stats.AddedNodeCount
+		rep.RemovedNodes = delta.Stats.RemovedNodeCount
+		rep.AddedEdges = delta.Stats.AddedEdgeCount
+		rep.RemovedEdges = delta.Stats.RemovedEdgeCount
+		rep.Drift = DriftRatio(base, delta)
+		rep.Drifted = rep.Drift > threshold
+	}
+
+	if rep.Drifted && opts.AutoRefresh && !t.pinned {
+		if err := s.refreshBaseline(ctx, t.req, head); err != nil {
+			log.Printf("drift check %s: refresh baseline: %v", t.req.RepoFullName, err)
+		} else {
+			rep.Refreshed = true
+		}
+	}
+
+	err = s.db.QueryRowContext(ctx,
+		`INSERT INTO baseline_drift (repo_id, baseline_commit_sha, head_commit_sha, added_nodes, removed_nodes,
+		                             added_edges, removed_edges, drift, drifted, refreshed)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		 ON CONFLICT (repo_id) DO UPDATE SET
+		   baseline_commit_sha = EXCLUDED.baseline_commit_sha, head_commit_sha = EXCLUDED.head_commit_sha,
+		   added_nodes = EXCLUDED.added_nodes, removed_nodes = EXCLUDED.removed_nodes,
+		   added_edges = EXCLUDED.added_edges, removed_edges = EXCLUDED.removed_edges,
+		   drift = EXCLUDED.drift, drifted = EXCLUDED.drifted, refreshed = EXCLUDED.refreshed,
+		   checked_at = now()
+		 RETURNING checked_at`,
+		rep.RepoID, rep.BaselineCommit, rep.HeadCommit, rep.AddedNodes, rep.RemovedNodes,
+		rep.AddedEdges, rep.RemovedEdges, rep.Drift, rep.Drifted, rep.Refreshed,
+	).Scan(&rep.CheckedAt)
+	if err != nil {
+		return nil, fmt.Errorf("record drift: %w", err)
+	}
+	return rep, nil
+}
+
+// refreshBaseline stores snap and makes it the repository's baseline. A
+// baseline pinned in the meantime is left alone.
+func (s *Service) refreshBaseline(ctx context.Context, req IngestionRequest, snap *graph.Snapshot) error {
+	data, err := json.Marshal(snap)
+	if err != nil {
+		return fmt.Errorf("marshal snapshot: %w", err)
+	}
+	req.CommitSHA = snap.CommitSHA
+	id, err := s.StoreSnapshot(ctx, req, snap, data)
+	if err != nil {
+		return fmt.Errorf("store snapshot: %w", err)
+	}
+	s.recordCommit(ctx, req, id, snap.CommitSHA)
+
+	_, err = s.db.ExecContext(ctx,
+		`INSERT INTO baselines (repo_id, snapshot_id) VALUES ($1, $2)
+		 ON CONFLICT (repo_id) DO UPDATE SET snapshot_id = $2, updated_at = now()
+		 WHERE NOT baselines.pinned`,
+		req.RepoID, id,
+	)
+	if err != nil {
+		return fmt.Errorf("set baseline: %w", err)
+	}
+	return nil
+}
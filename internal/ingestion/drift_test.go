@@ -0,0 +1,43 @@
+package ingestion
+
+import (
+	"math"
+	"testing"
+
+	"github.com/toposcope/toposcope/pkg/graph"
+)
+
+func TestDriftRatio(t *testing.T) {
+	base := &graph.Snapshot{
+		Nodes: map[string]*graph.Node{
+			"//a:a": {Key: "//a:a"},
+			"//b:b": {Key: "//b:b"},
+			"//c:c": {Key: "//c:c"},
+		},
+		Edges: []graph.Edge{{From: "//a:a", To: "//b:b"}},
+	}
+	head := &graph.Snapshot{
+		Nodes: map[string]*graph.Node{
+			"//a:a": {Key: "//a:a"},
+			"//b:b": {Key: "//b:b"},
+			"//d:d": {Key: "//d:d"},
+		},
+		Edges: []graph.Edge{{From: "//a:a", To: "//b:b"}, {From: "//a:a", To: "//d:d"}},
+	}
+
+	// One node added, one removed, one edge added, against 4 nodes and edges.
+	if got := DriftRatio(base, graph.ComputeDelta(base, head)); math.Abs(got-0.75) > 1e-9 {
+		t.Errorf("DriftRatio = %v, want 0.75", got)
+	}
+	if got := DriftRatio(base, graph.ComputeDelta(base, base)); got != 0 {
+		t.Errorf("DriftRatio of unchanged graph = %v, want 0", got)
+	}
+
+	empty := &graph.Snapshot{Nodes: map[string]*graph.Node{}}
+	if got := DriftRatio(empty, graph.ComputeDelta(empty, head)); got != 1 {
+		t.Errorf("DriftRatio from empty baseline = %v, want 1", got)
+	}
+	if got := DriftRatio(empty, graph.ComputeDelta(empty, empty)); got != 0 {
+		t.Errorf("DriftRatio of empty graphs = %v, want 0", got)
+	}
+}
@@ -0,0 +1,225 @@
+package ingestion
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+)
+
+// encryptedMagic prefixes encrypted blobs. Blobs without it are read as
+// plaintext, so encryption can be turned on for an existing bucket.
+var encryptedMagic = []byte("TSE1")
+
+// KeyWrapper encrypts and decrypts per-blob data keys.
+type KeyWrapper interface {
+	WrapKey(ctx context.Context, dataKey []byte) ([]byte, error)
+	UnwrapKey(ctx context.Context, wrapped []byte) ([]byte, error)
+}
+
+// EncryptedStorage wraps a StorageClient with envelope encryption. Each blob
+// is sealed with AES-256-GCM under a fresh data key, and the data key is
+// stored alongside it, wrapped by Keys. The blob's tenant, kind, and ID are
+// bound as additional data, so a blob cannot be moved to another key.
+type EncryptedStorage struct {
+	Inner StorageClient
+	Keys  KeyWrapper
+}
+
+// NewEncryptedStorage returns inner with envelope encryption applied.
+func NewEncryptedStorage(inner StorageClient, keys KeyWrapper) *EncryptedStorage {
+	return &EncryptedStorage{Inner: inner, Keys: keys}
+}
+
+// PutSnapshot encrypts and stores a snapshot blob.
+func (s *EncryptedStorage) PutSnapshot(ctx context.Context, tenantID, snapshotID string, data []byte) error {
+	sealed, err := s.seal(ctx, blobAAD(tenantID, "snapshots", snapshotID), data)
+	if err != nil {
+		return err
+	}
+	return s.Inner.PutSnapshot(ctx, tenantID, snapshotID, sealed)
+}
+
+// GetSnapshot retrieves and decrypts a snapshot blob.
+func (s *EncryptedStorage) GetSnapshot(ctx context.Context, tenantID, snapshotID string) ([]byte, error) {
+	data, err := s.Inner.GetSnapshot(ctx, tenantID, snapshotID)
+	if err != nil {
+		return nil, err
+	}
+	return s.open(ctx, blobAAD(tenantID, "snapshots", snapshotID), data)
+}
+
+// PutDelta encrypts and stores a delta blob.
+func (s *EncryptedStorage) PutDelta(ctx context.Context, tenantID, deltaID string, data []byte) error {
+	sealed, err := s.seal(ctx, blobAAD(tenantID, "deltas", deltaID), data)
+	if err != nil {
+		return err
+	}
+	return s.Inner.PutDelta(ctx, tenantID, deltaID, sealed)
+}
+
+// GetDelta retrieves and decrypts a delta blob.
+func (s *EncryptedStorage) GetDelta(ctx context.Context, tenantID, deltaID string) ([]byte, error) {
+	data, err := s.Inner.GetDelta(ctx, tenantID, deltaID)
+	if err != nil {
+		return nil, err
+	}
+	return s.open(ctx, blobAAD(tenantID, "deltas", deltaID), data)
+}
+
+// DeleteSnapshot removes a snapshot blob.
+func (s *EncryptedStorage) DeleteSnapshot(ctx context.Context, tenantID, snapshotID string) error {
+	return s.Inner.DeleteSnapshot(ctx, tenantID, snapshotID)
+}
+
+// DeleteDelta removes a delta blob.
+func (s *EncryptedStorage) DeleteDelta(ctx context.Context, tenantID, deltaID string) error {
+	return s.Inner.DeleteDelta(ctx, tenantID, deltaID)
+}
+
+// Ping checks the inner backend and that Keys can wrap and unwrap a data key.
+func (s *EncryptedStorage) Ping(ctx context.Context) error {
+	if err := s.Inner.Ping(ctx); err != nil {
+		return err
+	}
+	probe := make([]byte, 32)
+	if _, err := rand.Read(probe); err != nil {
+		return fmt.Errorf("generate data key: %w", err)
+	}
+	wrapped, err := s.Keys.WrapKey(ctx, probe)
+	if err != nil {
+		return fmt.Errorf("encryption key cannot wrap data keys: %w", err)
+	}
+	unwrapped, err := s.Keys.UnwrapKey(ctx, wrapped)
+	if err != nil {
+		return fmt.Errorf("encryption key cannot unwrap data keys: %w", err)
+	}
+	if !bytes.Equal(unwrapped, probe) {
+		return fmt.Errorf("encryption key returned a different data key than it wrapped")
+	}
+	return nil
+}
+
+func blobAAD(tenantID, kind, id string) []byte {
+	return []byte(tenantID + "/" + kind + "/" + id)
+}
+
+// seal returns magic | wrapped key length (uint16) | wrapped key | nonce | ciphertext.
+func (s *EncryptedStorage) seal(ctx context.Context, aad, plaintext []byte) ([]byte, error) {
+	dataKey := make([]byte, 32)
+	if _, err := rand.Read(dataKey); err != nil {
+		return nil, fmt.Errorf("generate data key: %w", err)
+	}
+	wrapped, err := s.Keys.WrapKey(ctx, dataKey)
+	if err != nil {
+		return nil, fmt.Errorf("wrap data key: %w", err)
+	}
+	if len(wrapped) > 0xffff {
+		return nil, fmt.Errorf("wrapped data key too large (%d bytes)", len(wrapped))
+	}
+
+	gcm, err := newGCM(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("generate nonce: %w", err)
+	}
+
+	out := make([]byte, 0, len(encryptedMagic)+2+len(wrapped)+len(nonce)+len(plaintext)+gcm.Overhead())
+	out = append(out, encryptedMagic...)
+	out = binary.BigEndian.AppendUint16(out, uint16(len(wrapped)))
+	out = append(out, wrapped...)
+	out = append(out, nonce...)
+	return gcm.Seal(out, nonce, plaintext, aad), nil
+}
+
+func (s *EncryptedStorage) open(ctx context.Context, aad, blob []byte) ([]byte, error) {
+	if !bytes.HasPrefix(blob, encryptedMagic) {
+		return blob, nil
+	}
+	rest := blob[len(encryptedMagic):]
+	if len(rest) < 2 {
+		return nil, fmt.Errorf("decrypt blob: truncated header")
+	}
+	n := int(binary.BigEndian.Uint16(rest))
+	rest = rest[2:]
+	if len(rest) < n {
+		return nil, fmt.Errorf("decrypt blob: truncated data key")
+	}
+	dataKey, err := s.Keys.UnwrapKey(ctx, rest[:n])
+	if err != nil {
+		return nil, fmt.Errorf("unwrap data key: %w", err)
+	}
+	rest = rest[n:]
+
+	gcm, err := newGCM(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	if len(rest) < gcm.NonceSize() {
+		return nil, fmt.Errorf("decrypt blob: truncated nonce")
+	}
+	plaintext, err := gcm.Open(nil, rest[:gcm.NonceSize()], rest[gcm.NonceSize():], aad)
+	if err != nil {
+		return nil, fmt.Errorf("decrypt blob: %w", err)
+	}
+	return plaintext, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("create cipher: %w", err)
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("create gcm: %w", err)
+	}
+	return gcm, nil
+}
+
+// StaticKeyWrapper wraps data keys with AES-256-GCM under a fixed key
+// encryption key, typically supplied through the environment.
+type StaticKeyWrapper struct {
+	gcm cipher.AEAD
+}
+
+// NewStaticKeyWrapper parses a base64-encoded 32-byte key.
+func NewStaticKeyWrapper(encoded string) (*StaticKeyWrapper, error) {
+	key, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("decode encryption key: %w", err)
+	}
+	if len(key) != 32 {
+		return nil, fmt.Errorf("encryption key must be 32 bytes, got %d", len(key))
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	return &StaticKeyWrapper{gcm: gcm}, nil
+}
+
+// WrapKey encrypts dataKey as nonce | ciphertext.
+func (w *StaticKeyWrapper) WrapKey(_ context.Context, dataKey []byte) ([]byte, error) {
+	nonce := make([]byte, w.gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("generate nonce: %w", err)
+	}
+	return w.gcm.Seal(nonce, nonce, dataKey, nil), nil
+}
+
+// UnwrapKey decrypts a key produced by WrapKey.
+func (w *StaticKeyWrapper) UnwrapKey(_ context.Context, wrapped []byte) ([]byte, error) {
+	if len(wrapped) < w.gcm.NonceSize() {
+		return nil, fmt.Errorf("wrapped key too short")
+	}
+	ns := w.gcm.NonceSize()
+	return w.gcm.Open(nil, wrapped[:ns], wrapped[ns:], nil)
+}
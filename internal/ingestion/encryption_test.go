@@ -0,0 +1,127 @@
+package ingestion
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func testKeyWrapper(t *testing.T) *StaticKeyWrapper {
+	t.Helper()
+	w, err := NewStaticKeyWrapper(base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{7}, 32)))
+	if err != nil {
+		t.Fatalf("NewStaticKeyWrapper: %v", err)
+	}
+	return w
+}
+
+func TestEncryptedStorageRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	s := NewEncryptedStorage(NewLocalStorage(dir), testKeyWrapper(t))
+	ctx := context.Background()
+
+	data := []byte(`{"nodes":{"//app:main":{}}}`)
+	if err := s.PutSnapshot(ctx, "tenant1", "snap1", data); err != nil {
+		t.Fatalf("PutSnapshot: %v", err)
+	}
+	got, err := s.GetSnapshot(ctx, "tenant1", "snap1")
+	if err != nil {
+		t.Fatalf("GetSnapshot: %v", err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Errorf("GetSnapshot = %q, want %q", got, data)
+	}
+
+	raw, err := os.ReadFile(filepath.Join(dir, "tenant1", "snapshots", "snap1.json"))
+	if err != nil {
+		t.Fatalf("read blob: %v", err)
+	}
+	if bytes.Contains(raw, []byte("//app:main")) {
+		t.Error("blob on disk contains plaintext")
+	}
+
+	if err := s.PutDelta(ctx, "tenant1", "d1", data); err != nil {
+		t.Fatalf("PutDelta: %v", err)
+	}
+	if got, err := s.GetDelta(ctx, "tenant1", "d1"); err != nil || !bytes.Equal(got, data) {
+		t.Errorf("GetDelta = %q, %v", got, err)
+	}
+}
+
+func TestEncryptedStorageReadsPlaintext(t *testing.T) {
+	inner := NewLocalStorage(t.TempDir())
+	ctx := context.Background()
+	data := []byte(`{"nodes":{}}`)
+	if err := inner.PutSnapshot(ctx, "tenant1", "old", data); err != nil {
+		t.Fatal(err)
+	}
+
+	s := NewEncryptedStorage(inner, testKeyWrapper(t))
+	got, err := s.GetSnapshot(ctx, "tenant1", "old")
+	if err != nil || !bytes.Equal(got, data) {
+		t.Errorf("GetSnapshot(unencrypted) = %q, %v", got, err)
+	}
+}
+
+func TestEncryptedStorageBindsLocation(t *testing.T) {
+	inner := NewLocalStorage(t.TempDir())
+	s := NewEncryptedStorage(inner, testKeyWrapper(t))
+	ctx := context.Background()
+
+	if err := s.PutSnapshot(ctx, "tenant1", "snap1", []byte(`{}`)); err != nil {
+		t.Fatal(err)
+	}
+	// Copy the sealed blob to another tenant's path.
+	sealed, _ := inner.GetSnapshot(ctx, "tenant1", "snap1")
+	if err := inner.PutSnapshot(ctx, "tenant2", "snap1", sealed); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.GetSnapshot(ctx, "tenant2", "snap1"); err == nil {
+		t.Error("expected decryption to fail for a blob moved to another tenant")
+	}
+}
+
+func TestEncryptedStorageWrongKey(t *testing.T) {
+	inner := NewLocalStorage(t.TempDir())
+	ctx := context.Background()
+	if err := NewEncryptedStorage(inner, testKeyWrapper(t)).PutSnapshot(ctx, "t", "s", []byte(`{}`)); err != nil {
+		t.Fatal(err)
+	}
+
+	other, err := NewStaticKeyWrapper(base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{9}, 32)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewEncryptedStorage(inner, other).GetSnapshot(ctx, "t", "s"); err == nil {
+		t.Error("expected error decrypting with a different key")
+	}
+}
+
+func TestNewStaticKeyWrapperInvalid(t *testing.T) {
+	for _, key := range []string{"not base64!", base64.StdEncoding.EncodeToString([]byte("short"))} {
+		if _, err := NewStaticKeyWrapper(key); err == nil {
+			t.Errorf("NewStaticKeyWrapper(%q): expected error", key)
+		}
+	}
+}
+
+type failingKeyWrapper struct{ *StaticKeyWrapper }
+
+func (failingKeyWrapper) WrapKey(context.Context, []byte) ([]byte, error) {
+	return nil, errors.New("access denied")
+}
+
+func TestEncryptedStoragePing(t *testing.T) {
+	ctx := context.Background()
+	inner := NewLocalStorage(t.TempDir())
+	if err := NewEncryptedStorage(inner, testKeyWrapper(t)).Ping(ctx); err != nil {
+		t.Errorf("Ping: %v", err)
+	}
+	if err := NewEncryptedStorage(inner, failingKeyWrapper{}).Ping(ctx); err == nil {
+		t.Error("Ping with a failing key wrapper: expected error")
+	}
+}
@@ -0,0 +1,303 @@
+package ingestion
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/toposcope/toposcope/pkg/extract"
+	"github.com/toposcope/toposcope/pkg/extract/bazeldiff"
+	"github.com/toposcope/toposcope/pkg/extract/subgraph"
+	"github.com/toposcope/toposcope/pkg/graph"
+)
+
+// TokenSource mints short-lived GitHub App installation tokens.
+type TokenSource interface {
+	InstallationToken(ctx context.Context, installationID int64) (string, error)
+}
+
+// CloneExecutor implements extract.Extractor for hosted ingestion. Each call
+// clones the repository at the requested commit into a fresh directory
+// under WorkDir, extracts the graph, and removes the checkout.
+type CloneExecutor struct {
+	Tokens    TokenSource
+	WorkDir   string // parent of per-extraction checkouts (default: os.TempDir())
+	GitURL    string // clone base URL (default: https://github.com)
+	BazelPath string
+	Timeout   time.Duration // per-extraction timeout covering clone and query
+
+	// SparsePaths, if set, limits the checkout to these directories (cone
+	// mode). Every package the query reaches must be included.
+	SparsePaths []string
+
+	// BazelDiffJar is the bazel-diff jar ImpactedTargets runs. Empty runs
+	// bazel-diff through the repository's @bazel_diff target.
+	BazelDiffJar string
+
+	// Limits bound the memory and running time of each bazel query.
+	Limits subgraph.ResourceLimits
+
+	// OutputBaseDir, if set, keeps one bazel output base per repository
+	// under it instead of a throwaway one per checkout, so fetched external
+	// repositories and the action cache carry over between extractions.
+	// Extractions of one repository then run one at a time.
+	OutputBaseDir string
+
+	// ShardParallelism, if positive, runs full extractions as one query per
+	// top-level package tree, this many at a time.
+	ShardParallelism int
+
+	// Profile captures a bazel profile of each query, so snapshots carry
+	// bazel's phase timings in their stats. The profiles are discarded.
+	Profile bool
+
+	repoLocks sync.Map // repository -> *sync.Mutex, with OutputBaseDir
+}
+
+// Extract clones req.Repo at req.CommitSHA (or the tip of req.Ref) and runs
+// the subgraph extractor in the checkout.
+func (x *CloneExecutor) Extract(ctx context.Context, req extract.ExtractionRequest) (*graph.Snapshot, error) {
+	if req.Repo == "" {
+		return nil, fmt.Errorf("clone executor: request has no repository")
+	}
+	if x.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, x.Timeout)
+		defer cancel()
+	}
+
+	dir, err := os.MkdirTemp(x.WorkDir, "toposcope-extract-")
+	if err != nil {
+		return nil, fmt.Errorf("create workdir: %w", err)
+	}
+	defer func() {
+		if err := os.RemoveAll(dir); err != nil {
+			log.Printf("clean up %s: %v", dir, err)
+		}
+	}()
+
+	defer x.lockRepo(req.Repo)()
+	commitSHA, err := x.checkout(ctx, dir, req)
+	if err != nil {
+		return nil, err
+	}
+
+	ext := &subgraph.Extractor{WorkspacePath: dir, BazelPath: x.BazelPath, Limits: x.Limits, OutputBase: x.outputBase(req.Repo), ShardParallelism: x.ShardParallelism}
+	defer x.release(ext)
+	if x.Profile {
+		profiles, err := os.MkdirTemp(x.WorkDir, "toposcope-profile-")
+		if err != nil {
+			return nil, fmt.Errorf("create profile dir: %w", err)
+		}
+		defer os.RemoveAll(profiles)
+		ext.ProfileDir = profiles
+	}
+
+	if req.Scope.Mode == extract.ScopeModeScoped && len(req.Scope.Roots) > 0 {
+		return ext.Extract(ctx, subgraph.SubgraphRequest{
+			Targets:   req.Scope.Roots,
+			RdepDepth: req.Scope.RdepsDepth,
+			CommitSHA: commitSHA,
+			Timeout:   req.Scope.Timeout,
+		})
+	}
+	return ext.ExtractFull(ctx, commitSHA, req.Scope.Timeout)
+}
+
+// ImpactedTargets implements ImpactDetector. It checks out baseSHA, then
+// req.CommitSHA, in one fresh directory, and runs bazel-diff on the two.
+func (x *CloneExecutor) ImpactedTargets(ctx context.Context, req extract.ExtractionRequest, baseSHA string) ([]string, error) {
+	if req.Repo == "" || req.CommitSHA == "" || baseSHA == "" {
+		return nil, fmt.Errorf("clone executor: impact detection needs a repository and both commits")
+	}
+	if x.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, x.Timeout)
+		defer cancel()
+	}
+
+	dir, err := os.MkdirTemp(x.WorkDir, "toposcope-impact-")
+	if err != nil {
+		return nil, fmt.Errorf("create workdir: %w", err)
+	}
+	defer func() {
+		if err := os.RemoveAll(dir); err != nil {
+			log.Printf("clean up %s: %v", dir, err)
+		}
+	}()
+	workspace := filepath.Join(dir, "src")
+	if err := os.Mkdir(workspace, 0o755); err != nil {
+		return nil, fmt.Errorf("create workdir: %w", err)
+	}
+
+	defer x.lockRepo(req.Repo)()
+	base := req
+	base.CommitSHA = baseSHA
+	if _, err := x.checkout(ctx, workspace, base); err != nil {
+		return nil, err
+	}
+	outputBase := x.outputBase(req.Repo)
+	defer x.release(&subgraph.Extractor{WorkspacePath: workspace, BazelPath: x.BazelPath, OutputBase: outputBase})
+
+	runner := &bazeldiff.Runner{
+		BazelDiffJarPath: x.BazelDiffJar,
+		WorkspacePath:    workspace,
+		BazelPath:        x.BazelPath,
+		CacheDir:         filepath.Join(dir, "hashes"),
+		OutputBase:       outputBase,
+	}
+	baseHashes, err := runner.GenerateHashes(ctx, baseSHA)
+	if err != nil {
+		return nil, fmt.Errorf("hash base: %w", err)
+	}
+	if _, err := x.fetch(ctx, workspace, req); err != nil {
+		return nil, err
+	}
+	headHashes, err := runner.GenerateHashes(ctx, req.CommitSHA)
+	if err != nil {
+		return nil, fmt.Errorf("hash head: %w", err)
+	}
+	return runner.GetImpactedTargets(ctx, baseHashes, headHashes)
+}
+
+// checkout performs a shallow, optionally sparse, fetch of a single commit
+// into a new repository in dir and returns the checked-out SHA.
+func (x *CloneExecutor) checkout(ctx context.Context, dir string, req extract.ExtractionRequest) (string, error) {
+	baseURL := strings.TrimRight(x.GitURL, "/")
+	if baseURL == "" {
+		baseURL = "https://github.com"
+	}
+	steps := [][]string{
+		{"init", "--quiet"},
+		{"remote", "add", "origin", baseURL + "/" + req.Repo + ".git"},
+	}
+	if len(x.SparsePaths) > 0 {
+		steps = append(steps, append([]string{"sparse-checkout", "set", "--cone"}, x.SparsePaths...))
+	}
+	for _, args := range steps {
+		if _, err := runGit(ctx, dir, args...); err != nil {
+			return "", fmt.Errorf("clone %s: %w", req.Repo, err)
+		}
+	}
+	return x.fetch(ctx, dir, req)
+}
+
+// fetch makes a shallow fetch of the requested commit into the repository
+// in dir, checks it out, and returns its SHA. The token is passed as a
+// per-command header through the environment, so it is neither written to
+// the checkout's git config nor visible in the process arguments.
+func (x *CloneExecutor) fetch(ctx context.Context, dir string, req extract.ExtractionRequest) (string, error) {
+	var authEnv []string
+	if x.Tokens != nil && req.InstallationID != 0 {
+		token, err := x.Tokens.InstallationToken(ctx, req.InstallationID)
+		if err != nil {
+			return "", fmt.Errorf("get installation token: %w", err)
+		}
+		basic := base64.StdEncoding.EncodeToString([]byte("x-access-token:" + token))
+		authEnv = []string{
+			"GIT_CONFIG_COUNT=1",
+			"GIT_CONFIG_KEY_0=http.extraHeader",
+			"GIT_CONFIG_VALUE_0=Authorization: Basic " + basic,
+		}
+	}
+
+	ref := req.CommitSHA
+	if ref == "" {
+		ref = firstNonEmpty(req.Ref, "HEAD")
+	}
+
+	fetch := []string{"fetch", "--quiet", "--depth=1", "--no-tags"}
+	if len(x.SparsePaths) > 0 {
+		fetch = append(fetch, "--filter=blob:none")
+	}
+	steps := [][]string{
+		append(fetch, "origin", ref),
+		{"checkout", "--quiet", "--detach", "FETCH_HEAD"},
+	}
+	for _, args := range steps {
+		if _, err := runGitEnv(ctx, dir, authEnv, args...); err != nil {
+			return "", fmt.Errorf("clone %s@%s: %w", req.Repo, ref, err)
+		}
+	}
+
+	sha, err := runGit(ctx, dir, "rev-parse", "HEAD")
+	if err != nil {
+		return "", fmt.Errorf("resolve checkout: %w", err)
+	}
+	return sha, nil
+}
+
+// outputBase returns the shared output base of repo, or "" for a throwaway
+// one per checkout.
+func (x *CloneExecutor) outputBase(repo string) string {
+	if x.OutputBaseDir == "" {
+		return ""
+	}
+	return filepath.Join(x.OutputBaseDir, strings.ReplaceAll(repo, "/", "_"))
+}
+
+// lockRepo serializes extractions of repo when they share an output base,
+// and returns the unlock function.
+func (x *CloneExecutor) lockRepo(repo string) func() {
+	if x.OutputBaseDir == "" {
+		return func() {}
+	}
+	mu, _ := x.repoLocks.LoadOrStore(repo, &sync.Mutex{})
+	mu.(*sync.Mutex).Lock()
+	return mu.(*sync.Mutex).Unlock
+}
+
+// release stops the bazel server of a finished checkout. A throwaway output
+// base is expunged, since it would otherwise outlive the deleted workspace; a
+// shared one is kept for the next extraction.
+func (x *CloneExecutor) release(ext *subgraph.Extractor) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+	if ext.OutputBase != "" {
+		if err := ext.Shutdown(ctx); err != nil {
+			log.Printf("shut down bazel in %s: %v", ext.WorkspacePath, err)
+		}
+		return
+	}
+	bazel := firstNonEmpty(x.BazelPath, "bazelisk")
+	cmd := exec.CommandContext(ctx, bazel, "clean", "--expunge")
+	cmd.Dir = ext.WorkspacePath
+	if out, err := cmd.CombinedOutput(); err != nil {
+		log.Printf("bazel clean --expunge in %s: %v: %s", ext.WorkspacePath, err, strings.TrimSpace(string(out)))
+	}
+}
+
+func runGit(ctx context.Context, dir string, args ...string) (string, error) {
+	return runGitEnv(ctx, dir, nil, args...)
+}
+
+// runGitEnv runs git with env added to the environment.
+func runGitEnv(ctx context.Context, dir string, env []string, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+	cmd.Env = append(append(os.Environ(), "GIT_TERMINAL_PROMPT=0"), env...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("git %s: %w: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+func firstNonEmpty(vals ...string) string {
+	for _, v := range vals {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
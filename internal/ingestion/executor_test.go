@@ -0,0 +1,117 @@
+package ingestion
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/toposcope/toposcope/pkg/extract"
+)
+
+func gitCmd(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	out, err := runGit(context.Background(), dir, args...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+func TestCloneExecutorCheckout(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+	t.Setenv("GIT_AUTHOR_NAME", "test")
+	t.Setenv("GIT_AUTHOR_EMAIL", "test@example.com")
+	t.Setenv("GIT_COMMITTER_NAME", "test")
+	t.Setenv("GIT_COMMITTER_EMAIL", "test@example.com")
+
+	root := t.TempDir()
+	src := filepath.Join(root, "acme", "mono.git")
+	if err := os.MkdirAll(filepath.Join(src, "app"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	gitCmd(t, src, "init", "--quiet", "--initial-branch=main")
+	if err := os.WriteFile(filepath.Join(src, "app", "BUILD"), []byte("# v1\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	gitCmd(t, src, "add", "-A")
+	gitCmd(t, src, "commit", "--quiet", "-m", "v1")
+	first := gitCmd(t, src, "rev-parse", "HEAD")
+	if err := os.WriteFile(filepath.Join(src, "app", "BUILD"), []byte("# v2\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	gitCmd(t, src, "commit", "--quiet", "-am", "v2")
+	tip := gitCmd(t, src, "rev-parse", "HEAD")
+	gitCmd(t, src, "config", "uploadpack.allowAnySHA1InWant", "true")
+
+	x := &CloneExecutor{GitURL: "file://" + root}
+	ctx := context.Background()
+
+	dir := t.TempDir()
+	sha, err := x.checkout(ctx, dir, extract.ExtractionRequest{Repo: "acme/mono", CommitSHA: first})
+	if err != nil {
+		t.Fatalf("checkout(commit): %v", err)
+	}
+	if sha != first {
+		t.Errorf("checkout(commit) = %s, want %s", sha, first)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "app", "BUILD")); string(data) != "# v1\n" {
+		t.Errorf("checked out content = %q, want v1", data)
+	}
+
+	// A later fetch moves the same checkout to another commit.
+	sha, err = x.fetch(ctx, dir, extract.ExtractionRequest{Repo: "acme/mono", CommitSHA: tip})
+	if err != nil {
+		t.Fatalf("fetch(commit): %v", err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "app", "BUILD")); sha != tip || string(data) != "# v2\n" {
+		t.Errorf("fetch(commit) = %s with content %q, want %s at v2", sha, data, tip)
+	}
+
+	dir = t.TempDir()
+	sha, err = x.checkout(ctx, dir, extract.ExtractionRequest{Repo: "acme/mono", Ref: "main"})
+	if err != nil {
+		t.Fatalf("checkout(ref): %v", err)
+	}
+	if sha != tip {
+		t.Errorf("checkout(ref) = %s, want branch tip %s", sha, tip)
+	}
+
+	if _, err := x.Extract(ctx, extract.ExtractionRequest{}); err == nil {
+		t.Error("expected error for request without a repository")
+	}
+	if _, err := x.ImpactedTargets(ctx, extract.ExtractionRequest{Repo: "acme/mono"}, first); err == nil {
+		t.Error("expected error for impact detection without a head commit")
+	}
+}
+
+func TestCloneExecutorOutputBase(t *testing.T) {
+	x := &CloneExecutor{}
+	if ob := x.outputBase("acme/mono"); ob != "" {
+		t.Errorf("without OutputBaseDir: output base %q, want none", ob)
+	}
+	x.lockRepo("acme/mono")() // no-op
+
+	x.OutputBaseDir = "/var/cache/toposcope"
+	if ob := x.outputBase("acme/mono"); ob != filepath.Join("/var/cache/toposcope", "acme_mono") {
+		t.Errorf("output base = %q", ob)
+	}
+
+	unlock := x.lockRepo("acme/mono")
+	locked := make(chan struct{})
+	go func() {
+		x.lockRepo("acme/mono")()
+		close(locked)
+	}()
+	x.lockRepo("acme/other")() // other repositories don't wait
+	select {
+	case <-locked:
+		t.Fatal("second extraction of a repository ran while the first held its output base")
+	default:
+	}
+	unlock()
+	<-locked
+}
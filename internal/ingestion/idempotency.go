@@ -0,0 +1,104 @@
+package ingestion
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Idempotency keys let a client retry an ingest without storing it twice.
+const (
+	// IdempotencyKeyTTL is how long a completed ingest's response is
+	// replayed for its key.
+	IdempotencyKeyTTL = 24 * time.Hour
+	// idempotencyClaimTimeout is how long a key stays claimed by an ingest
+	// that never finished, e.g. because the server died, before a retry
+	// may claim it again.
+	idempotencyClaimTimeout = 15 * time.Minute
+)
+
+var (
+	// ErrIdempotencyKeyInProgress is returned when another request with the
+	// key is still being ingested.
+	ErrIdempotencyKeyInProgress = errors.New("an ingest with this idempotency key is in progress")
+	// ErrIdempotencyKeyReused is returned when the key was used for a
+	// request with a different body.
+	ErrIdempotencyKeyReused = errors.New("idempotency key was already used for a different request")
+)
+
+// ClaimIdempotencyKey reserves key for an ingest to repoID whose body hashes
+// to requestHash. It returns the stored response if an earlier request with
+// the key completed, in which case the ingest must not run again. Otherwise
+// the caller owns the key until CompleteIdempotencyKey or
+// ReleaseIdempotencyKey.
+func (s *Service) ClaimIdempotencyKey(ctx context.Context, repoID, key, requestHash string) (response []byte, err error) {
+	now := time.Now()
+	if _, err := s.db.ExecContext(ctx,
+		`DELETE FROM ingest_idempotency_keys
+		 WHERE repo_id = $1 AND key = $2
+		   AND (created_at < $3 OR (response IS NULL AND created_at < $4))`,
+		repoID, key, now.Add(-IdempotencyKeyTTL), now.Add(-idempotencyClaimTimeout),
+	); err != nil {
+		return nil, fmt.Errorf("expire idempotency key: %w", err)
+	}
+
+	res, err := s.db.ExecContext(ctx,
+		`INSERT INTO ingest_idempotency_keys (repo_id, key, request_hash) VALUES ($1, $2, $3)
+		 ON CONFLICT (repo_id, key) DO NOTHING`,
+		repoID, key, requestHash,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("claim idempotency key: %w", err)
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return nil, fmt.Errorf("claim idempotency key: %w", err)
+	} else if n == 1 {
+		return nil, nil
+	}
+
+	var storedHash string
+	err = s.db.QueryRowContext(ctx,
+		`SELECT request_hash, response FROM ingest_idempotency_keys WHERE repo_id = $1 AND key = $2`,
+		repoID, key,
+	).Scan(&storedHash, &response)
+	if errors.Is(err, sql.ErrNoRows) {
+		// Released between the insert and the select; the retry may run.
+		return s.ClaimIdempotencyKey(ctx, repoID, key, requestHash)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get idempotency key: %w", err)
+	}
+	if storedHash != requestHash {
+		return nil, ErrIdempotencyKeyReused
+	}
+	if response == nil {
+		return nil, ErrIdempotencyKeyInProgress
+	}
+	return response, nil
+}
+
+// CompleteIdempotencyKey stores the response of the ingest that claimed key,
+// to be replayed to retries.
+func (s *Service) CompleteIdempotencyKey(ctx context.Context, repoID, key string, response []byte) error {
+	if _, err := s.db.ExecContext(ctx,
+		`UPDATE ingest_idempotency_keys SET response = $3 WHERE repo_id = $1 AND key = $2`,
+		repoID, key, response,
+	); err != nil {
+		return fmt.Errorf("complete idempotency key: %w", err)
+	}
+	return nil
+}
+
+// ReleaseIdempotencyKey forgets key after its ingest failed, so a retry runs
+// the ingest again.
+func (s *Service) ReleaseIdempotencyKey(ctx context.Context, repoID, key string) error {
+	if _, err := s.db.ExecContext(ctx,
+		`DELETE FROM ingest_idempotency_keys WHERE repo_id = $1 AND key = $2 AND response IS NULL`,
+		repoID, key,
+	); err != nil {
+		return fmt.Errorf("release idempotency key: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,310 @@
+package ingestion
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/toposcope/toposcope/pkg/extract"
+	"github.com/toposcope/toposcope/pkg/graph"
+)
+
+// JobTenant is the storage tenant under which extraction jobs write their
+// snapshots for the controller to pick up. The blobs are not needed once
+// read; expire the prefix with a bucket lifecycle rule.
+const JobTenant = "_jobs"
+
+// Environment variables that carry the job's inputs to the worker.
+const (
+	EnvExtractionRequest = "EXTRACTION_REQUEST"
+	EnvJobSnapshotID     = "JOB_SNAPSHOT_ID"
+)
+
+const serviceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+
+// KubeClient is a minimal client for the Kubernetes batch/v1 Jobs API.
+type KubeClient struct {
+	BaseURL    string
+	Token      string
+	HTTPClient *http.Client
+}
+
+// NewInClusterKubeClient builds a client from the pod's service account.
+func NewInClusterKubeClient() (*KubeClient, error) {
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if host == "" || port == "" {
+		return nil, fmt.Errorf("not running in a Kubernetes cluster")
+	}
+	token, err := os.ReadFile(serviceAccountDir + "/token")
+	if err != nil {
+		return nil, fmt.Errorf("read service account token: %w", err)
+	}
+	ca, err := os.ReadFile(serviceAccountDir + "/ca.crt")
+	if err != nil {
+		return nil, fmt.Errorf("read cluster CA: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(ca) {
+		return nil, fmt.Errorf("parse cluster CA")
+	}
+
+	return &KubeClient{
+		BaseURL: "https://" + host + ":" + port,
+		Token:   strings.TrimSpace(string(token)),
+		HTTPClient: &http.Client{
+			Timeout:   30 * time.Second,
+			Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}},
+		},
+	}, nil
+}
+
+// InClusterNamespace returns the pod's namespace, or "default".
+func InClusterNamespace() string {
+	if ns, err := os.ReadFile(serviceAccountDir + "/namespace"); err == nil {
+		return strings.TrimSpace(string(ns))
+	}
+	return "default"
+}
+
+// KubernetesRunner implements extract.Extractor by running each extraction
+// as a Kubernetes Job. The job runs toposcoped in extract-job mode, which
+// clones and extracts the repository and writes the snapshot to shared
+// storage; the runner waits for the job and then reads the snapshot back.
+type KubernetesRunner struct {
+	Client         *KubeClient
+	Storage        StorageClient // must be shared with the jobs (s3 or gcs)
+	Namespace      string
+	Image          string
+	ServiceAccount string
+	CPU            string // request and limit, e.g. "4"
+	Memory         string // request and limit, e.g. "16Gi"
+	SecretName     string // optional secret exposed to the job as env vars
+	Env            map[string]string
+	PollInterval   time.Duration // default 10s
+	Timeout        time.Duration // job deadline (default 1h)
+}
+
+// Extract runs req as a Job and returns the snapshot it produced.
+func (k *KubernetesRunner) Extract(ctx context.Context, req extract.ExtractionRequest) (*graph.Snapshot, error) {
+	reqJSON, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("marshal extraction request: %w", err)
+	}
+
+	name := "toposcope-extract-" + uuid.NewString()[:8]
+	if err := k.Client.createJob(ctx, k.Namespace, k.jobSpec(name, string(reqJSON))); err != nil {
+		return nil, fmt.Errorf("create job: %w", err)
+	}
+
+	if err := k.wait(ctx, name); err != nil {
+		// Don't leave a job running for an extraction nobody is waiting on.
+		// Failed jobs are kept (until their TTL) so their logs can be read.
+		if ctx.Err() != nil {
+			_ = k.Client.deleteJob(context.Background(), k.Namespace, name)
+		}
+		return nil, err
+	}
+
+	data, err := k.Storage.GetSnapshot(ctx, JobTenant, name)
+	if err != nil {
+		return nil, fmt.Errorf("fetch snapshot from job %s: %w", name, err)
+	}
+	var snap graph.Snapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return nil, fmt.Errorf("decode snapshot from job %s: %w", name, err)
+	}
+	return &snap, nil
+}
+
+func (k *KubernetesRunner) wait(ctx context.Context, name string) error {
+	interval := k.PollInterval
+	if interval <= 0 {
+		interval = 10 * time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		status, err := k.Client.getJobStatus(ctx, k.Namespace, name)
+		if err != nil {
+			return fmt.Errorf("poll job %s: %w", name, err)
+		}
+		switch {
+		case status.Succeeded > 0:
+			return nil
+		case status.Failed > 0:
+			return fmt.Errorf("job %s failed%s", name, status.failureReason())
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("waiting for job %s: %w", name, ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
+
+func (k *KubernetesRunner) timeout() time.Duration {
+	if k.Timeout > 0 {
+		return k.Timeout
+	}
+	return time.Hour
+}
+
+func (k *KubernetesRunner) jobSpec(name, reqJSON string) map[string]any {
+	env := []map[string]string{
+		{"name": "TOPOSCOPE_MODE", "value": "extract-job"},
+		{"name": EnvExtractionRequest, "value": reqJSON},
+		{"name": EnvJobSnapshotID, "value": name},
+	}
+	keys := make([]string, 0, len(k.Env))
+	for key := range k.Env {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		env = append(env, map[string]string{"name": key, "value": k.Env[key]})
+	}
+
+	container := map[string]any{
+		"name":  "extract",
+		"image": k.Image,
+		"env":   env,
+	}
+	if k.CPU != "" || k.Memory != "" {
+		res := map[string]string{}
+		if k.CPU != "" {
+			res["cpu"] = k.CPU
+		}
+		if k.Memory != "" {
+			res["memory"] = k.Memory
+		}
+		container["resources"] = map[string]any{"requests": res, "limits": res}
+	}
+	if k.SecretName != "" {
+		container["envFrom"] = []map[string]any{{"secretRef": map[string]string{"name": k.SecretName}}}
+	}
+
+	// Extraction runs untrusted repository code, and a job needs no access
+	// to the Kubernetes API.
+	pod := map[string]any{
+		"restartPolicy":                "Never",
+		"automountServiceAccountToken": false,
+		"containers":                   []any{container},
+	}
+	if k.ServiceAccount != "" {
+		pod["serviceAccountName"] = k.ServiceAccount
+	}
+
+	labels := map[string]string{
+		"app.kubernetes.io/name":      "toposcope",
+		"app.kubernetes.io/component": "extract",
+	}
+	return map[string]any{
+		"apiVersion": "batch/v1",
+		"kind":       "Job",
+		"metadata":   map[string]any{"name": name, "labels": labels},
+		"spec": map[string]any{
+			"backoffLimit":            0,
+			"activeDeadlineSeconds":   int64(k.timeout().Seconds()),
+			"ttlSecondsAfterFinished": 600,
+			"template": map[string]any{
+				"metadata": map[string]any{"labels": labels},
+				"spec":     pod,
+			},
+		},
+	}
+}
+
+type jobStatus struct {
+	Succeeded  int `json:"succeeded"`
+	Failed     int `json:"failed"`
+	Conditions []struct {
+		Type    string `json:"type"`
+		Reason  string `json:"reason"`
+		Message string `json:"message"`
+	} `json:"conditions"`
+}
+
+func (s jobStatus) failureReason() string {
+	for _, c := range s.Conditions {
+		if c.Type == "Failed" {
+			return fmt.Sprintf(": %s: %s", c.Reason, c.Message)
+		}
+	}
+	return ""
+}
+
+func (c *KubeClient) jobsURL(namespace string) string {
+	return strings.TrimRight(c.BaseURL, "/") + "/apis/batch/v1/namespaces/" + url.PathEscape(namespace) + "/jobs"
+}
+
+func (c *KubeClient) createJob(ctx context.Context, namespace string, job map[string]any) error {
+	body, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+	_, err = c.do(ctx, http.MethodPost, c.jobsURL(namespace), body)
+	return err
+}
+
+func (c *KubeClient) getJobStatus(ctx context.Context, namespace, name string) (jobStatus, error) {
+	var job struct {
+		Status jobStatus `json:"status"`
+	}
+	data, err := c.do(ctx, http.MethodGet, c.jobsURL(namespace)+"/"+url.PathEscape(name), nil)
+	if err != nil {
+		return job.Status, err
+	}
+	err = json.Unmarshal(data, &job)
+	return job.Status, err
+}
+
+func (c *KubeClient) deleteJob(ctx context.Context, namespace, name string) error {
+	body := []byte(`{"kind":"DeleteOptions","apiVersion":"v1","propagationPolicy":"Background"}`)
+	_, err := c.do(ctx, http.MethodDelete, c.jobsURL(namespace)+"/"+url.PathEscape(name), body)
+	return err
+}
+
+func (c *KubeClient) do(ctx context.Context, method, endpoint string, body []byte) ([]byte, error) {
+	var r io.Reader
+	if body != nil {
+		r = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, r)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+
+	client := c.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("%s %s: status %d: %s", method, endpoint, resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+	return data, nil
+}
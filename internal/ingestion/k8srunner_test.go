@@ -0,0 +1,187 @@
+package ingestion
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/toposcope/toposcope/pkg/extract"
+)
+
+// fakeJobsAPI emulates the batch/v1 Jobs API. On creation it runs worker,
+// standing in for the job's container, and reports the job as finished after
+// one poll.
+type fakeJobsAPI struct {
+	mu      sync.Mutex
+	jobs    map[string]map[string]any
+	polls   map[string]int
+	deleted []string
+	worker  func(name string, job map[string]any) bool // reports success
+	results map[string]bool
+}
+
+func (f *fakeJobsAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	const prefix = "/apis/batch/v1/namespaces/ci/jobs"
+	switch {
+	case r.Method == http.MethodPost && r.URL.Path == prefix:
+		var job map[string]any
+		_ = json.NewDecoder(r.Body).Decode(&job)
+		name := job["metadata"].(map[string]any)["name"].(string)
+		f.jobs[name] = job
+		f.results[name] = f.worker(name, job)
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(job)
+	case r.Method == http.MethodGet && strings.HasPrefix(r.URL.Path, prefix+"/"):
+		name := strings.TrimPrefix(r.URL.Path, prefix+"/")
+		f.polls[name]++
+		status := map[string]any{"active": 1}
+		if f.polls[name] > 1 {
+			if f.results[name] {
+				status = map[string]any{"succeeded": 1}
+			} else {
+				status = map[string]any{"failed": 1, "conditions": []map[string]string{
+					{"type": "Failed", "reason": "BackoffLimitExceeded", "message": "Job has reached the specified backoff limit"},
+				}}
+			}
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"status": status})
+	case r.Method == http.MethodDelete:
+		f.deleted = append(f.deleted, strings.TrimPrefix(r.URL.Path, prefix+"/"))
+		w.WriteHeader(http.StatusOK)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func TestKubernetesRunner(t *testing.T) {
+	storage := NewLocalStorage(t.TempDir())
+	api := &fakeJobsAPI{
+		jobs:    map[string]map[string]any{},
+		polls:   map[string]int{},
+		results: map[string]bool{},
+	}
+	api.worker = func(name string, job map[string]any) bool {
+		env := jobEnv(job)
+		var req extract.ExtractionRequest
+		if err := json.Unmarshal([]byte(env[EnvExtractionRequest]), &req); err != nil || req.Repo != "acme/mono" {
+			return false
+		}
+		snap := `{"id":"` + env[EnvJobSnapshotID] + `","commit_sha":"` + req.CommitSHA + `","nodes":{}}`
+		return storage.PutSnapshot(context.Background(), JobTenant, env[EnvJobSnapshotID], []byte(snap)) == nil
+	}
+	srv := httptest.NewServer(api)
+	defer srv.Close()
+
+	runner := &KubernetesRunner{
+		Client:       &KubeClient{BaseURL: srv.URL, Token: "t"},
+		Storage:      storage,
+		Namespace:    "ci",
+		Image:        "toposcope/toposcoped:test",
+		CPU:          "4",
+		Memory:       "16Gi",
+		SecretName:   "toposcope-extract",
+		Env:          map[string]string{"STORAGE_BACKEND": "gcs"},
+		PollInterval: time.Millisecond,
+	}
+
+	snap, err := runner.Extract(context.Background(), extract.ExtractionRequest{Repo: "acme/mono", CommitSHA: "abc123"})
+	if err != nil {
+		t.Fatalf("Extract: %v", err)
+	}
+	if snap.CommitSHA != "abc123" {
+		t.Errorf("snapshot commit = %q, want abc123", snap.CommitSHA)
+	}
+
+	if len(api.jobs) != 1 {
+		t.Fatalf("created %d jobs, want 1", len(api.jobs))
+	}
+	for _, job := range api.jobs {
+		spec := job["spec"].(map[string]any)
+		if spec["backoffLimit"].(float64) != 0 {
+			t.Errorf("backoffLimit = %v, want 0", spec["backoffLimit"])
+		}
+		pod := spec["template"].(map[string]any)["spec"].(map[string]any)
+		if pod["automountServiceAccountToken"] != false {
+			t.Errorf("automountServiceAccountToken = %v, want false", pod["automountServiceAccountToken"])
+		}
+		container := pod["containers"].([]any)[0].(map[string]any)
+		if container["image"] != "toposcope/toposcoped:test" {
+			t.Errorf("image = %v", container["image"])
+		}
+		limits := container["resources"].(map[string]any)["limits"].(map[string]any)
+		if limits["cpu"] != "4" || limits["memory"] != "16Gi" {
+			t.Errorf("limits = %v", limits)
+		}
+		if env := jobEnv(job); env["TOPOSCOPE_MODE"] != "extract-job" || env["STORAGE_BACKEND"] != "gcs" {
+			t.Errorf("env = %v", env)
+		}
+	}
+}
+
+func TestKubernetesRunnerJobFailure(t *testing.T) {
+	api := &fakeJobsAPI{
+		jobs:    map[string]map[string]any{},
+		polls:   map[string]int{},
+		results: map[string]bool{},
+		worker:  func(string, map[string]any) bool { return false },
+	}
+	srv := httptest.NewServer(api)
+	defer srv.Close()
+
+	runner := &KubernetesRunner{
+		Client:       &KubeClient{BaseURL: srv.URL},
+		Storage:      NewLocalStorage(t.TempDir()),
+		Namespace:    "ci",
+		Image:        "img",
+		PollInterval: time.Millisecond,
+	}
+	_, err := runner.Extract(context.Background(), extract.ExtractionRequest{Repo: "acme/mono"})
+	if err == nil || !strings.Contains(err.Error(), "BackoffLimitExceeded") {
+		t.Fatalf("Extract error = %v, want job failure reason", err)
+	}
+	if len(api.deleted) != 0 {
+		t.Errorf("deleted %v, want the failed job kept for its logs", api.deleted)
+	}
+}
+
+func TestKubernetesRunnerCancel(t *testing.T) {
+	api := &fakeJobsAPI{
+		jobs:    map[string]map[string]any{},
+		polls:   map[string]int{},
+		results: map[string]bool{},
+		worker:  func(string, map[string]any) bool { return true },
+	}
+	srv := httptest.NewServer(api)
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	runner := &KubernetesRunner{
+		Client:       &KubeClient{BaseURL: srv.URL},
+		Storage:      NewLocalStorage(t.TempDir()),
+		Namespace:    "ci",
+		Image:        "img",
+		PollInterval: time.Millisecond,
+	}
+	if _, err := runner.Extract(ctx, extract.ExtractionRequest{Repo: "acme/mono"}); err == nil {
+		t.Fatal("expected error for canceled context")
+	}
+}
+
+func jobEnv(job map[string]any) map[string]string {
+	container := job["spec"].(map[string]any)["template"].(map[string]any)["spec"].(map[string]any)["containers"].([]any)[0].(map[string]any)
+	env := map[string]string{}
+	for _, e := range container["env"].([]any) {
+		kv := e.(map[string]any)
+		env[kv["name"].(string)] = kv["value"].(string)
+	}
+	return env
+}
@@ -0,0 +1,171 @@
+package ingestion
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"golang.org/x/oauth2/google"
+)
+
+// NewKMSKeyWrapper returns a KeyWrapper for a KMS key, chosen by the form of
+// keyName: an AWS KMS key ARN (arn:aws:kms:...) or a Google Cloud KMS key
+// resource name (projects/.../cryptoKeys/...).
+func NewKMSKeyWrapper(ctx context.Context, keyName string) (KeyWrapper, error) {
+	switch {
+	case strings.HasPrefix(keyName, "arn:aws:kms:"):
+		return NewAWSKMSWrapper(ctx, keyName)
+	case strings.HasPrefix(keyName, "projects/"):
+		return NewGCPKMSWrapper(ctx, keyName)
+	default:
+		return nil, fmt.Errorf("unrecognized KMS key %q (want an AWS KMS key ARN or a GCP cryptoKeys resource name)", keyName)
+	}
+}
+
+// AWSKMSWrapper wraps data keys with AWS KMS Encrypt and Decrypt.
+type AWSKMSWrapper struct {
+	KeyARN     string
+	Region     string
+	Creds      aws.CredentialsProvider
+	HTTPClient *http.Client
+}
+
+// NewAWSKMSWrapper uses credentials from the default AWS configuration.
+func NewAWSKMSWrapper(ctx context.Context, keyARN string) (*AWSKMSWrapper, error) {
+	// arn:aws:kms:<region>:<account>:key/<id>
+	parts := strings.SplitN(keyARN, ":", 6)
+	if len(parts) < 6 || parts[3] == "" {
+		return nil, fmt.Errorf("invalid KMS key ARN %q", keyARN)
+	}
+	cfg, err := awsconfig.LoadDefaultConfig(ctx, awsconfig.WithRegion(parts[3]))
+	if err != nil {
+		return nil, fmt.Errorf("load aws config: %w", err)
+	}
+	return &AWSKMSWrapper{
+		KeyARN:     keyARN,
+		Region:     parts[3],
+		Creds:      cfg.Credentials,
+		HTTPClient: &http.Client{Timeout: 30 * time.Second},
+	}, nil
+}
+
+// WrapKey encrypts dataKey under the KMS key.
+func (w *AWSKMSWrapper) WrapKey(ctx context.Context, dataKey []byte) ([]byte, error) {
+	var resp struct {
+		CiphertextBlob []byte
+	}
+	err := w.call(ctx, "Encrypt", map[string]any{"KeyId": w.KeyARN, "Plaintext": dataKey}, &resp)
+	return resp.CiphertextBlob, err
+}
+
+// UnwrapKey decrypts a key produced by WrapKey.
+func (w *AWSKMSWrapper) UnwrapKey(ctx context.Context, wrapped []byte) ([]byte, error) {
+	var resp struct {
+		Plaintext []byte
+	}
+	err := w.call(ctx, "Decrypt", map[string]any{"KeyId": w.KeyARN, "CiphertextBlob": wrapped}, &resp)
+	return resp.Plaintext, err
+}
+
+// call invokes a KMS JSON API action. []byte fields marshal as base64,
+// which is what the API expects for blobs.
+func (w *AWSKMSWrapper) call(ctx context.Context, action string, in, out any) error {
+	body, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "https://kms."+w.Region+".amazonaws.com/", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "TrentService."+action)
+
+	creds, err := w.Creds.Retrieve(ctx)
+	if err != nil {
+		return fmt.Errorf("retrieve aws credentials: %w", err)
+	}
+	sum := sha256.Sum256(body)
+	if err := v4.NewSigner().SignHTTP(ctx, creds, req, hex.EncodeToString(sum[:]), "kms", w.Region, time.Now()); err != nil {
+		return fmt.Errorf("sign kms request: %w", err)
+	}
+	return doKMS(w.HTTPClient, req, "kms "+action, out)
+}
+
+// GCPKMSWrapper wraps data keys with Google Cloud KMS encrypt and decrypt.
+type GCPKMSWrapper struct {
+	KeyName    string
+	HTTPClient *http.Client // must attach OAuth credentials
+}
+
+// NewGCPKMSWrapper uses Application Default Credentials.
+func NewGCPKMSWrapper(ctx context.Context, keyName string) (*GCPKMSWrapper, error) {
+	client, err := google.DefaultClient(ctx, "https://www.googleapis.com/auth/cloudkms")
+	if err != nil {
+		return nil, fmt.Errorf("google credentials: %w", err)
+	}
+	client.Timeout = 30 * time.Second
+	return &GCPKMSWrapper{KeyName: keyName, HTTPClient: client}, nil
+}
+
+// WrapKey encrypts dataKey under the KMS key.
+func (w *GCPKMSWrapper) WrapKey(ctx context.Context, dataKey []byte) ([]byte, error) {
+	var resp struct {
+		Ciphertext []byte `json:"ciphertext"`
+	}
+	err := w.call(ctx, "encrypt", map[string]any{"plaintext": dataKey}, &resp)
+	return resp.Ciphertext, err
+}
+
+// UnwrapKey decrypts a key produced by WrapKey.
+func (w *GCPKMSWrapper) UnwrapKey(ctx context.Context, wrapped []byte) ([]byte, error) {
+	var resp struct {
+		Plaintext []byte `json:"plaintext"`
+	}
+	err := w.call(ctx, "decrypt", map[string]any{"ciphertext": wrapped}, &resp)
+	return resp.Plaintext, err
+}
+
+func (w *GCPKMSWrapper) call(ctx context.Context, method string, in, out any) error {
+	body, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	endpoint := "https://cloudkms.googleapis.com/v1/" + w.KeyName + ":" + method
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return doKMS(w.HTTPClient, req, "cloudkms "+method, out)
+}
+
+func doKMS(client *http.Client, req *http.Request, what string, out any) error {
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s: %w", what, err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return fmt.Errorf("%s: read response: %w", what, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: status %d: %s", what, resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("%s: decode response: %w", what, err)
+	}
+	return nil
+}
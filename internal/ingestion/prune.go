@@ -0,0 +1,223 @@
+package ingestion
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+
+	"github.com/lib/pq"
+)
+
+// PruneResult reports what PrunePR removed.
+type PruneResult struct {
+	Scores    int `json:"scores"`
+	Deltas    int `json:"deltas"`
+	Snapshots int `json:"snapshots"`
+}
+
+// PrunePR keeps the keep most recent scores of a pull request and deletes
+// the older ones, along with their deltas and head snapshots once nothing
+// else refers to them. Each push to a PR supersedes the previous one, so
+// only the latest results are worth their storage.
+//
+// The oldest kept score records how many pushes were folded into it
+// (Superseded), so a PR's history still reads as a compacted series, and
+// the PR's ingestion records that pointed at pruned results are repointed at
+// the newest score. keep < 1 disables pruning.
+func (s *Service) PrunePR(ctx context.Context, repoID string, prNumber, keep int) (PruneResult, error) {
+	var res PruneResult
+	if keep < 1 {
+		return res, nil
+	}
+
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT id, COALESCE(delta_id::text, ''), head_snapshot_id, superseded FROM scores
+		 WHERE repo_id = $1 AND pr_number = $2
+		 ORDER BY created_at DESC, id DESC`,
+		repoID, prNumber,
+	)
+	if err != nil {
+		return res, fmt.Errorf("list pr scores: %w", err)
+	}
+	type score struct {
+		id, deltaID, headID string
+		superseded          int
+	}
+	var scores []score
+	for rows.Next() {
+		var sc score
+		if err := rows.Scan(&sc.id, &sc.deltaID, &sc.headID, &sc.superseded); err != nil {
+			rows.Close()
+			return res, fmt.Errorf("scan pr score: %w", err)
+		}
+		scores = append(scores, sc)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return res, fmt.Errorf("list pr scores: %w", err)
+	}
+	if len(scores) <= keep {
+		return res, nil
+	}
+
+	kept, pruned := scores[:keep], scores[keep:]
+	var scoreIDs, deltaIDs, snapshotIDs []string
+	folded := 0
+	for _, sc := range pruned {
+		scoreIDs = append(scoreIDs, sc.id)
+		if sc.deltaID != "" {
+			deltaIDs = append(deltaIDs, sc.deltaID)
+		}
+		snapshotIDs = append(snapshotIDs, sc.headID)
+		folded += 1 + sc.superseded
+	}
+	newest, oldestKept := kept[0], kept[len(kept)-1]
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return res, fmt.Errorf("begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := tx.ExecContext(ctx,
+		`UPDATE ingestions SET score_id = $1, delta_id = $2, snapshot_id = $3
+		 WHERE repo_id = $4 AND pr_number = $5
+		   AND (score_id = ANY($6) OR delta_id = ANY($7) OR snapshot_id = ANY($8))`,
+		newest.id, nilIfEmpty(newest.deltaID), newest.headID, repoID, prNumber,
+		pq.Array(scoreIDs), pq.Array(deltaIDs), pq.Array(snapshotIDs),
+	); err != nil {
+		return res, fmt.Errorf("repoint ingestions: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx,
+		`UPDATE scores SET superseded = superseded + $1 WHERE id = $2`, folded, oldestKept.id,
+	); err != nil {
+		return res, fmt.Errorf("record superseded scores: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM score_findings WHERE score_id = ANY($1)`, pq.Array(scoreIDs)); err != nil {
+		return res, fmt.Errorf("delete superseded findings: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM scores WHERE id = ANY($1)`, pq.Array(scoreIDs)); err != nil {
+		return res, fmt.Errorf("delete superseded scores: %w", err)
+	}
+	res.Scores = len(scoreIDs)
+
+	var blobs []prunedBlob
+	deltaRows, err := tx.QueryContext(ctx,
+		`DELETE FROM deltas d WHERE d.id = ANY($1)
+		   AND NOT EXISTS (SELECT 1 FROM scores WHERE delta_id = d.id)
+		 RETURNING tenant_id, storage_ref`,
+		pq.Array(deltaIDs),
+	)
+	if err != nil {
+		return res, fmt.Errorf("delete superseded deltas: %w", err)
+	}
+	if blobs, err = scanPrunedBlobs(deltaRows, "deltas", blobs); err != nil {
+		return res, err
+	}
+	res.Deltas = len(blobs)
+
+	// A PR head can also be a default-branch commit, a baseline, or the base
+	// of another change, so only unreferenced snapshots go.
+	snapRows, err := tx.QueryContext(ctx,
+		`DELETE FROM snapshots sn WHERE sn.id = ANY($1)
+		   AND NOT EXISTS (SELECT 1 FROM scores WHERE base_snapshot_id = sn.id OR head_snapshot_id = sn.id)
+		   AND NOT EXISTS (SELECT 1 FROM deltas WHERE base_snapshot_id = sn.id OR head_snapshot_id = sn.id)
+		   AND NOT EXISTS (SELECT 1 FROM baselines WHERE snapshot_id = sn.id)
+		   AND NOT EXISTS (SELECT 1 FROM ingestions WHERE snapshot_id = sn.id)
+		 RETURNING tenant_id, storage_ref`,
+		pq.Array(snapshotIDs),
+	)
+	if err != nil {
+		return res, fmt.Errorf("delete superseded snapshots: %w", err)
+	}
+	if blobs, err = scanPrunedBlobs(snapRows, "snapshots", blobs); err != nil {
+		return res, err
+	}
+	res.Snapshots = len(blobs) - res.Deltas
+
+	// Blobs are named by content, so a row of another repository, or
+	// another row of this one, may still use a blob whose row went.
+	if blobs, err = unsharedBlobs(ctx, tx, blobs); err != nil {
+		return res, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return res, fmt.Errorf("commit: %w", err)
+	}
+
+	// The rows are gone, so a blob that fails to delete is only wasted space.
+	for _, b := range blobs {
+		var err error
+		id := blobID(b.ref)
+		if b.kind == "snapshots" {
+			err = s.storage.DeleteSnapshot(ctx, b.tenantID, id)
+		} else {
+			err = s.storage.DeleteDelta(ctx, b.tenantID, id)
+		}
+		if err != nil {
+			log.Printf("prune PR %d of repo %s: delete %s blob %s: %v", prNumber, repoID, b.kind, id, err)
+		}
+	}
+	return res, nil
+}
+
+type prunedBlob struct {
+	kind, tenantID, ref string
+}
+
+// unsharedBlobs returns the blobs that no remaining row refers to.
+func unsharedBlobs(ctx context.Context, tx *sql.Tx, blobs []prunedBlob) ([]prunedBlob, error) {
+	if len(blobs) == 0 {
+		return blobs, nil
+	}
+	refs := make([]string, len(blobs))
+	for i, b := range blobs {
+		refs[i] = b.ref
+	}
+	rows, err := tx.QueryContext(ctx,
+		`SELECT storage_ref FROM snapshots WHERE storage_ref = ANY($1)
+		 UNION SELECT storage_ref FROM deltas WHERE storage_ref = ANY($1)`,
+		pq.Array(refs),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("find shared blobs: %w", err)
+	}
+	defer rows.Close()
+	shared := make(map[string]bool)
+	for rows.Next() {
+		var ref string
+		if err := rows.Scan(&ref); err != nil {
+			return nil, fmt.Errorf("scan shared blob: %w", err)
+		}
+		shared[ref] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("find shared blobs: %w", err)
+	}
+	kept := blobs[:0]
+	for _, b := range blobs {
+		if !shared[b.ref] {
+			kept = append(kept, b)
+			shared[b.ref] = true // delete each blob once
+		}
+	}
+	return kept, nil
+}
+
+// scanPrunedBlobs appends the blobs named by rows of (tenant_id, storage_ref)
+// to blobs and closes rows.
+func scanPrunedBlobs(rows *sql.Rows, kind string, blobs []prunedBlob) ([]prunedBlob, error) {
+	defer rows.Close()
+	for rows.Next() {
+		var tenantID, ref string
+		if err := rows.Scan(&tenantID, &ref); err != nil {
+			return blobs, fmt.Errorf("scan pruned %s: %w", kind, err)
+		}
+		blobs = append(blobs, prunedBlob{kind, tenantID, ref})
+	}
+	if err := rows.Err(); err != nil {
+		return blobs, fmt.Errorf("delete superseded %s: %w", kind, err)
+	}
+	return blobs, nil
+}
@@ -0,0 +1,59 @@
+package ingestion
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/toposcope/toposcope/pkg/scoring"
+	"github.com/toposcope/toposcope/pkg/surface"
+)
+
+// CheckPublisher posts results to GitHub as a Check Run.
+type CheckPublisher interface {
+	PublishCheckRun(ctx context.Context, installationID int64, owner, repo, headSHA string, data surface.CheckRunData) error
+}
+
+// previousPushScore returns the most recent score of req's PR for a commit
+// other than req.CommitSHA, or nil if this is the PR's first push.
+func (s *Service) previousPushScore(ctx context.Context, req IngestionRequest) (*scoring.ScoreResult, error) {
+	var result scoring.ScoreResult
+	var breakdown []byte
+	err := s.db.QueryRowContext(ctx,
+		`SELECT total_score, grade, breakdown FROM scores
+		 WHERE repo_id = $1 AND pr_number = $2 AND commit_sha <> $3
+		 ORDER BY created_at DESC, id DESC LIMIT 1`,
+		req.RepoID, *req.PRNumber, req.CommitSHA,
+	).Scan(&result.TotalScore, &result.Grade, &breakdown)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get previous score: %w", err)
+	}
+	if err := json.Unmarshal(breakdown, &result.Breakdown); err != nil {
+		return nil, fmt.Errorf("decode previous score breakdown: %w", err)
+	}
+	return &result, nil
+}
+
+// publishCheck posts result to the PR's head commit, comparing it with the
+// previous push when there was one. Failures are logged; the ingestion has
+// succeeded.
+func (s *Service) publishCheck(ctx context.Context, req IngestionRequest, result, previous *scoring.ScoreResult) {
+	if s.Checks == nil || req.InstallationID == 0 {
+		return
+	}
+	owner, repo, ok := strings.Cut(req.RepoFullName, "/")
+	if !ok {
+		log.Printf("publish check run: invalid repository name %q", req.RepoFullName)
+		return
+	}
+	data := (&surface.CheckRunRenderer{Previous: previous}).BuildCheckRunData(result)
+	if err := s.Checks.PublishCheckRun(ctx, req.InstallationID, owner, repo, req.CommitSHA, data); err != nil {
+		log.Printf("publish check run for %s@%s: %v", req.RepoFullName, req.CommitSHA, err)
+	}
+}
@@ -0,0 +1,228 @@
+package ingestion
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+)
+
+// PurgeOptions controls Purge.
+type PurgeOptions struct {
+	// RetainFor is how long soft-deleted repositories and tenants are kept
+	// before they are purged.
+	RetainFor time.Duration
+	// DryRun reports what would be purged without deleting anything.
+	DryRun bool
+}
+
+// PurgedRepo describes a repository removed, or due to be removed, by Purge.
+type PurgedRepo struct {
+	ID        string    `json:"id"`
+	TenantID  string    `json:"tenant_id"`
+	FullName  string    `json:"full_name"`
+	DeletedAt time.Time `json:"deleted_at"`
+	Snapshots int       `json:"snapshots"`
+	Deltas    int       `json:"deltas"`
+	Bytes     int64     `json:"bytes"`
+}
+
+// PurgeResult reports what Purge did, or would do on a dry run.
+type PurgeResult struct {
+	Repos   []PurgedRepo `json:"repos"`
+	Tenants []string     `json:"tenants"`
+	// BlobsDeleted counts snapshot and delta blobs removed from storage.
+	BlobsDeleted int `json:"blobs_deleted"`
+	// Skipped lists repositories left in place because some of their blobs
+	// could not be deleted. The next run retries them.
+	Skipped []string `json:"skipped,omitempty"`
+}
+
+// Purge permanently removes repositories and tenants that were soft-deleted
+// more than opts.RetainFor ago: first their snapshot and delta blobs, then
+// their database rows. A repository whose blobs cannot all be deleted keeps
+// its rows so that the blobs are not orphaned; it is retried on the next run.
+// Tenants are removed once they have no repositories left.
+func (s *Service) Purge(ctx context.Context, opts PurgeOptions) (PurgeResult, error) {
+	res := PurgeResult{Repos: []PurgedRepo{}, Tenants: []string{}}
+	cutoff := time.Now().Add(-opts.RetainFor)
+
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT r.id, r.tenant_id, r.full_name, r.deleted_at,
+		        (SELECT COUNT(*) FROM snapshots WHERE repo_id = r.id),
+		        (SELECT COUNT(*) FROM deltas WHERE repo_id = r.id),
+		        COALESCE((SELECT SUM(size_bytes) FROM snapshots WHERE repo_id = r.id), 0)
+		          + COALESCE((SELECT SUM(size_bytes) FROM deltas WHERE repo_id = r.id), 0)
+		 FROM repositories r
+		 WHERE r.deleted_at < $1
+		 ORDER BY r.deleted_at`,
+		cutoff,
+	)
+	if err != nil {
+		return res, fmt.Errorf("list deleted repositories: %w", err)
+	}
+	var repos []PurgedRepo
+	for rows.Next() {
+		var r PurgedRepo
+		if err := rows.Scan(&r.ID, &r.TenantID, &r.FullName, &r.DeletedAt, &r.Snapshots, &r.Deltas, &r.Bytes); err != nil {
+			rows.Close()
+			return res, fmt.Errorf("scan deleted repository: %w", err)
+		}
+		repos = append(repos, r)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return res, fmt.Errorf("list deleted repositories: %w", err)
+	}
+
+	for _, r := range repos {
+		if opts.DryRun {
+			res.Repos = append(res.Repos, r)
+			continue
+		}
+		n, err := s.purgeBlobs(ctx, r.ID)
+		res.BlobsDeleted += n
+		if err != nil {
+			log.Printf("purge %s (%s): %v", r.FullName, r.ID, err)
+			res.Skipped = append(res.Skipped, r.ID)
+			continue
+		}
+		if err := s.purgeRepoRows(ctx, r.ID); err != nil {
+			return res, err
+		}
+		res.Repos = append(res.Repos, r)
+	}
+
+	// On a dry run the repositories above are still present, so count a
+	// tenant as empty if all of its repositories are due to be purged.
+	tenantRows, err := s.db.QueryContext(ctx,
+		`SELECT t.id FROM tenants t
+		 WHERE t.deleted_at < $1
+		   AND NOT EXISTS (
+		     SELECT 1 FROM repositories r
+		     WHERE r.tenant_id = t.id AND ($2 = false OR r.deleted_at IS NULL OR r.deleted_at >= $1)
+		   )
+		 ORDER BY t.deleted_at`,
+		cutoff, opts.DryRun,
+	)
+	if err != nil {
+		return res, fmt.Errorf("list deleted tenants: %w", err)
+	}
+	var tenants []string
+	for tenantRows.Next() {
+		var id string
+		if err := tenantRows.Scan(&id); err != nil {
+			tenantRows.Close()
+			return res, fmt.Errorf("scan deleted tenant: %w", err)
+		}
+		tenants = append(tenants, id)
+	}
+	tenantRows.Close()
+	if err := tenantRows.Err(); err != nil {
+		return res, fmt.Errorf("list deleted tenants: %w", err)
+	}
+
+	for _, id := range tenants {
+		if !opts.DryRun {
+			if err := s.purgeTenantRows(ctx, id); err != nil {
+				return res, err
+			}
+		}
+		res.Tenants = append(res.Tenants, id)
+	}
+	return res, nil
+}
+
+// purgeBlobs deletes the snapshot and delta blobs of a repository and
+// returns how many it deleted. Blobs are named by content, so another
+// repository of the tenant with the same graph shares them; those are kept.
+func (s *Service) purgeBlobs(ctx context.Context, repoID string) (int, error) {
+	var deleted int
+	for _, kind := range []string{"snapshots", "deltas"} {
+		rows, err := s.db.QueryContext(ctx,
+			`SELECT DISTINCT tenant_id, storage_ref FROM `+kind+` b WHERE repo_id = $1
+			   AND NOT EXISTS (SELECT 1 FROM `+kind+` o WHERE o.storage_ref = b.storage_ref AND o.repo_id <> $1)`, repoID)
+		if err != nil {
+			return deleted, fmt.Errorf("list %s: %w", kind, err)
+		}
+		type blob struct{ tenantID, id string }
+		var blobs []blob
+		for rows.Next() {
+			var tenantID, ref string
+			if err := rows.Scan(&tenantID, &ref); err != nil {
+				rows.Close()
+				return deleted, fmt.Errorf("scan %s: %w", kind, err)
+			}
+			blobs = append(blobs, blob{tenantID, blobID(ref)})
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return deleted, fmt.Errorf("list %s: %w", kind, err)
+		}
+
+		for _, b := range blobs {
+			var err error
+			if kind == "snapshots" {
+				err = s.storage.DeleteSnapshot(ctx, b.tenantID, b.id)
+			} else {
+				err = s.storage.DeleteDelta(ctx, b.tenantID, b.id)
+			}
+			if err != nil {
+				return deleted, fmt.Errorf("delete %s blob %s: %w", kind, b.id, err)
+			}
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+// purgeRepoRows deletes a repository and all associated data in FK order
+// within a transaction.
+func (s *Service) purgeRepoRows(ctx context.Context, repoID string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	queries := []string{
+		`DELETE FROM ingestions WHERE repo_id = $1`,
+		`DELETE FROM ingest_idempotency_keys WHERE repo_id = $1`,
+		`DELETE FROM backfill_commits WHERE job_id IN (SELECT id FROM backfill_jobs WHERE repo_id = $1)`,
+		`DELETE FROM backfill_jobs WHERE repo_id = $1`,
+		`DELETE FROM score_findings WHERE repo_id = $1`,
+		`DELETE FROM scores WHERE repo_id = $1`,
+		`DELETE FROM deltas WHERE repo_id = $1`,
+		`DELETE FROM baselines WHERE repo_id = $1`,
+		`DELETE FROM baseline_drift WHERE repo_id = $1`,
+		`DELETE FROM snapshots WHERE repo_id = $1`,
+		`DELETE FROM repo_api_keys WHERE repo_id = $1`,
+		`DELETE FROM repositories WHERE id = $1`,
+	}
+	for _, q := range queries {
+		if _, err := tx.ExecContext(ctx, q, repoID); err != nil {
+			return fmt.Errorf("purge repo %s: %w", repoID, err)
+		}
+	}
+	return tx.Commit()
+}
+
+// purgeTenantRows deletes an empty tenant and its usage history.
+func (s *Service) purgeTenantRows(ctx context.Context, tenantID string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	queries := []string{
+		`DELETE FROM tenant_usage WHERE tenant_id = $1`,
+		`DELETE FROM tenants WHERE id = $1`,
+	}
+	for _, q := range queries {
+		if _, err := tx.ExecContext(ctx, q, tenantID); err != nil {
+			return fmt.Errorf("purge tenant %s: %w", tenantID, err)
+		}
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,179 @@
+package ingestion
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// IngestionRecord is a row of the ingestions table.
+type IngestionRecord struct {
+	ID           string
+	TenantID     string
+	RepoID       string
+	RepoFullName string
+	CommitSHA    string
+	PRNumber     *int
+	Status       string
+	ErrorMessage *string
+	SnapshotID   *string
+	DeltaID      *string
+	ScoreID      *string
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+}
+
+// IngestionFilter narrows ListIngestions. Empty fields match everything.
+type IngestionFilter struct {
+	Status string
+	RepoID string
+	Limit  int // default 50
+}
+
+const ingestionColumns = `i.id, i.tenant_id, i.repo_id, r.full_name, i.commit_sha, i.pr_number, i.status,
+	i.error_message, i.snapshot_id, i.delta_id, i.score_id, i.created_at, i.updated_at`
+
+func scanIngestion(row interface{ Scan(...any) error }) (*IngestionRecord, error) {
+	rec := &IngestionRecord{}
+	err := row.Scan(&rec.ID, &rec.TenantID, &rec.RepoID, &rec.RepoFullName, &rec.CommitSHA, &rec.PRNumber, &rec.Status,
+		&rec.ErrorMessage, &rec.SnapshotID, &rec.DeltaID, &rec.ScoreID, &rec.CreatedAt, &rec.UpdatedAt)
+	return rec, err
+}
+
+// ListIngestions returns ingestion records matching f, most recently
+// updated first.
+func (s *Service) ListIngestions(ctx context.Context, f IngestionFilter) ([]IngestionRecord, error) {
+	query := `SELECT ` + ingestionColumns + ` FROM ingestions i JOIN repositories r ON r.id = i.repo_id`
+	var conds []string
+	var args []any
+	if f.Status != "" {
+		args = append(args, f.Status)
+		conds = append(conds, fmt.Sprintf("i.status = $%d", len(args)))
+	}
+	if f.RepoID != "" {
+		args = append(args, f.RepoID)
+		conds = append(conds, fmt.Sprintf("i.repo_id = $%d", len(args)))
+	}
+	if len(conds) > 0 {
+		query += ` WHERE ` + strings.Join(conds, " AND ")
+	}
+	limit := f.Limit
+	if limit <= 0 {
+		limit = 50
+	}
+	args = append(args, limit)
+	query += fmt.Sprintf(` ORDER BY i.updated_at DESC LIMIT $%d`, len(args))
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("list ingestions: %w", err)
+	}
+	defer rows.Close()
+
+	var recs []IngestionRecord
+	for rows.Next() {
+		rec, err := scanIngestion(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan ingestion: %w", err)
+		}
+		recs = append(recs, *rec)
+	}
+	return recs, rows.Err()
+}
+
+// GetIngestion returns one ingestion record.
+func (s *Service) GetIngestion(ctx context.Context, id string) (*IngestionRecord, error) {
+	rec, err := scanIngestion(s.db.QueryRowContext(ctx,
+		`SELECT `+ingestionColumns+` FROM ingestions i JOIN repositories r ON r.id = i.repo_id WHERE i.id = $1`, id))
+	if err != nil {
+		return nil, fmt.Errorf("get ingestion %s: %w", id, err)
+	}
+	return rec, nil
+}
+
+// GCOptions controls CollectGarbage.
+type GCOptions struct {
+	// StuckAfter marks QUEUED or RUNNING ingestions not updated for this
+	// long as FAILED. Their worker has died, so they'd never finish.
+	StuckAfter time.Duration
+	// RetainFor deletes COMPLETED and FAILED ingestion records older than
+	// this. Snapshots, deltas, and scores are kept.
+	RetainFor time.Duration
+	// DryRun counts what would change without changing it.
+	DryRun bool
+}
+
+// GCResult reports what CollectGarbage did, or would do on a dry run.
+type GCResult struct {
+	ExpiredIngestions      int `json:"expired_ingestions"`
+	DeletedIngestions      int `json:"deleted_ingestions"`
+	ExpiredIdempotencyKeys int `json:"expired_idempotency_keys"`
+}
+
+// CollectGarbage expires stuck ingestions, deletes old ingestion records,
+// and deletes idempotency keys older than IdempotencyKeyTTL.
+func (s *Service) CollectGarbage(ctx context.Context, opts GCOptions) (GCResult, error) {
+	var res GCResult
+	stuckCutoff := time.Now().Add(-opts.StuckAfter)
+	retainCutoff := time.Now().Add(-opts.RetainFor)
+
+	count := func(query string, args ...any) (int, error) {
+		var n int
+		err := s.db.QueryRowContext(ctx, query, args...).Scan(&n)
+		return n, err
+	}
+	exec := func(query string, args ...any) (int, error) {
+		r, err := s.db.ExecContext(ctx, query, args...)
+		if err != nil {
+			return 0, err
+		}
+		n, err := r.RowsAffected()
+		return int(n), err
+	}
+
+	var err error
+	if opts.StuckAfter > 0 {
+		if opts.DryRun {
+			res.ExpiredIngestions, err = count(
+				`SELECT COUNT(*) FROM ingestions WHERE status IN ($1, $2) AND updated_at < $3`,
+				StatusQueued, StatusRunning, stuckCutoff)
+		} else {
+			res.ExpiredIngestions, err = exec(
+				`UPDATE ingestions SET status = $1, error_message = $2, updated_at = now()
+				 WHERE status IN ($3, $4) AND updated_at < $5`,
+				StatusFailed, "expired: no progress for "+opts.StuckAfter.String(), StatusQueued, StatusRunning, stuckCutoff)
+		}
+		if err != nil {
+			return res, fmt.Errorf("expire stuck ingestions: %w", err)
+		}
+	}
+
+	if opts.RetainFor > 0 {
+		if opts.DryRun {
+			res.DeletedIngestions, err = count(
+				`SELECT COUNT(*) FROM ingestions WHERE status IN ($1, $2) AND updated_at < $3`,
+				StatusCompleted, StatusFailed, retainCutoff)
+		} else {
+			res.DeletedIngestions, err = exec(
+				`DELETE FROM ingestions WHERE status IN ($1, $2) AND updated_at < $3`,
+				StatusCompleted, StatusFailed, retainCutoff)
+		}
+		if err != nil {
+			return res, fmt.Errorf("delete old ingestions: %w", err)
+		}
+	}
+
+	keyCutoff := time.Now().Add(-IdempotencyKeyTTL)
+	if opts.DryRun {
+		res.ExpiredIdempotencyKeys, err = count(
+			`SELECT COUNT(*) FROM ingest_idempotency_keys WHERE created_at < $1`, keyCutoff)
+	} else {
+		res.ExpiredIdempotencyKeys, err = exec(
+			`DELETE FROM ingest_idempotency_keys WHERE created_at < $1`, keyCutoff)
+	}
+	if err != nil {
+		return res, fmt.Errorf("delete expired idempotency keys: %w", err)
+	}
+	return res, nil
+}
@@ -0,0 +1,61 @@
+package ingestion
+
+import (
+	"context"
+	"log"
+
+	"github.com/toposcope/toposcope/pkg/extract"
+)
+
+// DefaultMaxScopedTargets is the largest impact set a PR head is extracted
+// scoped for when the service doesn't set MaxScopedTargets.
+const DefaultMaxScopedTargets = 2000
+
+// ImpactDetector finds the targets a commit changes relative to a base
+// commit, for repositories the service extracts itself.
+type ImpactDetector interface {
+	// ImpactedTargets returns the targets req.CommitSHA changes relative
+	// to baseSHA.
+	ImpactedTargets(ctx context.Context, req extract.ExtractionRequest, baseSHA string) ([]string, error)
+}
+
+// headScope returns the extraction scope of a PR head: the neighborhood of
+// the targets it changes relative to the baseline commit, or the full graph
+// when there is no impact detector, detection fails, or the impact set is
+// empty or too large.
+func (s *Service) headScope(ctx context.Context, req IngestionRequest, baseSHA string) extract.ExtractionScope {
+	if s.Impacts == nil || req.PRNumber == nil || baseSHA == "" {
+		return extract.ExtractionScope{Mode: extract.ScopeModeFull}
+	}
+	impacted, err := s.Impacts.ImpactedTargets(ctx, extract.ExtractionRequest{
+		CommitSHA:      req.CommitSHA,
+		Repo:           req.RepoFullName,
+		InstallationID: req.InstallationID,
+	}, baseSHA)
+	if err != nil {
+		log.Printf("PR %d of %s: impact detection failed, extracting in full: %v", *req.PRNumber, req.RepoFullName, err)
+		return extract.ExtractionScope{Mode: extract.ScopeModeFull}
+	}
+	scope := scopeFor(impacted, s.ScopedRdepsDepth, s.MaxScopedTargets)
+	if scope.Mode == extract.ScopeModeFull && len(impacted) > 0 {
+		log.Printf("PR %d of %s: %d impacted targets, extracting in full", *req.PRNumber, req.RepoFullName, len(impacted))
+	}
+	return scope
+}
+
+// scopeFor returns the scoped extraction of impacted, or a full one when
+// impacted is empty or has more than maxTargets targets (0 uses
+// DefaultMaxScopedTargets).
+func scopeFor(impacted []string, rdepsDepth, maxTargets int) extract.ExtractionScope {
+	if maxTargets <= 0 {
+		maxTargets = DefaultMaxScopedTargets
+	}
+	if len(impacted) == 0 || len(impacted) > maxTargets {
+		return extract.ExtractionScope{Mode: extract.ScopeModeFull}
+	}
+	return extract.ExtractionScope{
+		Mode:       extract.ScopeModeScoped,
+		Roots:      impacted,
+		RdepsDepth: rdepsDepth,
+	}
+}
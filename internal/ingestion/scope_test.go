@@ -0,0 +1,22 @@
+package ingestion
+
+import (
+	"testing"
+
+	"github.com/toposcope/toposcope/pkg/extract"
+)
+
+func TestScopeFor(t *testing.T) {
+	impacted := []string{"//app:server", "//lib:auth"}
+
+	scope := scopeFor(impacted, 3, 0)
+	if scope.Mode != extract.ScopeModeScoped || len(scope.Roots) != 2 || scope.RdepsDepth != 3 {
+		t.Errorf("scopeFor(2 targets) = %+v, want scoped with depth 3", scope)
+	}
+	if scope := scopeFor(impacted, 0, 1); scope.Mode != extract.ScopeModeFull || scope.Roots != nil {
+		t.Errorf("scopeFor over the limit = %+v, want full", scope)
+	}
+	if scope := scopeFor(nil, 2, 0); scope.Mode != extract.ScopeModeFull {
+		t.Errorf("scopeFor(no targets) = %+v, want full", scope)
+	}
+}
@@ -6,8 +6,11 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"path"
+	"strings"
 	"time"
 
+	"github.com/lib/pq"
 	"github.com/toposcope/toposcope/internal/tenant"
 	"github.com/toposcope/toposcope/pkg/extract"
 	"github.com/toposcope/toposcope/pkg/graph"
@@ -32,6 +35,8 @@ type IngestionRequest struct {
 	PRNumber       *int
 	InstallationID int64
 	CommittedAt    *time.Time // If set, used as timestamp instead of now()
+	// Provenance is stored with the score when it was uploaded signed.
+	Provenance *scoring.Provenance
 }
 
 // Scorer abstracts the scoring engine so the ingestion package does not
@@ -47,6 +52,34 @@ type Service struct {
 	storage   StorageClient
 	extractor extract.Extractor
 	scorer    Scorer
+
+	// KeepPRScores is how many of a PR's most recent scores ProcessPR keeps
+	// when the repository doesn't set its own limit. 0 keeps them all.
+	KeepPRScores int
+
+	// Commits, if set, supplies author, message, and commit time for the
+	// snapshots of installed repositories.
+	Commits CommitFetcher
+
+	// Checks, if set, receives a Check Run for each scored PR push of an
+	// installed repository.
+	Checks CheckPublisher
+
+	// Webhooks, if set, delivers each stored score to the repository's
+	// configured webhooks.
+	Webhooks *WebhookSender
+
+	// Impacts, if set, finds the targets each PR push changes relative to
+	// the baseline, and ProcessPR extracts only their neighborhood.
+	Impacts ImpactDetector
+
+	// ScopedRdepsDepth is the reverse dependency depth of scoped PR
+	// extractions (0 = the extractor's default of 2).
+	ScopedRdepsDepth int
+
+	// MaxScopedTargets is the largest impact set extracted scoped. Larger
+	// sets are extracted in full (0 = DefaultMaxScopedTargets).
+	MaxScopedTargets int
 }
 
 // NewService creates a new ingestion Service.
@@ -65,6 +98,31 @@ func (s *Service) Storage() StorageClient {
 	return s.storage
 }
 
+// BeginIngestion checks the tenant's quota and, if it allows, counts n
+// ingestions against the current month. It returns an error wrapping
+// tenant.ErrQuotaExceeded if the tenant is over quota.
+func (s *Service) BeginIngestion(ctx context.Context, tenantID string, n int) error {
+	if s.tenants == nil {
+		return nil
+	}
+	if err := s.tenants.CheckQuota(ctx, tenantID); err != nil {
+		return err
+	}
+	s.recordUsage(ctx, tenantID, tenant.Usage{Ingestions: int64(n)})
+	return nil
+}
+
+// recordUsage adds to the tenant's monthly usage. Failures are logged: usage
+// is advisory and must not fail an ingestion that has already been stored.
+func (s *Service) recordUsage(ctx context.Context, tenantID string, u tenant.Usage) {
+	if s.tenants == nil {
+		return
+	}
+	if err := s.tenants.RecordUsage(ctx, tenantID, u); err != nil {
+		log.Printf("warning: %v", err)
+	}
+}
+
 // CreateIngestion creates a new ingestion record and returns its ID.
 // The idempotency key is repo_id + commit_sha (+ pr_number if present).
 func (s *Service) CreateIngestion(ctx context.Context, req IngestionRequest) (string, error) {
@@ -101,6 +159,10 @@ func (s *Service) UpdateIngestionStatus(ctx context.Context, id, status string,
 
 // ProcessPR runs the full ingestion pipeline for a PR or push event.
 func (s *Service) ProcessPR(ctx context.Context, req IngestionRequest) error {
+	if s.extractor == nil {
+		return fmt.Errorf("no extractor configured; hosted extraction is unavailable")
+	}
+
 	// 1. Create or retrieve ingestion record
 	ingestionID, err := s.CreateIngestion(ctx, req)
 	if err != nil {
@@ -121,23 +183,45 @@ func (s *Service) ProcessPR(ctx context.Context, req IngestionRequest) error {
 		}
 	}()
 
+	if err = s.BeginIngestion(ctx, req.TenantID, 1); err != nil {
+		return err
+	}
+
 	// 2. Ensure baseline exists
 	baseSnapshotID, err := s.ensureBaseline(ctx, req)
 	if err != nil {
 		return fmt.Errorf("ensure baseline: %w", err)
 	}
 
-	// 3. Extract head snapshot
+	baseSnapshotData, err := s.storage.GetSnapshot(ctx, req.TenantID, baseSnapshotID)
+	if err != nil {
+		return fmt.Errorf("load base snapshot: %w", err)
+	}
+
+	var baseSnapshot graph.Snapshot
+	if err := json.Unmarshal(baseSnapshotData, &baseSnapshot); err != nil {
+		return fmt.Errorf("unmarshal base snapshot: %w", err)
+	}
+
+	// 3. Extract head snapshot, scoped to what a PR changes if possible
 	start := time.Now()
-	headSnapshot, err := s.extractor.Extract(ctx, extract.ExtractionRequest{
-		CommitSHA: req.CommitSHA,
-		Scope: extract.ExtractionScope{
-			Mode: extract.ScopeModeFull,
-		},
+	extractCtx, cancel := s.withExtractionTimeout(ctx, req.RepoID)
+	headSnapshot, err := s.extractor.Extract(extractCtx, extract.ExtractionRequest{
+		CommitSHA:      req.CommitSHA,
+		Scope:          s.headScope(extractCtx, req, baseSnapshot.CommitSHA),
+		Repo:           req.RepoFullName,
+		InstallationID: req.InstallationID,
 	})
+	cancel()
 	if err != nil {
 		return fmt.Errorf("extract head snapshot: %w", err)
 	}
+	// Scoped extractions only cover the changed neighborhood. Overlay them
+	// onto the baseline so the delta doesn't report every target outside
+	// the scope as removed.
+	if headSnapshot.Partial {
+		headSnapshot = graph.MergeIntoBaseline(&baseSnapshot, headSnapshot)
+	}
 	headSnapshot.Stats.ExtractionMs = int(time.Since(start).Milliseconds())
 
 	// Store head snapshot
@@ -150,19 +234,10 @@ func (s *Service) ProcessPR(ctx context.Context, req IngestionRequest) error {
 	if err != nil {
 		return fmt.Errorf("store head snapshot: %w", err)
 	}
+	s.recordCommit(ctx, req, headSnapshotID, req.CommitSHA)
 
-	// 4. Load base snapshot and compute delta
-	baseSnapshotData, err := s.storage.GetSnapshot(ctx, req.TenantID, baseSnapshotID)
-	if err != nil {
-		return fmt.Errorf("load base snapshot: %w", err)
-	}
-
-	var baseSnapshot graph.Snapshot
-	if err := json.Unmarshal(baseSnapshotData, &baseSnapshot); err != nil {
-		return fmt.Errorf("unmarshal base snapshot: %w", err)
-	}
-
-	delta := computeDelta(&baseSnapshot, headSnapshot)
+	// 4. Compute delta
+	delta := graph.ComputeDelta(&baseSnapshot, headSnapshot)
 	delta.BaseSnapshotID = baseSnapshotID
 	delta.HeadSnapshotID = headSnapshotID
 
@@ -185,7 +260,14 @@ func (s *Service) ProcessPR(ctx context.Context, req IngestionRequest) error {
 		}
 	}
 
-	// 6. Store score
+	// 6. Store score, after looking up the previous push it will be
+	// compared with
+	var previous *scoring.ScoreResult
+	if scoreResult != nil && req.PRNumber != nil && s.Checks != nil {
+		if previous, err = s.previousPushScore(ctx, req); err != nil {
+			log.Printf("PR %d of %s: %v", *req.PRNumber, req.RepoFullName, err)
+		}
+	}
 	var scoreID string
 	if scoreResult != nil {
 		scoreID, err = s.StoreScore(ctx, req, baseSnapshotID, headSnapshotID, deltaID, scoreResult)
@@ -205,9 +287,57 @@ func (s *Service) ProcessPR(ctx context.Context, req IngestionRequest) error {
 	}
 
 	log.Printf("ingestion %s completed: snapshot=%s delta=%s score=%s", ingestionID, headSnapshotID, deltaID, scoreID)
+
+	if scoreID != "" {
+		s.NotifyScore(ctx, req, scoreID, scoreResult)
+	}
+	if req.PRNumber != nil && scoreID != "" {
+		s.publishCheck(ctx, req, scoreResult, previous)
+		s.pruneSuperseded(ctx, req)
+	}
 	return nil
 }
 
+// pruneSuperseded applies the repository's PR score retention after a new
+// score for req's PR. Failures are logged; the ingestion has succeeded.
+func (s *Service) pruneSuperseded(ctx context.Context, req IngestionRequest) {
+	keep := s.KeepPRScores
+	if s.tenants != nil {
+		settings, err := s.tenants.GetRepoSettings(ctx, req.RepoID)
+		if err != nil {
+			log.Printf("prune PR %d: load repo settings: %v", *req.PRNumber, err)
+		} else if settings.KeepPRScores != nil {
+			keep = *settings.KeepPRScores
+		}
+	}
+	res, err := s.PrunePR(ctx, req.RepoID, *req.PRNumber, keep)
+	if err != nil {
+		log.Printf("prune PR %d of %s: %v", *req.PRNumber, req.RepoFullName, err)
+		return
+	}
+	if res.Scores > 0 {
+		log.Printf("pruned %d superseded scores, %d deltas, and %d snapshots of %s#%d",
+			res.Scores, res.Deltas, res.Snapshots, req.RepoFullName, *req.PRNumber)
+	}
+}
+
+// withExtractionTimeout bounds ctx by the repository's extraction timeout
+// setting, if it has one.
+func (s *Service) withExtractionTimeout(ctx context.Context, repoID string) (context.Context, context.CancelFunc) {
+	if s.tenants == nil {
+		return ctx, func() {}
+	}
+	settings, err := s.tenants.GetRepoSettings(ctx, repoID)
+	if err != nil {
+		log.Printf("extraction timeout of %s: load repo settings: %v", repoID, err)
+		return ctx, func() {}
+	}
+	if settings.ExtractionTimeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, time.Duration(settings.ExtractionTimeout)*time.Second)
+}
+
 func (s *Service) ensureBaseline(ctx context.Context, req IngestionRequest) (string, error) {
 	var snapshotID string
 	err := s.db.QueryRowContext(ctx,
@@ -222,10 +352,15 @@ func (s *Service) ensureBaseline(ctx context.Context, req IngestionRequest) (str
 	}
 
 	// No baseline: extract one from the base branch
-	baseSnapshot, err := s.extractor.Extract(ctx, extract.ExtractionRequest{
+	extractCtx, cancel := s.withExtractionTimeout(ctx, req.RepoID)
+	defer cancel()
+	baseSnapshot, err := s.extractor.Extract(extractCtx, extract.ExtractionRequest{
 		Scope: extract.ExtractionScope{
 			Mode: extract.ScopeModeFull,
 		},
+		Repo:           req.RepoFullName,
+		Ref:            req.BaseBranch,
+		InstallationID: req.InstallationID,
 	})
 	if err != nil {
 		return "", fmt.Errorf("extract baseline: %w", err)
@@ -241,11 +376,13 @@ func (s *Service) ensureBaseline(ctx context.Context, req IngestionRequest) (str
 	if err != nil {
 		return "", fmt.Errorf("store baseline snapshot: %w", err)
 	}
+	s.recordCommit(ctx, req, id, baseSnapshot.CommitSHA)
 
 	// Set as baseline
 	_, err = s.db.ExecContext(ctx,
 		`INSERT INTO baselines (repo_id, snapshot_id) VALUES ($1, $2)
-		 ON CONFLICT (repo_id) DO UPDATE SET snapshot_id = $2, updated_at = now()`,
+		 ON CONFLICT (repo_id) DO UPDATE SET snapshot_id = $2, updated_at = now()
+		 WHERE NOT baselines.pinned`,
 		req.RepoID, id,
 	)
 	if err != nil {
@@ -255,9 +392,39 @@ func (s *Service) ensureBaseline(ctx context.Context, req IngestionRequest) (str
 	return id, nil
 }
 
+// loadSnapshot reads the snapshot stored for a snapshots row. Blobs are
+// named by content ID, which the row's storage_ref holds, not by row ID.
+func (s *Service) loadSnapshot(ctx context.Context, tenantID, snapshotID string) (*graph.Snapshot, error) {
+	var ref, checksum string
+	if err := s.db.QueryRowContext(ctx,
+		`SELECT storage_ref, COALESCE(checksum, '') FROM snapshots WHERE id = $1`, snapshotID,
+	).Scan(&ref, &checksum); err != nil {
+		return nil, fmt.Errorf("get snapshot %s: %w", snapshotID, err)
+	}
+	data, err := s.storage.GetSnapshot(ctx, tenantID, blobID(ref))
+	if err != nil {
+		return nil, err
+	}
+	if err := VerifyChecksum(data, checksum); err != nil {
+		return nil, fmt.Errorf("snapshot %s: %w", snapshotID, err)
+	}
+	var snap graph.Snapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return nil, fmt.Errorf("unmarshal snapshot %s: %w", snapshotID, err)
+	}
+	return &snap, nil
+}
+
+// blobID returns the ID of the blob a storage_ref names.
+func blobID(storageRef string) string {
+	return strings.TrimSuffix(path.Base(storageRef), ".json")
+}
+
 // StoreSnapshot stores a snapshot blob and metadata to storage and database.
+// The row records the blob's checksum, which loads verify against.
 func (s *Service) StoreSnapshot(ctx context.Context, req IngestionRequest, snap *graph.Snapshot, data []byte) (string, error) {
 	storageRef := fmt.Sprintf("snapshots/%s/%s.json", req.TenantID, snap.ID)
+	checksum := Checksum(data)
 	if err := s.storage.PutSnapshot(ctx, req.TenantID, snap.ID, data); err != nil {
 		return "", fmt.Errorf("put snapshot blob: %w", err)
 	}
@@ -266,28 +433,29 @@ func (s *Service) StoreSnapshot(ctx context.Context, req IngestionRequest, snap
 	var err error
 	if req.CommittedAt != nil {
 		err = s.db.QueryRowContext(ctx,
-			`INSERT INTO snapshots (tenant_id, repo_id, commit_sha, branch, node_count, edge_count, package_count, extraction_ms, storage_ref, created_at)
-			 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
-			 ON CONFLICT (repo_id, commit_sha) DO UPDATE SET storage_ref = EXCLUDED.storage_ref, created_at = EXCLUDED.created_at
+			`INSERT INTO snapshots (tenant_id, repo_id, commit_sha, branch, node_count, edge_count, package_count, extraction_ms, storage_ref, size_bytes, checksum, created_at)
+			 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+			 ON CONFLICT (repo_id, commit_sha) DO UPDATE SET storage_ref = EXCLUDED.storage_ref, size_bytes = EXCLUDED.size_bytes, checksum = EXCLUDED.checksum, created_at = EXCLUDED.created_at
 			 RETURNING id`,
 			req.TenantID, req.RepoID, snap.CommitSHA, nilIfEmpty(snap.Branch),
 			snap.Stats.NodeCount, snap.Stats.EdgeCount, snap.Stats.PackageCount, snap.Stats.ExtractionMs,
-			storageRef, *req.CommittedAt,
+			storageRef, len(data), checksum, *req.CommittedAt,
 		).Scan(&id)
 	} else {
 		err = s.db.QueryRowContext(ctx,
-			`INSERT INTO snapshots (tenant_id, repo_id, commit_sha, branch, node_count, edge_count, package_count, extraction_ms, storage_ref)
-			 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
-			 ON CONFLICT (repo_id, commit_sha) DO UPDATE SET storage_ref = EXCLUDED.storage_ref
+			`INSERT INTO snapshots (tenant_id, repo_id, commit_sha, branch, node_count, edge_count, package_count, extraction_ms, storage_ref, size_bytes, checksum)
+			 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+			 ON CONFLICT (repo_id, commit_sha) DO UPDATE SET storage_ref = EXCLUDED.storage_ref, size_bytes = EXCLUDED.size_bytes, checksum = EXCLUDED.checksum
 			 RETURNING id`,
 			req.TenantID, req.RepoID, snap.CommitSHA, nilIfEmpty(snap.Branch),
 			snap.Stats.NodeCount, snap.Stats.EdgeCount, snap.Stats.PackageCount, snap.Stats.ExtractionMs,
-			storageRef,
+			storageRef, len(data), checksum,
 		).Scan(&id)
 	}
 	if err != nil {
 		return "", fmt.Errorf("insert snapshot row: %w", err)
 	}
+	s.recordUsage(ctx, req.TenantID, tenant.Usage{Snapshots: 1, BytesWritten: int64(len(data))})
 	return id, nil
 }
 
@@ -298,25 +466,38 @@ func (s *Service) StoreDelta(ctx context.Context, req IngestionRequest, delta *g
 		return "", fmt.Errorf("put delta blob: %w", err)
 	}
 
+	summary := delta.Summary
+	if summary == nil {
+		summary = graph.SummarizeDelta(delta)
+	}
+	summaryJSON, err := json.Marshal(summary)
+	if err != nil {
+		return "", fmt.Errorf("marshal delta summary: %w", err)
+	}
+
 	var id string
-	err := s.db.QueryRowContext(ctx,
-		`INSERT INTO deltas (tenant_id, repo_id, base_snapshot_id, head_snapshot_id, added_nodes, removed_nodes, added_edges, removed_edges, storage_ref)
-		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
-		 ON CONFLICT (base_snapshot_id, head_snapshot_id) DO UPDATE SET storage_ref = EXCLUDED.storage_ref
+	err = s.db.QueryRowContext(ctx,
+		`INSERT INTO deltas (tenant_id, repo_id, base_snapshot_id, head_snapshot_id, added_nodes, removed_nodes, added_edges, removed_edges, storage_ref, summary, size_bytes)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		 ON CONFLICT (base_snapshot_id, head_snapshot_id) DO UPDATE SET storage_ref = EXCLUDED.storage_ref, summary = EXCLUDED.summary, size_bytes = EXCLUDED.size_bytes
 		 RETURNING id`,
 		req.TenantID, req.RepoID, delta.BaseSnapshotID, delta.HeadSnapshotID,
 		delta.Stats.AddedNodeCount, delta.Stats.RemovedNodeCount,
 		delta.Stats.AddedEdgeCount, delta.Stats.RemovedEdgeCount,
-		storageRef,
+		storageRef, summaryJSON, len(data),
 	).Scan(&id)
 	if err != nil {
 		return "", fmt.Errorf("insert delta row: %w", err)
 	}
+	s.recordUsage(ctx, req.TenantID, tenant.Usage{Deltas: 1, BytesWritten: int64(len(data))})
 	return id, nil
 }
 
-// StoreScore stores a scoring result to the database.
+// StoreScore stores a scoring result to the database, along with the
+// fingerprints of its findings.
 func (s *Service) StoreScore(ctx context.Context, req IngestionRequest, baseSnapshotID, headSnapshotID, deltaID string, result *scoring.ScoreResult) (string, error) {
+	// Uploaded results may come from clients that predate fingerprints.
+	result.AssignFingerprints()
 	breakdownJSON, err := json.Marshal(result.Breakdown)
 	if err != nil {
 		return "", fmt.Errorf("marshal breakdown: %w", err)
@@ -329,38 +510,64 @@ func (s *Service) StoreScore(ctx context.Context, req IngestionRequest, baseSnap
 	if err != nil {
 		return "", fmt.Errorf("marshal suggested actions: %w", err)
 	}
+	configJSON, err := json.Marshal(result.Config())
+	if err != nil {
+		return "", fmt.Errorf("marshal score config: %w", err)
+	}
+	var provenanceJSON []byte
+	if req.Provenance != nil {
+		if provenanceJSON, err = json.Marshal(req.Provenance); err != nil {
+			return "", fmt.Errorf("marshal provenance: %w", err)
+		}
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return "", fmt.Errorf("begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
 
 	var id string
 	if req.CommittedAt != nil {
-		err = s.db.QueryRowContext(ctx,
-			`INSERT INTO scores (tenant_id, repo_id, pr_number, commit_sha, base_snapshot_id, head_snapshot_id, delta_id, total_score, grade, breakdown, hotspots, suggested_actions, created_at)
-			 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+		err = tx.QueryRowContext(ctx,
+			`INSERT INTO scores (tenant_id, repo_id, pr_number, commit_sha, base_snapshot_id, head_snapshot_id, delta_id, total_score, grade, breakdown, hotspots, suggested_actions, config, provenance, created_at)
+			 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15)
 			 RETURNING id`,
 			req.TenantID, req.RepoID, req.PRNumber, req.CommitSHA,
 			baseSnapshotID, headSnapshotID, deltaID,
 			result.TotalScore, result.Grade,
-			breakdownJSON, hotspotsJSON, actionsJSON,
+			breakdownJSON, hotspotsJSON, actionsJSON, configJSON, provenanceJSON,
 			*req.CommittedAt,
 		).Scan(&id)
 	} else {
-		err = s.db.QueryRowContext(ctx,
-			`INSERT INTO scores (tenant_id, repo_id, pr_number, commit_sha, base_snapshot_id, head_snapshot_id, delta_id, total_score, grade, breakdown, hotspots, suggested_actions)
-			 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+		err = tx.QueryRowContext(ctx,
+			`INSERT INTO scores (tenant_id, repo_id, pr_number, commit_sha, base_snapshot_id, head_snapshot_id, delta_id, total_score, grade, breakdown, hotspots, suggested_actions, config, provenance)
+			 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
 			 RETURNING id`,
 			req.TenantID, req.RepoID, req.PRNumber, req.CommitSHA,
 			baseSnapshotID, headSnapshotID, deltaID,
 			result.TotalScore, result.Grade,
-			breakdownJSON, hotspotsJSON, actionsJSON,
+			breakdownJSON, hotspotsJSON, actionsJSON, configJSON, provenanceJSON,
 		).Scan(&id)
 	}
 	if err != nil {
 		return "", fmt.Errorf("insert score row: %w", err)
 	}
+
+	if err := insertFindings(ctx, tx, id, req.RepoID, result); err != nil {
+		return "", err
+	}
+	if err := tx.Commit(); err != nil {
+		return "", fmt.Errorf("commit score: %w", err)
+	}
+	s.recordUsage(ctx, req.TenantID, tenant.Usage{Scores: 1})
 	return id, nil
 }
 
-// UpdateScore updates an existing score row in-place with new scoring results.
+// UpdateScore updates an existing score row in-place with new scoring
+// results and replaces its finding fingerprints.
 func (s *Service) UpdateScore(ctx context.Context, scoreID string, result *scoring.ScoreResult) error {
+	result.AssignFingerprints()
 	breakdownJSON, err := json.Marshal(result.Breakdown)
 	if err != nil {
 		return fmt.Errorf("marshal breakdown: %w", err)
@@ -373,65 +580,60 @@ func (s *Service) UpdateScore(ctx context.Context, scoreID string, result *scori
 	if err != nil {
 		return fmt.Errorf("marshal suggested actions: %w", err)
 	}
+	configJSON, err := json.Marshal(result.Config())
+	if err != nil {
+		return fmt.Errorf("marshal score config: %w", err)
+	}
 
-	_, err = s.db.ExecContext(ctx,
-		`UPDATE scores SET total_score = $1, grade = $2, breakdown = $3, hotspots = $4, suggested_actions = $5
-		 WHERE id = $6`,
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var repoID string
+	err = tx.QueryRowContext(ctx,
+		`UPDATE scores SET total_score = $1, grade = $2, breakdown = $3, hotspots = $4, suggested_actions = $5, config = $6
+		 WHERE id = $7
+		 RETURNING repo_id`,
 		result.TotalScore, result.Grade,
-		breakdownJSON, hotspotsJSON, actionsJSON,
+		breakdownJSON, hotspotsJSON, actionsJSON, configJSON,
 		scoreID,
-	)
+	).Scan(&repoID)
 	if err != nil {
 		return fmt.Errorf("update score row: %w", err)
 	}
-	return nil
-}
-
-// computeDelta calculates the structural difference between two snapshots.
-func computeDelta(base, head *graph.Snapshot) *graph.Delta {
-	delta := &graph.Delta{}
-
-	// Added/removed nodes
-	for key, node := range head.Nodes {
-		if _, exists := base.Nodes[key]; !exists {
-			delta.AddedNodes = append(delta.AddedNodes, *node)
-		}
-	}
-	for key, node := range base.Nodes {
-		if _, exists := head.Nodes[key]; !exists {
-			delta.RemovedNodes = append(delta.RemovedNodes, *node)
-		}
-	}
-
-	// Added/removed edges
-	baseEdges := make(map[string]graph.Edge)
-	for _, e := range base.Edges {
-		baseEdges[e.EdgeKey()] = e
+	if _, err := tx.ExecContext(ctx, `DELETE FROM score_findings WHERE score_id = $1`, scoreID); err != nil {
+		return fmt.Errorf("replace score findings: %w", err)
 	}
-	headEdges := make(map[string]graph.Edge)
-	for _, e := range head.Edges {
-		headEdges[e.EdgeKey()] = e
+	if err := insertFindings(ctx, tx, scoreID, repoID, result); err != nil {
+		return err
 	}
+	return tx.Commit()
+}
 
-	for key, edge := range headEdges {
-		if _, exists := baseEdges[key]; !exists {
-			delta.AddedEdges = append(delta.AddedEdges, edge)
+// insertFindings records the fingerprints of result's findings for scoreID,
+// so later scores can tell new findings from known ones.
+func insertFindings(ctx context.Context, tx *sql.Tx, scoreID, repoID string, result *scoring.ScoreResult) error {
+	var fingerprints, metrics []string
+	for _, mr := range result.Breakdown {
+		for _, ev := range mr.Evidence {
+			fingerprints = append(fingerprints, ev.Fingerprint)
+			metrics = append(metrics, mr.Key)
 		}
 	}
-	for key, edge := range baseEdges {
-		if _, exists := headEdges[key]; !exists {
-			delta.RemovedEdges = append(delta.RemovedEdges, edge)
-		}
+	if len(fingerprints) == 0 {
+		return nil
 	}
-
-	delta.Stats = graph.DeltaStats{
-		AddedNodeCount:   len(delta.AddedNodes),
-		RemovedNodeCount: len(delta.RemovedNodes),
-		AddedEdgeCount:   len(delta.AddedEdges),
-		RemovedEdgeCount: len(delta.RemovedEdges),
+	if _, err := tx.ExecContext(ctx,
+		`INSERT INTO score_findings (score_id, repo_id, fingerprint, metric)
+		 SELECT $1, $2, f.fingerprint, f.metric FROM unnest($3::text[], $4::text[]) AS f(fingerprint, metric)
+		 ON CONFLICT DO NOTHING`,
+		scoreID, repoID, pq.Array(fingerprints), pq.Array(metrics),
+	); err != nil {
+		return fmt.Errorf("insert score findings: %w", err)
 	}
-
-	return delta
+	return nil
 }
 
 func nilIfEmpty(s string) *string {
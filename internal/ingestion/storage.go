@@ -4,9 +4,15 @@ package ingestion
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"strings"
+	"time"
 )
 
 // StorageClient abstracts blob storage for snapshots and deltas.
@@ -15,12 +21,52 @@ type StorageClient interface {
 	GetSnapshot(ctx context.Context, tenantID, snapshotID string) ([]byte, error)
 	PutDelta(ctx context.Context, tenantID, deltaID string, data []byte) error
 	GetDelta(ctx context.Context, tenantID, deltaID string) ([]byte, error)
+	// DeleteSnapshot and DeleteDelta remove a blob. Deleting a blob that
+	// does not exist is not an error.
+	DeleteSnapshot(ctx context.Context, tenantID, snapshotID string) error
+	DeleteDelta(ctx context.Context, tenantID, deltaID string) error
+	// Ping checks that the backend is reachable and usable with the
+	// configured credentials, so misconfiguration surfaces at startup rather
+	// than on the first ingest.
+	Ping(ctx context.Context) error
+}
+
+// URLSigner is implemented by storage backends that can mint time-limited
+// URLs for downloading snapshot blobs directly from object storage.
+type URLSigner interface {
+	SignedSnapshotURL(ctx context.Context, tenantID, snapshotID string, ttl time.Duration) (string, error)
 }
 
 // LocalStorage implements StorageClient using the local filesystem.
 // Useful for development and testing.
+//
+// Blobs are written to a temporary file and renamed into place, so a crash
+// mid-write leaves either the old blob or the new one, never a torn file.
+// Each blob has a {id}.json.sha256 sidecar that reads verify against.
 type LocalStorage struct {
 	BaseDir string
+	// Sync fsyncs each blob and its directory before a write returns, so a
+	// completed write survives power loss. Off by default.
+	Sync bool
+}
+
+// ErrChecksumMismatch is returned when a local blob does not match its
+// recorded checksum.
+var ErrChecksumMismatch = errors.New("blob checksum mismatch")
+
+// Checksum returns the hex SHA-256 of a serialized blob.
+func Checksum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// VerifyChecksum checks data against a recorded checksum. An empty
+// checksum was never recorded and always passes.
+func VerifyChecksum(data []byte, checksum string) error {
+	if checksum != "" && Checksum(data) != checksum {
+		return ErrChecksumMismatch
+	}
+	return nil
 }
 
 // NewLocalStorage creates a LocalStorage rooted at the given directory.
@@ -32,11 +78,93 @@ func (s *LocalStorage) path(tenantID, kind, id string) string {
 	return filepath.Join(s.BaseDir, tenantID, kind, id+".json")
 }
 
+func checksumPath(path string) string {
+	return path + ".sha256"
+}
+
 func (s *LocalStorage) put(path string, data []byte) error {
 	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
 		return fmt.Errorf("create directory: %w", err)
 	}
-	return os.WriteFile(path, data, 0o644)
+
+	// Drop the old checksum first: if we crash before writing the new one,
+	// the blob is read unverified rather than reported as corrupt.
+	sum := Checksum(data)
+	if err := os.Remove(checksumPath(path)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("remove checksum: %w", err)
+	}
+	if err := s.writeAtomic(path, data); err != nil {
+		return err
+	}
+	return s.writeAtomic(checksumPath(path), []byte(sum+"\n"))
+}
+
+// writeAtomic writes data to a temporary file beside path and renames it
+// over path.
+func (s *LocalStorage) writeAtomic(path string, data []byte) error {
+	dir := filepath.Dir(path)
+	f, err := os.CreateTemp(dir, ".tmp-"+filepath.Base(path)+"-*")
+	if err != nil {
+		return fmt.Errorf("create temp file: %w", err)
+	}
+	tmp := f.Name()
+	defer os.Remove(tmp) // no-op once renamed
+
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return fmt.Errorf("write %s: %w", path, err)
+	}
+	if s.Sync {
+		if err := f.Sync(); err != nil {
+			f.Close()
+			return fmt.Errorf("sync %s: %w", path, err)
+		}
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("write %s: %w", path, err)
+	}
+	if err := os.Chmod(tmp, 0o644); err != nil {
+		return fmt.Errorf("chmod %s: %w", path, err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("rename %s: %w", path, err)
+	}
+	if s.Sync {
+		return syncDir(dir)
+	}
+	return nil
+}
+
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return fmt.Errorf("open %s: %w", dir, err)
+	}
+	defer d.Close()
+	if err := d.Sync(); err != nil {
+		return fmt.Errorf("sync %s: %w", dir, err)
+	}
+	return nil
+}
+
+// get reads the blob at path and verifies it against its checksum, if one
+// was recorded. Blobs written before checksums were added have none.
+func (s *LocalStorage) get(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	want, err := os.ReadFile(checksumPath(path))
+	if errors.Is(err, fs.ErrNotExist) {
+		return data, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read checksum: %w", err)
+	}
+	if err := VerifyChecksum(data, strings.TrimSpace(string(want))); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return data, nil
 }
 
 // PutSnapshot stores a snapshot blob.
@@ -46,7 +174,7 @@ func (s *LocalStorage) PutSnapshot(ctx context.Context, tenantID, snapshotID str
 
 // GetSnapshot retrieves a snapshot blob.
 func (s *LocalStorage) GetSnapshot(ctx context.Context, tenantID, snapshotID string) ([]byte, error) {
-	return os.ReadFile(s.path(tenantID, "snapshots", snapshotID))
+	return s.get(s.path(tenantID, "snapshots", snapshotID))
 }
 
 // PutDelta stores a delta blob.
@@ -56,5 +184,38 @@ func (s *LocalStorage) PutDelta(ctx context.Context, tenantID, deltaID string, d
 
 // GetDelta retrieves a delta blob.
 func (s *LocalStorage) GetDelta(ctx context.Context, tenantID, deltaID string) ([]byte, error) {
-	return os.ReadFile(s.path(tenantID, "deltas", deltaID))
+	return s.get(s.path(tenantID, "deltas", deltaID))
+}
+
+// remove deletes the blob at path and its checksum.
+func (s *LocalStorage) remove(path string) error {
+	for _, p := range []string{checksumPath(path), path} {
+		if err := os.Remove(p); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeleteSnapshot removes a snapshot blob.
+func (s *LocalStorage) DeleteSnapshot(ctx context.Context, tenantID, snapshotID string) error {
+	return s.remove(s.path(tenantID, "snapshots", snapshotID))
+}
+
+// DeleteDelta removes a delta blob.
+func (s *LocalStorage) DeleteDelta(ctx context.Context, tenantID, deltaID string) error {
+	return s.remove(s.path(tenantID, "deltas", deltaID))
+}
+
+// Ping checks that BaseDir exists, or can be created, and is writable.
+func (s *LocalStorage) Ping(ctx context.Context) error {
+	if err := os.MkdirAll(s.BaseDir, 0o755); err != nil {
+		return fmt.Errorf("local storage %s: %w", s.BaseDir, err)
+	}
+	f, err := os.CreateTemp(s.BaseDir, ".ping-*")
+	if err != nil {
+		return fmt.Errorf("local storage %s is not writable: %w", s.BaseDir, err)
+	}
+	f.Close()
+	return os.Remove(f.Name())
 }
@@ -2,8 +2,10 @@ package ingestion
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
+	"time"
 
 	gcs "cloud.google.com/go/storage"
 )
@@ -65,3 +67,48 @@ func (s *GCSStorage) PutDelta(ctx context.Context, tenantID, deltaID string, dat
 func (s *GCSStorage) GetDelta(ctx context.Context, tenantID, deltaID string) ([]byte, error) {
 	return s.get(ctx, s.key(tenantID, "deltas", deltaID))
 }
+
+func (s *GCSStorage) delete(ctx context.Context, key string) error {
+	err := s.client.Bucket(s.bucket).Object(key).Delete(ctx)
+	if err != nil && !errors.Is(err, gcs.ErrObjectNotExist) {
+		return fmt.Errorf("gcs delete %s: %w", key, err)
+	}
+	return nil
+}
+
+func (s *GCSStorage) DeleteSnapshot(ctx context.Context, tenantID, snapshotID string) error {
+	return s.delete(ctx, s.key(tenantID, "snapshots", snapshotID))
+}
+
+func (s *GCSStorage) DeleteDelta(ctx context.Context, tenantID, deltaID string) error {
+	return s.delete(ctx, s.key(tenantID, "deltas", deltaID))
+}
+
+// SignedSnapshotURL returns a V4 signed GET URL for a snapshot blob. The
+// client's credentials must be able to sign: a service account key, or a
+// service account with iam.serviceAccounts.signBlob on itself.
+func (s *GCSStorage) SignedSnapshotURL(ctx context.Context, tenantID, snapshotID string, ttl time.Duration) (string, error) {
+	key := s.key(tenantID, "snapshots", snapshotID)
+	url, err := s.client.Bucket(s.bucket).SignedURL(key, &gcs.SignedURLOptions{
+		Scheme:  gcs.SigningSchemeV4,
+		Method:  "GET",
+		Expires: time.Now().Add(ttl),
+	})
+	if err != nil {
+		return "", fmt.Errorf("gcs sign %s: %w", key, err)
+	}
+	return url, nil
+}
+
+// Ping checks that the bucket exists and is readable with the default
+// credentials.
+func (s *GCSStorage) Ping(ctx context.Context) error {
+	_, err := s.client.Bucket(s.bucket).Attrs(ctx)
+	switch {
+	case errors.Is(err, gcs.ErrBucketNotExist):
+		return fmt.Errorf("gcs bucket %q does not exist", s.bucket)
+	case err != nil:
+		return fmt.Errorf("gcs bucket %q: %w (check the service account's storage permissions)", s.bucket, err)
+	}
+	return nil
+}
@@ -3,13 +3,32 @@ package ingestion
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
+	"log"
+	"net/http"
+	"slices"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
 	awsconfig "github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/credentials"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// Multipart upload bounds. S3 rejects parts smaller than 5 MiB (except the
+// last) and larger than 5 GiB.
+const (
+	minPartSize     = 5 << 20
+	maxPartSize     = 5 << 30
+	defaultPartSize = 16 << 20
+
+	defaultS3MaxAttempts = 5
+	maxS3Backoff         = 20 * time.Second
 )
 
 // S3Config holds configuration for the S3 storage backend.
@@ -19,17 +38,61 @@ type S3Config struct {
 	Endpoint  string
 	AccessKey string
 	SecretKey string
+
+	// PartSize is the multipart part size in bytes. Blobs larger than one
+	// part are uploaded in parts. Defaults to 16 MiB and is clamped to the
+	// 5 MiB–5 GiB range S3 accepts.
+	PartSize int64
+	// MaxAttempts bounds how many times a request is tried before a
+	// transient error (throttling, 5xx, timeouts) is returned. Defaults to 5.
+	MaxAttempts int
+	// ServerSideEncryption is "AES256" or "aws:kms". Empty uses the bucket
+	// default.
+	ServerSideEncryption string
+	// SSEKMSKeyID is the KMS key for "aws:kms" encryption. Empty uses the
+	// AWS-managed key.
+	SSEKMSKeyID string
+	// StorageClass is the storage class for new objects, e.g.
+	// INTELLIGENT_TIERING. Empty uses STANDARD.
+	StorageClass string
 }
 
 // S3Storage implements StorageClient using AWS S3 (or S3-compatible stores like MinIO).
 type S3Storage struct {
-	client *s3.Client
-	bucket string
+	client       *s3.Client
+	bucket       string
+	partSize     int64
+	sse          types.ServerSideEncryption
+	sseKMSKeyID  string
+	storageClass types.StorageClass
 }
 
 // NewS3Storage creates an S3-backed StorageClient.
 func NewS3Storage(ctx context.Context, cfg S3Config) (*S3Storage, error) {
-	var opts []func(*awsconfig.LoadOptions) error
+	sse := types.ServerSideEncryption(cfg.ServerSideEncryption)
+	if sse != "" && !slices.Contains(sse.Values(), sse) {
+		return nil, fmt.Errorf("unsupported s3 server-side encryption %q", cfg.ServerSideEncryption)
+	}
+	if cfg.SSEKMSKeyID != "" && sse != types.ServerSideEncryptionAwsKms {
+		return nil, fmt.Errorf("s3 kms key id requires server-side encryption %q", types.ServerSideEncryptionAwsKms)
+	}
+	storageClass := types.StorageClass(cfg.StorageClass)
+	if storageClass != "" && !slices.Contains(storageClass.Values(), storageClass) {
+		return nil, fmt.Errorf("unsupported s3 storage class %q", cfg.StorageClass)
+	}
+
+	maxAttempts := cfg.MaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = defaultS3MaxAttempts
+	}
+	opts := []func(*awsconfig.LoadOptions) error{
+		awsconfig.WithRetryer(func() aws.Retryer {
+			return retry.NewStandard(func(o *retry.StandardOptions) {
+				o.MaxAttempts = maxAttempts
+				o.MaxBackoff = maxS3Backoff
+			})
+		}),
+	}
 	if cfg.Region != "" {
 		opts = append(opts, awsconfig.WithRegion(cfg.Region))
 	}
@@ -53,7 +116,26 @@ func NewS3Storage(ctx context.Context, cfg S3Config) (*S3Storage, error) {
 	}
 
 	client := s3.NewFromConfig(awsCfg, s3Opts...)
-	return &S3Storage{client: client, bucket: cfg.Bucket}, nil
+	return &S3Storage{
+		client:       client,
+		bucket:       cfg.Bucket,
+		partSize:     clampPartSize(cfg.PartSize),
+		sse:          sse,
+		sseKMSKeyID:  cfg.SSEKMSKeyID,
+		storageClass: storageClass,
+	}, nil
+}
+
+func clampPartSize(n int64) int64 {
+	switch {
+	case n <= 0:
+		return defaultPartSize
+	case n < minPartSize:
+		return minPartSize
+	case n > maxPartSize:
+		return maxPartSize
+	}
+	return n
 }
 
 func (s *S3Storage) key(tenantID, kind, id string) string {
@@ -61,18 +143,89 @@ func (s *S3Storage) key(tenantID, kind, id string) string {
 }
 
 func (s *S3Storage) put(ctx context.Context, key string, data []byte) error {
-	_, err := s.client.PutObject(ctx, &s3.PutObjectInput{
-		Bucket:      aws.String(s.bucket),
-		Key:         aws.String(key),
-		Body:        bytes.NewReader(data),
-		ContentType: aws.String("application/json"),
+	if int64(len(data)) > s.partSize {
+		return s.putMultipart(ctx, key, data)
+	}
+	in := &s3.PutObjectInput{
+		Bucket:               aws.String(s.bucket),
+		Key:                  aws.String(key),
+		Body:                 bytes.NewReader(data),
+		ContentType:          aws.String("application/json"),
+		StorageClass:         s.storageClass,
+		ServerSideEncryption: s.sse,
+	}
+	if s.sseKMSKeyID != "" {
+		in.SSEKMSKeyId = aws.String(s.sseKMSKeyID)
+	}
+	if _, err := s.client.PutObject(ctx, in); err != nil {
+		return fmt.Errorf("s3 put %s: %w", key, err)
+	}
+	return nil
+}
+
+// putMultipart uploads data in partSize chunks. Each part is retried on its
+// own, so a transient error late in a large upload doesn't restart it. On
+// failure the upload is aborted so its parts don't linger in the bucket.
+func (s *S3Storage) putMultipart(ctx context.Context, key string, data []byte) error {
+	in := &s3.CreateMultipartUploadInput{
+		Bucket:               aws.String(s.bucket),
+		Key:                  aws.String(key),
+		ContentType:          aws.String("application/json"),
+		StorageClass:         s.storageClass,
+		ServerSideEncryption: s.sse,
+	}
+	if s.sseKMSKeyID != "" {
+		in.SSEKMSKeyId = aws.String(s.sseKMSKeyID)
+	}
+	created, err := s.client.CreateMultipartUpload(ctx, in)
+	if err != nil {
+		return fmt.Errorf("s3 create multipart upload %s: %w", key, err)
+	}
+	uploadID := created.UploadId
+
+	var parts []types.CompletedPart
+	for off, n := int64(0), int32(1); off < int64(len(data)); off, n = off+s.partSize, n+1 {
+		end := min(off+s.partSize, int64(len(data)))
+		out, err := s.client.UploadPart(ctx, &s3.UploadPartInput{
+			Bucket:     aws.String(s.bucket),
+			Key:        aws.String(key),
+			UploadId:   uploadID,
+			PartNumber: aws.Int32(n),
+			Body:       bytes.NewReader(data[off:end]),
+		})
+		if err != nil {
+			s.abortMultipart(ctx, key, uploadID)
+			return fmt.Errorf("s3 upload part %d of %s: %w", n, key, err)
+		}
+		parts = append(parts, types.CompletedPart{ETag: out.ETag, PartNumber: aws.Int32(n)})
+	}
+
+	_, err = s.client.CompleteMultipartUpload(ctx, &s3.CompleteMultipartUploadInput{
+		Bucket:          aws.String(s.bucket),
+		Key:             aws.String(key),
+		UploadId:        uploadID,
+		MultipartUpload: &types.CompletedMultipartUpload{Parts: parts},
 	})
 	if err != nil {
-		return fmt.Errorf("s3 put %s: %w", key, err)
+		s.abortMultipart(ctx, key, uploadID)
+		return fmt.Errorf("s3 complete multipart upload %s: %w", key, err)
 	}
 	return nil
 }
 
+func (s *S3Storage) abortMultipart(ctx context.Context, key string, uploadID *string) {
+	// Abort even if ctx was canceled; that's often why the upload failed.
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 30*time.Second)
+	defer cancel()
+	if _, err := s.client.AbortMultipartUpload(ctx, &s3.AbortMultipartUploadInput{
+		Bucket:   aws.String(s.bucket),
+		Key:      aws.String(key),
+		UploadId: uploadID,
+	}); err != nil {
+		log.Printf("s3 abort multipart upload %s: %v", key, err)
+	}
+}
+
 func (s *S3Storage) get(ctx context.Context, key string) ([]byte, error) {
 	out, err := s.client.GetObject(ctx, &s3.GetObjectInput{
 		Bucket: aws.String(s.bucket),
@@ -100,3 +253,56 @@ func (s *S3Storage) PutDelta(ctx context.Context, tenantID, deltaID string, data
 func (s *S3Storage) GetDelta(ctx context.Context, tenantID, deltaID string) ([]byte, error) {
 	return s.get(ctx, s.key(tenantID, "deltas", deltaID))
 }
+
+// delete removes an object. S3 reports success for keys that do not exist.
+func (s *S3Storage) delete(ctx context.Context, key string) error {
+	_, err := s.client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return fmt.Errorf("s3 delete %s: %w", key, err)
+	}
+	return nil
+}
+
+func (s *S3Storage) DeleteSnapshot(ctx context.Context, tenantID, snapshotID string) error {
+	return s.delete(ctx, s.key(tenantID, "snapshots", snapshotID))
+}
+
+func (s *S3Storage) DeleteDelta(ctx context.Context, tenantID, deltaID string) error {
+	return s.delete(ctx, s.key(tenantID, "deltas", deltaID))
+}
+
+// Ping checks that the bucket exists and the credentials can reach it.
+func (s *S3Storage) Ping(ctx context.Context) error {
+	_, err := s.client.HeadBucket(ctx, &s3.HeadBucketInput{Bucket: aws.String(s.bucket)})
+	if err == nil {
+		return nil
+	}
+	var respErr *awshttp.ResponseError
+	if errors.As(err, &respErr) {
+		switch respErr.HTTPStatusCode() {
+		case http.StatusNotFound:
+			return fmt.Errorf("s3 bucket %q does not exist", s.bucket)
+		case http.StatusForbidden:
+			return fmt.Errorf("access to s3 bucket %q denied; check the credentials and bucket policy", s.bucket)
+		case http.StatusMovedPermanently:
+			return fmt.Errorf("s3 bucket %q is in a different region; set S3_REGION", s.bucket)
+		}
+	}
+	return fmt.Errorf("s3 bucket %q: %w", s.bucket, err)
+}
+
+// SignedSnapshotURL returns a presigned GET URL for a snapshot blob.
+func (s *S3Storage) SignedSnapshotURL(ctx context.Context, tenantID, snapshotID string, ttl time.Duration) (string, error) {
+	key := s.key(tenantID, "snapshots", snapshotID)
+	req, err := s3.NewPresignClient(s.client).PresignGetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	}, s3.WithPresignExpires(ttl))
+	if err != nil {
+		return "", fmt.Errorf("s3 presign %s: %w", key, err)
+	}
+	return req.URL, nil
+}
@@ -0,0 +1,261 @@
+package ingestion
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeS3 is a minimal path-style S3 server: single-part puts, multipart
+// uploads, and gets. failNext makes the next N requests return 503, and
+// failOp makes every request of that operation return 503.
+type fakeS3 struct {
+	mu       sync.Mutex
+	objects  map[string][]byte
+	headers  map[string]http.Header
+	uploads  map[string]map[int][]byte
+	requests []string
+	failNext int
+	failOp   string
+	headCode int // status for HeadBucket; 0 is 200
+}
+
+func newFakeS3(t *testing.T) (*fakeS3, *httptest.Server) {
+	f := &fakeS3{
+		objects: make(map[string][]byte),
+		headers: make(map[string]http.Header),
+		uploads: make(map[string]map[int][]byte),
+	}
+	srv := httptest.NewServer(http.HandlerFunc(f.serve))
+	t.Cleanup(srv.Close)
+	return f, srv
+}
+
+func (f *fakeS3) serve(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	body, _ := io.ReadAll(r.Body)
+	q := r.URL.Query()
+	key := r.URL.Path
+	op := r.Method
+	switch {
+	case q.Has("uploads"):
+		op = "CreateMultipartUpload"
+	case q.Has("partNumber"):
+		op = "UploadPart"
+	case q.Has("uploadId") && r.Method == http.MethodPost:
+		op = "CompleteMultipartUpload"
+	case q.Has("uploadId") && r.Method == http.MethodDelete:
+		op = "AbortMultipartUpload"
+	}
+	f.requests = append(f.requests, op)
+
+	if f.failNext > 0 || op == f.failOp {
+		if f.failNext > 0 {
+			f.failNext--
+		}
+		w.WriteHeader(http.StatusServiceUnavailable)
+		fmt.Fprint(w, `<Error><Code>SlowDown</Code><Message>slow down</Message></Error>`)
+		return
+	}
+
+	switch op {
+	case http.MethodHead:
+		if f.headCode != 0 {
+			w.WriteHeader(f.headCode)
+		}
+	case http.MethodPut:
+		f.objects[key] = body
+		f.headers[key] = r.Header.Clone()
+	case http.MethodGet:
+		data, ok := f.objects[key]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `<Error><Code>NoSuchKey</Code></Error>`)
+			return
+		}
+		w.Write(data)
+	case "CreateMultipartUpload":
+		id := "upload-" + strconv.Itoa(len(f.uploads)+1)
+		f.uploads[id] = make(map[int][]byte)
+		f.headers[key] = r.Header.Clone()
+		fmt.Fprintf(w, `<InitiateMultipartUploadResult><Bucket>b</Bucket><Key>%s</Key><UploadId>%s</UploadId></InitiateMultipartUploadResult>`, key, id)
+	case "UploadPart":
+		n, _ := strconv.Atoi(q.Get("partNumber"))
+		f.uploads[q.Get("uploadId")][n] = body
+		w.Header().Set("ETag", `"etag-`+strconv.Itoa(n)+`"`)
+	case "CompleteMultipartUpload":
+		parts := f.uploads[q.Get("uploadId")]
+		nums := make([]int, 0, len(parts))
+		for n := range parts {
+			nums = append(nums, n)
+		}
+		sort.Ints(nums)
+		var buf bytes.Buffer
+		for _, n := range nums {
+			buf.Write(parts[n])
+		}
+		f.objects[key] = buf.Bytes()
+		delete(f.uploads, q.Get("uploadId"))
+		fmt.Fprintf(w, `<CompleteMultipartUploadResult><Bucket>b</Bucket><Key>%s</Key><ETag>"done"</ETag></CompleteMultipartUploadResult>`, key)
+	case "AbortMultipartUpload":
+		delete(f.uploads, q.Get("uploadId"))
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func newTestS3Storage(t *testing.T, endpoint string, cfg S3Config) *S3Storage {
+	t.Helper()
+	cfg.Bucket = "b"
+	cfg.Region = "us-east-1"
+	cfg.Endpoint = endpoint
+	cfg.AccessKey, cfg.SecretKey = "test", "test"
+	s, err := NewS3Storage(context.Background(), cfg)
+	if err != nil {
+		t.Fatalf("NewS3Storage: %v", err)
+	}
+	return s
+}
+
+func TestS3StoragePutSinglePart(t *testing.T) {
+	f, srv := newFakeS3(t)
+	s := newTestS3Storage(t, srv.URL, S3Config{
+		ServerSideEncryption: "aws:kms",
+		SSEKMSKeyID:          "alias/toposcope",
+		StorageClass:         "INTELLIGENT_TIERING",
+	})
+	ctx := context.Background()
+
+	data := []byte(`{"nodes":{}}`)
+	if err := s.PutSnapshot(ctx, "t1", "snap1", data); err != nil {
+		t.Fatalf("PutSnapshot: %v", err)
+	}
+	got, err := s.GetSnapshot(ctx, "t1", "snap1")
+	if err != nil {
+		t.Fatalf("GetSnapshot: %v", err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Errorf("GetSnapshot = %q, want %q", got, data)
+	}
+
+	h := f.headers["/b/t1/snapshots/snap1.json"]
+	for name, want := range map[string]string{
+		"X-Amz-Server-Side-Encryption":                "aws:kms",
+		"X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id": "alias/toposcope",
+		"X-Amz-Storage-Class":                         "INTELLIGENT_TIERING",
+	} {
+		if got := h.Get(name); got != want {
+			t.Errorf("%s = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestS3StoragePutMultipart(t *testing.T) {
+	f, srv := newFakeS3(t)
+	s := newTestS3Storage(t, srv.URL, S3Config{PartSize: minPartSize, StorageClass: "STANDARD_IA"})
+	ctx := context.Background()
+
+	data := bytes.Repeat([]byte("0123456789abcdef"), (2*minPartSize+1024)/16)
+	if err := s.PutSnapshot(ctx, "t1", "big", data); err != nil {
+		t.Fatalf("PutSnapshot: %v", err)
+	}
+
+	if got := f.objects["/b/t1/snapshots/big.json"]; !bytes.Equal(got, data) {
+		t.Fatalf("stored %d bytes, want %d", len(got), len(data))
+	}
+	want := "CreateMultipartUpload,UploadPart,UploadPart,UploadPart,CompleteMultipartUpload"
+	if got := strings.Join(f.requests, ","); got != want {
+		t.Errorf("requests = %s, want %s", got, want)
+	}
+	if got := f.headers["/b/t1/snapshots/big.json"].Get("X-Amz-Storage-Class"); got != "STANDARD_IA" {
+		t.Errorf("storage class = %q, want STANDARD_IA", got)
+	}
+}
+
+func TestS3StorageRetriesTransientErrors(t *testing.T) {
+	f, srv := newFakeS3(t)
+	s := newTestS3Storage(t, srv.URL, S3Config{MaxAttempts: 3})
+	ctx := context.Background()
+
+	f.failNext = 2
+	if err := s.PutDelta(ctx, "t1", "d1", []byte(`{}`)); err != nil {
+		t.Fatalf("PutDelta after two transient failures: %v", err)
+	}
+
+	f.failNext = 3
+	if err := s.PutDelta(ctx, "t1", "d2", []byte(`{}`)); err == nil {
+		t.Fatal("expected error once attempts are exhausted")
+	}
+}
+
+func TestS3StorageAbortsFailedMultipart(t *testing.T) {
+	f, srv := newFakeS3(t)
+	s := newTestS3Storage(t, srv.URL, S3Config{PartSize: minPartSize, MaxAttempts: 1})
+
+	f.failOp = "UploadPart"
+	data := make([]byte, minPartSize+1)
+	if err := s.PutSnapshot(context.Background(), "t1", "big", data); err == nil {
+		t.Fatal("expected error")
+	}
+	if len(f.uploads) != 0 {
+		t.Errorf("%d multipart uploads left open, want 0", len(f.uploads))
+	}
+	if last := f.requests[len(f.requests)-1]; last != "AbortMultipartUpload" {
+		t.Errorf("last request = %s, want AbortMultipartUpload", last)
+	}
+}
+
+func TestS3StoragePing(t *testing.T) {
+	f, srv := newFakeS3(t)
+	s := newTestS3Storage(t, srv.URL, S3Config{MaxAttempts: 1})
+	ctx := context.Background()
+
+	if err := s.Ping(ctx); err != nil {
+		t.Errorf("Ping: %v", err)
+	}
+	for code, want := range map[int]string{
+		http.StatusNotFound:  "does not exist",
+		http.StatusForbidden: "denied",
+	} {
+		f.headCode = code
+		err := s.Ping(ctx)
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("Ping with HTTP %d = %v, want error containing %q", code, err, want)
+		}
+	}
+}
+
+func TestNewS3StorageValidatesConfig(t *testing.T) {
+	for _, cfg := range []S3Config{
+		{ServerSideEncryption: "rot13"},
+		{StorageClass: "COLD"},
+		{SSEKMSKeyID: "alias/k"},
+		{ServerSideEncryption: "AES256", SSEKMSKeyID: "alias/k"},
+	} {
+		if _, err := NewS3Storage(context.Background(), cfg); err == nil {
+			t.Errorf("NewS3Storage(%+v) succeeded, want error", cfg)
+		}
+	}
+}
+
+func TestClampPartSize(t *testing.T) {
+	for _, tt := range []struct{ in, want int64 }{
+		{0, defaultPartSize},
+		{1 << 20, minPartSize},
+		{64 << 20, 64 << 20},
+		{10 << 30, maxPartSize},
+	} {
+		if got := clampPartSize(tt.in); got != tt.want {
+			t.Errorf("clampPartSize(%d) = %d, want %d", tt.in, got, tt.want)
+		}
+	}
+}
@@ -2,6 +2,7 @@ package ingestion
 
 import (
 	"context"
+	"errors"
 	"os"
 	"path/filepath"
 	"testing"
@@ -66,3 +67,122 @@ func TestLocalStorageGetNotFound(t *testing.T) {
 		t.Error("expected error for nonexistent snapshot")
 	}
 }
+
+func TestLocalStoragePing(t *testing.T) {
+	ctx := context.Background()
+	if err := NewLocalStorage(filepath.Join(t.TempDir(), "new")).Ping(ctx); err != nil {
+		t.Errorf("Ping on creatable dir: %v", err)
+	}
+
+	file := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(file, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := NewLocalStorage(file).Ping(ctx); err == nil {
+		t.Error("Ping on a regular file: expected error")
+	}
+}
+
+func TestLocalStorageAtomicWriteLeavesNoTempFiles(t *testing.T) {
+	dir := t.TempDir()
+	s := &LocalStorage{BaseDir: dir, Sync: true}
+	ctx := context.Background()
+
+	for _, data := range []string{`{"v":1}`, `{"v":2}`} {
+		if err := s.PutSnapshot(ctx, "t1", "snap1", []byte(data)); err != nil {
+			t.Fatalf("PutSnapshot: %v", err)
+		}
+	}
+	got, err := s.GetSnapshot(ctx, "t1", "snap1")
+	if err != nil {
+		t.Fatalf("GetSnapshot: %v", err)
+	}
+	if string(got) != `{"v":2}` {
+		t.Errorf("GetSnapshot = %q, want the second write", got)
+	}
+
+	entries, err := os.ReadDir(filepath.Join(dir, "t1", "snapshots"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	if len(names) != 2 || names[0] != "snap1.json" || names[1] != "snap1.json.sha256" {
+		t.Errorf("directory holds %v, want snap1.json and its checksum", names)
+	}
+}
+
+func TestLocalStorageDetectsCorruption(t *testing.T) {
+	dir := t.TempDir()
+	s := NewLocalStorage(dir)
+	ctx := context.Background()
+
+	if err := s.PutDelta(ctx, "t1", "d1", []byte(`{"added_nodes":[]}`)); err != nil {
+		t.Fatalf("PutDelta: %v", err)
+	}
+	path := filepath.Join(dir, "t1", "deltas", "d1.json")
+	if err := os.WriteFile(path, []byte(`{"added_no`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.GetDelta(ctx, "t1", "d1"); !errors.Is(err, ErrChecksumMismatch) {
+		t.Errorf("GetDelta on truncated blob = %v, want ErrChecksumMismatch", err)
+	}
+}
+
+func TestLocalStorageReadsBlobsWithoutChecksum(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "t1", "snapshots", "old.json")
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(`{}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	got, err := NewLocalStorage(dir).GetSnapshot(context.Background(), "t1", "old")
+	if err != nil || string(got) != `{}` {
+		t.Errorf("GetSnapshot = %q, %v; want {} for a blob written before checksums", got, err)
+	}
+}
+
+func TestVerifyChecksum(t *testing.T) {
+	data := []byte(`{"id":"s1"}`)
+	if err := VerifyChecksum(data, Checksum(data)); err != nil {
+		t.Errorf("VerifyChecksum(matching) = %v", err)
+	}
+	if err := VerifyChecksum(data, ""); err != nil {
+		t.Errorf("VerifyChecksum(unrecorded) = %v", err)
+	}
+	if err := VerifyChecksum([]byte(`{"id":"s2"}`), Checksum(data)); !errors.Is(err, ErrChecksumMismatch) {
+		t.Errorf("VerifyChecksum(changed) = %v, want ErrChecksumMismatch", err)
+	}
+}
+
+func TestLocalStorageDelete(t *testing.T) {
+	dir := t.TempDir()
+	s := NewLocalStorage(dir)
+	ctx := context.Background()
+
+	if err := s.PutSnapshot(ctx, "tenant1", "snap1", []byte(`{}`)); err != nil {
+		t.Fatalf("PutSnapshot: %v", err)
+	}
+	if err := s.DeleteSnapshot(ctx, "tenant1", "snap1"); err != nil {
+		t.Fatalf("DeleteSnapshot: %v", err)
+	}
+	if _, err := s.GetSnapshot(ctx, "tenant1", "snap1"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("GetSnapshot after delete: err = %v, want not exist", err)
+	}
+	entries, _ := os.ReadDir(filepath.Join(dir, "tenant1", "snapshots"))
+	if len(entries) != 0 {
+		t.Errorf("files left after delete: %v", entries)
+	}
+
+	// Deleting again, or a blob that never existed, is not an error.
+	if err := s.DeleteSnapshot(ctx, "tenant1", "snap1"); err != nil {
+		t.Errorf("second DeleteSnapshot: %v", err)
+	}
+	if err := s.DeleteDelta(ctx, "tenant1", "missing"); err != nil {
+		t.Errorf("DeleteDelta of missing blob: %v", err)
+	}
+}
@@ -0,0 +1,207 @@
+package ingestion
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/netip"
+	"syscall"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/toposcope/toposcope/internal/tenant"
+	"github.com/toposcope/toposcope/pkg/scoring"
+)
+
+// ScoreEvent is the event name of score webhook deliveries.
+const ScoreEvent = "score.completed"
+
+// Webhook delivery headers.
+const (
+	headerEvent     = "X-Toposcope-Event"
+	headerDelivery  = "X-Toposcope-Delivery"
+	headerSignature = "X-Toposcope-Signature-256"
+)
+
+// ScorePayload is the body POSTed to a repository's webhooks when a score is
+// stored.
+type ScorePayload struct {
+	Event     string               `json:"event"`
+	RepoID    string               `json:"repo_id"`
+	Repo      string               `json:"repo"`
+	CommitSHA string               `json:"commit_sha"`
+	PRNumber  *int                 `json:"pr_number,omitempty"`
+	ScoreID   string               `json:"score_id"`
+	Score     *scoring.ScoreResult `json:"score"`
+}
+
+// WebhookSender delivers webhook payloads, retrying transient failures.
+type WebhookSender struct {
+	HTTPClient  *http.Client  // default: NewWebhookClient()
+	MaxAttempts int           // default: 3
+	Backoff     time.Duration // delay before the first retry, doubled for each one after (default: 1s)
+}
+
+// NewWebhookClient returns the HTTP client webhooks are delivered with. It
+// refuses to connect to loopback, private, link-local (which includes cloud
+// metadata endpoints), and other non-public addresses. The check runs on
+// every connection, after DNS resolution and for each redirect, so a
+// hostname that resolves or redirects to an internal address is refused
+// too. It ignores proxy settings, which would hide the real destination.
+func NewWebhookClient() *http.Client {
+	dialer := &net.Dialer{Timeout: 10 * time.Second, Control: webhookDialControl}
+	return &http.Client{
+		Timeout: 10 * time.Second,
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: 10 * time.Second,
+			MaxIdleConns:        10,
+			IdleConnTimeout:     90 * time.Second,
+		},
+	}
+}
+
+// errNonPublicAddress is the dial error for addresses BlockedWebhookAddr
+// refuses. Deliveries that hit it aren't retried.
+var errNonPublicAddress = errors.New("not a public address")
+
+func webhookDialControl(network, address string, _ syscall.RawConn) error {
+	ap, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return fmt.Errorf("webhook address %q: %w", address, err)
+	}
+	if BlockedWebhookAddr(ap.Addr()) {
+		return fmt.Errorf("webhook address %s: %w", ap.Addr(), errNonPublicAddress)
+	}
+	return nil
+}
+
+// sharedAddressSpace is 100.64.0.0/10 (RFC 6598), used for carrier-grade NAT
+// and by some clouds for metadata services.
+var sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+// BlockedWebhookAddr reports whether webhooks may not be delivered to addr:
+// loopback, private (RFC 1918 and IPv6 ULA), link-local, shared, unspecified,
+// and multicast addresses.
+func BlockedWebhookAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	return !addr.IsValid() || addr.IsLoopback() || addr.IsPrivate() ||
+		addr.IsLinkLocalUnicast() || addr.IsLinkLocalMulticast() || addr.IsInterfaceLocalMulticast() ||
+		addr.IsMulticast() || addr.IsUnspecified() || sharedAddressSpace.Contains(addr)
+}
+
+// Sign returns the signature header value for body: "sha256=" followed by
+// the hex HMAC-SHA256 of body keyed with secret.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Deliver POSTs body to hook. Network errors, 429, and 5xx responses are
+// retried; any other non-2xx response fails at once. Every attempt carries
+// the same delivery ID, so receivers can drop duplicates.
+func (w *WebhookSender) Deliver(ctx context.Context, hook tenant.Webhook, event, deliveryID string, body []byte) error {
+	client := w.HTTPClient
+	if client == nil {
+		client = NewWebhookClient()
+	}
+	attempts := w.MaxAttempts
+	if attempts <= 0 {
+		attempts = 3
+	}
+	backoff := w.Backoff
+	if backoff <= 0 {
+		backoff = time.Second
+	}
+
+	var lastErr error
+	for attempt := 1; ; attempt++ {
+		retry, err := w.post(ctx, client, hook, event, deliveryID, body)
+		if err == nil {
+			return nil
+		}
+		lastErr = err
+		if !retry || attempt == attempts {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+	return fmt.Errorf("deliver %s to %s: %w", event, hook.URL, lastErr)
+}
+
+// post makes one delivery attempt and reports whether a failure is worth
+// retrying.
+func (w *WebhookSender) post(ctx context.Context, client *http.Client, hook tenant.Webhook, event, deliveryID string, body []byte) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hook.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "toposcope-webhook")
+	req.Header.Set(headerEvent, event)
+	req.Header.Set(headerDelivery, deliveryID)
+	if hook.Secret != "" {
+		req.Header.Set(headerSignature, Sign(hook.Secret, body))
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return !errors.Is(err, errNonPublicAddress), err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+	retry := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+	return retry, fmt.Errorf("endpoint returned %s", resp.Status)
+}
+
+// NotifyScore delivers result to the webhooks configured for req's
+// repository. Failures are logged; the score has been stored.
+func (s *Service) NotifyScore(ctx context.Context, req IngestionRequest, scoreID string, result *scoring.ScoreResult) {
+	if s.Webhooks == nil || s.tenants == nil {
+		return
+	}
+	settings, err := s.tenants.GetRepoSettings(ctx, req.RepoID)
+	if err != nil {
+		log.Printf("score webhooks for %s: load repo settings: %v", req.RepoFullName, err)
+		return
+	}
+	if len(settings.Webhooks) == 0 {
+		return
+	}
+	body, err := json.Marshal(ScorePayload{
+		Event:     ScoreEvent,
+		RepoID:    req.RepoID,
+		Repo:      req.RepoFullName,
+		CommitSHA: req.CommitSHA,
+		PRNumber:  req.PRNumber,
+		ScoreID:   scoreID,
+		Score:     result,
+	})
+	if err != nil {
+		log.Printf("score webhooks for %s: marshal payload: %v", req.RepoFullName, err)
+		return
+	}
+	for _, hook := range settings.Webhooks {
+		if err := s.Webhooks.Deliver(ctx, hook, ScoreEvent, uuid.NewString(), body); err != nil {
+			log.Printf("score webhook for %s: %v", req.RepoFullName, err)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package ingestion
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"testing"
+
+	"github.com/toposcope/toposcope/internal/tenant"
+)
+
+func TestWebhookSenderSignsAndRetries(t *testing.T) {
+	body := []byte(`{"event":"score.completed"}`)
+	var attempts int
+	var deliveries []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		deliveries = append(deliveries, r.Header.Get(headerDelivery))
+		got, _ := io.ReadAll(r.Body)
+		if string(got) != string(body) {
+			t.Errorf("body = %s", got)
+		}
+		if sig := r.Header.Get(headerSignature); sig != Sign("s3cret", body) {
+			t.Errorf("signature = %q", sig)
+		}
+		if r.Header.Get(headerEvent) != ScoreEvent {
+			t.Errorf("event = %q", r.Header.Get(headerEvent))
+		}
+		if attempts < 3 {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	defer srv.Close()
+
+	sender := &WebhookSender{HTTPClient: srv.Client(), Backoff: 1}
+	if err := sender.Deliver(context.Background(), tenant.Webhook{URL: srv.URL, Secret: "s3cret"}, ScoreEvent, "d-1", body); err != nil {
+		t.Fatalf("Deliver: %v", err)
+	}
+	if attempts != 3 {
+		t.Errorf("attempts = %d, want 3", attempts)
+	}
+	for _, id := range deliveries {
+		if id != "d-1" {
+			t.Errorf("delivery IDs = %v, want d-1 on every attempt", deliveries)
+			break
+		}
+	}
+}
+
+func TestWebhookSenderStopsOnClientError(t *testing.T) {
+	var attempts int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if r.Header.Get(headerSignature) != "" {
+			t.Error("unsigned webhook sent a signature")
+		}
+		w.WriteHeader(http.StatusGone)
+	}))
+	defer srv.Close()
+
+	sender := &WebhookSender{HTTPClient: srv.Client(), Backoff: 1}
+	err := sender.Deliver(context.Background(), tenant.Webhook{URL: srv.URL}, ScoreEvent, "d-2", []byte(`{}`))
+	if err == nil || !strings.Contains(err.Error(), "410") {
+		t.Fatalf("Deliver error = %v, want the 410", err)
+	}
+	if attempts != 1 {
+		t.Errorf("attempts = %d, want 1", attempts)
+	}
+}
+
+func TestWebhookSenderRefusesInternalAddresses(t *testing.T) {
+	var attempts int
+	internal := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+	}))
+	defer internal.Close()
+
+	sender := &WebhookSender{Backoff: 1}
+	err := sender.Deliver(context.Background(), tenant.Webhook{URL: internal.URL}, ScoreEvent, "d-3", []byte(`{}`))
+	if err == nil || !strings.Contains(err.Error(), "not a public address") {
+		t.Errorf("Deliver error = %v, want a refused address", err)
+	}
+	if attempts != 0 {
+		t.Errorf("internal endpoint got %d requests, want 0", attempts)
+	}
+}
+
+func TestBlockedWebhookAddr(t *testing.T) {
+	for addr, want := range map[string]bool{
+		"127.0.0.1":       true,
+		"::1":             true,
+		"10.1.2.3":        true,
+		"172.16.0.1":      true,
+		"192.168.1.1":     true,
+		"169.254.169.254": true, // cloud metadata
+		"100.100.100.200": true, // shared address space metadata
+		"fd00:ec2::254":   true, // IPv6 metadata (ULA)
+		"fe80::1":         true,
+		"0.0.0.0":         true,
+		"::ffff:10.0.0.1": true, // IPv4-mapped private
+		"224.0.0.1":       true,
+		"8.8.8.8":         false,
+		"2606:4700::1111": false,
+	} {
+		if got := BlockedWebhookAddr(netip.MustParseAddr(addr)); got != want {
+			t.Errorf("BlockedWebhookAddr(%s) = %v, want %v", addr, got, want)
+		}
+	}
+}
+
+func TestSign(t *testing.T) {
+	// echo -n 'hello' | openssl dgst -sha256 -hmac key
+	want := "sha256=9307b3b915efb5171ff14d8cb55fbcc798c6c0ef1456d66ded1a6aa723a58b7b"
+	if got := Sign("key", []byte("hello")); got != want {
+		t.Errorf("Sign = %s, want %s", got, want)
+	}
+}
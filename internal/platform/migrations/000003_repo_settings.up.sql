@@ -0,0 +1 @@
+ALTER TABLE repositories ADD COLUMN settings JSONB NOT NULL DEFAULT '{}'::jsonb;
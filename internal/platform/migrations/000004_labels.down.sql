@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_scores_labels;
+DROP INDEX IF EXISTS idx_snapshots_labels;
+
+ALTER TABLE scores DROP COLUMN IF EXISTS labels;
+ALTER TABLE snapshots DROP COLUMN IF EXISTS labels;
@@ -0,0 +1,5 @@
+ALTER TABLE snapshots ADD COLUMN labels JSONB NOT NULL DEFAULT '[]'::jsonb;
+ALTER TABLE scores ADD COLUMN labels JSONB NOT NULL DEFAULT '[]'::jsonb;
+
+CREATE INDEX idx_snapshots_labels ON snapshots USING GIN (labels);
+CREATE INDEX idx_scores_labels ON scores USING GIN (labels);
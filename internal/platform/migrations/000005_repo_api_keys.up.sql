@@ -0,0 +1,10 @@
+CREATE TABLE repo_api_keys (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    tenant_id UUID NOT NULL REFERENCES tenants(id),
+    repo_id UUID NOT NULL REFERENCES repositories(id),
+    key_hash TEXT NOT NULL UNIQUE,
+    key_prefix TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX idx_repo_api_keys_repo ON repo_api_keys(repo_id);
@@ -0,0 +1 @@
+ALTER TABLE deltas DROP COLUMN IF EXISTS summary;
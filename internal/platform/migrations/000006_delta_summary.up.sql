@@ -0,0 +1 @@
+ALTER TABLE deltas ADD COLUMN summary JSONB;
@@ -0,0 +1 @@
+ALTER TABLE scores ADD COLUMN config JSONB;
@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS tenant_usage;
+ALTER TABLE deltas DROP COLUMN IF EXISTS size_bytes;
+ALTER TABLE snapshots DROP COLUMN IF EXISTS size_bytes;
+ALTER TABLE tenants DROP COLUMN IF EXISTS quota_monthly_ingestions;
+ALTER TABLE tenants DROP COLUMN IF EXISTS quota_storage_bytes;
@@ -0,0 +1,17 @@
+ALTER TABLE tenants ADD COLUMN quota_storage_bytes BIGINT;
+ALTER TABLE tenants ADD COLUMN quota_monthly_ingestions INTEGER;
+
+ALTER TABLE snapshots ADD COLUMN size_bytes BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE deltas ADD COLUMN size_bytes BIGINT NOT NULL DEFAULT 0;
+
+CREATE TABLE tenant_usage (
+    tenant_id UUID NOT NULL REFERENCES tenants(id),
+    month DATE NOT NULL,
+    ingestions INTEGER NOT NULL DEFAULT 0,
+    snapshots INTEGER NOT NULL DEFAULT 0,
+    deltas INTEGER NOT NULL DEFAULT 0,
+    scores INTEGER NOT NULL DEFAULT 0,
+    bytes_written BIGINT NOT NULL DEFAULT 0,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (tenant_id, month)
+);
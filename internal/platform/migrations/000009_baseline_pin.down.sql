@@ -0,0 +1 @@
+ALTER TABLE baselines DROP COLUMN IF EXISTS pinned;
@@ -0,0 +1 @@
+ALTER TABLE baselines ADD COLUMN pinned BOOLEAN NOT NULL DEFAULT false;
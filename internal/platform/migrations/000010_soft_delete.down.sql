@@ -0,0 +1,12 @@
+DROP INDEX IF EXISTS idx_tenants_deleted;
+DROP INDEX IF EXISTS idx_repositories_deleted;
+
+DROP INDEX IF EXISTS idx_tenants_display_name_no_installation;
+CREATE UNIQUE INDEX idx_tenants_display_name_no_installation
+    ON tenants (display_name) WHERE github_installation_id IS NULL;
+
+DROP INDEX IF EXISTS idx_repositories_live_name;
+ALTER TABLE repositories ADD CONSTRAINT repositories_tenant_id_full_name_key UNIQUE (tenant_id, full_name);
+
+ALTER TABLE repositories DROP COLUMN IF EXISTS deleted_at;
+ALTER TABLE tenants DROP COLUMN IF EXISTS deleted_at;
@@ -0,0 +1,15 @@
+ALTER TABLE tenants ADD COLUMN deleted_at TIMESTAMPTZ;
+ALTER TABLE repositories ADD COLUMN deleted_at TIMESTAMPTZ;
+
+-- Deleted rows keep their names until they are purged, so uniqueness only
+-- applies to live ones.
+ALTER TABLE repositories DROP CONSTRAINT IF EXISTS repositories_tenant_id_full_name_key;
+CREATE UNIQUE INDEX idx_repositories_live_name
+    ON repositories (tenant_id, full_name) WHERE deleted_at IS NULL;
+
+DROP INDEX IF EXISTS idx_tenants_display_name_no_installation;
+CREATE UNIQUE INDEX idx_tenants_display_name_no_installation
+    ON tenants (display_name) WHERE github_installation_id IS NULL AND deleted_at IS NULL;
+
+CREATE INDEX idx_repositories_deleted ON repositories (deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX idx_tenants_deleted ON tenants (deleted_at) WHERE deleted_at IS NOT NULL;
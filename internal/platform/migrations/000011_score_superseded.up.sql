@@ -0,0 +1 @@
+ALTER TABLE scores ADD COLUMN superseded INTEGER NOT NULL DEFAULT 0;
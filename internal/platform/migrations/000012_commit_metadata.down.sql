@@ -0,0 +1,4 @@
+ALTER TABLE snapshots
+    DROP COLUMN IF EXISTS commit_author,
+    DROP COLUMN IF EXISTS commit_message,
+    DROP COLUMN IF EXISTS committed_at;
@@ -0,0 +1,4 @@
+ALTER TABLE snapshots
+    ADD COLUMN commit_author TEXT,
+    ADD COLUMN commit_message TEXT,
+    ADD COLUMN committed_at TIMESTAMPTZ;
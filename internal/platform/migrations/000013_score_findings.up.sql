@@ -0,0 +1,9 @@
+CREATE TABLE score_findings (
+    score_id UUID NOT NULL REFERENCES scores(id),
+    repo_id UUID NOT NULL REFERENCES repositories(id),
+    fingerprint TEXT NOT NULL,
+    metric TEXT NOT NULL,
+    PRIMARY KEY (score_id, fingerprint)
+);
+
+CREATE INDEX idx_score_findings_repo_fingerprint ON score_findings (repo_id, fingerprint);
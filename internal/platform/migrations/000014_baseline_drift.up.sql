@@ -0,0 +1,13 @@
+CREATE TABLE baseline_drift (
+    repo_id UUID PRIMARY KEY REFERENCES repositories(id),
+    baseline_commit_sha TEXT NOT NULL,
+    head_commit_sha TEXT NOT NULL,
+    added_nodes INT NOT NULL DEFAULT 0,
+    removed_nodes INT NOT NULL DEFAULT 0,
+    added_edges INT NOT NULL DEFAULT 0,
+    removed_edges INT NOT NULL DEFAULT 0,
+    drift DOUBLE PRECISION NOT NULL DEFAULT 0,
+    drifted BOOLEAN NOT NULL DEFAULT false,
+    refreshed BOOLEAN NOT NULL DEFAULT false,
+    checked_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS backfill_commits;
+DROP TABLE IF EXISTS backfill_jobs;
@@ -0,0 +1,30 @@
+CREATE TABLE backfill_jobs (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    tenant_id UUID NOT NULL REFERENCES tenants(id),
+    repo_id UUID NOT NULL REFERENCES repositories(id),
+    branch TEXT NOT NULL,
+    status TEXT NOT NULL DEFAULT 'RUNNING',
+    concurrency INT NOT NULL,
+    per_hour INT NOT NULL DEFAULT 0,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+-- At most one active backfill per repository.
+CREATE UNIQUE INDEX idx_backfill_jobs_active ON backfill_jobs (repo_id) WHERE status = 'RUNNING';
+
+CREATE TABLE backfill_commits (
+    job_id UUID NOT NULL REFERENCES backfill_jobs(id),
+    position INT NOT NULL,
+    commit_sha TEXT NOT NULL,
+    status TEXT NOT NULL DEFAULT 'PENDING',
+    attempts INT NOT NULL DEFAULT 0,
+    reused BOOLEAN NOT NULL DEFAULT false,
+    snapshot_id UUID REFERENCES snapshots(id) ON DELETE SET NULL,
+    score_id UUID REFERENCES scores(id) ON DELETE SET NULL,
+    error_message TEXT,
+    started_at TIMESTAMPTZ,
+    PRIMARY KEY (job_id, position)
+);
+
+CREATE INDEX idx_backfill_commits_open ON backfill_commits (job_id, position) WHERE status IN ('PENDING', 'RUNNING');
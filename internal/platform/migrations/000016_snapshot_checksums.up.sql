@@ -0,0 +1,3 @@
+-- SHA-256 of the stored snapshot blob. NULL for snapshots stored before
+-- checksums were recorded.
+ALTER TABLE snapshots ADD COLUMN checksum TEXT;
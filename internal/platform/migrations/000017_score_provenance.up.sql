@@ -0,0 +1,3 @@
+-- Where a signed score was computed: the verified key ID, runner ID, and
+-- CLI version. NULL for unsigned scores.
+ALTER TABLE scores ADD COLUMN provenance JSONB;
@@ -0,0 +1,11 @@
+-- Responses to ingests sent with an Idempotency-Key, so a retried upload gets
+-- the original response instead of storing everything again. A NULL response
+-- marks an ingest still in progress.
+CREATE TABLE ingest_idempotency_keys (
+    repo_id UUID NOT NULL REFERENCES repositories(id),
+    key TEXT NOT NULL,
+    request_hash TEXT NOT NULL,
+    response BYTEA,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (repo_id, key)
+);
@@ -0,0 +1,2 @@
+ALTER TABLE backfill_commits DROP COLUMN IF EXISTS charged;
+ALTER TABLE backfill_jobs DROP COLUMN IF EXISTS error_message;
@@ -0,0 +1,6 @@
+-- Why a backfill job stopped, when it failed.
+ALTER TABLE backfill_jobs ADD COLUMN error_message TEXT;
+
+-- Whether a commit's extraction has been counted toward the tenant's
+-- quota, so retries aren't counted again.
+ALTER TABLE backfill_commits ADD COLUMN charged BOOLEAN NOT NULL DEFAULT false;
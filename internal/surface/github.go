@@ -45,7 +45,7 @@ func NewGitHubPublisher(appID int64, privateKeyPEM []byte) (*GitHubPublisher, er
 
 // PublishCheckRun creates a GitHub Check Run on the given commit.
 func (p *GitHubPublisher) PublishCheckRun(ctx context.Context, installationID int64, owner, repo, headSHA string, data surface.CheckRunData) error {
-	token, err := p.getInstallationToken(ctx, installationID)
+	token, err := p.InstallationToken(ctx, installationID)
 	if err != nil {
 		return fmt.Errorf("get installation token: %w", err)
 	}
@@ -89,8 +89,8 @@ func (p *GitHubPublisher) PublishCheckRun(ctx context.Context, installationID in
 	return nil
 }
 
-// getInstallationToken generates a JWT and exchanges it for an installation access token.
-func (p *GitHubPublisher) getInstallationToken(ctx context.Context, installationID int64) (string, error) {
+// InstallationToken generates a JWT and exchanges it for an installation access token.
+func (p *GitHubPublisher) InstallationToken(ctx context.Context, installationID int64) (string, error) {
 	jwt, err := p.generateJWT()
 	if err != nil {
 		return "", fmt.Errorf("generate JWT: %w", err)
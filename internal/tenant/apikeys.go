@@ -0,0 +1,105 @@
+package tenant
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// RepoKeyPrefix marks per-repository API keys so they can be told apart
+// from the service-wide key.
+const RepoKeyPrefix = "tsk_"
+
+// GenerateRepoAPIKey returns a new random repository API key.
+func GenerateRepoAPIKey() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generate api key: %w", err)
+	}
+	return RepoKeyPrefix + hex.EncodeToString(b), nil
+}
+
+// hashAPIKey returns the stored form of an API key. Only the hash is kept,
+// so a key can be shown once at creation and never recovered.
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// CreateRepoAPIKey issues a new API key scoped to a repository and returns
+// it in plaintext.
+func (s *Service) CreateRepoAPIKey(ctx context.Context, tenantID, repoID string) (string, error) {
+	key, err := GenerateRepoAPIKey()
+	if err != nil {
+		return "", err
+	}
+	_, err = s.db.ExecContext(ctx,
+		`INSERT INTO repo_api_keys (tenant_id, repo_id, key_hash, key_prefix)
+		 VALUES ($1, $2, $3, $4)`,
+		tenantID, repoID, hashAPIKey(key), key[:len(RepoKeyPrefix)+8],
+	)
+	if err != nil {
+		return "", fmt.Errorf("create repo api key: %w", err)
+	}
+	return key, nil
+}
+
+// GetRepositoryByAPIKey returns the repository a key was issued for.
+func (s *Service) GetRepositoryByAPIKey(ctx context.Context, key string) (*Repository, error) {
+	if !strings.HasPrefix(key, RepoKeyPrefix) {
+		return nil, fmt.Errorf("not a repository api key")
+	}
+	r := &Repository{}
+	err := s.db.QueryRowContext(ctx,
+		`SELECT r.id, r.tenant_id, r.github_repo_id, r.full_name, r.default_branch, r.created_at
+		 FROM repo_api_keys k JOIN repositories r ON r.id = k.repo_id
+		 WHERE k.key_hash = $1 AND r.deleted_at IS NULL`,
+		hashAPIKey(key),
+	).Scan(&r.ID, &r.TenantID, &r.GitHubRepoID, &r.FullName, &r.DefaultBranch, &r.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("get repository by api key: %w", err)
+	}
+	return r, nil
+}
+
+// RotateRepoAPIKey issues a new API key for a repository. If revoke is true,
+// the repository's existing keys stop working in the same transaction. It
+// returns the new key and the number of keys revoked.
+func (s *Service) RotateRepoAPIKey(ctx context.Context, repoID string, revoke bool) (string, int, error) {
+	key, err := GenerateRepoAPIKey()
+	if err != nil {
+		return "", 0, err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return "", 0, fmt.Errorf("begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var revoked int64
+	if revoke {
+		res, err := tx.ExecContext(ctx, `DELETE FROM repo_api_keys WHERE repo_id = $1`, repoID)
+		if err != nil {
+			return "", 0, fmt.Errorf("revoke repo api keys: %w", err)
+		}
+		if revoked, err = res.RowsAffected(); err != nil {
+			return "", 0, fmt.Errorf("check rows affected: %w", err)
+		}
+	}
+	_, err = tx.ExecContext(ctx,
+		`INSERT INTO repo_api_keys (tenant_id, repo_id, key_hash, key_prefix)
+		 SELECT tenant_id, id, $2, $3 FROM repositories WHERE id = $1 AND deleted_at IS NULL`,
+		repoID, hashAPIKey(key), key[:len(RepoKeyPrefix)+8],
+	)
+	if err != nil {
+		return "", 0, fmt.Errorf("create repo api key: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return "", 0, fmt.Errorf("commit: %w", err)
+	}
+	return key, int(revoked), nil
+}
@@ -0,0 +1,40 @@
+package tenant
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGenerateRepoAPIKey(t *testing.T) {
+	a, err := GenerateRepoAPIKey()
+	if err != nil {
+		t.Fatalf("GenerateRepoAPIKey: %v", err)
+	}
+	b, err := GenerateRepoAPIKey()
+	if err != nil {
+		t.Fatalf("GenerateRepoAPIKey: %v", err)
+	}
+
+	if !strings.HasPrefix(a, RepoKeyPrefix) {
+		t.Errorf("key %q missing prefix %q", a, RepoKeyPrefix)
+	}
+	if len(a) != len(RepoKeyPrefix)+48 {
+		t.Errorf("key length = %d, want %d", len(a), len(RepoKeyPrefix)+48)
+	}
+	if a == b {
+		t.Error("two generated keys are identical")
+	}
+}
+
+func TestHashAPIKey(t *testing.T) {
+	key := RepoKeyPrefix + "abc"
+	if hashAPIKey(key) != hashAPIKey(key) {
+		t.Error("hash is not deterministic")
+	}
+	if hashAPIKey(key) == hashAPIKey(key+"d") {
+		t.Error("different keys hash equal")
+	}
+	if strings.Contains(hashAPIKey(key), "abc") {
+		t.Error("hash contains the key")
+	}
+}
@@ -0,0 +1,106 @@
+package tenant
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// Deleting a repository or tenant only marks it deleted: it disappears from
+// lookups and listings and its API keys stop working, but its snapshots,
+// deltas, and scores stay until the purge job removes them once the
+// retention window has passed (see ingestion.Service.Purge). Until then a
+// deleted repository can be restored.
+
+// DeleteRepo soft-deletes a repository and revokes its API keys.
+func (s *Service) DeleteRepo(ctx context.Context, repoID string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	res, err := tx.ExecContext(ctx,
+		`UPDATE repositories SET deleted_at = now() WHERE id = $1 AND deleted_at IS NULL`, repoID)
+	if err != nil {
+		return fmt.Errorf("delete repository: %w", err)
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return fmt.Errorf("check rows affected: %w", err)
+	} else if n == 0 {
+		return fmt.Errorf("repository %s not found", repoID)
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM repo_api_keys WHERE repo_id = $1`, repoID); err != nil {
+		return fmt.Errorf("revoke repo api keys: %w", err)
+	}
+	return tx.Commit()
+}
+
+// DeleteRepoByName soft-deletes a tenant's repository by full name. It is a
+// no-op if the repository is not tracked.
+func (s *Service) DeleteRepoByName(ctx context.Context, tenantID, fullName string) error {
+	var repoID string
+	err := s.db.QueryRowContext(ctx,
+		`SELECT id FROM repositories WHERE tenant_id = $1 AND full_name = $2 AND deleted_at IS NULL`,
+		tenantID, fullName,
+	).Scan(&repoID)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("get repository %s: %w", fullName, err)
+	}
+	return s.DeleteRepo(ctx, repoID)
+}
+
+// RestoreRepo undoes DeleteRepo for a repository that has not been purged
+// yet. It fails if a live repository with the same name has been created in
+// the meantime. New API keys must be issued after a restore.
+func (s *Service) RestoreRepo(ctx context.Context, repoID string) (*Repository, error) {
+	r := &Repository{}
+	err := s.db.QueryRowContext(ctx,
+		`UPDATE repositories r SET deleted_at = NULL
+		 FROM tenants t
+		 WHERE r.id = $1 AND r.deleted_at IS NOT NULL AND t.id = r.tenant_id AND t.deleted_at IS NULL
+		 RETURNING r.id, r.tenant_id, r.github_repo_id, r.full_name, r.default_branch, r.created_at`,
+		repoID,
+	).Scan(&r.ID, &r.TenantID, &r.GitHubRepoID, &r.FullName, &r.DefaultBranch, &r.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("restore repository %s: %w", repoID, err)
+	}
+	return r, nil
+}
+
+// DeleteTenant soft-deletes a tenant along with all of its repositories and
+// revokes their API keys. The tenant's installation ID is released so the
+// GitHub App can be installed again as a new tenant.
+func (s *Service) DeleteTenant(ctx context.Context, tenantID string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	res, err := tx.ExecContext(ctx,
+		`UPDATE tenants SET deleted_at = now(), github_installation_id = NULL
+		 WHERE id = $1 AND deleted_at IS NULL`, tenantID)
+	if err != nil {
+		return fmt.Errorf("delete tenant: %w", err)
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return fmt.Errorf("check rows affected: %w", err)
+	} else if n == 0 {
+		return fmt.Errorf("tenant %s not found", tenantID)
+	}
+
+	queries := []string{
+		`UPDATE repositories SET deleted_at = now() WHERE tenant_id = $1 AND deleted_at IS NULL`,
+		`DELETE FROM repo_api_keys WHERE tenant_id = $1`,
+	}
+	for _, q := range queries {
+		if _, err := tx.ExecContext(ctx, q, tenantID); err != nil {
+			return fmt.Errorf("delete tenant cascade: %w", err)
+		}
+	}
+	return tx.Commit()
+}
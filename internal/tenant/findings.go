@@ -0,0 +1,49 @@
+package tenant
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// FindingSighting is the earliest score a finding appeared in.
+type FindingSighting struct {
+	ScoreID   string
+	CommitSHA string
+	PRNumber  *int
+	CreatedAt time.Time
+}
+
+// FirstSeen returns, for each of fingerprints already present in an earlier
+// score of the repository than scoreID, the earliest such score. Scores of
+// the same commit don't count, so re-running an ingestion doesn't make its
+// findings look known.
+func (s *Service) FirstSeen(ctx context.Context, repoID, scoreID string, fingerprints []string) (map[string]FindingSighting, error) {
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT DISTINCT ON (f.fingerprint) f.fingerprint, s.id, s.commit_sha, s.pr_number, s.created_at
+		 FROM score_findings f
+		 JOIN scores s ON s.id = f.score_id
+		 JOIN scores cur ON cur.id = $2
+		 WHERE f.repo_id = $1 AND f.fingerprint = ANY($3)
+		   AND s.id <> cur.id AND s.commit_sha <> cur.commit_sha AND s.created_at <= cur.created_at
+		 ORDER BY f.fingerprint, s.created_at, s.id`,
+		repoID, scoreID, pq.Array(fingerprints),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("query finding history: %w", err)
+	}
+	defer rows.Close()
+
+	seen := make(map[string]FindingSighting)
+	for rows.Next() {
+		var fp string
+		var sg FindingSighting
+		if err := rows.Scan(&fp, &sg.ScoreID, &sg.CommitSHA, &sg.PRNumber, &sg.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scan finding history: %w", err)
+		}
+		seen[fp] = sg
+	}
+	return seen, rows.Err()
+}
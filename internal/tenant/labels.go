@@ -0,0 +1,112 @@
+package tenant
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// maxLabelLength bounds the length of a single label.
+const maxLabelLength = 64
+
+var labelPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._:/-]*$`)
+
+// Labels is a set of user-defined annotations (e.g. "release-1.42") attached
+// to a snapshot or score. It is stored as a JSONB array.
+type Labels []string
+
+// Scan implements sql.Scanner.
+func (l *Labels) Scan(src any) error {
+	var data []byte
+	switch v := src.(type) {
+	case nil:
+		*l = nil
+		return nil
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return fmt.Errorf("scan labels: unsupported type %T", src)
+	}
+	return json.Unmarshal(data, (*[]string)(l))
+}
+
+// Value implements driver.Valuer.
+func (l Labels) Value() (driver.Value, error) {
+	if l == nil {
+		return []byte("[]"), nil
+	}
+	data, err := json.Marshal([]string(l))
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// NormalizeLabels trims, validates, deduplicates, and sorts labels.
+func NormalizeLabels(labels []string) (Labels, error) {
+	seen := make(map[string]bool)
+	result := Labels{}
+	for _, label := range labels {
+		label = strings.TrimSpace(label)
+		if label == "" {
+			continue
+		}
+		if len(label) > maxLabelLength {
+			return nil, fmt.Errorf("label %q exceeds %d characters", label, maxLabelLength)
+		}
+		if !labelPattern.MatchString(label) {
+			return nil, fmt.Errorf("label %q contains invalid characters", label)
+		}
+		if !seen[label] {
+			seen[label] = true
+			result = append(result, label)
+		}
+	}
+	sort.Strings(result)
+	return result, nil
+}
+
+// SetSnapshotLabels replaces the labels on a snapshot.
+func (s *Service) SetSnapshotLabels(ctx context.Context, snapshotID string, labels Labels) error {
+	result, err := s.db.ExecContext(ctx,
+		`UPDATE snapshots SET labels = $1 WHERE id = $2`,
+		labels, snapshotID,
+	)
+	if err != nil {
+		return fmt.Errorf("set snapshot labels: %w", err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("check rows affected: %w", err)
+	}
+	if rows == 0 {
+		return fmt.Errorf("snapshot %s not found", snapshotID)
+	}
+	return nil
+}
+
+// SetScoreLabels replaces the labels on a score of the given repo. A score
+// of another repo is reported as not found.
+func (s *Service) SetScoreLabels(ctx context.Context, repoID, scoreID string, labels Labels) error {
+	result, err := s.db.ExecContext(ctx,
+		`UPDATE scores SET labels = $1 WHERE id = $2 AND repo_id = $3`,
+		labels, scoreID, repoID,
+	)
+	if err != nil {
+		return fmt.Errorf("set score labels: %w", err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("check rows affected: %w", err)
+	}
+	if rows == 0 {
+		return fmt.Errorf("score %s not found", scoreID)
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package tenant
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNormalizeLabels(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   []string
+		want    Labels
+		wantErr bool
+	}{
+		{name: "empty", input: nil, want: Labels{}},
+		{name: "trim dedupe sort", input: []string{" release-1.42", "post-migration", "release-1.42 ", ""}, want: Labels{"post-migration", "release-1.42"}},
+		{name: "namespaced", input: []string{"team:platform/core"}, want: Labels{"team:platform/core"}},
+		{name: "invalid characters", input: []string{"has space"}, wantErr: true},
+		{name: "leading punctuation", input: []string{"-oops"}, wantErr: true},
+		{name: "too long", input: []string{string(make([]byte, 65))}, wantErr: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := NormalizeLabels(tc.input)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got %v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("NormalizeLabels(%q) = %q, want %q", tc.input, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestLabelsScanValue(t *testing.T) {
+	var l Labels
+	if err := l.Scan([]byte(`["a","b"]`)); err != nil {
+		t.Fatalf("Scan: %v", err)
+	}
+	if !reflect.DeepEqual(l, Labels{"a", "b"}) {
+		t.Errorf("Scan = %q, want [a b]", l)
+	}
+
+	if err := l.Scan(nil); err != nil || l != nil {
+		t.Errorf("Scan(nil) = %q, %v; want nil, nil", l, err)
+	}
+
+	if err := l.Scan(42); err == nil {
+		t.Error("expected error scanning int")
+	}
+
+	v, err := Labels(nil).Value()
+	if err != nil {
+		t.Fatalf("Value: %v", err)
+	}
+	if string(v.([]byte)) != "[]" {
+		t.Errorf("Value(nil) = %s, want []", v)
+	}
+}
@@ -0,0 +1,150 @@
+package tenant
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// TenantSummary is a tenant with its repository count, for operator
+// listings.
+type TenantSummary struct {
+	Tenant
+	RepoCount int
+}
+
+// ListTenants returns every tenant, ordered by name.
+func (s *Service) ListTenants(ctx context.Context) ([]TenantSummary, error) {
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT t.id, t.display_name, t.github_installation_id, t.created_at, COUNT(r.id)
+		 FROM tenants t LEFT JOIN repositories r ON r.tenant_id = t.id AND r.deleted_at IS NULL
+		 WHERE t.deleted_at IS NULL
+		 GROUP BY t.id
+		 ORDER BY t.display_name`,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("list tenants: %w", err)
+	}
+	defer rows.Close()
+
+	var tenants []TenantSummary
+	for rows.Next() {
+		var t TenantSummary
+		if err := rows.Scan(&t.ID, &t.DisplayName, &t.GitHubInstallationID, &t.CreatedAt, &t.RepoCount); err != nil {
+			return nil, fmt.Errorf("scan tenant: %w", err)
+		}
+		tenants = append(tenants, t)
+	}
+	return tenants, rows.Err()
+}
+
+// Baseline is a repository's baseline pointer.
+type Baseline struct {
+	RepoID     string
+	SnapshotID string
+	Pinned     bool
+	UpdatedAt  time.Time
+}
+
+// PinBaseline sets the repository's baseline to snapshotID and pins it, so
+// default-branch ingests no longer move it. The snapshot must belong to the
+// repository.
+func (s *Service) PinBaseline(ctx context.Context, repoID, snapshotID string) (*Baseline, error) {
+	b := &Baseline{}
+	err := s.db.QueryRowContext(ctx,
+		`INSERT INTO baselines (repo_id, snapshot_id, pinned)
+		 SELECT repo_id, id, true FROM snapshots WHERE id = $2 AND repo_id = $1
+		 ON CONFLICT (repo_id) DO UPDATE SET snapshot_id = EXCLUDED.snapshot_id, pinned = true, updated_at = now()
+		 RETURNING repo_id, snapshot_id, pinned, updated_at`,
+		repoID, snapshotID,
+	).Scan(&b.RepoID, &b.SnapshotID, &b.Pinned, &b.UpdatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("pin baseline of repo %s to snapshot %s: %w", repoID, snapshotID, err)
+	}
+	return b, nil
+}
+
+// UnpinBaseline lets default-branch ingests move the repository's baseline
+// again. The baseline itself is unchanged.
+func (s *Service) UnpinBaseline(ctx context.Context, repoID string) (*Baseline, error) {
+	b := &Baseline{}
+	err := s.db.QueryRowContext(ctx,
+		`UPDATE baselines SET pinned = false, updated_at = now()
+		 WHERE repo_id = $1
+		 RETURNING repo_id, snapshot_id, pinned, updated_at`,
+		repoID,
+	).Scan(&b.RepoID, &b.SnapshotID, &b.Pinned, &b.UpdatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("unpin baseline of repo %s: %w", repoID, err)
+	}
+	return b, nil
+}
+
+// BaselineDrift is the latest drift check of a repository's baseline
+// against its default branch.
+type BaselineDrift struct {
+	RepoID            string
+	FullName          string
+	BaselineCommitSHA string
+	HeadCommitSHA     string
+	AddedNodes        int
+	RemovedNodes      int
+	AddedEdges        int
+	RemovedEdges      int
+	Drift             float64
+	Drifted           bool
+	Refreshed         bool
+	CheckedAt         time.Time
+}
+
+const baselineDriftColumns = `d.repo_id, r.full_name, d.baseline_commit_sha, d.head_commit_sha,
+		        d.added_nodes, d.removed_nodes, d.added_edges, d.removed_edges,
+		        d.drift, d.drifted, d.refreshed, d.checked_at`
+
+func scanBaselineDrift(row interface{ Scan(...any) error }) (*BaselineDrift, error) {
+	d := &BaselineDrift{}
+	err := row.Scan(&d.RepoID, &d.FullName, &d.BaselineCommitSHA, &d.HeadCommitSHA,
+		&d.AddedNodes, &d.RemovedNodes, &d.AddedEdges, &d.RemovedEdges,
+		&d.Drift, &d.Drifted, &d.Refreshed, &d.CheckedAt)
+	return d, err
+}
+
+// GetBaselineDrift returns the latest drift check of the repository's
+// baseline.
+func (s *Service) GetBaselineDrift(ctx context.Context, repoID string) (*BaselineDrift, error) {
+	d, err := scanBaselineDrift(s.db.QueryRowContext(ctx,
+		`SELECT `+baselineDriftColumns+`
+		 FROM baseline_drift d JOIN repositories r ON r.id = d.repo_id
+		 WHERE d.repo_id = $1`,
+		repoID,
+	))
+	if err != nil {
+		return nil, fmt.Errorf("get baseline drift of repo %s: %w", repoID, err)
+	}
+	return d, nil
+}
+
+// ListDriftedBaselines returns the repositories whose last drift check
+// flagged their baseline and didn't refresh it, most drifted first.
+func (s *Service) ListDriftedBaselines(ctx context.Context) ([]BaselineDrift, error) {
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT `+baselineDriftColumns+`
+		 FROM baseline_drift d JOIN repositories r ON r.id = d.repo_id
+		 WHERE d.drifted AND NOT d.refreshed AND r.deleted_at IS NULL
+		 ORDER BY d.drift DESC, r.full_name`,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("list drifted baselines: %w", err)
+	}
+	defer rows.Close()
+
+	var out []BaselineDrift
+	for rows.Next() {
+		d, err := scanBaselineDrift(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan baseline drift: %w", err)
+		}
+		out = append(out, *d)
+	}
+	return out, rows.Err()
+}
@@ -4,16 +4,23 @@ package tenant
 
 import (
 	"context"
+	"crypto/ed25519"
 	"database/sql"
 	"encoding/json"
 	"fmt"
 	"strings"
 	"time"
+
+	"github.com/toposcope/toposcope/pkg/scoring"
 )
 
 // Service provides tenant and repository management backed by Postgres.
 type Service struct {
 	db *sql.DB
+
+	// DefaultQuota applies to tenants without their own limits. The zero
+	// value enforces nothing.
+	DefaultQuota Quota
 }
 
 // Tenant represents a GitHub App installation (one per org/user).
@@ -60,7 +67,7 @@ func (s *Service) GetTenantByInstallation(ctx context.Context, installationID in
 	t := &Tenant{}
 	err := s.db.QueryRowContext(ctx,
 		`SELECT id, display_name, github_installation_id, credentials_ref, created_at
-		 FROM tenants WHERE github_installation_id = $1`,
+		 FROM tenants WHERE github_installation_id = $1 AND deleted_at IS NULL`,
 		installationID,
 	).Scan(&t.ID, &t.DisplayName, &t.GitHubInstallationID, &t.CredentialsRef, &t.CreatedAt)
 	if err != nil {
@@ -75,7 +82,7 @@ func (s *Service) UpsertRepository(ctx context.Context, tenantID, fullName strin
 	err := s.db.QueryRowContext(ctx,
 		`INSERT INTO repositories (tenant_id, full_name, github_repo_id, default_branch)
 		 VALUES ($1, $2, $3, $4)
-		 ON CONFLICT (tenant_id, full_name) DO UPDATE
+		 ON CONFLICT (tenant_id, full_name) WHERE deleted_at IS NULL DO UPDATE
 		   SET github_repo_id = COALESCE(EXCLUDED.github_repo_id, repositories.github_repo_id),
 		       default_branch = EXCLUDED.default_branch
 		 RETURNING id, tenant_id, github_repo_id, full_name, default_branch, created_at`,
@@ -92,7 +99,7 @@ func (s *Service) GetRepository(ctx context.Context, tenantID, fullName string)
 	r := &Repository{}
 	err := s.db.QueryRowContext(ctx,
 		`SELECT id, tenant_id, github_repo_id, full_name, default_branch, created_at
-		 FROM repositories WHERE tenant_id = $1 AND full_name = $2`,
+		 FROM repositories WHERE tenant_id = $1 AND full_name = $2 AND deleted_at IS NULL`,
 		tenantID, fullName,
 	).Scan(&r.ID, &r.TenantID, &r.GitHubRepoID, &r.FullName, &r.DefaultBranch, &r.CreatedAt)
 	if err != nil {
@@ -101,11 +108,25 @@ func (s *Service) GetRepository(ctx context.Context, tenantID, fullName string)
 	return r, nil
 }
 
+// GetRepositoryByID retrieves a repository by ID.
+func (s *Service) GetRepositoryByID(ctx context.Context, repoID string) (*Repository, error) {
+	r := &Repository{}
+	err := s.db.QueryRowContext(ctx,
+		`SELECT id, tenant_id, github_repo_id, full_name, default_branch, created_at
+		 FROM repositories WHERE id = $1 AND deleted_at IS NULL`,
+		repoID,
+	).Scan(&r.ID, &r.TenantID, &r.GitHubRepoID, &r.FullName, &r.DefaultBranch, &r.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("get repository %s: %w", repoID, err)
+	}
+	return r, nil
+}
+
 // ListRepositories returns all repositories for a tenant.
 func (s *Service) ListRepositories(ctx context.Context, tenantID string) ([]Repository, error) {
 	rows, err := s.db.QueryContext(ctx,
 		`SELECT id, tenant_id, github_repo_id, full_name, default_branch, created_at
-		 FROM repositories WHERE tenant_id = $1 ORDER BY full_name`,
+		 FROM repositories WHERE tenant_id = $1 AND deleted_at IS NULL ORDER BY full_name`,
 		tenantID,
 	)
 	if err != nil {
@@ -139,12 +160,21 @@ type ScoreRow struct {
 	Breakdown        json.RawMessage
 	Hotspots         json.RawMessage
 	SuggestedActions json.RawMessage
+	Config           json.RawMessage // nil for scores stored before configs were recorded
+	Provenance       json.RawMessage // nil unless the score was uploaded signed
+	Labels           Labels
+	Superseded       int // earlier pushes to the same PR pruned in favor of this score
 	CreatedAt        time.Time
 	// Delta stats (from LEFT JOIN with deltas table)
 	AddedNodes   int
 	RemovedNodes int
 	AddedEdges   int
 	RemovedEdges int
+	DeltaSummary json.RawMessage // nil for deltas stored before summaries
+	// Head commit metadata, empty unless fetched from GitHub at ingestion
+	CommitAuthor  string
+	CommitMessage string
+	CommittedAt   *time.Time
 }
 
 // SnapshotRow represents snapshot metadata from the database.
@@ -159,7 +189,13 @@ type SnapshotRow struct {
 	PackageCount int
 	ExtractionMs int
 	StorageRef   string
+	Checksum     string // SHA-256 of the stored blob; empty if not recorded
+	Labels       Labels
 	CreatedAt    time.Time
+	// Commit metadata, empty unless fetched from GitHub at ingestion
+	CommitAuthor  string
+	CommitMessage string
+	CommittedAt   *time.Time
 }
 
 // GetTenantByName looks up a tenant by display name (for non-installation tenants).
@@ -167,7 +203,7 @@ func (s *Service) GetTenantByName(ctx context.Context, name string) (*Tenant, er
 	t := &Tenant{}
 	err := s.db.QueryRowContext(ctx,
 		`SELECT id, display_name, github_installation_id, credentials_ref, created_at
-		 FROM tenants WHERE display_name = $1`,
+		 FROM tenants WHERE display_name = $1 AND deleted_at IS NULL`,
 		name,
 	).Scan(&t.ID, &t.DisplayName, &t.GitHubInstallationID, &t.CredentialsRef, &t.CreatedAt)
 	if err != nil {
@@ -224,7 +260,7 @@ func (s *Service) EnsureTenantAndRepo(ctx context.Context, orgName, repoFullName
 func (s *Service) ListAllRepos(ctx context.Context) ([]Repository, error) {
 	rows, err := s.db.QueryContext(ctx,
 		`SELECT id, tenant_id, github_repo_id, full_name, default_branch, created_at
-		 FROM repositories ORDER BY full_name`,
+		 FROM repositories WHERE deleted_at IS NULL ORDER BY full_name`,
 	)
 	if err != nil {
 		return nil, fmt.Errorf("list all repositories: %w", err)
@@ -243,18 +279,24 @@ func (s *Service) ListAllRepos(ctx context.Context) ([]Repository, error) {
 }
 
 // ListScoresByRepo returns all scores for a repository, newest first.
-// Delta stats are included via a LEFT JOIN with the deltas table.
-func (s *Service) ListScoresByRepo(ctx context.Context, repoID string) ([]ScoreRow, error) {
+// Delta stats are included via a LEFT JOIN with the deltas table. If label is
+// non-empty, only scores carrying that label (directly or on their head
+// snapshot) are returned.
+func (s *Service) ListScoresByRepo(ctx context.Context, repoID, label string) ([]ScoreRow, error) {
 	rows, err := s.db.QueryContext(ctx,
 		`SELECT s.id, s.tenant_id, s.repo_id, s.pr_number, s.commit_sha,
 		        s.base_snapshot_id, s.head_snapshot_id, s.delta_id,
-		        s.total_score, s.grade, s.breakdown, s.hotspots, s.suggested_actions, s.created_at,
+		        s.total_score, s.grade, s.breakdown, s.hotspots, s.suggested_actions, s.config, s.provenance, s.labels, s.superseded, s.created_at,
 		        COALESCE(d.added_nodes, 0), COALESCE(d.removed_nodes, 0),
-		        COALESCE(d.added_edges, 0), COALESCE(d.removed_edges, 0)
+		        COALESCE(d.added_edges, 0), COALESCE(d.removed_edges, 0), d.summary,
+		        COALESCE(hs.commit_author, ''), COALESCE(hs.commit_message, ''), hs.committed_at
 		 FROM scores s
 		 LEFT JOIN deltas d ON d.id = s.delta_id
-		 WHERE s.repo_id = $1 ORDER BY s.created_at DESC`,
-		repoID,
+		 LEFT JOIN snapshots hs ON hs.id = s.head_snapshot_id
+		 WHERE s.repo_id = $1
+		   AND ($2 = '' OR s.labels ? $2 OR hs.labels ? $2)
+		 ORDER BY s.created_at DESC`,
+		repoID, label,
 	)
 	if err != nil {
 		return nil, fmt.Errorf("list scores: %w", err)
@@ -267,8 +309,9 @@ func (s *Service) ListScoresByRepo(ctx context.Context, repoID string) ([]ScoreR
 		if err := rows.Scan(
 			&sc.ID, &sc.TenantID, &sc.RepoID, &sc.PRNumber, &sc.CommitSHA,
 			&sc.BaseSnapshotID, &sc.HeadSnapshotID, &sc.DeltaID,
-			&sc.TotalScore, &sc.Grade, &sc.Breakdown, &sc.Hotspots, &sc.SuggestedActions, &sc.CreatedAt,
-			&sc.AddedNodes, &sc.RemovedNodes, &sc.AddedEdges, &sc.RemovedEdges,
+			&sc.TotalScore, &sc.Grade, &sc.Breakdown, &sc.Hotspots, &sc.SuggestedActions, (*[]byte)(&sc.Config), (*[]byte)(&sc.Provenance), &sc.Labels, &sc.Superseded, &sc.CreatedAt,
+			&sc.AddedNodes, &sc.RemovedNodes, &sc.AddedEdges, &sc.RemovedEdges, (*[]byte)(&sc.DeltaSummary),
+			&sc.CommitAuthor, &sc.CommitMessage, &sc.CommittedAt,
 		); err != nil {
 			return nil, fmt.Errorf("scan score: %w", err)
 		}
@@ -278,18 +321,22 @@ func (s *Service) ListScoresByRepo(ctx context.Context, repoID string) ([]ScoreR
 }
 
 // ListDefaultBranchScores returns scores for default branch pushes (pr_number IS NULL), newest first.
-func (s *Service) ListDefaultBranchScores(ctx context.Context, repoID string) ([]ScoreRow, error) {
+// If label is non-empty, results are filtered as in ListScoresByRepo.
+func (s *Service) ListDefaultBranchScores(ctx context.Context, repoID, label string) ([]ScoreRow, error) {
 	rows, err := s.db.QueryContext(ctx,
 		`SELECT s.id, s.tenant_id, s.repo_id, s.pr_number, s.commit_sha,
 		        s.base_snapshot_id, s.head_snapshot_id, s.delta_id,
-		        s.total_score, s.grade, s.breakdown, s.hotspots, s.suggested_actions, s.created_at,
+		        s.total_score, s.grade, s.breakdown, s.hotspots, s.suggested_actions, s.config, s.provenance, s.labels, s.superseded, s.created_at,
 		        COALESCE(d.added_nodes, 0), COALESCE(d.removed_nodes, 0),
-		        COALESCE(d.added_edges, 0), COALESCE(d.removed_edges, 0)
+		        COALESCE(d.added_edges, 0), COALESCE(d.removed_edges, 0), d.summary,
+		        COALESCE(hs.commit_author, ''), COALESCE(hs.commit_message, ''), hs.committed_at
 		 FROM scores s
 		 LEFT JOIN deltas d ON d.id = s.delta_id
+		 LEFT JOIN snapshots hs ON hs.id = s.head_snapshot_id
 		 WHERE s.repo_id = $1 AND s.pr_number IS NULL
+		   AND ($2 = '' OR s.labels ? $2 OR hs.labels ? $2)
 		 ORDER BY s.created_at DESC`,
-		repoID,
+		repoID, label,
 	)
 	if err != nil {
 		return nil, fmt.Errorf("list default branch scores: %w", err)
@@ -302,8 +349,50 @@ func (s *Service) ListDefaultBranchScores(ctx context.Context, repoID string) ([
 		if err := rows.Scan(
 			&sc.ID, &sc.TenantID, &sc.RepoID, &sc.PRNumber, &sc.CommitSHA,
 			&sc.BaseSnapshotID, &sc.HeadSnapshotID, &sc.DeltaID,
-			&sc.TotalScore, &sc.Grade, &sc.Breakdown, &sc.Hotspots, &sc.SuggestedActions, &sc.CreatedAt,
-			&sc.AddedNodes, &sc.RemovedNodes, &sc.AddedEdges, &sc.RemovedEdges,
+			&sc.TotalScore, &sc.Grade, &sc.Breakdown, &sc.Hotspots, &sc.SuggestedActions, (*[]byte)(&sc.Config), (*[]byte)(&sc.Provenance), &sc.Labels, &sc.Superseded, &sc.CreatedAt,
+			&sc.AddedNodes, &sc.RemovedNodes, &sc.AddedEdges, &sc.RemovedEdges, (*[]byte)(&sc.DeltaSummary),
+			&sc.CommitAuthor, &sc.CommitMessage, &sc.CommittedAt,
+		); err != nil {
+			return nil, fmt.Errorf("scan score: %w", err)
+		}
+		scores = append(scores, sc)
+	}
+	return scores, rows.Err()
+}
+
+// ListBranchScores returns scores whose head snapshot was taken on branch,
+// newest first, for PRs and pushes alike. If label is non-empty, results are
+// filtered as in ListScoresByRepo.
+func (s *Service) ListBranchScores(ctx context.Context, repoID, branch, label string) ([]ScoreRow, error) {
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT s.id, s.tenant_id, s.repo_id, s.pr_number, s.commit_sha,
+		        s.base_snapshot_id, s.head_snapshot_id, s.delta_id,
+		        s.total_score, s.grade, s.breakdown, s.hotspots, s.suggested_actions, s.config, s.provenance, s.labels, s.superseded, s.created_at,
+		        COALESCE(d.added_nodes, 0), COALESCE(d.removed_nodes, 0),
+		        COALESCE(d.added_edges, 0), COALESCE(d.removed_edges, 0), d.summary,
+		        COALESCE(hs.commit_author, ''), COALESCE(hs.commit_message, ''), hs.committed_at
+		 FROM scores s
+		 LEFT JOIN deltas d ON d.id = s.delta_id
+		 JOIN snapshots hs ON hs.id = s.head_snapshot_id
+		 WHERE s.repo_id = $1 AND hs.branch = $2
+		   AND ($3 = '' OR s.labels ? $3 OR hs.labels ? $3)
+		 ORDER BY s.created_at DESC`,
+		repoID, branch, label,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("list branch scores: %w", err)
+	}
+	defer rows.Close()
+
+	var scores []ScoreRow
+	for rows.Next() {
+		var sc ScoreRow
+		if err := rows.Scan(
+			&sc.ID, &sc.TenantID, &sc.RepoID, &sc.PRNumber, &sc.CommitSHA,
+			&sc.BaseSnapshotID, &sc.HeadSnapshotID, &sc.DeltaID,
+			&sc.TotalScore, &sc.Grade, &sc.Breakdown, &sc.Hotspots, &sc.SuggestedActions, (*[]byte)(&sc.Config), (*[]byte)(&sc.Provenance), &sc.Labels, &sc.Superseded, &sc.CreatedAt,
+			&sc.AddedNodes, &sc.RemovedNodes, &sc.AddedEdges, &sc.RemovedEdges, (*[]byte)(&sc.DeltaSummary),
+			&sc.CommitAuthor, &sc.CommitMessage, &sc.CommittedAt,
 		); err != nil {
 			return nil, fmt.Errorf("scan score: %w", err)
 		}
@@ -318,18 +407,21 @@ func (s *Service) GetScoreByID(ctx context.Context, scoreID string) (*ScoreRow,
 	err := s.db.QueryRowContext(ctx,
 		`SELECT s.id, s.tenant_id, s.repo_id, s.pr_number, s.commit_sha,
 		        s.base_snapshot_id, s.head_snapshot_id, s.delta_id,
-		        s.total_score, s.grade, s.breakdown, s.hotspots, s.suggested_actions, s.created_at,
+		        s.total_score, s.grade, s.breakdown, s.hotspots, s.suggested_actions, s.config, s.provenance, s.labels, s.superseded, s.created_at,
 		        COALESCE(d.added_nodes, 0), COALESCE(d.removed_nodes, 0),
-		        COALESCE(d.added_edges, 0), COALESCE(d.removed_edges, 0)
+		        COALESCE(d.added_edges, 0), COALESCE(d.removed_edges, 0), d.summary,
+		        COALESCE(hs.commit_author, ''), COALESCE(hs.commit_message, ''), hs.committed_at
 		 FROM scores s
 		 LEFT JOIN deltas d ON d.id = s.delta_id
+		 LEFT JOIN snapshots hs ON hs.id = s.head_snapshot_id
 		 WHERE s.id = $1`,
 		scoreID,
 	).Scan(
 		&sc.ID, &sc.TenantID, &sc.RepoID, &sc.PRNumber, &sc.CommitSHA,
 		&sc.BaseSnapshotID, &sc.HeadSnapshotID, &sc.DeltaID,
-		&sc.TotalScore, &sc.Grade, &sc.Breakdown, &sc.Hotspots, &sc.SuggestedActions, &sc.CreatedAt,
-		&sc.AddedNodes, &sc.RemovedNodes, &sc.AddedEdges, &sc.RemovedEdges,
+		&sc.TotalScore, &sc.Grade, &sc.Breakdown, &sc.Hotspots, &sc.SuggestedActions, (*[]byte)(&sc.Config), (*[]byte)(&sc.Provenance), &sc.Labels, &sc.Superseded, &sc.CreatedAt,
+		&sc.AddedNodes, &sc.RemovedNodes, &sc.AddedEdges, &sc.RemovedEdges, (*[]byte)(&sc.DeltaSummary),
+		&sc.CommitAuthor, &sc.CommitMessage, &sc.CommittedAt,
 	)
 	if err != nil {
 		return nil, fmt.Errorf("get score %s: %w", scoreID, err)
@@ -343,19 +435,22 @@ func (s *Service) GetScoreByPR(ctx context.Context, repoID string, prNumber int)
 	err := s.db.QueryRowContext(ctx,
 		`SELECT s.id, s.tenant_id, s.repo_id, s.pr_number, s.commit_sha,
 		        s.base_snapshot_id, s.head_snapshot_id, s.delta_id,
-		        s.total_score, s.grade, s.breakdown, s.hotspots, s.suggested_actions, s.created_at,
+		        s.total_score, s.grade, s.breakdown, s.hotspots, s.suggested_actions, s.config, s.provenance, s.labels, s.superseded, s.created_at,
 		        COALESCE(d.added_nodes, 0), COALESCE(d.removed_nodes, 0),
-		        COALESCE(d.added_edges, 0), COALESCE(d.removed_edges, 0)
+		        COALESCE(d.added_edges, 0), COALESCE(d.removed_edges, 0), d.summary,
+		        COALESCE(hs.commit_author, ''), COALESCE(hs.commit_message, ''), hs.committed_at
 		 FROM scores s
 		 LEFT JOIN deltas d ON d.id = s.delta_id
+		 LEFT JOIN snapshots hs ON hs.id = s.head_snapshot_id
 		 WHERE s.repo_id = $1 AND s.pr_number = $2
 		 ORDER BY s.created_at DESC LIMIT 1`,
 		repoID, prNumber,
 	).Scan(
 		&sc.ID, &sc.TenantID, &sc.RepoID, &sc.PRNumber, &sc.CommitSHA,
 		&sc.BaseSnapshotID, &sc.HeadSnapshotID, &sc.DeltaID,
-		&sc.TotalScore, &sc.Grade, &sc.Breakdown, &sc.Hotspots, &sc.SuggestedActions, &sc.CreatedAt,
-		&sc.AddedNodes, &sc.RemovedNodes, &sc.AddedEdges, &sc.RemovedEdges,
+		&sc.TotalScore, &sc.Grade, &sc.Breakdown, &sc.Hotspots, &sc.SuggestedActions, (*[]byte)(&sc.Config), (*[]byte)(&sc.Provenance), &sc.Labels, &sc.Superseded, &sc.CreatedAt,
+		&sc.AddedNodes, &sc.RemovedNodes, &sc.AddedEdges, &sc.RemovedEdges, (*[]byte)(&sc.DeltaSummary),
+		&sc.CommitAuthor, &sc.CommitMessage, &sc.CommittedAt,
 	)
 	if err != nil {
 		return nil, fmt.Errorf("get score for PR %d: %w", prNumber, err)
@@ -382,47 +477,172 @@ func (s *Service) UpdateRepoDefaultBranch(ctx context.Context, repoID, defaultBr
 	return nil
 }
 
-// DeleteRepo deletes a repository and all associated data in FK order within a transaction.
-func (s *Service) DeleteRepo(ctx context.Context, repoID string) error {
-	tx, err := s.db.BeginTx(ctx, nil)
-	if err != nil {
-		return fmt.Errorf("begin transaction: %w", err)
-	}
-	defer func() { _ = tx.Rollback() }()
-
-	// Delete in FK dependency order
-	queries := []string{
-		`DELETE FROM ingestions WHERE repo_id = $1`,
-		`DELETE FROM scores WHERE repo_id = $1`,
-		`DELETE FROM deltas WHERE repo_id = $1`,
-		`DELETE FROM baselines WHERE repo_id = $1`,
-		`DELETE FROM snapshots WHERE repo_id = $1`,
-		`DELETE FROM repositories WHERE id = $1`,
-	}
-
-	for _, q := range queries {
-		if _, err := tx.ExecContext(ctx, q, repoID); err != nil {
-			return fmt.Errorf("delete repo cascade: %w", err)
-		}
-	}
-
-	return tx.Commit()
-}
-
 // GetSnapshotByID returns snapshot metadata by ID.
 func (s *Service) GetSnapshotByID(ctx context.Context, snapshotID string) (*SnapshotRow, error) {
 	sn := &SnapshotRow{}
 	err := s.db.QueryRowContext(ctx,
 		`SELECT id, tenant_id, repo_id, commit_sha, branch,
-		        node_count, edge_count, package_count, extraction_ms, storage_ref, created_at
+		        node_count, edge_count, package_count, extraction_ms, storage_ref, COALESCE(checksum, ''), labels, created_at,
+		        COALESCE(commit_author, ''), COALESCE(commit_message, ''), committed_at
 		 FROM snapshots WHERE id = $1`,
 		snapshotID,
 	).Scan(
 		&sn.ID, &sn.TenantID, &sn.RepoID, &sn.CommitSHA, &sn.Branch,
-		&sn.NodeCount, &sn.EdgeCount, &sn.PackageCount, &sn.ExtractionMs, &sn.StorageRef, &sn.CreatedAt,
+		&sn.NodeCount, &sn.EdgeCount, &sn.PackageCount, &sn.ExtractionMs, &sn.StorageRef, &sn.Checksum, &sn.Labels, &sn.CreatedAt,
+		&sn.CommitAuthor, &sn.CommitMessage, &sn.CommittedAt,
 	)
 	if err != nil {
 		return nil, fmt.Errorf("get snapshot %s: %w", snapshotID, err)
 	}
 	return sn, nil
 }
+
+// GetBaselineSnapshot returns metadata for the repository's current
+// baseline snapshot.
+func (s *Service) GetBaselineSnapshot(ctx context.Context, repoID string) (*SnapshotRow, error) {
+	sn := &SnapshotRow{}
+	err := s.db.QueryRowContext(ctx,
+		`SELECT sn.id, sn.tenant_id, sn.repo_id, sn.commit_sha, sn.branch,
+		        sn.node_count, sn.edge_count, sn.package_count, sn.extraction_ms, sn.storage_ref, sn.labels, sn.created_at,
+		        COALESCE(sn.commit_author, ''), COALESCE(sn.commit_message, ''), sn.committed_at
+		 FROM baselines b JOIN snapshots sn ON sn.id = b.snapshot_id
+		 WHERE b.repo_id = $1`,
+		repoID,
+	).Scan(
+		&sn.ID, &sn.TenantID, &sn.RepoID, &sn.CommitSHA, &sn.Branch,
+		&sn.NodeCount, &sn.EdgeCount, &sn.PackageCount, &sn.ExtractionMs, &sn.StorageRef, &sn.Labels, &sn.CreatedAt,
+		&sn.CommitAuthor, &sn.CommitMessage, &sn.CommittedAt,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("get baseline for repo %s: %w", repoID, err)
+	}
+	return sn, nil
+}
+
+// DeltaRow represents delta metadata from the database.
+type DeltaRow struct {
+	ID             string
+	TenantID       string
+	RepoID         string
+	BaseSnapshotID string
+	HeadSnapshotID string
+	AddedNodes     int
+	RemovedNodes   int
+	AddedEdges     int
+	RemovedEdges   int
+	StorageRef     string
+	CreatedAt      time.Time
+}
+
+// GetDeltaByID returns delta metadata by ID.
+func (s *Service) GetDeltaByID(ctx context.Context, deltaID string) (*DeltaRow, error) {
+	d := &DeltaRow{}
+	err := s.db.QueryRowContext(ctx,
+		`SELECT id, tenant_id, repo_id, base_snapshot_id, head_snapshot_id,
+		        added_nodes, removed_nodes, added_edges, removed_edges, storage_ref, created_at
+		 FROM deltas WHERE id = $1`,
+		deltaID,
+	).Scan(
+		&d.ID, &d.TenantID, &d.RepoID, &d.BaseSnapshotID, &d.HeadSnapshotID,
+		&d.AddedNodes, &d.RemovedNodes, &d.AddedEdges, &d.RemovedEdges, &d.StorageRef, &d.CreatedAt,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("get delta %s: %w", deltaID, err)
+	}
+	return d, nil
+}
+
+// RepoSettings holds per-repository configuration stored alongside the
+// repository record.
+type RepoSettings struct {
+	GradeThresholds *scoring.GradeThresholds `json:"grade_thresholds,omitempty"`
+	Boundaries      []string                 `json:"boundaries,omitempty"` // top-level architectural boundaries
+	FailOn          string                   `json:"fail_on,omitempty"`    // CI gate: fail at this grade or worse
+	// KeepPRScores is how many of a PR's most recent scores to keep; older
+	// ones are pruned. nil uses the server default and 0 keeps every score.
+	KeepPRScores *int `json:"keep_pr_scores,omitempty"`
+	// ExtractionTimeout bounds each hosted extraction of the repository, in
+	// seconds. It can shorten the server's timeout but not extend it. 0
+	// uses the server's.
+	ExtractionTimeout int `json:"extraction_timeout_seconds,omitempty"`
+	// Webhooks receive each score stored for the repository.
+	Webhooks []Webhook `json:"webhooks,omitempty"`
+	// SigningKeys are the ed25519 public keys trusted to sign uploaded
+	// scores, PEM or base64. With RequireSignedScores, scores that aren't
+	// signed by one of them are rejected.
+	SigningKeys         []string `json:"signing_keys,omitempty"`
+	RequireSignedScores bool     `json:"require_signed_scores,omitempty"`
+}
+
+// Webhook is an endpoint that scores are POSTed to. If Secret is set, each
+// delivery is signed with an HMAC-SHA256 of the body.
+type Webhook struct {
+	URL    string `json:"url"`
+	Secret string `json:"secret,omitempty"`
+}
+
+// Grades returns the repository's grade thresholds, or the defaults if none
+// are configured.
+func (rs *RepoSettings) Grades() scoring.GradeThresholds {
+	if rs == nil || rs.GradeThresholds == nil {
+		return scoring.DefaultGradeThresholds()
+	}
+	return *rs.GradeThresholds
+}
+
+// VerifyKeys returns the repository's signing keys, skipping any that don't
+// parse.
+func (rs *RepoSettings) VerifyKeys() []ed25519.PublicKey {
+	if rs == nil {
+		return nil
+	}
+	var keys []ed25519.PublicKey
+	for _, s := range rs.SigningKeys {
+		if key, err := scoring.ParseVerifyKey(s); err == nil {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// GetRepoSettings returns the settings for a repository.
+func (s *Service) GetRepoSettings(ctx context.Context, repoID string) (*RepoSettings, error) {
+	var raw []byte
+	err := s.db.QueryRowContext(ctx,
+		`SELECT settings FROM repositories WHERE id = $1`,
+		repoID,
+	).Scan(&raw)
+	if err != nil {
+		return nil, fmt.Errorf("get repo settings %s: %w", repoID, err)
+	}
+
+	settings := &RepoSettings{}
+	if err := json.Unmarshal(raw, settings); err != nil {
+		return nil, fmt.Errorf("decode repo settings %s: %w", repoID, err)
+	}
+	return settings, nil
+}
+
+// UpdateRepoSettings replaces the settings for a repository.
+func (s *Service) UpdateRepoSettings(ctx context.Context, repoID string, settings *RepoSettings) error {
+	raw, err := json.Marshal(settings)
+	if err != nil {
+		return fmt.Errorf("encode repo settings: %w", err)
+	}
+
+	result, err := s.db.ExecContext(ctx,
+		`UPDATE repositories SET settings = $1 WHERE id = $2`,
+		raw, repoID,
+	)
+	if err != nil {
+		return fmt.Errorf("update repo settings: %w", err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("check rows affected: %w", err)
+	}
+	if rows == 0 {
+		return fmt.Errorf("repository %s not found", repoID)
+	}
+	return nil
+}
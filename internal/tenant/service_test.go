@@ -1,7 +1,10 @@
 package tenant
 
 import (
+	"encoding/json"
 	"testing"
+
+	"github.com/toposcope/toposcope/pkg/scoring"
 )
 
 func TestTenantStruct(t *testing.T) {
@@ -132,3 +135,41 @@ func TestRepositoryOptionalGitHubRepoID(t *testing.T) {
 func ptrInt64(v int64) *int64 {
 	return &v
 }
+
+func TestRepoSettingsGrades(t *testing.T) {
+	var nilSettings *RepoSettings
+	if got := nilSettings.Grades(); got != scoring.DefaultGradeThresholds() {
+		t.Errorf("nil settings Grades() = %+v, want defaults", got)
+	}
+
+	if got := (&RepoSettings{}).Grades(); got != scoring.DefaultGradeThresholds() {
+		t.Errorf("empty settings Grades() = %+v, want defaults", got)
+	}
+
+	custom := scoring.GradeThresholds{A: 1, B: 2, C: 3, D: 4}
+	if got := (&RepoSettings{GradeThresholds: &custom}).Grades(); got != custom {
+		t.Errorf("Grades() = %+v, want %+v", got, custom)
+	}
+}
+
+func TestRepoSettingsKeepPRScoresDistinguishesZero(t *testing.T) {
+	for _, tc := range []struct {
+		raw  string
+		want *int
+	}{
+		{`{}`, nil},
+		{`{"keep_pr_scores":0}`, new(int)},
+	} {
+		var rs RepoSettings
+		if err := json.Unmarshal([]byte(tc.raw), &rs); err != nil {
+			t.Fatal(err)
+		}
+		if (rs.KeepPRScores == nil) != (tc.want == nil) || (rs.KeepPRScores != nil && *rs.KeepPRScores != *tc.want) {
+			t.Errorf("%s: KeepPRScores = %v, want %v", tc.raw, rs.KeepPRScores, tc.want)
+		}
+		out, _ := json.Marshal(rs)
+		if string(out) != tc.raw {
+			t.Errorf("round trip of %s = %s", tc.raw, out)
+		}
+	}
+}
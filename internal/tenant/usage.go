@@ -0,0 +1,161 @@
+package tenant
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrQuotaExceeded is returned by CheckQuota when a tenant is over one of
+// its limits.
+var ErrQuotaExceeded = errors.New("tenant quota exceeded")
+
+// Quota limits a tenant's resource use. Zero fields are unlimited.
+type Quota struct {
+	StorageBytes      int64 `json:"storage_bytes"`
+	MonthlyIngestions int64 `json:"monthly_ingestions"`
+}
+
+// Usage is a tenant's activity in one calendar month (UTC).
+type Usage struct {
+	Month        time.Time `json:"month"`
+	Ingestions   int64     `json:"ingestions"`
+	Snapshots    int64     `json:"snapshots"`
+	Deltas       int64     `json:"deltas"`
+	Scores       int64     `json:"scores"`
+	BytesWritten int64     `json:"bytes_written"`
+}
+
+// Check reports whether a tenant holding storageBytes, with current-month
+// usage u, may start another ingestion.
+func (q Quota) Check(storageBytes int64, u Usage) error {
+	if q.StorageBytes > 0 && storageBytes >= q.StorageBytes {
+		return fmt.Errorf("%w: storing %d of %d bytes", ErrQuotaExceeded, storageBytes, q.StorageBytes)
+	}
+	if q.MonthlyIngestions > 0 && u.Ingestions >= q.MonthlyIngestions {
+		return fmt.Errorf("%w: %d of %d ingestions this month", ErrQuotaExceeded, u.Ingestions, q.MonthlyIngestions)
+	}
+	return nil
+}
+
+// RecordUsage adds u's counts to the tenant's current-month usage. u.Month
+// is ignored.
+func (s *Service) RecordUsage(ctx context.Context, tenantID string, u Usage) error {
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO tenant_usage (tenant_id, month, ingestions, snapshots, deltas, scores, bytes_written)
+		 VALUES ($1, date_trunc('month', now() AT TIME ZONE 'UTC')::date, $2, $3, $4, $5, $6)
+		 ON CONFLICT (tenant_id, month) DO UPDATE SET
+		   ingestions = tenant_usage.ingestions + EXCLUDED.ingestions,
+		   snapshots = tenant_usage.snapshots + EXCLUDED.snapshots,
+		   deltas = tenant_usage.deltas + EXCLUDED.deltas,
+		   scores = tenant_usage.scores + EXCLUDED.scores,
+		   bytes_written = tenant_usage.bytes_written + EXCLUDED.bytes_written,
+		   updated_at = now()`,
+		tenantID, u.Ingestions, u.Snapshots, u.Deltas, u.Scores, u.BytesWritten,
+	)
+	if err != nil {
+		return fmt.Errorf("record usage for tenant %s: %w", tenantID, err)
+	}
+	return nil
+}
+
+// ListUsage returns the tenant's usage for the last months calendar
+// months, newest first. Months without activity are omitted.
+func (s *Service) ListUsage(ctx context.Context, tenantID string, months int) ([]Usage, error) {
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT month, ingestions, snapshots, deltas, scores, bytes_written
+		 FROM tenant_usage
+		 WHERE tenant_id = $1
+		 ORDER BY month DESC
+		 LIMIT $2`,
+		tenantID, months,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("list usage for tenant %s: %w", tenantID, err)
+	}
+	defer rows.Close()
+
+	var usage []Usage
+	for rows.Next() {
+		var u Usage
+		if err := rows.Scan(&u.Month, &u.Ingestions, &u.Snapshots, &u.Deltas, &u.Scores, &u.BytesWritten); err != nil {
+			return nil, fmt.Errorf("scan usage row: %w", err)
+		}
+		usage = append(usage, u)
+	}
+	return usage, rows.Err()
+}
+
+// currentUsage returns the tenant's usage for the current month.
+func (s *Service) currentUsage(ctx context.Context, tenantID string) (Usage, error) {
+	var u Usage
+	err := s.db.QueryRowContext(ctx,
+		`SELECT month, ingestions, snapshots, deltas, scores, bytes_written
+		 FROM tenant_usage
+		 WHERE tenant_id = $1 AND month = date_trunc('month', now() AT TIME ZONE 'UTC')::date`,
+		tenantID,
+	).Scan(&u.Month, &u.Ingestions, &u.Snapshots, &u.Deltas, &u.Scores, &u.BytesWritten)
+	if err != nil && err != sql.ErrNoRows {
+		return Usage{}, fmt.Errorf("get usage for tenant %s: %w", tenantID, err)
+	}
+	return u, nil
+}
+
+// StorageBytes returns the size of the tenant's stored snapshot and delta
+// blobs. Blobs stored before sizes were recorded count as zero.
+func (s *Service) StorageBytes(ctx context.Context, tenantID string) (int64, error) {
+	var total int64
+	err := s.db.QueryRowContext(ctx,
+		`SELECT (SELECT COALESCE(SUM(size_bytes), 0) FROM snapshots WHERE tenant_id = $1)
+		      + (SELECT COALESCE(SUM(size_bytes), 0) FROM deltas WHERE tenant_id = $1)`,
+		tenantID,
+	).Scan(&total)
+	if err != nil {
+		return 0, fmt.Errorf("get storage bytes for tenant %s: %w", tenantID, err)
+	}
+	return total, nil
+}
+
+// GetQuota returns the tenant's quota: its own limits where set, and
+// DefaultQuota otherwise.
+func (s *Service) GetQuota(ctx context.Context, tenantID string) (Quota, error) {
+	var storage, ingestions sql.NullInt64
+	err := s.db.QueryRowContext(ctx,
+		`SELECT quota_storage_bytes, quota_monthly_ingestions FROM tenants WHERE id = $1`,
+		tenantID,
+	).Scan(&storage, &ingestions)
+	if err != nil {
+		return Quota{}, fmt.Errorf("get quota for tenant %s: %w", tenantID, err)
+	}
+	q := s.DefaultQuota
+	if storage.Valid {
+		q.StorageBytes = storage.Int64
+	}
+	if ingestions.Valid {
+		q.MonthlyIngestions = ingestions.Int64
+	}
+	return q, nil
+}
+
+// CheckQuota returns an error wrapping ErrQuotaExceeded if the tenant may
+// not start another ingestion.
+func (s *Service) CheckQuota(ctx context.Context, tenantID string) error {
+	q, err := s.GetQuota(ctx, tenantID)
+	if err != nil {
+		return err
+	}
+	if q == (Quota{}) {
+		return nil
+	}
+	storage, err := s.StorageBytes(ctx, tenantID)
+	if err != nil {
+		return err
+	}
+	u, err := s.currentUsage(ctx, tenantID)
+	if err != nil {
+		return err
+	}
+	return q.Check(storage, u)
+}
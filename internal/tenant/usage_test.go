@@ -0,0 +1,33 @@
+package tenant
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestQuotaCheck(t *testing.T) {
+	tests := []struct {
+		name    string
+		quota   Quota
+		storage int64
+		usage   Usage
+		wantErr bool
+	}{
+		{"unlimited", Quota{}, 1 << 40, Usage{Ingestions: 1 << 20}, false},
+		{"under both", Quota{StorageBytes: 100, MonthlyIngestions: 10}, 99, Usage{Ingestions: 9}, false},
+		{"storage full", Quota{StorageBytes: 100}, 100, Usage{}, true},
+		{"ingestions used up", Quota{MonthlyIngestions: 10}, 0, Usage{Ingestions: 10}, true},
+		{"only ingestions limited", Quota{MonthlyIngestions: 10}, 1 << 40, Usage{Ingestions: 3}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.quota.Check(tt.storage, tt.usage)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Check() = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrQuotaExceeded) {
+				t.Errorf("Check() = %v, want ErrQuotaExceeded", err)
+			}
+		})
+	}
+}
@@ -17,14 +17,18 @@ type Handler struct {
 	webhookSecret []byte
 	tenants       *tenant.Service
 	ingestions    *ingestion.Service
+	dispatcher    ingestion.Dispatcher
 }
 
-// NewHandler creates a new webhook Handler.
-func NewHandler(webhookSecret []byte, tenants *tenant.Service, ingestions *ingestion.Service) *Handler {
+// NewHandler creates a new webhook Handler. Ingestions created from events
+// are handed to dispatcher for processing; if it is nil they are only
+// recorded as queued.
+func NewHandler(webhookSecret []byte, tenants *tenant.Service, ingestions *ingestion.Service, dispatcher ingestion.Dispatcher) *Handler {
 	return &Handler{
 		webhookSecret: webhookSecret,
 		tenants:       tenants,
 		ingestions:    ingestions,
+		dispatcher:    dispatcher,
 	}
 }
 
@@ -106,7 +110,15 @@ func (h *Handler) handleInstallation(ctx context.Context, e *InstallationEvent)
 		}
 		log.Printf("created tenant for installation %d (%s)", e.Installation.ID, e.Installation.Account.Login)
 	case "deleted":
-		log.Printf("installation %d deleted, tenant soft-delete not yet implemented", e.Installation.ID)
+		t, err := h.tenants.GetTenantByInstallation(ctx, e.Installation.ID)
+		if err != nil {
+			log.Printf("installation %d deleted but has no tenant: %v", e.Installation.ID, err)
+			return nil
+		}
+		if err := h.tenants.DeleteTenant(ctx, t.ID); err != nil {
+			return fmt.Errorf("delete tenant for installation %d: %w", e.Installation.ID, err)
+		}
+		log.Printf("deleted tenant %s for installation %d; its data is purged after the retention window", t.ID, e.Installation.ID)
 	}
 	return nil
 }
@@ -126,9 +138,11 @@ func (h *Handler) handleInstallationRepositories(ctx context.Context, e *Install
 		log.Printf("added repository %s for tenant %s", repo.FullName, t.ID)
 	}
 
-	// Removed repos: log only for now (soft-delete not yet implemented)
 	for _, repo := range e.RepositoriesRemoved {
-		log.Printf("repository %s removed from installation %d (no-op)", repo.FullName, e.Installation.ID)
+		if err := h.tenants.DeleteRepoByName(ctx, t.ID, repo.FullName); err != nil {
+			return fmt.Errorf("delete repository %s: %w", repo.FullName, err)
+		}
+		log.Printf("deleted repository %s for tenant %s", repo.FullName, t.ID)
 	}
 
 	return nil
@@ -161,8 +175,8 @@ func (h *Handler) handlePullRequest(ctx context.Context, e *PullRequestEvent) er
 		InstallationID: e.Installation.ID,
 	}
 
-	if _, err := h.ingestions.CreateIngestion(ctx, req); err != nil {
-		return fmt.Errorf("create ingestion: %w", err)
+	if err := h.enqueue(ctx, req); err != nil {
+		return err
 	}
 
 	log.Printf("enqueued ingestion for PR #%d on %s (commit %s)", e.Number, e.Repository.FullName, e.PullRequest.Head.SHA)
@@ -194,10 +208,26 @@ func (h *Handler) handlePush(ctx context.Context, e *PushEvent) error {
 		InstallationID: e.Installation.ID,
 	}
 
-	if _, err := h.ingestions.CreateIngestion(ctx, req); err != nil {
-		return fmt.Errorf("create ingestion: %w", err)
+	if err := h.enqueue(ctx, req); err != nil {
+		return err
 	}
 
 	log.Printf("enqueued baseline ingestion for push to %s on %s (commit %s)", e.Repository.DefaultBranch, e.Repository.FullName, e.After)
 	return nil
 }
+
+// enqueue records the ingestion and dispatches it for processing. The row is
+// created first so a failed dispatch still leaves a QUEUED ingestion behind;
+// redelivering the event is safe because ingestions are idempotent.
+func (h *Handler) enqueue(ctx context.Context, req ingestion.IngestionRequest) error {
+	if _, err := h.ingestions.CreateIngestion(ctx, req); err != nil {
+		return fmt.Errorf("create ingestion: %w", err)
+	}
+	if h.dispatcher == nil {
+		return nil
+	}
+	if err := h.dispatcher.Dispatch(ctx, req); err != nil {
+		return fmt.Errorf("dispatch ingestion: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,281 @@
+// Package bundle reads and writes offline bundles: gzipped tar archives of
+// snapshots, deltas, and scores for a range of commits. Bundles carry results
+// from CI runners that cannot reach the platform to one that can.
+//
+// An archive holds a manifest.json followed by snapshots/{sha}.json,
+// deltas/{base}_{head}.json, and scores/{base}_{head}.json files.
+package bundle
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/toposcope/toposcope/pkg/graph"
+	"github.com/toposcope/toposcope/pkg/scoring"
+)
+
+// FormatVersion is the bundle layout written by Write. Read rejects other
+// versions.
+const FormatVersion = 1
+
+const manifestName = "manifest.json"
+
+// Read's decompression limits. An archive can expand to far more than its
+// compressed size, so each file and the archive as a whole are capped.
+var (
+	maxEntryBytes int64 = 1 << 30
+	maxTotalBytes int64 = 4 << 30
+)
+
+// Commit is a snapshot of one commit.
+type Commit struct {
+	SHA         string
+	CommittedAt string // RFC3339, optional
+	Snapshot    *graph.Snapshot
+}
+
+// Change is a scored change between two commits in the bundle.
+type Change struct {
+	BaseSHA     string
+	HeadSHA     string
+	Delta       *graph.Delta
+	Score       *scoring.ScoreResult // optional
+	SignedScore *scoring.SignedScore // optional; signed in place of Score
+}
+
+// Bundle is the decoded contents of an archive.
+type Bundle struct {
+	Repo          string // owner/name, optional
+	DefaultBranch string
+	CreatedAt     time.Time
+	Commits       []Commit
+	Changes       []Change
+}
+
+type manifest struct {
+	Version       int              `json:"version"`
+	Repo          string           `json:"repo,omitempty"`
+	DefaultBranch string           `json:"default_branch,omitempty"`
+	CreatedAt     time.Time        `json:"created_at"`
+	Commits       []manifestCommit `json:"commits"`
+	Changes       []manifestChange `json:"changes"`
+}
+
+type manifestCommit struct {
+	SHA         string `json:"sha"`
+	CommittedAt string `json:"committed_at,omitempty"`
+	File        string `json:"file"`
+}
+
+type manifestChange struct {
+	BaseSHA   string `json:"base_sha"`
+	HeadSHA   string `json:"head_sha"`
+	DeltaFile string `json:"delta_file"`
+	ScoreFile string `json:"score_file,omitempty"`
+	// SignedScoreFile holds the change's signed score, if it has one.
+	SignedScoreFile string `json:"signed_score_file,omitempty"`
+}
+
+// Write encodes b as a gzipped tar archive. Every change must refer to
+// commits in the bundle.
+func Write(w io.Writer, b *Bundle) error {
+	if err := b.validate(); err != nil {
+		return err
+	}
+
+	m := manifest{
+		Version:       FormatVersion,
+		Repo:          b.Repo,
+		DefaultBranch: b.DefaultBranch,
+		CreatedAt:     b.CreatedAt,
+		Commits:       []manifestCommit{},
+		Changes:       []manifestChange{},
+	}
+	files := make(map[string]any)
+	var order []string
+	add := func(name string, v any) {
+		files[name] = v
+		order = append(order, name)
+	}
+	for _, c := range b.Commits {
+		name := "snapshots/" + c.SHA + ".json"
+		m.Commits = append(m.Commits, manifestCommit{SHA: c.SHA, CommittedAt: c.CommittedAt, File: name})
+		add(name, c.Snapshot)
+	}
+	for _, c := range b.Changes {
+		id := c.BaseSHA + "_" + c.HeadSHA
+		mc := manifestChange{BaseSHA: c.BaseSHA, HeadSHA: c.HeadSHA, DeltaFile: "deltas/" + id + ".json"}
+		add(mc.DeltaFile, c.Delta)
+		if c.Score != nil {
+			mc.ScoreFile = "scores/" + id + ".json"
+			add(mc.ScoreFile, c.Score)
+		}
+		if c.SignedScore != nil {
+			mc.SignedScoreFile = "scores/" + id + ".signed.json"
+			add(mc.SignedScoreFile, c.SignedScore)
+		}
+		m.Changes = append(m.Changes, mc)
+	}
+
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	if err := writeFile(tw, manifestName, m, b.CreatedAt); err != nil {
+		return err
+	}
+	for _, name := range order {
+		if err := writeFile(tw, name, files[name], b.CreatedAt); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("closing tar: %w", err)
+	}
+	return gz.Close()
+}
+
+func writeFile(tw *tar.Writer, name string, v any, modTime time.Time) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("encoding %s: %w", name, err)
+	}
+	hdr := &tar.Header{Name: name, Mode: 0o644, Size: int64(len(data)), ModTime: modTime, Typeflag: tar.TypeReg}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return fmt.Errorf("writing %s: %w", name, err)
+	}
+	if _, err := tw.Write(data); err != nil {
+		return fmt.Errorf("writing %s: %w", name, err)
+	}
+	return nil
+}
+
+// Read decodes a gzipped tar archive written by Write.
+func Read(r io.Reader) (*Bundle, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("not a gzip archive: %w", err)
+	}
+	defer gz.Close()
+
+	files := make(map[string][]byte)
+	var total int64
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading archive: %w", err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		if hdr.Size > maxEntryBytes {
+			return nil, fmt.Errorf("%s is larger than %d bytes", hdr.Name, maxEntryBytes)
+		}
+		data, err := io.ReadAll(io.LimitReader(tr, maxEntryBytes+1))
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %w", hdr.Name, err)
+		}
+		if int64(len(data)) > maxEntryBytes {
+			return nil, fmt.Errorf("%s is larger than %d bytes", hdr.Name, maxEntryBytes)
+		}
+		if total += int64(len(data)); total > maxTotalBytes {
+			return nil, fmt.Errorf("archive expands to more than %d bytes", maxTotalBytes)
+		}
+		files[hdr.Name] = data
+	}
+
+	data, ok := files[manifestName]
+	if !ok {
+		return nil, fmt.Errorf("archive has no %s", manifestName)
+	}
+	var m manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("decoding %s: %w", manifestName, err)
+	}
+	if m.Version != FormatVersion {
+		return nil, fmt.Errorf("unsupported bundle version %d (want %d)", m.Version, FormatVersion)
+	}
+
+	decode := func(name string, v any) error {
+		data, ok := files[name]
+		if !ok {
+			return fmt.Errorf("archive is missing %s", name)
+		}
+		if err := json.Unmarshal(data, v); err != nil {
+			return fmt.Errorf("decoding %s: %w", name, err)
+		}
+		return nil
+	}
+
+	b := &Bundle{Repo: m.Repo, DefaultBranch: m.DefaultBranch, CreatedAt: m.CreatedAt}
+	for _, mc := range m.Commits {
+		var snap graph.Snapshot
+		if err := decode(mc.File, &snap); err != nil {
+			return nil, err
+		}
+		b.Commits = append(b.Commits, Commit{SHA: mc.SHA, CommittedAt: mc.CommittedAt, Snapshot: &snap})
+	}
+	for _, mc := range m.Changes {
+		c := Change{BaseSHA: mc.BaseSHA, HeadSHA: mc.HeadSHA, Delta: &graph.Delta{}}
+		if err := decode(mc.DeltaFile, c.Delta); err != nil {
+			return nil, err
+		}
+		if mc.ScoreFile != "" {
+			c.Score = &scoring.ScoreResult{}
+			if err := decode(mc.ScoreFile, c.Score); err != nil {
+				return nil, err
+			}
+		}
+		if mc.SignedScoreFile != "" {
+			c.SignedScore = &scoring.SignedScore{}
+			if err := decode(mc.SignedScoreFile, c.SignedScore); err != nil {
+				return nil, err
+			}
+		}
+		b.Changes = append(b.Changes, c)
+	}
+
+	if err := b.validate(); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// Commit returns the commit with the given SHA, or nil.
+func (b *Bundle) Commit(sha string) *Commit {
+	for i := range b.Commits {
+		if b.Commits[i].SHA == sha {
+			return &b.Commits[i]
+		}
+	}
+	return nil
+}
+
+func (b *Bundle) validate() error {
+	seen := make(map[string]bool, len(b.Commits))
+	for _, c := range b.Commits {
+		if c.SHA == "" || c.Snapshot == nil {
+			return fmt.Errorf("commit %q has no snapshot", c.SHA)
+		}
+		if seen[c.SHA] {
+			return fmt.Errorf("commit %s appears twice", c.SHA)
+		}
+		seen[c.SHA] = true
+	}
+	for _, c := range b.Changes {
+		if !seen[c.BaseSHA] || !seen[c.HeadSHA] {
+			return fmt.Errorf("change %s..%s refers to a commit not in the bundle", c.BaseSHA, c.HeadSHA)
+		}
+		if c.Delta == nil {
+			return fmt.Errorf("change %s..%s has no delta", c.BaseSHA, c.HeadSHA)
+		}
+	}
+	return nil
+}
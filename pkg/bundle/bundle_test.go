@@ -0,0 +1,135 @@
+package bundle
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/toposcope/toposcope/pkg/graph"
+	"github.com/toposcope/toposcope/pkg/scoring"
+)
+
+func testBundle() *Bundle {
+	base := &graph.Snapshot{ID: "s1", CommitSHA: "aaa", Nodes: map[string]*graph.Node{"//a:lib": {Key: "//a:lib"}}}
+	head := &graph.Snapshot{ID: "s2", CommitSHA: "bbb", Nodes: map[string]*graph.Node{
+		"//a:lib": {Key: "//a:lib"},
+		"//b:lib": {Key: "//b:lib"},
+	}}
+	return &Bundle{
+		Repo:          "acme/mono",
+		DefaultBranch: "main",
+		CreatedAt:     time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+		Commits: []Commit{
+			{SHA: "aaa", Snapshot: base},
+			{SHA: "bbb", CommittedAt: "2026-01-02T00:00:00Z", Snapshot: head},
+		},
+		Changes: []Change{
+			{BaseSHA: "aaa", HeadSHA: "bbb", Delta: graph.ComputeDelta(base, head), Score: &scoring.ScoreResult{TotalScore: 1.5, Grade: "A"}},
+		},
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Write(&buf, testBundle()); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := Read(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Repo != "acme/mono" || got.DefaultBranch != "main" || len(got.Commits) != 2 || len(got.Changes) != 1 {
+		t.Fatalf("unexpected bundle: %+v", got)
+	}
+	if c := got.Commit("bbb"); c == nil || c.CommittedAt != "2026-01-02T00:00:00Z" || len(c.Snapshot.Nodes) != 2 {
+		t.Errorf("unexpected head commit: %+v", c)
+	}
+	ch := got.Changes[0]
+	if ch.Delta.Stats.AddedNodeCount != 1 || ch.Score == nil || ch.Score.TotalScore != 1.5 {
+		t.Errorf("unexpected change: %+v", ch)
+	}
+}
+
+func TestRoundTripSignedScore(t *testing.T) {
+	b := testBundle()
+	signed := &scoring.SignedScore{Payload: []byte(`{"repo":"acme/mono"}`), KeyID: "k1", Signature: []byte{1, 2, 3}}
+	b.Changes[0].Score, b.Changes[0].SignedScore = nil, signed
+
+	var buf bytes.Buffer
+	if err := Write(&buf, b); err != nil {
+		t.Fatal(err)
+	}
+	got, err := Read(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ch := got.Changes[0]
+	if ch.Score != nil || ch.SignedScore == nil || ch.SignedScore.KeyID != "k1" || !bytes.Equal(ch.SignedScore.Payload, signed.Payload) {
+		t.Errorf("unexpected change: %+v", ch)
+	}
+}
+
+func TestWriteRejectsDanglingChange(t *testing.T) {
+	b := testBundle()
+	b.Commits = b.Commits[1:]
+	if err := Write(&bytes.Buffer{}, b); err == nil {
+		t.Error("expected an error for a change whose base is not bundled")
+	}
+}
+
+func TestReadRejectsBadArchives(t *testing.T) {
+	if _, err := Read(strings.NewReader("not gzip")); err == nil {
+		t.Error("expected an error for a non-gzip body")
+	}
+
+	archive := func(files map[string]string) *bytes.Buffer {
+		var buf bytes.Buffer
+		gz := gzip.NewWriter(&buf)
+		tw := tar.NewWriter(gz)
+		for name, body := range files {
+			_ = tw.WriteHeader(&tar.Header{Name: name, Mode: 0o644, Size: int64(len(body)), Typeflag: tar.TypeReg})
+			_, _ = tw.Write([]byte(body))
+		}
+		_ = tw.Close()
+		_ = gz.Close()
+		return &buf
+	}
+
+	for name, files := range map[string]map[string]string{
+		"no manifest":   {"snapshots/aaa.json": "{}"},
+		"wrong version": {"manifest.json": `{"version":99}`},
+		"missing file":  {"manifest.json": `{"version":1,"commits":[{"sha":"aaa","file":"snapshots/aaa.json"}]}`},
+	} {
+		if _, err := Read(archive(files)); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestReadLimits(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Write(&buf, testBundle()); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+	defer func(entry, total int64) { maxEntryBytes, maxTotalBytes = entry, total }(maxEntryBytes, maxTotalBytes)
+
+	maxEntryBytes, maxTotalBytes = 64, 1<<20
+	if _, err := Read(bytes.NewReader(data)); err == nil || !strings.Contains(err.Error(), "larger than 64 bytes") {
+		t.Errorf("entry limit: err = %v", err)
+	}
+
+	maxEntryBytes, maxTotalBytes = 1<<20, 256
+	if _, err := Read(bytes.NewReader(data)); err == nil || !strings.Contains(err.Error(), "more than 256 bytes") {
+		t.Errorf("total limit: err = %v", err)
+	}
+
+	maxEntryBytes, maxTotalBytes = 1<<20, 1<<20
+	if _, err := Read(bytes.NewReader(data)); err != nil {
+		t.Errorf("within limits: %v", err)
+	}
+}
@@ -0,0 +1,464 @@
+// Package client is a Go client for the hosted Toposcope API. It covers
+// ingest and the read endpoints CI tools need; the full API is described by
+// schemas/openapi.json.
+package client
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/toposcope/toposcope/pkg/graph"
+	"github.com/toposcope/toposcope/pkg/scoring"
+)
+
+// APIVersion is the version of the API this package was written against.
+// The service accepts clients of the same major version.
+const APIVersion = "2.0.0"
+
+// Client calls a Toposcope platform.
+type Client struct {
+	BaseURL string
+	APIKey  string // sent as X-API-Key
+	// IDToken is sent as a bearer token, for platforms behind an
+	// identity-aware proxy (e.g. Cloud Run).
+	IDToken    string
+	HTTPClient *http.Client // default: a client with a two-minute timeout
+	// IngestAttempts is how many times Ingest sends a request that fails
+	// with a network error, 409, 429, or 5xx (default: 3).
+	IngestAttempts int
+	RetryBackoff   time.Duration // delay before the first retry, doubled for each one after (default: 1s)
+}
+
+// New returns a client for the platform at baseURL.
+func New(baseURL string) *Client {
+	return &Client{BaseURL: strings.TrimRight(baseURL, "/")}
+}
+
+// FromEnv returns a client for the platform at baseURL with credentials
+// from TOPOSCOPE_API_KEY and TOPOSCOPE_ID_TOKEN.
+func FromEnv(baseURL string) *Client {
+	c := New(baseURL)
+	c.APIKey = os.Getenv("TOPOSCOPE_API_KEY")
+	c.IDToken = os.Getenv("TOPOSCOPE_ID_TOKEN")
+	return c
+}
+
+// Error is a non-2xx response from the platform.
+type Error struct {
+	Method     string
+	Path       string
+	StatusCode int
+	Message    string // the response's error message, or its body
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s %s: HTTP %d: %s", e.Method, e.Path, e.StatusCode, e.Message)
+}
+
+// IsNotFound reports whether err is a 404 response.
+func IsNotFound(err error) bool {
+	var e *Error
+	return errors.As(err, &e) && e.StatusCode == http.StatusNotFound
+}
+
+// Repo is a registered repository.
+type Repo struct {
+	ID            string `json:"id"`
+	FullName      string `json:"full_name"`
+	DefaultBranch string `json:"default_branch"`
+}
+
+// RepoSettings are the settings of a repository that CI applies: the
+// boundaries it is scored with and the grade its gate fails at.
+type RepoSettings struct {
+	Boundaries []string `json:"boundaries,omitempty"`
+	FailOn     string   `json:"fail_on,omitempty"`
+}
+
+// Baseline is the snapshot a repository's pull requests are scored against.
+type Baseline struct {
+	SnapshotID    string  `json:"snapshot_id"`
+	CommitSHA     string  `json:"commit_sha"`
+	Branch        *string `json:"branch,omitempty"`
+	NodeCount     int     `json:"node_count"`
+	EdgeCount     int     `json:"edge_count"`
+	CommitAuthor  string  `json:"commit_author,omitempty"`
+	CommitMessage string  `json:"commit_message,omitempty"`
+	CommittedAt   string  `json:"committed_at,omitempty"`
+	CreatedAt     string  `json:"created_at"`
+}
+
+// DeltaStats counts the changes a score was computed from.
+type DeltaStats struct {
+	ImpactedTargets int `json:"impacted_targets"`
+	AddedNodes      int `json:"added_nodes"`
+	RemovedNodes    int `json:"removed_nodes"`
+	AddedEdges      int `json:"added_edges"`
+	RemovedEdges    int `json:"removed_edges"`
+}
+
+// Score is a stored score.
+type Score struct {
+	ID               string                    `json:"id"`
+	TotalScore       float64                   `json:"total_score"`
+	Grade            string                    `json:"grade"`
+	CommitSHA        string                    `json:"commit_sha"`
+	PRNumber         *int                      `json:"pr_number,omitempty"`
+	BaseSnapshotID   string                    `json:"base_snapshot_id"`
+	HeadSnapshotID   string                    `json:"head_snapshot_id"`
+	DeltaID          string                    `json:"delta_id"`
+	Breakdown        []scoring.MetricResult    `json:"breakdown"`
+	Hotspots         []scoring.Hotspot         `json:"hotspots"`
+	SuggestedActions []scoring.SuggestedAction `json:"suggested_actions"`
+	Config           *scoring.ScoreConfig      `json:"config,omitempty"`
+	Provenance       *scoring.Provenance       `json:"provenance,omitempty"` // set when the score was uploaded signed
+	Labels           []string                  `json:"labels"`
+	Superseded       int                       `json:"superseded,omitempty"`
+	DeltaStats       *DeltaStats               `json:"delta_stats,omitempty"`
+	DeltaSummary     *graph.DeltaSummary       `json:"delta_summary,omitempty"`
+	CommitAuthor     string                    `json:"commit_author,omitempty"`
+	CommitMessage    string                    `json:"commit_message,omitempty"`
+	CommittedAt      string                    `json:"committed_at,omitempty"`
+	CreatedAt        string                    `json:"created_at"`
+}
+
+// IngestRequest is the body of an ingest. Send the snapshots inline, or
+// upload them first with UploadSnapshot and pass their IDs.
+type IngestRequest struct {
+	RepoFullName   string               `json:"repo_full_name"`
+	DefaultBranch  string               `json:"default_branch,omitempty"`
+	CommitSHA      string               `json:"commit_sha"`
+	Branch         string               `json:"branch,omitempty"`
+	PRNumber       *int                 `json:"pr_number,omitempty"`    // the pull request the commit was scored for
+	CommittedAt    string               `json:"committed_at,omitempty"` // RFC3339
+	Snapshot       *graph.Snapshot      `json:"snapshot,omitempty"`
+	Score          *scoring.ScoreResult `json:"score,omitempty"`
+	SignedScore    *scoring.SignedScore `json:"signed_score,omitempty"` // sent instead of Score by runners with a signing key
+	BaseSnapshot   *graph.Snapshot      `json:"base_snapshot,omitempty"`
+	SnapshotID     string               `json:"snapshot_id,omitempty"`
+	BaseSnapshotID string               `json:"base_snapshot_id,omitempty"`
+	BuildEvents    string               `json:"build_events,omitempty"`  // BEP JSON stream for the head commit
+	ExecutionLog   string               `json:"execution_log,omitempty"` // JSON execution log of the same build
+}
+
+// IngestResponse identifies what an ingest stored.
+type IngestResponse struct {
+	SnapshotID     string `json:"snapshot_id"`
+	BaseSnapshotID string `json:"base_snapshot_id,omitempty"`
+	DeltaID        string `json:"delta_id,omitempty"`
+	ScoreID        string `json:"score_id,omitempty"`
+}
+
+// BundleImport counts what a bundle import stored.
+type BundleImport struct {
+	Snapshots   int               `json:"snapshots"`
+	Deltas      int               `json:"deltas"`
+	Scores      int               `json:"scores"`
+	SnapshotIDs map[string]string `json:"snapshot_ids"` // commit SHA -> snapshot ID
+}
+
+// Meta describes a platform's version and the clients it accepts.
+type Meta struct {
+	ServerVersion       string `json:"server_version"`
+	APIVersion          string `json:"api_version"`
+	MinClientAPIVersion string `json:"min_client_api_version"`
+	BundleFormat        int    `json:"bundle_format"` // the bundle layout the platform imports
+}
+
+// IncompatibleError reports a platform that doesn't accept this client's
+// payloads.
+type IncompatibleError struct {
+	BaseURL string
+	Meta    Meta
+	Reason  string
+}
+
+func (e *IncompatibleError) Error() string {
+	return fmt.Sprintf("platform %s (version %s) is not compatible with this client: %s", e.BaseURL, e.Meta.ServerVersion, e.Reason)
+}
+
+// Meta fetches the platform's version and the clients it accepts.
+func (c *Client) Meta(ctx context.Context) (*Meta, error) {
+	var m Meta
+	if err := c.do(ctx, http.MethodGet, "/api/v2/meta", nil, &m); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// CheckCompatible checks that the platform speaks this client's major API
+// version and accepts its payloads, returning an *IncompatibleError that says
+// which side to upgrade if not. Platforms that predate GET /api/v2/meta
+// return nil Meta and no error.
+func (c *Client) CheckCompatible(ctx context.Context) (*Meta, error) {
+	m, err := c.Meta(ctx)
+	if IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	incompatible := func(format string, args ...any) (*Meta, error) {
+		return m, &IncompatibleError{BaseURL: c.BaseURL, Meta: *m, Reason: fmt.Sprintf(format, args...)}
+	}
+	server, okServer := parseVersion(m.APIVersion)
+	client, _ := parseVersion(APIVersion)
+	if okServer && server[0] > client[0] {
+		return incompatible("it serves API %s and this client speaks %s; upgrade toposcope", m.APIVersion, APIVersion)
+	}
+	if okServer && server[0] < client[0] {
+		return incompatible("it serves API %s, older than this client's %s; upgrade the platform or use an older toposcope", m.APIVersion, APIVersion)
+	}
+	if least, ok := parseVersion(m.MinClientAPIVersion); ok && compareVersions(client, least) < 0 {
+		return incompatible("it needs clients of API %s or later and this one speaks %s; upgrade toposcope", m.MinClientAPIVersion, APIVersion)
+	}
+	return m, nil
+}
+
+// parseVersion parses a major.minor.patch version.
+func parseVersion(s string) (v [3]int, ok bool) {
+	parts := strings.Split(strings.TrimPrefix(s, "v"), ".")
+	if len(parts) != 3 {
+		return v, false
+	}
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 0 {
+			return v, false
+		}
+		v[i] = n
+	}
+	return v, true
+}
+
+func compareVersions(a, b [3]int) int {
+	for i := range a {
+		if a[i] != b[i] {
+			if a[i] < b[i] {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+// ListRepos lists the repositories the caller can see.
+func (c *Client) ListRepos(ctx context.Context) ([]Repo, error) {
+	var repos []Repo
+	err := c.do(ctx, http.MethodGet, "/api/v2/repos", nil, &repos)
+	return repos, err
+}
+
+// FindRepo returns the repository named fullName (owner/name, compared
+// case-insensitively), or nil if it isn't registered.
+func (c *Client) FindRepo(ctx context.Context, fullName string) (*Repo, error) {
+	repos, err := c.ListRepos(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for i := range repos {
+		if strings.EqualFold(repos[i].FullName, fullName) {
+			return &repos[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// GetBaseline returns a repository's baseline.
+func (c *Client) GetBaseline(ctx context.Context, repoID string) (*Baseline, error) {
+	var b Baseline
+	if err := c.do(ctx, http.MethodGet, "/api/v2/repos/"+url.PathEscape(repoID)+"/baseline", nil, &b); err != nil {
+		return nil, err
+	}
+	return &b, nil
+}
+
+// GetRepoSettings returns a repository's settings.
+func (c *Client) GetRepoSettings(ctx context.Context, repoID string) (*RepoSettings, error) {
+	var s RepoSettings
+	if err := c.do(ctx, http.MethodGet, "/api/v2/repos/"+url.PathEscape(repoID)+"/settings", nil, &s); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// GetSnapshot downloads a snapshot's graph.
+func (c *Client) GetSnapshot(ctx context.Context, snapshotID string) (*graph.Snapshot, error) {
+	var snap graph.Snapshot
+	if err := c.do(ctx, http.MethodGet, "/api/v2/snapshots/"+url.PathEscape(snapshotID), nil, &snap); err != nil {
+		return nil, err
+	}
+	return &snap, nil
+}
+
+// ListScores lists a repository's scores, newest first. A non-empty label
+// limits them to scores carrying it.
+func (c *Client) ListScores(ctx context.Context, repoID, label string) ([]Score, error) {
+	path := "/api/v2/repos/" + url.PathEscape(repoID) + "/scores"
+	if label != "" {
+		path += "?label=" + url.QueryEscape(label)
+	}
+	var scores []Score
+	err := c.do(ctx, http.MethodGet, path, nil, &scores)
+	return scores, err
+}
+
+// GetScore returns a score.
+func (c *Client) GetScore(ctx context.Context, repoID, scoreID string) (*Score, error) {
+	var sc Score
+	if err := c.do(ctx, http.MethodGet, "/api/v2/repos/"+url.PathEscape(repoID)+"/scores/"+url.PathEscape(scoreID), nil, &sc); err != nil {
+		return nil, err
+	}
+	return &sc, nil
+}
+
+// Ingest stores a commit's snapshot and, with a base snapshot and score, a
+// pull request's score. Transient failures are retried; see IngestAttempts.
+func (c *Client) Ingest(ctx context.Context, req *IngestRequest) (*IngestResponse, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("encoding ingest request: %w", err)
+	}
+	// Every attempt carries the same Idempotency-Key, so a retry after a
+	// lost response gets the original response instead of a second ingest.
+	p := &payload{contentType: "application/json", data: body, idempotencyKey: uuid.NewString()}
+	attempts := c.IngestAttempts
+	if attempts <= 0 {
+		attempts = 3
+	}
+	backoff := c.RetryBackoff
+	if backoff <= 0 {
+		backoff = time.Second
+	}
+	for attempt := 1; ; attempt++ {
+		var resp IngestResponse
+		err := c.do(ctx, http.MethodPost, "/api/v2/ingest", p, &resp)
+		if err == nil {
+			return &resp, nil
+		}
+		if attempt == attempts || !retryable(err) {
+			return nil, err
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// retryable reports whether an ingest that failed with err may succeed if
+// sent again: network errors, 409 (the first attempt is still running), 429,
+// and 5xx responses.
+func retryable(err error) bool {
+	var e *Error
+	if !errors.As(err, &e) {
+		return !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded)
+	}
+	return e.StatusCode == http.StatusConflict || e.StatusCode == http.StatusTooManyRequests || e.StatusCode >= 500
+}
+
+// UploadSnapshot uploads a snapshot, gzip-compressed, and returns the ID to
+// pass to Ingest.
+func (c *Client) UploadSnapshot(ctx context.Context, snap *graph.Snapshot) (string, error) {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	if err := json.NewEncoder(gz).Encode(snap); err != nil {
+		return "", fmt.Errorf("encoding snapshot: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		return "", fmt.Errorf("encoding snapshot: %w", err)
+	}
+	var resp struct {
+		SnapshotID string `json:"snapshot_id"`
+	}
+	if err := c.do(ctx, http.MethodPost, "/api/v2/snapshots", &payload{contentType: "application/json", encoding: "gzip", data: buf.Bytes()}, &resp); err != nil {
+		return "", err
+	}
+	return resp.SnapshotID, nil
+}
+
+// ImportBundle uploads a bundle written by pkg/bundle.
+func (c *Client) ImportBundle(ctx context.Context, data []byte) (*BundleImport, error) {
+	var resp BundleImport
+	if err := c.do(ctx, http.MethodPost, "/api/v2/bundles", &payload{contentType: "application/gzip", data: data}, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// payload is a request body.
+type payload struct {
+	contentType    string
+	encoding       string // Content-Encoding, if any
+	data           []byte
+	idempotencyKey string // Idempotency-Key, if any
+}
+
+// do sends a request and decodes the JSON response into out.
+func (c *Client) do(ctx context.Context, method, path string, body *payload, out any) error {
+	var r io.Reader
+	if body != nil {
+		r = bytes.NewReader(body.data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.BaseURL+path, r)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", body.contentType)
+		if body.encoding != "" {
+			req.Header.Set("Content-Encoding", body.encoding)
+		}
+		if body.idempotencyKey != "" {
+			req.Header.Set("Idempotency-Key", body.idempotencyKey)
+		}
+	}
+	req.Header.Set("User-Agent", "toposcope-client/"+APIVersion)
+	if c.APIKey != "" {
+		req.Header.Set("X-API-Key", c.APIKey)
+	}
+	if c.IDToken != "" {
+		req.Header.Set("Authorization", "Bearer "+c.IDToken)
+	}
+
+	hc := c.HTTPClient
+	if hc == nil {
+		hc = &http.Client{Timeout: 2 * time.Minute}
+	}
+	resp, err := hc.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+		e := &Error{Method: method, Path: path, StatusCode: resp.StatusCode, Message: strings.TrimSpace(string(data))}
+		var msg struct {
+			Error string `json:"error"`
+		}
+		if json.Unmarshal(data, &msg) == nil && msg.Error != "" {
+			e.Message = msg.Error
+		}
+		return e
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("%s %s: decoding response: %w", method, path, err)
+	}
+	return nil
+}
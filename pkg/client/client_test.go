@@ -0,0 +1,155 @@
+package client
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/toposcope/toposcope/pkg/graph"
+)
+
+func TestClientSendsCredentialsAndDecodes(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-API-Key") != "key" || r.Header.Get("Authorization") != "Bearer tok" {
+			t.Errorf("%s %s: headers = %v", r.Method, r.URL.Path, r.Header)
+		}
+		switch r.URL.Path {
+		case "/api/v2/repos":
+			_, _ = w.Write([]byte(`[{"id":"r1","full_name":"Acme/Mono","default_branch":"main"}]`))
+		case "/api/v2/ingest":
+			var req IngestRequest
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.CommitSHA != "abc" {
+				t.Errorf("ingest body = %+v, %v", req, err)
+			}
+			_, _ = w.Write([]byte(`{"snapshot_id":"s1","score_id":"sc1"}`))
+		case "/api/v2/snapshots":
+			gz, err := gzip.NewReader(r.Body)
+			if err != nil || r.Header.Get("Content-Encoding") != "gzip" {
+				t.Fatalf("snapshot upload not gzipped: %v", err)
+			}
+			var snap graph.Snapshot
+			if err := json.NewDecoder(gz).Decode(&snap); err != nil || snap.CommitSHA != "abc" {
+				t.Errorf("uploaded snapshot = %+v, %v", snap, err)
+			}
+			_, _ = w.Write([]byte(`{"snapshot_id":"up1"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"error": "baseline not found"}`))
+		}
+	}))
+	defer srv.Close()
+
+	c := New(srv.URL + "/")
+	c.APIKey, c.IDToken = "key", "tok"
+	ctx := context.Background()
+
+	repo, err := c.FindRepo(ctx, "acme/mono")
+	if err != nil || repo == nil || repo.ID != "r1" {
+		t.Fatalf("FindRepo = %+v, %v", repo, err)
+	}
+	if repo, err := c.FindRepo(ctx, "acme/other"); err != nil || repo != nil {
+		t.Errorf("FindRepo(unregistered) = %+v, %v; want nil", repo, err)
+	}
+
+	resp, err := c.Ingest(ctx, &IngestRequest{RepoFullName: "acme/mono", CommitSHA: "abc"})
+	if err != nil || resp.SnapshotID != "s1" || resp.ScoreID != "sc1" {
+		t.Errorf("Ingest = %+v, %v", resp, err)
+	}
+	if id, err := c.UploadSnapshot(ctx, &graph.Snapshot{CommitSHA: "abc"}); err != nil || id != "up1" {
+		t.Errorf("UploadSnapshot = %q, %v", id, err)
+	}
+
+	_, err = c.GetBaseline(ctx, "r1")
+	if !IsNotFound(err) {
+		t.Fatalf("GetBaseline error = %v, want not found", err)
+	}
+	if got := err.Error(); got != "GET /api/v2/repos/r1/baseline: HTTP 404: baseline not found" {
+		t.Errorf("error = %q", got)
+	}
+}
+
+func TestCheckCompatible(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		status int
+		meta   string
+		want   string // substring of the error; "" for compatible
+	}{
+		{"same", 200, `{"server_version":"1.4.0","api_version":"2.0.0","min_client_api_version":"2.0.0","bundle_format":1}`, ""},
+		{"newer minor", 200, `{"api_version":"2.3.0","min_client_api_version":"2.0.0"}`, ""},
+		{"newer major", 200, `{"api_version":"3.0.0","min_client_api_version":"3.0.0"}`, "upgrade toposcope"},
+		{"older major", 200, `{"api_version":"1.2.0","min_client_api_version":"1.0.0"}`, "upgrade the platform"},
+		{"client too old", 200, `{"server_version":"1.9.0","api_version":"2.5.0","min_client_api_version":"2.1.0"}`, "needs clients of API 2.1.0 or later"},
+		{"no meta endpoint", 404, `{"error":"not found"}`, ""},
+		{"server error", 500, `{"error":"boom"}`, "HTTP 500"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/api/v2/meta" {
+					t.Errorf("path = %s", r.URL.Path)
+				}
+				w.WriteHeader(tc.status)
+				_, _ = w.Write([]byte(tc.meta))
+			}))
+			defer srv.Close()
+
+			_, err := New(srv.URL).CheckCompatible(context.Background())
+			if tc.want == "" {
+				if err != nil {
+					t.Errorf("CheckCompatible: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Errorf("CheckCompatible: err = %v, want %q", err, tc.want)
+			}
+			var incompatible *IncompatibleError
+			if tc.status == 200 && !errors.As(err, &incompatible) {
+				t.Errorf("err is a %T, want *IncompatibleError", err)
+			}
+		})
+	}
+}
+
+func TestIngestRetriesWithIdempotencyKey(t *testing.T) {
+	var keys []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		keys = append(keys, r.Header.Get("Idempotency-Key"))
+		switch len(keys) {
+		case 1:
+			w.WriteHeader(http.StatusBadGateway)
+		case 2:
+			w.WriteHeader(http.StatusConflict)
+			_, _ = w.Write([]byte(`{"error":"an ingest with this idempotency key is in progress"}`))
+		default:
+			_, _ = w.Write([]byte(`{"snapshot_id":"s1"}`))
+		}
+	}))
+	defer srv.Close()
+
+	c := New(srv.URL)
+	c.RetryBackoff = time.Millisecond
+	resp, err := c.Ingest(context.Background(), &IngestRequest{RepoFullName: "acme/mono", CommitSHA: "abc"})
+	if err != nil || resp.SnapshotID != "s1" {
+		t.Fatalf("Ingest = %+v, %v", resp, err)
+	}
+	if len(keys) != 3 || keys[0] == "" || keys[1] != keys[0] || keys[2] != keys[0] {
+		t.Errorf("Idempotency-Key per attempt = %q, want one key on all three", keys)
+	}
+
+	// Client errors aren't retried, and each Ingest gets its own key.
+	keys = nil
+	srv.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		keys = append(keys, r.Header.Get("Idempotency-Key"))
+		w.WriteHeader(http.StatusBadRequest)
+	})
+	if _, err := c.Ingest(context.Background(), &IngestRequest{}); err == nil || len(keys) != 1 {
+		t.Errorf("Ingest after a 400: err = %v, %d attempts; want one failed attempt", err, len(keys))
+	}
+}
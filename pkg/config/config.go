@@ -11,14 +11,49 @@ import (
 
 // Config is the top-level configuration for Toposcope.
 type Config struct {
-	Scoring    ScoringConfig    `yaml:"scoring"`
-	Extraction ExtractionConfig `yaml:"extraction"`
+	Scoring     ScoringConfig     `yaml:"scoring"`
+	Extraction  ExtractionConfig  `yaml:"extraction"`
+	RemoteCache RemoteCacheConfig `yaml:"remote_cache"`
 }
 
 // ScoringConfig controls scoring behavior.
 type ScoringConfig struct {
-	Boundaries []string           `yaml:"boundaries"`
-	Weights    map[string]float64 `yaml:"weights"`
+	Boundaries       []string               `yaml:"boundaries"`
+	Weights          map[string]float64     `yaml:"weights"`
+	ExternalMetrics  []ExternalMetricConfig `yaml:"external_metrics"`
+	Normalization    string                 `yaml:"normalization"`     // "" (raw) or "size"
+	GradeThresholds  map[string]float64     `yaml:"grade_thresholds"`  // upper bound per grade (A-D); missing grades keep defaults
+	ThirdPartyAllow  []string               `yaml:"third_party_allow"` // external repos exempt from third_party_exposure
+	CrossLanguage    bool                   `yaml:"cross_language"`    // score edges between languages as cross-boundary
+	IncludeGenerated bool                   `yaml:"include_generated"` // apply fanout and centrality penalties to generated targets
+	EdgeTypes        []string               `yaml:"edge_types"`        // score only edges of these types; empty scores all
+	ConfidenceLevel  float64                `yaml:"confidence_level"`  // bootstrap interval level for scores (e.g. 0.9); 0 disables it
+	Waivers          []WaiverConfig         `yaml:"waivers"`
+	// Layers orders boundaries from top to bottom for `toposcope report
+	// conformance`. A boundary may depend on its own layer and the layers
+	// below it.
+	Layers [][]string `yaml:"layers"`
+}
+
+// WaiverConfig suppresses a metric's findings on matching targets until it
+// expires. Targets can also be waived in BUILD files with a
+// "toposcope-ignore" tag.
+type WaiverConfig struct {
+	Metric  string `yaml:"metric"` // metric key; empty waives every metric
+	Target  string `yaml:"target"` // label, //pkg:* (package), or //pkg/... (package tree)
+	Reason  string `yaml:"reason"`
+	Expires string `yaml:"expires"` // YYYY-MM-DD; the waiver applies through that day (UTC)
+}
+
+// ExternalMetricConfig declares a custom metric implemented by an external
+// binary. The binary reads the delta and snapshots as JSON on stdin and writes
+// a metric result as JSON on stdout.
+type ExternalMetricConfig struct {
+	Key     string   `yaml:"key"`
+	Name    string   `yaml:"name"`
+	Command string   `yaml:"command"` // relative paths resolve against the workspace root
+	Args    []string `yaml:"args"`
+	Timeout int      `yaml:"timeout"` // seconds
 }
 
 // ExtractionConfig controls extraction behavior.
@@ -28,6 +63,88 @@ type ExtractionConfig struct {
 	BazelRC      string `yaml:"bazelrc"`
 	UseCQuery    bool   `yaml:"use_cquery"`
 	BazelDiffJar string `yaml:"bazel_diff_jar"` // path to bazel-diff.jar
+
+	// Resource limits for bazel queries; 0 is unlimited. MaxMemoryMB caps
+	// the bazel server's Java heap and MaxCPUSeconds how long each bazel
+	// query may run.
+	MaxMemoryMB   int `yaml:"max_memory_mb"`
+	MaxCPUSeconds int `yaml:"max_cpu_seconds"`
+
+	// OutputBase gives extractions their own bazel output base, so they
+	// don't restart the server of your own builds. "auto" keeps them in the
+	// workspace cache dir; any other value is a directory to keep them in.
+	// Empty uses bazel's default.
+	OutputBase string `yaml:"output_base"`
+
+	// KeepServer keeps the extraction bazel server running between
+	// invocations (--max_idle_secs=0) instead of bazel's 3 hour default.
+	KeepServer bool `yaml:"keep_server"`
+
+	// ShardParallelism, if positive, runs full extractions as one query per
+	// top-level package tree, this many at a time, for workspaces where a
+	// single kind(rule, //...) query times out. A failing tree is reported
+	// as skipped instead of failing the snapshot.
+	ShardParallelism int `yaml:"shard_parallelism"`
+
+	// ProfileDir, if set, keeps a bazel --profile of every extraction query
+	// there and adds the phases it records to the snapshot's stats.
+	ProfileDir string `yaml:"profile_dir"`
+
+	// Hash cache limits for bazel-diff. Least recently used files are
+	// evicted beyond the size limit; 0 disables a limit.
+	HashCacheMaxMB   int `yaml:"hash_cache_max_mb"`
+	HashCacheTTLDays int `yaml:"hash_cache_ttl_days"`
+
+	// IncludeExternal retains external dependencies (@maven, @pip, ...) as
+	// one node per external repo instead of dropping them.
+	IncludeExternal bool `yaml:"include_external"`
+
+	// RepoMapping maps external repository names to the tracked
+	// repositories they are built from (e.g. "@corp_foo": "acme/foo").
+	// Dependencies on them keep their targets and become cross-repo edges
+	// in org-level views.
+	RepoMapping map[string]string `yaml:"repo_mapping"`
+
+	// EdgeAttributes overrides which rule attributes produce dependency
+	// edges and their edge type (e.g. tools: TOOLCHAIN). An empty type
+	// disables a default attribute.
+	EdgeAttributes map[string]string `yaml:"edge_attributes"`
+
+	// Generated overrides the rule class and tag patterns that mark
+	// generated targets (default: proto and gRPC outputs, and targets
+	// tagged "generated").
+	Generated *GeneratedConfig `yaml:"generated"`
+
+	// Normalize rewrites target labels with regular expressions before
+	// they become node keys, so targets that macros name differently
+	// compare stably across snapshots. Rules apply in order.
+	Normalize []LabelRewriteConfig `yaml:"normalize"`
+}
+
+// LabelRewriteConfig replaces matches of a regular expression in a target
+// label. Replace may use capture groups ($1).
+type LabelRewriteConfig struct {
+	Match   string `yaml:"match"`
+	Replace string `yaml:"replace"`
+}
+
+// GeneratedConfig lists glob patterns for the rule classes and tags of
+// generated targets.
+type GeneratedConfig struct {
+	Kinds []string `yaml:"kinds"`
+	Tags  []string `yaml:"tags"`
+}
+
+// RemoteCacheConfig enables a shared snapshot and hash cache, so parallel CI
+// jobs reuse each other's extractions. Objects are keyed by commit SHA under
+// Namespace. S3 credentials come from the standard AWS environment.
+type RemoteCacheConfig struct {
+	Backend   string `yaml:"backend"` // "" (disabled), "s3", "gcs", or "local"
+	Bucket    string `yaml:"bucket"`
+	Region    string `yaml:"region"`
+	Endpoint  string `yaml:"endpoint"`  // S3-compatible endpoint (e.g. MinIO)
+	Path      string `yaml:"path"`      // directory for the local backend (e.g. a shared mount)
+	Namespace string `yaml:"namespace"` // key prefix (default: derived from the workspace path)
 }
 
 // DefaultConfig returns a Config with sensible defaults.
@@ -38,8 +155,10 @@ func DefaultConfig() *Config {
 			Weights:    map[string]float64{},
 		},
 		Extraction: ExtractionConfig{
-			Timeout:   600,
-			BazelPath: "bazelisk",
+			Timeout:          600,
+			BazelPath:        "bazelisk",
+			HashCacheMaxMB:   2048,
+			HashCacheTTLDays: 30,
 		},
 	}
 }
@@ -83,11 +202,13 @@ func FindConfigFile(dir string) string {
 
 // FindBazelDiffJar looks for bazel-diff.jar in common locations.
 func FindBazelDiffJar() string {
-	candidates := []string{
-		"bazel-diff.jar", // current dir
-		filepath.Join(os.Getenv("HOME"), "bazel-diff.jar"),        // home dir
-		filepath.Join(os.Getenv("HOME"), "bazel-diff_deploy.jar"), // alternate name
-		filepath.Join(os.Getenv("HOME"), "bin", "bazel-diff.jar"), // ~/bin
+	candidates := []string{"bazel-diff.jar"} // current dir
+	if home, err := os.UserHomeDir(); err == nil {
+		candidates = append(candidates,
+			filepath.Join(home, "bazel-diff.jar"),        // home dir
+			filepath.Join(home, "bazel-diff_deploy.jar"), // alternate name
+			filepath.Join(home, "bin", "bazel-diff.jar"), // ~/bin
+		)
 	}
 	for _, c := range candidates {
 		if _, err := os.Stat(c); err == nil {
@@ -99,15 +220,29 @@ func FindBazelDiffJar() string {
 }
 
 // CacheDir returns the cache directory for a given workspace path.
-// Uses ~/.cache/toposcope/<repo-slug>/ to avoid polluting the repo.
+// Uses <user cache dir>/toposcope/<repo-slug>/ to avoid polluting the repo:
+// ~/.cache on Linux, %LocalAppData% on Windows and ~/Library/Caches on
+// macOS. An existing ~/.cache/toposcope is kept on every platform.
 func CacheDir(workspacePath string) string {
+	return filepath.Join(cacheRoot(), repoSlug(workspacePath))
+}
+
+func cacheRoot() string {
 	home, err := os.UserHomeDir()
-	if err != nil {
-		// Fallback to temp dir if HOME isn't available
-		home = os.TempDir()
+	if err == nil {
+		legacy := filepath.Join(home, ".cache", "toposcope")
+		if info, err := os.Stat(legacy); err == nil && info.IsDir() {
+			return legacy
+		}
 	}
-	slug := repoSlug(workspacePath)
-	return filepath.Join(home, ".cache", "toposcope", slug)
+	if dir, err := os.UserCacheDir(); err == nil {
+		return filepath.Join(dir, "toposcope")
+	}
+	if err == nil {
+		return filepath.Join(home, ".cache", "toposcope")
+	}
+	// Fallback to temp dir if there is no home directory
+	return filepath.Join(os.TempDir(), "toposcope")
 }
 
 // SnapshotDir returns the snapshot storage directory for a workspace.
@@ -125,6 +260,33 @@ func ScoreDir(workspacePath string) string {
 	return filepath.Join(CacheDir(workspacePath), "scores")
 }
 
+// OutputBaseDir returns the bazel output base for extractions in a checkout
+// of the workspace at workspacePath, or "" when OutputBase is unset.
+// Checkouts other than the workspace itself, such as a backfill worktree,
+// are named by worktree and get their own output base, since one output base
+// serves one checkout at a time.
+func (e ExtractionConfig) OutputBaseDir(workspacePath, worktree string) string {
+	var root string
+	switch e.OutputBase {
+	case "":
+		return ""
+	case "auto":
+		root = filepath.Join(CacheDir(workspacePath), "output_base")
+	default:
+		root = filepath.Join(e.OutputBase, repoSlug(workspacePath))
+	}
+	if worktree == "" {
+		worktree = "main"
+	}
+	return filepath.Join(root, worktree)
+}
+
+// RepoSlug returns the filesystem-safe identifier used for a workspace's
+// cache directory.
+func RepoSlug(workspacePath string) string {
+	return repoSlug(workspacePath)
+}
+
 // repoSlug creates a filesystem-safe identifier from a workspace path.
 // Uses the last two path components (e.g., "user/myrepo" from "/home/user/workspace/myrepo").
 func repoSlug(workspacePath string) string {
@@ -3,6 +3,7 @@ package config
 import (
 	"os"
 	"path/filepath"
+	"runtime"
 	"strings"
 	"testing"
 )
@@ -50,6 +51,8 @@ extraction:
   timeout: 120
   bazel_path: "/usr/bin/bazel"
   use_cquery: true
+  edge_attributes:
+    tools: TOOLCHAIN
 scoring:
   boundaries:
     - svc
@@ -57,6 +60,11 @@ scoring:
   weights:
     coupling: 0.5
     cohesion: 0.3
+  external_metrics:
+    - key: layering
+      command: ./tools/layering-metric
+      args: ["--strict"]
+      timeout: 30
 `,
 			check: func(t *testing.T, cfg *Config) {
 				if cfg.Extraction.Timeout != 120 {
@@ -74,6 +82,12 @@ scoring:
 				if cfg.Scoring.Weights["coupling"] != 0.5 {
 					t.Errorf("expected coupling weight 0.5, got %f", cfg.Scoring.Weights["coupling"])
 				}
+				if len(cfg.Scoring.ExternalMetrics) != 1 || cfg.Scoring.ExternalMetrics[0].Command != "./tools/layering-metric" {
+					t.Errorf("expected 1 external metric, got %+v", cfg.Scoring.ExternalMetrics)
+				}
+				if cfg.Extraction.EdgeAttributes["tools"] != "TOOLCHAIN" {
+					t.Errorf("expected tools edge attribute, got %v", cfg.Extraction.EdgeAttributes)
+				}
 			},
 		},
 		{
@@ -153,6 +167,44 @@ func TestDirectoryFunctions(t *testing.T) {
 	}
 }
 
+func TestCacheDirLocation(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("the user cache dir comes from XDG_CACHE_HOME on Linux only")
+	}
+	home, xdg := t.TempDir(), t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CACHE_HOME", xdg)
+
+	if got, want := CacheDir("/src/acme/mono"), filepath.Join(xdg, "toposcope", "acme_mono"); got != want {
+		t.Errorf("CacheDir = %q, want %q under the user cache dir", got, want)
+	}
+
+	// An existing ~/.cache/toposcope keeps being used.
+	legacy := filepath.Join(home, ".cache", "toposcope")
+	if err := os.MkdirAll(legacy, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := CacheDir("/src/acme/mono"), filepath.Join(legacy, "acme_mono"); got != want {
+		t.Errorf("CacheDir = %q, want the existing %q", got, want)
+	}
+}
+
+func TestOutputBaseDir(t *testing.T) {
+	workspace := "/home/alice/repos/myproject"
+
+	if dir := (ExtractionConfig{}).OutputBaseDir(workspace, ""); dir != "" {
+		t.Errorf("unset OutputBase: got %q, want bazel's default", dir)
+	}
+	auto := ExtractionConfig{OutputBase: "auto"}
+	if dir := auto.OutputBaseDir(workspace, ""); dir != filepath.Join(CacheDir(workspace), "output_base", "main") {
+		t.Errorf("auto: got %q", dir)
+	}
+	custom := ExtractionConfig{OutputBase: "/var/cache/bazel"}
+	if dir := custom.OutputBaseDir(workspace, "backfill"); dir != filepath.Join("/var/cache/bazel", "repos_myproject", "backfill") {
+		t.Errorf("custom worktree: got %q", dir)
+	}
+}
+
 func TestRepoSlug(t *testing.T) {
 	tests := []struct {
 		name string
@@ -275,3 +327,195 @@ func TestFindConfigFile(t *testing.T) {
 		}
 	})
 }
+
+func TestValidate(t *testing.T) {
+	data := []byte(`extraction:
+  timout: 30
+  generated:
+    kinds: ["go_[binary"]
+  normalize:
+    - match: "(unclosed"
+scoring:
+  cross_language: nope
+  normalization: log
+  waivers:
+    - target: //a:lib
+      expires: next week
+remote_cache:
+  backend: s3
+`)
+	cfg, problems, err := Validate(data)
+	if err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+	if cfg == nil || cfg.Extraction.BazelPath != "bazelisk" {
+		t.Errorf("expected defaults filled in, got %+v", cfg)
+	}
+
+	want := []Problem{
+		{Path: "extraction.timout", Line: 2, Message: "unknown key (did you mean timeout?)"},
+		{Path: "extraction.generated.kinds[0]", Line: 4, Message: `bad glob pattern "go_[binary"`},
+		{Path: "extraction.normalize[0].match", Line: 6},
+		{Path: "scoring.cross_language", Line: 8},
+		{Path: "scoring.normalization", Line: 9, Message: `unknown normalization "log" (want size, or leave unset)`},
+		{Path: "scoring.waivers[0].expires", Line: 12, Message: `invalid date "next week" (want YYYY-MM-DD)`},
+		{Path: "remote_cache.bucket", Line: 13, Message: "required for the s3 backend"},
+	}
+	if len(problems) != len(want) {
+		t.Fatalf("got %d problems, want %d: %v", len(problems), len(want), problems)
+	}
+	for i, w := range want {
+		got := problems[i]
+		if got.Path != w.Path || got.Line != w.Line || (w.Message != "" && got.Message != w.Message) {
+			t.Errorf("problem %d = %+v, want %+v", i, got, w)
+		}
+	}
+	if !strings.Contains(problems[2].Message, "bad regular expression") {
+		t.Errorf("expected a regexp problem, got %q", problems[2].Message)
+	}
+	if !strings.Contains(problems[3].Message, "!!str `nope`") {
+		t.Errorf("expected a type problem, got %q", problems[3].Message)
+	}
+
+	cfg, problems, err = Validate([]byte("scoring:\n  boundaries: [svc]\n  weights:\n    fanout_weight: 1\n"))
+	if err != nil || len(problems) != 0 {
+		t.Fatalf("valid config: problems %v, err %v", problems, err)
+	}
+	if len(cfg.Scoring.Boundaries) != 1 || cfg.Scoring.Weights["fanout_weight"] != 1 {
+		t.Errorf("unexpected config %+v", cfg.Scoring)
+	}
+
+	if _, _, err := Validate([]byte("{{invalid yaml")); err == nil {
+		t.Error("expected an error for invalid YAML")
+	}
+}
+
+func TestEnvOverrides(t *testing.T) {
+	got, err := EnvOverrides([]string{
+		"TOPOSCOPE_SCORING_WEIGHTS_FANOUT_WEIGHT=2",
+		"TOPOSCOPE_REMOTE_CACHE_BUCKET=ci-cache",
+		"TOPOSCOPE_EXTRACTION_GENERATED_KINDS=go_*,proto_*",
+		"TOPOSCOPE_URL=https://toposcope.example.com", // not a config key
+		"HOME=/home/ci",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []Override{
+		{Key: "extraction.generated.kinds", Value: "go_*,proto_*", Source: "TOPOSCOPE_EXTRACTION_GENERATED_KINDS"},
+		{Key: "remote_cache.bucket", Value: "ci-cache", Source: "TOPOSCOPE_REMOTE_CACHE_BUCKET"},
+		{Key: "scoring.weights.fanout_weight", Value: "2", Source: "TOPOSCOPE_SCORING_WEIGHTS_FANOUT_WEIGHT"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("EnvOverrides = %+v, want %+v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("override %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+
+	if _, err := EnvOverrides([]string{"TOPOSCOPE_SCORING_BOGUS=1"}); err == nil {
+		t.Error("expected error for an unknown key in a config section")
+	}
+}
+
+func TestApply(t *testing.T) {
+	cfg := DefaultConfig()
+	err := Apply(cfg, []Override{
+		{Key: "extraction.timeout", Value: "900"},
+		{Key: "extraction.timeout", Value: "1200"}, // later overrides win
+		{Key: "scoring.boundaries", Value: "svc, lib"},
+		{Key: "scoring.third_party_allow", Value: `["@maven"]`},
+		{Key: "scoring.weights.fanout_weight", Value: "0.25"},
+		{Key: "extraction.generated.tags", Value: "generated"},
+		{Key: "extraction.bazel_path", Value: ""},
+		{Key: "scoring.cross_language", Value: "true"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.Extraction.Timeout != 1200 {
+		t.Errorf("Timeout = %d, want 1200", cfg.Extraction.Timeout)
+	}
+	if strings.Join(cfg.Scoring.Boundaries, ",") != "svc,lib" || strings.Join(cfg.Scoring.ThirdPartyAllow, ",") != "@maven" {
+		t.Errorf("lists = %v, %v", cfg.Scoring.Boundaries, cfg.Scoring.ThirdPartyAllow)
+	}
+	if cfg.Scoring.Weights["fanout_weight"] != 0.25 || !cfg.Scoring.CrossLanguage {
+		t.Errorf("scoring = %+v", cfg.Scoring)
+	}
+	if cfg.Extraction.Generated == nil || cfg.Extraction.Generated.Tags[0] != "generated" {
+		t.Errorf("Generated = %+v, want tags set", cfg.Extraction.Generated)
+	}
+	if cfg.Extraction.BazelPath != "" {
+		t.Errorf("BazelPath = %q, want cleared", cfg.Extraction.BazelPath)
+	}
+
+	for _, o := range []Override{
+		{Key: "extraction.timout", Value: "1", Source: "--set"},
+		{Key: "extraction.timeout", Value: "soon", Source: "--set"},
+		{Key: "extraction.timeout.seconds", Value: "1", Source: "--set"},
+	} {
+		if err := Apply(DefaultConfig(), []Override{o}); err == nil || !strings.HasPrefix(err.Error(), "--set: "+o.Key+": ") {
+			t.Errorf("Apply(%+v) error = %v", o, err)
+		}
+	}
+}
+
+func TestResolve(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte("extraction:\n  timeout: 120\n  bazel_path: /usr/bin/bazel\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	env, err := EnvOverrides([]string{"TOPOSCOPE_EXTRACTION_TIMEOUT=300"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	set, err := ParseSet("extraction.timeout=400")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := Resolve(path, env)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.Extraction.Timeout != 300 || cfg.Extraction.BazelPath != "/usr/bin/bazel" || cfg.Extraction.HashCacheMaxMB != 2048 {
+		t.Errorf("env over file over defaults: %+v", cfg.Extraction)
+	}
+	if cfg, _ = Resolve(path, append(env, set)); cfg.Extraction.Timeout != 400 {
+		t.Errorf("Timeout = %d, want --set to win", cfg.Extraction.Timeout)
+	}
+	if cfg, _ = Resolve("", []Override{set}); cfg.Extraction.Timeout != 400 || cfg.Extraction.BazelPath != "bazelisk" {
+		t.Errorf("overrides without a file: %+v", cfg.Extraction)
+	}
+
+	if _, err := ParseSet("extraction.timeout"); err == nil {
+		t.Error("expected error for --set without a value")
+	}
+}
+
+func TestValidateOverrides(t *testing.T) {
+	cfg, problems, err := Validate([]byte("remote_cache:\n  backend: local\n  path: /mnt/cache\n"),
+		Override{Key: "remote_cache.backend", Value: "ftp", Source: "TOPOSCOPE_REMOTE_CACHE_BACKEND"},
+		Override{Key: "scoring.normalizaton", Value: "size", Source: "--set"},
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.RemoteCache.Path != "/mnt/cache" {
+		t.Errorf("file value lost: %+v", cfg.RemoteCache)
+	}
+	want := []Problem{
+		{Path: "scoring.normalizaton", Message: "unknown key normalizaton (did you mean normalization?) (set by --set)"},
+		{Path: "remote_cache.backend", Message: `unknown backend "ftp" (want s3, gcs, or local) (set by TOPOSCOPE_REMOTE_CACHE_BACKEND)`},
+	}
+	if len(problems) != len(want) {
+		t.Fatalf("problems = %+v, want %+v", problems, want)
+	}
+	for i := range want {
+		if problems[i] != want[i] {
+			t.Errorf("problem %d = %+v, want %+v", i, problems[i], want[i])
+		}
+	}
+}
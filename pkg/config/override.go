@@ -0,0 +1,184 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// EnvPrefix starts the environment variables that override config keys. The
+// rest of the name is the key path in upper case with "_" between keys, as
+// in TOPOSCOPE_EXTRACTION_TIMEOUT or TOPOSCOPE_SCORING_WEIGHTS_FANOUT_WEIGHT.
+const EnvPrefix = "TOPOSCOPE_"
+
+// Override sets one config key over the config file.
+type Override struct {
+	Key    string // dotted key path, e.g. scoring.weights.fanout_weight
+	Value  string // a YAML value; lists of strings may also be comma-separated
+	Source string // the environment variable, or "--set"
+}
+
+// ParseSet parses a --set flag value of the form key=value.
+func ParseSet(s string) (Override, error) {
+	key, value, ok := strings.Cut(s, "=")
+	key = strings.TrimSpace(key)
+	if !ok || key == "" {
+		return Override{}, fmt.Errorf("--set %q: want key=value", s)
+	}
+	return Override{Key: key, Value: value, Source: "--set"}, nil
+}
+
+// EnvOverrides returns the overrides in environ, a list of NAME=value
+// entries as from os.Environ, sorted by key. Only variables that start with
+// EnvPrefix and a config section, such as TOPOSCOPE_SCORING_, are
+// overrides; other TOPOSCOPE_ variables are left alone.
+func EnvOverrides(environ []string) ([]Override, error) {
+	sections := yamlFields(reflect.TypeOf(Config{}))
+	var overrides []Override
+	for _, kv := range environ {
+		name, value, _ := strings.Cut(kv, "=")
+		rest, ok := strings.CutPrefix(name, EnvPrefix)
+		if !ok {
+			continue
+		}
+		rest = strings.ToLower(rest)
+		isSection := false
+		for section := range sections {
+			if strings.HasPrefix(rest, section+"_") {
+				isSection = true
+			}
+		}
+		if !isSection {
+			continue
+		}
+		key, ok := envKey(reflect.TypeOf(Config{}), rest)
+		if !ok {
+			return nil, fmt.Errorf("%s: no config key %s", name, strings.ReplaceAll(rest, "_", "."))
+		}
+		overrides = append(overrides, Override{Key: key, Value: value, Source: name})
+	}
+	sort.Slice(overrides, func(i, j int) bool { return overrides[i].Key < overrides[j].Key })
+	return overrides, nil
+}
+
+// envKey maps the lower-cased rest of an environment variable name to the
+// dotted key path of t it names. Keys contain "_" too, so at each level the
+// longest key that fits is taken. Below a map, the rest is the map key.
+func envKey(t reflect.Type, rest string) (string, bool) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Map:
+		return rest, rest != ""
+	case reflect.Struct:
+		fields := yamlFields(t)
+		names := make([]string, 0, len(fields))
+		for name := range fields {
+			names = append(names, name)
+		}
+		sort.Slice(names, func(i, j int) bool { return len(names[i]) > len(names[j]) })
+		for _, name := range names {
+			if rest == name {
+				return name, true
+			}
+			if tail, ok := strings.CutPrefix(rest, name+"_"); ok {
+				if key, ok := envKey(fields[name], tail); ok {
+					return name + "." + key, true
+				}
+			}
+		}
+	}
+	return "", false
+}
+
+// Apply sets each override on cfg, in order, so later ones win.
+func Apply(cfg *Config, overrides []Override) error {
+	for _, o := range overrides {
+		if err := setKey(reflect.ValueOf(cfg).Elem(), strings.Split(o.Key, "."), o.Value); err != nil {
+			return fmt.Errorf("%s: %s: %w", o.Source, o.Key, err)
+		}
+	}
+	return nil
+}
+
+// Resolve returns the effective config: the defaults, then the config file
+// at path (if any), then overrides.
+func Resolve(path string, overrides []Override) (*Config, error) {
+	cfg := DefaultConfig()
+	if path != "" {
+		var err error
+		if cfg, err = Load(path); err != nil {
+			return nil, err
+		}
+	}
+	if err := Apply(cfg, overrides); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// setKey sets the value at path below v, a struct field, map entry, or
+// whole value, from YAML.
+func setKey(v reflect.Value, path []string, value string) error {
+	for v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		v = v.Elem()
+	}
+	if len(path) == 0 {
+		return decodeValue(v, value)
+	}
+	switch v.Kind() {
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			name, _, _ := strings.Cut(v.Type().Field(i).Tag.Get("yaml"), ",")
+			if name == path[0] {
+				return setKey(v.Field(i), path[1:], value)
+			}
+		}
+		msg := fmt.Sprintf("unknown key %s", path[0])
+		if s := closest(path[0], yamlFields(v.Type())); s != "" {
+			msg += fmt.Sprintf(" (did you mean %s?)", s)
+		}
+		return errors.New(msg)
+	case reflect.Map:
+		if v.IsNil() {
+			v.Set(reflect.MakeMap(v.Type()))
+		}
+		elem := reflect.New(v.Type().Elem()).Elem()
+		if err := decodeValue(elem, value); err != nil {
+			return err
+		}
+		v.SetMapIndex(reflect.ValueOf(strings.Join(path, ".")), elem)
+		return nil
+	}
+	return fmt.Errorf("%s has no keys", v.Type())
+}
+
+// decodeValue decodes value into v. An empty value clears v.
+func decodeValue(v reflect.Value, value string) error {
+	if strings.TrimSpace(value) == "" {
+		v.Set(reflect.Zero(v.Type()))
+		return nil
+	}
+	if v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.String && !strings.HasPrefix(strings.TrimSpace(value), "[") {
+		items := strings.Split(value, ",")
+		for i := range items {
+			items[i] = strings.TrimSpace(items[i])
+		}
+		v.Set(reflect.ValueOf(items))
+		return nil
+	}
+	p := reflect.New(v.Type())
+	if err := yaml.Unmarshal([]byte(value), p.Interface()); err != nil {
+		return fmt.Errorf("invalid value %q (want %s)", value, v.Type())
+	}
+	v.Set(p.Elem())
+	return nil
+}
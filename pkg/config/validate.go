@@ -0,0 +1,368 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Problem is one thing wrong with a config file.
+type Problem struct {
+	Path    string `json:"path,omitempty"` // e.g. scoring.waivers[0].expires
+	Line    int    `json:"line,omitempty"` // 1-based, when known
+	Message string `json:"message"`
+}
+
+func (p Problem) String() string {
+	s := p.Message
+	if p.Path != "" {
+		s = p.Path + ": " + s
+	}
+	if p.Line > 0 {
+		s = fmt.Sprintf("line %d: %s", p.Line, s)
+	}
+	return s
+}
+
+// Validate parses a config file and checks it for keys the config doesn't
+// have, values of the wrong type, and values it can't use: unknown enum
+// values, bad glob patterns and regular expressions, negative limits, and
+// incomplete waivers, external metrics, and remote caches. It returns the
+// config the file resolves to, with defaults filled in and overrides
+// applied, and what's wrong with it. Only a file that isn't YAML at all
+// returns an error.
+func Validate(data []byte, overrides ...Override) (*Config, []Problem, error) {
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return nil, nil, fmt.Errorf("parsing config: %w", err)
+	}
+	v := &validator{}
+	if len(root.Content) > 0 {
+		v.root = root.Content[0]
+		v.checkKeys(v.root, reflect.TypeOf(Config{}), "")
+	}
+
+	cfg := DefaultConfig()
+	if err := yaml.Unmarshal(data, cfg); err != nil {
+		var te *yaml.TypeError
+		if !errors.As(err, &te) {
+			return nil, nil, fmt.Errorf("parsing config: %w", err)
+		}
+		for _, msg := range te.Errors {
+			v.problems = append(v.problems, v.typeProblem(msg))
+		}
+	}
+	v.overridden = make(map[string]string)
+	for _, o := range overrides {
+		if err := setKey(reflect.ValueOf(cfg).Elem(), strings.Split(o.Key, "."), o.Value); err != nil {
+			v.problems = append(v.problems, Problem{Path: o.Key, Message: fmt.Sprintf("%v (set by %s)", err, o.Source)})
+			continue
+		}
+		v.overridden[o.Key] = o.Source
+	}
+	v.checkValues(cfg)
+
+	sort.SliceStable(v.problems, func(i, j int) bool { return v.problems[i].Line < v.problems[j].Line })
+	return cfg, v.problems, nil
+}
+
+type validator struct {
+	root       *yaml.Node
+	overridden map[string]string // key path to the override that set it
+	problems   []Problem
+}
+
+// addf records a problem at the dotted path of keys and sequence indexes.
+// A problem with an overridden value names the override instead of a line.
+func (v *validator) addf(at []any, format string, args ...any) {
+	p := Problem{Path: formatPath(at), Message: fmt.Sprintf(format, args...)}
+	for key, source := range v.overridden {
+		if p.Path == key || strings.HasPrefix(p.Path, key+".") || strings.HasPrefix(p.Path, key+"[") {
+			p.Message += fmt.Sprintf(" (set by %s)", source)
+			v.problems = append(v.problems, p)
+			return
+		}
+	}
+	p.Line = v.line(at)
+	v.problems = append(v.problems, p)
+}
+
+// line returns the line of the node at path, or of the closest ancestor
+// present in the file.
+func (v *validator) line(at []any) int {
+	n := v.root
+	if n == nil {
+		return 0
+	}
+	line := n.Line
+	for _, seg := range at {
+		var next *yaml.Node
+		switch seg := seg.(type) {
+		case string:
+			if n.Kind == yaml.MappingNode {
+				for i := 0; i+1 < len(n.Content); i += 2 {
+					if n.Content[i].Value == seg {
+						line, next = n.Content[i].Line, n.Content[i+1]
+						break
+					}
+				}
+			}
+		case int:
+			if n.Kind == yaml.SequenceNode && seg < len(n.Content) {
+				next = n.Content[seg]
+				line = next.Line
+			}
+		}
+		if next == nil {
+			break
+		}
+		n = next
+	}
+	return line
+}
+
+func formatPath(at []any) string {
+	var b strings.Builder
+	for _, seg := range at {
+		switch seg := seg.(type) {
+		case int:
+			fmt.Fprintf(&b, "[%d]", seg)
+		default:
+			if b.Len() > 0 {
+				b.WriteByte('.')
+			}
+			fmt.Fprint(&b, seg)
+		}
+	}
+	return b.String()
+}
+
+// checkKeys reports the mapping keys under n that t has no field for.
+func (v *validator) checkKeys(n *yaml.Node, t reflect.Type, at string) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch {
+	case t.Kind() == reflect.Struct && n.Kind == yaml.MappingNode:
+		fields := yamlFields(t)
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			key, val := n.Content[i], n.Content[i+1]
+			path := joinPath(at, key.Value)
+			ft, ok := fields[key.Value]
+			if !ok {
+				msg := "unknown key"
+				if s := closest(key.Value, fields); s != "" {
+					msg += fmt.Sprintf(" (did you mean %s?)", s)
+				}
+				v.problems = append(v.problems, Problem{Path: path, Line: key.Line, Message: msg})
+				continue
+			}
+			v.checkKeys(val, ft, path)
+		}
+	case t.Kind() == reflect.Map && n.Kind == yaml.MappingNode:
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			v.checkKeys(n.Content[i+1], t.Elem(), joinPath(at, n.Content[i].Value))
+		}
+	case t.Kind() == reflect.Slice && n.Kind == yaml.SequenceNode:
+		for i, item := range n.Content {
+			v.checkKeys(item, t.Elem(), at+"["+strconv.Itoa(i)+"]")
+		}
+	}
+}
+
+func joinPath(at, key string) string {
+	if at == "" {
+		return key
+	}
+	return at + "." + key
+}
+
+// yamlFields maps the yaml keys of t's fields to their types.
+func yamlFields(t reflect.Type) map[string]reflect.Type {
+	fields := make(map[string]reflect.Type, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, _, _ := strings.Cut(f.Tag.Get("yaml"), ",")
+		if !f.IsExported() || name == "-" {
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(f.Name)
+		}
+		fields[name] = f.Type
+	}
+	return fields
+}
+
+// closest returns the key of fields within two edits of key, if any.
+func closest(key string, fields map[string]reflect.Type) string {
+	best, bestDist := "", 3
+	for name := range fields {
+		if d := editDistance(key, name); d < bestDist || (d == bestDist && name < best) {
+			best, bestDist = name, d
+		}
+	}
+	return best
+}
+
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
+
+var typeErrorLine = regexp.MustCompile(`^line (\d+): (.*)$`)
+
+// typeProblem converts one of yaml's type errors, such as "line 3: cannot
+// unmarshal !!str `x` into int", naming the value on that line.
+func (v *validator) typeProblem(msg string) Problem {
+	m := typeErrorLine.FindStringSubmatch(msg)
+	if m == nil {
+		return Problem{Message: msg}
+	}
+	line, _ := strconv.Atoi(m[1])
+	return Problem{Path: pathAtLine(v.root, line, ""), Line: line, Message: m[2]}
+}
+
+// pathAtLine returns the path of the value under n that starts on line.
+func pathAtLine(n *yaml.Node, line int, at string) string {
+	if n == nil {
+		return ""
+	}
+	switch n.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			key, val := n.Content[i], n.Content[i+1]
+			path := joinPath(at, key.Value)
+			if p := pathAtLine(val, line, path); p != "" {
+				return p
+			}
+			if key.Line == line || val.Line == line {
+				return path
+			}
+		}
+	case yaml.SequenceNode:
+		for i, item := range n.Content {
+			path := at + "[" + strconv.Itoa(i) + "]"
+			if p := pathAtLine(item, line, path); p != "" {
+				return p
+			}
+			if item.Line == line {
+				return path
+			}
+		}
+	}
+	return ""
+}
+
+func (v *validator) checkValues(cfg *Config) {
+	sc := cfg.Scoring
+	switch sc.Normalization {
+	case "", "size":
+	default:
+		v.addf([]any{"scoring", "normalization"}, "unknown normalization %q (want size, or leave unset)", sc.Normalization)
+	}
+	if sc.ConfidenceLevel < 0 || sc.ConfidenceLevel >= 1 {
+		v.addf([]any{"scoring", "confidence_level"}, "must be at least 0 and below 1")
+	}
+	metricKeys := make(map[string]bool)
+	for i, em := range sc.ExternalMetrics {
+		at := []any{"scoring", "external_metrics", i}
+		if em.Key == "" || em.Command == "" {
+			v.addf(at, "key and command are required")
+		}
+		if metricKeys[em.Key] {
+			v.addf(append(at, "key"), "duplicate metric key %q", em.Key)
+		}
+		metricKeys[em.Key] = true
+		if em.Timeout < 0 {
+			v.addf(append(at, "timeout"), "must not be negative")
+		}
+	}
+	for i, w := range sc.Waivers {
+		at := []any{"scoring", "waivers", i}
+		if w.Target == "" {
+			v.addf(at, "target is required")
+		}
+		if w.Expires != "" {
+			if _, err := time.Parse("2006-01-02", w.Expires); err != nil {
+				v.addf(append(at, "expires"), "invalid date %q (want YYYY-MM-DD)", w.Expires)
+			}
+		}
+	}
+
+	ex := cfg.Extraction
+	for _, limit := range []struct {
+		key   string
+		value int
+	}{
+		{"timeout", ex.Timeout},
+		{"max_memory_mb", ex.MaxMemoryMB},
+		{"max_cpu_seconds", ex.MaxCPUSeconds},
+		{"shard_parallelism", ex.ShardParallelism},
+		{"hash_cache_max_mb", ex.HashCacheMaxMB},
+		{"hash_cache_ttl_days", ex.HashCacheTTLDays},
+	} {
+		if limit.value < 0 {
+			v.addf([]any{"extraction", limit.key}, "must not be negative")
+		}
+	}
+	if g := ex.Generated; g != nil {
+		for _, list := range []struct {
+			key      string
+			patterns []string
+		}{{"kinds", g.Kinds}, {"tags", g.Tags}} {
+			for i, pat := range list.patterns {
+				if _, err := path.Match(pat, ""); err != nil {
+					v.addf([]any{"extraction", "generated", list.key, i}, "bad glob pattern %q", pat)
+				}
+			}
+		}
+	}
+	for i, r := range ex.Normalize {
+		at := []any{"extraction", "normalize", i, "match"}
+		if r.Match == "" {
+			v.addf(at, "match is required")
+		} else if _, err := regexp.Compile(r.Match); err != nil {
+			v.addf(at, "bad regular expression: %v", err)
+		}
+	}
+
+	rc := cfg.RemoteCache
+	at := []any{"remote_cache"}
+	switch rc.Backend {
+	case "":
+	case "s3", "gcs":
+		if rc.Bucket == "" {
+			v.addf(append(at, "bucket"), "required for the %s backend", rc.Backend)
+		}
+	case "local":
+		if rc.Path == "" {
+			v.addf(append(at, "path"), "required for the local backend")
+		}
+	default:
+		v.addf(append(at, "backend"), "unknown backend %q (want s3, gcs, or local)", rc.Backend)
+	}
+}
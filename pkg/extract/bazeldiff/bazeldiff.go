@@ -8,6 +8,7 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"runtime"
 	"strings"
 	"time"
 
@@ -22,17 +23,23 @@ type Runner struct {
 	BazelRC          string // .bazelrc file to use
 	UseCQuery        bool
 	CacheDir         string // where to store hash files
+	CacheLimits      CacheLimits
+	OutputBase       string // bazel --output_base, if set
+	KeepServer       bool   // keep the bazel server running (--max_idle_secs=0)
 }
 
 // externalTargetPrefixes lists target prefixes to filter out from impacted targets.
 var externalTargetPrefixes = []string{"@pip", "@maven", "@com_", "."}
 
 // GenerateHashes runs bazel-diff generate-hashes for the given commit.
-// If a cached hash file exists, it returns immediately.
+// If a cached hash file exists for the commit and current flags, it returns
+// immediately.
 func (r *Runner) GenerateHashes(ctx context.Context, commitSHA string) (string, error) {
-	hashFile := filepath.Join(r.CacheDir, commitSHA+".json")
+	hashFile := r.HashFilePath(commitSHA)
 
 	if _, err := os.Stat(hashFile); err == nil {
+		now := time.Now()
+		_ = os.Chtimes(hashFile, now, now) // mark as recently used for LRU eviction
 		return hashFile, nil
 	}
 
@@ -51,6 +58,9 @@ func (r *Runner) GenerateHashes(ctx context.Context, commitSHA string) (string,
 		return "", fmt.Errorf("generate-hashes for %s failed: %w\nstderr: %s", commitSHA, err, stderr.String())
 	}
 
+	// Best effort: a failed prune shouldn't fail change detection.
+	_, _, _ = PruneCache(r.CacheDir, r.CacheLimits)
+
 	return hashFile, nil
 }
 
@@ -114,7 +124,7 @@ func (r *Runner) buildCommand(ctx context.Context, subcommand string, extraArgs
 	if r.BazelDiffJarPath != "" {
 		args := []string{"-jar", r.BazelDiffJarPath, subcommand}
 		args = append(args, extraArgs...)
-		return exec.CommandContext(ctx, "java", args...)
+		return exec.CommandContext(ctx, javaPath(), args...)
 	}
 
 	bazel := r.BazelPath
@@ -143,6 +153,12 @@ func (r *Runner) buildGenerateHashesArgs(commitSHA, outputFile string) []string
 	} else {
 		args = append(args, "-so", "--nohome_rc")
 	}
+	if r.OutputBase != "" {
+		args = append(args, "-so", "--output_base="+r.OutputBase)
+	}
+	if r.KeepServer {
+		args = append(args, "-so", "--max_idle_secs=0")
+	}
 
 	if r.UseCQuery {
 		args = append(args, "--useCquery")
@@ -213,3 +229,18 @@ func NormalizeLabel(label string) string {
 
 	return label
 }
+
+// javaPath returns the java of $JAVA_HOME, which Windows installers set
+// without putting java on PATH, falling back to java on PATH.
+func javaPath() string {
+	if home := os.Getenv("JAVA_HOME"); home != "" {
+		java := filepath.Join(home, "bin", "java")
+		if runtime.GOOS == "windows" {
+			java += ".exe"
+		}
+		if _, err := os.Stat(java); err == nil {
+			return java
+		}
+	}
+	return "java"
+}
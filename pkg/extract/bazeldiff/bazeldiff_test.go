@@ -95,17 +95,17 @@ func TestGenerateHashesCaching(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	// Pre-create a cached hash file
-	cachedFile := filepath.Join(cacheDir, "abc123.json")
-	if err := os.WriteFile(cachedFile, []byte(`{"test": true}`), 0o644); err != nil {
-		t.Fatal(err)
-	}
-
 	runner := &Runner{
 		WorkspacePath: dir,
 		CacheDir:      cacheDir,
 	}
 
+	// Pre-create a cached hash file
+	cachedFile := runner.HashFilePath("abc123")
+	if err := os.WriteFile(cachedFile, []byte(`{"test": true}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
 	// Should return cached file without running bazel-diff
 	result, err := runner.GenerateHashes(context.Background(), "abc123")
 	if err != nil {
@@ -161,6 +161,8 @@ func TestBuildGenerateHashesArgs(t *testing.T) {
 		BazelPath:     "bazelisk",
 		BazelRC:       "/workspace/.bazelrc",
 		UseCQuery:     true,
+		OutputBase:    "/cache/output_base/main",
+		KeepServer:    true,
 	}
 
 	args := runner.buildGenerateHashesArgs("abc123", "/cache/abc123.json")
@@ -177,6 +179,8 @@ func TestBuildGenerateHashesArgs(t *testing.T) {
 	assertContains("-w", "/workspace")
 	assertContains("-o", "/cache/abc123.json")
 	assertContains("-b", "bazelisk")
+	assertContains("-so", "--output_base=/cache/output_base/main")
+	assertContains("-so", "--max_idle_secs=0")
 
 	hasUseCquery := false
 	for _, a := range args {
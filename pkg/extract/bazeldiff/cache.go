@@ -0,0 +1,114 @@
+package bazeldiff
+
+import (
+	"cmp"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+)
+
+// CacheLimits bounds the hash cache. Zero values mean unlimited.
+type CacheLimits struct {
+	MaxBytes int64         // total size of cached hash files
+	MaxAge   time.Duration // evict files not used within this window
+}
+
+// HashFilePath returns the cache path for a commit's hashes. The key includes
+// a digest of everything that affects hashing, so changed flags or .bazelrc
+// contents never return stale hashes.
+func (r *Runner) HashFilePath(commitSHA string) string {
+	return filepath.Join(r.CacheDir, commitSHA+"-"+r.flagsDigest()+".json")
+}
+
+func (r *Runner) flagsDigest() string {
+	h := sha256.New()
+	fmt.Fprintf(h, "bazel=%s\ncquery=%t\nbazelrc=%s\n", r.BazelPath, r.UseCQuery, r.BazelRC)
+
+	// The system and workspace .bazelrc are always loaded, plus the
+	// explicit one if set.
+	rcFiles := []string{systemBazelRC(), filepath.Join(r.WorkspacePath, ".bazelrc")}
+	if r.BazelRC != "" {
+		rc := r.BazelRC
+		if !filepath.IsAbs(rc) {
+			rc = filepath.Join(r.WorkspacePath, rc)
+		}
+		rcFiles = append(rcFiles, rc)
+	}
+	for _, rc := range rcFiles {
+		if data, err := os.ReadFile(rc); err == nil {
+			h.Write(data)
+		}
+	}
+
+	return hex.EncodeToString(h.Sum(nil))[:12]
+}
+
+// PruneCache removes hash files in dir not used within limits.MaxAge, then
+// evicts least recently used files until the total size fits limits.MaxBytes.
+// The most recently used file is always kept. It returns the number of files
+// removed and the bytes freed.
+func PruneCache(dir string, limits CacheLimits) (int, int64, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, 0, nil
+		}
+		return 0, 0, fmt.Errorf("reading hash cache: %w", err)
+	}
+
+	type cacheFile struct {
+		path    string
+		size    int64
+		modTime time.Time
+	}
+	var files []cacheFile
+	var total int64
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".json") {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		files = append(files, cacheFile{filepath.Join(dir, e.Name()), info.Size(), info.ModTime()})
+		total += info.Size()
+	}
+
+	// Newest first; eviction walks from the end.
+	sort.Slice(files, func(i, j int) bool { return files[i].modTime.After(files[j].modTime) })
+
+	var removed int
+	var freed int64
+	cutoff := time.Now().Add(-limits.MaxAge)
+	for i := len(files) - 1; i > 0; i-- {
+		f := files[i]
+		expired := limits.MaxAge > 0 && f.modTime.Before(cutoff)
+		oversize := limits.MaxBytes > 0 && total > limits.MaxBytes
+		if !expired && !oversize {
+			break
+		}
+		if err := os.Remove(f.path); err != nil {
+			return removed, freed, fmt.Errorf("removing %s: %w", f.path, err)
+		}
+		removed++
+		freed += f.size
+		total -= f.size
+	}
+
+	return removed, freed, nil
+}
+
+// systemBazelRC returns the path of the system-wide bazelrc bazel reads.
+func systemBazelRC() string {
+	if runtime.GOOS == "windows" {
+		return filepath.Join(cmp.Or(os.Getenv("ProgramData"), `C:\ProgramData`), "bazel.bazelrc")
+	}
+	return "/etc/bazel.bazelrc"
+}
@@ -0,0 +1,71 @@
+package bazeldiff
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestHashFilePathKeyedByFlags(t *testing.T) {
+	dir := t.TempDir()
+	runner := &Runner{WorkspacePath: dir, CacheDir: dir}
+	plain := runner.HashFilePath("abc123")
+
+	cquery := &Runner{WorkspacePath: dir, CacheDir: dir, UseCQuery: true}
+	if cquery.HashFilePath("abc123") == plain {
+		t.Error("expected --useCquery to change the cache key")
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, ".bazelrc"), []byte("build --config=ci\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if runner.HashFilePath("abc123") == plain {
+		t.Error("expected .bazelrc contents to change the cache key")
+	}
+}
+
+func TestPruneCache(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	write := func(name string, size int, age time.Duration) {
+		t.Helper()
+		p := filepath.Join(dir, name)
+		if err := os.WriteFile(p, make([]byte, size), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(p, now.Add(-age), now.Add(-age)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("new.json", 100, time.Minute)
+	write("mid.json", 100, time.Hour)
+	write("old.json", 100, 48*time.Hour)
+
+	removed, freed, err := PruneCache(dir, CacheLimits{MaxAge: 24 * time.Hour})
+	if err != nil {
+		t.Fatalf("PruneCache() error: %v", err)
+	}
+	if removed != 1 || freed != 100 {
+		t.Errorf("TTL prune removed %d files (%d bytes), want 1 (100)", removed, freed)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "old.json")); !os.IsNotExist(err) {
+		t.Error("expected old.json to be evicted")
+	}
+
+	removed, _, err = PruneCache(dir, CacheLimits{MaxBytes: 150})
+	if err != nil {
+		t.Fatalf("PruneCache() error: %v", err)
+	}
+	if removed != 1 {
+		t.Errorf("size prune removed %d files, want 1", removed)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "new.json")); err != nil {
+		t.Error("expected the most recently used file to be kept")
+	}
+
+	// The newest file survives even when it alone exceeds the limit.
+	if removed, _, _ := PruneCache(dir, CacheLimits{MaxBytes: 1}); removed != 0 {
+		t.Errorf("expected the last file to be kept, removed %d", removed)
+	}
+}
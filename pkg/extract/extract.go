@@ -4,6 +4,7 @@ package extract
 
 import (
 	"context"
+	"strings"
 	"time"
 
 	"github.com/toposcope/toposcope/pkg/graph"
@@ -20,6 +21,12 @@ type ExtractionRequest struct {
 	RepoPath  string          `json:"repo_path"` // local filesystem path to repo root
 	CommitSHA string          `json:"commit_sha"`
 	Scope     ExtractionScope `json:"scope"`
+
+	// Remote source, for extractors that fetch the repository themselves
+	// instead of reading RepoPath.
+	Repo           string `json:"repo,omitempty"`            // owner/name
+	Ref            string `json:"ref,omitempty"`             // branch to extract when CommitSHA is empty
+	InstallationID int64  `json:"installation_id,omitempty"` // GitHub App installation used to clone
 }
 
 // ExtractionScope controls what portion of the graph to extract.
@@ -69,12 +76,39 @@ type ChangeDetectionResult struct {
 
 // EdgeType constants for dependency classification.
 const (
-	EdgeTypeCompile   = "COMPILE"
-	EdgeTypeRuntime   = "RUNTIME"
-	EdgeTypeToolchain = "TOOLCHAIN"
-	EdgeTypeData      = "DATA"
+	EdgeTypeCompile        = "COMPILE"
+	EdgeTypeRuntime        = "RUNTIME"
+	EdgeTypeToolchain      = "TOOLCHAIN"
+	EdgeTypeData           = "DATA"
+	EdgeTypeExports        = "EXPORTS"
+	EdgeTypeImplementation = "IMPLEMENTATION"
+	EdgeTypePlugin         = "PLUGIN"
 )
 
-// DepAttributes lists which Bazel rule attributes constitute structural dependencies.
-// deps and runtime_deps for Phase 1; extensible later.
-var DepAttributes = []string{"deps", "runtime_deps"}
+// DefaultEdgeAttributes maps the Bazel rule attributes that constitute
+// structural dependencies to the edge type each produces. Other attributes,
+// such as exports, implementation_deps, plugins, and toolchains, are opt-in
+// through EdgeAttributes overrides.
+var DefaultEdgeAttributes = map[string]string{
+	"deps":         EdgeTypeCompile,
+	"runtime_deps": EdgeTypeRuntime,
+	"data":         EdgeTypeData,
+}
+
+// EdgeAttributes returns DefaultEdgeAttributes with the given overrides
+// applied. Edge types are upper-cased; an empty edge type disables the
+// attribute.
+func EdgeAttributes(overrides map[string]string) map[string]string {
+	attrs := make(map[string]string, len(DefaultEdgeAttributes)+len(overrides))
+	for attr, typ := range DefaultEdgeAttributes {
+		attrs[attr] = typ
+	}
+	for attr, typ := range overrides {
+		if typ == "" {
+			delete(attrs, attr)
+			continue
+		}
+		attrs[attr] = strings.ToUpper(typ)
+	}
+	return attrs
+}
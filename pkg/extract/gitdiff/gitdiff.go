@@ -0,0 +1,173 @@
+// Package gitdiff implements change detection without bazel-diff: changed
+// files from `git diff --name-only` are mapped to their owning Bazel packages
+// and expanded to reverse dependencies using a base snapshot.
+package gitdiff
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/toposcope/toposcope/pkg/extract"
+	"github.com/toposcope/toposcope/pkg/graph"
+)
+
+// globalFiles are workspace-level files whose changes can affect any target.
+// Mapping them to a package would under-report, so detection fails instead
+// and callers fall back to bazel-diff or a structural diff.
+var globalFiles = map[string]bool{
+	"WORKSPACE":         true,
+	"WORKSPACE.bazel":   true,
+	"MODULE.bazel":      true,
+	"MODULE.bazel.lock": true,
+	".bazelrc":          true,
+	".bazelversion":     true,
+}
+
+// Detector maps changed files to impacted targets using the base snapshot's
+// reverse dependencies.
+type Detector struct {
+	WorkspacePath string
+	Base          *graph.Snapshot // base snapshot used for package→target and rdeps lookups
+	RdepsDepth    int             // max reverse dependency depth; 0 means unlimited
+}
+
+// DetectChanges implements extract.ChangeDetector.
+func (d *Detector) DetectChanges(ctx context.Context, req extract.ChangeDetectionRequest) (*extract.ChangeDetectionResult, error) {
+	start := time.Now()
+
+	if d.Base == nil {
+		return nil, fmt.Errorf("native change detection requires a base snapshot")
+	}
+
+	dir := req.RepoPath
+	if dir == "" {
+		dir = d.WorkspacePath
+	}
+
+	files, err := git(ctx, dir, "diff", "--name-only", "--relative", req.BaseSHA, req.HeadSHA)
+	if err != nil {
+		return nil, fmt.Errorf("listing changed files: %w", err)
+	}
+	for _, f := range files {
+		if globalFiles[f] || strings.HasSuffix(f, ".bzl") {
+			return nil, fmt.Errorf("%s changed; it may affect any target", f)
+		}
+	}
+
+	// A package exists at either commit if it has a BUILD file there.
+	buildDirs := make(map[string]bool)
+	for _, sha := range []string{req.BaseSHA, req.HeadSHA} {
+		tree, err := git(ctx, dir, "ls-tree", "-r", "--name-only", sha)
+		if err != nil {
+			return nil, fmt.Errorf("listing files at %s: %w", sha, err)
+		}
+		for _, f := range tree {
+			if isBuildFile(f) {
+				buildDirs[path.Dir(f)] = true
+			}
+		}
+	}
+
+	packages := make(map[string]bool)
+	for _, f := range files {
+		if pkg, ok := owningPackage(f, buildDirs); ok {
+			packages[pkg] = true
+		}
+	}
+
+	return &extract.ChangeDetectionResult{
+		ImpactedTargets: ImpactedTargets(d.Base, packages, d.RdepsDepth),
+		Duration:        time.Since(start),
+	}, nil
+}
+
+// ImpactedTargets returns the targets in the given packages plus their
+// reverse dependencies in snap, up to depth hops (0 means unlimited).
+func ImpactedTargets(snap *graph.Snapshot, packages map[string]bool, depth int) []string {
+	rdeps := make(map[string][]string)
+	for _, e := range snap.Edges {
+		rdeps[e.To] = append(rdeps[e.To], e.From)
+	}
+
+	visited := make(map[string]bool)
+	var frontier []string
+	for key, n := range snap.Nodes {
+		if packages[n.Package] && !n.IsExternal {
+			visited[key] = true
+			frontier = append(frontier, key)
+		}
+	}
+
+	for hop := 0; len(frontier) > 0 && (depth <= 0 || hop < depth); hop++ {
+		var next []string
+		for _, key := range frontier {
+			for _, from := range rdeps[key] {
+				if !visited[from] {
+					visited[from] = true
+					next = append(next, from)
+				}
+			}
+		}
+		frontier = next
+	}
+
+	targets := make([]string, 0, len(visited))
+	for key := range visited {
+		targets = append(targets, key)
+	}
+	sort.Strings(targets)
+	return targets
+}
+
+// owningPackage returns the Bazel package ("//a/b") owning a workspace-relative
+// file: the nearest ancestor directory containing a BUILD file.
+func owningPackage(file string, buildDirs map[string]bool) (string, bool) {
+	dir := path.Dir(file)
+	for {
+		if buildDirs[dir] {
+			if dir == "." {
+				return "//", true
+			}
+			return "//" + dir, true
+		}
+		if dir == "." || dir == "/" {
+			return "", false
+		}
+		dir = path.Dir(dir)
+	}
+}
+
+func isBuildFile(file string) bool {
+	base := path.Base(file)
+	return base == "BUILD" || base == "BUILD.bazel"
+}
+
+func git(ctx context.Context, dir string, args ...string) ([]string, error) {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("git %s: %w: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+
+	var lines []string
+	for _, line := range strings.Split(stdout.String(), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines, nil
+}
+
+// Verify interface satisfaction at compile time.
+var _ extract.ChangeDetector = (*Detector)(nil)
@@ -0,0 +1,130 @@
+package gitdiff
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/toposcope/toposcope/pkg/extract"
+	"github.com/toposcope/toposcope/pkg/graph"
+)
+
+func testSnapshot() *graph.Snapshot {
+	return &graph.Snapshot{
+		Nodes: map[string]*graph.Node{
+			"//lib/core:core": {Key: "//lib/core:core", Package: "//lib/core"},
+			"//lib/util:util": {Key: "//lib/util:util", Package: "//lib/util"},
+			"//app/api:api":   {Key: "//app/api:api", Package: "//app/api"},
+			"//app/web:web":   {Key: "//app/web:web", Package: "//app/web"},
+		},
+		Edges: []graph.Edge{
+			{From: "//lib/util:util", To: "//lib/core:core", Type: "COMPILE"},
+			{From: "//app/api:api", To: "//lib/util:util", Type: "COMPILE"},
+		},
+	}
+}
+
+func TestImpactedTargets(t *testing.T) {
+	snap := testSnapshot()
+	pkgs := map[string]bool{"//lib/core": true}
+
+	got := ImpactedTargets(snap, pkgs, 0)
+	want := []string{"//app/api:api", "//lib/core:core", "//lib/util:util"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("ImpactedTargets(unlimited) = %v, want %v", got, want)
+	}
+
+	got = ImpactedTargets(snap, pkgs, 1)
+	want = []string{"//lib/core:core", "//lib/util:util"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("ImpactedTargets(depth 1) = %v, want %v", got, want)
+	}
+}
+
+func TestOwningPackage(t *testing.T) {
+	buildDirs := map[string]bool{".": true, "lib/core": true}
+	tests := []struct {
+		file string
+		want string
+	}{
+		{"lib/core/core.go", "//lib/core"},
+		{"lib/core/internal/x.go", "//lib/core"},
+		{"lib/core/BUILD.bazel", "//lib/core"},
+		{"README.md", "//"},
+		{"docs/guide.md", "//"},
+	}
+	for _, tt := range tests {
+		got, ok := owningPackage(tt.file, buildDirs)
+		if !ok || got != tt.want {
+			t.Errorf("owningPackage(%q) = %q, %v; want %q", tt.file, got, ok, tt.want)
+		}
+	}
+
+	if _, ok := owningPackage("docs/guide.md", map[string]bool{"lib": true}); ok {
+		t.Error("expected no owning package outside any BUILD tree")
+	}
+}
+
+func TestDetectChanges(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+
+	dir := t.TempDir()
+	run := func(args ...string) string {
+		t.Helper()
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		cmd.Env = append(os.Environ(),
+			"GIT_AUTHOR_NAME=test", "GIT_AUTHOR_EMAIL=test@example.com",
+			"GIT_COMMITTER_NAME=test", "GIT_COMMITTER_EMAIL=test@example.com")
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+		return strings.TrimSpace(string(out))
+	}
+	write := func(name, content string) {
+		t.Helper()
+		p := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	run("init", "-q")
+	write("MODULE.bazel", "")
+	write("lib/core/BUILD.bazel", "")
+	write("lib/core/core.go", "package core")
+	write("app/web/BUILD.bazel", "")
+	run("add", "-A")
+	run("commit", "-q", "-m", "base")
+	base := run("rev-parse", "HEAD")
+
+	write("lib/core/core.go", "package core // changed")
+	run("commit", "-q", "-am", "head")
+	head := run("rev-parse", "HEAD")
+
+	d := &Detector{WorkspacePath: dir, Base: testSnapshot()}
+	result, err := d.DetectChanges(context.Background(), extract.ChangeDetectionRequest{BaseSHA: base, HeadSHA: head})
+	if err != nil {
+		t.Fatalf("DetectChanges() error: %v", err)
+	}
+	want := []string{"//app/api:api", "//lib/core:core", "//lib/util:util"}
+	if strings.Join(result.ImpactedTargets, ",") != strings.Join(want, ",") {
+		t.Errorf("ImpactedTargets = %v, want %v", result.ImpactedTargets, want)
+	}
+
+	// Workspace-level changes can't be attributed to a package.
+	write("MODULE.bazel", "module(name = \"x\")")
+	run("commit", "-q", "-am", "module")
+	if _, err := d.DetectChanges(context.Background(), extract.ChangeDetectionRequest{BaseSHA: head, HeadSHA: "HEAD"}); err == nil {
+		t.Error("expected an error when MODULE.bazel changes")
+	}
+}
@@ -6,13 +6,16 @@ import (
 	"bytes"
 	"context"
 	"encoding/xml"
+	"errors"
 	"fmt"
 	"os/exec"
 	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
-	"github.com/google/uuid"
 	"github.com/toposcope/toposcope/pkg/extract"
 	"github.com/toposcope/toposcope/pkg/graph"
 )
@@ -26,6 +29,50 @@ type Extractor struct {
 	BazelPath     string
 	BazelRC       string
 	UseCQuery     bool
+
+	// EdgeAttributes maps rule attributes to edge types. Nil uses
+	// extract.DefaultEdgeAttributes.
+	EdgeAttributes map[string]string
+
+	// IncludeExternal retains dependencies on external repositories as one
+	// IsExternal node per repo (e.g. "@maven") instead of dropping them.
+	IncludeExternal bool
+
+	// RepoMapping maps external repository names ("@corp_foo") to the
+	// tracked repositories they are built from ("acme/foo"). Dependencies on
+	// a mapped repository are kept as one IsExternal node per target, with
+	// Repo set, whether or not IncludeExternal is.
+	RepoMapping map[string]string
+
+	// Generated identifies generated targets, which are marked
+	// IsGenerated. Nil uses graph.DefaultGeneratedPatterns.
+	Generated *graph.GeneratedPatterns
+
+	// LabelRewrites normalize internal target labels before they become
+	// node keys. Targets rewritten onto the same label are merged.
+	LabelRewrites []LabelRewrite
+
+	// Limits bound the memory and running time of bazel queries.
+	Limits ResourceLimits
+
+	// OutputBase, if set, is the bazel output base for the queries
+	// (--output_base). A dedicated output base keeps extraction from
+	// restarting the server of other bazel commands in the workspace.
+	OutputBase string
+
+	// KeepServer keeps the bazel server running until it is shut down
+	// (--max_idle_secs=0).
+	KeepServer bool
+
+	// ShardParallelism, if positive, makes ExtractFull query each top-level
+	// package tree separately, this many at a time, instead of the whole
+	// workspace at once. For workspaces where kind(rule, //...) times out.
+	ShardParallelism int
+
+	// ProfileDir, if set, is where each bazel query writes a JSON trace
+	// profile (--profile), named after the commit: "<sha>-query-1.profile.gz". The build phases the profiles record are added
+	// to the snapshot's phase timings.
+	ProfileDir string
 }
 
 // SubgraphRequest specifies what subgraph to extract.
@@ -41,6 +88,15 @@ type SubgraphRequest struct {
 func (e *Extractor) Extract(ctx context.Context, req SubgraphRequest) (*graph.Snapshot, error) {
 	start := time.Now()
 
+	opts, err := e.buildOptions()
+	if err != nil {
+		return nil, err
+	}
+	pt, err := e.newPhaseTimer(req.CommitSHA)
+	if err != nil {
+		return nil, err
+	}
+
 	if req.RdepDepth <= 0 {
 		req.RdepDepth = 2
 	}
@@ -53,26 +109,65 @@ func (e *Extractor) Extract(ctx context.Context, req SubgraphRequest) (*graph.Sn
 
 	chunks := chunkTargets(req.Targets, maxQueryLabelLength)
 	var allRules []xmlRule
+	var skipped []string
 
-	for _, chunk := range chunks {
+	for i, chunk := range chunks {
 		query := buildRdepsQuery(chunk, req.RdepDepth)
-		rules, err := e.runQuery(ctx, query)
+		rules, chunkSkipped, err := e.runQuery(ctx, query, pt)
 		if err != nil {
+			if ctx.Err() != nil {
+				return nil, &CancelledError{Chunks: i, TotalChunks: len(chunks), Targets: countTargets(allRules), Elapsed: time.Since(start), Err: ctx.Err()}
+			}
 			return nil, fmt.Errorf("query chunk failed: %w", err)
 		}
 		allRules = append(allRules, rules...)
+		skipped = append(skipped, chunkSkipped...)
 	}
 
-	snap := buildSnapshot(allRules, req.CommitSHA, req.Targets, start)
+	buildStart := time.Now()
+	snap := buildSnapshot(allRules, req.CommitSHA, req.Targets, opts, start)
+	pt.since("build", buildStart)
+	snap.Stats.SkippedPackages = dedupeSorted(skipped)
+	snap.Stats.Phases = pt.timings()
 	return snap, nil
 }
 
+// buildOptions returns the options that control how query results are
+// turned into a snapshot.
+func (e *Extractor) buildOptions() (buildOptions, error) {
+	generated := graph.DefaultGeneratedPatterns
+	if e.Generated != nil {
+		generated = *e.Generated
+	}
+	rewrite, err := compileRewrites(e.LabelRewrites)
+	if err != nil {
+		return buildOptions{}, err
+	}
+	var mapping map[string]string
+	for name, repo := range e.RepoMapping {
+		if mapping == nil {
+			mapping = make(map[string]string, len(e.RepoMapping))
+		}
+		mapping[externalRepoName(name)] = repo
+	}
+	return buildOptions{edgeAttrs: e.EdgeAttributes, includeExternal: e.IncludeExternal, repoMapping: mapping, workspaceRoot: e.WorkspacePath, generated: generated, rewrite: rewrite}, nil
+}
+
 // ExtractFull runs a full `bazel query kind(rule, //...)` to extract the complete graph.
 // Only internal rule targets are included; external deps (@maven, @pip, etc.) are excluded
-// as nodes but their edges are tracked for reference.
+// unless IncludeExternal is set, in which case they are aggregated per external repo.
 func (e *Extractor) ExtractFull(ctx context.Context, commitSHA string, timeout time.Duration) (*graph.Snapshot, error) {
 	start := time.Now()
 
+	opts, err := e.buildOptions()
+	if err != nil {
+		return nil, err
+	}
+	pt, err := e.newPhaseTimer(commitSHA)
+	if err != nil {
+		return nil, err
+	}
+
 	if timeout > 0 {
 		var cancel context.CancelFunc
 		ctx, cancel = context.WithTimeout(ctx, timeout)
@@ -82,28 +177,87 @@ func (e *Extractor) ExtractFull(ctx context.Context, commitSHA string, timeout t
 	// Use kind(rule, //...) to get only rule targets (excludes source files,
 	// generated files, and package groups). This is significantly faster and
 	// smaller than //... on large repos.
-	rules, err := e.runQuery(ctx, "kind(rule, //...)")
-	if err != nil {
-		return nil, fmt.Errorf("full query failed: %w", err)
+	var rules []xmlRule
+	var skipped []string
+	if e.ShardParallelism > 0 {
+		patterns, err := shardPatterns(e.WorkspacePath)
+		if err != nil {
+			return nil, err
+		}
+		if rules, skipped, err = e.queryShards(ctx, patterns, pt, start); err != nil {
+			return nil, err
+		}
+	} else {
+		rules, skipped, err = e.runQuery(ctx, "kind(rule, //...)", pt)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, &CancelledError{TotalChunks: 1, Elapsed: time.Since(start), Err: ctx.Err()}
+			}
+			return nil, fmt.Errorf("full query failed: %w", err)
+		}
 	}
 
-	snap := buildSnapshot(rules, commitSHA, nil, start)
+	buildStart := time.Now()
+	snap := buildSnapshot(rules, commitSHA, nil, opts, start)
+	pt.since("build", buildStart)
 	snap.Partial = false
+	snap.Stats.SkippedPackages = dedupeSorted(skipped)
+	snap.Stats.Phases = pt.timings()
 	return snap, nil
 }
 
-func (e *Extractor) runQuery(ctx context.Context, query string) ([]xmlRule, error) {
+// startupArgs returns the bazel startup options of every invocation.
+func (e *Extractor) startupArgs() []string {
+	var args []string
+	if e.OutputBase != "" {
+		args = append(args, "--output_base="+e.OutputBase)
+	}
+	if e.BazelRC != "" {
+		args = append(args, "--bazelrc="+e.BazelRC)
+	}
+	args = append(args, "--nohome_rc") // don't load user's .bazelrc
+	if e.KeepServer {
+		args = append(args, "--max_idle_secs=0")
+	}
+	return append(args, e.Limits.startupArgs()...)
+}
+
+// Shutdown stops the bazel server of the extractor's workspace and output
+// base, and those of its shard queries.
+func (e *Extractor) Shutdown(ctx context.Context) error {
+	var errs []error
+	for w := 0; w == 0 || (w < e.ShardParallelism && e.OutputBase != ""); w++ {
+		if err := e.shardWorker(w).shutdown(ctx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (e *Extractor) shutdown(ctx context.Context) error {
+	bazel := e.BazelPath
+	if bazel == "" {
+		bazel = "bazelisk"
+	}
+	cmd := exec.CommandContext(ctx, bazel, append(e.startupArgs(), "shutdown")...)
+	cmd.Dir = e.WorkspacePath
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("bazel shutdown: %w: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// runQuery runs a bazel query and returns the parsed rules along with any
+// packages bazel reported as failing to load. It times the query and the
+// parse with pt.
+func (e *Extractor) runQuery(ctx context.Context, query string, pt *phaseTimer) ([]xmlRule, []string, error) {
 	bazel := e.BazelPath
 	if bazel == "" {
 		bazel = "bazelisk"
 	}
 
 	// Startup options (before the command) must come first
-	var args []string
-	if e.BazelRC != "" {
-		args = append(args, "--bazelrc="+e.BazelRC)
-	}
-	args = append(args, "--nohome_rc") // don't load user's .bazelrc
+	args := e.startupArgs()
 
 	// Command
 	if e.UseCQuery {
@@ -114,22 +268,121 @@ func (e *Extractor) runQuery(ctx context.Context, query string) ([]xmlRule, erro
 
 	// Command flags
 	args = append(args, query, "--output=xml", "--order_output=no", "--keep_going", "--noimplicit_deps")
+	profile := pt.nextProfile()
+	if profile != "" {
+		args = append(args, "--profile="+profile)
+	}
 
-	cmd := exec.CommandContext(ctx, bazel, args...)
+	qctx, cancel := e.Limits.queryContext(ctx)
+	defer cancel()
+	cmd := exec.CommandContext(qctx, bazel, args...)
 	cmd.Dir = e.WorkspacePath
 
 	var stdout, stderr bytes.Buffer
 	cmd.Stdout = &stdout
 	cmd.Stderr = &stderr
 
-	if err := cmd.Run(); err != nil {
+	queryStart := time.Now()
+	err := cmd.Run()
+	pt.since("query", queryStart)
+	if profile != "" {
+		pt.addProfile(profile)
+	}
+	if err != nil {
+		// Output cut off by cancellation is incomplete XML.
+		if ctx.Err() != nil {
+			return nil, nil, ctx.Err()
+		}
+		if qctx.Err() != nil {
+			return nil, nil, fmt.Errorf("bazel query exceeded the %ds limit", e.Limits.CPUSeconds)
+		}
 		// bazel query with --keep_going may exit non-zero but still produce output
 		if stdout.Len() == 0 {
-			return nil, fmt.Errorf("bazel query failed: %w\nstderr: %s", err, stderr.String())
+			return nil, nil, fmt.Errorf("bazel query failed: %w\nstderr: %s", err, stderr.String())
 		}
 	}
 
-	return parseXML(stdout.Bytes())
+	parseStart := time.Now()
+	rules, err := parseXML(stdout.Bytes())
+	pt.since("parse", parseStart)
+	if err != nil {
+		return nil, nil, err
+	}
+	return rules, parseSkippedPackages(stderr.Bytes(), e.WorkspacePath), nil
+}
+
+var (
+	// e.g. "ERROR: error loading package 'app/foo': ..." or
+	// "no such package 'app/foo': BUILD file not found ..."
+	quotedPackageRe = regexp.MustCompile(`(?:error loading package|no such package) '([^']*)'`)
+	// e.g. "ERROR: /ws/app/foo/BUILD.bazel:12:8: ..."
+	buildFileErrorRe = regexp.MustCompile(`^ERROR: (\S+)/BUILD(?:\.bazel)?:\d+:\d+: `)
+)
+
+// parseSkippedPackages scans bazel stderr for package load failures that
+// --keep_going tolerated, returning them as //-prefixed package labels.
+// External repositories are ignored since they are excluded from snapshots.
+func parseSkippedPackages(stderr []byte, workspace string) []string {
+	var pkgs []string
+	for _, line := range strings.Split(string(stderr), "\n") {
+		line = strings.TrimSpace(line)
+		if m := quotedPackageRe.FindStringSubmatch(line); m != nil {
+			if pkg := normalizePackage(m[1]); pkg != "" {
+				pkgs = append(pkgs, pkg)
+			}
+			continue
+		}
+		if m := buildFileErrorRe.FindStringSubmatch(line); m != nil && workspace != "" {
+			rel, err := filepath.Rel(workspace, m[1])
+			if err != nil || strings.HasPrefix(rel, "..") {
+				continue
+			}
+			if rel == "." {
+				rel = ""
+			}
+			pkgs = append(pkgs, "//"+filepath.ToSlash(rel))
+		}
+	}
+	return dedupeSorted(pkgs)
+}
+
+// normalizePackage converts a package name as printed by bazel ("app/foo",
+// "//app/foo", "@//app/foo") to "//app/foo". Returns "" for external repos.
+func normalizePackage(pkg string) string {
+	pkg = strings.TrimPrefix(pkg, "@")
+	if strings.HasPrefix(pkg, "//") {
+		return pkg
+	}
+	if strings.Contains(pkg, "//") {
+		return "" // @repo//pkg
+	}
+	return "//" + pkg
+}
+
+// countTargets returns the number of distinct targets in rules. The rdeps
+// of different query chunks overlap, so a target may appear more than once.
+func countTargets(rules []xmlRule) int {
+	seen := make(map[string]bool, len(rules))
+	for _, rule := range rules {
+		seen[rule.Name] = true
+	}
+	return len(seen)
+}
+
+func dedupeSorted(items []string) []string {
+	if len(items) == 0 {
+		return nil
+	}
+	seen := make(map[string]bool, len(items))
+	var out []string
+	for _, it := range items {
+		if !seen[it] {
+			seen[it] = true
+			out = append(out, it)
+		}
+	}
+	sort.Strings(out)
+	return out
 }
 
 func buildRdepsQuery(targets []string, depth int) string {
@@ -177,10 +430,11 @@ type xmlQuery struct {
 }
 
 type xmlRule struct {
-	Class string       `xml:"class,attr"`
-	Name  string       `xml:"name,attr"`
-	Lists []xmlList    `xml:"list"`
-	Attrs []xmlAttrStr `xml:"string"`
+	Class    string       `xml:"class,attr"`
+	Name     string       `xml:"name,attr"`
+	Location string       `xml:"location,attr"` // "/abs/path/BUILD.bazel:12:5"
+	Lists    []xmlList    `xml:"list"`
+	Attrs    []xmlAttrStr `xml:"string"`
 }
 
 type xmlList struct {
@@ -245,36 +499,73 @@ func isExternalLabel(label string) bool {
 	return strings.HasPrefix(label, "@")
 }
 
-func buildSnapshot(rules []xmlRule, commitSHA string, scope []string, start time.Time) *graph.Snapshot {
+// externalRepo returns the repository name for an external label, e.g.
+// "@maven" for "@maven//:guava" and "@rules_go~" for "@@rules_go~//go:def".
+func externalRepo(label string) string {
+	repo := strings.TrimLeft(label, "@")
+	if idx := strings.Index(repo, "//"); idx >= 0 {
+		repo = repo[:idx]
+	}
+	return "@" + repo
+}
+
+// externalRepoName returns the apparent name of an external repository,
+// without @s or a bzlmod version suffix: "corp_foo" for "@corp_foo",
+// "@@corp_foo~" and "@@corp_foo+".
+func externalRepoName(repo string) string {
+	name := strings.TrimLeft(externalRepo(repo), "@")
+	if idx := strings.IndexAny(name, "~+"); idx >= 0 {
+		name = name[:idx]
+	}
+	return name
+}
+
+// buildOptions controls how query results are converted into a snapshot.
+type buildOptions struct {
+	edgeAttrs       map[string]string // nil uses extract.DefaultEdgeAttributes
+	includeExternal bool
+	repoMapping     map[string]string // apparent external repo name -> tracked repository
+	workspaceRoot   string            // BUILD file paths are made relative to this
+	generated       graph.GeneratedPatterns
+	rewrite         labelRewriter
+}
+
+func buildSnapshot(rules []xmlRule, commitSHA string, scope []string, opts buildOptions, start time.Time) *graph.Snapshot {
 	nodes := make(map[string]*graph.Node)
 	var edges []graph.Edge
 	seen := make(map[string]bool) // deduplicate edges
 
 	for _, rule := range rules {
-		label := NormalizeLabel(rule.Name)
-
 		// Skip external targets entirely — they're not part of the codebase's
 		// architecture. This dramatically reduces graph size on large monorepos.
 		if isExternalLabel(rule.Name) {
 			continue
 		}
 
+		label := opts.rewrite.apply(NormalizeLabel(rule.Name))
 		pkg := labelToPackage(label)
 
-		node := &graph.Node{
-			Key:        label,
-			Kind:       rule.Class,
-			Package:    pkg,
-			Tags:       extractTags(rule),
-			Visibility: extractVisibility(rule),
-			IsTest:     isTestRule(rule.Class),
-			IsExternal: false,
+		// A target rewritten onto an existing label merges into that node:
+		// the first target keeps its attributes and the rest add edges.
+		if nodes[label] == nil {
+			node := &graph.Node{
+				Key:        label,
+				Kind:       rule.Class,
+				Language:   graph.KindLanguage(rule.Class),
+				Package:    pkg,
+				Tags:       extractTags(rule),
+				Visibility: extractVisibility(rule),
+				IsTest:     isTestRule(rule.Class),
+				IsExternal: false,
+			}
+			node.IsGenerated = opts.generated.Match(node)
+			nodes[label] = node
 		}
-		nodes[label] = node
+		buildFile, buildLine := buildFileLocation(rule.Location, opts.workspaceRoot)
 
 		// Extract dependency edges
 		for _, list := range rule.Lists {
-			edgeType := classifyDep(list.Name)
+			edgeType := classifyDep(opts.edgeAttrs, list.Name)
 			if edgeType == "" {
 				continue
 			}
@@ -283,7 +574,40 @@ func buildSnapshot(rules []xmlRule, commitSHA string, scope []string, start time
 
 				// Skip edges to external deps — they add noise without
 				// architectural signal. We care about internal coupling.
+				// When retained, they collapse onto one node per repo.
+				// Deps on repos mapped to tracked repositories keep their
+				// targets, so org views can connect them.
 				if isExternalLabel(dep.Value) {
+					if tracked, ok := opts.repoMapping[externalRepoName(dep.Value)]; ok {
+						if nodes[depLabel] == nil {
+							nodes[depLabel] = &graph.Node{
+								Key:        depLabel,
+								Kind:       "external_target",
+								Package:    labelToPackage(depLabel),
+								IsExternal: true,
+								Repo:       tracked,
+							}
+						}
+					} else if !opts.includeExternal {
+						continue
+					} else {
+						repo := externalRepo(dep.Value)
+						if nodes[repo] == nil {
+							nodes[repo] = &graph.Node{
+								Key:        repo,
+								Kind:       "external_repo",
+								Package:    repo,
+								IsExternal: true,
+							}
+						}
+						depLabel = repo
+					}
+				} else {
+					depLabel = opts.rewrite.apply(depLabel)
+				}
+
+				// Dependencies between merged targets are internal to the node.
+				if depLabel == label {
 					continue
 				}
 
@@ -291,9 +615,12 @@ func buildSnapshot(rules []xmlRule, commitSHA string, scope []string, start time
 				if !seen[eKey] {
 					seen[eKey] = true
 					edges = append(edges, graph.Edge{
-						From: label,
-						To:   depLabel,
-						Type: edgeType,
+						From:      label,
+						To:        depLabel,
+						Type:      edgeType,
+						Attr:      list.Name,
+						BuildFile: buildFile,
+						BuildLine: buildLine,
 					})
 				}
 			}
@@ -302,16 +629,15 @@ func buildSnapshot(rules []xmlRule, commitSHA string, scope []string, start time
 
 	pkgs := make(map[string]bool)
 	for _, n := range nodes {
-		if n.Package != "" {
+		if n.Package != "" && !n.IsExternal {
 			pkgs[n.Package] = true
 		}
 	}
 
 	snap := &graph.Snapshot{
-		ID:        uuid.New().String(),
 		CommitSHA: commitSHA,
 		Partial:   len(scope) > 0,
-		Scope:     scope,
+		Scope:     rewriteScope(scope, opts.rewrite),
 		Nodes:     nodes,
 		Edges:     edges,
 		Stats: graph.SnapshotStats{
@@ -322,10 +648,32 @@ func buildSnapshot(rules []xmlRule, commitSHA string, scope []string, start time
 		},
 		ExtractedAt: time.Now(),
 	}
+	snap.ID = graph.ContentID(snap)
 
 	return snap
 }
 
+// rewriteScope maps scope targets onto the node keys they were rewritten to,
+// so baseline merging sees the same labels as the snapshot's nodes.
+func rewriteScope(scope []string, rewrite labelRewriter) []string {
+	if len(rewrite) == 0 {
+		return scope
+	}
+	out := make([]string, 0, len(scope))
+	seen := make(map[string]bool, len(scope))
+	for _, target := range scope {
+		label := target
+		if !isExternalLabel(target) {
+			label = rewrite.apply(NormalizeLabel(target))
+		}
+		if !seen[label] {
+			seen[label] = true
+			out = append(out, label)
+		}
+	}
+	return out
+}
+
 // NormalizeLabel normalizes a Bazel label to canonical form.
 func NormalizeLabel(label string) string {
 	label = strings.TrimSpace(label)
@@ -385,15 +733,40 @@ func isTestRule(ruleClass string) bool {
 	return strings.HasSuffix(ruleClass, "_test") || strings.HasSuffix(ruleClass, "_tests") || ruleClass == "test_suite"
 }
 
-func classifyDep(attrName string) string {
-	switch attrName {
-	case "deps":
-		return extract.EdgeTypeCompile
-	case "runtime_deps":
-		return extract.EdgeTypeRuntime
-	case "data":
-		return extract.EdgeTypeData
-	default:
-		return ""
+// buildFileLocation splits a rule location ("/ws/app/BUILD.bazel:12:5") into
+// the BUILD file path, relative to root when it lies inside it, and the line.
+func buildFileLocation(location, root string) (string, int) {
+	file, line := location, 0
+	// Strip ":line:col" (or just ":line") from the end.
+	for i := 0; i < 2; i++ {
+		idx := strings.LastIndex(file, ":")
+		if idx < 0 {
+			break
+		}
+		n, err := strconv.Atoi(file[idx+1:])
+		if err != nil {
+			break
+		}
+		file, line = file[:idx], n
+	}
+	if file == "" {
+		return "", 0
+	}
+	if root != "" {
+		if abs, err := filepath.Abs(root); err == nil {
+			if rel, err := filepath.Rel(abs, file); err == nil && !strings.HasPrefix(rel, "..") {
+				file = rel
+			}
+		}
+	}
+	return filepath.ToSlash(file), line
+}
+
+// classifyDep returns the edge type for a rule attribute, or "" if the
+// attribute does not produce dependency edges.
+func classifyDep(edgeAttrs map[string]string, attrName string) string {
+	if edgeAttrs == nil {
+		edgeAttrs = extract.DefaultEdgeAttributes
 	}
+	return edgeAttrs[attrName]
 }
@@ -1,8 +1,19 @@
 package subgraph
 
 import (
+	"compress/gzip"
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
 	"testing"
 	"time"
+
+	"github.com/toposcope/toposcope/pkg/extract"
+	"github.com/toposcope/toposcope/pkg/graph"
 )
 
 func TestNormalizeLabel(t *testing.T) {
@@ -186,7 +197,7 @@ func TestBuildSnapshot(t *testing.T) {
 		},
 	}
 
-	snap := buildSnapshot(rules, "abc123", []string{"//app/foo:lib"}, time.Now())
+	snap := buildSnapshot(rules, "abc123", []string{"//app/foo:lib"}, buildOptions{}, time.Now())
 	if snap.CommitSHA != "abc123" {
 		t.Errorf("CommitSHA = %q, want abc123", snap.CommitSHA)
 	}
@@ -229,13 +240,17 @@ func TestClassifyDep(t *testing.T) {
 		{"deps", "COMPILE"},
 		{"runtime_deps", "RUNTIME"},
 		{"data", "DATA"},
+		{"exports", ""},
+		{"implementation_deps", ""},
+		{"plugins", ""},
+		{"toolchains", ""},
 		{"srcs", ""},
 		{"tools", ""},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.attr, func(t *testing.T) {
-			got := classifyDep(tt.attr)
+			got := classifyDep(nil, tt.attr)
 			if got != tt.want {
 				t.Errorf("classifyDep(%q) = %q, want %q", tt.attr, got, tt.want)
 			}
@@ -265,3 +280,481 @@ func TestIsTestRule(t *testing.T) {
 		})
 	}
 }
+
+func TestParseSkippedPackages(t *testing.T) {
+	stderr := `Loading: 0 packages loaded
+ERROR: error loading package 'app/broken': Unable to find package for @rules_foo//foo:defs.bzl
+ERROR: /ws/lib/bad/BUILD.bazel:12:8: no such target '//lib/missing:lib'
+ERROR: /ws/BUILD:3:1: name 'undefined_rule' is not defined
+WARNING: no such package '@maven//': repository not defined
+ERROR: no such package 'app/broken': BUILD file not found
+ERROR: /elsewhere/pkg/BUILD:1:1: outside the workspace
+INFO: Empty results
+`
+	got := parseSkippedPackages([]byte(stderr), "/ws")
+	want := []string{"//", "//app/broken", "//lib/bad"}
+	if len(got) != len(want) {
+		t.Fatalf("parseSkippedPackages() = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("parseSkippedPackages()[%d] = %q, want %q", i, got[i], want[i])
+		}
+	}
+}
+
+func TestParseSkippedPackagesClean(t *testing.T) {
+	if got := parseSkippedPackages([]byte("Loading: 0 packages loaded\n"), "/ws"); got != nil {
+		t.Errorf("expected no skipped packages, got %v", got)
+	}
+}
+
+func TestCountTargets(t *testing.T) {
+	// Overlapping rdeps chunks return shared targets more than once.
+	rules := []xmlRule{{Name: "//app:a"}, {Name: "//lib:b"}, {Name: "//app:a"}, {Name: "//lib:c"}, {Name: "//lib:b"}}
+	if got := countTargets(rules); got != 3 {
+		t.Errorf("countTargets() = %d, want 3", got)
+	}
+}
+
+func TestClassifyDepConfigured(t *testing.T) {
+	attrs := extract.EdgeAttributes(map[string]string{
+		"tools":   "toolchain",
+		"data":    "",
+		"exports": extract.EdgeTypeExports,
+	})
+
+	if got := classifyDep(attrs, "tools"); got != "TOOLCHAIN" {
+		t.Errorf("classifyDep(tools) = %q, want TOOLCHAIN", got)
+	}
+	if got := classifyDep(attrs, "data"); got != "" {
+		t.Errorf("classifyDep(data) = %q, want disabled", got)
+	}
+	if got := classifyDep(attrs, "exports"); got != "EXPORTS" {
+		t.Errorf("classifyDep(exports) = %q, want opted-in EXPORTS", got)
+	}
+	if got := classifyDep(attrs, "deps"); got != "COMPILE" {
+		t.Errorf("classifyDep(deps) = %q, want default COMPILE", got)
+	}
+}
+
+func TestBuildSnapshotIncludeExternal(t *testing.T) {
+	rules := []xmlRule{
+		{
+			Class: "java_library",
+			Name:  "//app/foo:lib",
+			Lists: []xmlList{{
+				Name: "deps",
+				Labels: []xmlLabelValue{
+					{Value: "@maven//:com_google_guava_guava"},
+					{Value: "@maven//:org_slf4j_slf4j_api"},
+					{Value: "@@rules_go~//go/runfiles"},
+					{Value: "//lib/bar:bar"},
+				},
+			}},
+		},
+	}
+
+	snap := buildSnapshot(rules, "abc123", nil, buildOptions{}, time.Now())
+	if len(snap.Nodes) != 1 || len(snap.Edges) != 1 {
+		t.Fatalf("default: got %d nodes, %d edges, want externals dropped", len(snap.Nodes), len(snap.Edges))
+	}
+
+	snap = buildSnapshot(rules, "abc123", nil, buildOptions{includeExternal: true}, time.Now())
+	maven := snap.Nodes["@maven"]
+	if maven == nil || !maven.IsExternal {
+		t.Fatalf("expected external node @maven, got %+v", maven)
+	}
+	if snap.Nodes["@rules_go~"] == nil {
+		t.Error("expected external node @rules_go~")
+	}
+	// Both maven deps collapse onto one edge.
+	if len(snap.Edges) != 3 {
+		t.Errorf("got %d edges, want 3", len(snap.Edges))
+	}
+	if snap.Stats.PackageCount != 1 {
+		t.Errorf("PackageCount = %d, want 1 (external repos are not packages)", snap.Stats.PackageCount)
+	}
+}
+
+func TestBuildSnapshotRepoMapping(t *testing.T) {
+	rules := []xmlRule{
+		{
+			Class: "go_library",
+			Name:  "//app/foo:lib",
+			Lists: []xmlList{{
+				Name: "deps",
+				Labels: []xmlLabelValue{
+					{Value: "@corp_payments//client:client"},
+					{Value: "@@corp_payments~//api:proto"},
+					{Value: "@maven//:com_google_guava_guava"},
+				},
+			}},
+		},
+	}
+	opts, err := (&Extractor{RepoMapping: map[string]string{"@corp_payments": "acme/payments"}}).buildOptions()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	snap := buildSnapshot(rules, "abc123", nil, opts, time.Now())
+	client := snap.Nodes["@corp_payments//client"]
+	if client == nil || !client.IsExternal || client.Repo != "acme/payments" || client.Package != "@corp_payments//client" {
+		t.Fatalf("expected mapped target @corp_payments//client, got %+v", client)
+	}
+	if n := snap.Nodes["@@corp_payments~//api:proto"]; n == nil || n.Repo != "acme/payments" {
+		t.Errorf("expected canonical label to map, got %+v", n)
+	}
+	// Unmapped externals are still dropped without include_external.
+	if snap.Nodes["@maven"] != nil || len(snap.Edges) != 2 {
+		t.Errorf("got %d edges, want 2 mapped edges", len(snap.Edges))
+	}
+}
+
+func TestBuildSnapshotGenerated(t *testing.T) {
+	rules := []xmlRule{
+		{Class: "go_proto_library", Name: "//api:api_go_proto"},
+		{Class: "go_library", Name: "//api:client"},
+	}
+	snap := buildSnapshot(rules, "abc123", nil, buildOptions{generated: graph.DefaultGeneratedPatterns}, time.Now())
+	if !snap.Nodes["//api:api_go_proto"].IsGenerated {
+		t.Error("go_proto_library should be marked generated")
+	}
+	if snap.Nodes["//api:client"].IsGenerated {
+		t.Error("go_library should not be marked generated")
+	}
+}
+
+func TestBuildSnapshotLabelRewrites(t *testing.T) {
+	rewrite, err := compileRewrites([]LabelRewrite{{Match: `^(//[^:]+):(\w+?)(_test)?_lib$`, Replace: "$1:$2$3"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rules := []xmlRule{
+		{
+			Class: "go_library", Name: "//foo:foo_lib",
+			Lists: []xmlList{{Name: "deps", Labels: []xmlLabelValue{{Value: "//bar:bar_lib"}}}},
+		},
+		{
+			Class: "go_library", Name: "//foo:foo_test_lib",
+			Lists: []xmlList{{Name: "deps", Labels: []xmlLabelValue{{Value: "//foo:foo_lib"}}}},
+		},
+		{
+			Class: "alias", Name: "//foo:foo",
+			Lists: []xmlList{{Name: "actual", Labels: []xmlLabelValue{{Value: "//foo:foo_lib"}}}},
+		},
+		{Class: "go_library", Name: "//bar:bar_lib"},
+	}
+	snap := buildSnapshot(rules, "abc123", []string{"//foo:foo_lib", "//foo:foo"}, buildOptions{rewrite: rewrite}, time.Now())
+
+	if len(snap.Nodes) != 3 {
+		t.Fatalf("got %d nodes, want 3: %v", len(snap.Nodes), snap.Nodes)
+	}
+	if n := snap.Nodes["//foo"]; n == nil || n.Kind != "go_library" {
+		t.Errorf("//foo = %+v, want the first merged target's go_library", n)
+	}
+	if snap.Nodes["//bar"] == nil || snap.Nodes["//foo:foo_test"] == nil {
+		t.Errorf("rewritten nodes missing: %v", snap.Nodes)
+	}
+	want := map[string]bool{"//foo|//bar|COMPILE": true, "//foo:foo_test|//foo|COMPILE": true}
+	if len(snap.Edges) != len(want) {
+		t.Errorf("got %d edges, want %d: %v", len(snap.Edges), len(want), snap.Edges)
+	}
+	for _, e := range snap.Edges {
+		if !want[e.From+"|"+e.To+"|"+e.Type] {
+			t.Errorf("unexpected edge %s -> %s (%s)", e.From, e.To, e.Type)
+		}
+	}
+	if len(snap.Scope) != 1 || snap.Scope[0] != "//foo" {
+		t.Errorf("Scope = %v, want [//foo]", snap.Scope)
+	}
+}
+
+func TestCompileRewritesInvalid(t *testing.T) {
+	if _, err := compileRewrites([]LabelRewrite{{Match: "("}}); err == nil {
+		t.Error("expected an error for an invalid pattern")
+	}
+}
+
+func TestExternalRepo(t *testing.T) {
+	tests := []struct {
+		label string
+		want  string
+	}{
+		{"@maven//:guava", "@maven"},
+		{"@pip//numpy", "@pip"},
+		{"@@rules_go~//go:def", "@rules_go~"},
+		{"@com_google_protobuf", "@com_google_protobuf"},
+	}
+	for _, tt := range tests {
+		if got := externalRepo(tt.label); got != tt.want {
+			t.Errorf("externalRepo(%q) = %q, want %q", tt.label, got, tt.want)
+		}
+	}
+}
+
+func TestBuildSnapshotEdgeProvenance(t *testing.T) {
+	rules := []xmlRule{
+		{
+			Class:    "java_library",
+			Name:     "//app/foo:lib",
+			Location: "/ws/app/foo/BUILD.bazel:12:13",
+			Lists: []xmlList{
+				{Name: "implementation_deps", Labels: []xmlLabelValue{{Value: "//lib/bar:bar"}}},
+			},
+		},
+	}
+
+	attrs := extract.EdgeAttributes(map[string]string{"implementation_deps": extract.EdgeTypeImplementation})
+	snap := buildSnapshot(rules, "abc123", nil, buildOptions{edgeAttrs: attrs, workspaceRoot: "/ws"}, time.Now())
+	if len(snap.Edges) != 1 {
+		t.Fatalf("got %d edges, want 1", len(snap.Edges))
+	}
+	e := snap.Edges[0]
+	if e.Attr != "implementation_deps" {
+		t.Errorf("Attr = %q, want implementation_deps", e.Attr)
+	}
+	if e.BuildFile != "app/foo/BUILD.bazel" || e.BuildLine != 12 {
+		t.Errorf("location = %s:%d, want app/foo/BUILD.bazel:12", e.BuildFile, e.BuildLine)
+	}
+}
+
+func TestBuildFileLocation(t *testing.T) {
+	tests := []struct {
+		location, root string
+		file           string
+		line           int
+	}{
+		{"/ws/app/BUILD:3:1", "/ws", "app/BUILD", 3},
+		{"/ws/BUILD.bazel:7", "/ws", "BUILD.bazel", 7},
+		{"/elsewhere/BUILD:3:1", "/ws", "/elsewhere/BUILD", 3},
+		{"/ws/app/BUILD:3:1", "", "/ws/app/BUILD", 3},
+		{"", "/ws", "", 0},
+	}
+	for _, tt := range tests {
+		file, line := buildFileLocation(tt.location, tt.root)
+		if file != tt.file || line != tt.line {
+			t.Errorf("buildFileLocation(%q, %q) = %q, %d; want %q, %d", tt.location, tt.root, file, line, tt.file, tt.line)
+		}
+	}
+}
+
+func TestResourceLimits(t *testing.T) {
+	if args := (ResourceLimits{}).startupArgs(); args != nil {
+		t.Errorf("no limits: startup args %v, want none", args)
+	}
+	if args := (ResourceLimits{MemoryMB: 4096}).startupArgs(); len(args) != 1 || args[0] != "--host_jvm_args=-Xmx4096m" {
+		t.Errorf("memory limit: startup args %v", args)
+	}
+
+	ctx, cancel := (ResourceLimits{}).queryContext(context.Background())
+	defer cancel()
+	if _, ok := ctx.Deadline(); ok {
+		t.Error("no limits: query context has a deadline")
+	}
+	// Each query gets its own deadline, however long earlier ones ran.
+	for i := 0; i < 2; i++ {
+		start := time.Now()
+		ctx, cancel := (ResourceLimits{CPUSeconds: 60}).queryContext(context.Background())
+		deadline, ok := ctx.Deadline()
+		cancel()
+		if !ok || deadline.Before(start.Add(60*time.Second)) {
+			t.Errorf("query %d: deadline %v, want 60s after %v", i, deadline, start)
+		}
+	}
+}
+
+func TestStartupArgs(t *testing.T) {
+	e := &Extractor{BazelRC: ".bazelrc.ci", OutputBase: "/cache/ob", KeepServer: true, Limits: ResourceLimits{MemoryMB: 2048}}
+	got := strings.Join(e.startupArgs(), " ")
+	want := "--output_base=/cache/ob --bazelrc=.bazelrc.ci --nohome_rc --max_idle_secs=0 --host_jvm_args=-Xmx2048m"
+	if got != want {
+		t.Errorf("startupArgs() = %q, want %q", got, want)
+	}
+	if got := strings.Join((&Extractor{}).startupArgs(), " "); got != "--nohome_rc" {
+		t.Errorf("default startupArgs() = %q, want --nohome_rc", got)
+	}
+}
+
+func TestExtractCancelled(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("needs a POSIX shell")
+	}
+	// A bazel that never answers.
+	bazel := filepath.Join(t.TempDir(), "bazel")
+	if err := os.WriteFile(bazel, []byte("#!/bin/sh\nexec sleep 30\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	e := &Extractor{WorkspacePath: t.TempDir(), BazelPath: bazel}
+
+	_, err := e.Extract(context.Background(), SubgraphRequest{Targets: []string{"//app:lib"}, Timeout: 100 * time.Millisecond})
+	var cancelled *CancelledError
+	if !errors.As(err, &cancelled) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected a CancelledError wrapping the deadline, got %v", err)
+	}
+	if cancelled.Chunks != 0 || cancelled.TotalChunks != 1 {
+		t.Errorf("progress = %d of %d chunks, want 0 of 1", cancelled.Chunks, cancelled.TotalChunks)
+	}
+}
+
+func TestExtractQueryTimeLimit(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("needs a POSIX shell")
+	}
+	bazel := filepath.Join(t.TempDir(), "bazel")
+	if err := os.WriteFile(bazel, []byte("#!/bin/sh\nexec sleep 30\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	e := &Extractor{WorkspacePath: t.TempDir(), BazelPath: bazel, Limits: ResourceLimits{CPUSeconds: 1}}
+
+	_, err := e.Extract(context.Background(), SubgraphRequest{Targets: []string{"//app:lib"}, Timeout: time.Minute})
+	var cancelled *CancelledError
+	if err == nil || errors.As(err, &cancelled) || !strings.Contains(err.Error(), "exceeded the 1s limit") {
+		t.Fatalf("expected the query time limit error, got %v", err)
+	}
+}
+
+func TestShardPatterns(t *testing.T) {
+	ws := t.TempDir()
+	for _, f := range []string{"BUILD.bazel", "app/BUILD", "lib/deep/BUILD.bazel", "docs/README.md", ".git/BUILD", "vendored/BUILD", "third_party/x/BUILD"} {
+		path := filepath.Join(ws, f)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(ws, ".bazelignore"), []byte("# comment\nvendored/\nthird_party/x\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := shardPatterns(ws)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"//:*", "//app/...", "//lib/...", "//third_party/..."}
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("shardPatterns = %v, want %v", got, want)
+	}
+}
+
+func TestExtractFullSharded(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("needs a POSIX shell")
+	}
+	ws := t.TempDir()
+	for _, dir := range []string{"app", "lib", "broken"} {
+		if err := os.MkdirAll(filepath.Join(ws, dir), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(ws, dir, "BUILD"), nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// A bazel that answers per package tree and fails on //broken/....
+	bazel := filepath.Join(t.TempDir(), "bazel")
+	script := `#!/bin/sh
+for a; do
+  case "$a" in
+  "kind(rule, //app/...)") echo '<query version="2"><rule class="go_binary" name="//app:server"><list name="deps"><label value="//lib:util"/></list></rule></query>'; exit 0 ;;
+  "kind(rule, //lib/...)") echo '<query version="2"><rule class="go_library" name="//lib:util"/></query>'; exit 0 ;;
+  esac
+done
+echo "ERROR: boom" >&2
+exit 1
+`
+	if err := os.WriteFile(bazel, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	e := &Extractor{WorkspacePath: ws, BazelPath: bazel, ShardParallelism: 2}
+	snap, err := e.ExtractFull(context.Background(), "abc", 0)
+	if err != nil {
+		t.Fatalf("ExtractFull: %v", err)
+	}
+	if snap.Nodes["//app:server"] == nil || snap.Nodes["//lib:util"] == nil {
+		t.Errorf("nodes = %v, want both shards merged", snap.Nodes)
+	}
+	if len(snap.Edges) != 1 || snap.Edges[0].To != "//lib:util" {
+		t.Errorf("edges = %v, want the cross-shard edge", snap.Edges)
+	}
+	if got := snap.Stats.SkippedPackages; len(got) != 1 || got[0] != "//broken/..." {
+		t.Errorf("skipped = %v, want the failed shard", got)
+	}
+
+	// With every shard failing, the extraction fails.
+	e.WorkspacePath = t.TempDir()
+	if err := os.MkdirAll(filepath.Join(e.WorkspacePath, "broken"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(e.WorkspacePath, "broken", "BUILD"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := e.ExtractFull(context.Background(), "abc", 0); err == nil {
+		t.Error("expected an error when every shard fails")
+	}
+}
+
+func TestProfilePhases(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "query.profile.gz")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gz := gzip.NewWriter(f)
+	gz.Write([]byte(`{"otherData":{},"traceEvents":[
+		{"name":"Launch Blaze","cat":"build phase marker","ph":"i","ts":0},
+		{"name":"Initialize command","cat":"build phase marker","ph":"i","ts":2000000},
+		{"name":"runQuery","cat":"general information","ph":"X","ts":2500000,"dur":7500000}
+	]}`))
+	gz.Close()
+	f.Close()
+
+	phases, err := profilePhases(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if phases["Launch Blaze"] != 2*time.Second || phases["Initialize command"] != 8*time.Second {
+		t.Errorf("phases = %v, want Launch Blaze 2s and Initialize command 8s", phases)
+	}
+}
+
+func TestExtractPhases(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("needs a POSIX shell")
+	}
+	if _, err := exec.LookPath("gzip"); err != nil {
+		t.Skip("needs gzip")
+	}
+	// A bazel that writes a profile where it's asked to.
+	bazel := filepath.Join(t.TempDir(), "bazel")
+	script := `#!/bin/sh
+for a; do
+  case "$a" in
+  --profile=*) echo '[{"name":"Launch Blaze","cat":"build phase marker","ts":0},{"name":"x","ts":0,"dur":1000}]' | gzip > "${a#--profile=}" ;;
+  esac
+done
+echo '<query version="2"><rule class="go_library" name="//app:lib"/></query>'
+`
+	if err := os.WriteFile(bazel, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	profiles := filepath.Join(t.TempDir(), "profiles")
+	e := &Extractor{WorkspacePath: t.TempDir(), BazelPath: bazel, ProfileDir: profiles}
+	snap, err := e.Extract(context.Background(), SubgraphRequest{Targets: []string{"//app:lib"}, CommitSHA: "abc"})
+	if err != nil {
+		t.Fatalf("Extract: %v", err)
+	}
+	var names []string
+	for _, p := range snap.Stats.Phases {
+		names = append(names, p.Name)
+	}
+	if got := strings.Join(names, ","); got != "query,bazel/Launch Blaze,parse,build" {
+		t.Errorf("phases = %s", got)
+	}
+	if _, err := os.Stat(filepath.Join(profiles, "abc-query-1.profile.gz")); err != nil {
+		t.Errorf("profile not kept: %v", err)
+	}
+}
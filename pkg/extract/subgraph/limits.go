@@ -0,0 +1,57 @@
+package subgraph
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// ResourceLimits bound the resources of bazel invocations. Zero values are
+// unlimited.
+type ResourceLimits struct {
+	// MemoryMB caps the Java heap of the bazel server
+	// (--host_jvm_args=-Xmx). A server already running with other startup
+	// options is restarted.
+	MemoryMB int
+
+	// CPUSeconds caps how long each bazel query may run, with a deadline
+	// on the invocation. It is enforced per query rather than with
+	// ulimit -t, since a server kept warm between queries accumulates CPU
+	// time across all of them.
+	CPUSeconds int
+}
+
+// startupArgs returns the bazel startup options that apply l.
+func (l ResourceLimits) startupArgs() []string {
+	if l.MemoryMB <= 0 {
+		return nil
+	}
+	return []string{"--host_jvm_args=-Xmx" + strconv.Itoa(l.MemoryMB) + "m"}
+}
+
+// queryContext returns the context for a single bazel query under l. It
+// ends CPUSeconds after the query starts, or with ctx.
+func (l ResourceLimits) queryContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if l.CPUSeconds <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, time.Duration(l.CPUSeconds)*time.Second)
+}
+
+// CancelledError reports how far an extraction got before its context was
+// cancelled or timed out.
+type CancelledError struct {
+	Chunks      int // query chunks that completed
+	TotalChunks int
+	Targets     int // distinct targets parsed from the completed chunks
+	Elapsed     time.Duration
+	Err         error // the context's error
+}
+
+func (e *CancelledError) Error() string {
+	return fmt.Sprintf("extraction cancelled after %s with %d of %d query chunks done (%d targets): %v",
+		e.Elapsed.Round(time.Second), e.Chunks, e.TotalChunks, e.Targets, e.Err)
+}
+
+func (e *CancelledError) Unwrap() error { return e.Err }
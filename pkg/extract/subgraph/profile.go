@@ -0,0 +1,183 @@
+package subgraph
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/toposcope/toposcope/pkg/graph"
+)
+
+// phaseTimer sums the time an extraction spends in each phase. It is safe
+// for concurrent use by shard queries.
+type phaseTimer struct {
+	profileDir string // where bazel query profiles go; empty for none
+	commit     string // names the profiles
+
+	mu       sync.Mutex
+	order    []string
+	phases   map[string]time.Duration
+	profiles int
+}
+
+// newPhaseTimer returns the timer of one extraction of commitSHA by e,
+// creating its profile directory.
+func (e *Extractor) newPhaseTimer(commitSHA string) (*phaseTimer, error) {
+	t := &phaseTimer{commit: commitSHA[:min(12, len(commitSHA))], phases: make(map[string]time.Duration)}
+	if e.ProfileDir != "" {
+		dir, err := filepath.Abs(e.ProfileDir)
+		if err == nil {
+			err = os.MkdirAll(dir, 0o755)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("creating profile dir: %w", err)
+		}
+		t.profileDir = dir
+	}
+	return t, nil
+}
+
+func (t *phaseTimer) add(name string, d time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if _, ok := t.phases[name]; !ok {
+		t.order = append(t.order, name)
+	}
+	t.phases[name] += d
+}
+
+// since adds the time since start to the phase name.
+func (t *phaseTimer) since(name string, start time.Time) {
+	t.add(name, time.Since(start))
+}
+
+// nextProfile returns the path of the next query's profile, or "" when
+// profiles aren't captured.
+func (t *phaseTimer) nextProfile() string {
+	if t.profileDir == "" {
+		return ""
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.profiles++
+	name := fmt.Sprintf("query-%d.profile.gz", t.profiles)
+	if t.commit != "" {
+		name = t.commit + "-" + name
+	}
+	return filepath.Join(t.profileDir, name)
+}
+
+// timings returns the phases in the order they were first timed.
+func (t *phaseTimer) timings() []graph.PhaseTiming {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	timings := make([]graph.PhaseTiming, 0, len(t.order))
+	for _, name := range t.order {
+		timings = append(timings, graph.PhaseTiming{Name: name, Ms: int(t.phases[name].Milliseconds())})
+	}
+	return timings
+}
+
+type traceEvent struct {
+	Name string  `json:"name"`
+	Cat  string  `json:"cat"`
+	TS   float64 `json:"ts"`  // microseconds
+	Dur  float64 `json:"dur"` // microseconds, for complete events
+}
+
+// profilePhases reads a bazel JSON trace profile (--profile) and returns
+// how long each build phase it marks took. A phase lasts until the next
+// marker, and the last one until the end of the profile.
+func profilePhases(path string) (map[string]time.Duration, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var r io.Reader = f
+	if strings.HasSuffix(path, ".gz") {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return nil, fmt.Errorf("reading profile: %w", err)
+		}
+		defer gz.Close()
+		r = gz
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("reading profile: %w", err)
+	}
+
+	// Current bazel writes an object with traceEvents; old versions a bare
+	// array of events.
+	var profile struct {
+		TraceEvents []traceEvent `json:"traceEvents"`
+	}
+	if err := json.Unmarshal(data, &profile); err != nil {
+		if err := json.Unmarshal(data, &profile.TraceEvents); err != nil {
+			return nil, fmt.Errorf("parsing profile: %w", err)
+		}
+	}
+
+	var markers []traceEvent
+	var end float64
+	for _, ev := range profile.TraceEvents {
+		end = max(end, ev.TS+ev.Dur)
+		if ev.Cat == "build phase marker" {
+			markers = append(markers, ev)
+		}
+	}
+	sort.SliceStable(markers, func(i, j int) bool { return markers[i].TS < markers[j].TS })
+
+	phases := make(map[string]time.Duration, len(markers))
+	for i, m := range markers {
+		until := end
+		if i+1 < len(markers) {
+			until = markers[i+1].TS
+		}
+		phases[m.Name] += time.Duration(until-m.TS) * time.Microsecond
+	}
+	return phases, nil
+}
+
+// addProfile adds the build phases of the profile at path, prefixed
+// "bazel/", in the order bazel runs them. Unreadable profiles are ignored:
+// they only add detail.
+func (t *phaseTimer) addProfile(path string) {
+	phases, err := profilePhases(path)
+	if err != nil {
+		return
+	}
+	names := make([]string, 0, len(phases))
+	for name := range phases {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if ri, rj := bazelPhaseRank(names[i]), bazelPhaseRank(names[j]); ri != rj {
+			return ri < rj
+		}
+		return names[i] < names[j]
+	})
+	for _, name := range names {
+		t.add("bazel/"+name, phases[name])
+	}
+}
+
+// bazelPhaseOrder lists the build phase markers bazel writes, in order.
+var bazelPhaseOrder = []string{"Launch Blaze", "Initialize command", "Evaluate target patterns", "Load and analyze dependencies", "Analyze licenses", "Prepare for build", "Build artifacts", "Complete build", "Finish"}
+
+func bazelPhaseRank(name string) int {
+	for i, p := range bazelPhaseOrder {
+		if p == name {
+			return i
+		}
+	}
+	return len(bazelPhaseOrder)
+}
@@ -0,0 +1,46 @@
+package subgraph
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// LabelRewrite rewrites target labels matching a regular expression, so
+// targets that macros name differently across snapshots (e.g. //foo:foo_lib
+// and //foo:foo) compare as one. Replace may refer to capture groups as in
+// regexp.Regexp.ReplaceAllString.
+type LabelRewrite struct {
+	Match   string
+	Replace string
+}
+
+type compiledRewrite struct {
+	re      *regexp.Regexp
+	replace string
+}
+
+// labelRewriter applies rewrites in order to normalized labels; each sees
+// the previous result, and the final label is normalized again.
+type labelRewriter []compiledRewrite
+
+func compileRewrites(rewrites []LabelRewrite) (labelRewriter, error) {
+	var out labelRewriter
+	for _, r := range rewrites {
+		re, err := regexp.Compile(r.Match)
+		if err != nil {
+			return nil, fmt.Errorf("invalid label rewrite %q: %w", r.Match, err)
+		}
+		out = append(out, compiledRewrite{re: re, replace: r.Replace})
+	}
+	return out, nil
+}
+
+func (lr labelRewriter) apply(label string) string {
+	if len(lr) == 0 {
+		return label
+	}
+	for _, r := range lr {
+		label = r.re.ReplaceAllString(label, r.replace)
+	}
+	return NormalizeLabel(label)
+}
@@ -0,0 +1,161 @@
+package subgraph
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// shardPatterns returns the target patterns a sharded full query covers the
+// workspace with: the root package, when it has a BUILD file, and every
+// top-level directory with a BUILD file beneath it. Hidden directories,
+// bazel-* convenience symlinks and top-level .bazelignore entries are left
+// out.
+func shardPatterns(workspace string) ([]string, error) {
+	entries, err := os.ReadDir(workspace)
+	if err != nil {
+		return nil, fmt.Errorf("listing workspace: %w", err)
+	}
+	ignored := bazelIgnored(workspace)
+
+	var patterns []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.IsDir() {
+			if name == "BUILD" || name == "BUILD.bazel" {
+				patterns = append(patterns, "//:*")
+			}
+			continue
+		}
+		if strings.HasPrefix(name, ".") || strings.HasPrefix(name, "bazel-") || ignored[name] {
+			continue
+		}
+		if hasBuildFile(filepath.Join(workspace, name)) {
+			patterns = append(patterns, "//"+name+"/...")
+		}
+	}
+	return dedupeSorted(patterns), nil
+}
+
+// bazelIgnored returns the top-level directories listed in the workspace's
+// .bazelignore.
+func bazelIgnored(workspace string) map[string]bool {
+	ignored := make(map[string]bool)
+	f, err := os.Open(filepath.Join(workspace, ".bazelignore"))
+	if err != nil {
+		return ignored
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.Trim(strings.TrimSpace(scanner.Text()), "/")
+		if line == "" || strings.HasPrefix(line, "#") || strings.Contains(line, "/") {
+			continue
+		}
+		ignored[line] = true
+	}
+	return ignored
+}
+
+// hasBuildFile reports whether dir or a directory beneath it has a BUILD
+// file. It stops at the first one.
+func hasBuildFile(dir string) bool {
+	found := false
+	filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if !d.IsDir() && (d.Name() == "BUILD" || d.Name() == "BUILD.bazel") {
+			found = true
+			return fs.SkipAll
+		}
+		return nil
+	})
+	return found
+}
+
+// queryShards runs kind(rule, ...) over each of patterns, ShardParallelism
+// at a time, and merges the rules. A shard that fails is reported as
+// skipped rather than failing the extraction, unless every shard fails.
+// With an OutputBase, each concurrent query gets an output base of its own
+// next to it; without one they share the workspace's bazel server, which
+// runs them one at a time. Phase timings sum over the shards.
+func (e *Extractor) queryShards(ctx context.Context, patterns []string, pt *phaseTimer, start time.Time) ([]xmlRule, []string, error) {
+	if len(patterns) == 0 {
+		return nil, nil, errors.New("no BUILD files in the workspace")
+	}
+	type shardResult struct {
+		rules   []xmlRule
+		skipped []string
+		err     error
+		ran     bool
+	}
+	results := make([]shardResult, len(patterns))
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < min(e.ShardParallelism, len(patterns)); w++ {
+		wg.Add(1)
+		worker := e.shardWorker(w)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				r := &results[i]
+				r.rules, r.skipped, r.err = worker.runQuery(ctx, "kind(rule, "+patterns[i]+")", pt)
+				r.ran = true
+			}
+		}()
+	}
+feed:
+	for i := range patterns {
+		select {
+		case next <- i:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(next)
+	wg.Wait()
+
+	var rules []xmlRule
+	var skipped []string
+	var failed []error
+	done := 0
+	for i, r := range results {
+		if !r.ran || (r.err != nil && ctx.Err() != nil) {
+			continue
+		}
+		if r.err != nil {
+			failed = append(failed, fmt.Errorf("%s: %w", patterns[i], r.err))
+			skipped = append(skipped, patterns[i])
+			continue
+		}
+		done++
+		rules = append(rules, r.rules...)
+		skipped = append(skipped, r.skipped...)
+	}
+	if ctx.Err() != nil {
+		return nil, nil, &CancelledError{Chunks: done, TotalChunks: len(patterns), Targets: countTargets(rules), Elapsed: time.Since(start), Err: ctx.Err()}
+	}
+	if len(failed) == len(patterns) {
+		return nil, nil, fmt.Errorf("every query shard failed: %w", errors.Join(failed...))
+	}
+	return rules, skipped, nil
+}
+
+// shardWorker returns the extractor that the w-th concurrent shard query
+// runs with. The first uses the extractor's own output base.
+func (e *Extractor) shardWorker(w int) *Extractor {
+	if w == 0 || e.OutputBase == "" {
+		return e
+	}
+	worker := *e
+	worker.OutputBase = fmt.Sprintf("%s-shard%d", e.OutputBase, w)
+	return &worker
+}
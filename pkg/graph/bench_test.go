@@ -0,0 +1,82 @@
+package graph_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/toposcope/toposcope/pkg/graph"
+	"github.com/toposcope/toposcope/pkg/graph/graphtest"
+)
+
+// benchSizes are the edge counts the hot-path benchmarks run at.
+var benchSizes = []int{10000, 100000, 1000000}
+
+func BenchmarkSnapshotMarshal(b *testing.B) {
+	for _, n := range benchSizes {
+		snap := graphtest.Snapshot(n)
+		b.Run(fmt.Sprintf("edges=%d", n), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := json.Marshal(snap); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkSnapshotUnmarshal(b *testing.B) {
+	for _, n := range benchSizes {
+		data, err := json.Marshal(graphtest.Snapshot(n))
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.Run(fmt.Sprintf("edges=%d", n), func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(int64(len(data)))
+			for i := 0; i < b.N; i++ {
+				var snap graph.Snapshot
+				if err := json.Unmarshal(data, &snap); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkComputeDeltaSynthetic(b *testing.B) {
+	for _, n := range benchSizes {
+		base, head := graphtest.Pair(n)
+		b.Run(fmt.Sprintf("edges=%d", n), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				graph.ComputeDelta(base, head)
+			}
+		})
+	}
+}
+
+func BenchmarkNewIndex(b *testing.B) {
+	for _, n := range benchSizes {
+		snap := graphtest.Snapshot(n)
+		b.Run(fmt.Sprintf("edges=%d", n), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				graph.NewIndex(snap)
+			}
+		})
+	}
+}
+
+func BenchmarkContentID(b *testing.B) {
+	for _, n := range benchSizes {
+		snap := graphtest.Snapshot(n)
+		b.Run(fmt.Sprintf("edges=%d", n), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				graph.ContentID(snap)
+			}
+		})
+	}
+}
@@ -0,0 +1,148 @@
+package graph
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TargetTiming is how long one target took in a build, in milliseconds.
+type TargetTiming struct {
+	BuildMs int64 `json:"build_ms"`
+	TestMs  int64 `json:"test_ms"`
+}
+
+// buildEvent holds the parts of a build event protocol event that carry
+// timing. Bazel writes one per line with --build_event_json_file.
+type buildEvent struct {
+	ID struct {
+		ActionCompleted *struct {
+			Label string `json:"label"`
+		} `json:"actionCompleted"`
+		TestSummary *struct {
+			Label string `json:"label"`
+		} `json:"testSummary"`
+	} `json:"id"`
+	Action *struct {
+		Label     string `json:"label"`
+		StartTime string `json:"startTime"`
+		EndTime   string `json:"endTime"`
+	} `json:"action"`
+	TestSummary *struct {
+		TotalRunDuration       string     `json:"totalRunDuration"`
+		TotalRunDurationMillis protoInt64 `json:"totalRunDurationMillis"`
+	} `json:"testSummary"`
+}
+
+// protoInt64 is an int64 in proto3 JSON, which may be quoted.
+type protoInt64 int64
+
+func (v *protoInt64) UnmarshalJSON(data []byte) error {
+	n, err := strconv.ParseInt(string(bytes.Trim(data, `"`)), 10, 64)
+	if err != nil {
+		return err
+	}
+	*v = protoInt64(n)
+	return nil
+}
+
+// ParseBuildEvents reads a build event protocol JSON stream, as written by
+// bazel's --build_event_json_file, and returns the timing of each target it
+// names. A target's build time is the sum of its actions' durations, so
+// actions are only counted when bazel ran with
+// --build_event_publish_all_actions. Its test time is the total run time of
+// its test summary. Unrelated events are skipped.
+func ParseBuildEvents(r io.Reader) (map[string]TargetTiming, error) {
+	timings := make(map[string]TargetTiming)
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	line := 0
+	for sc.Scan() {
+		line++
+		data := bytes.TrimSpace(sc.Bytes())
+		if len(data) == 0 {
+			continue
+		}
+		var ev buildEvent
+		if err := json.Unmarshal(data, &ev); err != nil {
+			return nil, fmt.Errorf("build event on line %d: %w", line, err)
+		}
+
+		switch {
+		case ev.ID.ActionCompleted != nil && ev.Action != nil:
+			label := ev.ID.ActionCompleted.Label
+			if label == "" {
+				label = ev.Action.Label
+			}
+			start, err1 := time.Parse(time.RFC3339Nano, ev.Action.StartTime)
+			end, err2 := time.Parse(time.RFC3339Nano, ev.Action.EndTime)
+			if label == "" || err1 != nil || err2 != nil || end.Before(start) {
+				continue
+			}
+			label = bepLabel(label)
+			t := timings[label]
+			t.BuildMs += end.Sub(start).Milliseconds()
+			timings[label] = t
+
+		case ev.ID.TestSummary != nil && ev.TestSummary != nil:
+			ms := int64(ev.TestSummary.TotalRunDurationMillis)
+			if d, err := time.ParseDuration(ev.TestSummary.TotalRunDuration); err == nil {
+				ms = d.Milliseconds()
+			}
+			label := bepLabel(ev.ID.TestSummary.Label)
+			t := timings[label]
+			t.TestMs = ms
+			timings[label] = t
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("reading build events: %w", err)
+	}
+	return timings, nil
+}
+
+// bepLabel maps a main-repository label in BEP ("@//app:lib" or
+// "@@//app:lib") to its key in a snapshot.
+func bepLabel(label string) string {
+	if trimmed := strings.TrimLeft(label, "@"); strings.HasPrefix(trimmed, "//") {
+		return trimmed
+	}
+	return label
+}
+
+// AnnotateTimings sets the build and test durations of the nodes in snap
+// that timings names, and returns how many nodes it annotated. Annotated
+// nodes are copied first, since a merged snapshot shares nodes with its
+// baseline, and snap.ID is re-derived from the annotated content.
+func AnnotateTimings(snap *Snapshot, timings map[string]TargetTiming) int {
+	n := 0
+	for label, t := range timings {
+		node := snap.Nodes[label]
+		if node == nil {
+			continue
+		}
+		annotated := *node
+		annotated.BuildMs, annotated.TestMs = t.BuildMs, t.TestMs
+		snap.Nodes[label] = &annotated
+		n++
+	}
+	if n > 0 {
+		snap.ID = ContentID(snap)
+	}
+	return n
+}
+
+// HasTimings reports whether any node in snap has a build duration.
+func HasTimings(snap *Snapshot) bool {
+	for _, n := range snap.Nodes {
+		if n.BuildMs > 0 {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,64 @@
+package graph
+
+import (
+	"strings"
+	"testing"
+)
+
+const testBuildEvents = `{"id":{"started":{}},"started":{"uuid":"b1"}}
+{"id":{"actionCompleted":{"primaryOutput":"bazel-out/a.a","label":"//app:lib"}},"action":{"success":true,"startTime":"2024-05-01T10:00:00Z","endTime":"2024-05-01T10:00:01.500Z"}}
+{"id":{"actionCompleted":{"primaryOutput":"bazel-out/a.x","label":"@//app:lib"}},"action":{"success":true,"startTime":"2024-05-01T10:00:02Z","endTime":"2024-05-01T10:00:02.250Z"}}
+{"id":{"actionCompleted":{"primaryOutput":"bazel-out/g.a","label":"@@rules_go~//go:stdlib"}},"action":{"success":true,"startTime":"2024-05-01T10:00:00Z","endTime":"2024-05-01T10:00:03Z"}}
+
+{"id":{"testSummary":{"label":"@@//app:lib_test"}},"testSummary":{"overallStatus":"PASSED","totalRunDuration":"2.5s"}}
+{"id":{"testSummary":{"label":"//app:old_test"}},"testSummary":{"totalRunDurationMillis":"700"}}
+`
+
+func TestParseBuildEvents(t *testing.T) {
+	timings, err := ParseBuildEvents(strings.NewReader(testBuildEvents))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]TargetTiming{
+		"//app:lib":              {BuildMs: 1750},
+		"@@rules_go~//go:stdlib": {BuildMs: 3000},
+		"//app:lib_test":         {TestMs: 2500},
+		"//app:old_test":         {TestMs: 700},
+	}
+	if len(timings) != len(want) {
+		t.Fatalf("expected %d targets, got %v", len(want), timings)
+	}
+	for label, w := range want {
+		if timings[label] != w {
+			t.Errorf("%s: expected %+v, got %+v", label, w, timings[label])
+		}
+	}
+
+	if _, err := ParseBuildEvents(strings.NewReader("{\n")); err == nil {
+		t.Error("expected an error for malformed events")
+	}
+}
+
+func TestAnnotateTimings(t *testing.T) {
+	snap := &Snapshot{Nodes: map[string]*Node{
+		"//app:lib":      {Key: "//app:lib"},
+		"//app:lib_test": {Key: "//app:lib_test", IsTest: true},
+	}}
+	if HasTimings(snap) {
+		t.Error("expected no timings before annotating")
+	}
+	n := AnnotateTimings(snap, map[string]TargetTiming{
+		"//app:lib":      {BuildMs: 1200},
+		"//app:lib_test": {TestMs: 300},
+		"//gone:lib":     {BuildMs: 5},
+	})
+	if n != 2 || snap.Nodes["//app:lib"].BuildMs != 1200 || snap.Nodes["//app:lib_test"].TestMs != 300 {
+		t.Errorf("unexpected annotation (%d): %+v %+v", n, snap.Nodes["//app:lib"], snap.Nodes["//app:lib_test"])
+	}
+	if !HasTimings(snap) {
+		t.Error("expected timings after annotating")
+	}
+	if snap.ID != ContentID(snap) {
+		t.Errorf("ID = %q after annotating, want the content ID %q", snap.ID, ContentID(snap))
+	}
+}
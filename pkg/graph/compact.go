@@ -0,0 +1,410 @@
+package graph
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// compactMagic identifies the compact snapshot format and its version.
+const compactMagic = "TSCMPCT1"
+
+// compactHeaderLen is the magic followed by six uint32 counts.
+const compactHeaderLen = len(compactMagic) + 6*4
+
+// ErrCompactFormat is returned when a file is not a compact snapshot.
+var ErrCompactFormat = errors.New("not a compact snapshot")
+
+// Compact is a read-only snapshot in a flat binary file that is memory-mapped
+// rather than decoded. Labels are interned and numbered as in Index, and
+// adjacency is stored in compressed sparse row form, so traversals touch only
+// the pages they need. Nodes are kept as JSON and decoded one at a time.
+//
+// The file is a header, then little-endian uint32 arrays, then the string
+// blobs the arrays point into:
+//
+//	keyOff[keys+1] nodeOff[keys+1] pkg[keys] strOff[strs+1]
+//	edgeFrom[edges] edgeTo[edges] edgeType[edges] edgeAttr[edges]
+//	edgeFile[edges] edgeLine[edges]
+//	outStart[keys+1] outEdge[edges] inStart[keys+1] inEdge[edges]
+//	keyBlob nodeBlob strBlob
+//
+// outEdge and inEdge hold edge indices grouped by source and target, in edge
+// order. A label with an empty node span is an edge endpoint that is not a
+// node. String index 0 is the empty string.
+type Compact struct {
+	data  []byte
+	unmap func() error
+
+	keys, edges, strs int
+
+	keyOff, nodeOff, pkg, strOff                             int
+	edgeFrom, edgeTo, edgeType, edgeAttr, edgeFile, edgeLine int
+	outStart, outEdge, inStart, inEdge                       int
+	keyBlob, nodeBlob, strBlob                               int
+}
+
+// WriteCompact writes snap to path in the compact format. The file is written
+// beside path and renamed into place, so readers never see a partial file.
+func WriteCompact(path string, snap *Snapshot) error {
+	ix := NewIndex(snap)
+	n, m := ix.Len(), len(snap.Edges)
+
+	strIDs := map[string]uint32{"": 0}
+	strs := []string{""}
+	intern := func(s string) uint32 {
+		id, ok := strIDs[s]
+		if !ok {
+			id = uint32(len(strs))
+			strIDs[s] = id
+			strs = append(strs, s)
+		}
+		return id
+	}
+
+	var keyBlob, nodeBlob bytes.Buffer
+	keyOff := make([]uint32, n+1)
+	nodeOff := make([]uint32, n+1)
+	pkg := make([]uint32, n)
+	for i := 0; i < n; i++ {
+		key := ix.Key(int32(i))
+		keyBlob.WriteString(key)
+		if node := snap.Nodes[key]; node != nil {
+			data, err := json.Marshal(node)
+			if err != nil {
+				return fmt.Errorf("encoding node %s: %w", key, err)
+			}
+			nodeBlob.Write(data)
+			pkg[i] = intern(node.Package)
+		}
+		keyOff[i+1] = uint32(keyBlob.Len())
+		nodeOff[i+1] = uint32(nodeBlob.Len())
+		if int64(keyBlob.Len()) > math.MaxUint32 || int64(nodeBlob.Len()) > math.MaxUint32 {
+			return errors.New("snapshot too large for the compact format")
+		}
+	}
+
+	edgeFrom := make([]uint32, m)
+	edgeTo := make([]uint32, m)
+	edgeType := make([]uint32, m)
+	edgeAttr := make([]uint32, m)
+	edgeFile := make([]uint32, m)
+	edgeLine := make([]uint32, m)
+	edgeIdx := make([]int32, m)
+	for i, e := range snap.Edges {
+		edgeFrom[i] = uint32(ix.EdgeFrom[i])
+		edgeTo[i] = uint32(ix.EdgeTo[i])
+		edgeType[i] = intern(e.Type)
+		edgeAttr[i] = intern(e.Attr)
+		edgeFile[i] = intern(e.BuildFile)
+		edgeLine[i] = uint32(e.BuildLine)
+		edgeIdx[i] = int32(i)
+	}
+	outStart, outEdge := buildCSR(n, ix.EdgeFrom, edgeIdx)
+	inStart, inEdge := buildCSR(n, ix.EdgeTo, edgeIdx)
+
+	var strBlob bytes.Buffer
+	strOff := make([]uint32, len(strs)+1)
+	for i, s := range strs {
+		strBlob.WriteString(s)
+		strOff[i+1] = uint32(strBlob.Len())
+	}
+	if int64(strBlob.Len()) > math.MaxUint32 {
+		return errors.New("snapshot too large for the compact format")
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("creating directory for compact snapshot: %w", err)
+	}
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("creating compact snapshot: %w", err)
+	}
+	defer os.Remove(f.Name())
+
+	w := bufio.NewWriterSize(f, 1<<20)
+	w.WriteString(compactMagic)
+	for _, v := range []int{n, m, len(strs), keyBlob.Len(), nodeBlob.Len(), strBlob.Len()} {
+		binary.Write(w, binary.LittleEndian, uint32(v))
+	}
+	for _, arr := range [][]uint32{keyOff, nodeOff, pkg, strOff, edgeFrom, edgeTo, edgeType, edgeAttr, edgeFile, edgeLine} {
+		binary.Write(w, binary.LittleEndian, arr)
+	}
+	for _, arr := range [][]int32{outStart, outEdge, inStart, inEdge} {
+		binary.Write(w, binary.LittleEndian, arr)
+	}
+	w.Write(keyBlob.Bytes())
+	w.Write(nodeBlob.Bytes())
+	w.Write(strBlob.Bytes())
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return fmt.Errorf("writing compact snapshot: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("writing compact snapshot: %w", err)
+	}
+	if err := os.Rename(f.Name(), path); err != nil {
+		return fmt.Errorf("writing compact snapshot: %w", err)
+	}
+	return nil
+}
+
+// OpenCompact maps the compact snapshot at path. Close releases it; strings
+// and nodes returned before Close remain valid.
+func OpenCompact(path string) (*Compact, error) {
+	data, unmap, err := mapFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("opening compact snapshot: %w", err)
+	}
+	c := &Compact{data: data, unmap: unmap}
+	if err := c.layout(); err != nil {
+		unmap()
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return c, nil
+}
+
+// layout reads the header, locates each section, and checks that every
+// offset and index in the arrays stays within the section it points into, so
+// a corrupt or truncated file is an error rather than a panic on access.
+func (c *Compact) layout() error {
+	if len(c.data) < compactHeaderLen || string(c.data[:len(compactMagic)]) != compactMagic {
+		return ErrCompactFormat
+	}
+	hdr := func(i int) int { return int(binary.LittleEndian.Uint32(c.data[len(compactMagic)+4*i:])) }
+	c.keys, c.edges, c.strs = hdr(0), hdr(1), hdr(2)
+	keyBlobLen, nodeBlobLen, strBlobLen := hdr(3), hdr(4), hdr(5)
+
+	off := compactHeaderLen
+	next := func(count int) int {
+		at := off
+		off += 4 * count
+		return at
+	}
+	c.keyOff, c.nodeOff, c.pkg, c.strOff = next(c.keys+1), next(c.keys+1), next(c.keys), next(c.strs+1)
+	c.edgeFrom, c.edgeTo, c.edgeType = next(c.edges), next(c.edges), next(c.edges)
+	c.edgeAttr, c.edgeFile, c.edgeLine = next(c.edges), next(c.edges), next(c.edges)
+	c.outStart, c.outEdge, c.inStart, c.inEdge = next(c.keys+1), next(c.edges), next(c.keys+1), next(c.edges)
+	c.keyBlob = off
+	c.nodeBlob = c.keyBlob + keyBlobLen
+	c.strBlob = c.nodeBlob + nodeBlobLen
+	if c.strBlob+strBlobLen != len(c.data) {
+		return ErrCompactFormat
+	}
+
+	for _, sec := range []struct {
+		name         string
+		at, n, limit int
+	}{
+		{"key offsets", c.keyOff, c.keys, keyBlobLen},
+		{"node offsets", c.nodeOff, c.keys, nodeBlobLen},
+		{"string offsets", c.strOff, c.strs, strBlobLen},
+		{"out-edge starts", c.outStart, c.keys, c.edges},
+		{"in-edge starts", c.inStart, c.keys, c.edges},
+	} {
+		if err := c.checkOffsets(sec.at, sec.n, sec.limit); err != nil {
+			return fmt.Errorf("%w: %s %v", ErrCompactFormat, sec.name, err)
+		}
+	}
+	for _, sec := range []struct {
+		name         string
+		at, n, limit int
+	}{
+		{"packages", c.pkg, c.keys, c.strs},
+		{"edge sources", c.edgeFrom, c.edges, c.keys},
+		{"edge targets", c.edgeTo, c.edges, c.keys},
+		{"edge types", c.edgeType, c.edges, c.strs},
+		{"edge attributes", c.edgeAttr, c.edges, c.strs},
+		{"edge files", c.edgeFile, c.edges, c.strs},
+		{"out-edges", c.outEdge, c.edges, c.edges},
+		{"in-edges", c.inEdge, c.edges, c.edges},
+	} {
+		for i := 0; i < sec.n; i++ {
+			if v := int(c.u32(sec.at, i)); v >= sec.limit {
+				return fmt.Errorf("%w: %s[%d] = %d, want < %d", ErrCompactFormat, sec.name, i, v, sec.limit)
+			}
+		}
+	}
+	return nil
+}
+
+// checkOffsets checks an offset array of n+1 entries: it starts at 0, never
+// decreases, and ends at limit, the length of what it points into.
+func (c *Compact) checkOffsets(at, n, limit int) error {
+	prev := 0
+	for i := 0; i <= n; i++ {
+		v := int(c.u32(at, i))
+		if i == 0 && v != 0 {
+			return fmt.Errorf("start at %d, want 0", v)
+		}
+		if v < prev {
+			return fmt.Errorf("decrease at %d", i)
+		}
+		prev = v
+	}
+	if prev != limit {
+		return fmt.Errorf("end at %d, want %d", prev, limit)
+	}
+	return nil
+}
+
+// Close unmaps the file.
+func (c *Compact) Close() error {
+	if c.unmap == nil {
+		return nil
+	}
+	err := c.unmap()
+	c.unmap, c.data = nil, nil
+	return err
+}
+
+func (c *Compact) u32(section, i int) uint32 {
+	return binary.LittleEndian.Uint32(c.data[section+4*i:])
+}
+
+func (c *Compact) keyBytes(id int32) []byte {
+	return c.data[c.keyBlob+int(c.u32(c.keyOff, int(id))) : c.keyBlob+int(c.u32(c.keyOff, int(id)+1))]
+}
+
+func (c *Compact) str(i uint32) string {
+	return string(c.data[c.strBlob+int(c.u32(c.strOff, int(i))) : c.strBlob+int(c.u32(c.strOff, int(i)+1))])
+}
+
+// Len returns the number of interned labels.
+func (c *Compact) Len() int { return c.keys }
+
+// EdgeCount returns the number of edges.
+func (c *Compact) EdgeCount() int { return c.edges }
+
+// Key returns the label for an ID.
+func (c *Compact) Key(id int32) string { return string(c.keyBytes(id)) }
+
+// ID returns the ID for a label.
+func (c *Compact) ID(key string) (int32, bool) {
+	kb := []byte(key)
+	i := sort.Search(c.keys, func(i int) bool { return bytes.Compare(c.keyBytes(int32(i)), kb) >= 0 })
+	if i < c.keys && bytes.Equal(c.keyBytes(int32(i)), kb) {
+		return int32(i), true
+	}
+	return 0, false
+}
+
+// IsNode reports whether id is a node rather than only an edge endpoint.
+func (c *Compact) IsNode(id int32) bool {
+	return c.u32(c.nodeOff, int(id)+1) > c.u32(c.nodeOff, int(id))
+}
+
+// NodeCount returns the number of nodes.
+func (c *Compact) NodeCount() int {
+	var n int
+	for id := int32(0); id < int32(c.keys); id++ {
+		if c.IsNode(id) {
+			n++
+		}
+	}
+	return n
+}
+
+// Node decodes the node for id, or returns nil if id is not a node.
+func (c *Compact) Node(id int32) (*Node, error) {
+	lo, hi := c.u32(c.nodeOff, int(id)), c.u32(c.nodeOff, int(id)+1)
+	if lo == hi {
+		return nil, nil
+	}
+	var n Node
+	if err := json.Unmarshal(c.data[c.nodeBlob+int(lo):c.nodeBlob+int(hi)], &n); err != nil {
+		return nil, fmt.Errorf("decoding node %d: %w", id, err)
+	}
+	return &n, nil
+}
+
+// NodesWithPrefix returns the IDs of the nodes whose labels start with
+// prefix, in label order.
+func (c *Compact) NodesWithPrefix(prefix string) []int32 {
+	pb := []byte(prefix)
+	i := sort.Search(c.keys, func(i int) bool { return bytes.Compare(c.keyBytes(int32(i)), pb) >= 0 })
+	var ids []int32
+	for ; i < c.keys && bytes.HasPrefix(c.keyBytes(int32(i)), pb); i++ {
+		if c.IsNode(int32(i)) {
+			ids = append(ids, int32(i))
+		}
+	}
+	return ids
+}
+
+// NodesInPackage returns the IDs of the nodes in pkg, in label order.
+func (c *Compact) NodesInPackage(pkg string) []int32 {
+	want := -1
+	for i := 1; i < c.strs; i++ {
+		if c.str(uint32(i)) == pkg {
+			want = i
+			break
+		}
+	}
+	var ids []int32
+	if want < 0 {
+		return ids
+	}
+	for id := int32(0); id < int32(c.keys); id++ {
+		if c.IsNode(id) && int(c.u32(c.pkg, int(id))) == want {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// OutEdges returns the indices of the edges leaving id, in edge order.
+func (c *Compact) OutEdges(id int32) []int32 { return c.edgeRange(c.outStart, c.outEdge, id) }
+
+// InEdges returns the indices of the edges entering id, in edge order.
+func (c *Compact) InEdges(id int32) []int32 { return c.edgeRange(c.inStart, c.inEdge, id) }
+
+func (c *Compact) edgeRange(start, adj int, id int32) []int32 {
+	lo, hi := int(c.u32(start, int(id))), int(c.u32(start, int(id)+1))
+	out := make([]int32, hi-lo)
+	for i := range out {
+		out[i] = int32(c.u32(adj, lo+i))
+	}
+	return out
+}
+
+// EdgeFrom and EdgeTo return the endpoint IDs of edge i.
+func (c *Compact) EdgeFrom(i int32) int32 { return int32(c.u32(c.edgeFrom, int(i))) }
+func (c *Compact) EdgeTo(i int32) int32   { return int32(c.u32(c.edgeTo, int(i))) }
+
+// Deps returns the IDs id depends on, one per edge.
+func (c *Compact) Deps(id int32) []int32 {
+	out := c.OutEdges(id)
+	for i, e := range out {
+		out[i] = c.EdgeTo(e)
+	}
+	return out
+}
+
+// RDeps returns the IDs that depend on id, one per edge.
+func (c *Compact) RDeps(id int32) []int32 {
+	in := c.InEdges(id)
+	for i, e := range in {
+		in[i] = c.EdgeFrom(e)
+	}
+	return in
+}
+
+// Edge decodes edge i.
+func (c *Compact) Edge(i int32) Edge {
+	return Edge{
+		From:      c.Key(c.EdgeFrom(i)),
+		To:        c.Key(c.EdgeTo(i)),
+		Type:      c.str(c.u32(c.edgeType, int(i))),
+		Attr:      c.str(c.u32(c.edgeAttr, int(i))),
+		BuildFile: c.str(c.u32(c.edgeFile, int(i))),
+		BuildLine: int(c.u32(c.edgeLine, int(i))),
+	}
+}
@@ -0,0 +1,109 @@
+package graph
+
+import (
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestCompactRoundTrip(t *testing.T) {
+	snap := &Snapshot{
+		Nodes: map[string]*Node{
+			"//a:lib":  {Key: "//a:lib", Kind: "go_library", Package: "//a", Tags: []string{"x"}},
+			"//a:test": {Key: "//a:test", Kind: "go_test", Package: "//a", IsTest: true},
+			"//b:lib":  {Key: "//b:lib", Kind: "go_library", Package: "//b"},
+		},
+		Edges: []Edge{
+			{From: "//a:lib", To: "//b:lib", Type: "COMPILE", Attr: "deps", BuildFile: "a/BUILD", BuildLine: 3},
+			{From: "//a:test", To: "//a:lib", Type: "COMPILE"},
+			{From: "//a:lib", To: "//a:data.txt", Type: "DATA"},
+		},
+	}
+	path := filepath.Join(t.TempDir(), "snap.idx")
+	if err := WriteCompact(path, snap); err != nil {
+		t.Fatal(err)
+	}
+	c, err := OpenCompact(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	ix := NewIndex(snap)
+	if c.Len() != ix.Len() || c.EdgeCount() != 3 || c.NodeCount() != 3 {
+		t.Fatalf("Len, EdgeCount, NodeCount = %d, %d, %d; want %d, 3, 3", c.Len(), c.EdgeCount(), c.NodeCount(), ix.Len())
+	}
+	for id := int32(0); id < int32(ix.Len()); id++ {
+		if c.Key(id) != ix.Key(id) {
+			t.Errorf("Key(%d) = %s, want %s", id, c.Key(id), ix.Key(id))
+		}
+		if got, ok := c.ID(ix.Key(id)); !ok || got != id {
+			t.Errorf("ID(%s) = %d, %v; want %d", ix.Key(id), got, ok, id)
+		}
+		if !reflect.DeepEqual(c.Deps(id), append([]int32{}, ix.Deps(id)...)) || !reflect.DeepEqual(c.RDeps(id), append([]int32{}, ix.RDeps(id)...)) {
+			t.Errorf("adjacency of %s differs from Index", ix.Key(id))
+		}
+	}
+	if _, ok := c.ID("//missing:lib"); ok {
+		t.Error("ID found a missing label")
+	}
+
+	data, _ := c.ID("//a:data.txt")
+	if c.IsNode(data) {
+		t.Error("an edge endpoint without a node is reported as a node")
+	}
+	a, _ := c.ID("//a:lib")
+	if n, err := c.Node(a); err != nil || !reflect.DeepEqual(n, snap.Nodes["//a:lib"]) {
+		t.Errorf("Node(//a:lib) = %+v, %v", n, err)
+	}
+	for i, e := range snap.Edges {
+		if got := c.Edge(int32(i)); got != e {
+			t.Errorf("Edge(%d) = %+v, want %+v", i, got, e)
+		}
+	}
+	if got := c.NodesWithPrefix("//a:"); len(got) != 2 {
+		t.Errorf("NodesWithPrefix(//a:) = %v, want the two //a nodes", got)
+	}
+	if got := c.NodesInPackage("//b"); len(got) != 1 || c.Key(got[0]) != "//b:lib" {
+		t.Errorf("NodesInPackage(//b) = %v", got)
+	}
+}
+
+func TestOpenCompactRejectsOtherFiles(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "snap.json")
+	if err := os.WriteFile(path, []byte(`{"id":"s1","nodes":{}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := OpenCompact(path); !errors.Is(err, ErrCompactFormat) {
+		t.Errorf("OpenCompact(json) = %v, want ErrCompactFormat", err)
+	}
+}
+
+func TestOpenCompactRejectsCorruptOffsets(t *testing.T) {
+	snap := &Snapshot{
+		Nodes: map[string]*Node{
+			"//a:lib": {Key: "//a:lib", Kind: "go_library", Package: "//a"},
+			"//b:lib": {Key: "//b:lib", Kind: "go_library", Package: "//b"},
+		},
+		Edges: []Edge{{From: "//a:lib", To: "//b:lib", Type: "COMPILE"}},
+	}
+	path := filepath.Join(t.TempDir(), "snap.idx")
+	if err := WriteCompact(path, snap); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The key offsets follow the header; point the second one past the key blob.
+	binary.LittleEndian.PutUint32(data[compactHeaderLen+4:], 1<<30)
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := OpenCompact(path); !errors.Is(err, ErrCompactFormat) {
+		t.Errorf("OpenCompact(corrupt key offset) = %v, want ErrCompactFormat", err)
+	}
+}
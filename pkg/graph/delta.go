@@ -1,9 +1,23 @@
 package graph
 
-import "github.com/google/uuid"
+import (
+	"runtime"
+	"sort"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+// minParallelChunk is the smallest slice of edges worth handing to its own
+// worker; smaller inputs are compared on the calling goroutine.
+const minParallelChunk = 8192
 
 // ComputeDelta computes the structural difference between a base and head snapshot.
 // For nodes, it diffs by key. For edges, it diffs by (from, to, type) triple.
+//
+// The node diffs, the edge set construction, and the edge comparisons run
+// concurrently, with large edge lists split across a pool of workers. Added
+// and removed nodes are sorted by key; edges keep snapshot order.
 func ComputeDelta(base, head *Snapshot) *Delta {
 	delta := &Delta{
 		ID:             uuid.New().String(),
@@ -11,45 +25,88 @@ func ComputeDelta(base, head *Snapshot) *Delta {
 		HeadSnapshotID: head.ID,
 	}
 
-	// Node diff
-	for key, node := range head.Nodes {
-		if _, exists := base.Nodes[key]; !exists {
-			delta.AddedNodes = append(delta.AddedNodes, *node)
-		}
+	var baseEdges, headEdges map[string]struct{}
+	var wg sync.WaitGroup
+	wg.Add(4)
+	go func() { defer wg.Done(); delta.AddedNodes = missingNodes(head.Nodes, base.Nodes) }()
+	go func() { defer wg.Done(); delta.RemovedNodes = missingNodes(base.Nodes, head.Nodes) }()
+	go func() { defer wg.Done(); baseEdges = edgeSet(base.Edges) }()
+	go func() { defer wg.Done(); headEdges = edgeSet(head.Edges) }()
+	wg.Wait()
+
+	wg.Add(2)
+	go func() { defer wg.Done(); delta.AddedEdges = missingEdges(head.Edges, baseEdges) }()
+	go func() { defer wg.Done(); delta.RemovedEdges = missingEdges(base.Edges, headEdges) }()
+	wg.Wait()
+
+	delta.Stats = DeltaStats{
+		AddedNodeCount:   len(delta.AddedNodes),
+		RemovedNodeCount: len(delta.RemovedNodes),
+		AddedEdgeCount:   len(delta.AddedEdges),
+		RemovedEdgeCount: len(delta.RemovedEdges),
 	}
-	for key, node := range base.Nodes {
-		if _, exists := head.Nodes[key]; !exists {
-			delta.RemovedNodes = append(delta.RemovedNodes, *node)
+	delta.Summary = SummarizeDelta(delta)
+
+	return delta
+}
+
+// missingNodes returns the nodes of from whose keys are not in other.
+func missingNodes(from, other map[string]*Node) []Node {
+	var out []Node
+	for key, node := range from {
+		if _, exists := other[key]; !exists {
+			out = append(out, *node)
 		}
 	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Key < out[j].Key })
+	return out
+}
 
-	// Edge diff using set operations on edge keys
-	baseEdges := make(map[string]Edge, len(base.Edges))
-	for _, e := range base.Edges {
-		baseEdges[e.EdgeKey()] = e
-	}
-	headEdges := make(map[string]Edge, len(head.Edges))
-	for _, e := range head.Edges {
-		headEdges[e.EdgeKey()] = e
+func edgeSet(edges []Edge) map[string]struct{} {
+	set := make(map[string]struct{}, len(edges))
+	for _, e := range edges {
+		set[e.EdgeKey()] = struct{}{}
 	}
+	return set
+}
 
-	for key, edge := range headEdges {
-		if _, exists := baseEdges[key]; !exists {
-			delta.AddedEdges = append(delta.AddedEdges, edge)
-		}
+// missingEdges returns the edges whose keys are not in other, dropping
+// duplicates. The read-only set is shared by all workers.
+func missingEdges(edges []Edge, other map[string]struct{}) []Edge {
+	workers := runtime.GOMAXPROCS(0)
+	if n := len(edges) / minParallelChunk; n < workers {
+		workers = max(n, 1)
 	}
-	for key, edge := range baseEdges {
-		if _, exists := headEdges[key]; !exists {
-			delta.RemovedEdges = append(delta.RemovedEdges, edge)
+	chunk := (len(edges) + workers - 1) / workers
+
+	parts := make([][]Edge, workers)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		lo, hi := w*chunk, min((w+1)*chunk, len(edges))
+		if lo >= hi {
+			break
 		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for _, e := range edges[lo:hi] {
+				if _, exists := other[e.EdgeKey()]; !exists {
+					parts[w] = append(parts[w], e)
+				}
+			}
+		}()
 	}
+	wg.Wait()
 
-	delta.Stats = DeltaStats{
-		AddedNodeCount:   len(delta.AddedNodes),
-		RemovedNodeCount: len(delta.RemovedNodes),
-		AddedEdgeCount:   len(delta.AddedEdges),
-		RemovedEdgeCount: len(delta.RemovedEdges),
+	var out []Edge
+	seen := make(map[string]bool)
+	for _, part := range parts {
+		for _, e := range part {
+			if key := e.EdgeKey(); !seen[key] {
+				seen[key] = true
+				out = append(out, e)
+			}
+		}
 	}
-
-	return delta
+	return out
 }
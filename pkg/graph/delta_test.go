@@ -1,6 +1,7 @@
 package graph
 
 import (
+	"fmt"
 	"path/filepath"
 	"runtime"
 	"testing"
@@ -100,3 +101,62 @@ func TestComputeDelta_AllRemoved(t *testing.T) {
 		t.Errorf("RemovedEdgeCount = %d, want 1", delta.Stats.RemovedEdgeCount)
 	}
 }
+
+// syntheticPair returns a base graph of n chain-linked nodes and a head that
+// drops every 10th edge and adds a skip edge for every 7th node.
+func syntheticPair(n int) (*Snapshot, *Snapshot) {
+	base := &Snapshot{ID: "base", Nodes: make(map[string]*Node, n)}
+	head := &Snapshot{ID: "head", Nodes: make(map[string]*Node, n+1)}
+	key := func(i int) string { return fmt.Sprintf("//pkg%d:lib", i) }
+	for i := 0; i < n; i++ {
+		node := &Node{Key: key(i), Kind: "go_library", Package: fmt.Sprintf("//pkg%d", i)}
+		base.Nodes[node.Key] = node
+		head.Nodes[node.Key] = node
+	}
+	head.Nodes["//new:lib"] = &Node{Key: "//new:lib", Package: "//new"}
+	for i := 0; i+1 < n; i++ {
+		e := Edge{From: key(i), To: key(i + 1), Type: "COMPILE"}
+		base.Edges = append(base.Edges, e)
+		if i%10 != 0 {
+			head.Edges = append(head.Edges, e)
+		}
+		if i%7 == 0 && i+2 < n {
+			head.Edges = append(head.Edges, Edge{From: key(i), To: key(i + 2), Type: "COMPILE"})
+		}
+	}
+	return base, head
+}
+
+func TestComputeDelta_LargeParallel(t *testing.T) {
+	const n = 50000
+	base, head := syntheticPair(n)
+	// Duplicate edges must be reported once, even when the copies land
+	// in different workers' chunks.
+	head.Edges = append(head.Edges, head.Edges...)
+
+	delta := ComputeDelta(base, head)
+
+	wantRemoved := (n - 1 + 9) / 10
+	wantAdded := (n - 3 + 7) / 7
+	if delta.Stats.RemovedEdgeCount != wantRemoved {
+		t.Errorf("RemovedEdgeCount = %d, want %d", delta.Stats.RemovedEdgeCount, wantRemoved)
+	}
+	if delta.Stats.AddedEdgeCount != wantAdded {
+		t.Errorf("AddedEdgeCount = %d, want %d", delta.Stats.AddedEdgeCount, wantAdded)
+	}
+	if delta.Stats.AddedNodeCount != 1 || delta.AddedNodes[0].Key != "//new:lib" {
+		t.Errorf("AddedNodes = %v, want [//new:lib]", delta.AddedNodes)
+	}
+}
+
+func BenchmarkComputeDelta(b *testing.B) {
+	for _, n := range []int{10000, 100000, 1000000} {
+		base, head := syntheticPair(n)
+		b.Run(fmt.Sprintf("edges=%d", len(base.Edges)), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				ComputeDelta(base, head)
+			}
+		})
+	}
+}
@@ -0,0 +1,81 @@
+package graph
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// CacheStats counts a target's spawns in a build and how many of them were
+// served from a cache.
+type CacheStats struct {
+	Spawns int `json:"spawns"`
+	Hits   int `json:"hits"`
+}
+
+// spawnExec holds the parts of an execution log entry that carry caching.
+type spawnExec struct {
+	TargetLabel    string `json:"targetLabel"`
+	RemoteCacheHit bool   `json:"remoteCacheHit"`
+	Runner         string `json:"runner"`
+}
+
+// ParseExecutionLog reads a bazel execution log, as written by
+// --execution_log_json_file, and returns the cache stats of each target its
+// spawns name. A spawn is a hit when the remote cache served it or its
+// runner was a disk or remote cache. Spawns without a target are skipped.
+func ParseExecutionLog(r io.Reader) (map[string]CacheStats, error) {
+	stats := make(map[string]CacheStats)
+	dec := json.NewDecoder(r)
+	for n := 1; ; n++ {
+		var spawn spawnExec
+		if err := dec.Decode(&spawn); err != nil {
+			if errors.Is(err, io.EOF) {
+				return stats, nil
+			}
+			return nil, fmt.Errorf("execution log entry %d: %w", n, err)
+		}
+		if spawn.TargetLabel == "" {
+			continue
+		}
+		label := bepLabel(spawn.TargetLabel)
+		s := stats[label]
+		s.Spawns++
+		if spawn.RemoteCacheHit || strings.Contains(spawn.Runner, "cache hit") {
+			s.Hits++
+		}
+		stats[label] = s
+	}
+}
+
+// AnnotateCacheStats sets the cache stats of the nodes in snap that stats
+// names, and returns how many nodes it annotated. Like AnnotateTimings, it
+// copies the nodes it annotates and re-derives snap.ID.
+func AnnotateCacheStats(snap *Snapshot, stats map[string]CacheStats) int {
+	n := 0
+	for label, s := range stats {
+		node := snap.Nodes[label]
+		if node == nil {
+			continue
+		}
+		annotated := *node
+		annotated.CacheSpawns, annotated.CacheHits = s.Spawns, s.Hits
+		snap.Nodes[label] = &annotated
+		n++
+	}
+	if n > 0 {
+		snap.ID = ContentID(snap)
+	}
+	return n
+}
+
+// CacheHitRate returns the share of n's spawns served from a cache, and
+// false when n has no cache stats.
+func (n *Node) CacheHitRate() (float64, bool) {
+	if n.CacheSpawns == 0 {
+		return 0, false
+	}
+	return float64(n.CacheHits) / float64(n.CacheSpawns), true
+}
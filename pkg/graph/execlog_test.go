@@ -0,0 +1,46 @@
+package graph
+
+import (
+	"strings"
+	"testing"
+)
+
+// Older bazel versions write the execution log pretty-printed, newer ones
+// one entry per line; both must parse.
+const testExecutionLog = `{"commandArgs":["gcc"],"targetLabel":"//app:lib","runner":"remote cache hit","remoteCacheHit":true}
+{"commandArgs":["gcc"],"targetLabel":"@//app:lib","runner":"linux-sandbox"}
+{
+  "commandArgs": ["javac"],
+  "targetLabel": "//lib:util",
+  "runner": "disk cache hit"
+}
+{"commandArgs":["touch"],"runner":"local"}
+`
+
+func TestParseExecutionLog(t *testing.T) {
+	stats, err := ParseExecutionLog(strings.NewReader(testExecutionLog))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stats) != 2 || stats["//app:lib"] != (CacheStats{Spawns: 2, Hits: 1}) || stats["//lib:util"] != (CacheStats{Spawns: 1, Hits: 1}) {
+		t.Errorf("unexpected stats: %v", stats)
+	}
+
+	snap := &Snapshot{Nodes: map[string]*Node{"//app:lib": {Key: "//app:lib"}, "//other:lib": {Key: "//other:lib"}}}
+	if n := AnnotateCacheStats(snap, stats); n != 1 {
+		t.Errorf("expected 1 annotated node, got %d", n)
+	}
+	if rate, ok := snap.Nodes["//app:lib"].CacheHitRate(); !ok || rate != 0.5 {
+		t.Errorf("expected a 0.5 hit rate, got %v %v", rate, ok)
+	}
+	if _, ok := snap.Nodes["//other:lib"].CacheHitRate(); ok {
+		t.Error("expected no hit rate without stats")
+	}
+	if snap.ID != ContentID(snap) {
+		t.Errorf("ID = %q after annotating, want the content ID %q", snap.ID, ContentID(snap))
+	}
+
+	if _, err := ParseExecutionLog(strings.NewReader(`{"targetLabel": 1}`)); err == nil {
+		t.Error("expected an error for a malformed entry")
+	}
+}
@@ -0,0 +1,35 @@
+package graph
+
+import "path"
+
+// GeneratedPatterns identify generated targets by rule class or tag. Each
+// pattern is a glob as in path.Match: "*_proto_library" matches
+// go_proto_library and java_proto_library.
+type GeneratedPatterns struct {
+	Kinds []string
+	Tags  []string
+}
+
+// DefaultGeneratedPatterns marks the targets protobuf and gRPC rules
+// generate, and targets tagged "generated".
+var DefaultGeneratedPatterns = GeneratedPatterns{
+	Kinds: []string{"*_proto_library", "*_grpc_library", "*_proto_compile"},
+	Tags:  []string{"generated"},
+}
+
+// Match reports whether n's rule class or one of its tags matches a pattern.
+func (p GeneratedPatterns) Match(n *Node) bool {
+	for _, pat := range p.Kinds {
+		if ok, _ := path.Match(pat, n.Kind); ok {
+			return true
+		}
+	}
+	for _, pat := range p.Tags {
+		for _, tag := range n.Tags {
+			if ok, _ := path.Match(pat, tag); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,30 @@
+package graph
+
+import "testing"
+
+func TestGeneratedPatternsMatch(t *testing.T) {
+	tests := []struct {
+		node Node
+		want bool
+	}{
+		{Node{Kind: "go_proto_library"}, true},
+		{Node{Kind: "java_grpc_library"}, true},
+		{Node{Kind: "proto_library"}, false},
+		{Node{Kind: "go_library"}, false},
+		{Node{Kind: "genrule", Tags: []string{"manual", "generated"}}, true},
+		{Node{Kind: "genrule", Tags: []string{"manual"}}, false},
+	}
+	for _, tt := range tests {
+		if got := DefaultGeneratedPatterns.Match(&tt.node); got != tt.want {
+			t.Errorf("Match(%s %v) = %v, want %v", tt.node.Kind, tt.node.Tags, got, tt.want)
+		}
+	}
+
+	custom := GeneratedPatterns{Kinds: []string{"*_wrapper"}, Tags: []string{"codegen-*"}}
+	if !custom.Match(&Node{Kind: "swig_wrapper"}) || !custom.Match(&Node{Kind: "genrule", Tags: []string{"codegen-thrift"}}) {
+		t.Error("custom patterns did not match")
+	}
+	if custom.Match(&Node{Kind: "go_proto_library"}) {
+		t.Error("custom patterns should replace the defaults")
+	}
+}
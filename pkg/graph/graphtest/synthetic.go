@@ -0,0 +1,119 @@
+// Package graphtest builds synthetic snapshots for tests and benchmarks.
+package graphtest
+
+import (
+	"fmt"
+	"math/rand"
+
+	"github.com/toposcope/toposcope/pkg/graph"
+)
+
+// targetsPerPackage is how many targets share a package, so package
+// aggregation and cross-package metrics see realistic groupings.
+const targetsPerPackage = 8
+
+// depsPerTarget is the average out-degree. Real monorepos sit near 4-5.
+const depsPerTarget = 4
+
+// Snapshot returns a deterministic acyclic graph with about the given number
+// of edges. Targets live under 16 top-level directories; every 5th target is
+// a test, and most dependencies stay near their source so locality resembles
+// a real tree. The same size always yields the same graph.
+func Snapshot(edges int) *graph.Snapshot {
+	n := max(edges/depsPerTarget, 16)
+	rng := rand.New(rand.NewSource(int64(edges)))
+	snap := &graph.Snapshot{
+		ID:        fmt.Sprintf("synthetic-%d", edges),
+		CommitSHA: fmt.Sprintf("%040x", edges),
+		Nodes:     make(map[string]*graph.Node, n),
+		Edges:     make([]graph.Edge, 0, edges),
+	}
+	for i := 0; i < n; i++ {
+		key := Key(i)
+		test := i%5 == 4
+		kind := "go_library"
+		if test {
+			kind = "go_test"
+		}
+		snap.Nodes[key] = &graph.Node{
+			Key:      key,
+			Kind:     kind,
+			Language: "go",
+			Package:  pkgOf(i),
+			IsTest:   test,
+		}
+	}
+
+	// Each target depends only on lower-numbered targets, which keeps the
+	// graph acyclic. Three of four deps are local; the rest are anywhere.
+	seen := make(map[[2]int]bool, edges)
+	for len(snap.Edges) < edges {
+		from := 1 + rng.Intn(n-1)
+		var to int
+		if rng.Intn(4) > 0 {
+			to = max(0, from-1-rng.Intn(4*targetsPerPackage))
+		} else {
+			to = rng.Intn(from)
+		}
+		if seen[[2]int{from, to}] {
+			continue
+		}
+		seen[[2]int{from, to}] = true
+		snap.Edges = append(snap.Edges, graph.Edge{From: Key(from), To: Key(to), Type: "COMPILE"})
+	}
+	snap.Stats = graph.SnapshotStats{
+		NodeCount:    len(snap.Nodes),
+		EdgeCount:    len(snap.Edges),
+		PackageCount: len(snap.Packages()),
+	}
+	return snap
+}
+
+// Pair returns Snapshot(edges) as a base and a head that changes about 1% of
+// the edges: every 100th edge is removed and as many new edges are added,
+// including some to a new target.
+func Pair(edges int) (base, head *graph.Snapshot) {
+	base = Snapshot(edges)
+	head = &graph.Snapshot{
+		ID:        base.ID + "-head",
+		CommitSHA: fmt.Sprintf("%040x", edges+1),
+		Nodes:     make(map[string]*graph.Node, len(base.Nodes)+1),
+		Edges:     make([]graph.Edge, 0, len(base.Edges)),
+	}
+	for k, n := range base.Nodes {
+		head.Nodes[k] = n
+	}
+	const added = "//new/feature:lib"
+	head.Nodes[added] = &graph.Node{Key: added, Kind: "go_library", Language: "go", Package: "//new/feature"}
+
+	n := len(base.Nodes)
+	for i, e := range base.Edges {
+		if i%100 != 0 {
+			head.Edges = append(head.Edges, e)
+			continue
+		}
+		// Replace the edge with one from the new target, or a fanout
+		// increase on an existing one.
+		if i%200 == 0 {
+			head.Edges = append(head.Edges, graph.Edge{From: added, To: e.To, Type: "COMPILE"})
+		} else {
+			head.Edges = append(head.Edges, graph.Edge{From: e.From, To: Key((i * 7) % n), Type: "COMPILE"})
+		}
+	}
+	head.Stats = graph.SnapshotStats{
+		NodeCount:    len(head.Nodes),
+		EdgeCount:    len(head.Edges),
+		PackageCount: len(head.Packages()),
+	}
+	return base, head
+}
+
+// Key returns the label of the i'th synthetic target.
+func Key(i int) string {
+	return fmt.Sprintf("%s:t%d", pkgOf(i), i)
+}
+
+func pkgOf(i int) string {
+	p := i / targetsPerPackage
+	return fmt.Sprintf("//d%d/p%d", p%16, p)
+}
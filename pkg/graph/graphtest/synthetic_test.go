@@ -0,0 +1,28 @@
+package graphtest
+
+import "testing"
+
+func TestSnapshot(t *testing.T) {
+	snap := Snapshot(1000)
+	if len(snap.Edges) != 1000 {
+		t.Errorf("got %d edges, want 1000", len(snap.Edges))
+	}
+	for _, e := range snap.Edges {
+		if snap.Nodes[e.From] == nil || snap.Nodes[e.To] == nil {
+			t.Fatalf("edge %s -> %s has a missing endpoint", e.From, e.To)
+		}
+	}
+	if again := Snapshot(1000); again.Edges[500] != snap.Edges[500] {
+		t.Error("Snapshot is not deterministic")
+	}
+}
+
+func TestPair(t *testing.T) {
+	base, head := Pair(1000)
+	if len(head.Edges) != len(base.Edges) {
+		t.Errorf("head has %d edges, want %d", len(head.Edges), len(base.Edges))
+	}
+	if len(head.Nodes) != len(base.Nodes)+1 {
+		t.Errorf("head has %d nodes, want one more than base's %d", len(head.Nodes), len(base.Nodes))
+	}
+}
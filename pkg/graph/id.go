@@ -0,0 +1,51 @@
+package graph
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"slices"
+
+	"github.com/google/uuid"
+)
+
+// snapshotNamespace scopes content-derived snapshot IDs.
+var snapshotNamespace = uuid.NewSHA1(uuid.NameSpaceURL, []byte("https://github.com/toposcope/toposcope/snapshot"))
+
+// ContentID derives a snapshot ID from its commit, extraction scope, and
+// graph content, so extracting the same commit twice yields the same ID.
+// Branch, stats, and the extraction time are not part of the content. The
+// ID is a UUID so it can stand wherever a random one did.
+func ContentID(s *Snapshot) string {
+	h := sha256.New()
+	enc := json.NewEncoder(h)
+	scope := slices.Clone(s.Scope)
+	slices.Sort(scope)
+	enc.Encode(struct {
+		CommitSHA string   `json:"commit_sha"`
+		Partial   bool     `json:"partial"`
+		Scope     []string `json:"scope"`
+	}{s.CommitSHA, s.Partial, scope})
+
+	keys := make([]string, 0, len(s.Nodes))
+	for k := range s.Nodes {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	for _, k := range keys {
+		enc.Encode(s.Nodes[k])
+	}
+
+	// Edge order follows query output, so hash the edges as a sorted set.
+	edges := make([]string, len(s.Edges))
+	for i, e := range s.Edges {
+		data, _ := json.Marshal(e)
+		edges[i] = string(data)
+	}
+	slices.Sort(edges)
+	for _, e := range edges {
+		h.Write([]byte(e))
+		h.Write([]byte{'\n'})
+	}
+
+	return uuid.NewSHA1(snapshotNamespace, h.Sum(nil)).String()
+}
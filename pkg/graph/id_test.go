@@ -0,0 +1,73 @@
+package graph
+
+import (
+	"testing"
+	"time"
+)
+
+func TestContentID(t *testing.T) {
+	build := func() *Snapshot {
+		return &Snapshot{
+			CommitSHA: "abc123",
+			Nodes: map[string]*Node{
+				"//a:lib": {Key: "//a:lib", Kind: "go_library", Package: "//a"},
+				"//b:lib": {Key: "//b:lib", Kind: "go_library", Package: "//b"},
+			},
+			Edges: []Edge{
+				{From: "//a:lib", To: "//b:lib", Type: "COMPILE"},
+				{From: "//b:lib", To: "//a:lib", Type: "RUNTIME"},
+			},
+		}
+	}
+
+	a, b := build(), build()
+	b.Edges[0], b.Edges[1] = b.Edges[1], b.Edges[0]
+	b.Branch = "main"
+	b.ExtractedAt = time.Now()
+	b.Stats.ExtractionMs = 42
+	if ContentID(a) != ContentID(b) {
+		t.Error("IDs differ for the same content")
+	}
+
+	changes := map[string]func(*Snapshot){
+		"commit": func(s *Snapshot) { s.CommitSHA = "def456" },
+		"scope":  func(s *Snapshot) { s.Partial, s.Scope = true, []string{"//a:lib"} },
+		"node":   func(s *Snapshot) { s.Nodes["//b:lib"].IsTest = true },
+		"edge":   func(s *Snapshot) { s.Edges = s.Edges[:1] },
+	}
+	for name, change := range changes {
+		c := build()
+		change(c)
+		if ContentID(c) == ContentID(a) {
+			t.Errorf("%s change kept the same ID", name)
+		}
+	}
+}
+
+func TestVerify(t *testing.T) {
+	snap := &Snapshot{
+		CommitSHA: "abc123",
+		Nodes: map[string]*Node{
+			"//a:lib": {Key: "//a:lib"},
+			"//b:lib": {Key: "//b:lib"},
+		},
+		Edges: []Edge{{From: "//a:lib", To: "//b:lib", Type: "COMPILE"}},
+		Stats: SnapshotStats{NodeCount: 2, EdgeCount: 1},
+	}
+	snap.ID = ContentID(snap)
+	if problems := Verify(snap); len(problems) != 0 {
+		t.Fatalf("Verify = %v, want no problems", problems)
+	}
+
+	snap.Edges = append(snap.Edges, Edge{From: "//c:lib", To: "//a:lib", Type: "COMPILE"})
+	if problems := Verify(snap); len(problems) != 3 {
+		t.Errorf("Verify = %v, want edge count, dangling edge, and ID problems", problems)
+	}
+
+	// Random IDs predate content IDs and are not checked against content.
+	snap.ID = "0b0e7d8e-5f1c-4c39-9d0e-5f7a3f8d2c11"
+	snap.Stats.EdgeCount = 2
+	if problems := Verify(snap); len(problems) != 1 {
+		t.Errorf("Verify = %v, want only the dangling edge", problems)
+	}
+}
@@ -0,0 +1,98 @@
+package graph
+
+import "sort"
+
+// Index is an interned, read-only view of a snapshot's structure. Each label
+// is stored once in a string table and nodes are referred to by dense int32
+// IDs; adjacency is kept in compressed sparse row form. On million-edge graphs
+// this is several times smaller than label-keyed adjacency maps, and
+// traversals can track visited nodes in a slice instead of a map.
+//
+// IDs are assigned in sorted label order, so they are stable for a given set
+// of labels. Edge endpoints missing from Nodes are interned too.
+type Index struct {
+	keys []string
+	ids  map[string]int32
+
+	// EdgeFrom[i] and EdgeTo[i] are the endpoint IDs of snap.Edges[i].
+	EdgeFrom []int32
+	EdgeTo   []int32
+
+	outStart, outAdj []int32
+	inStart, inAdj   []int32
+}
+
+// NewIndex builds an Index for snap.
+func NewIndex(snap *Snapshot) *Index {
+	seen := make(map[string]bool, len(snap.Nodes))
+	keys := make([]string, 0, len(snap.Nodes))
+	for key := range snap.Nodes {
+		seen[key] = true
+		keys = append(keys, key)
+	}
+	for _, e := range snap.Edges {
+		for _, key := range [2]string{e.From, e.To} {
+			if !seen[key] {
+				seen[key] = true
+				keys = append(keys, key)
+			}
+		}
+	}
+	sort.Strings(keys)
+
+	ix := &Index{
+		keys:     keys,
+		ids:      make(map[string]int32, len(keys)),
+		EdgeFrom: make([]int32, len(snap.Edges)),
+		EdgeTo:   make([]int32, len(snap.Edges)),
+	}
+	for i, key := range keys {
+		ix.ids[key] = int32(i)
+	}
+	for i, e := range snap.Edges {
+		ix.EdgeFrom[i] = ix.ids[e.From]
+		ix.EdgeTo[i] = ix.ids[e.To]
+	}
+	ix.outStart, ix.outAdj = buildCSR(len(keys), ix.EdgeFrom, ix.EdgeTo)
+	ix.inStart, ix.inAdj = buildCSR(len(keys), ix.EdgeTo, ix.EdgeFrom)
+	return ix
+}
+
+// buildCSR groups to[i] by from[i]. Neighbors keep edge order.
+func buildCSR(n int, from, to []int32) (start, adj []int32) {
+	start = make([]int32, n+1)
+	for _, f := range from {
+		start[f+1]++
+	}
+	for i := 1; i <= n; i++ {
+		start[i] += start[i-1]
+	}
+	adj = make([]int32, len(to))
+	next := make([]int32, n)
+	copy(next, start[:n])
+	for i, f := range from {
+		adj[next[f]] = to[i]
+		next[f]++
+	}
+	return start, adj
+}
+
+// Len returns the number of interned labels.
+func (ix *Index) Len() int { return len(ix.keys) }
+
+// ID returns the ID for a label.
+func (ix *Index) ID(key string) (int32, bool) {
+	id, ok := ix.ids[key]
+	return id, ok
+}
+
+// Key returns the label for an ID.
+func (ix *Index) Key(id int32) string { return ix.keys[id] }
+
+// Deps returns the IDs id depends on, one per edge. The slice must not be
+// modified.
+func (ix *Index) Deps(id int32) []int32 { return ix.outAdj[ix.outStart[id]:ix.outStart[id+1]] }
+
+// RDeps returns the IDs that depend on id, one per edge. The slice must not
+// be modified.
+func (ix *Index) RDeps(id int32) []int32 { return ix.inAdj[ix.inStart[id]:ix.inStart[id+1]] }
@@ -0,0 +1,55 @@
+package graph
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNewIndex(t *testing.T) {
+	snap := &Snapshot{
+		Nodes: map[string]*Node{
+			"//a:lib": {Key: "//a:lib"},
+			"//b:lib": {Key: "//b:lib"},
+			"//c:lib": {Key: "//c:lib"},
+		},
+		Edges: []Edge{
+			{From: "//a:lib", To: "//b:lib", Type: "COMPILE"},
+			{From: "//a:lib", To: "//c:lib", Type: "COMPILE"},
+			{From: "//b:lib", To: "//c:lib", Type: "RUNTIME"},
+			{From: "//c:lib", To: "//missing:lib", Type: "COMPILE"},
+		},
+	}
+	ix := NewIndex(snap)
+
+	if ix.Len() != 4 {
+		t.Fatalf("Len = %d, want 4 (edge endpoints are interned too)", ix.Len())
+	}
+	id := func(key string) int32 {
+		t.Helper()
+		v, ok := ix.ID(key)
+		if !ok {
+			t.Fatalf("missing ID for %s", key)
+		}
+		if ix.Key(v) != key {
+			t.Fatalf("Key(ID(%s)) = %s", key, ix.Key(v))
+		}
+		return v
+	}
+	a, b, c, m := id("//a:lib"), id("//b:lib"), id("//c:lib"), id("//missing:lib")
+
+	if got := ix.Deps(a); !reflect.DeepEqual(got, []int32{b, c}) {
+		t.Errorf("Deps(a) = %v, want [%d %d]", got, b, c)
+	}
+	if got := ix.RDeps(c); !reflect.DeepEqual(got, []int32{a, b}) {
+		t.Errorf("RDeps(c) = %v, want [%d %d]", got, a, b)
+	}
+	if got := ix.Deps(m); len(got) != 0 {
+		t.Errorf("Deps(missing) = %v, want none", got)
+	}
+	if ix.EdgeFrom[3] != c || ix.EdgeTo[3] != m {
+		t.Errorf("edge 3 = %d->%d, want %d->%d", ix.EdgeFrom[3], ix.EdgeTo[3], c, m)
+	}
+	if _, ok := ix.ID("//nope:lib"); ok {
+		t.Error("unexpected ID for unknown label")
+	}
+}
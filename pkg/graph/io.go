@@ -1,8 +1,12 @@
 package graph
 
 import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
 	"encoding/json"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 )
@@ -25,15 +29,57 @@ func SaveSnapshot(path string, snap *Snapshot) error {
 	return nil
 }
 
-// LoadSnapshot reads a snapshot from disk.
+// LoadSnapshot reads a snapshot from disk. The file may be gzip-compressed.
 func LoadSnapshot(path string) (*Snapshot, error) {
-	data, err := os.ReadFile(path)
+	f, err := os.Open(path)
 	if err != nil {
 		return nil, fmt.Errorf("reading snapshot: %w", err)
 	}
+	defer f.Close()
+
+	return ReadSnapshot(f)
+}
+
+// WriteSnapshot writes a snapshot to w as JSON, in the same form as
+// SaveSnapshot, gzip-compressing it when compress is set.
+func WriteSnapshot(w io.Writer, snap *Snapshot, compress bool) error {
+	var gz *gzip.Writer
+	if compress {
+		gz = gzip.NewWriter(w)
+		w = gz
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(snap); err != nil {
+		return fmt.Errorf("writing snapshot: %w", err)
+	}
+
+	if gz != nil {
+		if err := gz.Close(); err != nil {
+			return fmt.Errorf("writing snapshot: %w", err)
+		}
+	}
+	return nil
+}
+
+// ReadSnapshot reads a JSON snapshot from r, such as a pipe from
+// `toposcope snapshot --output -`. Gzip-compressed input is detected.
+func ReadSnapshot(r io.Reader) (*Snapshot, error) {
+	br := bufio.NewReader(r)
+	if magic, _ := br.Peek(2); bytes.Equal(magic, []byte{0x1f, 0x8b}) {
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, fmt.Errorf("reading snapshot: %w", err)
+		}
+		defer gz.Close()
+		r = gz
+	} else {
+		r = br
+	}
 
 	var snap Snapshot
-	if err := json.Unmarshal(data, &snap); err != nil {
+	if err := json.NewDecoder(r).Decode(&snap); err != nil {
 		return nil, fmt.Errorf("unmarshaling snapshot: %w", err)
 	}
 
@@ -0,0 +1,65 @@
+package graph
+
+import (
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteReadSnapshot(t *testing.T) {
+	snap, err := LoadSnapshot(testdataPath("snapshot_base.json"))
+	if err != nil {
+		t.Fatalf("loading snapshot: %v", err)
+	}
+
+	for _, compress := range []bool{false, true} {
+		var buf bytes.Buffer
+		if err := WriteSnapshot(&buf, snap, compress); err != nil {
+			t.Fatalf("WriteSnapshot(compress=%v): %v", compress, err)
+		}
+		if gz := bytes.HasPrefix(buf.Bytes(), []byte{0x1f, 0x8b}); gz != compress {
+			t.Errorf("compress=%v: gzip header present = %v", compress, gz)
+		}
+		got, err := ReadSnapshot(&buf)
+		if err != nil {
+			t.Fatalf("ReadSnapshot(compress=%v): %v", compress, err)
+		}
+		if got.CommitSHA != snap.CommitSHA || len(got.Nodes) != len(snap.Nodes) || len(got.Edges) != len(snap.Edges) {
+			t.Errorf("compress=%v: round trip = %s with %d nodes, %d edges; want %s with %d, %d",
+				compress, got.CommitSHA, len(got.Nodes), len(got.Edges), snap.CommitSHA, len(snap.Nodes), len(snap.Edges))
+		}
+	}
+
+	// The stream is what SaveSnapshot writes.
+	path := filepath.Join(t.TempDir(), "snap.json")
+	if err := SaveSnapshot(path, snap); err != nil {
+		t.Fatal(err)
+	}
+	saved, _ := os.ReadFile(path)
+	var streamed bytes.Buffer
+	if err := WriteSnapshot(&streamed, snap, false); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(bytes.TrimSpace(streamed.Bytes()), bytes.TrimSpace(saved)) {
+		t.Error("WriteSnapshot output differs from SaveSnapshot")
+	}
+
+	// LoadSnapshot reads gzipped files too.
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	_, _ = zw.Write(saved)
+	_ = zw.Close()
+	gzPath := filepath.Join(t.TempDir(), "snap.json.gz")
+	if err := os.WriteFile(gzPath, gz.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := LoadSnapshot(gzPath); err != nil || got.CommitSHA != snap.CommitSHA {
+		t.Errorf("LoadSnapshot(gzip) = %v, %v", got, err)
+	}
+
+	if _, err := ReadSnapshot(bytes.NewReader([]byte("not json"))); err == nil {
+		t.Error("expected an error for invalid input")
+	}
+}
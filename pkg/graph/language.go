@@ -0,0 +1,47 @@
+package graph
+
+import "strings"
+
+// languagePrefixes maps rule class prefixes to languages.
+var languagePrefixes = []struct{ prefix, language string }{
+	{"go_", "go"},
+	{"java_", "java"},
+	{"android_", "java"},
+	{"kt_", "kotlin"},
+	{"kotlin_", "kotlin"},
+	{"scala_", "scala"},
+	{"py_", "python"},
+	{"python_", "python"},
+	{"ts_", "typescript"},
+	{"js_", "javascript"},
+	{"nodejs_", "javascript"},
+	{"cc_", "cc"},
+	{"objc_", "objc"},
+	{"swift_", "swift"},
+	{"rust_", "rust"},
+	{"sh_", "shell"},
+	{"proto_", "proto"},
+}
+
+// KindLanguage derives a target's language from its rule class:
+// "go_library" -> "go", "java_test" -> "java". Language-specific proto
+// rules (go_proto_library, java_proto_library, ...) take the language
+// they generate. It returns "" for classes it doesn't know, such as
+// genrule, filegroup, or test_suite.
+func KindLanguage(kind string) string {
+	for _, p := range languagePrefixes {
+		if strings.HasPrefix(kind, p.prefix) {
+			return p.language
+		}
+	}
+	return ""
+}
+
+// LanguageOf returns n's language, deriving it from the rule class for
+// snapshots extracted before nodes recorded one.
+func LanguageOf(n *Node) string {
+	if n.Language != "" || n.IsExternal {
+		return n.Language
+	}
+	return KindLanguage(n.Kind)
+}
@@ -0,0 +1,44 @@
+package graph
+
+import "testing"
+
+func TestKindLanguage(t *testing.T) {
+	tests := map[string]string{
+		"go_library":         "go",
+		"go_proto_library":   "go",
+		"java_test":          "java",
+		"android_library":    "java",
+		"kt_jvm_library":     "kotlin",
+		"py_binary":          "python",
+		"ts_project":         "typescript",
+		"cc_library":         "cc",
+		"proto_library":      "proto",
+		"genrule":            "",
+		"test_suite":         "",
+		"":                   "",
+		"rust_binary":        "rust",
+		"sh_test":            "shell",
+		"scala_library":      "scala",
+		"js_library":         "javascript",
+		"swift_library":      "swift",
+		"objc_library":       "objc",
+		"python_wheel_maker": "python",
+	}
+	for kind, want := range tests {
+		if got := KindLanguage(kind); got != want {
+			t.Errorf("KindLanguage(%q) = %q, want %q", kind, got, want)
+		}
+	}
+}
+
+func TestLanguageOf(t *testing.T) {
+	if got := LanguageOf(&Node{Kind: "go_library"}); got != "go" {
+		t.Errorf("derived language = %q, want go", got)
+	}
+	if got := LanguageOf(&Node{Kind: "custom_rule", Language: "java"}); got != "java" {
+		t.Errorf("recorded language = %q, want java", got)
+	}
+	if got := LanguageOf(&Node{Kind: "java_library", IsExternal: true}); got != "" {
+		t.Errorf("external language = %q, want none", got)
+	}
+}
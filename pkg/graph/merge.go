@@ -0,0 +1,76 @@
+package graph
+
+// MergeIntoBaseline overlays a partial (scoped) snapshot onto a full baseline,
+// producing a full snapshot suitable for diffing against that baseline.
+//
+// Nodes present in the partial snapshot are authoritative: their metadata and
+// outgoing edges replace the baseline's. Scope targets missing from the partial
+// snapshot are treated as deleted, along with any edges touching them. All
+// other baseline nodes and edges are carried over unchanged, so a scoped head
+// no longer appears to remove everything outside its extraction scope.
+//
+// The merged snapshot is new content, so it gets its own content ID rather
+// than the partial's. If partial is not a partial snapshot it is returned
+// as-is.
+func MergeIntoBaseline(baseline, partial *Snapshot) *Snapshot {
+	if partial == nil || !partial.Partial || baseline == nil {
+		return partial
+	}
+
+	deleted := make(map[string]bool)
+	for _, target := range partial.Scope {
+		if _, ok := partial.Nodes[target]; !ok {
+			deleted[target] = true
+		}
+	}
+
+	nodes := make(map[string]*Node, len(baseline.Nodes)+len(partial.Nodes))
+	for key, node := range baseline.Nodes {
+		if !deleted[key] {
+			nodes[key] = node
+		}
+	}
+	for key, node := range partial.Nodes {
+		nodes[key] = node
+	}
+
+	seen := make(map[string]bool, len(baseline.Edges)+len(partial.Edges))
+	edges := make([]Edge, 0, len(baseline.Edges)+len(partial.Edges))
+	for _, e := range partial.Edges {
+		if deleted[e.To] || seen[e.EdgeKey()] {
+			continue
+		}
+		seen[e.EdgeKey()] = true
+		edges = append(edges, e)
+	}
+	for _, e := range baseline.Edges {
+		// Outgoing edges of re-extracted nodes come from the partial snapshot.
+		if _, overridden := partial.Nodes[e.From]; overridden {
+			continue
+		}
+		if deleted[e.From] || deleted[e.To] || seen[e.EdgeKey()] {
+			continue
+		}
+		seen[e.EdgeKey()] = true
+		edges = append(edges, e)
+	}
+
+	merged := &Snapshot{
+		CommitSHA:   partial.CommitSHA,
+		Branch:      partial.Branch,
+		Partial:     false,
+		Scope:       partial.Scope,
+		Nodes:       nodes,
+		Edges:       edges,
+		ExtractedAt: partial.ExtractedAt,
+	}
+	merged.Stats = SnapshotStats{
+		NodeCount:    len(nodes),
+		EdgeCount:    len(edges),
+		PackageCount: len(merged.Packages()),
+		ExtractionMs: partial.Stats.ExtractionMs,
+	}
+	merged.ID = ContentID(merged)
+
+	return merged
+}
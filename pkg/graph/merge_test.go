@@ -0,0 +1,106 @@
+package graph
+
+import "testing"
+
+func mergeBaseline() *Snapshot {
+	return &Snapshot{
+		ID: "base",
+		Nodes: map[string]*Node{
+			"//a:lib": {Key: "//a:lib", Package: "//a"},
+			"//b:lib": {Key: "//b:lib", Package: "//b"},
+			"//c:lib": {Key: "//c:lib", Package: "//c"},
+			"//d:lib": {Key: "//d:lib", Package: "//d"},
+		},
+		Edges: []Edge{
+			{From: "//a:lib", To: "//b:lib", Type: "COMPILE"},
+			{From: "//b:lib", To: "//c:lib", Type: "COMPILE"},
+			{From: "//d:lib", To: "//c:lib", Type: "COMPILE"},
+		},
+	}
+}
+
+func TestMergeIntoBaseline(t *testing.T) {
+	base := mergeBaseline()
+
+	// Scoped extraction of //b:lib and its rdeps: //b:lib now depends on //d:lib
+	// instead of //c:lib, and new target //e:lib depends on //b:lib.
+	partial := &Snapshot{
+		ID:        "head",
+		CommitSHA: "abc123",
+		Partial:   true,
+		Scope:     []string{"//b:lib"},
+		Nodes: map[string]*Node{
+			"//a:lib": {Key: "//a:lib", Package: "//a"},
+			"//b:lib": {Key: "//b:lib", Package: "//b", Kind: "go_library"},
+			"//e:lib": {Key: "//e:lib", Package: "//e"},
+		},
+		Edges: []Edge{
+			{From: "//a:lib", To: "//b:lib", Type: "COMPILE"},
+			{From: "//b:lib", To: "//d:lib", Type: "COMPILE"},
+			{From: "//e:lib", To: "//b:lib", Type: "COMPILE"},
+		},
+	}
+
+	merged := MergeIntoBaseline(base, partial)
+
+	if merged.Partial {
+		t.Error("expected merged snapshot to be full")
+	}
+	if merged.ID != ContentID(merged) || merged.CommitSHA != "abc123" {
+		t.Errorf("expected head commit and a content ID, got ID=%q CommitSHA=%q", merged.ID, merged.CommitSHA)
+	}
+	if len(merged.Nodes) != 5 {
+		t.Errorf("got %d nodes, want 5", len(merged.Nodes))
+	}
+	if merged.Nodes["//b:lib"].Kind != "go_library" {
+		t.Error("expected partial node metadata to override baseline")
+	}
+	if merged.Stats.NodeCount != 5 || merged.Stats.EdgeCount != len(merged.Edges) || merged.Stats.PackageCount != 5 {
+		t.Errorf("unexpected stats: %+v", merged.Stats)
+	}
+
+	delta := ComputeDelta(base, merged)
+	if delta.Stats.RemovedNodeCount != 0 {
+		t.Errorf("RemovedNodeCount = %d, want 0", delta.Stats.RemovedNodeCount)
+	}
+	if delta.Stats.AddedNodeCount != 1 {
+		t.Errorf("AddedNodeCount = %d, want 1", delta.Stats.AddedNodeCount)
+	}
+	// Removed: b->c. Added: b->d, e->b.
+	if delta.Stats.RemovedEdgeCount != 1 || delta.RemovedEdges[0].To != "//c:lib" {
+		t.Errorf("RemovedEdges = %v, want [//b:lib -> //c:lib]", delta.RemovedEdges)
+	}
+	if delta.Stats.AddedEdgeCount != 2 {
+		t.Errorf("AddedEdgeCount = %d, want 2", delta.Stats.AddedEdgeCount)
+	}
+}
+
+func TestMergeIntoBaseline_DeletedScopeTarget(t *testing.T) {
+	base := mergeBaseline()
+	partial := &Snapshot{
+		Partial: true,
+		Scope:   []string{"//d:lib"},
+		Nodes:   map[string]*Node{},
+	}
+
+	merged := MergeIntoBaseline(base, partial)
+	if _, ok := merged.Nodes["//d:lib"]; ok {
+		t.Error("expected deleted scope target to be removed")
+	}
+	for _, e := range merged.Edges {
+		if e.From == "//d:lib" || e.To == "//d:lib" {
+			t.Errorf("unexpected edge touching deleted target: %v", e)
+		}
+	}
+	if len(merged.Nodes) != 3 {
+		t.Errorf("got %d nodes, want 3", len(merged.Nodes))
+	}
+}
+
+func TestMergeIntoBaseline_FullSnapshot(t *testing.T) {
+	base := mergeBaseline()
+	full := &Snapshot{ID: "full", Nodes: map[string]*Node{}}
+	if got := MergeIntoBaseline(base, full); got != full {
+		t.Error("expected full snapshot to be returned unchanged")
+	}
+}
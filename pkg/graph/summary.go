@@ -0,0 +1,115 @@
+package graph
+
+import (
+	"sort"
+	"strings"
+)
+
+// ChangeCount tallies the changes attributed to one package or boundary.
+type ChangeCount struct {
+	Name         string `json:"name"`
+	AddedNodes   int    `json:"added_nodes"`
+	RemovedNodes int    `json:"removed_nodes"`
+	AddedEdges   int    `json:"added_edges"`
+	RemovedEdges int    `json:"removed_edges"`
+}
+
+// Total returns the number of changes counted.
+func (c ChangeCount) Total() int {
+	return c.AddedNodes + c.RemovedNodes + c.AddedEdges + c.RemovedEdges
+}
+
+// DeltaSummary rolls a delta up by package and by boundary (the first path
+// component of the package, e.g. "app" for //app/foo). Entries are ordered
+// by total changes, most affected first.
+type DeltaSummary struct {
+	Packages   []ChangeCount `json:"packages"`
+	Boundaries []ChangeCount `json:"boundaries"`
+}
+
+// SummarizeDelta computes a DeltaSummary. A node counts toward its own
+// package; an edge counts toward the packages of both endpoints (once when
+// they are the same).
+func SummarizeDelta(d *Delta) *DeltaSummary {
+	pkgs := make(map[string]*ChangeCount)
+	bounds := make(map[string]*ChangeCount)
+	tally := func(m map[string]*ChangeCount, name string, field func(*ChangeCount) *int) {
+		c := m[name]
+		if c == nil {
+			c = &ChangeCount{Name: name}
+			m[name] = c
+		}
+		*field(c)++
+	}
+	count := func(names []string, field func(*ChangeCount) *int) {
+		seenPkg := make(map[string]bool, 2)
+		seenBound := make(map[string]bool, 2)
+		for _, pkg := range names {
+			if pkg == "" {
+				continue
+			}
+			if !seenPkg[pkg] {
+				seenPkg[pkg] = true
+				tally(pkgs, pkg, field)
+			}
+			if b := Boundary(pkg); !seenBound[b] {
+				seenBound[b] = true
+				tally(bounds, b, field)
+			}
+		}
+	}
+
+	nodePkg := func(n Node) string {
+		if n.Package != "" {
+			return n.Package
+		}
+		return LabelPackage(n.Key)
+	}
+	for _, n := range d.AddedNodes {
+		count([]string{nodePkg(n)}, func(c *ChangeCount) *int { return &c.AddedNodes })
+	}
+	for _, n := range d.RemovedNodes {
+		count([]string{nodePkg(n)}, func(c *ChangeCount) *int { return &c.RemovedNodes })
+	}
+	for _, e := range d.AddedEdges {
+		count([]string{LabelPackage(e.From), LabelPackage(e.To)}, func(c *ChangeCount) *int { return &c.AddedEdges })
+	}
+	for _, e := range d.RemovedEdges {
+		count([]string{LabelPackage(e.From), LabelPackage(e.To)}, func(c *ChangeCount) *int { return &c.RemovedEdges })
+	}
+
+	return &DeltaSummary{Packages: rankedCounts(pkgs), Boundaries: rankedCounts(bounds)}
+}
+
+func rankedCounts(m map[string]*ChangeCount) []ChangeCount {
+	out := make([]ChangeCount, 0, len(m))
+	for _, c := range m {
+		out = append(out, *c)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if ti, tj := out[i].Total(), out[j].Total(); ti != tj {
+			return ti > tj
+		}
+		return out[i].Name < out[j].Name
+	})
+	return out
+}
+
+// LabelPackage returns the package part of a label: "//app/foo" for
+// "//app/foo:lib". Labels without a target name are their own package.
+func LabelPackage(label string) string {
+	if idx := strings.LastIndex(label, ":"); idx >= 0 {
+		return label[:idx]
+	}
+	return label
+}
+
+// Boundary returns the first path component of a package: "app" for
+// "//app/foo", "@maven" for "@maven//:guava".
+func Boundary(pkg string) string {
+	p := strings.TrimPrefix(pkg, "//")
+	if idx := strings.Index(p, "/"); idx >= 0 {
+		return p[:idx]
+	}
+	return p
+}
@@ -0,0 +1,50 @@
+package graph
+
+import "testing"
+
+func TestSummarizeDelta(t *testing.T) {
+	d := &Delta{
+		AddedNodes:   []Node{{Key: "//app/foo:new", Package: "//app/foo"}},
+		RemovedNodes: []Node{{Key: "//lib/old:lib", Package: "//lib/old"}},
+		AddedEdges: []Edge{
+			{From: "//app/foo:lib", To: "//lib/bar:lib", Type: "COMPILE"},
+			{From: "//app/foo:lib", To: "//app/foo:new", Type: "COMPILE"},
+		},
+		RemovedEdges: []Edge{
+			{From: "//app/baz:lib", To: "//lib/old:lib", Type: "COMPILE"},
+		},
+	}
+
+	s := SummarizeDelta(d)
+
+	wantPkgs := map[string]ChangeCount{
+		"//app/foo": {Name: "//app/foo", AddedNodes: 1, AddedEdges: 2},
+		"//lib/bar": {Name: "//lib/bar", AddedEdges: 1},
+		"//lib/old": {Name: "//lib/old", RemovedNodes: 1, RemovedEdges: 1},
+		"//app/baz": {Name: "//app/baz", RemovedEdges: 1},
+	}
+	if len(s.Packages) != len(wantPkgs) {
+		t.Fatalf("got %d packages, want %d: %+v", len(s.Packages), len(wantPkgs), s.Packages)
+	}
+	for _, c := range s.Packages {
+		if want, ok := wantPkgs[c.Name]; !ok || c != want {
+			t.Errorf("package %s = %+v, want %+v", c.Name, c, want)
+		}
+	}
+	if s.Packages[0].Name != "//app/foo" {
+		t.Errorf("most affected package = %s, want //app/foo", s.Packages[0].Name)
+	}
+
+	wantBounds := map[string]ChangeCount{
+		"app": {Name: "app", AddedNodes: 1, AddedEdges: 2, RemovedEdges: 1},
+		"lib": {Name: "lib", RemovedNodes: 1, AddedEdges: 1, RemovedEdges: 1},
+	}
+	if len(s.Boundaries) != len(wantBounds) {
+		t.Fatalf("got %d boundaries, want %d: %+v", len(s.Boundaries), len(wantBounds), s.Boundaries)
+	}
+	for _, c := range s.Boundaries {
+		if want := wantBounds[c.Name]; c != want {
+			t.Errorf("boundary %s = %+v, want %+v", c.Name, c, want)
+		}
+	}
+}
@@ -21,20 +21,41 @@ type Snapshot struct {
 
 // Node represents a single build target in the dependency graph.
 type Node struct {
-	Key        string   `json:"key"`     // canonical Bazel label: "//app/foo:lib"
-	Kind       string   `json:"kind"`    // rule class: "go_library", "java_test", etc.
-	Package    string   `json:"package"` // Bazel package: "//app/foo"
-	Tags       []string `json:"tags,omitempty"`
-	Visibility []string `json:"visibility,omitempty"`
-	IsTest     bool     `json:"is_test"`
-	IsExternal bool     `json:"is_external"` // labels starting with @
+	Key         string   `json:"key"`                // canonical Bazel label: "//app/foo:lib"
+	Kind        string   `json:"kind"`               // rule class: "go_library", "java_test", etc.
+	Language    string   `json:"language,omitempty"` // from the rule class: "go", "java", etc.; empty if unknown
+	Package     string   `json:"package"`            // Bazel package: "//app/foo"
+	Tags        []string `json:"tags,omitempty"`
+	Visibility  []string `json:"visibility,omitempty"`
+	IsTest      bool     `json:"is_test"`
+	IsExternal  bool     `json:"is_external"`            // labels starting with @
+	IsGenerated bool     `json:"is_generated,omitempty"` // generated code, such as proto outputs
+
+	// Repo is the tracked repository an external target is built from,
+	// as set by the extraction repo_mapping: "acme/foo" for "@corp_foo//lib".
+	Repo string `json:"repo,omitempty"`
+
+	// Durations from the build event protocol, when the snapshot was
+	// annotated with a build.
+	BuildMs int64 `json:"build_ms,omitempty"` // summed action time
+	TestMs  int64 `json:"test_ms,omitempty"`  // total test run time
+
+	// Cache stats from an execution log, when the snapshot was annotated
+	// with a build.
+	CacheSpawns int `json:"cache_spawns,omitempty"` // spawns in the build
+	CacheHits   int `json:"cache_hits,omitempty"`   // of those, served from a cache
 }
 
 // Edge represents a dependency relationship between two targets.
 type Edge struct {
 	From string `json:"from"` // source node key
 	To   string `json:"to"`   // target node key
-	Type string `json:"type"` // COMPILE, RUNTIME, TOOLCHAIN, DATA
+	Type string `json:"type"` // COMPILE, RUNTIME, DATA, EXPORTS, etc.
+
+	// Provenance, when the extractor knows it.
+	Attr      string `json:"attr,omitempty"`       // rule attribute: "deps", "implementation_deps", etc.
+	BuildFile string `json:"build_file,omitempty"` // declaring BUILD file, relative to the workspace root
+	BuildLine int    `json:"build_line,omitempty"` // line of the declaring rule in BuildFile
 }
 
 // EdgeKey returns a stable string key for deduplication and set operations.
@@ -48,6 +69,22 @@ type SnapshotStats struct {
 	EdgeCount    int `json:"edge_count"`
 	PackageCount int `json:"package_count"`
 	ExtractionMs int `json:"extraction_ms"`
+
+	// SkippedPackages lists packages bazel failed to load (tolerated by
+	// --keep_going). When non-empty, the graph is incomplete.
+	SkippedPackages []string `json:"skipped_packages,omitempty"`
+
+	// Phases break the extraction time down, when the extractor timed it:
+	// "query", "parse" and "build", plus the phases of bazel query
+	// profiles as "bazel/<phase>" when they were captured. Concurrent
+	// queries add up, so phases can sum to more than ExtractionMs.
+	Phases []PhaseTiming `json:"phases,omitempty"`
+}
+
+// PhaseTiming is the time an extraction spent in one phase.
+type PhaseTiming struct {
+	Name string `json:"name"`
+	Ms   int    `json:"ms"`
 }
 
 // Delta represents the structural difference between two snapshots.
@@ -62,6 +99,9 @@ type Delta struct {
 	AddedEdges      []Edge     `json:"added_edges"`
 	RemovedEdges    []Edge     `json:"removed_edges"`
 	Stats           DeltaStats `json:"stats"`
+
+	// Summary rolls the changes up by package and boundary.
+	Summary *DeltaSummary `json:"summary,omitempty"`
 }
 
 // DeltaStats holds summary statistics for a delta.
@@ -0,0 +1,48 @@
+package graph
+
+import (
+	"fmt"
+
+	"github.com/google/uuid"
+)
+
+// Verify checks a snapshot for internal consistency and returns the problems
+// found. A snapshot whose ID is derived from its content (see ContentID) must
+// still match that content; snapshots with random IDs from before content IDs
+// skip that check.
+func Verify(s *Snapshot) []string {
+	var problems []string
+	if s.CommitSHA == "" {
+		problems = append(problems, "missing commit SHA")
+	}
+	for key, n := range s.Nodes {
+		if n == nil {
+			problems = append(problems, fmt.Sprintf("node %s is null", key))
+		} else if n.Key != key {
+			problems = append(problems, fmt.Sprintf("node %s has key %s", key, n.Key))
+		}
+	}
+	if s.Stats.NodeCount != len(s.Nodes) {
+		problems = append(problems, fmt.Sprintf("stats report %d nodes, found %d", s.Stats.NodeCount, len(s.Nodes)))
+	}
+	if s.Stats.EdgeCount != len(s.Edges) {
+		problems = append(problems, fmt.Sprintf("stats report %d edges, found %d", s.Stats.EdgeCount, len(s.Edges)))
+	}
+	// Edge targets may be absent (files, targets outside a partial
+	// extraction), but every source is an extracted node.
+	var dangling int
+	for _, e := range s.Edges {
+		if s.Nodes[e.From] == nil {
+			dangling++
+		}
+	}
+	if dangling > 0 {
+		problems = append(problems, fmt.Sprintf("%d edges start at a missing node", dangling))
+	}
+	if id, err := uuid.Parse(s.ID); err == nil && id.Version() == 5 {
+		if want := ContentID(s); s.ID != want {
+			problems = append(problems, fmt.Sprintf("ID %s does not match content (%s)", s.ID, want))
+		}
+	}
+	return problems
+}
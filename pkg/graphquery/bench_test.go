@@ -0,0 +1,85 @@
+package graphquery
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/toposcope/toposcope/pkg/graph"
+	"github.com/toposcope/toposcope/pkg/graph/graphtest"
+)
+
+// benchSizes are the edge counts the query benchmarks run at.
+var benchSizes = []int{10000, 100000, 1000000}
+
+// benchQuery runs fn against a synthetic snapshot of each size.
+func benchQuery(b *testing.B, fn func(snap *graph.Snapshot)) {
+	for _, n := range benchSizes {
+		snap := graphtest.Snapshot(n)
+		b.Run(fmt.Sprintf("edges=%d", n), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				fn(snap)
+			}
+		})
+	}
+}
+
+func BenchmarkExtractSubgraph(b *testing.B) {
+	benchQuery(b, func(snap *graph.Snapshot) {
+		ExtractSubgraph(snap, []string{graphtest.Key(len(snap.Nodes) / 2)}, 3, 500)
+	})
+}
+
+func BenchmarkEgoGraph(b *testing.B) {
+	benchQuery(b, func(snap *graph.Snapshot) {
+		EgoGraph(snap, graphtest.Key(len(snap.Nodes)/2), 2, "both", 500)
+	})
+}
+
+func BenchmarkFindPaths(b *testing.B) {
+	benchQuery(b, func(snap *graph.Snapshot) {
+		FindPaths(snap, graphtest.Key(len(snap.Nodes)-1), graphtest.Key(0), 10)
+	})
+}
+
+func BenchmarkAggregatePackages(b *testing.B) {
+	benchQuery(b, func(snap *graph.Snapshot) {
+		AggregatePackages(snap, true, false, 1, 500)
+	})
+}
+
+func BenchmarkSearchNodes(b *testing.B) {
+	benchQuery(b, func(snap *graph.Snapshot) {
+		SearchNodes(snap, "p1", nil, nil, 50)
+	})
+}
+
+func BenchmarkTopOffenders(b *testing.B) {
+	benchQuery(b, func(snap *graph.Snapshot) {
+		TopOffenders(snap, 10, false)
+	})
+}
+
+func BenchmarkFindCycles(b *testing.B) {
+	benchQuery(b, func(snap *graph.Snapshot) {
+		FindCycles(snap)
+	})
+}
+
+func BenchmarkSampleGraph(b *testing.B) {
+	benchQuery(b, func(snap *graph.Snapshot) {
+		SampleGraph(snap, 500, 0)
+	})
+}
+
+func BenchmarkLayoutPackages(b *testing.B) {
+	benchQuery(b, func(snap *graph.Snapshot) {
+		LayoutPackages(AggregatePackages(snap, true, false, 1, 500))
+	})
+}
+
+func BenchmarkDetectCommunities(b *testing.B) {
+	benchQuery(b, func(snap *graph.Snapshot) {
+		DetectCommunities(AggregatePackages(snap, true, false, 1, 500))
+	})
+}
@@ -0,0 +1,299 @@
+package graphquery
+
+import (
+	"sort"
+
+	"github.com/toposcope/toposcope/pkg/graph"
+)
+
+// Community is a group of packages more densely connected to each other
+// than to the rest of the graph.
+type Community struct {
+	ID       int      `json:"id"`
+	Packages []string `json:"packages"`
+	// Boundary is the top-level directory most of the packages are in, and
+	// Purity the share of packages in it. A community with low purity is a
+	// module that cuts across declared boundaries.
+	Boundary       string  `json:"boundary"`
+	Purity         float64 `json:"purity"`
+	InternalWeight int     `json:"internal_weight"` // edges between its packages
+	ExternalWeight int     `json:"external_weight"` // edges to or from other communities
+}
+
+// BoundaryCommunities lists the communities a boundary's packages fall in.
+// A boundary spread over several communities is not one module in practice.
+type BoundaryCommunities struct {
+	Boundary    string `json:"boundary"`
+	Packages    int    `json:"packages"`
+	Communities []int  `json:"communities"`
+}
+
+// CommunityResult holds the communities of a package graph.
+type CommunityResult struct {
+	Communities []Community           `json:"communities"`
+	Boundaries  []BoundaryCommunities `json:"boundaries"`
+	Modularity  float64               `json:"modularity"`
+	Truncated   bool                  `json:"truncated"` // the package graph was capped
+}
+
+// DetectCommunities groups the packages of a package graph with the Louvain
+// method, treating edges as undirected and weighted by target edge count.
+// Communities are numbered largest first, and each is compared against the
+// boundaries of its packages.
+func DetectCommunities(pg *PackageGraphResult) *CommunityResult {
+	keys := make([]string, 0, len(pg.Nodes))
+	for key := range pg.Nodes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	index := make(map[string]int, len(keys))
+	for i, key := range keys {
+		index[key] = i
+	}
+
+	weights := make([]map[int]float64, len(keys))
+	for i := range weights {
+		weights[i] = make(map[int]float64)
+	}
+	for _, e := range pg.Edges {
+		from, ok1 := index[e.From]
+		to, ok2 := index[e.To]
+		if !ok1 || !ok2 || from == to {
+			continue
+		}
+		weights[from][to] += float64(e.Weight)
+		weights[to][from] += float64(e.Weight)
+	}
+	adj := make([][]weightedEdge, len(keys))
+	for i, ws := range weights {
+		for j, w := range ws {
+			adj[i] = append(adj[i], weightedEdge{j, w})
+		}
+		sort.Slice(adj[i], func(a, b int) bool { return adj[i][a].to < adj[i][b].to })
+	}
+
+	member := louvain(adj)
+
+	// Number communities largest first, then by first package.
+	var groups [][]string
+	byMember := make(map[int]int)
+	for i, key := range keys {
+		g, ok := byMember[member[i]]
+		if !ok {
+			g = len(groups)
+			byMember[member[i]] = g
+			groups = append(groups, nil)
+		}
+		groups[g] = append(groups[g], key)
+	}
+	sort.SliceStable(groups, func(i, j int) bool { return len(groups[i]) > len(groups[j]) })
+	community := make(map[string]int, len(keys))
+	for id, g := range groups {
+		for _, key := range g {
+			community[key] = id
+		}
+	}
+
+	result := &CommunityResult{
+		Communities: make([]Community, len(groups)),
+		Boundaries:  []BoundaryCommunities{},
+		Truncated:   pg.Truncated,
+	}
+	for id, g := range groups {
+		counts := make(map[string]int)
+		for _, key := range g {
+			counts[graph.Boundary(key)]++
+		}
+		c := Community{ID: id, Packages: g}
+		for b, n := range counts {
+			if n > counts[c.Boundary] || (n == counts[c.Boundary] && b < c.Boundary) {
+				c.Boundary = b
+			}
+		}
+		c.Purity = float64(counts[c.Boundary]) / float64(len(g))
+		result.Communities[id] = c
+	}
+
+	var total float64
+	internal := make([]float64, len(groups))
+	degree := make([]float64, len(groups))
+	for _, e := range pg.Edges {
+		from, ok1 := community[e.From]
+		to, ok2 := community[e.To]
+		if !ok1 || !ok2 || e.From == e.To {
+			continue
+		}
+		total += float64(e.Weight)
+		degree[from] += float64(e.Weight)
+		degree[to] += float64(e.Weight)
+		if from == to {
+			result.Communities[from].InternalWeight += e.Weight
+			internal[from] += float64(e.Weight)
+		} else {
+			result.Communities[from].ExternalWeight += e.Weight
+			result.Communities[to].ExternalWeight += e.Weight
+		}
+	}
+	if total > 0 {
+		for c := range groups {
+			share := degree[c] / (2 * total)
+			result.Modularity += internal[c]/total - share*share
+		}
+	}
+
+	spans := make(map[string]map[int]bool)
+	sizes := make(map[string]int)
+	for _, key := range keys {
+		b := graph.Boundary(key)
+		if spans[b] == nil {
+			spans[b] = make(map[int]bool)
+		}
+		spans[b][community[key]] = true
+		sizes[b]++
+	}
+	for b, ids := range spans {
+		bc := BoundaryCommunities{Boundary: b, Packages: sizes[b]}
+		for id := range ids {
+			bc.Communities = append(bc.Communities, id)
+		}
+		sort.Ints(bc.Communities)
+		result.Boundaries = append(result.Boundaries, bc)
+	}
+	sort.Slice(result.Boundaries, func(i, j int) bool {
+		a, b := result.Boundaries[i], result.Boundaries[j]
+		if len(a.Communities) != len(b.Communities) {
+			return len(a.Communities) > len(b.Communities)
+		}
+		return a.Boundary < b.Boundary
+	})
+
+	return result
+}
+
+type weightedEdge struct {
+	to int
+	w  float64
+}
+
+// louvain returns the community of each node of an undirected weighted
+// graph, given as sorted adjacency lists with both directions of each edge.
+// Each level moves nodes to the neighbouring community with the largest
+// modularity gain until none moves, then merges each community into a node
+// and repeats. Nodes are visited in order, so the result is deterministic.
+func louvain(adj [][]weightedEdge) []int {
+	member := make([]int, len(adj))
+	for i := range member {
+		member[i] = i
+	}
+	self := make([]float64, len(adj))
+	for {
+		comm, n, moved := louvainLevel(adj, self)
+		if !moved || n == len(adj) {
+			return member
+		}
+		for i := range member {
+			member[i] = comm[member[i]]
+		}
+		adj, self = louvainMerge(adj, self, comm, n)
+	}
+}
+
+// louvainLevel runs local moves on one level of the graph. self holds each
+// node's self-loop weight, which is the internal weight of the community it
+// stands for. It returns the community of each node, numbered 0 to n-1, and
+// whether any node moved.
+func louvainLevel(adj [][]weightedEdge, self []float64) (comm []int, n int, moved bool) {
+	k := make([]float64, len(adj))
+	var m2 float64
+	for i, edges := range adj {
+		k[i] = 2 * self[i]
+		for _, e := range edges {
+			k[i] += e.w
+		}
+		m2 += k[i]
+	}
+	comm = make([]int, len(adj))
+	tot := make([]float64, len(adj))
+	for i := range comm {
+		comm[i] = i
+		tot[i] = k[i]
+	}
+	if m2 == 0 {
+		return comm, len(adj), false
+	}
+
+	toComm := make(map[int]float64)
+	var order []int
+	for pass := 0; pass < 100; pass++ {
+		changed := false
+		for i, edges := range adj {
+			own := comm[i]
+			tot[own] -= k[i]
+
+			clear(toComm)
+			order = order[:0]
+			for _, e := range edges {
+				c := comm[e.to]
+				if _, ok := toComm[c]; !ok {
+					order = append(order, c)
+				}
+				toComm[c] += e.w
+			}
+
+			best, bestGain := own, toComm[own]-tot[own]*k[i]/m2
+			for _, c := range order {
+				gain := toComm[c] - tot[c]*k[i]/m2
+				if gain > bestGain+1e-12 {
+					best, bestGain = c, gain
+				}
+			}
+			tot[best] += k[i]
+			if best != own {
+				comm[i] = best
+				changed, moved = true, true
+			}
+		}
+		if !changed {
+			break
+		}
+	}
+
+	renumber := make(map[int]int)
+	for i, c := range comm {
+		id, ok := renumber[c]
+		if !ok {
+			id = len(renumber)
+			renumber[c] = id
+		}
+		comm[i] = id
+	}
+	return comm, len(renumber), moved
+}
+
+// louvainMerge builds the next level's graph, with one node per community.
+func louvainMerge(adj [][]weightedEdge, self []float64, comm []int, n int) ([][]weightedEdge, []float64) {
+	weights := make([]map[int]float64, n)
+	for i := range weights {
+		weights[i] = make(map[int]float64)
+	}
+	nextSelf := make([]float64, n)
+	for i, edges := range adj {
+		ci := comm[i]
+		nextSelf[ci] += self[i]
+		for _, e := range edges {
+			if cj := comm[e.to]; cj == ci {
+				nextSelf[ci] += e.w / 2 // each edge is listed from both ends
+			} else {
+				weights[ci][cj] += e.w
+			}
+		}
+	}
+	next := make([][]weightedEdge, n)
+	for i, ws := range weights {
+		for j, w := range ws {
+			next[i] = append(next[i], weightedEdge{j, w})
+		}
+		sort.Slice(next[i], func(a, b int) bool { return next[i][a].to < next[i][b].to })
+	}
+	return next, nextSelf
+}
@@ -0,0 +1,82 @@
+package graphquery
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDetectCommunities(t *testing.T) {
+	// Two dense groups joined by one light edge. The second group spans
+	// the lib and platform boundaries.
+	pg := &PackageGraphResult{Nodes: map[string]*PackageNode{}}
+	for _, pkg := range []string{"//app/a", "//app/b", "//app/c", "//lib/x", "//lib/y", "//platform/z"} {
+		pg.Nodes[pkg] = &PackageNode{Package: pkg}
+	}
+	pg.Edges = []PackageEdge{
+		{From: "//app/a", To: "//app/b", Weight: 5},
+		{From: "//app/b", To: "//app/c", Weight: 5},
+		{From: "//app/c", To: "//app/a", Weight: 5},
+		{From: "//lib/x", To: "//lib/y", Weight: 5},
+		{From: "//lib/y", To: "//platform/z", Weight: 5},
+		{From: "//platform/z", To: "//lib/x", Weight: 5},
+		{From: "//app/a", To: "//lib/x", Weight: 1},
+	}
+
+	result := DetectCommunities(pg)
+	if len(result.Communities) != 2 {
+		t.Fatalf("expected 2 communities, got %+v", result.Communities)
+	}
+	app, lib := result.Communities[0], result.Communities[1]
+	if !reflect.DeepEqual(app.Packages, []string{"//app/a", "//app/b", "//app/c"}) || app.Boundary != "app" || app.Purity != 1 {
+		t.Errorf("unexpected first community: %+v", app)
+	}
+	if !reflect.DeepEqual(lib.Packages, []string{"//lib/x", "//lib/y", "//platform/z"}) || lib.Boundary != "lib" || lib.Purity < 0.66 || lib.Purity > 0.67 {
+		t.Errorf("unexpected second community: %+v", lib)
+	}
+	if app.InternalWeight != 15 || app.ExternalWeight != 1 {
+		t.Errorf("expected weights 15/1, got %d/%d", app.InternalWeight, app.ExternalWeight)
+	}
+	if result.Modularity < 0.4 {
+		t.Errorf("expected a clear split, got modularity %.3f", result.Modularity)
+	}
+
+	if len(result.Boundaries) != 3 {
+		t.Fatalf("expected 3 boundaries, got %+v", result.Boundaries)
+	}
+	for _, b := range result.Boundaries {
+		if len(b.Communities) != 1 {
+			t.Errorf("expected %s in one community, got %v", b.Boundary, b.Communities)
+		}
+	}
+}
+
+func TestDetectCommunitiesSplitBoundary(t *testing.T) {
+	// lib's packages each belong with a different app.
+	pg := &PackageGraphResult{Nodes: map[string]*PackageNode{}}
+	for _, pkg := range []string{"//a/x", "//a/y", "//lib/a", "//b/x", "//b/y", "//lib/b"} {
+		pg.Nodes[pkg] = &PackageNode{Package: pkg}
+	}
+	pg.Edges = []PackageEdge{
+		{From: "//a/x", To: "//a/y", Weight: 4},
+		{From: "//a/x", To: "//lib/a", Weight: 4},
+		{From: "//a/y", To: "//lib/a", Weight: 4},
+		{From: "//b/x", To: "//b/y", Weight: 4},
+		{From: "//b/x", To: "//lib/b", Weight: 4},
+		{From: "//b/y", To: "//lib/b", Weight: 4},
+	}
+
+	result := DetectCommunities(pg)
+	if len(result.Communities) != 2 {
+		t.Fatalf("expected 2 communities, got %+v", result.Communities)
+	}
+	if first := result.Boundaries[0]; first.Boundary != "lib" || len(first.Communities) != 2 || first.Packages != 2 {
+		t.Errorf("expected lib split across 2 communities first, got %+v", first)
+	}
+}
+
+func TestDetectCommunitiesEmpty(t *testing.T) {
+	result := DetectCommunities(&PackageGraphResult{Nodes: map[string]*PackageNode{}})
+	if len(result.Communities) != 0 || result.Modularity != 0 {
+		t.Errorf("expected no communities, got %+v", result)
+	}
+}
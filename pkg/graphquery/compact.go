@@ -0,0 +1,169 @@
+package graphquery
+
+import (
+	"slices"
+	"sort"
+
+	"github.com/toposcope/toposcope/pkg/graph"
+)
+
+// SubgraphCompact runs a subgraph query against a memory-mapped snapshot.
+// The result is the same as Subgraph's over the decoded snapshot, but only
+// the nodes and edges it returns are decoded.
+func SubgraphCompact(c *graph.Compact, p SubgraphParams) (*SubgraphResult, error) {
+	var result *SubgraphResult
+	var err error
+	if len(p.Roots) == 0 {
+		result, err = capCompact(c, p.MaxNodes, p.Offset)
+	} else {
+		var start []int32
+		seen := make(map[int32]bool)
+		for _, r := range p.Roots {
+			for _, id := range c.NodesWithPrefix(r) {
+				if !seen[id] {
+					seen[id] = true
+					start = append(start, id)
+				}
+			}
+		}
+		maxNodes := p.MaxNodes
+		if maxNodes <= 0 {
+			maxNodes = 500
+		}
+		limit := pageLimit(maxNodes, p.Offset, len(start))
+		order := bfsOrder(c, start, p.Depth, true, true, p.Offset+limit+1)
+		result, err = paginateCompact(c, order, p.Offset, limit)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if result.Truncated {
+		result.NextToken = encodePageToken(result.next, p.query())
+	}
+	return result, nil
+}
+
+// EgoCompact runs an ego graph query against a memory-mapped snapshot, with
+// the same result as Ego's over the decoded snapshot.
+func EgoCompact(c *graph.Compact, p EgoParams) (*SubgraphResult, error) {
+	direction, maxNodes := p.Direction, p.MaxNodes
+	if direction == "" {
+		direction = "both"
+	}
+	if maxNodes == 0 {
+		maxNodes = 500
+	}
+
+	// Match as matchNodes does: the target, the nodes under it, or failing
+	// those the nodes of the package it names.
+	var start []int32
+	if id, ok := c.ID(p.Target); ok && c.IsNode(id) {
+		start = append(start, id)
+	}
+	start = append(start, c.NodesWithPrefix(p.Target+":")...)
+	start = append(start, c.NodesWithPrefix(p.Target+"/")...)
+	if len(start) == 0 {
+		start = c.NodesInPackage(p.Target)
+	}
+	if len(start) == 0 {
+		return &SubgraphResult{
+			Nodes: map[string]*graph.Node{},
+			Edges: []graph.Edge{},
+		}, nil
+	}
+
+	limit := pageLimit(maxNodes, p.Offset, len(start))
+	deps, rdeps := direction == "deps" || direction == "both", direction == "rdeps" || direction == "both"
+	order := bfsOrder(c, start, p.Depth, deps, rdeps, p.Offset+limit+1)
+	result, err := paginateCompact(c, order, p.Offset, limit)
+	if err != nil {
+		return nil, err
+	}
+	if result.Truncated {
+		result.NextToken = encodePageToken(result.next, p.query())
+	}
+	return result, nil
+}
+
+// capCompact is capGraph over a memory-mapped snapshot.
+func capCompact(c *graph.Compact, maxNodes, offset int) (*SubgraphResult, error) {
+	var ids []int32
+	for id := int32(0); id < int32(c.Len()); id++ {
+		if c.IsNode(id) {
+			ids = append(ids, id)
+		}
+	}
+
+	if offset == 0 && len(ids) <= maxNodes {
+		nodes := make(map[string]*graph.Node, len(ids))
+		for _, id := range ids {
+			n, err := c.Node(id)
+			if err != nil {
+				return nil, err
+			}
+			nodes[n.Key] = n
+		}
+		edges := make([]graph.Edge, c.EdgeCount())
+		for i := range edges {
+			edges[i] = c.Edge(int32(i))
+		}
+		return &SubgraphResult{Nodes: nodes, Edges: edges}, nil
+	}
+
+	// IDs follow label order, so a stable sort by degree breaks ties by key.
+	degree := func(id int32) int { return len(c.OutEdges(id)) + len(c.InEdges(id)) }
+	deg := make([]int, c.Len())
+	for _, id := range ids {
+		deg[id] = degree(id)
+	}
+	sort.SliceStable(ids, func(i, j int) bool { return deg[ids[i]] > deg[ids[j]] })
+
+	// paginate reads keys only up to the end of the page; the rest of the
+	// order is needed only for its length.
+	end := min(offset+maxNodes, len(ids))
+	order := make([]string, len(ids))
+	for i, id := range ids[:end] {
+		order[i] = c.Key(id)
+	}
+	return paginateCompact(c, order, offset, maxNodes)
+}
+
+// paginateCompact is paginate over a memory-mapped snapshot. It finds the
+// edges between page nodes through their out-edges rather than scanning
+// every edge, then restores edge order.
+func paginateCompact(c *graph.Compact, order []string, offset, limit int) (*SubgraphResult, error) {
+	offset = min(offset, len(order))
+	end := min(offset+limit, len(order))
+
+	pos := make(map[int32]int, end)
+	ids := make([]int32, end)
+	for i, key := range order[:end] {
+		id, _ := c.ID(key)
+		pos[id] = i
+		ids[i] = id
+	}
+	nodes := make(map[string]*graph.Node, end-offset)
+	for _, id := range ids[offset:] {
+		n, err := c.Node(id)
+		if err != nil {
+			return nil, err
+		}
+		nodes[n.Key] = n
+	}
+
+	var idx []int32
+	for from, id := range ids {
+		for _, e := range c.OutEdges(id) {
+			if to, ok := pos[c.EdgeTo(e)]; ok && max(from, to) >= offset {
+				idx = append(idx, e)
+			}
+		}
+	}
+	slices.Sort(idx)
+	var edges []graph.Edge
+	for _, e := range idx {
+		edges = append(edges, c.Edge(e))
+	}
+
+	return &SubgraphResult{Nodes: nodes, Edges: edges, Truncated: end < len(order), next: end}, nil
+}
@@ -0,0 +1,73 @@
+package graphquery
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/toposcope/toposcope/pkg/graph"
+	"github.com/toposcope/toposcope/pkg/graph/graphtest"
+)
+
+func openCompact(t *testing.T, snap *graph.Snapshot) *graph.Compact {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "snap.idx")
+	if err := graph.WriteCompact(path, snap); err != nil {
+		t.Fatal(err)
+	}
+	c, err := graph.OpenCompact(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+func TestSubgraphCompactMatchesSubgraph(t *testing.T) {
+	snap := graphtest.Snapshot(2000)
+	// An endpoint that is not a node, which walks pass through.
+	snap.Edges = append(snap.Edges, graph.Edge{From: graphtest.Key(3), To: "//d0/p0:data.txt", Type: "DATA"})
+	c := openCompact(t, snap)
+
+	cases := []SubgraphParams{
+		{Roots: []string{graphtest.Key(100)}, Depth: 2, MaxNodes: 500},
+		{Roots: []string{"//d1/"}, Depth: 1, MaxNodes: 40},
+		{Roots: []string{graphtest.Key(100)}, Depth: 3, MaxNodes: 20, Offset: 20},
+		{MaxNodes: 50},
+		{MaxNodes: 50, Offset: 50},
+		{MaxNodes: 5000},
+	}
+	for _, p := range cases {
+		want := Subgraph(snap, p)
+		got, err := SubgraphCompact(c, p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("SubgraphCompact(%+v): got %d nodes, %d edges; want %d, %d", p, len(got.Nodes), len(got.Edges), len(want.Nodes), len(want.Edges))
+		}
+	}
+}
+
+func TestEgoCompactMatchesEgo(t *testing.T) {
+	snap := graphtest.Snapshot(2000)
+	c := openCompact(t, snap)
+
+	cases := []EgoParams{
+		{Target: graphtest.Key(100), Depth: 2, Direction: "both", MaxNodes: 500},
+		{Target: graphtest.Key(100), Depth: 3, Direction: "deps", MaxNodes: 10, Offset: 10},
+		{Target: graphtest.Key(5), Depth: 2, Direction: "rdeps", MaxNodes: 30},
+		{Target: "//d2/p2", Depth: 1, Direction: "both", MaxNodes: 500},
+		{Target: "//missing", Depth: 1, Direction: "both", MaxNodes: 500},
+	}
+	for _, p := range cases {
+		want := Ego(snap, p)
+		got, err := EgoCompact(c, p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("EgoCompact(%+v): got %d nodes, %d edges; want %d, %d", p, len(got.Nodes), len(got.Edges), len(want.Nodes), len(want.Edges))
+		}
+	}
+}
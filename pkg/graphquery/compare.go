@@ -0,0 +1,182 @@
+package graphquery
+
+import (
+	"sort"
+
+	"github.com/toposcope/toposcope/pkg/graph"
+)
+
+// DegreeChange is a target whose fan-in or fan-out moved between two
+// snapshots. Targets missing from one side count as zero there.
+type DegreeChange struct {
+	Key     string `json:"key"`
+	Package string `json:"package"`
+	Base    int    `json:"base"`
+	Head    int    `json:"head"`
+	Change  int    `json:"change"`
+}
+
+// BoundaryEdgeChange is the number of edges from one boundary (top-level
+// directory) to another in each snapshot.
+type BoundaryEdgeChange struct {
+	From   string `json:"from"`
+	To     string `json:"to"`
+	Base   int    `json:"base"`
+	Head   int    `json:"head"`
+	Change int    `json:"change"`
+}
+
+// ComparisonReport is a structural comparison of two snapshots that need not
+// be adjacent commits.
+type ComparisonReport struct {
+	BaseSnapshotID  string               `json:"base_snapshot_id"`
+	BaseCommitSHA   string               `json:"base_commit_sha"`
+	HeadSnapshotID  string               `json:"head_snapshot_id"`
+	HeadCommitSHA   string               `json:"head_commit_sha"`
+	BaseStats       graph.SnapshotStats  `json:"base_stats"`
+	HeadStats       graph.SnapshotStats  `json:"head_stats"`
+	FanIn           []DegreeChange       `json:"fan_in"`
+	FanOut          []DegreeChange       `json:"fan_out"`
+	AddedPackages   []string             `json:"added_packages"`
+	RemovedPackages []string             `json:"removed_packages"`
+	Boundaries      []BoundaryEdgeChange `json:"boundaries"`
+}
+
+// Compare reports the top n fan-in and fan-out changes by magnitude, the
+// packages present in only one snapshot, and every cross-boundary edge count
+// that changed, largest change first.
+func Compare(base, head *graph.Snapshot, n int) *ComparisonReport {
+	if n <= 0 {
+		n = 10
+	}
+
+	report := &ComparisonReport{
+		BaseSnapshotID: base.ID,
+		BaseCommitSHA:  base.CommitSHA,
+		HeadSnapshotID: head.ID,
+		HeadCommitSHA:  head.CommitSHA,
+		BaseStats:      base.Stats,
+		HeadStats:      head.Stats,
+	}
+
+	packageOf := func(key string) string {
+		if node := head.Nodes[key]; node != nil && node.Package != "" {
+			return node.Package
+		}
+		if node := base.Nodes[key]; node != nil && node.Package != "" {
+			return node.Package
+		}
+		return graph.LabelPackage(key)
+	}
+	report.FanIn = degreeChanges(base.ComputeInDegrees(), head.ComputeInDegrees(), packageOf, n)
+	report.FanOut = degreeChanges(base.ComputeOutDegrees(), head.ComputeOutDegrees(), packageOf, n)
+
+	basePkgs, headPkgs := packageSet(base), packageSet(head)
+	report.AddedPackages = setDifference(headPkgs, basePkgs)
+	report.RemovedPackages = setDifference(basePkgs, headPkgs)
+
+	baseCounts, headCounts := boundaryEdgeCounts(base), boundaryEdgeCounts(head)
+	pairs := make(map[[2]string]bool)
+	for p := range baseCounts {
+		pairs[p] = true
+	}
+	for p := range headCounts {
+		pairs[p] = true
+	}
+	report.Boundaries = []BoundaryEdgeChange{}
+	for p := range pairs {
+		b, h := baseCounts[p], headCounts[p]
+		if b != h {
+			report.Boundaries = append(report.Boundaries, BoundaryEdgeChange{From: p[0], To: p[1], Base: b, Head: h, Change: h - b})
+		}
+	}
+	sort.Slice(report.Boundaries, func(i, j int) bool {
+		a, b := report.Boundaries[i], report.Boundaries[j]
+		if abs(a.Change) != abs(b.Change) {
+			return abs(a.Change) > abs(b.Change)
+		}
+		if a.From != b.From {
+			return a.From < b.From
+		}
+		return a.To < b.To
+	})
+
+	return report
+}
+
+// degreeChanges returns the n largest non-zero differences between two
+// degree maps.
+func degreeChanges(base, head map[string]int, packageOf func(string) string, n int) []DegreeChange {
+	keys := make(map[string]bool, len(head))
+	for k := range base {
+		keys[k] = true
+	}
+	for k := range head {
+		keys[k] = true
+	}
+
+	out := []DegreeChange{}
+	for k := range keys {
+		if b, h := base[k], head[k]; b != h {
+			out = append(out, DegreeChange{Key: k, Package: packageOf(k), Base: b, Head: h, Change: h - b})
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if abs(out[i].Change) != abs(out[j].Change) {
+			return abs(out[i].Change) > abs(out[j].Change)
+		}
+		return out[i].Key < out[j].Key
+	})
+	if len(out) > n {
+		out = out[:n]
+	}
+	return out
+}
+
+func packageSet(snap *graph.Snapshot) map[string]bool {
+	pkgs := make(map[string]bool)
+	for _, node := range snap.Nodes {
+		if node.Package != "" {
+			pkgs[node.Package] = true
+		}
+	}
+	return pkgs
+}
+
+// setDifference returns the sorted members of a that are not in b.
+func setDifference(a, b map[string]bool) []string {
+	out := []string{}
+	for k := range a {
+		if !b[k] {
+			out = append(out, k)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// boundaryEdgeCounts counts edges whose endpoints sit in different
+// boundaries, keyed by (from, to) boundary.
+func boundaryEdgeCounts(snap *graph.Snapshot) map[[2]string]int {
+	pkg := func(key string) string {
+		if node := snap.Nodes[key]; node != nil && node.Package != "" {
+			return node.Package
+		}
+		return graph.LabelPackage(key)
+	}
+	counts := make(map[[2]string]int)
+	for _, e := range snap.Edges {
+		from, to := graph.Boundary(pkg(e.From)), graph.Boundary(pkg(e.To))
+		if from != to {
+			counts[[2]string{from, to}]++
+		}
+	}
+	return counts
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
@@ -0,0 +1,56 @@
+package graphquery
+
+import (
+	"testing"
+
+	"github.com/toposcope/toposcope/pkg/graph"
+)
+
+func TestCompare(t *testing.T) {
+	base := testSnapshot()
+	head := testSnapshot()
+
+	// Drop //c, add //g depending on //b:lib, and add a second a -> b edge.
+	delete(head.Nodes, "//c:lib")
+	head.Nodes["//g:lib"] = &graph.Node{Key: "//g:lib", Package: "//g"}
+	var edges []graph.Edge
+	for _, e := range head.Edges {
+		if e.From != "//c:lib" && e.To != "//c:lib" {
+			edges = append(edges, e)
+		}
+	}
+	head.Edges = append(edges,
+		graph.Edge{From: "//g:lib", To: "//b:lib", Type: "COMPILE"},
+		graph.Edge{From: "//a:lib", To: "//b:lib", Type: "RUNTIME"},
+	)
+
+	report := Compare(base, head, 10)
+
+	if len(report.AddedPackages) != 1 || report.AddedPackages[0] != "//g" {
+		t.Errorf("added packages = %v, want [//g]", report.AddedPackages)
+	}
+	if len(report.RemovedPackages) != 1 || report.RemovedPackages[0] != "//c" {
+		t.Errorf("removed packages = %v, want [//c]", report.RemovedPackages)
+	}
+
+	if len(report.FanIn) == 0 || report.FanIn[0].Key != "//b:lib" || report.FanIn[0].Change != 2 {
+		t.Fatalf("top fan-in change = %+v, want //b:lib +2", report.FanIn)
+	}
+
+	var bc *BoundaryEdgeChange
+	for i := range report.Boundaries {
+		if report.Boundaries[i].From == "a" && report.Boundaries[i].To == "b" {
+			bc = &report.Boundaries[i]
+		}
+	}
+	if bc == nil || bc.Base != 1 || bc.Head != 2 {
+		t.Errorf("a -> b boundary change = %+v, want 1 -> 2", bc)
+	}
+
+	t.Run("identical", func(t *testing.T) {
+		report := Compare(base, base, 10)
+		if len(report.FanIn)+len(report.FanOut)+len(report.Boundaries)+len(report.AddedPackages)+len(report.RemovedPackages) != 0 {
+			t.Errorf("expected no changes, got %+v", report)
+		}
+	})
+}
@@ -0,0 +1,221 @@
+package graphquery
+
+import (
+	"math"
+	"slices"
+	"sort"
+
+	"github.com/toposcope/toposcope/pkg/graph"
+)
+
+// ConformanceRules are the declared architecture a conformance report checks
+// a snapshot against.
+type ConformanceRules struct {
+	// Boundaries are the top-level directories to check. Edges with an end
+	// outside them are skipped. Empty checks every top-level directory.
+	Boundaries []string
+	// Layers orders boundaries from top to bottom. A boundary may depend on
+	// its own layer and the layers below it. Boundaries in no layer are
+	// unconstrained.
+	Layers [][]string
+	// IncludeTests checks edges from test targets too.
+	IncludeTests bool
+}
+
+// Conformance violation kinds.
+const (
+	ViolationLayer = "layer" // a boundary depends on a higher layer
+	ViolationCycle = "cycle" // two boundaries depend on each other
+)
+
+// ConformanceViolation is a cross-boundary edge that breaks a rule.
+type ConformanceViolation struct {
+	From         string `json:"from"`
+	To           string `json:"to"`
+	FromBoundary string `json:"from_boundary"`
+	ToBoundary   string `json:"to_boundary"`
+	Kind         string `json:"kind"`
+}
+
+// PackageConformance is a package with edges that break the rules.
+type PackageConformance struct {
+	Package    string `json:"package"`
+	Boundary   string `json:"boundary"`
+	Violations int    `json:"violations"`
+}
+
+// MisplacedPackage is a package whose detected community has more packages
+// from another boundary than from its own, which suggests it belongs there.
+type MisplacedPackage struct {
+	Package           string `json:"package"`
+	Boundary          string `json:"boundary"`
+	Community         int    `json:"community"`
+	CommunityBoundary string `json:"community_boundary"`
+}
+
+// ConformanceBaseline is the conformance of an earlier snapshot.
+type ConformanceBaseline struct {
+	SnapshotID string  `json:"snapshot_id"`
+	CommitSHA  string  `json:"commit_sha"`
+	Percent    float64 `json:"percent"`
+	Violations int     `json:"violations"`
+	Change     float64 `json:"change"` // percentage points since the baseline
+}
+
+// ConformanceReport measures how well a snapshot follows its declared
+// boundaries and layers.
+type ConformanceReport struct {
+	SnapshotID string `json:"snapshot_id"`
+	CommitSHA  string `json:"commit_sha"`
+	// Edges counts the cross-package edges checked, and Conforming those
+	// that break no rule. Percent is 100 when there are none.
+	Edges          int                    `json:"edges"`
+	Conforming     int                    `json:"conforming"`
+	Percent        float64                `json:"percent"`
+	ViolationCount int                    `json:"violation_count"`
+	ByKind         map[string]int         `json:"by_kind"`
+	Violations     []ConformanceViolation `json:"violations"`
+	TopPackages    []PackageConformance   `json:"top_packages"`
+	Misplaced      []MisplacedPackage     `json:"misplaced"`
+	Modularity     float64                `json:"modularity"`
+	Baseline       *ConformanceBaseline   `json:"baseline,omitempty"`
+}
+
+// Conformance checks every cross-package edge of snap against rules. Edges
+// from a lower layer to a higher one break the layering. Where two
+// boundaries not ordered by layers depend on each other, the edges of the
+// lighter direction break the cycle rule, or both directions when they weigh
+// the same. The report
+// lists the top n packages by violations, and the packages whose Louvain
+// community is led by another boundary. Violations lists at most 100 edges.
+func Conformance(snap *graph.Snapshot, rules ConformanceRules, n int) *ConformanceReport {
+	if n <= 0 {
+		n = 10
+	}
+	checked := func(b string) bool {
+		return len(rules.Boundaries) == 0 || slices.Contains(rules.Boundaries, b)
+	}
+	layer := make(map[string]int)
+	for i, bs := range rules.Layers {
+		for _, b := range bs {
+			layer[b] = i
+		}
+	}
+
+	type boundaryPair struct{ from, to string }
+	type edge struct {
+		from, to string
+		pair     boundaryPair
+	}
+	var edges []edge
+	weight := make(map[boundaryPair]int)
+	for _, e := range snap.Edges {
+		from, to := snap.Nodes[e.From], snap.Nodes[e.To]
+		if from == nil || to == nil || from.IsExternal || to.IsExternal || (from.IsTest && !rules.IncludeTests) {
+			continue
+		}
+		if from.Package == "" || to.Package == "" || from.Package == to.Package {
+			continue
+		}
+		fb, tb := graph.Boundary(from.Package), graph.Boundary(to.Package)
+		if !checked(fb) || !checked(tb) {
+			continue
+		}
+		p := boundaryPair{fb, tb}
+		edges = append(edges, edge{e.From, e.To, p})
+		if fb != tb {
+			weight[p]++
+		}
+	}
+
+	report := &ConformanceReport{
+		SnapshotID:  snap.ID,
+		CommitSHA:   snap.CommitSHA,
+		Edges:       len(edges),
+		ByKind:      map[string]int{ViolationLayer: 0, ViolationCycle: 0},
+		Violations:  []ConformanceViolation{},
+		TopPackages: []PackageConformance{},
+		Misplaced:   []MisplacedPackage{},
+	}
+
+	perPackage := make(map[string]int)
+	for _, e := range edges {
+		p := e.pair
+		if p.from == p.to {
+			report.Conforming++
+			continue
+		}
+		var kind string
+		fl, ok1 := layer[p.from]
+		tl, ok2 := layer[p.to]
+		switch back := weight[boundaryPair{p.to, p.from}]; {
+		case ok1 && ok2 && fl != tl:
+			// Layers decide which direction of a cycle is wrong.
+			if fl > tl {
+				kind = ViolationLayer
+			}
+		case back > 0 && weight[p] <= back:
+			kind = ViolationCycle
+		}
+		if kind == "" {
+			report.Conforming++
+			continue
+		}
+		report.ByKind[kind]++
+		report.ViolationCount++
+		perPackage[graph.LabelPackage(e.from)]++
+		if len(report.Violations) < 100 {
+			report.Violations = append(report.Violations, ConformanceViolation{
+				From: e.from, To: e.to, FromBoundary: p.from, ToBoundary: p.to, Kind: kind,
+			})
+		}
+	}
+	report.Percent = conformancePercent(report.Conforming, report.Edges)
+
+	for pkg, v := range perPackage {
+		report.TopPackages = append(report.TopPackages, PackageConformance{Package: pkg, Boundary: graph.Boundary(pkg), Violations: v})
+	}
+	sort.Slice(report.TopPackages, func(i, j int) bool {
+		a, b := report.TopPackages[i], report.TopPackages[j]
+		if a.Violations != b.Violations {
+			return a.Violations > b.Violations
+		}
+		return a.Package < b.Package
+	})
+	report.TopPackages = report.TopPackages[:min(n, len(report.TopPackages))]
+
+	communities := DetectCommunities(AggregatePackages(snap, !rules.IncludeTests, true, 1, math.MaxInt))
+	report.Modularity = communities.Modularity
+	for _, c := range communities.Communities {
+		if len(c.Packages) < 2 || !checked(c.Boundary) {
+			continue
+		}
+		for _, pkg := range c.Packages {
+			if b := graph.Boundary(pkg); b != c.Boundary && checked(b) {
+				report.Misplaced = append(report.Misplaced, MisplacedPackage{Package: pkg, Boundary: b, Community: c.ID, CommunityBoundary: c.Boundary})
+			}
+		}
+	}
+	sort.Slice(report.Misplaced, func(i, j int) bool { return report.Misplaced[i].Package < report.Misplaced[j].Package })
+
+	return report
+}
+
+// SetBaseline records base as the report's baseline, so the report shows the
+// trend since then.
+func (r *ConformanceReport) SetBaseline(base *ConformanceReport) {
+	r.Baseline = &ConformanceBaseline{
+		SnapshotID: base.SnapshotID,
+		CommitSHA:  base.CommitSHA,
+		Percent:    base.Percent,
+		Violations: base.ViolationCount,
+		Change:     math.Round((r.Percent-base.Percent)*100) / 100,
+	}
+}
+
+func conformancePercent(conforming, total int) float64 {
+	if total == 0 {
+		return 100
+	}
+	return math.Round(float64(conforming)*10000/float64(total)) / 100
+}
@@ -0,0 +1,89 @@
+package graphquery
+
+import (
+	"testing"
+
+	"github.com/toposcope/toposcope/pkg/graph"
+)
+
+func conformanceSnapshot(edges ...[2]string) *graph.Snapshot {
+	snap := &graph.Snapshot{ID: "s", CommitSHA: "abc1234def", Nodes: map[string]*graph.Node{}}
+	for _, e := range edges {
+		for _, key := range e {
+			if snap.Nodes[key] == nil {
+				snap.Nodes[key] = &graph.Node{Key: key, Package: graph.LabelPackage(key)}
+			}
+		}
+		snap.Edges = append(snap.Edges, graph.Edge{From: e[0], To: e[1], Type: "COMPILE"})
+	}
+	return snap
+}
+
+func TestConformance(t *testing.T) {
+	snap := conformanceSnapshot(
+		[2]string{"//app/a:lib", "//lib/x:lib"},      // downward: conforms
+		[2]string{"//app/a:lib", "//app/b:lib"},      // same boundary: conforms
+		[2]string{"//app/a:lib", "//app/a:util"},     // same package: not checked
+		[2]string{"//lib/x:lib", "//app/b:lib"},      // upward: layer violation
+		[2]string{"//lib/y:lib", "//platform/p:lib"}, // lighter direction of a cycle
+		[2]string{"//platform/p:lib", "//lib/x:lib"},
+		[2]string{"//platform/p:lib", "//lib/y:lib"},
+		[2]string{"//tools/t:lib", "//app/a:lib"}, // undeclared boundary: not checked
+	)
+	rules := ConformanceRules{
+		Boundaries: []string{"app", "lib", "platform"},
+		Layers:     [][]string{{"app"}, {"lib", "platform"}},
+	}
+
+	report := Conformance(snap, rules, 10)
+	if report.Edges != 6 || report.Conforming != 4 || report.ViolationCount != 2 {
+		t.Fatalf("expected 4 of 6 edges to conform, got %d of %d (%d violations)", report.Conforming, report.Edges, report.ViolationCount)
+	}
+	if report.Percent != 66.67 {
+		t.Errorf("expected 66.67%%, got %v", report.Percent)
+	}
+	if report.ByKind[ViolationLayer] != 1 || report.ByKind[ViolationCycle] != 1 {
+		t.Errorf("unexpected kinds: %v", report.ByKind)
+	}
+	if len(report.TopPackages) != 2 || report.TopPackages[0].Package != "//lib/x" || report.TopPackages[1].Package != "//lib/y" {
+		t.Errorf("unexpected top packages: %+v", report.TopPackages)
+	}
+
+	// Without rules every top-level directory is checked. Nothing orders
+	// app and lib, so both directions between them break the cycle rule.
+	open := Conformance(snap, ConformanceRules{}, 10)
+	if open.Edges != 7 || open.ByKind[ViolationLayer] != 0 || open.ByKind[ViolationCycle] != 3 {
+		t.Errorf("unexpected report without rules: %+v", open)
+	}
+}
+
+func TestConformanceBaseline(t *testing.T) {
+	base := Conformance(conformanceSnapshot(
+		[2]string{"//lib/x:lib", "//app/b:lib"},
+		[2]string{"//app/a:lib", "//lib/x:lib"},
+	), ConformanceRules{Layers: [][]string{{"app"}, {"lib"}}}, 10)
+	head := Conformance(conformanceSnapshot(
+		[2]string{"//app/a:lib", "//lib/x:lib"},
+	), ConformanceRules{Layers: [][]string{{"app"}, {"lib"}}}, 10)
+
+	head.SetBaseline(base)
+	if b := head.Baseline; b.Percent != 50 || b.Violations != 1 || b.Change != 50 {
+		t.Errorf("unexpected baseline: %+v", b)
+	}
+}
+
+func TestConformanceMisplaced(t *testing.T) {
+	// //lib/glue is tightly bound to app's packages and apart from lib's.
+	var edges [][2]string
+	for _, pair := range [][2]string{
+		{"//app/a:lib", "//app/b:lib"}, {"//app/b:lib", "//app/c:lib"}, {"//app/a:lib", "//app/c:lib"},
+		{"//app/a:lib", "//lib/glue:lib"}, {"//app/b:lib", "//lib/glue:lib"}, {"//app/c:lib", "//lib/glue:lib"},
+		{"//lib/x:lib", "//lib/y:lib"}, {"//lib/y:lib", "//lib/z:lib"}, {"//lib/x:lib", "//lib/z:lib"},
+	} {
+		edges = append(edges, pair, pair)
+	}
+	report := Conformance(conformanceSnapshot(edges...), ConformanceRules{}, 10)
+	if len(report.Misplaced) != 1 || report.Misplaced[0].Package != "//lib/glue" || report.Misplaced[0].CommunityBoundary != "app" {
+		t.Errorf("expected //lib/glue to cluster with app, got %+v", report.Misplaced)
+	}
+}
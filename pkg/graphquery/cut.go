@@ -0,0 +1,143 @@
+package graphquery
+
+import (
+	"sort"
+
+	"github.com/toposcope/toposcope/pkg/graph"
+)
+
+// ExplainResult answers "why does from depend on to": the shortest paths
+// between them, and a minimum set of edges whose removal would leave no
+// path at all.
+type ExplainResult struct {
+	*PathResult
+	Cut []graph.Edge `json:"cut"`
+	// CutTooLarge is set when no cut of at most max_cut edges exists. Cut
+	// is empty then, since breaking the dependency means restructuring
+	// rather than removing a few edges.
+	CutTooLarge bool `json:"cut_too_large,omitempty"`
+}
+
+// Explain finds the shortest paths from fromQ to toQ, as FindPaths does,
+// and a minimum edge cut between them. maxCut bounds the cut searched for
+// (0 means 50).
+func Explain(snap *graph.Snapshot, fromQ, toQ string, maxPaths, maxCut int) *ExplainResult {
+	result := &ExplainResult{PathResult: FindPaths(snap, fromQ, toQ, maxPaths), Cut: []graph.Edge{}}
+	if len(result.Paths) == 0 {
+		return result
+	}
+	cut, ok := MinCut(snap, fromQ, toQ, maxCut)
+	result.CutTooLarge = !ok
+	if ok {
+		result.Cut = cut
+	}
+	return result
+}
+
+// MinCut returns a minimum set of edges whose removal leaves no path from
+// the nodes matching fromQ to the nodes matching toQ, sorted by from, to,
+// and type. Parallel edges count separately. ok is false when the minimum
+// cut has more than maxCut edges (0 means 50), or is impossible because a
+// node matches both queries.
+func MinCut(snap *graph.Snapshot, fromQ, toQ string, maxCut int) (cut []graph.Edge, ok bool) {
+	if maxCut <= 0 {
+		maxCut = 50
+	}
+
+	ix := graph.NewIndex(snap)
+	n := ix.Len()
+	source := make([]bool, n)
+	for _, id := range matchNodes(snap, ix, fromQ) {
+		source[id] = true
+	}
+	sink := make([]bool, n)
+	for _, id := range matchNodes(snap, ix, toQ) {
+		if source[id] {
+			return nil, false
+		}
+		sink[id] = true
+	}
+
+	// Every edge has capacity 1, so the max flow equals the size of the
+	// minimum cut (Menger's theorem). Augmenting paths are found by BFS
+	// over the residual graph: unused edges forward, used edges backward.
+	out := make([][]int, n)
+	in := make([][]int, n)
+	for e := range ix.EdgeFrom {
+		out[ix.EdgeFrom[e]] = append(out[ix.EdgeFrom[e]], e)
+		in[ix.EdgeTo[e]] = append(in[ix.EdgeTo[e]], e)
+	}
+	used := make([]bool, len(ix.EdgeFrom))
+
+	// residual walks the residual graph from the sources and returns, for
+	// each node reached, the edge it was reached by (-1 for sources and
+	// -2 for unreached), stopping at the first sink it finds.
+	residual := func() ([]int, int32) {
+		via := make([]int, n)
+		var queue []int32
+		for id := range via {
+			via[id] = -2
+			if source[id] {
+				via[id] = -1
+				queue = append(queue, int32(id))
+			}
+		}
+		for len(queue) > 0 {
+			u := queue[0]
+			queue = queue[1:]
+			if sink[u] {
+				return via, u
+			}
+			for _, e := range out[u] {
+				if v := ix.EdgeTo[e]; !used[e] && via[v] == -2 {
+					via[v] = e
+					queue = append(queue, v)
+				}
+			}
+			for _, e := range in[u] {
+				if v := ix.EdgeFrom[e]; used[e] && via[v] == -2 {
+					via[v] = e
+					queue = append(queue, v)
+				}
+			}
+		}
+		return via, -1
+	}
+
+	for flow := 0; ; flow++ {
+		via, end := residual()
+		if end < 0 {
+			// The nodes still reachable are one side of a minimum cut;
+			// the used edges leaving them are the cut.
+			for e, u := range ix.EdgeFrom {
+				if via[u] != -2 && via[ix.EdgeTo[e]] == -2 {
+					cut = append(cut, snap.Edges[e])
+				}
+			}
+			sort.Slice(cut, func(i, j int) bool {
+				a, b := cut[i], cut[j]
+				if a.From != b.From {
+					return a.From < b.From
+				}
+				if a.To != b.To {
+					return a.To < b.To
+				}
+				return a.Type < b.Type
+			})
+			return cut, true
+		}
+		if flow == maxCut {
+			return nil, false
+		}
+		for v := end; via[v] >= 0; {
+			e := via[v]
+			if ix.EdgeTo[e] == v {
+				used[e] = true
+				v = ix.EdgeFrom[e]
+			} else {
+				used[e] = false
+				v = ix.EdgeTo[e]
+			}
+		}
+	}
+}
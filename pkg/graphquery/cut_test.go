@@ -0,0 +1,74 @@
+package graphquery
+
+import (
+	"testing"
+
+	"github.com/toposcope/toposcope/pkg/graph"
+)
+
+func cutSnapshot(edges ...[2]string) *graph.Snapshot {
+	snap := &graph.Snapshot{Nodes: map[string]*graph.Node{}}
+	for _, e := range edges {
+		for _, key := range e {
+			snap.Nodes[key] = &graph.Node{Key: key, Package: key[:3]}
+		}
+		snap.Edges = append(snap.Edges, graph.Edge{From: e[0], To: e[1], Type: "COMPILE"})
+	}
+	return snap
+}
+
+func cutKeys(cut []graph.Edge) []string {
+	var keys []string
+	for _, e := range cut {
+		keys = append(keys, e.From+"->"+e.To)
+	}
+	return keys
+}
+
+func TestMinCut(t *testing.T) {
+	// Two routes from //a meet at //x before reaching //d.
+	bottleneck := cutSnapshot(
+		[2]string{"//a:a", "//b:b"}, [2]string{"//a:a", "//c:c"},
+		[2]string{"//b:b", "//x:x"}, [2]string{"//c:c", "//x:x"},
+		[2]string{"//x:x", "//d:d"},
+	)
+	cut, ok := MinCut(bottleneck, "//a:a", "//d:d", 0)
+	if got := cutKeys(cut); !ok || len(got) != 1 || got[0] != "//x:x->//d:d" {
+		t.Errorf("bottleneck cut = %v (ok %v), want [//x:x->//d:d]", got, ok)
+	}
+
+	// Two disjoint routes need two cuts, one on each.
+	disjoint := cutSnapshot(
+		[2]string{"//a:a", "//b:b"}, [2]string{"//b:b", "//d:d"},
+		[2]string{"//a:a", "//c:c"}, [2]string{"//c:c", "//d:d"},
+		[2]string{"//b:b", "//c:c"},
+	)
+	cut, ok = MinCut(disjoint, "//a:a", "//d:d", 0)
+	if got := cutKeys(cut); !ok || len(got) != 2 {
+		t.Errorf("disjoint cut = %v (ok %v), want two edges", got, ok)
+	}
+	if _, ok := MinCut(disjoint, "//a:a", "//d:d", 1); ok {
+		t.Error("expected a two-edge cut to exceed max_cut 1")
+	}
+
+	if _, ok := MinCut(disjoint, "//a", "//a:a", 0); ok {
+		t.Error("expected no cut when a node matches both queries")
+	}
+}
+
+func TestExplain(t *testing.T) {
+	snap := testSnapshot()
+
+	result := Explain(snap, "//f:lib", "//d:lib", 0, 0)
+	if len(result.Paths) != 1 || result.PathLength != 4 {
+		t.Errorf("paths = %v, want one of length 4", result.Paths)
+	}
+	if len(result.Cut) != 1 || result.CutTooLarge {
+		t.Errorf("cut = %v (too large %v), want one edge", cutKeys(result.Cut), result.CutTooLarge)
+	}
+
+	result = Explain(snap, "//d:lib", "//a:lib", 0, 0)
+	if len(result.Paths) != 0 || len(result.Cut) != 0 || result.CutTooLarge {
+		t.Errorf("expected no paths and no cut, got %+v", result)
+	}
+}
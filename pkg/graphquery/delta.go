@@ -0,0 +1,167 @@
+package graphquery
+
+import (
+	"sort"
+
+	"github.com/toposcope/toposcope/pkg/graph"
+)
+
+// Change statuses for nodes and edges in a DeltaGraphResult.
+const (
+	StatusAdded     = "added"
+	StatusRemoved   = "removed"
+	StatusUnchanged = "unchanged"
+)
+
+// DeltaNode is a node annotated with how the change affected it.
+type DeltaNode struct {
+	graph.Node
+	Status string `json:"status"`
+}
+
+// DeltaEdge is an edge annotated with how the change affected it.
+type DeltaEdge struct {
+	graph.Edge
+	Status string `json:"status"`
+}
+
+// DeltaGraphResult is a merged before/after view of a change.
+type DeltaGraphResult struct {
+	Nodes     map[string]*DeltaNode `json:"nodes"`
+	Edges     []DeltaEdge           `json:"edges"`
+	Truncated bool                  `json:"truncated,omitempty"`
+}
+
+// DeltaGraph merges a delta with its head snapshot into one graph: the added
+// and removed nodes and edges, the unchanged endpoints of changed edges, and
+// unchanged neighbors up to depth hops away in head. Removed items come from
+// the delta itself, so the base snapshot is not needed.
+//
+// When the result exceeds maxNodes (0 means 500), changed nodes are kept
+// first, then endpoints of changed edges, then context nodes by degree.
+func DeltaGraph(delta *graph.Delta, head *graph.Snapshot, depth, maxNodes int, hideTests, hideExternal bool) *DeltaGraphResult {
+	if maxNodes <= 0 {
+		maxNodes = 500
+	}
+
+	// tier orders nodes for capping: 0 changed, 1 endpoint, 2 context.
+	nodes := make(map[string]*DeltaNode)
+	tier := make(map[string]int)
+	add := func(n graph.Node, status string, t int) {
+		if _, ok := nodes[n.Key]; ok {
+			return
+		}
+		nodes[n.Key] = &DeltaNode{Node: n, Status: status}
+		tier[n.Key] = t
+	}
+	lookup := func(key string) graph.Node {
+		if n := head.Nodes[key]; n != nil {
+			return *n
+		}
+		return graph.Node{Key: key}
+	}
+
+	for _, n := range delta.AddedNodes {
+		add(n, StatusAdded, 0)
+	}
+	for _, n := range delta.RemovedNodes {
+		add(n, StatusRemoved, 0)
+	}
+	for _, edges := range [][]graph.Edge{delta.AddedEdges, delta.RemovedEdges} {
+		for _, e := range edges {
+			add(lookup(e.From), StatusUnchanged, 1)
+			add(lookup(e.To), StatusUnchanged, 1)
+		}
+	}
+
+	// Expand context through head.
+	ix := graph.NewIndex(head)
+	degree := make([]int, ix.Len())
+	for i := range head.Edges {
+		degree[ix.EdgeFrom[i]]++
+		degree[ix.EdgeTo[i]]++
+	}
+	degreeOf := func(key string) int {
+		if id, ok := ix.ID(key); ok {
+			return degree[id]
+		}
+		return 0
+	}
+	var frontier []int32
+	for key := range nodes {
+		if id, ok := ix.ID(key); ok {
+			frontier = append(frontier, id)
+		}
+	}
+	for d := 0; d < depth && len(frontier) > 0; d++ {
+		var next []int32
+		for _, id := range frontier {
+			for _, neighbors := range [2][]int32{ix.Deps(id), ix.RDeps(id)} {
+				for _, nb := range neighbors {
+					key := ix.Key(nb)
+					if _, ok := nodes[key]; !ok && head.Nodes[key] != nil {
+						add(*head.Nodes[key], StatusUnchanged, 2)
+						next = append(next, nb)
+					}
+				}
+			}
+		}
+		frontier = next
+	}
+
+	for key, n := range nodes {
+		if (hideTests && n.IsTest) || (hideExternal && n.IsExternal) {
+			delete(nodes, key)
+		}
+	}
+
+	result := &DeltaGraphResult{Nodes: nodes}
+	if len(nodes) > maxNodes {
+		keys := make([]string, 0, len(nodes))
+		for key := range nodes {
+			keys = append(keys, key)
+		}
+		sort.Slice(keys, func(i, j int) bool {
+			a, b := keys[i], keys[j]
+			if tier[a] != tier[b] {
+				return tier[a] < tier[b]
+			}
+			if da, db := degreeOf(a), degreeOf(b); da != db {
+				return da > db
+			}
+			return a < b
+		})
+		for _, key := range keys[maxNodes:] {
+			delete(nodes, key)
+		}
+		result.Truncated = true
+	}
+
+	addedEdges := make(map[string]bool, len(delta.AddedEdges))
+	for _, e := range delta.AddedEdges {
+		addedEdges[e.EdgeKey()] = true
+	}
+	keep := func(e graph.Edge) bool {
+		return nodes[e.From] != nil && nodes[e.To] != nil
+	}
+	for _, e := range delta.AddedEdges {
+		if keep(e) {
+			result.Edges = append(result.Edges, DeltaEdge{Edge: e, Status: StatusAdded})
+		}
+	}
+	for _, e := range delta.RemovedEdges {
+		if keep(e) {
+			result.Edges = append(result.Edges, DeltaEdge{Edge: e, Status: StatusRemoved})
+		}
+	}
+	for _, e := range head.Edges {
+		if keep(e) && !addedEdges[e.EdgeKey()] {
+			result.Edges = append(result.Edges, DeltaEdge{Edge: e, Status: StatusUnchanged})
+		}
+	}
+	if result.Edges == nil {
+		result.Edges = []DeltaEdge{}
+	}
+
+	return result
+}
@@ -0,0 +1,85 @@
+package graphquery
+
+import (
+	"testing"
+
+	"github.com/toposcope/toposcope/pkg/graph"
+)
+
+func TestDeltaGraph(t *testing.T) {
+	head := testSnapshot()
+	head.Nodes["//g:lib"] = &graph.Node{Key: "//g:lib", Kind: "go_library", Package: "//g"}
+	head.Edges = append(head.Edges, graph.Edge{From: "//b:lib", To: "//g:lib", Type: "COMPILE"})
+
+	delta := &graph.Delta{
+		AddedNodes:   []graph.Node{*head.Nodes["//g:lib"]},
+		RemovedNodes: []graph.Node{{Key: "//old:lib", Kind: "go_library", Package: "//old"}},
+		AddedEdges:   []graph.Edge{{From: "//b:lib", To: "//g:lib", Type: "COMPILE"}},
+		RemovedEdges: []graph.Edge{{From: "//b:lib", To: "//old:lib", Type: "COMPILE"}},
+	}
+
+	t.Run("statuses", func(t *testing.T) {
+		result := DeltaGraph(delta, head, 0, 0, false, false)
+		want := map[string]string{
+			"//g:lib":   StatusAdded,
+			"//old:lib": StatusRemoved,
+			"//b:lib":   StatusUnchanged,
+		}
+		if len(result.Nodes) != len(want) {
+			t.Fatalf("expected %d nodes, got %d", len(want), len(result.Nodes))
+		}
+		for key, status := range want {
+			if n := result.Nodes[key]; n == nil || n.Status != status {
+				t.Errorf("node %s: want status %s, got %+v", key, status, n)
+			}
+		}
+
+		statuses := map[string]string{}
+		for _, e := range result.Edges {
+			statuses[e.EdgeKey()] = e.Status
+		}
+		if len(statuses) != 2 {
+			t.Errorf("expected 2 edges, got %d", len(statuses))
+		}
+		if s := statuses[delta.AddedEdges[0].EdgeKey()]; s != StatusAdded {
+			t.Errorf("added edge: want %s, got %q", StatusAdded, s)
+		}
+		if s := statuses[delta.RemovedEdges[0].EdgeKey()]; s != StatusRemoved {
+			t.Errorf("removed edge: want %s, got %q", StatusRemoved, s)
+		}
+	})
+
+	t.Run("context depth", func(t *testing.T) {
+		result := DeltaGraph(delta, head, 1, 0, false, false)
+		for _, key := range []string{"//a:lib", "//c:lib"} {
+			if n := result.Nodes[key]; n == nil || n.Status != StatusUnchanged {
+				t.Errorf("expected unchanged context node %s", key)
+			}
+		}
+		if _, ok := result.Nodes["//d:lib"]; ok {
+			t.Error("did not expect //d:lib at depth 1")
+		}
+	})
+
+	t.Run("cap keeps changed nodes", func(t *testing.T) {
+		result := DeltaGraph(delta, head, 3, 2, false, false)
+		if !result.Truncated {
+			t.Error("expected truncated result")
+		}
+		if len(result.Nodes) != 2 {
+			t.Fatalf("expected 2 nodes, got %d", len(result.Nodes))
+		}
+		for _, key := range []string{"//g:lib", "//old:lib"} {
+			if _, ok := result.Nodes[key]; !ok {
+				t.Errorf("expected changed node %s to survive the cap", key)
+			}
+		}
+	})
+
+	t.Run("hide tests", func(t *testing.T) {
+		result := DeltaGraph(delta, head, 2, 0, true, false)
+		if _, ok := result.Nodes["//a:test"]; ok {
+			t.Error("expected test node to be hidden")
+		}
+	})
+}
@@ -0,0 +1,265 @@
+package graphquery
+
+import (
+	"hash/fnv"
+	"math"
+	"sort"
+)
+
+// Point is a node position in a precomputed layout.
+type Point struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+}
+
+// Layout tuning. The force constants follow ForceAtlas2's defaults.
+const (
+	layoutIterations = 100
+	layoutGravity    = 1.0
+	layoutJitter     = 1.0
+	// Above this many nodes, repulsion is approximated with a Barnes-Hut
+	// quadtree instead of summed over every pair.
+	layoutExactLimit = 1000
+	layoutTheta      = 1.2
+)
+
+// LayoutPackages sets the position of every package in result with
+// ForceAtlas2, a force-directed layout. Connected packages pull together,
+// heavier edges pulling harder, and every package pushes the others away in
+// proportion to its degree. Start positions come from package names, so the
+// same graph always gets the same layout.
+func LayoutPackages(result *PackageGraphResult) {
+	keys := make([]string, 0, len(result.Nodes))
+	for key := range result.Nodes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	index := make(map[string]int, len(keys))
+	for i, key := range keys {
+		index[key] = i
+	}
+
+	var edges []layoutEdge
+	for _, e := range result.Edges {
+		from, ok1 := index[e.From]
+		to, ok2 := index[e.To]
+		if ok1 && ok2 && from != to {
+			edges = append(edges, layoutEdge{from, to, float64(e.Weight)})
+		}
+	}
+
+	pos := forceAtlas2(keys, edges, layoutIterations)
+	for i, key := range keys {
+		p := pos[i]
+		result.Nodes[key].Position = &Point{X: round2(p.X), Y: round2(p.Y)}
+	}
+}
+
+type layoutEdge struct {
+	from, to int
+	weight   float64
+}
+
+// forceAtlas2 lays out nodes named by keys and returns their positions in
+// the same order. It follows Jacomy et al., "ForceAtlas2, a Continuous Graph
+// Layout Algorithm", with linear attraction and adaptive global speed.
+func forceAtlas2(keys []string, edges []layoutEdge, iterations int) []Point {
+	n := len(keys)
+	pos := make([]Point, n)
+	if n == 0 {
+		return pos
+	}
+
+	mass := make([]float64, n)
+	for i := range mass {
+		mass[i] = 1
+	}
+	for _, e := range edges {
+		mass[e.from]++
+		mass[e.to]++
+	}
+
+	spread := 10 * math.Sqrt(float64(n))
+	for i, key := range keys {
+		h := fnv.New64a()
+		h.Write([]byte(key))
+		v := h.Sum64()
+		pos[i] = Point{
+			X: (float64(v&0xffffffff)/math.MaxUint32*2 - 1) * spread,
+			Y: (float64(v>>32)/math.MaxUint32*2 - 1) * spread,
+		}
+	}
+
+	scaling := 2.0
+	if n >= 100 {
+		scaling = 10
+	}
+
+	force := make([]Point, n)
+	prev := make([]Point, n)
+	swing := make([]float64, n)
+	speed, efficiency := 1.0, 1.0
+	for iter := 0; iter < iterations; iter++ {
+		copy(prev, force)
+		clear(force)
+
+		if n <= layoutExactLimit {
+			for i := 0; i < n; i++ {
+				for j := i + 1; j < n; j++ {
+					dx, dy := pos[i].X-pos[j].X, pos[i].Y-pos[j].Y
+					d2 := dx*dx + dy*dy
+					if d2 == 0 {
+						continue
+					}
+					f := scaling * mass[i] * mass[j] / d2
+					force[i].X += dx * f
+					force[i].Y += dy * f
+					force[j].X -= dx * f
+					force[j].Y -= dy * f
+				}
+			}
+		} else {
+			tree := newQuadTree(pos, mass)
+			for i := range pos {
+				tree.repel(i, pos, mass, scaling, &force[i])
+			}
+		}
+
+		for i := range pos {
+			d := math.Hypot(pos[i].X, pos[i].Y)
+			if d > 0 {
+				f := layoutGravity * mass[i] / d
+				force[i].X -= pos[i].X * f
+				force[i].Y -= pos[i].Y * f
+			}
+		}
+
+		for _, e := range edges {
+			dx, dy := pos[e.from].X-pos[e.to].X, pos[e.from].Y-pos[e.to].Y
+			force[e.from].X -= dx * e.weight
+			force[e.from].Y -= dy * e.weight
+			force[e.to].X += dx * e.weight
+			force[e.to].Y += dy * e.weight
+		}
+
+		// Slow down when nodes swing back and forth, speed up while they
+		// move steadily.
+		var totalSwing, totalTraction float64
+		for i := range pos {
+			swing[i] = math.Hypot(prev[i].X-force[i].X, prev[i].Y-force[i].Y)
+			totalSwing += mass[i] * swing[i]
+			totalTraction += mass[i] * math.Hypot(prev[i].X+force[i].X, prev[i].Y+force[i].Y) / 2
+		}
+		if totalSwing == 0 {
+			break
+		}
+		optimal := 0.05 * math.Sqrt(float64(n))
+		jitter := layoutJitter * max(math.Sqrt(optimal), min(10, optimal*totalTraction/float64(n*n)))
+		if totalSwing/totalTraction > 2 {
+			if efficiency > 0.05 {
+				efficiency *= 0.5
+			}
+			jitter = max(jitter, layoutJitter)
+		}
+		target := jitter * efficiency * totalTraction / totalSwing
+		if totalSwing > jitter*totalTraction {
+			if efficiency > 0.05 {
+				efficiency *= 0.7
+			}
+		} else if speed < 1000 {
+			efficiency *= 1.3
+		}
+		speed += min(target-speed, 0.5*speed)
+
+		for i := range pos {
+			f := speed / (1 + math.Sqrt(speed*swing[i]))
+			pos[i].X += force[i].X * f
+			pos[i].Y += force[i].Y * f
+		}
+	}
+	return pos
+}
+
+// quadTree is a Barnes-Hut tree over node positions. Each cell holds the
+// total mass and mass-weighted centre of the nodes inside it.
+type quadTree struct {
+	x0, y0, size float64
+	mass         float64
+	cx, cy       float64
+	node         int // the single node in a leaf, or -1
+	children     [4]*quadTree
+}
+
+func newQuadTree(pos []Point, mass []float64) *quadTree {
+	minX, minY := math.Inf(1), math.Inf(1)
+	maxX, maxY := math.Inf(-1), math.Inf(-1)
+	for _, p := range pos {
+		minX, maxX = min(minX, p.X), max(maxX, p.X)
+		minY, maxY = min(minY, p.Y), max(maxY, p.Y)
+	}
+	root := &quadTree{x0: minX, y0: minY, size: max(maxX-minX, maxY-minY, 1), node: -1}
+	for i := range pos {
+		root.insert(i, pos, mass, 0)
+	}
+	return root
+}
+
+func (t *quadTree) insert(i int, pos []Point, mass []float64, depth int) {
+	switch {
+	case t.mass == 0:
+		t.node = i
+	case depth < 32:
+		// Split a leaf by pushing its node down, then place i below.
+		if t.node >= 0 {
+			old := t.node
+			t.node = -1
+			t.child(old, pos).insert(old, pos, mass, depth+1)
+		}
+		t.child(i, pos).insert(i, pos, mass, depth+1)
+	}
+	t.cx = (t.cx*t.mass + pos[i].X*mass[i]) / (t.mass + mass[i])
+	t.cy = (t.cy*t.mass + pos[i].Y*mass[i]) / (t.mass + mass[i])
+	t.mass += mass[i]
+}
+
+func (t *quadTree) child(i int, pos []Point) *quadTree {
+	half := t.size / 2
+	q, x0, y0 := 0, t.x0, t.y0
+	if pos[i].X >= t.x0+half {
+		q, x0 = q+1, x0+half
+	}
+	if pos[i].Y >= t.y0+half {
+		q, y0 = q+2, y0+half
+	}
+	if t.children[q] == nil {
+		t.children[q] = &quadTree{x0: x0, y0: y0, size: half, node: -1}
+	}
+	return t.children[q]
+}
+
+// repel adds the repulsion on node i from every other node, treating cells
+// that are far away relative to their size as a single mass.
+func (t *quadTree) repel(i int, pos []Point, mass []float64, scaling float64, f *Point) {
+	if t == nil || t.mass == 0 || t.node == i {
+		return
+	}
+	dx, dy := pos[i].X-t.cx, pos[i].Y-t.cy
+	d2 := dx*dx + dy*dy
+	leaf := t.node >= 0 || t.children == [4]*quadTree{}
+	if leaf || t.size*t.size < layoutTheta*layoutTheta*d2 {
+		if d2 == 0 {
+			return
+		}
+		k := scaling * mass[i] * t.mass / d2
+		f.X += dx * k
+		f.Y += dy * k
+		return
+	}
+	for _, c := range t.children {
+		c.repel(i, pos, mass, scaling, f)
+	}
+}
+
+func round2(v float64) float64 {
+	return math.Round(v*100) / 100
+}
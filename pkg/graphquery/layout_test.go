@@ -0,0 +1,66 @@
+package graphquery
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"testing"
+)
+
+// clusteredPackages has two rings of packages joined by a single edge.
+func clusteredPackages(size int) *PackageGraphResult {
+	result := &PackageGraphResult{Nodes: map[string]*PackageNode{}}
+	for c := 0; c < 2; c++ {
+		for i := 0; i < size; i++ {
+			pkg := fmt.Sprintf("//c%d/p%d", c, i)
+			result.Nodes[pkg] = &PackageNode{Package: pkg}
+			result.Edges = append(result.Edges, PackageEdge{From: pkg, To: fmt.Sprintf("//c%d/p%d", c, (i+1)%size), Weight: 3})
+			result.Edges = append(result.Edges, PackageEdge{From: pkg, To: fmt.Sprintf("//c%d/p%d", c, (i+2)%size), Weight: 1})
+		}
+	}
+	result.Edges = append(result.Edges, PackageEdge{From: "//c0/p0", To: "//c1/p0", Weight: 1})
+	return result
+}
+
+func TestLayoutPackages(t *testing.T) {
+	result := clusteredPackages(10)
+	LayoutPackages(result)
+
+	centre := [2]Point{}
+	for key, n := range result.Nodes {
+		p := n.Position
+		if p == nil || math.IsNaN(p.X) || math.IsNaN(p.Y) || math.IsInf(p.X, 0) || math.IsInf(p.Y, 0) {
+			t.Fatalf("%s has no usable position: %v", key, p)
+		}
+		c := int(key[3] - '0')
+		centre[c].X += p.X / 10
+		centre[c].Y += p.Y / 10
+	}
+
+	// Packages sit nearer their own cluster's centre than the other's.
+	for key, n := range result.Nodes {
+		c := int(key[3] - '0')
+		own := math.Hypot(n.Position.X-centre[c].X, n.Position.Y-centre[c].Y)
+		other := math.Hypot(n.Position.X-centre[1-c].X, n.Position.Y-centre[1-c].Y)
+		if own >= other {
+			t.Errorf("%s is %.1f from its cluster and %.1f from the other", key, own, other)
+		}
+	}
+
+	again := clusteredPackages(10)
+	LayoutPackages(again)
+	if !reflect.DeepEqual(result, again) {
+		t.Error("expected the same layout for the same graph")
+	}
+}
+
+func TestLayoutPackagesLarge(t *testing.T) {
+	// Past layoutExactLimit, repulsion goes through the quadtree.
+	result := clusteredPackages(layoutExactLimit/2 + 50)
+	LayoutPackages(result)
+	for key, n := range result.Nodes {
+		if p := n.Position; p == nil || math.IsNaN(p.X) || math.IsNaN(p.Y) {
+			t.Fatalf("%s has no usable position: %v", key, p)
+		}
+	}
+}
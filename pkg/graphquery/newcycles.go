@@ -0,0 +1,223 @@
+package graphquery
+
+import (
+	"sort"
+
+	"github.com/toposcope/toposcope/pkg/graph"
+)
+
+// PackageCycle is a package-level cycle that a change closes.
+type PackageCycle struct {
+	// Packages are all the packages in the new cycle, sorted.
+	Packages []string `json:"packages"`
+	// Path is one way around the cycle. It starts and ends at the source
+	// package of the first closing edge.
+	Path []string `json:"path"`
+	// Edges are the added target edges that close the cycle.
+	Edges []graph.Edge `json:"edges"`
+}
+
+// NewPackageCycles returns the package cycles that added closes in head. An
+// added edge from package p to package q closes a cycle when q reaches p in
+// head's package graph, unless p and q were already on a cycle together in
+// base. Only the packages reachable from a closing edge's target that also
+// reach its source are examined, so the check stays fast for small changes.
+// Cycles are ordered largest first.
+func NewPackageCycles(base, head *graph.Snapshot, added []graph.Edge) []PackageCycle {
+	headAdj, headRev := packageAdjacency(head)
+
+	// Package cycles in base, so edges inside one are skipped.
+	baseAdj, _ := packageAdjacency(base)
+	var basePkgs []string
+	for pkg := range baseAdj {
+		basePkgs = append(basePkgs, pkg)
+	}
+	sort.Strings(basePkgs)
+	baseComp := make(map[string]int)
+	for i, comp := range stronglyConnected(basePkgs, baseAdj) {
+		for _, pkg := range comp {
+			baseComp[pkg] = i
+		}
+	}
+
+	type pkgPair struct{ from, to string }
+	closing := make(map[pkgPair][]graph.Edge)
+	for _, e := range added {
+		from, to := edgePackages(head, e)
+		if from == "" || to == "" || from == to {
+			continue
+		}
+		if c, ok := baseComp[from]; ok {
+			if d, ok := baseComp[to]; ok && c == d {
+				continue
+			}
+		}
+		p := pkgPair{from, to}
+		closing[p] = append(closing[p], e)
+	}
+	if len(closing) == 0 {
+		return nil
+	}
+
+	// Any new cycle runs through a closing edge, so it lies in the packages
+	// reachable from some closing target that reach some closing source.
+	var sources, targets []string
+	for p := range closing {
+		sources = append(sources, p.from)
+		targets = append(targets, p.to)
+	}
+	reach := reachablePackages(targets, headAdj)
+	back := reachablePackages(sources, headRev)
+	var region []string
+	regionAdj := make(map[string][]string)
+	for pkg := range reach {
+		if back[pkg] {
+			region = append(region, pkg)
+		}
+	}
+	sort.Strings(region)
+	for _, pkg := range region {
+		for _, to := range headAdj[pkg] {
+			if reach[to] && back[to] {
+				regionAdj[pkg] = append(regionAdj[pkg], to)
+			}
+		}
+	}
+
+	comp := make(map[string]int)
+	components := cyclicComponents(region, regionAdj)
+	for i, c := range components {
+		for _, pkg := range c {
+			comp[pkg] = i
+		}
+	}
+
+	pairs := make([]pkgPair, 0, len(closing))
+	for p := range closing {
+		pairs = append(pairs, p)
+	}
+	sort.Slice(pairs, func(i, j int) bool {
+		if pairs[i].from != pairs[j].from {
+			return pairs[i].from < pairs[j].from
+		}
+		return pairs[i].to < pairs[j].to
+	})
+
+	cycles := make(map[int]*PackageCycle)
+	for _, p := range pairs {
+		c, ok1 := comp[p.from]
+		d, ok2 := comp[p.to]
+		if !ok1 || !ok2 || c != d {
+			continue
+		}
+		cycle := cycles[c]
+		if cycle == nil {
+			members := make(map[string]bool, len(components[c]))
+			for _, pkg := range components[c] {
+				members[pkg] = true
+			}
+			cycle = &PackageCycle{
+				Packages: components[c],
+				Path:     append([]string{p.from}, packagePath(p.to, p.from, regionAdj, members)...),
+			}
+			cycles[c] = cycle
+		}
+		cycle.Edges = append(cycle.Edges, closing[p]...)
+	}
+
+	result := make([]PackageCycle, 0, len(cycles))
+	for i := range components {
+		if c := cycles[i]; c != nil {
+			result = append(result, *c)
+		}
+	}
+	return result
+}
+
+// packageAdjacency returns the deduplicated package graph of snap and its
+// reverse. Edges within a package and edges to or from external targets are
+// left out.
+func packageAdjacency(snap *graph.Snapshot) (adj, rev map[string][]string) {
+	adj, rev = make(map[string][]string), make(map[string][]string)
+	seen := make(map[[2]string]bool)
+	for _, e := range snap.Edges {
+		from, to := edgePackages(snap, e)
+		if from == "" || to == "" {
+			continue
+		}
+		if _, ok := adj[from]; !ok {
+			adj[from] = nil
+		}
+		if from == to || seen[[2]string{from, to}] {
+			continue
+		}
+		seen[[2]string{from, to}] = true
+		adj[from] = append(adj[from], to)
+		rev[to] = append(rev[to], from)
+	}
+	for _, tos := range adj {
+		sort.Strings(tos)
+	}
+	for _, froms := range rev {
+		sort.Strings(froms)
+	}
+	return adj, rev
+}
+
+// edgePackages returns the packages of an edge's ends, or empty strings when
+// either end is missing or external.
+func edgePackages(snap *graph.Snapshot, e graph.Edge) (string, string) {
+	from, to := snap.Nodes[e.From], snap.Nodes[e.To]
+	if from == nil || to == nil || from.IsExternal || to.IsExternal {
+		return "", ""
+	}
+	return from.Package, to.Package
+}
+
+// reachablePackages returns the packages reachable from start, including start.
+func reachablePackages(start []string, adj map[string][]string) map[string]bool {
+	seen := make(map[string]bool)
+	queue := append([]string(nil), start...)
+	for _, pkg := range start {
+		seen[pkg] = true
+	}
+	for len(queue) > 0 {
+		pkg := queue[0]
+		queue = queue[1:]
+		for _, next := range adj[pkg] {
+			if !seen[next] {
+				seen[next] = true
+				queue = append(queue, next)
+			}
+		}
+	}
+	return seen
+}
+
+// packagePath returns a shortest path from one package to another through
+// members only, including both ends.
+func packagePath(from, to string, adj map[string][]string, members map[string]bool) []string {
+	parent := map[string]string{from: ""}
+	queue := []string{from}
+	for len(queue) > 0 {
+		pkg := queue[0]
+		queue = queue[1:]
+		if pkg == to {
+			var path []string
+			for p := to; p != ""; p = parent[p] {
+				path = append(path, p)
+			}
+			for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+				path[i], path[j] = path[j], path[i]
+			}
+			return path
+		}
+		for _, next := range adj[pkg] {
+			if _, seen := parent[next]; !seen && members[next] {
+				parent[next] = pkg
+				queue = append(queue, next)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package graphquery
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/toposcope/toposcope/pkg/graph"
+)
+
+func TestNewPackageCycles(t *testing.T) {
+	base := conformanceSnapshot(
+		[2]string{"//a:lib", "//b:lib"},
+		[2]string{"//b:lib", "//c:lib"},
+		[2]string{"//x:lib", "//y:lib"},
+		[2]string{"//y:lib", "//x:lib"},
+	)
+	closing := graph.Edge{From: "//c:lib", To: "//a:lib", Type: "COMPILE"}
+	inside := graph.Edge{From: "//y:lib", To: "//x:util", Type: "COMPILE"}
+	head := conformanceSnapshot(
+		[2]string{"//a:lib", "//b:lib"},
+		[2]string{"//b:lib", "//c:lib"},
+		[2]string{"//c:lib", "//a:lib"},
+		[2]string{"//x:lib", "//y:lib"},
+		[2]string{"//y:lib", "//x:lib"},
+		[2]string{"//y:lib", "//x:util"},
+		[2]string{"//a:lib", "//d:lib"},
+	)
+	added := []graph.Edge{closing, inside, {From: "//a:lib", To: "//d:lib", Type: "COMPILE"}}
+
+	cycles := NewPackageCycles(base, head, added)
+	if len(cycles) != 1 {
+		t.Fatalf("expected 1 new cycle, got %+v", cycles)
+	}
+	c := cycles[0]
+	if !slices.Equal(c.Packages, []string{"//a", "//b", "//c"}) {
+		t.Errorf("unexpected packages: %v", c.Packages)
+	}
+	if !slices.Equal(c.Path, []string{"//c", "//a", "//b", "//c"}) {
+		t.Errorf("unexpected path: %v", c.Path)
+	}
+	if len(c.Edges) != 1 || c.Edges[0] != closing {
+		t.Errorf("unexpected edges: %v", c.Edges)
+	}
+
+	if got := NewPackageCycles(base, base, nil); len(got) != 0 {
+		t.Errorf("expected no cycles without added edges, got %+v", got)
+	}
+}
@@ -0,0 +1,70 @@
+package graphquery
+
+import (
+	"sort"
+
+	"github.com/toposcope/toposcope/pkg/graph"
+)
+
+// NodeDetailResult describes one node and its neighborhood.
+type NodeDetailResult struct {
+	Node            *graph.Node  `json:"node"`
+	Deps            []graph.Edge `json:"deps"`
+	RDeps           []graph.Edge `json:"rdeps"`
+	InDegree        int          `json:"in_degree"`
+	OutDegree       int          `json:"out_degree"`
+	TransitiveDeps  int          `json:"transitive_deps"`
+	TransitiveRDeps int          `json:"transitive_rdeps"`
+}
+
+// NodeDetail returns the node with the given key, its direct dependency and
+// reverse-dependency edges, and the sizes of its transitive closures in each
+// direction (excluding the node itself). It returns nil if the key is not in
+// the snapshot.
+func NodeDetail(snap *graph.Snapshot, key string) *NodeDetailResult {
+	node, ok := snap.Nodes[key]
+	if !ok {
+		return nil
+	}
+
+	result := &NodeDetailResult{Node: node, Deps: []graph.Edge{}, RDeps: []graph.Edge{}}
+	for _, e := range snap.Edges {
+		if e.From == key {
+			result.Deps = append(result.Deps, e)
+		}
+		if e.To == key {
+			result.RDeps = append(result.RDeps, e)
+		}
+	}
+	sort.Slice(result.Deps, func(i, j int) bool { return result.Deps[i].To < result.Deps[j].To })
+	sort.Slice(result.RDeps, func(i, j int) bool { return result.RDeps[i].From < result.RDeps[j].From })
+
+	result.OutDegree = len(result.Deps)
+	result.InDegree = len(result.RDeps)
+	ix := graph.NewIndex(snap)
+	id, _ := ix.ID(key)
+	result.TransitiveDeps = reachable(ix, id, ix.Deps)
+	result.TransitiveRDeps = reachable(ix, id, ix.RDeps)
+	return result
+}
+
+// reachable counts the nodes reachable from start through adj, not
+// counting start.
+func reachable(ix *graph.Index, start int32, adj func(int32) []int32) int {
+	visited := make([]bool, ix.Len())
+	visited[start] = true
+	queue := []int32{start}
+	count := 0
+	for len(queue) > 0 {
+		cur := queue[0]
+		queue = queue[1:]
+		for _, next := range adj(cur) {
+			if !visited[next] {
+				visited[next] = true
+				count++
+				queue = append(queue, next)
+			}
+		}
+	}
+	return count
+}
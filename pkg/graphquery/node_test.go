@@ -0,0 +1,33 @@
+package graphquery
+
+import "testing"
+
+func TestNodeDetail(t *testing.T) {
+	snap := testSnapshot()
+
+	d := NodeDetail(snap, "//b:lib")
+	if d == nil {
+		t.Fatal("expected detail for //b:lib")
+	}
+	if d.InDegree != 1 || d.OutDegree != 1 {
+		t.Errorf("expected in/out degree 1/1, got %d/%d", d.InDegree, d.OutDegree)
+	}
+	if len(d.Deps) != 1 || d.Deps[0].To != "//c:lib" {
+		t.Errorf("expected dep on //c:lib, got %v", d.Deps)
+	}
+	if len(d.RDeps) != 1 || d.RDeps[0].From != "//a:lib" {
+		t.Errorf("expected rdep from //a:lib, got %v", d.RDeps)
+	}
+	// //c:lib, //d:lib, @ext//e:lib
+	if d.TransitiveDeps != 3 {
+		t.Errorf("expected 3 transitive deps, got %d", d.TransitiveDeps)
+	}
+	// //a:lib, //a:test, //f:lib, //f:sub/inner
+	if d.TransitiveRDeps != 4 {
+		t.Errorf("expected 4 transitive rdeps, got %d", d.TransitiveRDeps)
+	}
+
+	if NodeDetail(snap, "//missing:lib") != nil {
+		t.Error("expected nil for missing node")
+	}
+}
@@ -0,0 +1,270 @@
+package graphquery
+
+import (
+	"sort"
+
+	"github.com/toposcope/toposcope/pkg/graph"
+)
+
+// OffenderEntry is a single ranked target in an offenders report.
+type OffenderEntry struct {
+	Key     string  `json:"key"`
+	Package string  `json:"package"`
+	Value   float64 `json:"value"`
+}
+
+// OffendersReport ranks the targets of a snapshot that carry the most
+// structural weight, for periodic architecture reviews.
+type OffendersReport struct {
+	SnapshotID string          `json:"snapshot_id"`
+	CommitSHA  string          `json:"commit_sha"`
+	NodeCount  int             `json:"node_count"`
+	EdgeCount  int             `json:"edge_count"`
+	FanIn      []OffenderEntry `json:"fan_in"`
+	FanOut     []OffenderEntry `json:"fan_out"`
+	Centrality []OffenderEntry `json:"centrality"`
+	Cycles     []OffenderEntry `json:"cycles"`      // value = size of the cycle the target belongs to
+	CycleCount int             `json:"cycle_count"` // number of distinct cycles (SCCs with >1 node)
+}
+
+// TopOffenders ranks the top n targets of a snapshot by fan-in, fan-out,
+// PageRank centrality, and cycle participation. Test targets are excluded
+// from the rankings unless includeTests is set; they still count towards
+// the degrees of the targets they depend on.
+func TopOffenders(snap *graph.Snapshot, n int, includeTests bool) *OffendersReport {
+	if n <= 0 {
+		n = 10
+	}
+
+	inDeg := snap.ComputeInDegrees()
+	outDeg := snap.ComputeOutDegrees()
+	rank := PageRank(snap, 20)
+
+	include := func(key string) bool {
+		node := snap.Nodes[key]
+		if node == nil {
+			return false
+		}
+		return includeTests || !node.IsTest
+	}
+
+	var fanIn, fanOut, centrality []OffenderEntry
+	for key, node := range snap.Nodes {
+		if !include(key) {
+			continue
+		}
+		fanIn = append(fanIn, OffenderEntry{Key: key, Package: node.Package, Value: float64(inDeg[key])})
+		fanOut = append(fanOut, OffenderEntry{Key: key, Package: node.Package, Value: float64(outDeg[key])})
+		centrality = append(centrality, OffenderEntry{Key: key, Package: node.Package, Value: rank[key]})
+	}
+
+	var cycles []OffenderEntry
+	components := FindCycles(snap)
+	for _, comp := range components {
+		for _, key := range comp {
+			if !include(key) {
+				continue
+			}
+			cycles = append(cycles, OffenderEntry{Key: key, Package: snap.Nodes[key].Package, Value: float64(len(comp))})
+		}
+	}
+
+	return &OffendersReport{
+		SnapshotID: snap.ID,
+		CommitSHA:  snap.CommitSHA,
+		NodeCount:  len(snap.Nodes),
+		EdgeCount:  len(snap.Edges),
+		FanIn:      topEntries(fanIn, n),
+		FanOut:     topEntries(fanOut, n),
+		Centrality: topEntries(centrality, n),
+		Cycles:     topEntries(cycles, n),
+		CycleCount: len(components),
+	}
+}
+
+// topEntries sorts entries by value descending (ties broken by key) and
+// returns at most n of them, dropping zero-valued entries.
+func topEntries(entries []OffenderEntry, n int) []OffenderEntry {
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Value != entries[j].Value {
+			return entries[i].Value > entries[j].Value
+		}
+		return entries[i].Key < entries[j].Key
+	})
+
+	result := make([]OffenderEntry, 0, n)
+	for _, e := range entries {
+		if len(result) >= n || e.Value <= 0 {
+			break
+		}
+		result = append(result, e)
+	}
+	return result
+}
+
+// PageRank computes a PageRank score for every node, with rank flowing from
+// a target to its dependencies. Heavily depended-upon targets, and targets
+// depended upon by other central targets, score highest. Scores sum to 1.
+func PageRank(snap *graph.Snapshot, iterations int) map[string]float64 {
+	const damping = 0.85
+
+	n := len(snap.Nodes)
+	rank := make(map[string]float64, n)
+	if n == 0 {
+		return rank
+	}
+
+	fwd := make(map[string][]string)
+	for _, e := range snap.Edges {
+		if snap.Nodes[e.From] == nil || snap.Nodes[e.To] == nil {
+			continue
+		}
+		fwd[e.From] = append(fwd[e.From], e.To)
+	}
+
+	initial := 1.0 / float64(n)
+	for key := range snap.Nodes {
+		rank[key] = initial
+	}
+
+	for i := 0; i < iterations; i++ {
+		next := make(map[string]float64, n)
+
+		// Rank held by nodes without dependencies is spread evenly.
+		var dangling float64
+		for key := range snap.Nodes {
+			if len(fwd[key]) == 0 {
+				dangling += rank[key]
+			}
+		}
+		base := (1-damping)/float64(n) + damping*dangling/float64(n)
+		for key := range snap.Nodes {
+			next[key] = base
+		}
+
+		for from, deps := range fwd {
+			share := damping * rank[from] / float64(len(deps))
+			for _, to := range deps {
+				next[to] += share
+			}
+		}
+		rank = next
+	}
+
+	return rank
+}
+
+// FindCycles returns the strongly connected components of the target graph
+// that contain more than one node. Each component is sorted by key, and the
+// components are ordered largest first.
+func FindCycles(snap *graph.Snapshot) [][]string {
+	adj := make(map[string][]string)
+	for _, e := range snap.Edges {
+		if snap.Nodes[e.From] == nil || snap.Nodes[e.To] == nil {
+			continue
+		}
+		adj[e.From] = append(adj[e.From], e.To)
+	}
+
+	keys := make([]string, 0, len(snap.Nodes))
+	for key := range snap.Nodes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return cyclicComponents(keys, adj)
+}
+
+// cyclicComponents runs Tarjan's algorithm over the given adjacency and
+// returns only the components with more than one member.
+func cyclicComponents(nodes []string, adj map[string][]string) [][]string {
+	var result [][]string
+	for _, comp := range stronglyConnected(nodes, adj) {
+		if len(comp) > 1 {
+			sort.Strings(comp)
+			result = append(result, comp)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if len(result[i]) != len(result[j]) {
+			return len(result[i]) > len(result[j])
+		}
+		return result[i][0] < result[j][0]
+	})
+	return result
+}
+
+// stronglyConnected is an iterative Tarjan SCC so deep dependency chains
+// don't exhaust the goroutine stack.
+func stronglyConnected(nodes []string, adj map[string][]string) [][]string {
+	index := make(map[string]int, len(nodes))
+	lowlink := make(map[string]int, len(nodes))
+	onStack := make(map[string]bool)
+	var stack []string
+	var components [][]string
+	counter := 0
+
+	type frame struct {
+		node string
+		next int // index of the next neighbor to visit
+	}
+
+	for _, root := range nodes {
+		if _, seen := index[root]; seen {
+			continue
+		}
+
+		index[root] = counter
+		lowlink[root] = counter
+		counter++
+		stack = append(stack, root)
+		onStack[root] = true
+		callStack := []frame{{node: root}}
+
+		for len(callStack) > 0 {
+			top := &callStack[len(callStack)-1]
+			v := top.node
+
+			if top.next < len(adj[v]) {
+				w := adj[v][top.next]
+				top.next++
+				if _, seen := index[w]; !seen {
+					index[w] = counter
+					lowlink[w] = counter
+					counter++
+					stack = append(stack, w)
+					onStack[w] = true
+					callStack = append(callStack, frame{node: w})
+				} else if onStack[w] && index[w] < lowlink[v] {
+					lowlink[v] = index[w]
+				}
+				continue
+			}
+
+			// All neighbors visited: pop the frame and propagate lowlink.
+			callStack = callStack[:len(callStack)-1]
+			if len(callStack) > 0 {
+				parent := callStack[len(callStack)-1].node
+				if lowlink[v] < lowlink[parent] {
+					lowlink[parent] = lowlink[v]
+				}
+			}
+
+			if lowlink[v] == index[v] {
+				var comp []string
+				for {
+					w := stack[len(stack)-1]
+					stack = stack[:len(stack)-1]
+					onStack[w] = false
+					comp = append(comp, w)
+					if w == v {
+						break
+					}
+				}
+				components = append(components, comp)
+			}
+		}
+	}
+
+	return components
+}
@@ -0,0 +1,111 @@
+package graphquery
+
+import (
+	"math"
+	"testing"
+
+	"github.com/toposcope/toposcope/pkg/graph"
+)
+
+func cyclicSnapshot() *graph.Snapshot {
+	snap := testSnapshot()
+	snap.Nodes["//g:lib"] = &graph.Node{Key: "//g:lib", Kind: "go_library", Package: "//g"}
+	snap.Nodes["//h:lib"] = &graph.Node{Key: "//h:lib", Kind: "go_library", Package: "//h"}
+	snap.Edges = append(snap.Edges,
+		graph.Edge{From: "//g:lib", To: "//h:lib", Type: "COMPILE"},
+		graph.Edge{From: "//h:lib", To: "//g:lib", Type: "COMPILE"},
+		graph.Edge{From: "//g:lib", To: "//a:lib", Type: "COMPILE"},
+	)
+	return snap
+}
+
+func TestTopOffenders(t *testing.T) {
+	snap := cyclicSnapshot()
+
+	t.Run("fan-in and fan-out", func(t *testing.T) {
+		report := TopOffenders(snap, 3, false)
+		if len(report.FanIn) == 0 || report.FanIn[0].Key != "//a:lib" {
+			t.Errorf("expected //a:lib to top fan-in, got %+v", report.FanIn)
+		}
+		// //a:test is excluded from the ranking but still counts towards //a:lib's degree.
+		if report.FanIn[0].Value != 3 {
+			t.Errorf("expected //a:lib fan-in 3, got %v", report.FanIn[0].Value)
+		}
+		if len(report.FanOut) > 3 {
+			t.Errorf("expected at most 3 fan-out entries, got %d", len(report.FanOut))
+		}
+		if report.FanOut[0].Key != "//g:lib" {
+			t.Errorf("expected //g:lib to top fan-out, got %s", report.FanOut[0].Key)
+		}
+	})
+
+	t.Run("tests excluded by default", func(t *testing.T) {
+		report := TopOffenders(snap, 100, false)
+		for _, e := range report.FanOut {
+			if e.Key == "//a:test" {
+				t.Error("did not expect test target in rankings")
+			}
+		}
+		report = TopOffenders(snap, 100, true)
+		found := false
+		for _, e := range report.FanOut {
+			if e.Key == "//a:test" {
+				found = true
+			}
+		}
+		if !found {
+			t.Error("expected test target with includeTests=true")
+		}
+	})
+
+	t.Run("cycles", func(t *testing.T) {
+		report := TopOffenders(snap, 10, false)
+		if report.CycleCount != 1 {
+			t.Errorf("expected 1 cycle, got %d", report.CycleCount)
+		}
+		if len(report.Cycles) != 2 {
+			t.Fatalf("expected 2 targets in cycles, got %d", len(report.Cycles))
+		}
+		for _, e := range report.Cycles {
+			if e.Value != 2 {
+				t.Errorf("expected cycle size 2 for %s, got %v", e.Key, e.Value)
+			}
+		}
+	})
+}
+
+func TestPageRank(t *testing.T) {
+	snap := testSnapshot()
+	rank := PageRank(snap, 20)
+
+	var total float64
+	for _, r := range rank {
+		total += r
+	}
+	if math.Abs(total-1) > 1e-6 {
+		t.Errorf("expected ranks to sum to 1, got %f", total)
+	}
+
+	// //a:lib is depended upon by both //f:lib and //a:test; //f:sub/inner by nobody.
+	if rank["//a:lib"] <= rank["//f:sub/inner"] {
+		t.Errorf("expected //a:lib (%f) to outrank //f:sub/inner (%f)", rank["//a:lib"], rank["//f:sub/inner"])
+	}
+}
+
+func TestFindCycles(t *testing.T) {
+	t.Run("acyclic", func(t *testing.T) {
+		if cycles := FindCycles(testSnapshot()); len(cycles) != 0 {
+			t.Errorf("expected no cycles, got %v", cycles)
+		}
+	})
+
+	t.Run("two-node cycle", func(t *testing.T) {
+		cycles := FindCycles(cyclicSnapshot())
+		if len(cycles) != 1 {
+			t.Fatalf("expected 1 cycle, got %d", len(cycles))
+		}
+		if cycles[0][0] != "//g:lib" || cycles[0][1] != "//h:lib" {
+			t.Errorf("unexpected cycle members: %v", cycles[0])
+		}
+	})
+}
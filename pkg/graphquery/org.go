@@ -0,0 +1,221 @@
+package graphquery
+
+import (
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/toposcope/toposcope/pkg/graph"
+)
+
+// OrgRepo is one repository in an org-level view, with the snapshot that
+// stands for it, usually its baseline.
+type OrgRepo struct {
+	Repo     string // owner/name
+	Snapshot *graph.Snapshot
+}
+
+// OrgRepoSummary describes one repository's snapshot in an org graph.
+type OrgRepoSummary struct {
+	Repo       string `json:"repo"`
+	SnapshotID string `json:"snapshot_id"`
+	CommitSHA  string `json:"commit_sha"`
+	Packages   int    `json:"packages"`
+	Targets    int    `json:"targets"`
+}
+
+// OrgGraphResult is the package graph of several repositories at once.
+type OrgGraphResult struct {
+	Repos []OrgRepoSummary `json:"repos"`
+	// RepoEdges sums the cross-repo target edges per pair of repositories.
+	// From and To are repository names.
+	RepoEdges []PackageEdge `json:"repo_edges"`
+	// Packages is the merged package graph. Node keys are the repository
+	// name followed by the package, as in "acme/api//app/server". A
+	// cross-repo edge points at the node "<repo>//...", which stands for the
+	// whole of the repository it depends on.
+	Packages *PackageGraphResult `json:"packages"`
+}
+
+// OrgGraph merges the package graphs of repos. A dependency on an external
+// target that extraction mapped to a sibling in repos (Node.Repo) becomes a
+// cross-repo edge to the package it names, or to the whole sibling when its
+// baseline lacks the package. A dependency on an external repository whose
+// name matches a sibling, as InferRepoMapping matches them, becomes a
+// cross-repo edge to the whole sibling. Other external dependencies are left
+// out. Snapshots only keep unmapped external dependencies when extracted with
+// include_external. HideExternal is ignored; MaxPackages caps the merged
+// graph by degree.
+func OrgGraph(repos []OrgRepo, p PackageParams) *OrgGraphResult {
+	names := make([]string, len(repos))
+	tracked := make(map[string]bool, len(repos))
+	for i, r := range repos {
+		names[i] = r.Repo
+		tracked[r.Repo] = true
+	}
+	sibling := InferRepoMapping(names)
+
+	result := &OrgGraphResult{
+		Repos:     make([]OrgRepoSummary, 0, len(repos)),
+		RepoEdges: []PackageEdge{},
+	}
+	nodes := make(map[string]*PackageNode)
+	graphs := make([]*PackageGraphResult, len(repos))
+	for i, r := range repos {
+		pg := AggregatePackages(FilterLanguages(r.Snapshot, p.Languages), p.HideTests, false, 1, math.MaxInt)
+		graphs[i] = pg
+		summary := OrgRepoSummary{Repo: r.Repo, SnapshotID: r.Snapshot.ID, CommitSHA: r.Snapshot.CommitSHA}
+		for pkg, n := range pg.Nodes {
+			if n.IsExternal {
+				continue
+			}
+			node := *n
+			node.Repo = r.Repo
+			nodes[r.Repo+pkg] = &node
+			summary.Packages++
+			summary.Targets += n.TargetCount
+		}
+		result.Repos = append(result.Repos, summary)
+	}
+
+	weights := make(map[[2]string]int)
+	repoWeights := make(map[[2]string]int)
+	for i, r := range repos {
+		pg := graphs[i]
+		for _, e := range pg.Edges {
+			if pg.Nodes[e.From].IsExternal {
+				continue
+			}
+			to := pg.Nodes[e.To]
+			if !to.IsExternal {
+				weights[[2]string{r.Repo + e.From, r.Repo + e.To}] += e.Weight
+				continue
+			}
+			target, ok := to.Repo, to.Repo != ""
+			if !ok {
+				target, ok = sibling.Resolve(e.To)
+			}
+			if !ok || !tracked[target] || target == r.Repo {
+				continue
+			}
+			key := target + "//..."
+			if _, pkg, found := strings.Cut(e.To, "//"); found && to.Repo != "" && nodes[target+"//"+pkg] != nil {
+				key = target + "//" + pkg
+			}
+			if nodes[key] == nil {
+				nodes[key] = &PackageNode{Package: "//...", Repo: target, Kinds: []string{}}
+			}
+			weights[[2]string{r.Repo + e.From, key}] += e.Weight
+			repoWeights[[2]string{r.Repo, target}] += e.Weight
+		}
+	}
+	sort.Slice(result.Repos, func(i, j int) bool { return result.Repos[i].Repo < result.Repos[j].Repo })
+
+	for pair, w := range repoWeights {
+		result.RepoEdges = append(result.RepoEdges, PackageEdge{From: pair[0], To: pair[1], Weight: w})
+	}
+	sortPackageEdges(result.RepoEdges)
+
+	edges := make([]PackageEdge, 0, len(weights))
+	for pair, w := range weights {
+		if w >= p.MinEdgeWeight {
+			edges = append(edges, PackageEdge{From: pair[0], To: pair[1], Weight: w, CrossRepo: nodes[pair[1]].Repo != nodes[pair[0]].Repo})
+		}
+	}
+	sortPackageEdges(edges)
+
+	truncated := false
+	if maxPkgs := p.MaxPackages; maxPkgs > 0 && len(nodes) > maxPkgs {
+		degree := make(map[string]int)
+		for _, e := range edges {
+			degree[e.From]++
+			degree[e.To]++
+		}
+		keys := make([]string, 0, len(nodes))
+		for key := range nodes {
+			keys = append(keys, key)
+		}
+		sort.Slice(keys, func(i, j int) bool {
+			if degree[keys[i]] != degree[keys[j]] {
+				return degree[keys[i]] > degree[keys[j]]
+			}
+			return keys[i] < keys[j]
+		})
+		for _, key := range keys[maxPkgs:] {
+			delete(nodes, key)
+		}
+		kept := edges[:0]
+		for _, e := range edges {
+			if nodes[e.From] != nil && nodes[e.To] != nil {
+				kept = append(kept, e)
+			}
+		}
+		edges = kept
+		truncated = true
+	}
+
+	result.Packages = &PackageGraphResult{Nodes: nodes, Edges: edges, Truncated: truncated}
+	if p.Layout {
+		LayoutPackages(result.Packages)
+	}
+	return result
+}
+
+// RepoMapping maps external repository names, as they appear in snapshots
+// ("@payments"), to tracked repositories ("acme/payments"). Keys are
+// normalized names; look external names up with Resolve.
+type RepoMapping map[string]string
+
+// InferRepoMapping guesses which external repository names name the
+// repositories in repos. An external name matches a repository
+// "owner/name" when, ignoring case, leading @s, a bzlmod version suffix
+// after ~ or +, and the difference between - and _, it is "name" or
+// "com_github_owner_name". Names that match more than one repository are
+// left out.
+func InferRepoMapping(repos []string) RepoMapping {
+	candidates := make(map[string][]string)
+	for _, repo := range repos {
+		owner, name, ok := strings.Cut(repo, "/")
+		if !ok {
+			name, owner = repo, ""
+		}
+		forms := []string{normalizeRepoName(name)}
+		if owner != "" {
+			forms = append(forms, normalizeRepoName("com_github_"+owner+"_"+name))
+		}
+		for _, f := range forms {
+			candidates[f] = append(candidates[f], repo)
+		}
+	}
+
+	mapping := make(RepoMapping)
+	for form, matches := range candidates {
+		if len(matches) == 1 {
+			mapping[form] = matches[0]
+		}
+	}
+	return mapping
+}
+
+// Resolve returns the repository an external repository name maps to.
+func (m RepoMapping) Resolve(external string) (string, bool) {
+	repo, ok := m[normalizeRepoName(external)]
+	return repo, ok
+}
+
+func normalizeRepoName(name string) string {
+	name = strings.TrimLeft(name, "@")
+	if i := strings.IndexAny(name, "~+"); i >= 0 {
+		name = name[:i]
+	}
+	return strings.ReplaceAll(strings.ToLower(name), "-", "_")
+}
+
+func sortPackageEdges(edges []PackageEdge) {
+	sort.Slice(edges, func(i, j int) bool {
+		if edges[i].From != edges[j].From {
+			return edges[i].From < edges[j].From
+		}
+		return edges[i].To < edges[j].To
+	})
+}
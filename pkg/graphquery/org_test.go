@@ -0,0 +1,107 @@
+package graphquery
+
+import (
+	"math"
+	"testing"
+
+	"github.com/toposcope/toposcope/pkg/graph"
+)
+
+func TestInferRepoMapping(t *testing.T) {
+	m := InferRepoMapping([]string{"acme/payments", "acme/api-gateway", "acme/util", "other/util"})
+	for external, want := range map[string]string{
+		"@payments":                  "acme/payments",
+		"@@payments~":                "acme/payments",
+		"@api_gateway":               "acme/api-gateway",
+		"@com_github_acme_util":      "acme/util",
+		"@com_github_acme_payments+": "acme/payments",
+	} {
+		if got, ok := m.Resolve(external); !ok || got != want {
+			t.Errorf("%s: expected %s, got %q", external, want, got)
+		}
+	}
+	for _, external := range []string{"@util", "@maven"} {
+		if got, ok := m.Resolve(external); ok {
+			t.Errorf("%s: expected no match, got %s", external, got)
+		}
+	}
+}
+
+func TestOrgGraph(t *testing.T) {
+	api := conformanceSnapshot(
+		[2]string{"//app:server", "//lib:auth"},
+		[2]string{"//app:server", "@payments"},
+		[2]string{"//lib:auth", "@payments"},
+		[2]string{"//lib:auth", "@maven"},
+	)
+	api.ID = "api-snap"
+	for _, key := range []string{"@payments", "@maven"} {
+		api.Nodes[key] = &graph.Node{Key: key, Package: key, Kind: "external_repo", IsExternal: true}
+	}
+	payments := conformanceSnapshot([2]string{"//billing:lib", "//core:lib"})
+	payments.ID = "payments-snap"
+
+	result := OrgGraph([]OrgRepo{
+		{Repo: "acme/payments", Snapshot: payments},
+		{Repo: "acme/api", Snapshot: api},
+	}, PackageParams{MinEdgeWeight: 1, MaxPackages: math.MaxInt})
+
+	if len(result.Repos) != 2 || result.Repos[0].Repo != "acme/api" || result.Repos[0].Packages != 2 {
+		t.Errorf("unexpected repos: %+v", result.Repos)
+	}
+	if len(result.RepoEdges) != 1 || result.RepoEdges[0] != (PackageEdge{From: "acme/api", To: "acme/payments", Weight: 2}) {
+		t.Errorf("unexpected repo edges: %+v", result.RepoEdges)
+	}
+
+	pg := result.Packages
+	if len(pg.Nodes) != 5 || pg.Nodes["acme/payments//..."] == nil || pg.Nodes["acme/api//lib"].Repo != "acme/api" {
+		t.Fatalf("unexpected nodes: %v", pg.Nodes)
+	}
+	cross := 0
+	for _, e := range pg.Edges {
+		if e.CrossRepo {
+			cross++
+			if e.To != "acme/payments//..." {
+				t.Errorf("unexpected cross-repo edge: %+v", e)
+			}
+		}
+	}
+	if len(pg.Edges) != 4 || cross != 2 {
+		t.Errorf("expected 4 edges, 2 of them cross-repo, got %+v", pg.Edges)
+	}
+}
+
+func TestOrgGraphRepoMapping(t *testing.T) {
+	api := conformanceSnapshot(
+		[2]string{"//app:server", "@corp_pay//billing:lib"},
+		[2]string{"//app:server", "@corp_pay//gone:lib"},
+	)
+	for _, key := range []string{"@corp_pay//billing:lib", "@corp_pay//gone:lib"} {
+		api.Nodes[key] = &graph.Node{Key: key, Package: graph.LabelPackage(key), Kind: "external_target", IsExternal: true, Repo: "acme/payments"}
+	}
+	payments := conformanceSnapshot([2]string{"//billing:lib", "//core:lib"})
+
+	result := OrgGraph([]OrgRepo{
+		{Repo: "acme/payments", Snapshot: payments},
+		{Repo: "acme/api", Snapshot: api},
+	}, PackageParams{MinEdgeWeight: 1, MaxPackages: math.MaxInt})
+
+	// The package the baseline has gets a real edge; the missing one falls
+	// back to the whole repository.
+	want := map[string]bool{"acme/payments//billing": true, "acme/payments//...": true}
+	for _, e := range result.Packages.Edges {
+		if !e.CrossRepo {
+			continue
+		}
+		if e.From != "acme/api//app" || !want[e.To] {
+			t.Errorf("unexpected edge: %+v", e)
+		}
+		delete(want, e.To)
+	}
+	if len(want) != 0 {
+		t.Errorf("missing cross-repo edges to %v", want)
+	}
+	if len(result.RepoEdges) != 1 || result.RepoEdges[0].Weight != 2 {
+		t.Errorf("unexpected repo edges: %+v", result.RepoEdges)
+	}
+}
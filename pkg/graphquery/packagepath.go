@@ -0,0 +1,147 @@
+package graphquery
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/toposcope/toposcope/pkg/graph"
+)
+
+// PackagePathResult holds the result of a package-level path query.
+type PackagePathResult struct {
+	Paths      [][]string              `json:"paths"`
+	Nodes      map[string]*PackageNode `json:"nodes"`
+	Edges      []PackageEdge           `json:"edges"`
+	From       string                  `json:"from"`
+	To         string                  `json:"to"`
+	PathLength int                     `json:"path_length"`
+}
+
+// FindPackagePaths finds the shortest chains of package-to-package edges
+// from the packages matching fromQ to those matching toQ, over the
+// aggregated package graph. A query matches a package and every package
+// below it, so "//legacy" also matches "//legacy/db". Each edge in the
+// result carries the number of target edges it aggregates. At most maxPaths
+// paths are returned (0 means 10), in a stable order.
+func FindPackagePaths(snap *graph.Snapshot, fromQ, toQ string, maxPaths int, hideTests, hideExternal bool) *PackagePathResult {
+	if maxPaths <= 0 {
+		maxPaths = 10
+	}
+
+	pg := AggregatePackages(snap, hideTests, hideExternal, 1, len(snap.Nodes)+1)
+	sort.Slice(pg.Edges, func(i, j int) bool {
+		if pg.Edges[i].From != pg.Edges[j].From {
+			return pg.Edges[i].From < pg.Edges[j].From
+		}
+		return pg.Edges[i].To < pg.Edges[j].To
+	})
+	adj := make(map[string][]string)
+	weight := make(map[[2]string]int)
+	for _, e := range pg.Edges {
+		adj[e.From] = append(adj[e.From], e.To)
+		weight[[2]string{e.From, e.To}] = e.Weight
+	}
+
+	matching := func(query string) []string {
+		query = strings.TrimSuffix(query, "/")
+		var pkgs []string
+		for pkg := range pg.Nodes {
+			if pkg == query || strings.HasPrefix(pkg, query+"/") {
+				pkgs = append(pkgs, pkg)
+			}
+		}
+		sort.Strings(pkgs)
+		return pkgs
+	}
+	fromPkgs, toPkgs := matching(fromQ), matching(toQ)
+
+	result := &PackagePathResult{
+		Paths: [][]string{},
+		Nodes: map[string]*PackageNode{},
+		Edges: []PackageEdge{},
+		From:  fromQ,
+		To:    toQ,
+	}
+	if len(fromPkgs) == 0 || len(toPkgs) == 0 {
+		return result
+	}
+
+	// BFS from every source at once, recording all shortest-path parents,
+	// and stop after the level where a target is first reached.
+	isTarget := make(map[string]bool, len(toPkgs))
+	for _, pkg := range toPkgs {
+		isTarget[pkg] = true
+	}
+	dist := make(map[string]int)
+	parents := make(map[string][]string)
+	queue := append([]string(nil), fromPkgs...)
+	for _, pkg := range fromPkgs {
+		dist[pkg] = 0
+	}
+	found := -1
+	for len(queue) > 0 {
+		curr := queue[0]
+		queue = queue[1:]
+		if found >= 0 && dist[curr] >= found {
+			if isTarget[curr] {
+				continue
+			}
+			break
+		}
+		if isTarget[curr] {
+			found = dist[curr]
+			continue
+		}
+		for _, nb := range adj[curr] {
+			d, seen := dist[nb]
+			switch {
+			case !seen:
+				dist[nb] = dist[curr] + 1
+				parents[nb] = []string{curr}
+				queue = append(queue, nb)
+			case d == dist[curr]+1:
+				parents[nb] = append(parents[nb], curr)
+			}
+		}
+	}
+	if found < 0 {
+		return result
+	}
+
+	var backtrack func(pkg string, suffix []string)
+	backtrack = func(pkg string, suffix []string) {
+		if len(result.Paths) >= maxPaths {
+			return
+		}
+		path := append([]string{pkg}, suffix...)
+		if dist[pkg] == 0 {
+			result.Paths = append(result.Paths, path)
+			return
+		}
+		for _, p := range parents[pkg] {
+			backtrack(p, path)
+		}
+	}
+	for _, pkg := range toPkgs {
+		if d, ok := dist[pkg]; ok && d == found {
+			backtrack(pkg, nil)
+		}
+	}
+
+	seenEdge := make(map[[2]string]bool)
+	for _, path := range result.Paths {
+		for i, pkg := range path {
+			result.Nodes[pkg] = pg.Nodes[pkg]
+			if i == 0 {
+				continue
+			}
+			e := [2]string{path[i-1], pkg}
+			if !seenEdge[e] {
+				seenEdge[e] = true
+				result.Edges = append(result.Edges, PackageEdge{From: e[0], To: e[1], Weight: weight[e]})
+			}
+		}
+	}
+	result.PathLength = found
+	return result
+}
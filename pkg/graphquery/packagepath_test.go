@@ -0,0 +1,58 @@
+package graphquery
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/toposcope/toposcope/pkg/graph"
+)
+
+func TestFindPackagePaths(t *testing.T) {
+	snap := testSnapshot()
+	snap.Nodes["//a/web:lib"] = &graph.Node{Key: "//a/web:lib", Kind: "go_library", Package: "//a/web"}
+	snap.Edges = append(snap.Edges,
+		graph.Edge{From: "//a/web:lib", To: "//c:lib", Type: "COMPILE"},
+		graph.Edge{From: "//b:lib", To: "//c:lib", Type: "COMPILE"},
+	)
+
+	t.Run("shortest chains", func(t *testing.T) {
+		result := FindPackagePaths(snap, "//f", "//d", 0, false, false)
+		want := [][]string{{"//f", "//a", "//b", "//c", "//d"}}
+		if !reflect.DeepEqual(result.Paths, want) || result.PathLength != 4 {
+			t.Errorf("paths = %v (length %d), want %v", result.Paths, result.PathLength, want)
+		}
+		if len(result.Nodes) != 5 || len(result.Edges) != 4 {
+			t.Errorf("got %d packages and %d edges, want 5 and 4", len(result.Nodes), len(result.Edges))
+		}
+		for _, e := range result.Edges {
+			if e.From == "//b" && e.To == "//c" && e.Weight != 2 {
+				t.Errorf("//b -> //c weight = %d, want 2", e.Weight)
+			}
+		}
+	})
+
+	t.Run("subpackages match", func(t *testing.T) {
+		// //a matches //a/web, whose direct edge to //c is shorter.
+		result := FindPackagePaths(snap, "//a", "//c", 0, false, false)
+		want := [][]string{{"//a/web", "//c"}}
+		if !reflect.DeepEqual(result.Paths, want) {
+			t.Errorf("paths = %v, want %v", result.Paths, want)
+		}
+	})
+
+	t.Run("hide external", func(t *testing.T) {
+		if result := FindPackagePaths(snap, "//d", "@ext//e", 0, false, false); len(result.Paths) != 1 {
+			t.Errorf("expected a path to the external package, got %v", result.Paths)
+		}
+		if result := FindPackagePaths(snap, "//d", "@ext//e", 0, false, true); len(result.Paths) != 0 {
+			t.Errorf("expected no path with externals hidden, got %v", result.Paths)
+		}
+	})
+
+	t.Run("no path", func(t *testing.T) {
+		result := FindPackagePaths(snap, "//d", "//a", 0, false, false)
+		if len(result.Paths) != 0 || len(result.Edges) != 0 || result.PathLength != 0 {
+			t.Errorf("expected an empty result, got %+v", result)
+		}
+	})
+}
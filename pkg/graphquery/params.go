@@ -0,0 +1,300 @@
+package graphquery
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/toposcope/toposcope/pkg/graph"
+)
+
+// The query string parsers below are shared by the local `toposcope ui`
+// server and the hosted API so both accept the same parameters with the
+// same defaults. Malformed numbers fall back to the default.
+
+// SubgraphParams are the parameters of a subgraph query.
+type SubgraphParams struct {
+	Roots    []string
+	Depth    int
+	MaxNodes int
+	Offset   int // where the page starts, from page_token
+}
+
+// ParseSubgraphParams reads root (repeatable), depth (default 2),
+// max_nodes (default 500), and page_token.
+func ParseSubgraphParams(q url.Values) (SubgraphParams, error) {
+	p := SubgraphParams{
+		Roots:    q["root"],
+		Depth:    intParam(q, "depth", 2, 0),
+		MaxNodes: intParam(q, "max_nodes", 500, 1),
+	}
+	var err error
+	p.Offset, err = decodePageToken(q.Get("page_token"), p.query())
+	return p, err
+}
+
+func (p SubgraphParams) query() string {
+	if len(p.Roots) == 0 {
+		return "subgraph"
+	}
+	return fmt.Sprintf("subgraph\x00%s\x00%d", strings.Join(p.Roots, "\x00"), p.Depth)
+}
+
+// Subgraph runs a subgraph query. Without roots it returns the whole graph
+// capped to the highest-degree nodes.
+func Subgraph(snap *graph.Snapshot, p SubgraphParams) *SubgraphResult {
+	var result *SubgraphResult
+	if len(p.Roots) == 0 {
+		result = capGraph(snap, p.MaxNodes, p.Offset)
+	} else {
+		result = extractSubgraph(snap, p.Roots, p.Depth, p.MaxNodes, p.Offset)
+	}
+	if result.Truncated {
+		result.NextToken = encodePageToken(result.next, p.query())
+	}
+	return result
+}
+
+// EgoParams are the parameters of an ego graph query.
+type EgoParams struct {
+	Target    string
+	Depth     int
+	Direction string
+	MaxNodes  int
+	Offset    int // where the page starts, from page_token
+}
+
+// ParseEgoParams reads target (required), depth (default 2), direction
+// (default "both"), max_nodes (default 500), and page_token.
+func ParseEgoParams(q url.Values) (EgoParams, error) {
+	p := EgoParams{
+		Target:    q.Get("target"),
+		Depth:     intParam(q, "depth", 2, 1),
+		Direction: q.Get("direction"),
+		MaxNodes:  intParam(q, "max_nodes", 500, 1),
+	}
+	if p.Target == "" {
+		return p, errors.New("target parameter required")
+	}
+	if p.Direction == "" {
+		p.Direction = "both"
+	}
+	var err error
+	p.Offset, err = decodePageToken(q.Get("page_token"), p.query())
+	return p, err
+}
+
+func (p EgoParams) query() string {
+	return fmt.Sprintf("ego\x00%s\x00%d\x00%s", p.Target, p.Depth, p.Direction)
+}
+
+// Ego runs an ego graph query.
+func Ego(snap *graph.Snapshot, p EgoParams) *SubgraphResult {
+	result := egoGraph(snap, p.Target, p.Depth, p.Direction, p.MaxNodes, p.Offset)
+	if result.Truncated {
+		result.NextToken = encodePageToken(result.next, p.query())
+	}
+	return result
+}
+
+// A continuation token is opaque to clients. It holds the position in the
+// query's result order where the next page starts, and a checksum of the
+// query, so it can't be replayed against a different query. max_nodes may
+// change from page to page.
+
+func encodePageToken(offset int, query string) string {
+	raw := fmt.Sprintf("%d.%08x", offset, crc32.ChecksumIEEE([]byte(query)))
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// decodePageToken returns the offset a token resumes at, or 0 for no token.
+func decodePageToken(token, query string) (int, error) {
+	if token == "" {
+		return 0, nil
+	}
+	errInvalid := errors.New("invalid page_token for this query")
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return 0, errInvalid
+	}
+	off, sum, ok := strings.Cut(string(raw), ".")
+	offset, err := strconv.Atoi(off)
+	if !ok || err != nil || offset < 0 || sum != fmt.Sprintf("%08x", crc32.ChecksumIEEE([]byte(query))) {
+		return 0, errInvalid
+	}
+	return offset, nil
+}
+
+// PathParams are the parameters of a path query.
+type PathParams struct {
+	From     string
+	To       string
+	MaxPaths int
+}
+
+// ParsePathParams reads from and to (both required) and max_paths
+// (default 10).
+func ParsePathParams(q url.Values) (PathParams, error) {
+	p := PathParams{
+		From:     q.Get("from"),
+		To:       q.Get("to"),
+		MaxPaths: intParam(q, "max_paths", 10, 1),
+	}
+	if p.From == "" || p.To == "" {
+		return p, errors.New("from and to parameters required")
+	}
+	return p, nil
+}
+
+// Paths runs a path query.
+func Paths(snap *graph.Snapshot, p PathParams) *PathResult {
+	return FindPaths(snap, p.From, p.To, p.MaxPaths)
+}
+
+// ExplainParams are the parameters of an explain query.
+type ExplainParams struct {
+	PathParams
+	MaxCut int
+}
+
+// ParseExplainParams reads the path query parameters and max_cut
+// (default 50).
+func ParseExplainParams(q url.Values) (ExplainParams, error) {
+	pp, err := ParsePathParams(q)
+	return ExplainParams{PathParams: pp, MaxCut: intParam(q, "max_cut", 50, 1)}, err
+}
+
+// Explanation runs an explain query.
+func Explanation(snap *graph.Snapshot, p ExplainParams) *ExplainResult {
+	return Explain(snap, p.From, p.To, p.MaxPaths, p.MaxCut)
+}
+
+// PackagePathParams are the parameters of a package path query.
+type PackagePathParams struct {
+	From         string
+	To           string
+	MaxPaths     int
+	HideTests    bool
+	HideExternal bool
+}
+
+// ParsePackagePathParams reads from and to (both required), max_paths
+// (default 10), hide_tests, and hide_external.
+func ParsePackagePathParams(q url.Values) (PackagePathParams, error) {
+	p := PackagePathParams{
+		From:         q.Get("from"),
+		To:           q.Get("to"),
+		MaxPaths:     intParam(q, "max_paths", 10, 1),
+		HideTests:    q.Get("hide_tests") == "true",
+		HideExternal: q.Get("hide_external") == "true",
+	}
+	if p.From == "" || p.To == "" {
+		return p, errors.New("from and to parameters required")
+	}
+	return p, nil
+}
+
+// PackagePaths runs a package path query.
+func PackagePaths(snap *graph.Snapshot, p PackagePathParams) *PackagePathResult {
+	return FindPackagePaths(snap, p.From, p.To, p.MaxPaths, p.HideTests, p.HideExternal)
+}
+
+// SampleParams are the parameters of a graph sample.
+type SampleParams struct {
+	MaxNodes int
+	Seed     int
+}
+
+// ParseSampleParams reads max_nodes (default 500) and seed (default 0).
+func ParseSampleParams(q url.Values) SampleParams {
+	return SampleParams{
+		MaxNodes: intParam(q, "max_nodes", 500, 1),
+		Seed:     intParam(q, "seed", 0, 0),
+	}
+}
+
+// Sample runs a graph sample.
+func Sample(snap *graph.Snapshot, p SampleParams) *SubgraphResult {
+	return SampleGraph(snap, p.MaxNodes, int64(p.Seed))
+}
+
+// PackageParams are the parameters of a package graph query.
+type PackageParams struct {
+	HideTests     bool
+	HideExternal  bool
+	MinEdgeWeight int
+	MaxPackages   int
+	Languages     []string // only targets in these languages; all if empty
+	Layout        bool     // precompute node positions
+}
+
+// ParsePackageParams reads hide_tests, hide_external, min_edge_weight
+// (default 1), max_packages (default 500), language (repeatable), and
+// layout.
+func ParsePackageParams(q url.Values) PackageParams {
+	return PackageParams{
+		HideTests:     q.Get("hide_tests") == "true",
+		HideExternal:  q.Get("hide_external") == "true",
+		MinEdgeWeight: intParam(q, "min_edge_weight", 1, 1),
+		MaxPackages:   intParam(q, "max_packages", 500, 1),
+		Languages:     q["language"],
+		Layout:        q.Get("layout") == "true",
+	}
+}
+
+// Packages runs a package graph query, laying it out when asked.
+func Packages(snap *graph.Snapshot, p PackageParams) *PackageGraphResult {
+	result := AggregatePackages(FilterLanguages(snap, p.Languages), p.HideTests, p.HideExternal, p.MinEdgeWeight, p.MaxPackages)
+	if p.Layout {
+		LayoutPackages(result)
+	}
+	return result
+}
+
+// Communities detects communities in the package graph the same
+// parameters select. Layout is ignored.
+func Communities(snap *graph.Snapshot, p PackageParams) *CommunityResult {
+	return DetectCommunities(AggregatePackages(FilterLanguages(snap, p.Languages), p.HideTests, p.HideExternal, p.MinEdgeWeight, p.MaxPackages))
+}
+
+// SearchParams are the parameters of a target search.
+type SearchParams struct {
+	Query     string
+	Kinds     []string
+	Languages []string
+	Limit     int
+}
+
+// ParseSearchParams reads q, kind and language (both repeatable), and
+// limit (default 50).
+func ParseSearchParams(q url.Values) SearchParams {
+	return SearchParams{
+		Query:     q.Get("q"),
+		Kinds:     q["kind"],
+		Languages: q["language"],
+		Limit:     intParam(q, "limit", 50, 1),
+	}
+}
+
+// Search runs a target search.
+func Search(snap *graph.Snapshot, p SearchParams) *SearchResult {
+	return SearchNodes(snap, p.Query, p.Kinds, p.Languages, p.Limit)
+}
+
+// intParam parses an integer parameter, returning def when it is missing,
+// malformed, or below min.
+func intParam(q url.Values, name string, def, min int) int {
+	v := q.Get(name)
+	if v == "" {
+		return def
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < min {
+		return def
+	}
+	return n
+}
@@ -0,0 +1,156 @@
+package graphquery
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestParseSubgraphParams(t *testing.T) {
+	p, err := ParseSubgraphParams(url.Values{"root": {"//a", "//b"}, "depth": {"0"}, "max_nodes": {"bogus"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(p.Roots) != 2 || p.Depth != 0 || p.MaxNodes != 500 {
+		t.Errorf("unexpected params: %+v", p)
+	}
+
+	p, _ = ParseSubgraphParams(url.Values{"depth": {"-1"}})
+	if p.Depth != 2 {
+		t.Errorf("negative depth should fall back to 2, got %d", p.Depth)
+	}
+}
+
+func TestParseEgoParams(t *testing.T) {
+	if _, err := ParseEgoParams(url.Values{}); err == nil {
+		t.Error("expected error without target")
+	}
+
+	p, err := ParseEgoParams(url.Values{"target": {"//a:lib"}, "depth": {"0"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Depth != 2 || p.Direction != "both" || p.MaxNodes != 500 {
+		t.Errorf("unexpected defaults: %+v", p)
+	}
+}
+
+func TestParsePathParams(t *testing.T) {
+	if _, err := ParsePathParams(url.Values{"from": {"//a:lib"}}); err == nil {
+		t.Error("expected error without to")
+	}
+
+	p, err := ParsePathParams(url.Values{"from": {"//a:lib"}, "to": {"//d:lib"}, "max_paths": {"3"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.MaxPaths != 3 {
+		t.Errorf("expected max_paths 3, got %d", p.MaxPaths)
+	}
+}
+
+func TestParsePackageParams(t *testing.T) {
+	p := ParsePackageParams(url.Values{"hide_tests": {"true"}, "min_edge_weight": {"0"}})
+	if !p.HideTests || p.HideExternal || p.MinEdgeWeight != 1 || p.MaxPackages != 500 {
+		t.Errorf("unexpected params: %+v", p)
+	}
+}
+
+func TestParseSampleParams(t *testing.T) {
+	p := ParseSampleParams(url.Values{"max_nodes": {"0"}, "seed": {"7"}})
+	if p.MaxNodes != 500 || p.Seed != 7 {
+		t.Errorf("unexpected params: %+v", p)
+	}
+}
+
+func TestSubgraph(t *testing.T) {
+	snap := testSnapshot()
+
+	t.Run("no roots caps whole graph", func(t *testing.T) {
+		result := Subgraph(snap, SubgraphParams{MaxNodes: 3})
+		if len(result.Nodes) != 3 || !result.Truncated {
+			t.Errorf("expected 3 truncated nodes, got %d (truncated=%v)", len(result.Nodes), result.Truncated)
+		}
+	})
+
+	t.Run("roots", func(t *testing.T) {
+		result := Subgraph(snap, SubgraphParams{Roots: []string{"//b:lib"}, Depth: 1, MaxNodes: 500})
+		if len(result.Nodes) != 3 || result.Truncated {
+			t.Errorf("expected 3 untruncated nodes, got %d (truncated=%v)", len(result.Nodes), result.Truncated)
+		}
+	})
+}
+
+// pageAll follows next_token until the last page, merging the pages.
+func pageAll(t *testing.T, q url.Values, run func(url.Values) (*SubgraphResult, error)) (nodes map[string]bool, edges int, pages int) {
+	t.Helper()
+	nodes = map[string]bool{}
+	for {
+		result, err := run(q)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pages++
+		for key := range result.Nodes {
+			if nodes[key] {
+				t.Errorf("page %d repeats %s", pages, key)
+			}
+			nodes[key] = true
+		}
+		edges += len(result.Edges)
+		if result.NextToken == "" {
+			if result.Truncated {
+				t.Errorf("page %d is truncated without a next_token", pages)
+			}
+			return nodes, edges, pages
+		}
+		q.Set("page_token", result.NextToken)
+	}
+}
+
+func TestSubgraphPages(t *testing.T) {
+	snap := testSnapshot()
+	subgraph := func(q url.Values) (*SubgraphResult, error) {
+		p, err := ParseSubgraphParams(q)
+		return Subgraph(snap, p), err
+	}
+	ego := func(q url.Values) (*SubgraphResult, error) {
+		p, err := ParseEgoParams(q)
+		return Ego(snap, p), err
+	}
+
+	for name, tc := range map[string]struct {
+		q   url.Values
+		run func(url.Values) (*SubgraphResult, error)
+	}{
+		"whole graph": {url.Values{"max_nodes": {"3"}}, subgraph},
+		"roots":       {url.Values{"root": {"//f"}, "depth": {"10"}, "max_nodes": {"3"}}, subgraph},
+		"ego":         {url.Values{"target": {"//c:lib"}, "depth": {"10"}, "max_nodes": {"2"}}, ego},
+	} {
+		nodes, edges, pages := pageAll(t, tc.q, tc.run)
+		if len(nodes) != len(snap.Nodes) || edges != len(snap.Edges) {
+			t.Errorf("%s: pages hold %d nodes and %d edges, want %d and %d", name, len(nodes), edges, len(snap.Nodes), len(snap.Edges))
+		}
+		if pages < 3 {
+			t.Errorf("%s: %d pages, want at least 3", name, pages)
+		}
+	}
+
+	// Pages are deterministic.
+	first := func() string {
+		p, _ := ParseEgoParams(url.Values{"target": {"//c:lib"}, "depth": {"10"}, "max_nodes": {"2"}})
+		return Ego(snap, p).NextToken
+	}
+	if a, b := first(), first(); a != b {
+		t.Errorf("next_token differs between calls: %s, %s", a, b)
+	}
+
+	// A token only resumes the query it came from.
+	p, _ := ParseSubgraphParams(url.Values{"root": {"//f"}, "depth": {"10"}, "max_nodes": {"3"}})
+	token := Subgraph(snap, p).NextToken
+	if _, err := ParseSubgraphParams(url.Values{"root": {"//a"}, "depth": {"10"}, "page_token": {token}}); err == nil {
+		t.Error("expected a token from another query to be rejected")
+	}
+	if _, err := ParseEgoParams(url.Values{"target": {"//a"}, "page_token": {"bogus"}}); err == nil {
+		t.Error("expected a malformed token to be rejected")
+	}
+}
@@ -4,6 +4,7 @@
 package graphquery
 
 import (
+	"slices"
 	"sort"
 	"strings"
 
@@ -15,22 +16,31 @@ type PackageNode struct {
 	Package     string   `json:"package"`
 	TargetCount int      `json:"target_count"`
 	Kinds       []string `json:"kinds"`
+	Languages   []string `json:"languages,omitempty"`
 	HasTests    bool     `json:"has_tests"`
 	IsExternal  bool     `json:"is_external"`
+	Position    *Point   `json:"position,omitempty"` // set when a layout was requested
+	Repo        string   `json:"repo,omitempty"`     // set in org graphs and for mapped external packages
 }
 
 // PackageEdge represents an aggregated edge between packages.
 type PackageEdge struct {
-	From   string `json:"from"`
-	To     string `json:"to"`
-	Weight int    `json:"weight"`
+	From      string `json:"from"`
+	To        string `json:"to"`
+	Weight    int    `json:"weight"`
+	CrossRepo bool   `json:"cross_repo,omitempty"` // set in org graphs
 }
 
 // SubgraphResult holds the result of a subgraph extraction or ego graph query.
+// A result capped at its node limit is marked truncated and, for results of
+// Subgraph and Ego, carries a NextToken that fetches the following page.
 type SubgraphResult struct {
 	Nodes     map[string]*graph.Node `json:"nodes"`
 	Edges     []graph.Edge           `json:"edges"`
 	Truncated bool                   `json:"truncated,omitempty"`
+	NextToken string                 `json:"next_token,omitempty"`
+
+	next int // position in the result order where the next page starts
 }
 
 // PackageGraphResult holds the result of a package-level graph aggregation.
@@ -51,69 +61,154 @@ type PathResult struct {
 }
 
 // ExtractSubgraph does BFS from roots to depth, collecting nodes and edges
-// in both directions. Roots support prefix matching against node keys.
-func ExtractSubgraph(snap *graph.Snapshot, roots []string, depth int) *SubgraphResult {
-	fwd := make(map[string][]graph.Edge)
-	rev := make(map[string][]graph.Edge)
-	for _, e := range snap.Edges {
-		fwd[e.From] = append(fwd[e.From], e)
-		rev[e.To] = append(rev[e.To], e)
-	}
+// in both directions. Roots support prefix matching against node keys and
+// are always included. maxNodes caps the result size (0 means 500); a capped
+// result is marked truncated, matching EgoGraph.
+func ExtractSubgraph(snap *graph.Snapshot, roots []string, depth, maxNodes int) *SubgraphResult {
+	return extractSubgraph(snap, roots, depth, maxNodes, 0)
+}
 
-	visited := make(map[string]bool)
-	queue := make([]string, 0, len(roots))
+func extractSubgraph(snap *graph.Snapshot, roots []string, depth, maxNodes, offset int) *SubgraphResult {
+	if maxNodes <= 0 {
+		maxNodes = 500
+	}
 
-	for _, r := range roots {
-		for key := range snap.Nodes {
+	ix := graph.NewIndex(snap)
+	var start []int32
+	for key := range snap.Nodes {
+		for _, r := range roots {
 			if key == r || strings.HasPrefix(key, r) {
-				if !visited[key] {
-					visited[key] = true
-					queue = append(queue, key)
-				}
+				id, _ := ix.ID(key)
+				start = append(start, id)
+				break
 			}
 		}
 	}
 
-	for d := 0; d < depth && len(queue) > 0; d++ {
-		var next []string
-		for _, node := range queue {
-			for _, e := range fwd[node] {
-				if !visited[e.To] {
-					visited[e.To] = true
-					next = append(next, e.To)
+	limit := pageLimit(maxNodes, offset, len(start))
+	order := bfsOrder(indexTraversal{ix, snap}, start, depth, true, true, offset+limit+1)
+	return paginate(snap, order, offset, limit)
+}
+
+// traversal is the structure bfsOrder walks: an Index over a loaded
+// snapshot, or a memory-mapped graph.Compact. Both number labels in sorted
+// order, so a walk visits nodes in the same order over either.
+type traversal interface {
+	Len() int
+	Key(id int32) string
+	IsNode(id int32) bool
+	Deps(id int32) []int32
+	RDeps(id int32) []int32
+}
+
+// indexTraversal adapts an Index to traversal.
+type indexTraversal struct {
+	*graph.Index
+	snap *graph.Snapshot
+}
+
+func (t indexTraversal) IsNode(id int32) bool { return t.snap.Nodes[t.Key(id)] != nil }
+
+// bfsOrder walks the graph breadth-first from start, following deps and/or
+// rdeps up to depth hops, and returns the keys of the nodes it reaches in
+// visit order. It stops once it has n. Start nodes are visited in key order
+// and neighbors in edge order, so the order is the same on every call and a
+// page of results is a fixed window of it.
+func bfsOrder(ix traversal, start []int32, depth int, deps, rdeps bool, n int) []string {
+	sort.Slice(start, func(i, j int) bool { return start[i] < start[j] })
+
+	visited := make([]bool, ix.Len())
+	var order []string
+	visit := func(id int32) bool {
+		if visited[id] {
+			return false
+		}
+		visited[id] = true
+		if ix.IsNode(id) {
+			order = append(order, ix.Key(id))
+		}
+		return true
+	}
+
+	var queue []int32
+	for _, id := range start {
+		if visit(id) {
+			queue = append(queue, id)
+		}
+	}
+	for d := 0; d < depth && len(queue) > 0 && len(order) < n; d++ {
+		var next []int32
+		for _, id := range queue {
+			if deps {
+				for _, nb := range ix.Deps(id) {
+					if visit(nb) {
+						next = append(next, nb)
+					}
 				}
 			}
-			for _, e := range rev[node] {
-				if !visited[e.From] {
-					visited[e.From] = true
-					next = append(next, e.From)
+			if rdeps {
+				for _, nb := range ix.RDeps(id) {
+					if visit(nb) {
+						next = append(next, nb)
+					}
 				}
 			}
+			if len(order) >= n {
+				break
+			}
 		}
 		queue = next
 	}
+	return order
+}
 
-	nodes := make(map[string]*graph.Node)
-	var edges []graph.Edge
+// pageLimit returns how many nodes the page starting at offset holds. The
+// first page is stretched to fit every start node.
+func pageLimit(maxNodes, offset, starts int) int {
+	if offset == 0 {
+		return max(maxNodes, starts)
+	}
+	return maxNodes
+}
 
-	for key := range visited {
-		if n, ok := snap.Nodes[key]; ok {
-			nodes[key] = n
-		}
+// paginate returns the page of order holding up to limit nodes from offset,
+// with the edges joining them to each other or to nodes of earlier pages, so
+// merging every page yields each edge exactly once. The page is truncated
+// when order continues past it.
+func paginate(snap *graph.Snapshot, order []string, offset, limit int) *SubgraphResult {
+	offset = min(offset, len(order))
+	end := min(offset+limit, len(order))
+
+	pos := make(map[string]int, end)
+	for i, key := range order[:end] {
+		pos[key] = i
 	}
+	nodes := make(map[string]*graph.Node, end-offset)
+	for _, key := range order[offset:end] {
+		nodes[key] = snap.Nodes[key]
+	}
+	var edges []graph.Edge
 	for _, e := range snap.Edges {
-		if visited[e.From] && visited[e.To] {
+		from, ok1 := pos[e.From]
+		to, ok2 := pos[e.To]
+		if ok1 && ok2 && max(from, to) >= offset {
 			edges = append(edges, e)
 		}
 	}
 
-	return &SubgraphResult{Nodes: nodes, Edges: edges}
+	return &SubgraphResult{Nodes: nodes, Edges: edges, Truncated: end < len(order), next: end}
 }
 
 // CapGraph returns a subset of the graph with at most maxNodes nodes,
-// preferring high-degree nodes (most connected = most interesting).
+// preferring high-degree nodes (most connected = most interesting). Ties are
+// broken by key so repeated calls return the same subset, and the result is
+// marked truncated when nodes were dropped.
 func CapGraph(snap *graph.Snapshot, maxNodes int) *SubgraphResult {
-	if len(snap.Nodes) <= maxNodes {
+	return capGraph(snap, maxNodes, 0)
+}
+
+func capGraph(snap *graph.Snapshot, maxNodes, offset int) *SubgraphResult {
+	if offset == 0 && len(snap.Nodes) <= maxNodes {
 		return &SubgraphResult{
 			Nodes: snap.Nodes,
 			Edges: snap.Edges,
@@ -126,42 +221,28 @@ func CapGraph(snap *graph.Snapshot, maxNodes int) *SubgraphResult {
 		degree[e.To]++
 	}
 
-	type ranked struct {
-		key string
-		deg int
-	}
-	var rankedNodes []ranked
+	order := make([]string, 0, len(snap.Nodes))
 	for key := range snap.Nodes {
-		rankedNodes = append(rankedNodes, ranked{key, degree[key]})
+		order = append(order, key)
 	}
-	sort.Slice(rankedNodes, func(i, j int) bool {
-		return rankedNodes[i].deg > rankedNodes[j].deg
-	})
-
-	keep := make(map[string]bool)
-	for i := 0; i < maxNodes && i < len(rankedNodes); i++ {
-		keep[rankedNodes[i].key] = true
-	}
-
-	nodes := make(map[string]*graph.Node)
-	for key := range keep {
-		nodes[key] = snap.Nodes[key]
-	}
-
-	var edges []graph.Edge
-	for _, e := range snap.Edges {
-		if keep[e.From] && keep[e.To] {
-			edges = append(edges, e)
+	sort.Slice(order, func(i, j int) bool {
+		if degree[order[i]] != degree[order[j]] {
+			return degree[order[i]] > degree[order[j]]
 		}
-	}
+		return order[i] < order[j]
+	})
 
-	return &SubgraphResult{Nodes: nodes, Edges: edges}
+	return paginate(snap, order, offset, maxNodes)
 }
 
 // EgoGraph computes the ego graph (neighborhood) of a target node with
 // directional control. Direction can be "deps", "rdeps", or "both".
-// maxNodes caps the result size (0 means no cap).
+// maxNodes caps the result size (0 means 500).
 func EgoGraph(snap *graph.Snapshot, target string, depth int, direction string, maxNodes int) *SubgraphResult {
+	return egoGraph(snap, target, depth, direction, maxNodes, 0)
+}
+
+func egoGraph(snap *graph.Snapshot, target string, depth int, direction string, maxNodes, offset int) *SubgraphResult {
 	if direction == "" {
 		direction = "both"
 	}
@@ -169,93 +250,41 @@ func EgoGraph(snap *graph.Snapshot, target string, depth int, direction string,
 		maxNodes = 500
 	}
 
-	fwd := make(map[string][]graph.Edge)
-	rev := make(map[string][]graph.Edge)
-	for _, e := range snap.Edges {
-		fwd[e.From] = append(fwd[e.From], e)
-		rev[e.To] = append(rev[e.To], e)
-	}
-
-	// Find matching root nodes (exact or prefix match)
-	visited := make(map[string]bool)
-	var queue []string
-	for key := range snap.Nodes {
-		if key == target || strings.HasPrefix(key, target+":") || strings.HasPrefix(key, target+"/") {
-			if !visited[key] {
-				visited[key] = true
-				queue = append(queue, key)
-			}
-		}
-	}
-
-	// Also match as package
-	if len(queue) == 0 {
-		for key, node := range snap.Nodes {
-			if node.Package == target {
-				if !visited[key] {
-					visited[key] = true
-					queue = append(queue, key)
-				}
-			}
-		}
-	}
-
-	if len(queue) == 0 {
+	ix := graph.NewIndex(snap)
+	start := matchNodes(snap, ix, target)
+	if len(start) == 0 {
 		return &SubgraphResult{
 			Nodes: map[string]*graph.Node{},
 			Edges: []graph.Edge{},
 		}
 	}
 
-	truncated := false
-
-	for d := 0; d < depth && len(queue) > 0; d++ {
-		var next []string
-		for _, node := range queue {
-			if direction == "deps" || direction == "both" {
-				for _, e := range fwd[node] {
-					if !visited[e.To] {
-						visited[e.To] = true
-						next = append(next, e.To)
-					}
-				}
-			}
-			if direction == "rdeps" || direction == "both" {
-				for _, e := range rev[node] {
-					if !visited[e.From] {
-						visited[e.From] = true
-						next = append(next, e.From)
-					}
-				}
-			}
-		}
-		queue = next
-
-		if len(visited) >= maxNodes {
-			truncated = true
-			break
-		}
-	}
+	limit := pageLimit(maxNodes, offset, len(start))
+	deps, rdeps := direction == "deps" || direction == "both", direction == "rdeps" || direction == "both"
+	order := bfsOrder(indexTraversal{ix, snap}, start, depth, deps, rdeps, offset+limit+1)
+	return paginate(snap, order, offset, limit)
+}
 
-	nodes := make(map[string]*graph.Node)
-	for key := range visited {
-		if n, ok := snap.Nodes[key]; ok {
-			nodes[key] = n
+// matchNodes resolves a target query: the node named query, the nodes under
+// it (query followed by ":" or "/"), or failing those the nodes of the
+// package named query.
+func matchNodes(snap *graph.Snapshot, ix *graph.Index, query string) []int32 {
+	var matches []int32
+	for key := range snap.Nodes {
+		if key == query || strings.HasPrefix(key, query+":") || strings.HasPrefix(key, query+"/") {
+			id, _ := ix.ID(key)
+			matches = append(matches, id)
 		}
 	}
-
-	var edges []graph.Edge
-	for _, e := range snap.Edges {
-		if visited[e.From] && visited[e.To] {
-			edges = append(edges, e)
+	if len(matches) == 0 {
+		for key, node := range snap.Nodes {
+			if node.Package == query {
+				id, _ := ix.ID(key)
+				matches = append(matches, id)
+			}
 		}
 	}
-
-	return &SubgraphResult{
-		Nodes:     nodes,
-		Edges:     edges,
-		Truncated: truncated,
-	}
+	return matches
 }
 
 // FindPaths finds all shortest paths between from and to node queries.
@@ -265,30 +294,9 @@ func FindPaths(snap *graph.Snapshot, fromQ, toQ string, maxPaths int) *PathResul
 		maxPaths = 10
 	}
 
-	fwd := make(map[string][]string)
-	for _, e := range snap.Edges {
-		fwd[e.From] = append(fwd[e.From], e.To)
-	}
-
-	resolveNodes := func(query string) []string {
-		var matches []string
-		for key := range snap.Nodes {
-			if key == query || strings.HasPrefix(key, query+":") || strings.HasPrefix(key, query+"/") {
-				matches = append(matches, key)
-			}
-		}
-		if len(matches) == 0 {
-			for key, node := range snap.Nodes {
-				if node.Package == query {
-					matches = append(matches, key)
-				}
-			}
-		}
-		return matches
-	}
-
-	fromNodes := resolveNodes(fromQ)
-	toNodes := resolveNodes(toQ)
+	ix := graph.NewIndex(snap)
+	fromNodes := matchNodes(snap, ix, fromQ)
+	toNodes := matchNodes(snap, ix, toQ)
 
 	emptyResult := &PathResult{
 		Paths:      [][]string{},
@@ -303,17 +311,20 @@ func FindPaths(snap *graph.Snapshot, fromQ, toQ string, maxPaths int) *PathResul
 		return emptyResult
 	}
 
-	toSet := make(map[string]bool)
+	toSet := make([]bool, ix.Len())
 	for _, n := range toNodes {
 		toSet[n] = true
 	}
 
 	type bfsEntry struct {
-		node  string
+		node  int32
 		depth int
 	}
-	parents := make(map[string][]string)
-	dist := make(map[string]int)
+	parents := make(map[int32][]int32)
+	dist := make([]int, ix.Len())
+	for i := range dist {
+		dist[i] = -1
+	}
 
 	var queue []bfsEntry
 	for _, n := range fromNodes {
@@ -335,11 +346,11 @@ func FindPaths(snap *graph.Snapshot, fromQ, toQ string, maxPaths int) *PathResul
 			foundDepth = curr.depth
 		}
 
-		for _, neighbor := range fwd[curr.node] {
+		for _, neighbor := range ix.Deps(curr.node) {
 			nextDepth := curr.depth + 1
-			if _, seen := dist[neighbor]; !seen {
+			if dist[neighbor] < 0 {
 				dist[neighbor] = nextDepth
-				parents[neighbor] = []string{curr.node}
+				parents[neighbor] = []int32{curr.node}
 				queue = append(queue, bfsEntry{neighbor, nextDepth})
 			} else if dist[neighbor] == nextDepth {
 				parents[neighbor] = append(parents[neighbor], curr.node)
@@ -347,9 +358,9 @@ func FindPaths(snap *graph.Snapshot, fromQ, toQ string, maxPaths int) *PathResul
 		}
 	}
 
-	var reachedTargets []string
+	var reachedTargets []int32
 	for _, n := range toNodes {
-		if _, ok := dist[n]; ok {
+		if dist[n] >= 0 {
 			reachedTargets = append(reachedTargets, n)
 		}
 	}
@@ -358,19 +369,19 @@ func FindPaths(snap *graph.Snapshot, fromQ, toQ string, maxPaths int) *PathResul
 		return emptyResult
 	}
 
-	fromSet := make(map[string]bool)
+	fromSet := make([]bool, ix.Len())
 	for _, n := range fromNodes {
 		fromSet[n] = true
 	}
 
 	var allPaths [][]string
-	var backtrack func(node string, path []string)
-	backtrack = func(node string, path []string) {
+	var backtrack func(node int32, path []string)
+	backtrack = func(node int32, path []string) {
 		if len(allPaths) >= maxPaths {
 			return
 		}
 		current := make([]string, len(path)+1)
-		current[0] = node
+		current[0] = ix.Key(node)
 		copy(current[1:], path)
 
 		if fromSet[node] {
@@ -456,6 +467,7 @@ func AggregatePackages(snap *graph.Snapshot, hideTests, hideExternal bool, minEd
 			pn = &PackageNode{
 				Package:    pkg,
 				IsExternal: node.IsExternal,
+				Repo:       node.Repo,
 			}
 			pkgNodes[pkg] = pn
 		}
@@ -473,6 +485,9 @@ func AggregatePackages(snap *graph.Snapshot, hideTests, hideExternal bool, minEd
 		if !found {
 			pn.Kinds = append(pn.Kinds, node.Kind)
 		}
+		if lang := graph.LanguageOf(node); lang != "" && !slices.Contains(pn.Languages, lang) {
+			pn.Languages = append(pn.Languages, lang)
+		}
 	}
 
 	includedTargets := make(map[string]bool)
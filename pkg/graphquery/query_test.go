@@ -34,7 +34,7 @@ func TestExtractSubgraph(t *testing.T) {
 	snap := testSnapshot()
 
 	t.Run("single root depth 1", func(t *testing.T) {
-		result := ExtractSubgraph(snap, []string{"//b:lib"}, 1)
+		result := ExtractSubgraph(snap, []string{"//b:lib"}, 1, 0)
 		if _, ok := result.Nodes["//b:lib"]; !ok {
 			t.Error("expected root node //b:lib in result")
 		}
@@ -50,11 +50,26 @@ func TestExtractSubgraph(t *testing.T) {
 	})
 
 	t.Run("prefix matching", func(t *testing.T) {
-		result := ExtractSubgraph(snap, []string{"//f"}, 0)
+		result := ExtractSubgraph(snap, []string{"//f"}, 0, 0)
 		if len(result.Nodes) != 2 {
 			t.Errorf("expected 2 nodes matching //f prefix, got %d", len(result.Nodes))
 		}
 	})
+
+	t.Run("capped keeps roots", func(t *testing.T) {
+		result := ExtractSubgraph(snap, []string{"//f"}, 5, 3)
+		if !result.Truncated {
+			t.Error("expected truncated result")
+		}
+		for _, key := range []string{"//f:lib", "//f:sub/inner"} {
+			if _, ok := result.Nodes[key]; !ok {
+				t.Errorf("expected root %s in capped result", key)
+			}
+		}
+		if _, ok := result.Nodes["//d:lib"]; ok {
+			t.Error("did not expect expansion past the cap")
+		}
+	})
 }
 
 func TestCapGraph(t *testing.T) {
@@ -65,6 +80,9 @@ func TestCapGraph(t *testing.T) {
 		if len(result.Nodes) != len(snap.Nodes) {
 			t.Errorf("expected all %d nodes, got %d", len(snap.Nodes), len(result.Nodes))
 		}
+		if result.Truncated {
+			t.Error("did not expect truncation under the limit")
+		}
 	})
 
 	t.Run("capped", func(t *testing.T) {
@@ -72,6 +90,9 @@ func TestCapGraph(t *testing.T) {
 		if len(result.Nodes) != 3 {
 			t.Errorf("expected 3 nodes, got %d", len(result.Nodes))
 		}
+		if !result.Truncated {
+			t.Error("expected truncated result")
+		}
 		// Verify only edges between kept nodes
 		for _, e := range result.Edges {
 			if _, ok := result.Nodes[e.From]; !ok {
@@ -0,0 +1,125 @@
+package graphquery
+
+import (
+	"encoding/binary"
+	"hash/fnv"
+	"math/bits"
+	"sort"
+
+	"github.com/toposcope/toposcope/pkg/graph"
+)
+
+// SampleGraph returns a subset of the graph with at most maxNodes nodes
+// whose degree distribution follows the full graph's. Unlike CapGraph, which
+// keeps only the hubs, it also keeps leaves and other periphery nodes, so an
+// overview drawn from it looks like the whole graph.
+//
+// Nodes are grouped by degree on a log2 scale. Every group gets at least one
+// node, and the rest of maxNodes is shared in proportion to group size.
+// Within a group, nodes are picked by a hash of their key and seed, so the
+// same seed always returns the same sample. The result is marked truncated
+// when nodes were dropped.
+func SampleGraph(snap *graph.Snapshot, maxNodes int, seed int64) *SubgraphResult {
+	if len(snap.Nodes) <= maxNodes {
+		return &SubgraphResult{
+			Nodes: snap.Nodes,
+			Edges: snap.Edges,
+		}
+	}
+
+	degree := make(map[string]int)
+	for _, e := range snap.Edges {
+		degree[e.From]++
+		degree[e.To]++
+	}
+
+	var groups [][]string
+	for key := range snap.Nodes {
+		g := bits.Len(uint(degree[key]))
+		for len(groups) <= g {
+			groups = append(groups, nil)
+		}
+		groups[g] = append(groups[g], key)
+	}
+	var nonEmpty [][]string
+	for _, g := range groups {
+		if len(g) > 0 {
+			nonEmpty = append(nonEmpty, g)
+		}
+	}
+
+	// Sort each group by hash so taking a prefix is a seeded random pick.
+	var buf [8]byte
+	binary.LittleEndian.PutUint64(buf[:], uint64(seed))
+	hashes := make(map[string]uint64, len(snap.Nodes))
+	for key := range snap.Nodes {
+		h := fnv.New64a()
+		h.Write(buf[:])
+		h.Write([]byte(key))
+		hashes[key] = h.Sum64()
+	}
+	for _, g := range nonEmpty {
+		sort.Slice(g, func(i, j int) bool {
+			if hashes[g[i]] != hashes[g[j]] {
+				return hashes[g[i]] < hashes[g[j]]
+			}
+			return g[i] < g[j]
+		})
+	}
+
+	quota := sampleQuotas(nonEmpty, maxNodes, len(snap.Nodes))
+	nodes := make(map[string]*graph.Node, maxNodes)
+	for i, g := range nonEmpty {
+		for _, key := range g[:quota[i]] {
+			nodes[key] = snap.Nodes[key]
+		}
+	}
+	var edges []graph.Edge
+	for _, e := range snap.Edges {
+		if nodes[e.From] != nil && nodes[e.To] != nil {
+			edges = append(edges, e)
+		}
+	}
+
+	return &SubgraphResult{Nodes: nodes, Edges: edges, Truncated: true}
+}
+
+// sampleQuotas splits maxNodes across degree groups, which are ordered from
+// lowest to highest degree. Each group gets one node, highest degree first
+// when there are more groups than nodes. The rest are shared by largest
+// remainder in proportion to how many nodes each group has left.
+func sampleQuotas(groups [][]string, maxNodes, total int) []int {
+	quota := make([]int, len(groups))
+	left := maxNodes
+	for i := len(groups) - 1; i >= 0 && left > 0; i-- {
+		quota[i] = 1
+		left--
+	}
+	rest := total - len(groups)
+	if left == 0 || rest <= 0 {
+		return quota
+	}
+
+	rem := make([]int, len(groups))
+	for i, g := range groups {
+		n := left * (len(g) - 1)
+		quota[i] += n / rest
+		rem[i] = n % rest
+		maxNodes -= quota[i]
+	}
+	order := make([]int, len(groups))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool { return rem[order[a]] > rem[order[b]] })
+	for _, i := range order {
+		if maxNodes == 0 {
+			break
+		}
+		if quota[i] < len(groups[i]) {
+			quota[i]++
+			maxNodes--
+		}
+	}
+	return quota
+}
@@ -0,0 +1,96 @@
+package graphquery
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/toposcope/toposcope/pkg/graph"
+)
+
+// hubSnapshot has 5 connected hubs with 10 leaves each, plus 10 isolated
+// targets.
+func hubSnapshot() *graph.Snapshot {
+	snap := &graph.Snapshot{Nodes: map[string]*graph.Node{}}
+	add := func(key string) { snap.Nodes[key] = &graph.Node{Key: key} }
+	for h := 0; h < 5; h++ {
+		hub := fmt.Sprintf("//hub%d:lib", h)
+		add(hub)
+		for o := 0; o < h; o++ {
+			snap.Edges = append(snap.Edges, graph.Edge{From: hub, To: fmt.Sprintf("//hub%d:lib", o), Type: "COMPILE"})
+		}
+		for l := 0; l < 10; l++ {
+			leaf := fmt.Sprintf("//hub%d/leaf:l%d", h, l)
+			add(leaf)
+			snap.Edges = append(snap.Edges, graph.Edge{From: hub, To: leaf, Type: "COMPILE"})
+		}
+	}
+	for i := 0; i < 10; i++ {
+		add(fmt.Sprintf("//lone:t%d", i))
+	}
+	return snap
+}
+
+func TestSampleGraph(t *testing.T) {
+	snap := hubSnapshot()
+
+	t.Run("under limit", func(t *testing.T) {
+		result := SampleGraph(snap, 100, 0)
+		if len(result.Nodes) != len(snap.Nodes) || result.Truncated {
+			t.Errorf("expected all %d nodes untruncated, got %d (truncated=%v)", len(snap.Nodes), len(result.Nodes), result.Truncated)
+		}
+	})
+
+	t.Run("sampled", func(t *testing.T) {
+		result := SampleGraph(snap, 10, 0)
+		if len(result.Nodes) != 10 {
+			t.Fatalf("expected 10 nodes, got %d", len(result.Nodes))
+		}
+		if !result.Truncated {
+			t.Error("expected truncated result")
+		}
+
+		degree := map[string]int{}
+		for _, e := range snap.Edges {
+			degree[e.From]++
+			degree[e.To]++
+		}
+		counts := map[int]int{}
+		for key := range result.Nodes {
+			counts[degree[key]]++
+		}
+		// 10 isolated, 50 leaves, and 5 hubs share 10 slots.
+		if counts[0] != 2 || counts[1] != 7 || counts[14] != 1 {
+			t.Errorf("unexpected degree mix: %v", counts)
+		}
+
+		for _, e := range result.Edges {
+			if result.Nodes[e.From] == nil || result.Nodes[e.To] == nil {
+				t.Errorf("edge %s -> %s has an endpoint outside the sample", e.From, e.To)
+			}
+		}
+	})
+
+	t.Run("seeded", func(t *testing.T) {
+		a, b := SampleGraph(snap, 10, 1), SampleGraph(snap, 10, 1)
+		if !reflect.DeepEqual(a, b) {
+			t.Error("expected the same sample for the same seed")
+		}
+		if reflect.DeepEqual(a, SampleGraph(snap, 10, 2)) {
+			t.Error("expected a different sample for a different seed")
+		}
+	})
+
+	t.Run("fewer slots than groups", func(t *testing.T) {
+		result := SampleGraph(snap, 1, 0)
+		if len(result.Nodes) != 1 {
+			t.Fatalf("expected 1 node, got %d", len(result.Nodes))
+		}
+		for key := range result.Nodes {
+			if !strings.HasSuffix(key, ":lib") {
+				t.Errorf("expected a hub, got %s", key)
+			}
+		}
+	})
+}
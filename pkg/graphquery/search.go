@@ -0,0 +1,74 @@
+package graphquery
+
+import (
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/toposcope/toposcope/pkg/graph"
+)
+
+// SearchResult holds the targets matching a search, in label order.
+type SearchResult struct {
+	Nodes     []*graph.Node `json:"nodes"`
+	Total     int           `json:"total"` // matches before the limit
+	Truncated bool          `json:"truncated"`
+}
+
+// SearchNodes returns up to limit targets whose label contains query
+// (ignoring case), optionally restricted to rule classes in kinds and
+// languages in languages. An empty query matches every target. Exact label
+// matches come first.
+func SearchNodes(snap *graph.Snapshot, query string, kinds, languages []string, limit int) *SearchResult {
+	q := strings.ToLower(query)
+	var matches []*graph.Node
+	for _, n := range snap.Nodes {
+		if len(kinds) > 0 && !slices.Contains(kinds, n.Kind) {
+			continue
+		}
+		if len(languages) > 0 && !slices.Contains(languages, graph.LanguageOf(n)) {
+			continue
+		}
+		if strings.Contains(strings.ToLower(n.Key), q) {
+			matches = append(matches, n)
+		}
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		if ei, ej := matches[i].Key == query, matches[j].Key == query; ei != ej {
+			return ei
+		}
+		return matches[i].Key < matches[j].Key
+	})
+
+	result := &SearchResult{Nodes: matches, Total: len(matches)}
+	if result.Nodes == nil {
+		result.Nodes = []*graph.Node{}
+	}
+	if limit > 0 && len(matches) > limit {
+		result.Nodes, result.Truncated = matches[:limit], true
+	}
+	return result
+}
+
+// FilterLanguages returns a copy of snap with only the targets in the given
+// languages, and the edges between them. snap is returned as is when
+// languages is empty.
+func FilterLanguages(snap *graph.Snapshot, languages []string) *graph.Snapshot {
+	if len(languages) == 0 {
+		return snap
+	}
+	out := *snap
+	out.Nodes = make(map[string]*graph.Node)
+	for k, n := range snap.Nodes {
+		if slices.Contains(languages, graph.LanguageOf(n)) {
+			out.Nodes[k] = n
+		}
+	}
+	out.Edges = nil
+	for _, e := range snap.Edges {
+		if out.Nodes[e.From] != nil && out.Nodes[e.To] != nil {
+			out.Edges = append(out.Edges, e)
+		}
+	}
+	return &out
+}
@@ -0,0 +1,85 @@
+package graphquery
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/toposcope/toposcope/pkg/graph"
+)
+
+func languageSnapshot() *graph.Snapshot {
+	return &graph.Snapshot{
+		Nodes: map[string]*graph.Node{
+			"//svc:server":  {Key: "//svc:server", Kind: "go_binary", Package: "//svc"},
+			"//svc:client":  {Key: "//svc:client", Kind: "java_library", Package: "//svc"},
+			"//lib:util":    {Key: "//lib:util", Kind: "go_library", Package: "//lib"},
+			"//lib:native":  {Key: "//lib:native", Kind: "cc_library", Package: "//lib"},
+			"//tools:build": {Key: "//tools:build", Kind: "genrule", Package: "//tools"},
+		},
+		Edges: []graph.Edge{
+			{From: "//svc:server", To: "//lib:util", Type: "COMPILE"},
+			{From: "//svc:client", To: "//lib:native", Type: "COMPILE"},
+			{From: "//lib:util", To: "//lib:native", Type: "COMPILE"},
+		},
+	}
+}
+
+func TestSearchNodes(t *testing.T) {
+	snap := languageSnapshot()
+
+	res := SearchNodes(snap, "LIB", nil, nil, 0)
+	if res.Total != 2 || res.Nodes[0].Key != "//lib:native" || res.Nodes[1].Key != "//lib:util" {
+		t.Errorf("search for LIB = %+v", res)
+	}
+
+	res = SearchNodes(snap, "", nil, []string{"go"}, 0)
+	if res.Total != 2 {
+		t.Errorf("go targets = %d, want 2", res.Total)
+	}
+
+	res = SearchNodes(snap, "//svc", []string{"java_library"}, nil, 0)
+	if res.Total != 1 || res.Nodes[0].Key != "//svc:client" {
+		t.Errorf("java_library in //svc = %+v", res.Nodes)
+	}
+
+	res = SearchNodes(snap, "", nil, nil, 2)
+	if res.Total != 5 || len(res.Nodes) != 2 || !res.Truncated {
+		t.Errorf("limited search: total %d, %d nodes, truncated %v", res.Total, len(res.Nodes), res.Truncated)
+	}
+
+	// An exact label sorts first.
+	snap.Nodes["//lib:util_test"] = &graph.Node{Key: "//lib:util_test", Kind: "go_test", Package: "//lib"}
+	snap.Nodes["//a:uses_lib:util"] = &graph.Node{Key: "//a:uses_lib:util", Kind: "go_library", Package: "//a"}
+	res = SearchNodes(snap, "//lib:util", nil, nil, 0)
+	if res.Nodes[0].Key != "//lib:util" {
+		t.Errorf("first match = %s, want the exact label", res.Nodes[0].Key)
+	}
+
+	if res := SearchNodes(snap, "nothing", nil, nil, 0); res.Nodes == nil || res.Total != 0 {
+		t.Errorf("empty search = %+v", res)
+	}
+}
+
+func TestPackagesLanguageFilter(t *testing.T) {
+	snap := languageSnapshot()
+
+	all := Packages(snap, ParsePackageParams(url.Values{}))
+	if langs := all.Nodes["//lib"].Languages; len(langs) != 2 {
+		t.Errorf("//lib languages = %v, want cc and go", langs)
+	}
+
+	goOnly := Packages(snap, ParsePackageParams(url.Values{"language": {"go"}}))
+	if len(goOnly.Nodes) != 2 || goOnly.Nodes["//tools"] != nil {
+		t.Errorf("go packages = %v", goOnly.Nodes)
+	}
+	if n := goOnly.Nodes["//lib"]; n == nil || n.TargetCount != 1 {
+		t.Errorf("//lib in the go graph = %+v", n)
+	}
+	if len(goOnly.Edges) != 1 || goOnly.Edges[0].From != "//svc" || goOnly.Edges[0].To != "//lib" {
+		t.Errorf("go package edges = %+v", goOnly.Edges)
+	}
+
+	if FilterLanguages(snap, nil) != snap {
+		t.Error("FilterLanguages without languages should return the snapshot")
+	}
+}
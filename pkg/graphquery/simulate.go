@@ -0,0 +1,127 @@
+package graphquery
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/toposcope/toposcope/pkg/graph"
+)
+
+// EdgeChanges are hypothetical edits to a snapshot's edges.
+type EdgeChanges struct {
+	// Add lists edges to add between existing targets. The type defaults
+	// to COMPILE.
+	Add []graph.Edge `json:"add,omitempty"`
+	// Remove lists edges to drop. An edge without a type drops every edge
+	// between its endpoints.
+	Remove []graph.Edge `json:"remove,omitempty"`
+}
+
+// CycleChange compares the dependency cycles (strongly connected
+// components) of two graphs. A cycle that grows or shrinks shows up as
+// removed in its old form and added in its new one.
+type CycleChange struct {
+	Base    int        `json:"base"`
+	Head    int        `json:"head"`
+	Added   [][]string `json:"added"`
+	Removed [][]string `json:"removed"`
+}
+
+// Simulation is the structural effect of hypothetical edge changes.
+type Simulation struct {
+	AddedEdges   int                  `json:"added_edges"`
+	RemovedEdges int                  `json:"removed_edges"`
+	FanIn        []DegreeChange       `json:"fan_in"`
+	FanOut       []DegreeChange       `json:"fan_out"`
+	Boundaries   []BoundaryEdgeChange `json:"boundaries"`
+	Cycles       CycleChange          `json:"cycles"`
+
+	// Head is the snapshot with the changes applied, for scoring.
+	Head *graph.Snapshot `json:"-"`
+}
+
+// Simulate applies changes to a copy of snap and reports how the graph
+// would move: every fan-in and fan-out change, cross-boundary edge counts,
+// and cycles. snap itself is not modified. It fails if an added edge names
+// an unknown target or already exists, or a removed edge doesn't exist.
+func Simulate(snap *graph.Snapshot, changes EdgeChanges) (*Simulation, error) {
+	head := *snap
+	head.Edges = nil
+	sim := &Simulation{Head: &head}
+
+	type edgeKey struct{ from, to, typ string }
+	remove := make(map[edgeKey]bool, len(changes.Remove))
+	removed := make(map[edgeKey]bool, len(changes.Remove))
+	for _, e := range changes.Remove {
+		remove[edgeKey{e.From, e.To, e.Type}] = true
+	}
+	existing := make(map[edgeKey]bool, len(snap.Edges))
+	for _, e := range snap.Edges {
+		existing[edgeKey{e.From, e.To, e.Type}] = true
+		exact, untyped := edgeKey{e.From, e.To, e.Type}, edgeKey{e.From, e.To, ""}
+		if remove[exact] || remove[untyped] {
+			removed[exact], removed[untyped] = true, true
+			sim.RemovedEdges++
+			continue
+		}
+		head.Edges = append(head.Edges, e)
+	}
+	for _, e := range changes.Remove {
+		if !removed[edgeKey{e.From, e.To, e.Type}] {
+			return nil, fmt.Errorf("cannot remove %s: no such edge", describeEdge(e))
+		}
+	}
+
+	for _, e := range changes.Add {
+		if e.Type == "" {
+			e.Type = "COMPILE"
+		}
+		for _, key := range []string{e.From, e.To} {
+			if snap.Nodes[key] == nil {
+				return nil, fmt.Errorf("cannot add %s: unknown target %s", describeEdge(e), key)
+			}
+		}
+		k := edgeKey{e.From, e.To, e.Type}
+		if existing[k] {
+			return nil, fmt.Errorf("cannot add %s: edge already exists", describeEdge(e))
+		}
+		existing[k] = true
+		head.Edges = append(head.Edges, e)
+		sim.AddedEdges++
+	}
+
+	cmp := Compare(snap, &head, len(snap.Nodes))
+	sim.FanIn, sim.FanOut, sim.Boundaries = cmp.FanIn, cmp.FanOut, cmp.Boundaries
+	sim.Cycles = compareCycles(FindCycles(snap), FindCycles(&head))
+	return sim, nil
+}
+
+func describeEdge(e graph.Edge) string {
+	s := e.From + " -> " + e.To
+	if e.Type != "" {
+		s += " (" + e.Type + ")"
+	}
+	return s
+}
+
+func compareCycles(base, head [][]string) CycleChange {
+	c := CycleChange{Base: len(base), Head: len(head), Added: [][]string{}, Removed: [][]string{}}
+	id := func(comp []string) string { return strings.Join(comp, "\x00") }
+	inBase := make(map[string]bool, len(base))
+	for _, comp := range base {
+		inBase[id(comp)] = true
+	}
+	inHead := make(map[string]bool, len(head))
+	for _, comp := range head {
+		inHead[id(comp)] = true
+		if !inBase[id(comp)] {
+			c.Added = append(c.Added, comp)
+		}
+	}
+	for _, comp := range base {
+		if !inHead[id(comp)] {
+			c.Removed = append(c.Removed, comp)
+		}
+	}
+	return c
+}
@@ -0,0 +1,56 @@
+package graphquery
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/toposcope/toposcope/pkg/graph"
+)
+
+func TestSimulate(t *testing.T) {
+	snap := testSnapshot()
+	edges := len(snap.Edges)
+
+	sim, err := Simulate(snap, EdgeChanges{
+		Add:    []graph.Edge{{From: "//d:lib", To: "//b:lib"}},
+		Remove: []graph.Edge{{From: "//f:lib", To: "//a:lib"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(snap.Edges) != edges {
+		t.Error("Simulate modified the snapshot")
+	}
+	if sim.AddedEdges != 1 || sim.RemovedEdges != 1 || len(sim.Head.Edges) != edges {
+		t.Errorf("added %d, removed %d, head has %d edges", sim.AddedEdges, sim.RemovedEdges, len(sim.Head.Edges))
+	}
+	if got := sim.Head.Edges[len(sim.Head.Edges)-1]; got.Type != "COMPILE" {
+		t.Errorf("added edge type = %q, want COMPILE", got.Type)
+	}
+
+	want := CycleChange{Base: 0, Head: 1, Added: [][]string{{"//b:lib", "//c:lib", "//d:lib"}}, Removed: [][]string{}}
+	if !reflect.DeepEqual(sim.Cycles, want) {
+		t.Errorf("cycles = %+v, want %+v", sim.Cycles, want)
+	}
+	var fanIn []string
+	for _, c := range sim.FanIn {
+		fanIn = append(fanIn, c.Key)
+	}
+	if !reflect.DeepEqual(fanIn, []string{"//a:lib", "//b:lib"}) {
+		t.Errorf("fan-in changes = %v", fanIn)
+	}
+}
+
+func TestSimulateRejects(t *testing.T) {
+	snap := testSnapshot()
+	for name, changes := range map[string]EdgeChanges{
+		"unknown target": {Add: []graph.Edge{{From: "//a:lib", To: "//nope:lib"}}},
+		"existing edge":  {Add: []graph.Edge{{From: "//a:lib", To: "//b:lib", Type: "COMPILE"}}},
+		"missing edge":   {Remove: []graph.Edge{{From: "//b:lib", To: "//a:lib"}}},
+		"wrong type":     {Remove: []graph.Edge{{From: "//a:lib", To: "//b:lib", Type: "RUNTIME"}}},
+	} {
+		if _, err := Simulate(snap, changes); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
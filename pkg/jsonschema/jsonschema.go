@@ -0,0 +1,263 @@
+// Package jsonschema generates JSON Schemas (draft 2020-12) from the Go types
+// that make up Toposcope's output, so external tools can validate and codegen
+// against snapshots, deltas, and score results. The config file schema lets
+// editors check .toposcope/config.yaml.
+package jsonschema
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/toposcope/toposcope/pkg/config"
+	"github.com/toposcope/toposcope/pkg/graph"
+	"github.com/toposcope/toposcope/pkg/scoring"
+)
+
+// Draft is the JSON Schema dialect of generated schemas.
+const Draft = "https://json-schema.org/draft/2020-12/schema"
+
+// BaseID prefixes the $id of the canonical schemas.
+const BaseID = "https://github.com/toposcope/toposcope/schemas/"
+
+// Schema is a JSON Schema document or subschema.
+type Schema struct {
+	Schema               string             `json:"$schema,omitempty"`
+	ID                   string             `json:"$id,omitempty"`
+	Title                string             `json:"title,omitempty"`
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 any                `json:"type,omitempty"` // string, or []string when nullable
+	Format               string             `json:"format,omitempty"`
+	Enum                 []string           `json:"enum,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Not                  *Schema            `json:"not,omitempty"`
+	Defs                 map[string]*Schema `json:"$defs,omitempty"`
+}
+
+// Canonical lists the published schemas by name, in a stable order.
+var Canonical = []struct {
+	Name  string
+	Title string
+	Value any
+	Input bool // a YAML input file: yaml tags, and no properties beyond them
+}{
+	{"snapshot", "Snapshot", graph.Snapshot{}, false},
+	{"delta", "Delta", graph.Delta{}, false},
+	{"score", "ScoreResult", scoring.ScoreResult{}, false},
+	{"config", "Config", config.Config{}, true},
+}
+
+// enums lists the allowed values of string types that are used as enums.
+var enums = map[reflect.Type][]string{
+	reflect.TypeOf(scoring.Severity("")): {
+		string(scoring.SeverityHigh), string(scoring.SeverityMedium),
+		string(scoring.SeverityLow), string(scoring.SeverityInfo),
+	},
+	reflect.TypeOf(scoring.Normalization("")): {
+		string(scoring.NormalizationNone), string(scoring.NormalizationSize),
+	},
+}
+
+// For returns the canonical schema with the given name.
+func For(name string) (*Schema, error) {
+	for _, c := range Canonical {
+		if c.Name == name {
+			s := Generate(c.Value)
+			if c.Input {
+				s = GenerateInput(c.Value)
+			}
+			s.ID = BaseID + c.Name + ".schema.json"
+			s.Title = c.Title
+			return s, nil
+		}
+	}
+	names := make([]string, len(Canonical))
+	for i, c := range Canonical {
+		names[i] = c.Name
+	}
+	return nil, fmt.Errorf("unknown schema %q (want one of: %s)", name, strings.Join(names, ", "))
+}
+
+// Generate builds a schema for v's type. Named struct types other than the
+// root are emitted once under $defs and referenced.
+func Generate(v any) *Schema {
+	g := newGenerator("#/$defs/")
+	t := reflect.TypeOf(v)
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	root := g.structSchema(t)
+	root.Schema = Draft
+	if len(g.defs) > 0 {
+		root.Defs = g.defs
+	}
+	return root
+}
+
+// GenerateInput builds a schema for v's type as read from YAML: properties
+// are named by yaml tags, none are required, and objects reject properties
+// they don't declare.
+func GenerateInput(v any) *Schema {
+	g := newGenerator("#/$defs/")
+	g.tag, g.closed = "yaml", true
+	t := reflect.TypeOf(v)
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	root := g.structSchema(t)
+	root.Schema = Draft
+	if len(g.defs) > 0 {
+		root.Defs = g.defs
+	}
+	return root
+}
+
+// Components generates schemas for many types into one shared set of
+// definitions, such as the components/schemas section of an OpenAPI
+// document.
+type Components struct {
+	g *generator
+}
+
+// NewComponents returns an empty set of definitions. References to them
+// are refPrefix followed by the definition name.
+func NewComponents(refPrefix string) *Components {
+	return &Components{g: newGenerator(refPrefix)}
+}
+
+// Schema returns the schema for v's type. Named struct types are added to
+// the definitions and referenced.
+func (c *Components) Schema(v any) *Schema {
+	return c.g.schemaFor(reflect.TypeOf(v))
+}
+
+// Defs returns the definitions generated so far, by name.
+func (c *Components) Defs() map[string]*Schema {
+	return c.g.defs
+}
+
+type generator struct {
+	refPrefix string
+	defs      map[string]*Schema
+	names     map[reflect.Type]string
+	tag       string // struct tag naming properties
+	closed    bool   // input schema: closed objects, nothing required
+}
+
+func newGenerator(refPrefix string) *generator {
+	return &generator{refPrefix: refPrefix, defs: make(map[string]*Schema), names: make(map[reflect.Type]string), tag: "json"}
+}
+
+var (
+	timeType       = reflect.TypeOf(time.Time{})
+	rawMessageType = reflect.TypeOf(json.RawMessage(nil))
+)
+
+func (g *generator) schemaFor(t reflect.Type) *Schema {
+	if vals, ok := enums[t]; ok {
+		return &Schema{Type: "string", Enum: vals}
+	}
+	if t == timeType {
+		return &Schema{Type: "string", Format: "date-time"}
+	}
+	if t == rawMessageType {
+		return &Schema{} // any JSON value
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		return nullable(g.schemaFor(t.Elem()))
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		// nil slices encode as null.
+		return nullable(&Schema{Type: "array", Items: g.schemaFor(t.Elem())})
+	case reflect.Map:
+		return nullable(&Schema{Type: "object", AdditionalProperties: g.schemaFor(t.Elem())})
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.structSchema(t)
+		}
+		name, ok := g.names[t]
+		if !ok {
+			name = g.defName(t)
+			g.names[t] = name
+			g.defs[name] = nil // reserve to stop recursion
+			g.defs[name] = g.structSchema(t)
+		}
+		return &Schema{Ref: g.refPrefix + name}
+	default:
+		return &Schema{}
+	}
+}
+
+// defName names the definition of t: its type name with the first letter
+// upper-cased, qualified by package if another type already has that name.
+func (g *generator) defName(t reflect.Type) string {
+	name := strings.ToUpper(t.Name()[:1]) + t.Name()[1:]
+	if _, taken := g.defs[name]; taken {
+		pkg := t.PkgPath()[strings.LastIndex(t.PkgPath(), "/")+1:]
+		name = strings.ToUpper(pkg[:1]) + pkg[1:] + name
+	}
+	return name
+}
+
+func (g *generator) structSchema(t reflect.Type) *Schema {
+	s := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+	if g.closed {
+		s.AdditionalProperties = &Schema{Not: &Schema{}} // no other properties
+	}
+	g.addFields(s, t)
+	sort.Strings(s.Required)
+	return s
+}
+
+// addFields adds t's fields to s. The fields of embedded structs are
+// promoted, as encoding/json does.
+func (g *generator) addFields(s *Schema, t reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, opts, _ := strings.Cut(f.Tag.Get(g.tag), ",")
+		if ft := f.Type; f.Anonymous && name == "" {
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				g.addFields(s, ft)
+				continue
+			}
+		}
+		if !f.IsExported() || name == "-" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		s.Properties[name] = g.schemaFor(f.Type)
+		if !g.closed && !strings.Contains(opts, "omitempty") {
+			s.Required = append(s.Required, name)
+		}
+	}
+}
+
+// nullable widens s to also accept null. References are left alone; only
+// pointers to structs produce them and those are never nil in practice.
+func nullable(s *Schema) *Schema {
+	if typ, ok := s.Type.(string); ok {
+		s.Type = []string{typ, "null"}
+	}
+	return s
+}
@@ -0,0 +1,162 @@
+package jsonschema_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/toposcope/toposcope/pkg/graph"
+	"github.com/toposcope/toposcope/pkg/jsonschema"
+)
+
+// The checked-in schemas must match the Go types; regenerate them with
+// `make schemas` after changing pkg/graph or pkg/scoring output types.
+func TestCanonicalSchemasUpToDate(t *testing.T) {
+	for _, c := range jsonschema.Canonical {
+		s, err := jsonschema.For(c.Name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var want bytes.Buffer
+		enc := json.NewEncoder(&want)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(s); err != nil {
+			t.Fatal(err)
+		}
+
+		got, err := os.ReadFile(filepath.Join("..", "..", "schemas", c.Name+".schema.json"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, want.Bytes()) {
+			t.Errorf("schemas/%s.schema.json is stale; run `make schemas`", c.Name)
+		}
+	}
+}
+
+func TestGenerateSnapshot(t *testing.T) {
+	s, err := jsonschema.For("snapshot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Schema != jsonschema.Draft || s.Title != "Snapshot" {
+		t.Errorf("unexpected header: $schema=%q title=%q", s.Schema, s.Title)
+	}
+
+	if got := s.Properties["extracted_at"]; got == nil || got.Format != "date-time" {
+		t.Errorf("extracted_at = %+v, want date-time string", got)
+	}
+	if got := s.Properties["nodes"]; got == nil || got.AdditionalProperties == nil || got.AdditionalProperties.Ref != "#/$defs/Node" {
+		t.Errorf("nodes = %+v, want map of Node refs", got)
+	}
+	if s.Defs["Node"] == nil || s.Defs["Edge"] == nil || s.Defs["SnapshotStats"] == nil {
+		t.Errorf("missing $defs, got %v", s.Defs)
+	}
+
+	required := map[string]bool{}
+	for _, r := range s.Required {
+		required[r] = true
+	}
+	if !required["commit_sha"] || required["branch"] {
+		t.Errorf("required = %v; want commit_sha required and omitempty branch optional", s.Required)
+	}
+
+	// Every key toposcope actually emits must be described by the schema.
+	snap := &graph.Snapshot{
+		ID:          "s",
+		Branch:      "main",
+		Scope:       []string{"//a:lib"},
+		Nodes:       map[string]*graph.Node{"//a:lib": {Key: "//a:lib", Tags: []string{"x"}}},
+		Edges:       []graph.Edge{{From: "//a:lib", To: "//b:lib", Type: "COMPILE"}},
+		Stats:       graph.SnapshotStats{SkippedPackages: []string{"//c"}},
+		ExtractedAt: time.Now(),
+	}
+	data, _ := json.Marshal(snap)
+	var doc map[string]any
+	if err := json.Unmarshal(data, &doc); err != nil {
+		t.Fatal(err)
+	}
+	for key := range doc {
+		if s.Properties[key] == nil {
+			t.Errorf("emitted key %q missing from schema", key)
+		}
+	}
+	for key := range doc["stats"].(map[string]any) {
+		if s.Defs["SnapshotStats"].Properties[key] == nil {
+			t.Errorf("emitted stats key %q missing from schema", key)
+		}
+	}
+}
+
+func TestForUnknown(t *testing.T) {
+	if _, err := jsonschema.For("nope"); err == nil {
+		t.Error("expected error for unknown schema")
+	}
+}
+
+type embedded struct {
+	Count int `json:"count"`
+}
+
+type embeddedPointer struct {
+	Total int `json:"total"`
+}
+
+type componentsRoot struct {
+	embedded
+	*embeddedPointer
+	Raw   json.RawMessage `json:"raw,omitempty"`
+	Node  graph.Node      `json:"node"`
+	Inner struct {
+		Name string `json:"name"`
+	} `json:"inner"`
+}
+
+func TestComponents(t *testing.T) {
+	c := jsonschema.NewComponents("#/components/schemas/")
+	if got := c.Schema(componentsRoot{}); got.Ref != "#/components/schemas/ComponentsRoot" {
+		t.Fatalf("root = %+v, want a component ref", got)
+	}
+	if got := c.Schema([]graph.Node{}); got.Items == nil || got.Items.Ref != "#/components/schemas/Node" {
+		t.Errorf("[]Node = %+v, want array of the shared Node", got)
+	}
+
+	root := c.Defs()["ComponentsRoot"]
+	if root.Properties["count"] == nil || root.Properties["embedded"] != nil || root.Properties["total"] == nil {
+		t.Errorf("embedded fields not promoted: %v", root.Properties)
+	}
+	if raw := root.Properties["raw"]; raw == nil || raw.Type != nil {
+		t.Errorf("raw = %+v, want any value", raw)
+	}
+	if inner := root.Properties["inner"]; inner == nil || inner.Properties["name"] == nil {
+		t.Errorf("anonymous struct not inlined: %+v", inner)
+	}
+	if c.Defs()["Node"] == nil {
+		t.Errorf("defs = %v, want Node", c.Defs())
+	}
+}
+
+func TestGenerateConfig(t *testing.T) {
+	s, err := jsonschema.For("config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Title != "Config" || len(s.Required) != 0 {
+		t.Errorf("unexpected header: title=%q required=%v", s.Title, s.Required)
+	}
+	// Keys come from the yaml tags, and config objects are closed so editors
+	// flag unknown keys.
+	if s.Properties["remote_cache"] == nil || s.AdditionalProperties == nil || s.AdditionalProperties.Not == nil {
+		t.Errorf("root = %+v, want yaml keys and a closed object", s)
+	}
+	sc := s.Defs["ScoringConfig"]
+	if sc == nil || sc.Properties["grade_thresholds"] == nil || sc.AdditionalProperties == nil || sc.AdditionalProperties.Not == nil {
+		t.Fatalf("ScoringConfig = %+v, want yaml keys and a closed object", sc)
+	}
+	if w := sc.Properties["weights"]; w == nil || w.AdditionalProperties == nil || w.AdditionalProperties.Not != nil {
+		t.Errorf("weights = %+v, want an open map of numbers", w)
+	}
+}
@@ -0,0 +1,41 @@
+package scoring_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/toposcope/toposcope/pkg/graph"
+	"github.com/toposcope/toposcope/pkg/graph/graphtest"
+	"github.com/toposcope/toposcope/pkg/scoring"
+)
+
+func BenchmarkEngineScore(b *testing.B) {
+	for _, n := range []int{10000, 100000, 1000000} {
+		base, head := graphtest.Pair(n)
+		delta := graph.ComputeDelta(base, head)
+		engine := scoring.NewEngine(scoring.DefaultMetrics()...)
+		b.Run(fmt.Sprintf("edges=%d", n), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := engine.Score(delta, base, head); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkMetrics times each default metric alone, so a regression in the
+// engine benchmark can be traced to one metric.
+func BenchmarkMetrics(b *testing.B) {
+	base, head := graphtest.Pair(100000)
+	delta := graph.ComputeDelta(base, head)
+	for _, m := range scoring.DefaultMetrics() {
+		b.Run(m.Key(), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				m.Evaluate(delta, base, head)
+			}
+		})
+	}
+}
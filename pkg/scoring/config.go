@@ -1,30 +1,49 @@
 package scoring
 
-// DefaultWeights holds the default scoring weights for all metrics.
+// DefaultWeights holds the default scoring weights for all metrics. The JSON
+// form is the weights object accepted by the score preview API.
 type DefaultWeights struct {
 	// M1: Cross-package dependencies
-	CrossPackageIntraBoundary float64
-	CrossPackageCrossBoundary float64
+	CrossPackageIntraBoundary float64 `json:"cross_package_intra_boundary"`
+	CrossPackageCrossBoundary float64 `json:"cross_package_cross_boundary"`
 
 	// M2: Fanout increase
-	FanoutWeight       float64
-	FanoutCapPerNode   float64
-	FanoutMinThreshold int // only score if out-degree exceeds this after change
+	FanoutWeight       float64 `json:"fanout_weight"`
+	FanoutCapPerNode   float64 `json:"fanout_cap_per_node"`
+	FanoutMinThreshold int     `json:"fanout_min_threshold"` // only score if out-degree exceeds this after change
 
 	// M3: Centrality penalty
-	CentralityWeight          float64
-	CentralityMinInDegree     int     // only apply for targets above this in-degree
-	CentralityMaxContribution float64 // safety cap on centrality contribution
+	CentralityWeight          float64 `json:"centrality_weight"`
+	CentralityMinInDegree     int     `json:"centrality_min_in_degree"`    // only apply for targets above this in-degree
+	CentralityMaxContribution float64 `json:"centrality_max_contribution"` // safety cap on centrality contribution
+
+	// M4: New package cycles
+	PackageCycleWeight          float64 `json:"package_cycle_weight"`
+	PackageCycleMaxContribution float64 `json:"package_cycle_max_contribution"`
 
 	// M5: Blast radius
-	BlastRadiusWeight          float64
-	BlastRadiusMaxContribution float64
+	BlastRadiusWeight          float64 `json:"blast_radius_weight"`
+	BlastRadiusMaxContribution float64 `json:"blast_radius_max_contribution"`
 
 	// M6: Credits
-	CreditPerRemovedCrossBoundaryEdge float64
-	CreditMaxTotal                    float64
-	CreditPerFanoutReduction          float64
-	CreditFanoutMaxTotal              float64
+	CreditPerRemovedCrossBoundaryEdge float64 `json:"credit_per_removed_cross_boundary_edge"`
+	CreditMaxTotal                    float64 `json:"credit_max_total"`
+	CreditPerFanoutReduction          float64 `json:"credit_per_fanout_reduction"`
+	CreditFanoutMaxTotal              float64 `json:"credit_fanout_max_total"`
+
+	// M7: Third-party exposure
+	ThirdPartyEdgeWeight      float64 `json:"third_party_edge_weight"`
+	ThirdPartyNewRepoWeight   float64 `json:"third_party_new_repo_weight"`
+	ThirdPartyMaxContribution float64 `json:"third_party_max_contribution"`
+
+	// M8: Critical build path
+	CriticalPathWeight          float64 `json:"critical_path_weight"`
+	CriticalPathMaxContribution float64 `json:"critical_path_max_contribution"`
+
+	// M9: Cache-busting change
+	CacheBustingWeight          float64 `json:"cache_busting_weight"`    // score for busting every cache hit
+	CacheBustingMinShare        float64 `json:"cache_busting_min_share"` // ignore sources below this share of hits
+	CacheBustingMaxContribution float64 `json:"cache_busting_max_contribution"`
 }
 
 // Defaults returns the default scoring weights.
@@ -44,6 +63,10 @@ func Defaults() DefaultWeights {
 		CentralityMinInDegree:     50,
 		CentralityMaxContribution: 40.0,
 
+		// M4
+		PackageCycleWeight:          5.0,
+		PackageCycleMaxContribution: 15.0,
+
 		// M5
 		BlastRadiusWeight:          2.0,
 		BlastRadiusMaxContribution: 15.0,
@@ -53,5 +76,19 @@ func Defaults() DefaultWeights {
 		CreditMaxTotal:                    -15.0,
 		CreditPerFanoutReduction:          -0.3,
 		CreditFanoutMaxTotal:              -10.0,
+
+		// M7
+		ThirdPartyEdgeWeight:      0.5,
+		ThirdPartyNewRepoWeight:   3.0,
+		ThirdPartyMaxContribution: 10.0,
+
+		// M8
+		CriticalPathWeight:          4.0,
+		CriticalPathMaxContribution: 12.0,
+
+		// M9
+		CacheBustingWeight:          10.0,
+		CacheBustingMinShare:        0.05,
+		CacheBustingMaxContribution: 10.0,
 	}
 }
@@ -2,7 +2,12 @@ package scoring
 
 // DefaultMetrics returns the standard set of scoring metrics with default weights.
 func DefaultMetrics() []Metric {
-	w := Defaults()
+	return MetricsFromWeights(Defaults())
+}
+
+// MetricsFromWeights returns the standard set of scoring metrics configured
+// with w.
+func MetricsFromWeights(w DefaultWeights) []Metric {
 	return []Metric{
 		&CrossPackageMetric{
 			IntraBoundaryWeight: w.CrossPackageIntraBoundary,
@@ -18,6 +23,10 @@ func DefaultMetrics() []Metric {
 			MinInDegree:     w.CentralityMinInDegree,
 			MaxContribution: w.CentralityMaxContribution,
 		},
+		&PackageCycleMetric{
+			Weight:          w.PackageCycleWeight,
+			MaxContribution: w.PackageCycleMaxContribution,
+		},
 		&BlastRadiusMetric{
 			Weight:          w.BlastRadiusWeight,
 			MaxContribution: w.BlastRadiusMaxContribution,
@@ -28,5 +37,19 @@ func DefaultMetrics() []Metric {
 			PerFanoutReduction:          w.CreditPerFanoutReduction,
 			FanoutMaxCredit:             w.CreditFanoutMaxTotal,
 		},
+		&ThirdPartyMetric{
+			EdgeWeight:      w.ThirdPartyEdgeWeight,
+			NewRepoWeight:   w.ThirdPartyNewRepoWeight,
+			MaxContribution: w.ThirdPartyMaxContribution,
+		},
+		&CriticalPathMetric{
+			Weight:          w.CriticalPathWeight,
+			MaxContribution: w.CriticalPathMaxContribution,
+		},
+		&CacheBustingMetric{
+			Weight:          w.CacheBustingWeight,
+			MinShare:        w.CacheBustingMinShare,
+			MaxContribution: w.CacheBustingMaxContribution,
+		},
 	}
 }
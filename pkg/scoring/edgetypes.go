@@ -0,0 +1,43 @@
+package scoring
+
+import (
+	"strings"
+
+	"github.com/toposcope/toposcope/pkg/graph"
+)
+
+// SetEdgeTypes limits scoring to edges of the given types (e.g. COMPILE and
+// RUNTIME), so edges from opt-in attributes such as toolchains can be
+// extracted for the graph views without adding to the score. Empty scores
+// every edge.
+func (e *Engine) SetEdgeTypes(types []string) {
+	e.edgeTypes = nil
+	for _, t := range types {
+		if e.edgeTypes == nil {
+			e.edgeTypes = make(map[string]bool, len(types))
+		}
+		e.edgeTypes[strings.ToUpper(t)] = true
+	}
+}
+
+// filterEdgeTypes returns copies of delta and the snapshots with only the
+// edges whose type is in types. Nodes are shared with the originals.
+func filterEdgeTypes(types map[string]bool, delta *graph.Delta, base, head *graph.Snapshot) (*graph.Delta, *graph.Snapshot, *graph.Snapshot) {
+	keep := func(edges []graph.Edge) []graph.Edge {
+		var kept []graph.Edge
+		for _, e := range edges {
+			if types[e.Type] {
+				kept = append(kept, e)
+			}
+		}
+		return kept
+	}
+
+	d := *delta
+	d.AddedEdges = keep(delta.AddedEdges)
+	d.RemovedEdges = keep(delta.RemovedEdges)
+	b, h := *base, *head
+	b.Edges = keep(base.Edges)
+	h.Edges = keep(head.Edges)
+	return &d, &b, &h
+}
@@ -3,6 +3,7 @@ package scoring
 import (
 	"fmt"
 	"sort"
+	"time"
 
 	"github.com/toposcope/toposcope/pkg/graph"
 )
@@ -17,14 +18,65 @@ type Metric interface {
 	Evaluate(delta *graph.Delta, base, head *graph.Snapshot) MetricResult
 }
 
+// ConfigReporter is implemented by metrics that can report their settings.
+// The engine echoes them into MetricResult.Config.
+type ConfigReporter interface {
+	Config() map[string]any
+}
+
+// Describer is implemented by metrics that can explain what they score, in
+// terms of their Config keys. `toposcope explain` prints it.
+type Describer interface {
+	Description() string
+}
+
 // Engine runs all configured metrics against a delta and produces a ScoreResult.
 type Engine struct {
-	metrics []Metric
+	metrics       []Metric
+	providers     []SuggestionProvider
+	normalization Normalization
+	grades        GradeThresholds
+	waivers       []Waiver
+	edgeTypes     map[string]bool // nil scores every edge type
+	confidence    float64         // confidence interval level; 0 disables it
 }
 
-// NewEngine creates a scoring engine with the given metrics.
+// NewEngine creates a scoring engine with the given metrics and the default
+// suggestion providers.
 func NewEngine(metrics ...Metric) *Engine {
-	return &Engine{metrics: metrics}
+	return &Engine{
+		metrics:   metrics,
+		providers: DefaultSuggestionProviders(),
+		grades:    DefaultGradeThresholds(),
+	}
+}
+
+// AddSuggestionProvider registers an additional suggestion provider. Providers
+// run in registration order after all metrics have been evaluated.
+func (e *Engine) AddSuggestionProvider(p SuggestionProvider) {
+	e.providers = append(e.providers, p)
+}
+
+// SetSuggestionProviders replaces all registered suggestion providers,
+// including the defaults.
+func (e *Engine) SetSuggestionProviders(providers ...SuggestionProvider) {
+	e.providers = providers
+}
+
+// SetNormalization enables a score normalization mode.
+func (e *Engine) SetNormalization(n Normalization) {
+	e.normalization = n
+}
+
+// SetGradeThresholds overrides the default grade boundaries.
+func (e *Engine) SetGradeThresholds(t GradeThresholds) {
+	e.grades = t
+}
+
+// SetWaivers sets configured waivers, in addition to those declared with
+// WaiverTag on targets. Expired waivers are ignored.
+func (e *Engine) SetWaivers(w []Waiver) {
+	e.waivers = w
 }
 
 // Score evaluates all metrics and produces a complete ScoreResult.
@@ -36,7 +88,23 @@ func (e *Engine) Score(delta *graph.Delta, base, head *graph.Snapshot) (*ScoreRe
 		return nil, fmt.Errorf("base and head snapshots are required")
 	}
 
+	if e.edgeTypes != nil {
+		delta, base, head = filterEdgeTypes(e.edgeTypes, delta, base, head)
+	}
+
+	// Partial heads only cover the extraction scope. Score the scoped portion
+	// of the delta, and evaluate graph-wide measures (degrees, centrality)
+	// against the head overlaid onto the base.
+	partial := head.Partial
+	scope := head.Scope
+	if partial {
+		delta = scopeDelta(delta, head)
+		head = graph.MergeIntoBaseline(base, head)
+	}
+
 	result := &ScoreResult{
+		Partial:    partial,
+		Scope:      scope,
 		BaseCommit: base.CommitSHA,
 		HeadCommit: head.CommitSHA,
 		DeltaStats: DeltaStatsView{
@@ -48,9 +116,22 @@ func (e *Engine) Score(delta *graph.Delta, base, head *graph.Snapshot) (*ScoreRe
 		},
 	}
 
+	now := time.Now()
+	var waivers []Waiver
+	for _, w := range e.waivers {
+		if w.Active(now) {
+			waivers = append(waivers, w)
+		}
+	}
+	waivers = append(waivers, tagWaivers(head, base)...)
+
 	// Run each metric
 	for _, m := range e.metrics {
 		mr := m.Evaluate(delta, base, head)
+		if c, ok := m.(ConfigReporter); ok {
+			mr.Config = c.Config()
+		}
+		result.Suppressed = applyWaivers(&mr, waivers, result.Suppressed)
 		result.Breakdown = append(result.Breakdown, mr)
 		result.TotalScore += mr.Contribution
 	}
@@ -60,9 +141,20 @@ func (e *Engine) Score(delta *graph.Delta, base, head *graph.Snapshot) (*ScoreRe
 		result.TotalScore = 0
 	}
 
-	result.Grade = GradeFromScore(result.TotalScore)
+	result.GradeThresholds = e.grades
+	result.Grade = e.grades.Grade(result.TotalScore)
+	if e.normalization == NormalizationSize {
+		result.Normalization = e.normalization
+		result.SizeFactor = SizeFactor(head)
+		result.NormalizedScore = result.TotalScore * result.SizeFactor
+		result.Grade = e.grades.Grade(result.NormalizedScore)
+	}
+	if e.confidence > 0 && e.confidence < 1 {
+		result.Interval = e.scoreInterval(delta, base, head, waivers, result)
+	}
+	result.AssignFingerprints()
 	result.Hotspots = computeHotspots(result.Breakdown)
-	result.SuggestedActions = generateSuggestions(result.Breakdown, delta)
+	result.SuggestedActions = e.suggest(result.Breakdown, delta)
 
 	return result, nil
 }
@@ -123,77 +215,6 @@ func computeHotspots(breakdown []MetricResult) []Hotspot {
 	return hotspots
 }
 
-// generateSuggestions produces actionable recommendations based on findings.
-func generateSuggestions(breakdown []MetricResult, delta *graph.Delta) []SuggestedAction {
-	var actions []SuggestedAction
-
-	for _, mr := range breakdown {
-		switch mr.Key {
-		case "fanout_increase":
-			for _, ev := range mr.Evidence {
-				if ev.Value >= 20 && ev.From != "" {
-					actions = append(actions, SuggestedAction{
-						Title:       fmt.Sprintf("Consider splitting %s", ev.From),
-						Description: fmt.Sprintf("This target now has %.0f dependencies. Targets with high fanout become fragile and slow to build.", ev.Value),
-						Targets:     []string{ev.From},
-						Confidence:  0.7,
-						Addresses:   []string{mr.Key},
-					})
-				}
-			}
-		case "cross_package_deps":
-			// Group added edges by source node
-			sourceEdges := make(map[string]int)
-			for _, ev := range mr.Evidence {
-				if ev.From != "" {
-					sourceEdges[ev.From]++
-				}
-			}
-			for source, count := range sourceEdges {
-				if count >= 3 {
-					actions = append(actions, SuggestedAction{
-						Title:       fmt.Sprintf("Extract shared dependency for %s", source),
-						Description: fmt.Sprintf("This target added %d cross-package dependencies. Consider extracting a shared library.", count),
-						Targets:     []string{source},
-						Confidence:  0.5,
-						Addresses:   []string{mr.Key},
-					})
-				}
-			}
-		case "centrality_penalty":
-			for _, ev := range mr.Evidence {
-				if ev.To == "" {
-					continue
-				}
-				if ev.Value >= 1000 {
-					// Foundational package — don't suggest avoiding it
-					actions = append(actions, SuggestedAction{
-						Title:       fmt.Sprintf("This change depends on foundational package %s (%.0f reverse deps)", ev.To, ev.Value),
-						Description: "This is a foundational target; depending on it is expected. No action needed unless a narrower API exists.",
-						Targets:     []string{ev.To},
-						Confidence:  0.3,
-						Addresses:   []string{mr.Key},
-					})
-				} else if ev.Value >= 100 {
-					actions = append(actions, SuggestedAction{
-						Title:       fmt.Sprintf("Avoid direct dependency on %s", ev.To),
-						Description: fmt.Sprintf("This target has %.0f reverse dependencies. Consider depending on a narrower interface.", ev.Value),
-						Targets:     []string{ev.To},
-						Confidence:  0.5,
-						Addresses:   []string{mr.Key},
-					})
-				}
-			}
-		}
-	}
-
-	if len(actions) > 5 {
-		actions = actions[:5]
-	}
-
-	return actions
-}
-
 func uniqueStrings(ss []string) []string {
 	seen := make(map[string]bool)
 	var result []string
@@ -1,6 +1,9 @@
 package scoring_test
 
 import (
+	"encoding/json"
+	"fmt"
+	"math"
 	"testing"
 
 	"github.com/toposcope/toposcope/pkg/graph"
@@ -133,3 +136,259 @@ func TestEngineScoreEmptyDelta(t *testing.T) {
 		t.Errorf("expected grade A for zero score, got %s", result.Grade)
 	}
 }
+
+func TestEngineScoreNormalization(t *testing.T) {
+	base, head, delta := loadFixtures(t)
+
+	raw, err := scoring.NewEngine(scoring.DefaultMetrics()...).Score(delta, base, head)
+	if err != nil {
+		t.Fatalf("Score() error: %v", err)
+	}
+	if raw.Normalization != "" || raw.NormalizedScore != 0 {
+		t.Errorf("expected no normalization by default, got %q/%f", raw.Normalization, raw.NormalizedScore)
+	}
+
+	engine := scoring.NewEngine(scoring.DefaultMetrics()...)
+	engine.SetNormalization(scoring.NormalizationSize)
+	result, err := engine.Score(delta, base, head)
+	if err != nil {
+		t.Fatalf("Score() error: %v", err)
+	}
+
+	if result.TotalScore != raw.TotalScore {
+		t.Errorf("expected raw TotalScore %f to be preserved, got %f", raw.TotalScore, result.TotalScore)
+	}
+	if result.SizeFactor != scoring.SizeFactor(head) {
+		t.Errorf("SizeFactor = %f, want %f", result.SizeFactor, scoring.SizeFactor(head))
+	}
+	if want := raw.TotalScore * result.SizeFactor; result.NormalizedScore != want {
+		t.Errorf("NormalizedScore = %f, want %f", result.NormalizedScore, want)
+	}
+	if result.Grade != scoring.GradeFromScore(result.NormalizedScore) {
+		t.Errorf("expected grade from normalized score, got %s", result.Grade)
+	}
+}
+
+func TestEngineScoreInterval(t *testing.T) {
+	base, head, delta := loadFixtures(t)
+
+	raw, err := scoring.NewEngine(scoring.DefaultMetrics()...).Score(delta, base, head)
+	if err != nil {
+		t.Fatalf("Score() error: %v", err)
+	}
+	if raw.Interval != nil {
+		t.Errorf("expected no interval by default, got %+v", raw.Interval)
+	}
+
+	engine := scoring.NewEngine(scoring.DefaultMetrics()...)
+	engine.SetConfidenceLevel(0.9)
+	result, err := engine.Score(delta, base, head)
+	if err != nil {
+		t.Fatalf("Score() error: %v", err)
+	}
+	iv := result.Interval
+	if iv == nil {
+		t.Fatal("expected an interval")
+	}
+	if iv.Level != 0.9 || iv.Samples == 0 {
+		t.Errorf("interval = %+v, want level 0.9 with samples", iv)
+	}
+	if iv.Low > iv.High || iv.Low < 0 {
+		t.Errorf("interval [%f, %f] is not a valid range", iv.Low, iv.High)
+	}
+	if result.TotalScore != raw.TotalScore {
+		t.Errorf("TotalScore = %f, want %f unchanged by the interval", result.TotalScore, raw.TotalScore)
+	}
+
+	again, err := engine.Score(delta, base, head)
+	if err != nil {
+		t.Fatalf("Score() error: %v", err)
+	}
+	if *again.Interval != *iv {
+		t.Errorf("interval not deterministic: %+v then %+v", iv, again.Interval)
+	}
+}
+
+func TestSizeFactor(t *testing.T) {
+	snapOfSize := func(nodes int) *graph.Snapshot {
+		snap := &graph.Snapshot{Nodes: map[string]*graph.Node{}}
+		for i := 0; i < nodes; i++ {
+			key := fmt.Sprintf("//pkg%d:lib", i)
+			snap.Nodes[key] = &graph.Node{Key: key}
+		}
+		return snap
+	}
+
+	if got := scoring.SizeFactor(snapOfSize(10000)); math.Abs(got-1) > 1e-9 {
+		t.Errorf("SizeFactor at reference size = %f, want 1", got)
+	}
+	if got := scoring.SizeFactor(snapOfSize(100000)); got >= 1 {
+		t.Errorf("expected SizeFactor < 1 for large graphs, got %f", got)
+	}
+	if got := scoring.SizeFactor(snapOfSize(5)); got != 2 {
+		t.Errorf("expected SizeFactor capped at 2 for tiny graphs, got %f", got)
+	}
+}
+
+func TestEngineScorePartial(t *testing.T) {
+	base := &graph.Snapshot{
+		CommitSHA: "base",
+		Nodes: map[string]*graph.Node{
+			"//a:lib": {Key: "//a:lib", Package: "//a"},
+			"//b:lib": {Key: "//b:lib", Package: "//b"},
+			"//c:lib": {Key: "//c:lib", Package: "//c"},
+			"//d:lib": {Key: "//d:lib", Package: "//d"},
+		},
+		Edges: []graph.Edge{
+			{From: "//a:lib", To: "//b:lib", Type: "COMPILE"},
+			{From: "//b:lib", To: "//c:lib", Type: "COMPILE"},
+			{From: "//d:lib", To: "//c:lib", Type: "COMPILE"},
+		},
+	}
+
+	// Scoped extraction of //b:lib: it swapped //c:lib for //d:lib. Nothing
+	// outside the scope changed, but //c:lib, //d:lib, and //d:lib -> //c:lib
+	// are absent from the partial head.
+	head := &graph.Snapshot{
+		CommitSHA: "head",
+		Partial:   true,
+		Scope:     []string{"//b:lib"},
+		Nodes: map[string]*graph.Node{
+			"//a:lib": {Key: "//a:lib", Package: "//a"},
+			"//b:lib": {Key: "//b:lib", Package: "//b"},
+		},
+		Edges: []graph.Edge{
+			{From: "//a:lib", To: "//b:lib", Type: "COMPILE"},
+			{From: "//b:lib", To: "//d:lib", Type: "COMPILE"},
+		},
+	}
+	delta := graph.ComputeDelta(base, head)
+
+	engine := scoring.NewEngine(scoring.DefaultMetrics()...)
+	result, err := engine.Score(delta, base, head)
+	if err != nil {
+		t.Fatalf("Score() error: %v", err)
+	}
+
+	if !result.Partial {
+		t.Error("expected result to be marked partial")
+	}
+	if len(result.Scope) != 1 || result.Scope[0] != "//b:lib" {
+		t.Errorf("Scope = %v, want [//b:lib]", result.Scope)
+	}
+	if result.DeltaStats.RemovedNodes != 0 {
+		t.Errorf("RemovedNodes = %d, want 0 (out-of-scope nodes are not removals)", result.DeltaStats.RemovedNodes)
+	}
+	if result.DeltaStats.RemovedEdges != 1 {
+		t.Errorf("RemovedEdges = %d, want 1 (only //b:lib -> //c:lib)", result.DeltaStats.RemovedEdges)
+	}
+	for _, mr := range result.Breakdown {
+		for _, ev := range mr.Evidence {
+			if ev.From == "//d:lib" && ev.To == "//c:lib" {
+				t.Errorf("metric %s: unexpected out-of-scope evidence %+v", mr.Key, ev)
+			}
+		}
+	}
+}
+
+func TestEngineScoreEchoesConfig(t *testing.T) {
+	base, head, delta := loadFixtures(t)
+
+	engine := scoring.NewEngine(
+		&scoring.FanoutMetric{Weight: 0.25, CapPerNode: 5, MinThreshold: 3},
+		&scoring.BlastRadiusMetric{Weight: 2, MaxContribution: 15},
+	)
+	engine.SetGradeThresholds(scoring.GradeThresholds{A: 1, B: 2, C: 3, D: 4})
+	result, err := engine.Score(delta, base, head)
+	if err != nil {
+		t.Fatalf("Score: %v", err)
+	}
+
+	fanout := result.Breakdown[0].Config
+	if fanout["weight"] != 0.25 || fanout["cap_per_node"] != 5.0 || fanout["min_threshold"] != 3 {
+		t.Errorf("fanout config = %v", fanout)
+	}
+
+	cfg := result.Config()
+	if cfg.GradeThresholds.D != 4 {
+		t.Errorf("config grade thresholds = %+v, want D=4", cfg.GradeThresholds)
+	}
+	if len(cfg.Metrics) != 2 || cfg.Metrics["blast_radius"]["max_contribution"] != 15.0 {
+		t.Errorf("config metrics = %v", cfg.Metrics)
+	}
+}
+
+func TestEngineScoreEdgeTypes(t *testing.T) {
+	snap := func(edges ...graph.Edge) *graph.Snapshot {
+		return &graph.Snapshot{Nodes: map[string]*graph.Node{}, Edges: edges}
+	}
+	compile := graph.Edge{From: "//a:a", To: "//b:b", Type: "COMPILE"}
+	toolchain := graph.Edge{From: "//a:a", To: "//tools:cc", Type: "TOOLCHAIN"}
+	base, head := snap(), snap(compile, toolchain)
+	delta := &graph.Delta{AddedEdges: []graph.Edge{compile, toolchain}}
+
+	var seen []graph.Edge
+	metric := edgeRecorder(func(d *graph.Delta, _, h *graph.Snapshot) {
+		seen = append(append([]graph.Edge(nil), d.AddedEdges...), h.Edges...)
+	})
+
+	engine := scoring.NewEngine(metric)
+	engine.SetEdgeTypes([]string{"compile"})
+	if _, err := engine.Score(delta, base, head); err != nil {
+		t.Fatalf("Score: %v", err)
+	}
+	if len(seen) != 2 || seen[0] != compile || seen[1] != compile {
+		t.Errorf("metric saw edges %v, want only the COMPILE edge", seen)
+	}
+	if len(delta.AddedEdges) != 2 || len(head.Edges) != 2 {
+		t.Error("filtering modified the caller's delta or snapshot")
+	}
+
+	engine.SetEdgeTypes(nil)
+	if _, err := engine.Score(delta, base, head); err != nil {
+		t.Fatalf("Score: %v", err)
+	}
+	if len(seen) != 4 {
+		t.Errorf("metric saw %d edges without a filter, want 4", len(seen))
+	}
+}
+
+// edgeRecorder is a metric that hands what it evaluates to a callback.
+type edgeRecorder func(delta *graph.Delta, base, head *graph.Snapshot)
+
+func (edgeRecorder) Key() string  { return "edges" }
+func (edgeRecorder) Name() string { return "Edges" }
+func (m edgeRecorder) Evaluate(delta *graph.Delta, base, head *graph.Snapshot) scoring.MetricResult {
+	m(delta, base, head)
+	return scoring.MetricResult{Key: "edges"}
+}
+
+func TestMetricsFromWeights(t *testing.T) {
+	w := scoring.Defaults()
+	if err := json.Unmarshal([]byte(`{"fanout_weight": 2, "blast_radius_max_contribution": 5}`), &w); err != nil {
+		t.Fatalf("unmarshal weights: %v", err)
+	}
+
+	cfg := map[string]map[string]any{}
+	for _, m := range scoring.MetricsFromWeights(w) {
+		cfg[m.Key()] = m.(scoring.ConfigReporter).Config()
+	}
+	if cfg["fanout_increase"]["weight"] != 2.0 {
+		t.Errorf("fanout weight = %v, want 2", cfg["fanout_increase"]["weight"])
+	}
+	if cfg["blast_radius"]["max_contribution"] != 5.0 {
+		t.Errorf("blast radius cap = %v, want 5", cfg["blast_radius"]["max_contribution"])
+	}
+	if cfg["centrality_penalty"]["weight"] != scoring.Defaults().CentralityWeight {
+		t.Errorf("centrality weight = %v, want default", cfg["centrality_penalty"]["weight"])
+	}
+}
+
+func TestDefaultMetricsDescribed(t *testing.T) {
+	for _, m := range scoring.DefaultMetrics() {
+		d, ok := m.(scoring.Describer)
+		if !ok || d.Description() == "" {
+			t.Errorf("%s has no description", m.Key())
+		}
+	}
+}
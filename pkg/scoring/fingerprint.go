@@ -0,0 +1,43 @@
+package scoring
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// Fingerprint identifies a finding across runs. It hashes the metric and the
+// targets involved, so the same metric flagging the same targets gets the
+// same fingerprint even if its summary or values change. Evidence that names
+// no targets falls back to its summary.
+func Fingerprint(metric string, ev EvidenceItem) string {
+	h := sha256.New()
+	parts := []string{metric, ev.From, ev.To}
+	if ev.From == "" && ev.To == "" {
+		parts = append(parts, ev.Summary)
+	}
+	for _, p := range parts {
+		h.Write([]byte(p))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil)[:8])
+}
+
+// AssignFingerprints sets Fingerprint on every finding that lacks one,
+// including suppressed findings. Results scored before fingerprints existed
+// get the same fingerprints a fresh run would assign.
+func (r *ScoreResult) AssignFingerprints() {
+	for i := range r.Breakdown {
+		mr := &r.Breakdown[i]
+		for j := range mr.Evidence {
+			if mr.Evidence[j].Fingerprint == "" {
+				mr.Evidence[j].Fingerprint = Fingerprint(mr.Key, mr.Evidence[j])
+			}
+		}
+	}
+	for i := range r.Suppressed {
+		sf := &r.Suppressed[i]
+		if sf.Evidence.Fingerprint == "" {
+			sf.Evidence.Fingerprint = Fingerprint(sf.Metric, sf.Evidence)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package scoring_test
+
+import (
+	"testing"
+
+	"github.com/toposcope/toposcope/pkg/scoring"
+)
+
+func TestFingerprint(t *testing.T) {
+	a := scoring.EvidenceItem{Type: scoring.EvidenceFanoutChange, Summary: "//app:a fanout 3 -> 8", From: "//app:a", Value: 8}
+	b := scoring.EvidenceItem{Type: scoring.EvidenceFanoutChange, Summary: "//app:a fanout 3 -> 9", From: "//app:a", Value: 9}
+	if scoring.Fingerprint("fanout_increase", a) != scoring.Fingerprint("fanout_increase", b) {
+		t.Error("fingerprint changed with the summary and value of the same finding")
+	}
+	if scoring.Fingerprint("fanout_increase", a) == scoring.Fingerprint("blast_radius", a) {
+		t.Error("fingerprint ignores the metric")
+	}
+	moved := scoring.EvidenceItem{From: "", To: "//app:a"}
+	if scoring.Fingerprint("fanout_increase", a) == scoring.Fingerprint("fanout_increase", moved) {
+		t.Error("fingerprint doesn't distinguish from and to")
+	}
+
+	noTargets := []scoring.EvidenceItem{{Summary: "3 new repos"}, {Summary: "4 new repos"}}
+	if scoring.Fingerprint("x", noTargets[0]) == scoring.Fingerprint("x", noTargets[1]) {
+		t.Error("evidence without targets should fall back to its summary")
+	}
+}
+
+func TestAssignFingerprints(t *testing.T) {
+	result := &scoring.ScoreResult{Breakdown: []scoring.MetricResult{{
+		Key: "cross_package_deps",
+		Evidence: []scoring.EvidenceItem{
+			{From: "//a:a", To: "//b:b"},
+			{From: "//a:a", To: "//c:c", Fingerprint: "kept"},
+		},
+	}}}
+	result.AssignFingerprints()
+	ev := result.Breakdown[0].Evidence
+	if ev[0].Fingerprint != scoring.Fingerprint("cross_package_deps", ev[0]) {
+		t.Errorf("fingerprint = %q", ev[0].Fingerprint)
+	}
+	if ev[1].Fingerprint != "kept" {
+		t.Errorf("existing fingerprint overwritten: %q", ev[1].Fingerprint)
+	}
+}
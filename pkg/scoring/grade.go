@@ -0,0 +1,49 @@
+package scoring
+
+import "fmt"
+
+// GradeThresholds holds the inclusive upper score bound for each letter
+// grade. Scores above D are graded F.
+type GradeThresholds struct {
+	A float64 `json:"a"`
+	B float64 `json:"b"`
+	C float64 `json:"c"`
+	D float64 `json:"d"`
+}
+
+// DefaultGradeThresholds returns the standard grade boundaries.
+func DefaultGradeThresholds() GradeThresholds {
+	return GradeThresholds{A: 3, B: 7, C: 14, D: 24}
+}
+
+// Validate checks that the thresholds are non-negative and non-decreasing.
+func (t GradeThresholds) Validate() error {
+	if t.A < 0 {
+		return fmt.Errorf("grade A threshold must be non-negative, got %g", t.A)
+	}
+	if t.B < t.A || t.C < t.B || t.D < t.C {
+		return fmt.Errorf("grade thresholds must be non-decreasing (A <= B <= C <= D), got %g/%g/%g/%g", t.A, t.B, t.C, t.D)
+	}
+	return nil
+}
+
+// Grade maps a score to a letter grade.
+func (t GradeThresholds) Grade(score float64) string {
+	switch {
+	case score <= t.A:
+		return "A"
+	case score <= t.B:
+		return "B"
+	case score <= t.C:
+		return "C"
+	case score <= t.D:
+		return "D"
+	default:
+		return "F"
+	}
+}
+
+// GradeFromScore maps a total score to a letter grade using the default thresholds.
+func GradeFromScore(score float64) string {
+	return DefaultGradeThresholds().Grade(score)
+}
@@ -0,0 +1,89 @@
+package scoring_test
+
+import (
+	"testing"
+
+	"github.com/toposcope/toposcope/pkg/graph"
+	"github.com/toposcope/toposcope/pkg/scoring"
+)
+
+func TestGradeFromScore(t *testing.T) {
+	tests := []struct {
+		score float64
+		want  string
+	}{
+		{0, "A"},
+		{3, "A"},
+		{3.1, "B"},
+		{7, "B"},
+		{14, "C"},
+		{24, "D"},
+		{24.5, "F"},
+	}
+
+	for _, tt := range tests {
+		if got := scoring.GradeFromScore(tt.score); got != tt.want {
+			t.Errorf("GradeFromScore(%v) = %q, want %q", tt.score, got, tt.want)
+		}
+	}
+}
+
+func TestGradeThresholds(t *testing.T) {
+	strict := scoring.GradeThresholds{A: 1, B: 2, C: 4, D: 8}
+
+	tests := []struct {
+		score float64
+		want  string
+	}{
+		{1, "A"},
+		{1.5, "B"},
+		{3, "C"},
+		{8, "D"},
+		{9, "F"},
+	}
+	for _, tt := range tests {
+		if got := strict.Grade(tt.score); got != tt.want {
+			t.Errorf("Grade(%v) = %q, want %q", tt.score, got, tt.want)
+		}
+	}
+
+	if err := strict.Validate(); err != nil {
+		t.Errorf("Validate() unexpected error: %v", err)
+	}
+	if err := (scoring.GradeThresholds{A: 5, B: 2, C: 4, D: 8}).Validate(); err == nil {
+		t.Error("expected error for decreasing thresholds")
+	}
+	if err := (scoring.GradeThresholds{A: -1, B: 2, C: 4, D: 8}).Validate(); err == nil {
+		t.Error("expected error for negative threshold")
+	}
+}
+
+func TestEngineGradeThresholds(t *testing.T) {
+	empty := &graph.Snapshot{Nodes: map[string]*graph.Node{}}
+	metric := stubMetric{scoring.MetricResult{Key: "stub", Name: "Stub", Contribution: 5}}
+
+	result, err := scoring.NewEngine(metric).Score(&graph.Delta{}, empty, empty)
+	if err != nil {
+		t.Fatalf("Score() error: %v", err)
+	}
+	if result.Grade != "B" {
+		t.Errorf("default Grade = %q, want B", result.Grade)
+	}
+	if result.GradeThresholds != scoring.DefaultGradeThresholds() {
+		t.Errorf("expected default thresholds echoed, got %+v", result.GradeThresholds)
+	}
+
+	strict := scoring.GradeThresholds{A: 1, B: 2, C: 3, D: 4}
+	engine := scoring.NewEngine(metric)
+	engine.SetGradeThresholds(strict)
+	result, err = engine.Score(&graph.Delta{}, empty, empty)
+	if err != nil {
+		t.Fatalf("Score() error: %v", err)
+	}
+	if result.Grade != "F" {
+		t.Errorf("strict Grade = %q, want F", result.Grade)
+	}
+	if result.GradeThresholds != strict {
+		t.Errorf("expected configured thresholds echoed, got %+v", result.GradeThresholds)
+	}
+}
@@ -0,0 +1,109 @@
+package scoring
+
+import (
+	"hash/fnv"
+	"math"
+	"math/rand/v2"
+	"sort"
+
+	"github.com/toposcope/toposcope/pkg/graph"
+)
+
+// intervalSamples is the number of bootstrap resamples behind a
+// ScoreInterval.
+const intervalSamples = 200
+
+// ScoreInterval is a bootstrap confidence interval for the graded score:
+// NormalizedScore under a normalization mode, TotalScore otherwise. It shows
+// how much the score hinges on a few of the change's nodes and edges; a wide
+// interval means a handful of them decide the grade.
+type ScoreInterval struct {
+	Level   float64 `json:"level"` // e.g. 0.9 for a 90% interval
+	Low     float64 `json:"low"`
+	High    float64 `json:"high"`
+	Samples int     `json:"samples"`
+}
+
+// SetConfidenceLevel enables a confidence interval on scores at the given
+// level, between 0 and 1 (e.g. 0.9). Each interval re-runs the metrics on
+// resampled deltas, so it multiplies scoring time. 0 disables it.
+func (e *Engine) SetConfidenceLevel(level float64) {
+	e.confidence = level
+}
+
+// scoreInterval computes the percentile bootstrap interval of the graded
+// score. Each sample redraws the delta's added and removed nodes and edges
+// with replacement and re-runs the metrics against it. External metrics run
+// a process per evaluation, so their contributions are held at the observed
+// value.
+func (e *Engine) scoreInterval(delta *graph.Delta, base, head *graph.Snapshot, waivers []Waiver, result *ScoreResult) *ScoreInterval {
+	var fixed float64
+	var metrics []Metric
+	for i, m := range e.metrics {
+		if _, ok := m.(*ExternalMetric); ok {
+			fixed += result.Breakdown[i].Contribution
+			continue
+		}
+		metrics = append(metrics, m)
+	}
+	factor := 1.0
+	if result.Normalization == NormalizationSize {
+		factor = result.SizeFactor
+	}
+
+	h := fnv.New64a()
+	h.Write([]byte(result.BaseCommit + ".." + result.HeadCommit))
+	rng := rand.New(rand.NewPCG(h.Sum64(), uint64(len(delta.AddedEdges)+len(delta.RemovedEdges))))
+
+	scores := make([]float64, intervalSamples)
+	for i := range scores {
+		sample := resampleDelta(rng, delta)
+		total := fixed
+		for _, m := range metrics {
+			mr := m.Evaluate(sample, base, head)
+			applyWaivers(&mr, waivers, nil)
+			total += mr.Contribution
+		}
+		scores[i] = math.Max(total, 0) * factor
+	}
+	sort.Float64s(scores)
+
+	tail := (1 - e.confidence) / 2
+	return &ScoreInterval{
+		Level:   e.confidence,
+		Low:     quantile(scores, tail),
+		High:    quantile(scores, 1-tail),
+		Samples: intervalSamples,
+	}
+}
+
+// resampleDelta returns a copy of d whose added and removed nodes and edges
+// are each drawn with replacement from d's, keeping their counts.
+func resampleDelta(rng *rand.Rand, d *graph.Delta) *graph.Delta {
+	sample := *d
+	sample.AddedNodes = resample(rng, d.AddedNodes)
+	sample.RemovedNodes = resample(rng, d.RemovedNodes)
+	sample.AddedEdges = resample(rng, d.AddedEdges)
+	sample.RemovedEdges = resample(rng, d.RemovedEdges)
+	return &sample
+}
+
+func resample[T any](rng *rand.Rand, items []T) []T {
+	if len(items) == 0 {
+		return items
+	}
+	out := make([]T, len(items))
+	for i := range out {
+		out[i] = items[rng.IntN(len(items))]
+	}
+	return out
+}
+
+// quantile returns the q-quantile of sorted values, interpolating linearly
+// between neighbors.
+func quantile(sorted []float64, q float64) float64 {
+	pos := q * float64(len(sorted)-1)
+	lo := int(math.Floor(pos))
+	hi := int(math.Ceil(pos))
+	return sorted[lo] + (sorted[hi]-sorted[lo])*(pos-float64(lo))
+}
@@ -17,6 +17,19 @@ type BlastRadiusMetric struct {
 func (m *BlastRadiusMetric) Key() string  { return "blast_radius" }
 func (m *BlastRadiusMetric) Name() string { return "Blast radius" }
 
+// Config reports the settings this metric scored with.
+func (m *BlastRadiusMetric) Config() map[string]any {
+	return map[string]any{
+		"weight":           m.Weight,
+		"max_contribution": m.MaxContribution,
+	}
+}
+
+// Description explains what this metric scores.
+func (m *BlastRadiusMetric) Description() string {
+	return "Sums the base in-degree of every target the change touches: the ends of added and removed edges, and added and removed targets. Test targets count 0.3×, and external repositories not at all. Scores weight × log2(1 + sum), up to max_contribution."
+}
+
 func (m *BlastRadiusMetric) Evaluate(delta *graph.Delta, base, head *graph.Snapshot) MetricResult {
 	result := MetricResult{
 		Key:      m.Key(),
@@ -59,6 +72,11 @@ func (m *BlastRadiusMetric) Evaluate(delta *graph.Delta, base, head *graph.Snaps
 	var nodeDegs []nodeWithDeg
 
 	for key := range affected {
+		// External repo nodes aggregate many targets, so their in-degree
+		// overstates the impact of a single change.
+		if isExternalNode(key, base, head) {
+			continue
+		}
 		deg := baseInDeg[key]
 		weight := 1.0
 		if node := base.Nodes[key]; node != nil && node.IsTest {
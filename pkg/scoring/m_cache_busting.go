@@ -0,0 +1,137 @@
+package scoring
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/toposcope/toposcope/pkg/graph"
+)
+
+// CacheBustingMetric (M9) flags added edges that invalidate a large part of
+// the remote cache. Adding a dependency changes the action keys of its
+// source and everything that depends on it transitively, so their cached
+// results miss on the next build. The share of cache hits those targets had
+// is what the edge busts. It needs cache stats on the head snapshot and
+// scores nothing without them.
+type CacheBustingMetric struct {
+	Weight          float64 // score for busting every cache hit
+	MinShare        float64 // ignore sources that bust less than this share
+	MaxContribution float64 // cap on contribution
+}
+
+func (m *CacheBustingMetric) Key() string  { return "cache_busting" }
+func (m *CacheBustingMetric) Name() string { return "Cache-busting change" }
+
+// Config reports the settings this metric scored with.
+func (m *CacheBustingMetric) Config() map[string]any {
+	return map[string]any{
+		"weight":           m.Weight,
+		"min_share":        m.MinShare,
+		"max_contribution": m.MaxContribution,
+	}
+}
+
+// Description explains what this metric scores.
+func (m *CacheBustingMetric) Description() string {
+	return "Finds the targets each added edge invalidates: its source and everything that depends on it. Sources that bust less than min_share of the build's cache hits are ignored. Scores weight × the share of cache hits the invalidated targets held, up to max_contribution. It needs cache stats on the head snapshot."
+}
+
+func (m *CacheBustingMetric) Evaluate(delta *graph.Delta, base, head *graph.Snapshot) MetricResult {
+	result := MetricResult{
+		Key:      m.Key(),
+		Name:     m.Name(),
+		Severity: SeverityInfo,
+	}
+
+	var totalHits int
+	for _, n := range head.Nodes {
+		totalHits += n.CacheHits
+	}
+	if totalHits == 0 || len(delta.AddedEdges) == 0 {
+		return result
+	}
+
+	added := make(map[string]int)
+	for _, e := range delta.AddedEdges {
+		if head.Nodes[e.From] != nil {
+			added[e.From]++
+		}
+	}
+
+	ix := graph.NewIndex(head)
+	type busted struct {
+		source  string
+		edges   int
+		targets []int32
+		hits    int
+	}
+	var sources []busted
+	for source, edges := range added {
+		id, ok := ix.ID(source)
+		if !ok {
+			continue
+		}
+		b := busted{source: source, edges: edges}
+		seen := map[int32]bool{id: true}
+		queue := []int32{id}
+		for len(queue) > 0 {
+			cur := queue[0]
+			queue = queue[1:]
+			b.targets = append(b.targets, cur)
+			if n := head.Nodes[ix.Key(cur)]; n != nil {
+				b.hits += n.CacheHits
+			}
+			for _, r := range ix.RDeps(cur) {
+				if !seen[r] {
+					seen[r] = true
+					queue = append(queue, r)
+				}
+			}
+		}
+		if float64(b.hits)/float64(totalHits) >= m.MinShare && b.hits > 0 {
+			sources = append(sources, b)
+		}
+	}
+	sort.Slice(sources, func(i, j int) bool {
+		if sources[i].hits != sources[j].hits {
+			return sources[i].hits > sources[j].hits
+		}
+		return sources[i].source < sources[j].source
+	})
+
+	// Sources often share dependents, so count each busted target once.
+	union := make(map[int32]bool)
+	var unionHits int
+	var maxShare float64
+	for _, b := range sources {
+		share := float64(b.hits) / float64(totalHits)
+		maxShare = max(maxShare, share)
+		for _, id := range b.targets {
+			if !union[id] {
+				union[id] = true
+				if n := head.Nodes[ix.Key(id)]; n != nil {
+					unionHits += n.CacheHits
+				}
+			}
+		}
+		result.Evidence = append(result.Evidence, EvidenceItem{
+			Type: EvidenceCacheBusting,
+			Summary: fmt.Sprintf("%s gains %d dep(s) and invalidates %d cached actions across %d targets (%.0f%% of cache hits)",
+				b.source, b.edges, b.hits, len(b.targets), share*100),
+			From:  b.source,
+			Value: share,
+		})
+	}
+
+	result.Contribution = min(m.Weight*float64(unionHits)/float64(totalHits), m.MaxContribution)
+	switch {
+	case maxShare >= 0.5:
+		result.Severity = SeverityHigh
+	case maxShare >= 0.2:
+		result.Severity = SeverityMedium
+	case len(sources) > 0:
+		result.Severity = SeverityLow
+	}
+
+	return result
+}
@@ -0,0 +1,59 @@
+package scoring_test
+
+import (
+	"testing"
+
+	"github.com/toposcope/toposcope/pkg/graph"
+	"github.com/toposcope/toposcope/pkg/scoring"
+)
+
+func TestCacheBustingMetric(t *testing.T) {
+	hits := map[string]int{
+		"//app:bin":   10,
+		"//app:tool":  10,
+		"//lib/a:a":   30,
+		"//lib/b:b":   40,
+		"//lib/c:c":   5,
+		"//leaf:leaf": 5,
+	}
+	head := &graph.Snapshot{Nodes: map[string]*graph.Node{}, Edges: []graph.Edge{
+		{From: "//app:bin", To: "//lib/a:a", Type: "COMPILE"},
+		{From: "//app:tool", To: "//lib/a:a", Type: "COMPILE"},
+		{From: "//lib/a:a", To: "//lib/b:b", Type: "COMPILE"},
+		{From: "//lib/b:b", To: "//lib/c:c", Type: "COMPILE"},
+		{From: "//app:bin", To: "//leaf:leaf", Type: "COMPILE"},
+	}}
+	for key, h := range hits {
+		head.Nodes[key] = &graph.Node{Key: key, Package: graph.LabelPackage(key), CacheSpawns: 100, CacheHits: h}
+	}
+	// //lib/b:b and its dependents hold 90 of the 100 cache hits. //app:bin
+	// alone holds 10, which it shares with //lib/b:b's dependents.
+	delta := &graph.Delta{AddedEdges: []graph.Edge{
+		{From: "//lib/b:b", To: "//lib/c:c", Type: "COMPILE"},
+		{From: "//app:bin", To: "//leaf:leaf", Type: "COMPILE"},
+	}}
+
+	m := &scoring.CacheBustingMetric{Weight: 10, MinShare: 0.05, MaxContribution: 10}
+	result := m.Evaluate(delta, head, head)
+
+	if result.Key != "cache_busting" {
+		t.Errorf("expected key cache_busting, got %s", result.Key)
+	}
+	if len(result.Evidence) != 2 || result.Evidence[0].From != "//lib/b:b" || result.Evidence[0].Value != 0.9 {
+		t.Fatalf("unexpected evidence: %+v", result.Evidence)
+	}
+	if result.Contribution != 9 {
+		t.Errorf("expected contribution 9 for 90%% of hits, got %f", result.Contribution)
+	}
+	if result.Severity != scoring.SeverityHigh {
+		t.Errorf("expected high severity, got %s", result.Severity)
+	}
+
+	// Without cache stats the metric scores nothing.
+	for _, n := range head.Nodes {
+		n.CacheSpawns, n.CacheHits = 0, 0
+	}
+	if result := m.Evaluate(delta, head, head); result.Contribution != 0 || len(result.Evidence) != 0 {
+		t.Errorf("expected no contribution without cache stats, got %+v", result)
+	}
+}
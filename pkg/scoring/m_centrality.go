@@ -12,11 +12,30 @@ type CentralityMetric struct {
 	Weight          float64 // score multiplier
 	MinInDegree     int     // only apply for targets above this in-degree in base
 	MaxContribution float64 // safety cap on total contribution (0 = no cap)
+	// IncludeGenerated scores new dependencies of and on generated targets
+	// too. Generated targets are widely depended on by design, so they are
+	// skipped by default.
+	IncludeGenerated bool
 }
 
 func (m *CentralityMetric) Key() string  { return "centrality_penalty" }
 func (m *CentralityMetric) Name() string { return "Centrality penalty" }
 
+// Config reports the settings this metric scored with.
+func (m *CentralityMetric) Config() map[string]any {
+	return map[string]any{
+		"weight":            m.Weight,
+		"min_in_degree":     m.MinInDegree,
+		"max_contribution":  m.MaxContribution,
+		"include_generated": m.IncludeGenerated,
+	}
+}
+
+// Description explains what this metric scores.
+func (m *CentralityMetric) Description() string {
+	return "Scores each added dependency on a target that had at least min_in_degree dependents in base, adding weight × log2(1 + in-degree). The total is capped at max_contribution. Generated targets are skipped unless include_generated is set."
+}
+
 func (m *CentralityMetric) Evaluate(delta *graph.Delta, base, head *graph.Snapshot) MetricResult {
 	result := MetricResult{
 		Key:      m.Key(),
@@ -48,6 +67,13 @@ func (m *CentralityMetric) Evaluate(delta *graph.Delta, base, head *graph.Snapsh
 		if srcNode := head.Nodes[edge.From]; srcNode != nil && srcNode.IsTest {
 			continue
 		}
+		// External repos aggregate many targets; third_party_exposure scores them.
+		if dstNode := head.Nodes[edge.To]; dstNode != nil && dstNode.IsExternal {
+			continue
+		}
+		if !m.IncludeGenerated && (isGenerated(head, edge.From) || isGenerated(head, edge.To)) {
+			continue
+		}
 
 		if _, ok := destMap[edge.To]; !ok {
 			destMap[edge.To] = &destInfo{}
@@ -91,3 +117,9 @@ func (m *CentralityMetric) Evaluate(delta *graph.Delta, base, head *graph.Snapsh
 
 	return result
 }
+
+// isGenerated reports whether key is a generated target in snap.
+func isGenerated(snap *graph.Snapshot, key string) bool {
+	n := snap.Nodes[key]
+	return n != nil && n.IsGenerated
+}
@@ -236,3 +236,38 @@ func TestCentralityMetric_MaxContribution(t *testing.T) {
 		t.Errorf("expected contribution to be exactly the cap 10.0, got %f", result.Contribution)
 	}
 }
+
+func TestCentralityMetric_SkipGenerated(t *testing.T) {
+	baseNodes := map[string]*graph.Node{
+		"//proto:api_go_proto": {Key: "//proto:api_go_proto", Package: "//proto", IsGenerated: true},
+	}
+	var baseEdges []graph.Edge
+	for i := 0; i < 60; i++ {
+		key := "//dep" + string(rune('a'+i%26)) + string(rune('0'+i/26)) + ":lib"
+		baseNodes[key] = &graph.Node{Key: key, Package: "//dep"}
+		baseEdges = append(baseEdges, graph.Edge{From: key, To: "//proto:api_go_proto", Type: "COMPILE"})
+	}
+
+	base := &graph.Snapshot{Nodes: baseNodes, Edges: baseEdges}
+	head := &graph.Snapshot{
+		Nodes: map[string]*graph.Node{
+			"//proto:api_go_proto": {Key: "//proto:api_go_proto", Package: "//proto", IsGenerated: true},
+			"//app:server":         {Key: "//app:server", Package: "//app"},
+		},
+	}
+	delta := &graph.Delta{
+		AddedEdges: []graph.Edge{
+			{From: "//app:server", To: "//proto:api_go_proto", Type: "COMPILE"},
+		},
+	}
+
+	m := &scoring.CentralityMetric{Weight: 0.7, MinInDegree: 50}
+	if got := m.Evaluate(delta, base, head).Contribution; got != 0 {
+		t.Errorf("expected zero contribution for a generated target, got %f", got)
+	}
+
+	m.IncludeGenerated = true
+	if got := m.Evaluate(delta, base, head).Contribution; got == 0 {
+		t.Error("expected a contribution with IncludeGenerated")
+	}
+}
@@ -12,11 +12,28 @@ type CreditsMetric struct {
 	MaxCreditTotal              float64 // max total credit for edge removals (negative value)
 	PerFanoutReduction          float64 // credit per unit of fanout reduction (negative value)
 	FanoutMaxCredit             float64 // max total credit for fanout reduction (negative value)
+	CrossLanguage               bool    // credit removed cross-language edges as cross-boundary
 }
 
 func (m *CreditsMetric) Key() string  { return "cleanup_credits" }
 func (m *CreditsMetric) Name() string { return "Cleanup credits" }
 
+// Config reports the settings this metric scored with.
+func (m *CreditsMetric) Config() map[string]any {
+	return map[string]any{
+		"per_removed_cross_boundary_edge": m.PerRemovedCrossBoundaryEdge,
+		"max_credit_total":                m.MaxCreditTotal,
+		"per_fanout_reduction":            m.PerFanoutReduction,
+		"fanout_max_credit":               m.FanoutMaxCredit,
+		"cross_language":                  m.CrossLanguage,
+	}
+}
+
+// Description explains what this metric scores.
+func (m *CreditsMetric) Description() string {
+	return "Credits cleanup with a negative contribution. Each removed cross-boundary edge earns per_removed_cross_boundary_edge, down to max_credit_total. Each unit of fanout reduction earns per_fanout_reduction, down to fanout_max_credit."
+}
+
 func (m *CreditsMetric) Evaluate(delta *graph.Delta, base, head *graph.Snapshot) MetricResult {
 	result := MetricResult{
 		Key:      m.Key(),
@@ -56,6 +73,11 @@ func (m *CreditsMetric) Evaluate(delta *graph.Delta, base, head *graph.Snapshot)
 
 		srcBoundary := topLevelDir(srcPkg)
 		tgtBoundary := topLevelDir(tgtPkg)
+		if m.CrossLanguage {
+			if srcLang, tgtLang, ok := crossesLanguages(srcNode, tgtNode); ok {
+				srcBoundary, tgtBoundary = srcLang, tgtLang
+			}
+		}
 
 		if srcBoundary != tgtBoundary {
 			edgeCredit += m.PerRemovedCrossBoundaryEdge
@@ -0,0 +1,165 @@
+package scoring
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/toposcope/toposcope/pkg/graph"
+)
+
+// CriticalPathMetric (M8) penalizes added edges from targets on the critical
+// build path, the chain of dependencies with the most build time. Each edge
+// is weighted by the share of the critical path's time the chain below its
+// target takes. It needs build durations on the head snapshot and scores
+// nothing without them.
+type CriticalPathMetric struct {
+	Weight          float64 // per edge, scaled by its share of the path
+	MaxContribution float64 // cap on contribution
+}
+
+func (m *CriticalPathMetric) Key() string  { return "critical_path" }
+func (m *CriticalPathMetric) Name() string { return "Critical build path" }
+
+// Config reports the settings this metric scored with.
+func (m *CriticalPathMetric) Config() map[string]any {
+	return map[string]any{
+		"weight":           m.Weight,
+		"max_contribution": m.MaxContribution,
+	}
+}
+
+// Description explains what this metric scores.
+func (m *CriticalPathMetric) Description() string {
+	return "Scores each added edge from a target on the critical build path, adding weight × the share of the path's build time taken by the chain below the edge's target. The total is capped at max_contribution. It needs build durations on the head snapshot."
+}
+
+func (m *CriticalPathMetric) Evaluate(delta *graph.Delta, base, head *graph.Snapshot) MetricResult {
+	result := MetricResult{
+		Key:      m.Key(),
+		Name:     m.Name(),
+		Severity: SeverityInfo,
+	}
+	if len(delta.AddedEdges) == 0 || !graph.HasTimings(head) {
+		return result
+	}
+
+	path, chain := criticalPath(head)
+	if len(path) == 0 {
+		return result
+	}
+	total := chain[path[0]]
+	onPath := make(map[string]bool, len(path))
+	for _, key := range path {
+		onPath[key] = true
+	}
+
+	var edges []graph.Edge
+	for _, e := range delta.AddedEdges {
+		if onPath[e.From] && chain[e.To] > 0 {
+			edges = append(edges, e)
+		}
+	}
+	sort.Slice(edges, func(i, j int) bool {
+		if chain[edges[i].To] != chain[edges[j].To] {
+			return chain[edges[i].To] > chain[edges[j].To]
+		}
+		return edges[i].EdgeKey() < edges[j].EdgeKey()
+	})
+
+	var score, maxShare float64
+	for _, e := range edges {
+		share := float64(chain[e.To]) / float64(total)
+		maxShare = max(maxShare, share)
+		score += m.Weight * share
+		result.Evidence = append(result.Evidence, EvidenceItem{
+			Type: EvidenceCriticalPath,
+			Summary: fmt.Sprintf("%s -> %s puts %.1fs of build time on the critical path (%.0f%% of %.1fs)",
+				e.From, e.To, float64(chain[e.To])/1000, share*100, float64(total)/1000),
+			From:  e.From,
+			To:    e.To,
+			Value: float64(chain[e.To]),
+		})
+	}
+
+	result.Contribution = min(score, m.MaxContribution)
+	switch {
+	case maxShare >= 0.5:
+		result.Severity = SeverityHigh
+	case len(edges) > 0:
+		result.Severity = SeverityMedium
+	}
+
+	return result
+}
+
+// criticalPath returns the chain of dependencies with the most build time in
+// snap, starting at the target that waits on it, and for every node the
+// build time of the longest chain from it, itself included. Edges that close
+// a cycle are ignored.
+func criticalPath(snap *graph.Snapshot) ([]string, map[string]int64) {
+	deps := make(map[string][]string)
+	for _, e := range snap.Edges {
+		deps[e.From] = append(deps[e.From], e.To)
+	}
+	keys := make([]string, 0, len(snap.Nodes))
+	for key := range snap.Nodes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	chain := make(map[string]int64, len(keys))
+	next := make(map[string]string)
+	const (
+		visiting = iota + 1
+		done
+	)
+	state := make(map[string]int, len(keys))
+	type frame struct {
+		key string
+		i   int
+	}
+	for _, root := range keys {
+		if state[root] != 0 {
+			continue
+		}
+		stack := []frame{{root, 0}}
+		state[root] = visiting
+		for len(stack) > 0 {
+			f := &stack[len(stack)-1]
+			if f.i < len(deps[f.key]) {
+				dep := deps[f.key][f.i]
+				f.i++
+				if state[dep] == 0 && snap.Nodes[dep] != nil {
+					state[dep] = visiting
+					stack = append(stack, frame{dep, 0})
+				}
+				continue
+			}
+			var best int64
+			for _, dep := range deps[f.key] {
+				if state[dep] == done && (chain[dep] > best || (chain[dep] == best && best > 0 && dep < next[f.key])) {
+					best = chain[dep]
+					next[f.key] = dep
+				}
+			}
+			chain[f.key] = snap.Nodes[f.key].BuildMs + best
+			state[f.key] = done
+			stack = stack[:len(stack)-1]
+		}
+	}
+
+	var start string
+	for _, key := range keys {
+		if chain[key] > chain[start] {
+			start = key
+		}
+	}
+	if chain[start] == 0 {
+		return nil, chain
+	}
+	var path []string
+	for key := start; key != ""; key = next[key] {
+		path = append(path, key)
+	}
+	return path, chain
+}
@@ -0,0 +1,56 @@
+package scoring_test
+
+import (
+	"testing"
+
+	"github.com/toposcope/toposcope/pkg/graph"
+	"github.com/toposcope/toposcope/pkg/scoring"
+)
+
+func criticalPathSnapshot(buildMs map[string]int64, edges ...graph.Edge) *graph.Snapshot {
+	snap := &graph.Snapshot{Nodes: map[string]*graph.Node{}, Edges: edges}
+	for key, ms := range buildMs {
+		snap.Nodes[key] = &graph.Node{Key: key, Package: graph.LabelPackage(key), BuildMs: ms}
+	}
+	return snap
+}
+
+func TestCriticalPathMetric(t *testing.T) {
+	durations := map[string]int64{
+		"//app:bin":  1000,
+		"//lib/a:a":  2000,
+		"//lib/b:b":  6000,
+		"//lib/c:c":  500,
+		"//tools:tl": 100,
+	}
+	onPath := graph.Edge{From: "//app:bin", To: "//lib/b:b", Type: "COMPILE"}
+	offPath := graph.Edge{From: "//tools:tl", To: "//lib/c:c", Type: "COMPILE"}
+	head := criticalPathSnapshot(durations,
+		graph.Edge{From: "//app:bin", To: "//lib/a:a", Type: "COMPILE"},
+		onPath,
+		graph.Edge{From: "//lib/a:a", To: "//lib/c:c", Type: "COMPILE"},
+		offPath,
+	)
+	delta := &graph.Delta{AddedEdges: []graph.Edge{onPath, offPath}}
+
+	m := &scoring.CriticalPathMetric{Weight: 4, MaxContribution: 12}
+	result := m.Evaluate(delta, head, head)
+
+	// The critical path is //app:bin -> //lib/b:b, 7s. The new edge puts 6s
+	// of it on the path; //tools:tl is not on it.
+	if len(result.Evidence) != 1 || result.Evidence[0].To != "//lib/b:b" {
+		t.Fatalf("expected evidence for the critical path edge only, got %+v", result.Evidence)
+	}
+	if want := 4 * 6000.0 / 7000; result.Contribution != want {
+		t.Errorf("expected contribution %f, got %f", want, result.Contribution)
+	}
+	if result.Severity != scoring.SeverityHigh {
+		t.Errorf("expected high severity, got %s", result.Severity)
+	}
+
+	// Without durations the metric scores nothing.
+	bare := criticalPathSnapshot(map[string]int64{"//app:bin": 0, "//lib/b:b": 0, "//lib/c:c": 0, "//tools:tl": 0}, head.Edges...)
+	if result := m.Evaluate(delta, bare, bare); result.Contribution != 0 || len(result.Evidence) != 0 {
+		t.Errorf("expected no contribution without durations, got %+v", result)
+	}
+}
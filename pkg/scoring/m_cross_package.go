@@ -8,15 +8,35 @@ import (
 )
 
 // CrossPackageMetric (M1) detects new edges that cross package boundaries.
+// Edges to targets of another tracked repository, mapped with repo_mapping,
+// cross the repository boundary and score as cross-boundary.
 type CrossPackageMetric struct {
 	IntraBoundaryWeight float64  // weight for edges crossing packages within the same top-level dir
 	CrossBoundaryWeight float64  // weight for edges crossing top-level directory boundaries
-	Boundaries          []string // auto-detected from head snapshot if empty
+	Boundaries          []string // package paths ("app", "services/payments"); top-level dirs if empty
+	// CrossLanguage scores edges between targets of different languages
+	// as cross-boundary, even within a top-level directory.
+	CrossLanguage bool
 }
 
 func (m *CrossPackageMetric) Key() string  { return "cross_package_deps" }
 func (m *CrossPackageMetric) Name() string { return "Cross-package dependencies" }
 
+// Config reports the settings this metric scored with.
+func (m *CrossPackageMetric) Config() map[string]any {
+	return map[string]any{
+		"intra_boundary_weight": m.IntraBoundaryWeight,
+		"cross_boundary_weight": m.CrossBoundaryWeight,
+		"boundaries":            m.Boundaries,
+		"cross_language":        m.CrossLanguage,
+	}
+}
+
+// Description explains what this metric scores.
+func (m *CrossPackageMetric) Description() string {
+	return "Scores each added edge from a non-test target to a target in another package. An edge within one boundary (top-level directory) adds intra_boundary_weight. An edge across boundaries, to another tracked repository, or between languages with cross_language set adds cross_boundary_weight. Boundaries are the top-level directories of the head snapshot unless configured."
+}
+
 func (m *CrossPackageMetric) Evaluate(delta *graph.Delta, base, head *graph.Snapshot) MetricResult {
 	result := MetricResult{
 		Key:      m.Key(),
@@ -24,11 +44,6 @@ func (m *CrossPackageMetric) Evaluate(delta *graph.Delta, base, head *graph.Snap
 		Severity: SeverityMedium,
 	}
 
-	boundaries := m.Boundaries
-	if len(boundaries) == 0 {
-		boundaries = detectBoundaries(head)
-	}
-
 	var contribution float64
 
 	for _, edge := range delta.AddedEdges {
@@ -39,8 +54,8 @@ func (m *CrossPackageMetric) Evaluate(delta *graph.Delta, base, head *graph.Snap
 		if srcNode != nil && srcNode.IsTest {
 			continue
 		}
-		// Skip if target is external
-		if tgtNode != nil && tgtNode.IsExternal {
+		// Skip if target is external, unless it is in a tracked repository
+		if tgtNode != nil && tgtNode.IsExternal && tgtNode.Repo == "" {
 			continue
 		}
 		// Skip proto deps
@@ -61,8 +76,15 @@ func (m *CrossPackageMetric) Evaluate(delta *graph.Delta, base, head *graph.Snap
 			continue
 		}
 
-		srcBoundary := topLevelDir(srcPkg)
-		tgtBoundary := topLevelDir(tgtPkg)
+		srcBoundary := boundaryOf(srcPkg, m.Boundaries)
+		tgtBoundary := boundaryOf(tgtPkg, m.Boundaries)
+		if tgtNode.Repo != "" {
+			tgtBoundary = tgtNode.Repo
+		} else if m.CrossLanguage {
+			if srcLang, tgtLang, ok := crossesLanguages(srcNode, tgtNode); ok {
+				srcBoundary, tgtBoundary = srcLang, tgtLang
+			}
+		}
 
 		if srcBoundary == tgtBoundary {
 			// Intra-boundary cross-package
@@ -87,8 +109,6 @@ func (m *CrossPackageMetric) Evaluate(delta *graph.Delta, base, head *graph.Snap
 		}
 	}
 
-	_ = boundaries // boundaries used for auto-detection above
-
 	result.Contribution = contribution
 	switch {
 	case contribution > 5:
@@ -102,6 +122,24 @@ func (m *CrossPackageMetric) Evaluate(delta *graph.Delta, base, head *graph.Snap
 	return result
 }
 
+// boundaryOf returns the boundary of a package: the longest of boundaries
+// that is the package or one of its parents, or else its top-level directory.
+// "//services/payments/api" is in "services/payments" when that is configured.
+func boundaryOf(pkg string, boundaries []string) string {
+	p := strings.TrimPrefix(pkg, "//")
+	best := ""
+	for _, b := range boundaries {
+		b = strings.Trim(strings.TrimPrefix(b, "//"), "/")
+		if (p == b || strings.HasPrefix(p, b+"/")) && len(b) > len(best) {
+			best = b
+		}
+	}
+	if best != "" {
+		return best
+	}
+	return topLevelDir(pkg)
+}
+
 // topLevelDir extracts the first path component from a Bazel package label.
 // "//app/auth" -> "app", "//lib/session" -> "lib"
 func topLevelDir(pkg string) string {
@@ -113,18 +151,12 @@ func topLevelDir(pkg string) string {
 	return p
 }
 
-// detectBoundaries enumerates unique first path components from all packages in the snapshot.
-func detectBoundaries(snap *graph.Snapshot) []string {
-	seen := make(map[string]bool)
-	for _, node := range snap.Nodes {
-		if node.Package != "" {
-			b := topLevelDir(node.Package)
-			seen[b] = true
-		}
-	}
-	var boundaries []string
-	for b := range seen {
-		boundaries = append(boundaries, b)
+// crossesLanguages reports whether an edge joins targets of two different
+// known languages, and returns them.
+func crossesLanguages(src, tgt *graph.Node) (string, string, bool) {
+	if src == nil || tgt == nil {
+		return "", "", false
 	}
-	return boundaries
+	srcLang, tgtLang := graph.LanguageOf(src), graph.LanguageOf(tgt)
+	return srcLang, tgtLang, srcLang != "" && tgtLang != "" && srcLang != tgtLang
 }
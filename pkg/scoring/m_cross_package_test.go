@@ -130,6 +130,36 @@ func TestCrossPackageMetric_SkipsExternalTarget(t *testing.T) {
 	}
 }
 
+func TestCrossPackageMetric_MappedRepoTarget(t *testing.T) {
+	base := &graph.Snapshot{
+		Nodes: map[string]*graph.Node{},
+	}
+	head := &graph.Snapshot{
+		Nodes: map[string]*graph.Node{
+			"//app/auth:handler":      {Key: "//app/auth:handler", Package: "//app/auth"},
+			"@corp_pay//client:lib":   {Key: "@corp_pay//client:lib", Package: "@corp_pay//client", IsExternal: true, Repo: "acme/payments"},
+			"@corp_pay//app/auth:lib": {Key: "@corp_pay//app/auth:lib", Package: "@corp_pay//app/auth", IsExternal: true, Repo: "acme/payments"},
+		},
+	}
+	delta := &graph.Delta{
+		AddedEdges: []graph.Edge{
+			{From: "//app/auth:handler", To: "@corp_pay//client:lib", Type: "COMPILE"},
+			{From: "//app/auth:handler", To: "@corp_pay//app/auth:lib", Type: "COMPILE"},
+		},
+	}
+
+	m := &scoring.CrossPackageMetric{
+		IntraBoundaryWeight: 0.5,
+		CrossBoundaryWeight: 1.5,
+	}
+
+	// Both edges leave the repository, whatever the package path.
+	result := m.Evaluate(delta, base, head)
+	if result.Contribution != 3.0 {
+		t.Errorf("expected two cross-boundary edges, got contribution %f", result.Contribution)
+	}
+}
+
 func TestCrossPackageMetric_SkipsProtoTarget(t *testing.T) {
 	base := &graph.Snapshot{
 		Nodes: map[string]*graph.Node{},
@@ -183,3 +213,59 @@ func TestCrossPackageMetric_SamePackageNoScore(t *testing.T) {
 		t.Errorf("expected zero contribution for same-package edge, got %f", result.Contribution)
 	}
 }
+
+func TestCrossPackageMetric_CrossLanguage(t *testing.T) {
+	head := &graph.Snapshot{
+		Nodes: map[string]*graph.Node{
+			"//app/api:server": {Key: "//app/api:server", Kind: "go_library", Package: "//app/api"},
+			"//app/jni:bridge": {Key: "//app/jni:bridge", Kind: "java_library", Package: "//app/jni"},
+			"//app/util:lib":   {Key: "//app/util:lib", Kind: "go_library", Package: "//app/util"},
+		},
+	}
+	delta := &graph.Delta{
+		AddedEdges: []graph.Edge{
+			{From: "//app/api:server", To: "//app/jni:bridge", Type: "COMPILE"},
+			{From: "//app/api:server", To: "//app/util:lib", Type: "COMPILE"},
+		},
+	}
+
+	m := &scoring.CrossPackageMetric{IntraBoundaryWeight: 0.5, CrossBoundaryWeight: 1.5}
+	if got := m.Evaluate(delta, head, head).Contribution; got != 1.0 {
+		t.Errorf("without CrossLanguage: contribution %v, want 1.0", got)
+	}
+
+	m.CrossLanguage = true
+	result := m.Evaluate(delta, head, head)
+	// go -> java is cross-boundary; go -> go stays intra-boundary
+	if result.Contribution != 2.0 {
+		t.Errorf("with CrossLanguage: contribution %v, want 2.0", result.Contribution)
+	}
+	if got := result.Evidence[0].Summary; got != "Cross-boundary edge: //app/api:server -> //app/jni:bridge (go -> java)" {
+		t.Errorf("evidence = %q", got)
+	}
+}
+
+func TestCrossPackageMetric_ConfiguredBoundaries(t *testing.T) {
+	nodes := map[string]*graph.Node{
+		"//services/orders/api:lib":   {Key: "//services/orders/api:lib", Package: "//services/orders/api"},
+		"//services/orders/store:lib": {Key: "//services/orders/store:lib", Package: "//services/orders/store"},
+		"//services/payments:lib":     {Key: "//services/payments:lib", Package: "//services/payments"},
+	}
+	snap := &graph.Snapshot{Nodes: nodes}
+	delta := &graph.Delta{
+		AddedEdges: []graph.Edge{
+			{From: "//services/orders/api:lib", To: "//services/orders/store:lib", Type: "COMPILE"},
+			{From: "//services/orders/api:lib", To: "//services/payments:lib", Type: "COMPILE"},
+		},
+	}
+
+	m := &scoring.CrossPackageMetric{IntraBoundaryWeight: 0.5, CrossBoundaryWeight: 1.5}
+	if got := m.Evaluate(delta, snap, snap).Contribution; got != 1.0 {
+		t.Errorf("top-level boundaries: contribution = %f, want 1.0 (both edges inside services)", got)
+	}
+
+	m.Boundaries = []string{"services/orders", "//services/payments"}
+	if got := m.Evaluate(delta, snap, snap).Contribution; got != 2.0 {
+		t.Errorf("configured boundaries: contribution = %f, want 2.0 (one intra, one cross)", got)
+	}
+}
@@ -0,0 +1,116 @@
+package scoring
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/toposcope/toposcope/pkg/graph"
+)
+
+// defaultExternalMetricTimeout bounds how long an external metric may run.
+const defaultExternalMetricTimeout = 60 * time.Second
+
+// ExternalMetricInput is the JSON document written to an external metric's stdin.
+type ExternalMetricInput struct {
+	Delta *graph.Delta    `json:"delta"`
+	Base  *graph.Snapshot `json:"base"`
+	Head  *graph.Snapshot `json:"head"`
+}
+
+// ExternalMetric runs a user-supplied binary as a scoring metric, so teams can
+// write custom metrics in any language. The binary receives an
+// ExternalMetricInput on stdin and must print a MetricResult as JSON on stdout.
+type ExternalMetric struct {
+	MetricKey  string
+	MetricName string
+	Command    string
+	Args       []string
+	Dir        string        // working directory for the command
+	Timeout    time.Duration // default 60s
+}
+
+func (m *ExternalMetric) Key() string  { return m.MetricKey }
+func (m *ExternalMetric) Name() string { return m.MetricName }
+
+// Config reports the settings this metric scored with.
+func (m *ExternalMetric) Config() map[string]any {
+	return map[string]any{
+		"command": m.Command,
+		"args":    m.Args,
+		"timeout": m.timeout().String(),
+	}
+}
+
+// Description explains what this metric scores.
+func (m *ExternalMetric) Description() string {
+	return "Scored by the external command " + m.Command + ", which reads the delta and snapshots as JSON and writes its result."
+}
+
+// Evaluate runs the external command. Failures never abort scoring: they are
+// reported as a zero-contribution result carrying the error as evidence.
+func (m *ExternalMetric) Evaluate(delta *graph.Delta, base, head *graph.Snapshot) MetricResult {
+	result, err := m.run(delta, base, head)
+	if err != nil {
+		return MetricResult{
+			Key:      m.Key(),
+			Name:     m.Name(),
+			Severity: SeverityInfo,
+			Evidence: []EvidenceItem{{
+				Type:    EvidenceExternal,
+				Summary: fmt.Sprintf("external metric failed: %v", err),
+			}},
+		}
+	}
+	return result
+}
+
+func (m *ExternalMetric) timeout() time.Duration {
+	if m.Timeout <= 0 {
+		return defaultExternalMetricTimeout
+	}
+	return m.Timeout
+}
+
+func (m *ExternalMetric) run(delta *graph.Delta, base, head *graph.Snapshot) (MetricResult, error) {
+	input, err := json.Marshal(ExternalMetricInput{Delta: delta, Base: base, Head: head})
+	if err != nil {
+		return MetricResult{}, fmt.Errorf("encoding input: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), m.timeout())
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, m.Command, m.Args...)
+	cmd.Dir = m.Dir
+	cmd.Stdin = bytes.NewReader(input)
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return MetricResult{}, fmt.Errorf("running %s: %w: %s", m.Command, err, strings.TrimSpace(stderr.String()))
+	}
+
+	var result MetricResult
+	if err := json.Unmarshal(stdout.Bytes(), &result); err != nil {
+		return MetricResult{}, fmt.Errorf("parsing output of %s: %w", m.Command, err)
+	}
+
+	// The configured identity wins so the breakdown stays stable even if the
+	// binary reports a different key.
+	result.Key = m.Key()
+	if m.MetricName != "" {
+		result.Name = m.MetricName
+	}
+	if result.Severity == "" {
+		result.Severity = SeverityInfo
+	}
+
+	return result, nil
+}
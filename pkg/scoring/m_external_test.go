@@ -0,0 +1,79 @@
+package scoring_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/toposcope/toposcope/pkg/graph"
+	"github.com/toposcope/toposcope/pkg/scoring"
+)
+
+func TestExternalMetric(t *testing.T) {
+	base := &graph.Snapshot{Nodes: map[string]*graph.Node{}}
+	head := &graph.Snapshot{Nodes: map[string]*graph.Node{}}
+	delta := &graph.Delta{}
+
+	tests := []struct {
+		name             string
+		script           string
+		wantContribution float64
+		wantSeverity     scoring.Severity
+		wantEvidence     string
+	}{
+		{
+			name:             "valid result",
+			script:           `read -r input; echo '{"key":"other","contribution":2.5,"severity":"MEDIUM","evidence":[{"type":"EDGE_ADDED","summary":"custom finding"}]}'`,
+			wantContribution: 2.5,
+			wantSeverity:     scoring.SeverityMedium,
+			wantEvidence:     "custom finding",
+		},
+		{
+			name:             "reads input",
+			script:           `grep -q '"delta"' && echo '{"contribution":1}'`,
+			wantContribution: 1,
+			wantSeverity:     scoring.SeverityInfo,
+		},
+		{
+			name:         "non-zero exit",
+			script:       `echo boom >&2; exit 3`,
+			wantSeverity: scoring.SeverityInfo,
+			wantEvidence: "external metric failed",
+		},
+		{
+			name:         "invalid JSON",
+			script:       `echo not-json`,
+			wantSeverity: scoring.SeverityInfo,
+			wantEvidence: "parsing output",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			m := &scoring.ExternalMetric{
+				MetricKey:  "custom_metric",
+				MetricName: "Custom metric",
+				Command:    "sh",
+				Args:       []string{"-c", tc.script},
+			}
+
+			result := m.Evaluate(delta, base, head)
+			if result.Key != "custom_metric" {
+				t.Errorf("Key = %q, want custom_metric", result.Key)
+			}
+			if result.Name != "Custom metric" {
+				t.Errorf("Name = %q, want Custom metric", result.Name)
+			}
+			if result.Contribution != tc.wantContribution {
+				t.Errorf("Contribution = %f, want %f", result.Contribution, tc.wantContribution)
+			}
+			if result.Severity != tc.wantSeverity {
+				t.Errorf("Severity = %q, want %q", result.Severity, tc.wantSeverity)
+			}
+			if tc.wantEvidence != "" {
+				if len(result.Evidence) == 0 || !strings.Contains(result.Evidence[0].Summary, tc.wantEvidence) {
+					t.Errorf("Evidence = %+v, want summary containing %q", result.Evidence, tc.wantEvidence)
+				}
+			}
+		})
+	}
+}
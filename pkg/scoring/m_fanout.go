@@ -12,11 +12,29 @@ type FanoutMetric struct {
 	Weight       float64 // score contribution per unit of fanout increase
 	CapPerNode   float64 // max contribution from a single node
 	MinThreshold int     // only score if out_degree(head) > this
+	// IncludeGenerated scores generated targets too. Their dependencies
+	// follow from their sources, so they are skipped by default.
+	IncludeGenerated bool
 }
 
 func (m *FanoutMetric) Key() string  { return "fanout_increase" }
 func (m *FanoutMetric) Name() string { return "Fanout increase" }
 
+// Config reports the settings this metric scored with.
+func (m *FanoutMetric) Config() map[string]any {
+	return map[string]any{
+		"weight":            m.Weight,
+		"cap_per_node":      m.CapPerNode,
+		"min_threshold":     m.MinThreshold,
+		"include_generated": m.IncludeGenerated,
+	}
+}
+
+// Description explains what this metric scores.
+func (m *FanoutMetric) Description() string {
+	return "Scores each target whose out-degree grows and ends above min_threshold, adding weight × min(increase, cap_per_node). Test and external targets are skipped, and generated targets too unless include_generated is set."
+}
+
 func (m *FanoutMetric) Evaluate(delta *graph.Delta, base, head *graph.Snapshot) MetricResult {
 	result := MetricResult{
 		Key:      m.Key(),
@@ -30,7 +48,7 @@ func (m *FanoutMetric) Evaluate(delta *graph.Delta, base, head *graph.Snapshot)
 	var contribution float64
 
 	for key, node := range head.Nodes {
-		if node.IsTest || node.IsExternal {
+		if node.IsTest || node.IsExternal || (node.IsGenerated && !m.IncludeGenerated) {
 			continue
 		}
 
@@ -136,3 +136,27 @@ func TestFanoutMetric_NoIncrease(t *testing.T) {
 		t.Errorf("expected zero for no fanout increase, got %f", result.Contribution)
 	}
 }
+
+func TestFanoutMetric_SkipGenerated(t *testing.T) {
+	headNodes := map[string]*graph.Node{
+		"//proto:api_go_proto": {Key: "//proto:api_go_proto", Package: "//proto", IsGenerated: true},
+	}
+	var headEdges []graph.Edge
+	for i := 0; i < 15; i++ {
+		key := "//dep" + string(rune('a'+i)) + ":lib"
+		headNodes[key] = &graph.Node{Key: key, Package: "//dep" + string(rune('a'+i))}
+		headEdges = append(headEdges, graph.Edge{From: "//proto:api_go_proto", To: key, Type: "COMPILE"})
+	}
+	base := &graph.Snapshot{Nodes: map[string]*graph.Node{}}
+	head := &graph.Snapshot{Nodes: headNodes, Edges: headEdges}
+
+	m := &scoring.FanoutMetric{Weight: 0.5, CapPerNode: 10, MinThreshold: 10}
+	if got := m.Evaluate(&graph.Delta{}, base, head).Contribution; got != 0 {
+		t.Errorf("expected zero contribution for a generated target, got %f", got)
+	}
+
+	m.IncludeGenerated = true
+	if got := m.Evaluate(&graph.Delta{}, base, head).Contribution; got != 5.0 {
+		t.Errorf("with IncludeGenerated: contribution %f, want 5.0", got)
+	}
+}
@@ -0,0 +1,59 @@
+package scoring
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/toposcope/toposcope/pkg/graph"
+	"github.com/toposcope/toposcope/pkg/graphquery"
+)
+
+// PackageCycleMetric (M4) penalizes added edges that close a new cycle in
+// the package graph.
+type PackageCycleMetric struct {
+	Weight          float64 // per new cycle
+	MaxContribution float64 // cap on contribution
+}
+
+func (m *PackageCycleMetric) Key() string  { return "package_cycles" }
+func (m *PackageCycleMetric) Name() string { return "New package cycles" }
+
+// Config reports the settings this metric scored with.
+func (m *PackageCycleMetric) Config() map[string]any {
+	return map[string]any{
+		"weight":           m.Weight,
+		"max_contribution": m.MaxContribution,
+	}
+}
+
+// Description explains what this metric scores.
+func (m *PackageCycleMetric) Description() string {
+	return "Adds weight for each new cycle in the package graph that an added edge closes, up to max_contribution."
+}
+
+func (m *PackageCycleMetric) Evaluate(delta *graph.Delta, base, head *graph.Snapshot) MetricResult {
+	result := MetricResult{
+		Key:      m.Key(),
+		Name:     m.Name(),
+		Severity: SeverityInfo,
+	}
+
+	cycles := graphquery.NewPackageCycles(base, head, delta.AddedEdges)
+	for _, c := range cycles {
+		e := c.Edges[0]
+		result.Evidence = append(result.Evidence, EvidenceItem{
+			Type:    EvidencePackageCycle,
+			Summary: fmt.Sprintf("%s -> %s closes a cycle of %d packages: %s", e.From, e.To, len(c.Packages), strings.Join(c.Path, " -> ")),
+			From:    e.From,
+			To:      e.To,
+			Value:   float64(len(c.Packages)),
+		})
+	}
+
+	result.Contribution = min(m.Weight*float64(len(cycles)), m.MaxContribution)
+	if len(cycles) > 0 {
+		result.Severity = SeverityHigh
+	}
+
+	return result
+}
@@ -0,0 +1,41 @@
+package scoring_test
+
+import (
+	"testing"
+
+	"github.com/toposcope/toposcope/pkg/graph"
+	"github.com/toposcope/toposcope/pkg/scoring"
+)
+
+func TestPackageCycleMetric_NewCycle(t *testing.T) {
+	nodes := func() map[string]*graph.Node {
+		return map[string]*graph.Node{
+			"//app/a:lib": {Key: "//app/a:lib", Package: "//app/a"},
+			"//app/b:lib": {Key: "//app/b:lib", Package: "//app/b"},
+		}
+	}
+	forward := graph.Edge{From: "//app/a:lib", To: "//app/b:lib", Type: "COMPILE"}
+	back := graph.Edge{From: "//app/b:lib", To: "//app/a:lib", Type: "COMPILE"}
+	base := &graph.Snapshot{Nodes: nodes(), Edges: []graph.Edge{forward}}
+	head := &graph.Snapshot{Nodes: nodes(), Edges: []graph.Edge{forward, back}}
+	delta := &graph.Delta{AddedEdges: []graph.Edge{back}}
+
+	m := &scoring.PackageCycleMetric{Weight: 5, MaxContribution: 15}
+	result := m.Evaluate(delta, base, head)
+
+	if result.Key != "package_cycles" {
+		t.Errorf("expected key package_cycles, got %s", result.Key)
+	}
+	if result.Contribution != 5 || result.Severity != scoring.SeverityHigh {
+		t.Errorf("expected contribution 5 at high severity, got %f at %s", result.Contribution, result.Severity)
+	}
+	if len(result.Evidence) != 1 || result.Evidence[0].Type != scoring.EvidencePackageCycle {
+		t.Fatalf("expected 1 package cycle evidence item, got %+v", result.Evidence)
+	}
+
+	// The same edge is no news when the cycle is already in base.
+	result = m.Evaluate(delta, head, head)
+	if result.Contribution != 0 || len(result.Evidence) != 0 {
+		t.Errorf("expected no contribution for an existing cycle, got %f", result.Contribution)
+	}
+}
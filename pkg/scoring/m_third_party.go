@@ -0,0 +1,140 @@
+package scoring
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/toposcope/toposcope/pkg/graph"
+)
+
+// ThirdPartyMetric (M7) penalizes new direct dependencies from production
+// targets on external repositories (@maven, @pip, ...). It only has an effect
+// when snapshots are extracted with external nodes retained. Targets of
+// external repos mapped to tracked repositories are first-party and left to
+// cross_package_deps.
+type ThirdPartyMetric struct {
+	EdgeWeight      float64  // per new production edge to an external repo
+	NewRepoWeight   float64  // extra penalty when production code had no prior dependency on the repo
+	MaxContribution float64  // cap on contribution
+	Allow           []string // external repos exempt from the penalty, e.g. "@com_google_protobuf"
+}
+
+func (m *ThirdPartyMetric) Key() string  { return "third_party_exposure" }
+func (m *ThirdPartyMetric) Name() string { return "Third-party exposure" }
+
+// Config reports the settings this metric scored with.
+func (m *ThirdPartyMetric) Config() map[string]any {
+	return map[string]any{
+		"edge_weight":      m.EdgeWeight,
+		"new_repo_weight":  m.NewRepoWeight,
+		"max_contribution": m.MaxContribution,
+		"allow":            m.Allow,
+	}
+}
+
+// Description explains what this metric scores.
+func (m *ThirdPartyMetric) Description() string {
+	return "Scores new direct dependencies of production targets on external repositories. Each new edge adds edge_weight, and a repository production code didn't depend on before adds new_repo_weight once. The total is capped at max_contribution. Repositories in allow are exempt. It needs snapshots extracted with include_external."
+}
+
+func (m *ThirdPartyMetric) Evaluate(delta *graph.Delta, base, head *graph.Snapshot) MetricResult {
+	result := MetricResult{
+		Key:      m.Key(),
+		Name:     m.Name(),
+		Severity: SeverityInfo,
+	}
+
+	allowed := make(map[string]bool, len(m.Allow))
+	for _, repo := range m.Allow {
+		allowed["@"+strings.TrimLeft(repo, "@")] = true
+	}
+
+	// Group new production edges by external repo.
+	sources := make(map[string][]string)
+	for _, edge := range delta.AddedEdges {
+		tgtNode := head.Nodes[edge.To]
+		if tgtNode == nil || !tgtNode.IsExternal || tgtNode.Repo != "" || allowed[edge.To] {
+			continue
+		}
+		if srcNode := head.Nodes[edge.From]; srcNode == nil || srcNode.IsTest {
+			continue
+		}
+		sources[edge.To] = append(sources[edge.To], edge.From)
+	}
+
+	if len(sources) == 0 {
+		return result
+	}
+
+	previouslyUsed := productionExternalRepos(base)
+
+	repos := make([]string, 0, len(sources))
+	for repo := range sources {
+		repos = append(repos, repo)
+	}
+	sort.Strings(repos)
+
+	var contribution float64
+	for _, repo := range repos {
+		from := sources[repo]
+		sort.Strings(from)
+
+		penalty := m.EdgeWeight * float64(len(from))
+		summary := fmt.Sprintf("%d new direct dependencies on %s from %s", len(from), repo, strings.Join(from, ", "))
+		if !previouslyUsed[repo] {
+			penalty += m.NewRepoWeight
+			summary = fmt.Sprintf("New external repo %s: %s", repo, summary)
+		}
+		contribution += penalty
+
+		result.Evidence = append(result.Evidence, EvidenceItem{
+			Type:    EvidenceThirdParty,
+			Summary: summary,
+			From:    from[0],
+			To:      repo,
+			Value:   float64(len(from)),
+		})
+	}
+
+	if contribution > m.MaxContribution {
+		contribution = m.MaxContribution
+	}
+	result.Contribution = contribution
+
+	switch {
+	case contribution > 5:
+		result.Severity = SeverityHigh
+	case contribution > 0:
+		result.Severity = SeverityMedium
+	}
+
+	return result
+}
+
+// productionExternalRepos returns the external repos that non-test targets
+// in snap depend on directly.
+func productionExternalRepos(snap *graph.Snapshot) map[string]bool {
+	used := make(map[string]bool)
+	for _, edge := range snap.Edges {
+		tgt := snap.Nodes[edge.To]
+		if tgt == nil || !tgt.IsExternal {
+			continue
+		}
+		if src := snap.Nodes[edge.From]; src != nil && !src.IsTest {
+			used[edge.To] = true
+		}
+	}
+	return used
+}
+
+// isExternalNode reports whether key is an external node in either snapshot.
+func isExternalNode(key string, base, head *graph.Snapshot) bool {
+	if n := head.Nodes[key]; n != nil {
+		return n.IsExternal
+	}
+	if n := base.Nodes[key]; n != nil {
+		return n.IsExternal
+	}
+	return false
+}
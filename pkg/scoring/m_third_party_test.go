@@ -0,0 +1,101 @@
+package scoring_test
+
+import (
+	"testing"
+
+	"github.com/toposcope/toposcope/pkg/graph"
+	"github.com/toposcope/toposcope/pkg/scoring"
+)
+
+func thirdPartySnapshots() (*graph.Snapshot, *graph.Snapshot) {
+	nodes := func() map[string]*graph.Node {
+		return map[string]*graph.Node{
+			"//app/api:lib":  {Key: "//app/api:lib", Package: "//app/api"},
+			"//app/web:lib":  {Key: "//app/web:lib", Package: "//app/web"},
+			"//app/api:test": {Key: "//app/api:test", Package: "//app/api", IsTest: true},
+			"@maven":         {Key: "@maven", Package: "@maven", IsExternal: true},
+			"@pip":           {Key: "@pip", Package: "@pip", IsExternal: true},
+			"@com_google_protobuf": {
+				Key: "@com_google_protobuf", Package: "@com_google_protobuf", IsExternal: true,
+			},
+		}
+	}
+	base := &graph.Snapshot{
+		Nodes: nodes(),
+		Edges: []graph.Edge{
+			{From: "//app/api:lib", To: "@maven", Type: "COMPILE"},
+		},
+	}
+	head := &graph.Snapshot{Nodes: nodes()}
+	return base, head
+}
+
+func TestThirdPartyMetric(t *testing.T) {
+	base, head := thirdPartySnapshots()
+	delta := &graph.Delta{
+		AddedEdges: []graph.Edge{
+			{From: "//app/web:lib", To: "@maven", Type: "COMPILE"},
+			{From: "//app/web:lib", To: "@pip", Type: "COMPILE"},
+			{From: "//app/api:lib", To: "@pip", Type: "COMPILE"},
+			{From: "//app/api:test", To: "@pip", Type: "COMPILE"},         // test source: ignored
+			{From: "//app/api:lib", To: "//app/web:lib", Type: "COMPILE"}, // internal: ignored
+		},
+	}
+
+	m := &scoring.ThirdPartyMetric{EdgeWeight: 0.5, NewRepoWeight: 3, MaxContribution: 10}
+	result := m.Evaluate(delta, base, head)
+
+	if result.Key != "third_party_exposure" {
+		t.Errorf("expected key third_party_exposure, got %s", result.Key)
+	}
+	// @maven: 1 edge (already used) = 0.5; @pip: 2 edges + new repo = 4.0
+	if result.Contribution != 4.5 {
+		t.Errorf("expected contribution 4.5, got %f", result.Contribution)
+	}
+	if len(result.Evidence) != 2 {
+		t.Fatalf("expected evidence per external repo, got %d", len(result.Evidence))
+	}
+	if result.Evidence[0].To != "@maven" || result.Evidence[1].To != "@pip" || result.Evidence[1].Value != 2 {
+		t.Errorf("unexpected evidence: %+v", result.Evidence)
+	}
+}
+
+func TestThirdPartyMetric_AllowList(t *testing.T) {
+	base, head := thirdPartySnapshots()
+	delta := &graph.Delta{
+		AddedEdges: []graph.Edge{
+			{From: "//app/web:lib", To: "@com_google_protobuf", Type: "COMPILE"},
+			{From: "//app/web:lib", To: "@pip", Type: "COMPILE"},
+		},
+	}
+
+	m := &scoring.ThirdPartyMetric{
+		EdgeWeight:      0.5,
+		NewRepoWeight:   3,
+		MaxContribution: 10,
+		Allow:           []string{"com_google_protobuf", "@pip"},
+	}
+	result := m.Evaluate(delta, base, head)
+
+	if result.Contribution != 0 || len(result.Evidence) != 0 {
+		t.Errorf("expected allow-listed repos to be exempt, got %f with %d evidence", result.Contribution, len(result.Evidence))
+	}
+	if result.Severity != scoring.SeverityInfo {
+		t.Errorf("expected INFO severity, got %s", result.Severity)
+	}
+}
+
+func TestThirdPartyMetric_SkipsMappedRepo(t *testing.T) {
+	base, head := thirdPartySnapshots()
+	head.Nodes["@corp_pay//client:lib"] = &graph.Node{Key: "@corp_pay//client:lib", Package: "@corp_pay//client", IsExternal: true, Repo: "acme/payments"}
+	delta := &graph.Delta{
+		AddedEdges: []graph.Edge{
+			{From: "//app/web:lib", To: "@corp_pay//client:lib", Type: "COMPILE"},
+		},
+	}
+
+	m := &scoring.ThirdPartyMetric{EdgeWeight: 0.5, NewRepoWeight: 3, MaxContribution: 10}
+	if result := m.Evaluate(delta, base, head); result.Contribution != 0 || len(result.Evidence) != 0 {
+		t.Errorf("tracked repositories are first-party, got %+v", result)
+	}
+}
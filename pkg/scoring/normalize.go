@@ -0,0 +1,38 @@
+package scoring
+
+import (
+	"math"
+
+	"github.com/toposcope/toposcope/pkg/graph"
+)
+
+// Normalization selects how a raw score is adjusted for repository size.
+type Normalization string
+
+const (
+	// NormalizationNone grades the raw score as-is.
+	NormalizationNone Normalization = ""
+	// NormalizationSize scales the raw score by the head snapshot's size so
+	// grades are comparable across small and very large repositories.
+	NormalizationSize Normalization = "size"
+)
+
+const (
+	// sizeReference is the graph size (nodes + edges) at which the size
+	// factor is exactly 1.
+	sizeReference = 10000
+	// maxSizeFactor bounds how much a tiny graph's score can be inflated.
+	maxSizeFactor = 2.0
+)
+
+// SizeFactor returns the multiplier applied to raw scores under
+// NormalizationSize. Larger graphs naturally accumulate larger raw
+// contributions, so the factor shrinks logarithmically with graph size:
+// ln(reference) / ln(nodes + edges), capped at 2.
+func SizeFactor(snap *graph.Snapshot) float64 {
+	size := float64(len(snap.Nodes) + len(snap.Edges))
+	if size < math.E {
+		return maxSizeFactor
+	}
+	return math.Min(math.Log(sizeReference)/math.Log(size), maxSizeFactor)
+}
@@ -0,0 +1,36 @@
+package scoring
+
+import "github.com/toposcope/toposcope/pkg/graph"
+
+// scopeDelta restricts a delta computed against a partial head snapshot to
+// the changes the scoped extraction can actually vouch for. Nodes and edges
+// outside the extraction scope are simply absent from a partial head, so they
+// show up as spurious removals (and would otherwise earn cleanup credits).
+//
+// Kept: all additions; removed nodes that are scope targets (the extraction
+// saw them disappear); removed edges whose source was re-extracted.
+func scopeDelta(delta *graph.Delta, head *graph.Snapshot) *graph.Delta {
+	inScope := make(map[string]bool, len(head.Scope))
+	for _, target := range head.Scope {
+		inScope[target] = true
+	}
+
+	scoped := *delta
+	scoped.RemovedNodes = nil
+	scoped.RemovedEdges = nil
+
+	for _, n := range delta.RemovedNodes {
+		if inScope[n.Key] {
+			scoped.RemovedNodes = append(scoped.RemovedNodes, n)
+		}
+	}
+	for _, e := range delta.RemovedEdges {
+		if _, extracted := head.Nodes[e.From]; extracted || inScope[e.From] {
+			scoped.RemovedEdges = append(scoped.RemovedEdges, e)
+		}
+	}
+
+	scoped.Stats.RemovedNodeCount = len(scoped.RemovedNodes)
+	scoped.Stats.RemovedEdgeCount = len(scoped.RemovedEdges)
+	return &scoped
+}
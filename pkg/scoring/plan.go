@@ -0,0 +1,263 @@
+package scoring
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/toposcope/toposcope/pkg/graph"
+)
+
+// Plan step kinds.
+const (
+	StepRemoveEdge  = "remove_edge"
+	StepSplitTarget = "split_target"
+)
+
+// maxPlanCandidates bounds the steps evaluated in each round of planning.
+const maxPlanCandidates = 50
+
+// PlanStep is one proposed refactoring. Its scores are estimates, computed by
+// rescoring the change with this step and every earlier one applied.
+type PlanStep struct {
+	Kind        string `json:"kind"` // remove_edge or split_target
+	Title       string `json:"title"`
+	Description string `json:"description"`
+
+	// Edge is the edge a remove_edge step drops.
+	Edge *graph.Edge `json:"edge,omitempty"`
+	// Target is the target a split_target step splits, NewTarget the target
+	// split out of it, and Dependents the targets moved onto NewTarget.
+	Target     string   `json:"target,omitempty"`
+	NewTarget  string   `json:"new_target,omitempty"`
+	Dependents []string `json:"dependents,omitempty"`
+
+	ScoreBefore float64 `json:"score_before"`
+	ScoreAfter  float64 `json:"score_after"`
+	Improvement float64 `json:"improvement"`
+	GradeAfter  string  `json:"grade_after"`
+}
+
+// RefactoringPlan is a sequence of refactorings that would lower a change's
+// score, best first.
+type RefactoringPlan struct {
+	BaseCommit   string     `json:"base_commit"`
+	HeadCommit   string     `json:"head_commit"`
+	InitialScore float64    `json:"initial_score"`
+	InitialGrade string     `json:"initial_grade"`
+	FinalScore   float64    `json:"final_score"`
+	FinalGrade   string     `json:"final_grade"`
+	Steps        []PlanStep `json:"steps"`
+}
+
+// Plan proposes up to maxSteps refactorings (0 means 5) that lower the
+// score of the change from base to head. It works greedily from the score's
+// evidence and hotspots: each round it tries removing every added edge that
+// a metric flagged, and splitting every flagged target that the change adds
+// several dependents to, then keeps whichever lowers the score most. A split
+// moves the new dependents onto a new target and assumes it needs none of
+// the original's dependencies, so its estimate is a best case. Planning
+// stops when no step helps.
+func (e *Engine) Plan(delta *graph.Delta, base, head *graph.Snapshot, maxSteps int) (*RefactoringPlan, error) {
+	if maxSteps <= 0 {
+		maxSteps = 5
+	}
+	if head != nil && head.Partial && base != nil {
+		head = graph.MergeIntoBaseline(base, head)
+	}
+
+	score := func(h *graph.Snapshot) (*ScoreResult, error) {
+		d := graph.ComputeDelta(base, h)
+		if delta != nil {
+			d.ImpactedTargets = delta.ImpactedTargets
+			d.Stats.ImpactedTargetCount = delta.Stats.ImpactedTargetCount
+		}
+		return e.Score(d, base, h)
+	}
+
+	current, err := e.Score(delta, base, head)
+	if err != nil {
+		return nil, err
+	}
+	plan := &RefactoringPlan{
+		BaseCommit:   current.BaseCommit,
+		HeadCommit:   current.HeadCommit,
+		InitialScore: current.TotalScore,
+		InitialGrade: current.Grade,
+		Steps:        []PlanStep{},
+	}
+
+	for len(plan.Steps) < maxSteps && current.TotalScore > 0 {
+		var best *PlanStep
+		var bestHead *graph.Snapshot
+		var bestResult *ScoreResult
+		for _, c := range planCandidates(current, base, head) {
+			result, err := score(c.head)
+			if err != nil {
+				return nil, err
+			}
+			if gain := current.TotalScore - result.TotalScore; gain > 1e-9 && (best == nil || gain > best.Improvement) {
+				step := c.step
+				step.ScoreBefore, step.ScoreAfter = current.TotalScore, result.TotalScore
+				step.Improvement, step.GradeAfter = gain, result.Grade
+				best, bestHead, bestResult = &step, c.head, result
+			}
+		}
+		if best == nil {
+			break
+		}
+		plan.Steps = append(plan.Steps, *best)
+		head, current = bestHead, bestResult
+	}
+
+	plan.FinalScore, plan.FinalGrade = current.TotalScore, current.Grade
+	return plan, nil
+}
+
+type planCandidate struct {
+	step PlanStep
+	head *graph.Snapshot
+}
+
+// planCandidates lists the steps worth trying against result: removals of
+// added edges named in evidence, and splits of flagged targets.
+func planCandidates(result *ScoreResult, base, head *graph.Snapshot) []planCandidate {
+	inBase := make(map[string]bool, len(base.Edges))
+	for _, e := range base.Edges {
+		inBase[e.EdgeKey()] = true
+	}
+	added := make(map[[2]string][]graph.Edge)
+	for _, e := range head.Edges {
+		if !inBase[e.EdgeKey()] {
+			added[[2]string{e.From, e.To}] = append(added[[2]string{e.From, e.To}], e)
+		}
+	}
+
+	// Weigh each flagged edge and target by the contribution behind it.
+	edgeWeight := make(map[[2]string]float64)
+	targetWeight := make(map[string]float64)
+	for _, mr := range result.Breakdown {
+		if mr.Contribution <= 0 || len(mr.Evidence) == 0 {
+			continue
+		}
+		share := mr.Contribution / float64(len(mr.Evidence))
+		for _, ev := range mr.Evidence {
+			if pair := [2]string{ev.From, ev.To}; added[pair] != nil {
+				edgeWeight[pair] += share
+			}
+			if ev.To != "" {
+				targetWeight[ev.To] += share
+			}
+		}
+	}
+	for _, hs := range result.Hotspots {
+		targetWeight[hs.NodeKey] += hs.ScoreContribution
+	}
+
+	var candidates []planCandidate
+	for _, pair := range rankedKeys(edgeWeight, func(p [2]string) string { return p[0] + "\x00" + p[1] }) {
+		edge := added[pair][0]
+		candidates = append(candidates, planCandidate{
+			step: PlanStep{
+				Kind:        StepRemoveEdge,
+				Title:       fmt.Sprintf("Remove the dependency %s -> %s", pair[0], pair[1]),
+				Description: fmt.Sprintf("Drop the new edge from %s to %s, or route it through an existing dependency.", pair[0], pair[1]),
+				Edge:        &edge,
+			},
+			head: withoutEdges(head, pair),
+		})
+	}
+
+	for _, target := range rankedKeys(targetWeight, func(k string) string { return k }) {
+		node := head.Nodes[target]
+		if node == nil || base.Nodes[target] == nil {
+			continue
+		}
+		var dependents []string
+		for pair := range added {
+			if pair[1] == target {
+				dependents = append(dependents, pair[0])
+			}
+		}
+		if len(dependents) < 2 {
+			continue
+		}
+		sort.Strings(dependents)
+		newTarget := target + "_api"
+		if head.Nodes[newTarget] != nil {
+			continue
+		}
+		candidates = append(candidates, planCandidate{
+			step: PlanStep{
+				Kind:  StepSplitTarget,
+				Title: fmt.Sprintf("Split %s", target),
+				Description: fmt.Sprintf("Move what the %d new dependents of %s use into a narrower target, %s, so they don't take on all of %s's dependents and dependencies.",
+					len(dependents), target, newTarget, target),
+				Target:     target,
+				NewTarget:  newTarget,
+				Dependents: dependents,
+			},
+			head: withSplit(head, node, newTarget, dependents),
+		})
+	}
+
+	if len(candidates) > maxPlanCandidates {
+		candidates = candidates[:maxPlanCandidates]
+	}
+	return candidates
+}
+
+// rankedKeys returns the keys of weights, heaviest first, with ties broken by
+// id so planning is deterministic.
+func rankedKeys[K comparable](weights map[K]float64, id func(K) string) []K {
+	keys := make([]K, 0, len(weights))
+	for k := range weights {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if weights[keys[i]] != weights[keys[j]] {
+			return weights[keys[i]] > weights[keys[j]]
+		}
+		return id(keys[i]) < id(keys[j])
+	})
+	return keys
+}
+
+// withoutEdges returns a copy of snap without the edges from pair[0] to
+// pair[1].
+func withoutEdges(snap *graph.Snapshot, pair [2]string) *graph.Snapshot {
+	out := *snap
+	out.Edges = make([]graph.Edge, 0, len(snap.Edges))
+	for _, e := range snap.Edges {
+		if e.From != pair[0] || e.To != pair[1] {
+			out.Edges = append(out.Edges, e)
+		}
+	}
+	return &out
+}
+
+// withSplit returns a copy of snap with a new target copied from node, and
+// the edges from dependents to node pointed at it instead.
+func withSplit(snap *graph.Snapshot, node *graph.Node, newTarget string, dependents []string) *graph.Snapshot {
+	moved := make(map[string]bool, len(dependents))
+	for _, d := range dependents {
+		moved[d] = true
+	}
+
+	out := *snap
+	out.Nodes = make(map[string]*graph.Node, len(snap.Nodes)+1)
+	for k, n := range snap.Nodes {
+		out.Nodes[k] = n
+	}
+	split := *node
+	split.Key = newTarget
+	out.Nodes[newTarget] = &split
+
+	out.Edges = make([]graph.Edge, 0, len(snap.Edges))
+	for _, e := range snap.Edges {
+		if e.To == node.Key && moved[e.From] {
+			e.To = newTarget
+		}
+		out.Edges = append(out.Edges, e)
+	}
+	return &out
+}
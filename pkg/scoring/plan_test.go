@@ -0,0 +1,80 @@
+package scoring_test
+
+import (
+	"testing"
+
+	"github.com/toposcope/toposcope/pkg/scoring"
+)
+
+func TestPlanLowersScore(t *testing.T) {
+	base, head, delta := loadFixtures(t)
+	engine := scoring.NewEngine(scoring.DefaultMetrics()...)
+
+	plan, err := engine.Plan(delta, base, head, 3)
+	if err != nil {
+		t.Fatalf("Plan() error: %v", err)
+	}
+	if len(plan.Steps) == 0 {
+		t.Fatal("expected at least one step for the fixture change")
+	}
+	if len(plan.Steps) > 3 {
+		t.Errorf("expected at most 3 steps, got %d", len(plan.Steps))
+	}
+
+	prev := plan.InitialScore
+	for i, step := range plan.Steps {
+		if step.ScoreBefore != prev {
+			t.Errorf("step %d: score_before %f, want %f", i, step.ScoreBefore, prev)
+		}
+		if step.Improvement <= 0 || step.ScoreAfter >= step.ScoreBefore {
+			t.Errorf("step %d: expected an improvement, got %f -> %f", i, step.ScoreBefore, step.ScoreAfter)
+		}
+		switch step.Kind {
+		case scoring.StepRemoveEdge:
+			if step.Edge == nil {
+				t.Errorf("step %d: remove_edge without an edge", i)
+			}
+		case scoring.StepSplitTarget:
+			if step.Target == "" || step.NewTarget == "" || len(step.Dependents) < 2 {
+				t.Errorf("step %d: incomplete split %+v", i, step)
+			}
+		default:
+			t.Errorf("step %d: unknown kind %q", i, step.Kind)
+		}
+		prev = step.ScoreAfter
+	}
+	if plan.FinalScore != prev {
+		t.Errorf("final score %f, want %f", plan.FinalScore, prev)
+	}
+
+	// The inputs are left untouched.
+	again, err := engine.Score(delta, base, head)
+	if err != nil {
+		t.Fatalf("Score() error: %v", err)
+	}
+	if again.TotalScore != plan.InitialScore {
+		t.Errorf("head was modified: score %f, want %f", again.TotalScore, plan.InitialScore)
+	}
+}
+
+func TestPlanDeterministic(t *testing.T) {
+	base, head, delta := loadFixtures(t)
+	engine := scoring.NewEngine(scoring.DefaultMetrics()...)
+
+	a, err := engine.Plan(delta, base, head, 0)
+	if err != nil {
+		t.Fatalf("Plan() error: %v", err)
+	}
+	b, err := engine.Plan(delta, base, head, 0)
+	if err != nil {
+		t.Fatalf("Plan() error: %v", err)
+	}
+	if len(a.Steps) != len(b.Steps) {
+		t.Fatalf("step counts differ: %d vs %d", len(a.Steps), len(b.Steps))
+	}
+	for i := range a.Steps {
+		if a.Steps[i].Title != b.Steps[i].Title {
+			t.Errorf("step %d differs: %q vs %q", i, a.Steps[i].Title, b.Steps[i].Title)
+		}
+	}
+}
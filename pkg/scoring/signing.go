@@ -0,0 +1,132 @@
+package scoring
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Provenance records where a score was computed.
+type Provenance struct {
+	// KeyID identifies the key the score was signed with. The platform sets
+	// it once the signature is verified; it isn't part of what is signed.
+	KeyID      string `json:"key_id,omitempty"`
+	RunnerID   string `json:"runner_id,omitempty"`
+	CLIVersion string `json:"cli_version,omitempty"`
+	SignedAt   string `json:"signed_at,omitempty"` // RFC 3339
+}
+
+// ScoreStatement is what a runner signs: a score, the change it is for, and
+// where it was computed. The change is pinned down by the head snapshot's
+// content ID, the base commit and the PR, so a signature can't be reused for
+// other content or another pull request.
+type ScoreStatement struct {
+	Repo          string       `json:"repo"`
+	CommitSHA     string       `json:"commit_sha"`
+	SnapshotID    string       `json:"snapshot_id"`               // graph.ContentID of the head snapshot
+	BaseCommitSHA string       `json:"base_commit_sha,omitempty"` // empty if the score has no base
+	PRNumber      *int         `json:"pr_number,omitempty"`
+	Score         *ScoreResult `json:"score"`
+	Provenance    Provenance   `json:"provenance"`
+}
+
+// SignedScore is a ScoreStatement with an ed25519 signature. The statement
+// is carried as the exact bytes that were signed, so verifying doesn't
+// depend on how either side encodes JSON.
+type SignedScore struct {
+	Payload   []byte `json:"payload"` // the JSON statement
+	KeyID     string `json:"key_id"`
+	Signature []byte `json:"signature"`
+}
+
+// KeyID returns the ID of a public key: the first 8 bytes of its SHA-256, in
+// hex.
+func KeyID(pub ed25519.PublicKey) string {
+	sum := sha256.Sum256(pub)
+	return hex.EncodeToString(sum[:8])
+}
+
+// SignScore signs st with key.
+func SignScore(key ed25519.PrivateKey, st *ScoreStatement) (*SignedScore, error) {
+	payload, err := json.Marshal(st)
+	if err != nil {
+		return nil, fmt.Errorf("encoding score statement: %w", err)
+	}
+	return &SignedScore{
+		Payload:   payload,
+		KeyID:     KeyID(key.Public().(ed25519.PublicKey)),
+		Signature: ed25519.Sign(key, payload),
+	}, nil
+}
+
+// Verify checks the signature against the key with the score's key ID among
+// keys and returns the signed statement, with its Provenance.KeyID set.
+func (s *SignedScore) Verify(keys []ed25519.PublicKey) (*ScoreStatement, error) {
+	var key ed25519.PublicKey
+	for _, k := range keys {
+		if KeyID(k) == s.KeyID {
+			key = k
+		}
+	}
+	if key == nil {
+		return nil, fmt.Errorf("score is signed with unknown key %q", s.KeyID)
+	}
+	if !ed25519.Verify(key, s.Payload, s.Signature) {
+		return nil, errors.New("score signature does not match")
+	}
+	var st ScoreStatement
+	if err := json.Unmarshal(s.Payload, &st); err != nil {
+		return nil, fmt.Errorf("decoding signed score: %w", err)
+	}
+	if st.Score == nil {
+		return nil, errors.New("signed statement has no score")
+	}
+	st.Provenance.KeyID = s.KeyID
+	return &st, nil
+}
+
+// ParseSigningKey parses a PEM-encoded PKCS #8 ed25519 private key, as
+// written by openssl genpkey -algorithm ed25519.
+func ParseSigningKey(data []byte) (ed25519.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("signing key is not PEM-encoded")
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("parsing signing key: %w", err)
+	}
+	priv, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("signing key is a %T, not ed25519", key)
+	}
+	return priv, nil
+}
+
+// ParseVerifyKey parses an ed25519 public key, either PEM-encoded PKIX (as
+// written by openssl pkey -pubout) or the 32 raw bytes in base64.
+func ParseVerifyKey(s string) (ed25519.PublicKey, error) {
+	if block, _ := pem.Decode([]byte(s)); block != nil {
+		key, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("parsing public key: %w", err)
+		}
+		pub, ok := key.(ed25519.PublicKey)
+		if !ok {
+			return nil, fmt.Errorf("public key is a %T, not ed25519", key)
+		}
+		return pub, nil
+	}
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(s))
+	if err != nil || len(raw) != ed25519.PublicKeySize {
+		return nil, errors.New("public key must be PEM or 32 bytes of base64")
+	}
+	return ed25519.PublicKey(raw), nil
+}
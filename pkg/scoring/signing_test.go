@@ -0,0 +1,83 @@
+package scoring_test
+
+import (
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"strings"
+	"testing"
+
+	"github.com/toposcope/toposcope/pkg/scoring"
+)
+
+func TestSignScore(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, _, _ := ed25519.GenerateKey(nil)
+
+	st := &scoring.ScoreStatement{
+		Repo:       "acme/app",
+		CommitSHA:  "abc123",
+		Score:      &scoring.ScoreResult{TotalScore: 12.5, Grade: "C"},
+		Provenance: scoring.Provenance{RunnerID: "runner-7", CLIVersion: "1.4.0"},
+	}
+	signed, err := scoring.SignScore(priv, st)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if signed.KeyID != scoring.KeyID(pub) {
+		t.Errorf("KeyID = %s, want %s", signed.KeyID, scoring.KeyID(pub))
+	}
+
+	got, err := signed.Verify([]ed25519.PublicKey{other, pub})
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if got.Score.TotalScore != 12.5 || got.Repo != "acme/app" || got.Provenance.RunnerID != "runner-7" {
+		t.Errorf("Verify returned %+v", got)
+	}
+	if got.Provenance.KeyID != signed.KeyID {
+		t.Errorf("Provenance.KeyID = %q, want %q", got.Provenance.KeyID, signed.KeyID)
+	}
+
+	if _, err := signed.Verify([]ed25519.PublicKey{other}); err == nil || !strings.Contains(err.Error(), "unknown key") {
+		t.Errorf("Verify with another key: err = %v, want unknown key", err)
+	}
+	tampered := *signed
+	tampered.Payload = []byte(strings.Replace(string(signed.Payload), "12.5", "1.5", 1))
+	if _, err := tampered.Verify([]ed25519.PublicKey{pub}); err == nil || !strings.Contains(err.Error(), "does not match") {
+		t.Errorf("Verify tampered payload: err = %v, want a mismatch", err)
+	}
+}
+
+func TestParseKeys(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, _ := x509.MarshalPKCS8PrivateKey(priv)
+	key, err := scoring.ParseSigningKey(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}))
+	if err != nil || !key.Equal(priv) {
+		t.Errorf("ParseSigningKey = %v, %v", key, err)
+	}
+	if _, err := scoring.ParseSigningKey([]byte("not a key")); err == nil {
+		t.Error("ParseSigningKey accepted a non-PEM key")
+	}
+
+	der, _ = x509.MarshalPKIXPublicKey(pub)
+	for name, s := range map[string]string{
+		"pem":    string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})),
+		"base64": base64.StdEncoding.EncodeToString(pub),
+	} {
+		got, err := scoring.ParseVerifyKey(s)
+		if err != nil || !got.Equal(pub) {
+			t.Errorf("ParseVerifyKey(%s) = %v, %v", name, got, err)
+		}
+	}
+	if _, err := scoring.ParseVerifyKey("c2hvcnQ="); err == nil {
+		t.Error("ParseVerifyKey accepted a short key")
+	}
+}
@@ -0,0 +1,167 @@
+package scoring
+
+import (
+	"fmt"
+
+	"github.com/toposcope/toposcope/pkg/graph"
+)
+
+// maxSuggestions caps the number of suggested actions attached to a result.
+const maxSuggestions = 5
+
+// SuggestionProvider produces recommendations from scored findings. Custom
+// providers can be registered with Engine.AddSuggestionProvider to encode
+// organization-specific guidance (e.g. "use the approved facade //platform/api").
+type SuggestionProvider interface {
+	// Suggest returns recommendations for the given metric results.
+	Suggest(breakdown []MetricResult, delta *graph.Delta) []SuggestedAction
+}
+
+// SuggestionFunc adapts an ordinary function to the SuggestionProvider interface.
+type SuggestionFunc func(breakdown []MetricResult, delta *graph.Delta) []SuggestedAction
+
+// Suggest calls f(breakdown, delta).
+func (f SuggestionFunc) Suggest(breakdown []MetricResult, delta *graph.Delta) []SuggestedAction {
+	return f(breakdown, delta)
+}
+
+// DefaultSuggestionProviders returns the built-in suggestion providers.
+func DefaultSuggestionProviders() []SuggestionProvider {
+	return []SuggestionProvider{
+		CrossPackageSuggestions{},
+		FanoutSuggestions{},
+		CentralitySuggestions{},
+	}
+}
+
+// suggest runs every registered provider and caps the combined result. It
+// takes one action from each provider in turn, so a provider that produces
+// many actions can't crowd out the others.
+func (e *Engine) suggest(breakdown []MetricResult, delta *graph.Delta) []SuggestedAction {
+	perProvider := make([][]SuggestedAction, len(e.providers))
+	for i, p := range e.providers {
+		perProvider[i] = p.Suggest(breakdown, delta)
+	}
+
+	var actions []SuggestedAction
+	for round := 0; len(actions) < maxSuggestions; round++ {
+		added := false
+		for _, pa := range perProvider {
+			if round < len(pa) && len(actions) < maxSuggestions {
+				actions = append(actions, pa[round])
+				added = true
+			}
+		}
+		if !added {
+			break
+		}
+	}
+
+	return actions
+}
+
+// findMetric returns the result for the given metric key, or nil.
+func findMetric(breakdown []MetricResult, key string) *MetricResult {
+	for i := range breakdown {
+		if breakdown[i].Key == key {
+			return &breakdown[i]
+		}
+	}
+	return nil
+}
+
+// FanoutSuggestions recommends splitting targets whose fanout grew large.
+type FanoutSuggestions struct{}
+
+// Suggest implements SuggestionProvider.
+func (FanoutSuggestions) Suggest(breakdown []MetricResult, _ *graph.Delta) []SuggestedAction {
+	mr := findMetric(breakdown, "fanout_increase")
+	if mr == nil {
+		return nil
+	}
+
+	var actions []SuggestedAction
+	for _, ev := range mr.Evidence {
+		if ev.Value >= 20 && ev.From != "" {
+			actions = append(actions, SuggestedAction{
+				Title:       fmt.Sprintf("Consider splitting %s", ev.From),
+				Description: fmt.Sprintf("This target now has %.0f dependencies. Targets with high fanout become fragile and slow to build.", ev.Value),
+				Targets:     []string{ev.From},
+				Confidence:  0.7,
+				Addresses:   []string{mr.Key},
+			})
+		}
+	}
+	return actions
+}
+
+// CrossPackageSuggestions recommends extracting a shared library when a single
+// target adds several cross-package dependencies.
+type CrossPackageSuggestions struct{}
+
+// Suggest implements SuggestionProvider.
+func (CrossPackageSuggestions) Suggest(breakdown []MetricResult, _ *graph.Delta) []SuggestedAction {
+	mr := findMetric(breakdown, "cross_package_deps")
+	if mr == nil {
+		return nil
+	}
+
+	// Group added edges by source node
+	sourceEdges := make(map[string]int)
+	for _, ev := range mr.Evidence {
+		if ev.From != "" {
+			sourceEdges[ev.From]++
+		}
+	}
+
+	var actions []SuggestedAction
+	for source, count := range sourceEdges {
+		if count >= 3 {
+			actions = append(actions, SuggestedAction{
+				Title:       fmt.Sprintf("Extract shared dependency for %s", source),
+				Description: fmt.Sprintf("This target added %d cross-package dependencies. Consider extracting a shared library.", count),
+				Targets:     []string{source},
+				Confidence:  0.5,
+				Addresses:   []string{mr.Key},
+			})
+		}
+	}
+	return actions
+}
+
+// CentralitySuggestions flags new dependencies on highly central targets.
+type CentralitySuggestions struct{}
+
+// Suggest implements SuggestionProvider.
+func (CentralitySuggestions) Suggest(breakdown []MetricResult, _ *graph.Delta) []SuggestedAction {
+	mr := findMetric(breakdown, "centrality_penalty")
+	if mr == nil {
+		return nil
+	}
+
+	var actions []SuggestedAction
+	for _, ev := range mr.Evidence {
+		if ev.To == "" {
+			continue
+		}
+		if ev.Value >= 1000 {
+			// Foundational package — don't suggest avoiding it
+			actions = append(actions, SuggestedAction{
+				Title:       fmt.Sprintf("This change depends on foundational package %s (%.0f reverse deps)", ev.To, ev.Value),
+				Description: "This is a foundational target; depending on it is expected. No action needed unless a narrower API exists.",
+				Targets:     []string{ev.To},
+				Confidence:  0.3,
+				Addresses:   []string{mr.Key},
+			})
+		} else if ev.Value >= 100 {
+			actions = append(actions, SuggestedAction{
+				Title:       fmt.Sprintf("Avoid direct dependency on %s", ev.To),
+				Description: fmt.Sprintf("This target has %.0f reverse dependencies. Consider depending on a narrower interface.", ev.Value),
+				Targets:     []string{ev.To},
+				Confidence:  0.5,
+				Addresses:   []string{mr.Key},
+			})
+		}
+	}
+	return actions
+}
@@ -0,0 +1,98 @@
+package scoring_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/toposcope/toposcope/pkg/graph"
+	"github.com/toposcope/toposcope/pkg/scoring"
+)
+
+func fanoutResult(values ...float64) scoring.MetricResult {
+	mr := scoring.MetricResult{Key: "fanout_increase", Name: "Fanout increase"}
+	for i, v := range values {
+		mr.Evidence = append(mr.Evidence, scoring.EvidenceItem{
+			From:  "//app/svc" + strings.Repeat("x", i) + ":lib",
+			Value: v,
+		})
+	}
+	return mr
+}
+
+func TestFanoutSuggestions(t *testing.T) {
+	actions := scoring.FanoutSuggestions{}.Suggest([]scoring.MetricResult{fanoutResult(25, 5)}, &graph.Delta{})
+	if len(actions) != 1 {
+		t.Fatalf("expected 1 suggestion for fanout >= 20, got %d", len(actions))
+	}
+	if actions[0].Targets[0] != "//app/svc:lib" {
+		t.Errorf("Targets = %v, want [//app/svc:lib]", actions[0].Targets)
+	}
+
+	if got := (scoring.FanoutSuggestions{}).Suggest(nil, &graph.Delta{}); got != nil {
+		t.Errorf("expected no suggestions without fanout metric, got %v", got)
+	}
+}
+
+type stubMetric struct{ mr scoring.MetricResult }
+
+func (m stubMetric) Key() string  { return m.mr.Key }
+func (m stubMetric) Name() string { return m.mr.Name }
+func (m stubMetric) Evaluate(*graph.Delta, *graph.Snapshot, *graph.Snapshot) scoring.MetricResult {
+	return m.mr
+}
+
+func TestEngineCustomSuggestionProvider(t *testing.T) {
+	empty := &graph.Snapshot{Nodes: map[string]*graph.Node{}}
+	facade := scoring.SuggestionFunc(func(breakdown []scoring.MetricResult, _ *graph.Delta) []scoring.SuggestedAction {
+		return []scoring.SuggestedAction{{Title: "Use the approved facade //platform/api"}}
+	})
+
+	t.Run("added after defaults", func(t *testing.T) {
+		engine := scoring.NewEngine(stubMetric{fanoutResult(30)})
+		engine.AddSuggestionProvider(facade)
+
+		result, err := engine.Score(&graph.Delta{}, empty, empty)
+		if err != nil {
+			t.Fatalf("Score() error: %v", err)
+		}
+		if len(result.SuggestedActions) != 2 {
+			t.Fatalf("expected 2 suggestions, got %d", len(result.SuggestedActions))
+		}
+		if result.SuggestedActions[1].Title != "Use the approved facade //platform/api" {
+			t.Errorf("expected custom suggestion last, got %q", result.SuggestedActions[1].Title)
+		}
+	})
+
+	t.Run("replace defaults", func(t *testing.T) {
+		engine := scoring.NewEngine(stubMetric{fanoutResult(30)})
+		engine.SetSuggestionProviders(facade)
+
+		result, err := engine.Score(&graph.Delta{}, empty, empty)
+		if err != nil {
+			t.Fatalf("Score() error: %v", err)
+		}
+		if len(result.SuggestedActions) != 1 {
+			t.Fatalf("expected 1 suggestion, got %d", len(result.SuggestedActions))
+		}
+	})
+
+	t.Run("capped", func(t *testing.T) {
+		engine := scoring.NewEngine(stubMetric{fanoutResult(30, 31, 32, 33, 34, 35)})
+		engine.AddSuggestionProvider(facade)
+
+		result, err := engine.Score(&graph.Delta{}, empty, empty)
+		if err != nil {
+			t.Fatalf("Score() error: %v", err)
+		}
+		if len(result.SuggestedActions) != 5 {
+			t.Fatalf("expected suggestions capped at 5, got %d", len(result.SuggestedActions))
+		}
+		var custom bool
+		for _, a := range result.SuggestedActions {
+			custom = custom || a.Title == "Use the approved facade //platform/api"
+		}
+		if !custom {
+			t.Errorf("custom suggestion dropped when the defaults fill the cap: %+v", result.SuggestedActions)
+		}
+	})
+}
@@ -13,6 +13,49 @@ type ScoreResult struct {
 	DeltaStats       DeltaStatsView    `json:"delta_stats"`
 	BaseCommit       string            `json:"base_commit"`
 	HeadCommit       string            `json:"head_commit"`
+	GradeThresholds  GradeThresholds   `json:"grade_thresholds"` // thresholds Grade was derived from
+
+	// Suppressed lists findings removed from Breakdown by waivers. Their
+	// contributions are not part of TotalScore.
+	Suppressed []SuppressedFinding `json:"suppressed,omitempty"`
+
+	// Set when the head snapshot was a scoped extraction; metrics only
+	// reflect changes within Scope.
+	Partial bool     `json:"partial,omitempty"`
+	Scope   []string `json:"scope,omitempty"`
+
+	// Set only when a normalization mode is enabled. TotalScore remains the
+	// raw score; Grade is derived from NormalizedScore instead.
+	Normalization   Normalization `json:"normalization,omitempty"`
+	NormalizedScore float64       `json:"normalized_score,omitempty"`
+	SizeFactor      float64       `json:"size_factor,omitempty"`
+
+	// Interval is a confidence interval for the graded score, set when the
+	// engine has a confidence level.
+	Interval *ScoreInterval `json:"interval,omitempty"`
+}
+
+// ScoreConfig is the configuration a ScoreResult was computed with.
+type ScoreConfig struct {
+	GradeThresholds GradeThresholds           `json:"grade_thresholds"`
+	Normalization   Normalization             `json:"normalization,omitempty"`
+	Metrics         map[string]map[string]any `json:"metrics"`
+}
+
+// Config collects the grade thresholds, normalization mode, and per-metric
+// configuration the result was scored with.
+func (r *ScoreResult) Config() ScoreConfig {
+	cfg := ScoreConfig{
+		GradeThresholds: r.GradeThresholds,
+		Normalization:   r.Normalization,
+		Metrics:         make(map[string]map[string]any, len(r.Breakdown)),
+	}
+	for _, mr := range r.Breakdown {
+		if mr.Config != nil {
+			cfg.Metrics[mr.Key] = mr.Config
+		}
+	}
+	return cfg
 }
 
 // DeltaStatsView is a read-only summary of the delta for display purposes.
@@ -31,6 +74,10 @@ type MetricResult struct {
 	Contribution float64        `json:"contribution"` // score contribution (positive = worse, negative = credit)
 	Severity     Severity       `json:"severity"`
 	Evidence     []EvidenceItem `json:"evidence"`
+
+	// Config echoes the metric's weights and thresholds, so the result stays
+	// interpretable after the configuration changes.
+	Config map[string]any `json:"config,omitempty"`
 }
 
 // Severity indicates how concerning a metric finding is.
@@ -50,6 +97,9 @@ type EvidenceItem struct {
 	From    string       `json:"from,omitempty"`  // source node key
 	To      string       `json:"to,omitempty"`    // target node key
 	Value   float64      `json:"value,omitempty"` // numeric value (degree, count, etc.)
+
+	// Fingerprint identifies the finding across runs; see Fingerprint.
+	Fingerprint string `json:"fingerprint,omitempty"`
 }
 
 // EvidenceType classifies what kind of evidence this is.
@@ -61,6 +111,11 @@ const (
 	EvidenceFanoutChange EvidenceType = "FANOUT_CHANGE"
 	EvidenceCentrality   EvidenceType = "CENTRALITY"
 	EvidenceBlastRadius  EvidenceType = "BLAST_RADIUS"
+	EvidenceExternal     EvidenceType = "EXTERNAL"
+	EvidenceThirdParty   EvidenceType = "THIRD_PARTY"
+	EvidencePackageCycle EvidenceType = "PACKAGE_CYCLE"
+	EvidenceCriticalPath EvidenceType = "CRITICAL_PATH"
+	EvidenceCacheBusting EvidenceType = "CACHE_BUSTING"
 )
 
 // Hotspot identifies a node that appears across multiple metric findings.
@@ -79,19 +134,3 @@ type SuggestedAction struct {
 	Confidence  float64  `json:"confidence"` // 0.0-1.0
 	Addresses   []string `json:"addresses"`  // metric keys this addresses
 }
-
-// GradeFromScore maps a total score to a letter grade.
-func GradeFromScore(score float64) string {
-	switch {
-	case score <= 3:
-		return "A"
-	case score <= 7:
-		return "B"
-	case score <= 14:
-		return "C"
-	case score <= 24:
-		return "D"
-	default:
-		return "F"
-	}
-}
@@ -0,0 +1,136 @@
+package scoring
+
+import (
+	"strings"
+	"time"
+
+	"github.com/toposcope/toposcope/pkg/graph"
+)
+
+// WaiverTag is the Bazel tag that waives findings on a target. A bare
+// "toposcope-ignore" waives every metric; "toposcope-ignore: key1,key2"
+// waives only the listed metrics.
+const WaiverTag = "toposcope-ignore"
+
+// Waiver suppresses a metric's findings on matching targets. Suppressed
+// findings are removed from the breakdown and recorded in
+// ScoreResult.Suppressed instead.
+type Waiver struct {
+	Metric  string     `json:"metric,omitempty"` // metric key; empty waives every metric
+	Target  string     `json:"target"`           // label, "//pkg:*" (package), or "//pkg/..." (package tree)
+	Reason  string     `json:"reason,omitempty"`
+	Expires *time.Time `json:"expires,omitempty"` // nil: never expires
+}
+
+// Active reports whether w still applies at now.
+func (w Waiver) Active(now time.Time) bool {
+	return w.Expires == nil || now.Before(*w.Expires)
+}
+
+func (w Waiver) matches(metric string, ev EvidenceItem) bool {
+	if w.Metric != "" && w.Metric != metric {
+		return false
+	}
+	return (ev.From != "" && matchTarget(w.Target, ev.From)) || (ev.To != "" && matchTarget(w.Target, ev.To))
+}
+
+// SuppressedFinding is a finding removed from the score by a waiver.
+type SuppressedFinding struct {
+	Metric   string       `json:"metric"`
+	Evidence EvidenceItem `json:"evidence"`
+	// Contribution is the share of the metric's contribution the finding
+	// accounted for, which the waiver removed from the total.
+	Contribution float64 `json:"contribution"`
+	Waiver       Waiver  `json:"waiver"`
+}
+
+// matchTarget reports whether label matches pattern: an exact label, every
+// target in a package ("//pkg:*" or "//pkg:all"), or a package and its
+// subpackages ("//pkg/...").
+func matchTarget(pattern, label string) bool {
+	if pattern == label {
+		return true
+	}
+	if prefix, ok := strings.CutSuffix(pattern, "/..."); ok {
+		pkg, _, _ := strings.Cut(label, ":")
+		return pkg == prefix || strings.HasPrefix(pkg, prefix+"/")
+	}
+	for _, all := range []string{":*", ":all"} {
+		if pkg, ok := strings.CutSuffix(pattern, all); ok {
+			lpkg, _, _ := strings.Cut(label, ":")
+			return lpkg == pkg
+		}
+	}
+	return false
+}
+
+// tagWaivers returns the waivers declared with WaiverTag on targets of head,
+// so a waiver added by the change applies and one it removes does not.
+// Targets the change deletes keep the tags they had in base. Tag waivers
+// never expire.
+func tagWaivers(head, base *graph.Snapshot) []Waiver {
+	var waivers []Waiver
+	for key, node := range head.Nodes {
+		waivers = appendTagWaivers(waivers, key, node.Tags)
+	}
+	for key, node := range base.Nodes {
+		if _, ok := head.Nodes[key]; !ok {
+			waivers = appendTagWaivers(waivers, key, node.Tags)
+		}
+	}
+	return waivers
+}
+
+// appendTagWaivers appends the waivers that WaiverTag tags declare for the
+// target key.
+func appendTagWaivers(waivers []Waiver, key string, tags []string) []Waiver {
+	for _, tag := range tags {
+		rest, ok := strings.CutPrefix(tag, WaiverTag)
+		if !ok || (rest != "" && rest[0] != ':') {
+			continue
+		}
+		reason := "tagged " + WaiverTag
+		metrics := strings.TrimSpace(strings.TrimPrefix(rest, ":"))
+		if metrics == "" {
+			waivers = append(waivers, Waiver{Target: key, Reason: reason})
+			continue
+		}
+		for _, m := range strings.Split(metrics, ",") {
+			if m = strings.TrimSpace(m); m != "" {
+				waivers = append(waivers, Waiver{Metric: m, Target: key, Reason: reason})
+			}
+		}
+	}
+	return waivers
+}
+
+// applyWaivers moves the findings of mr that a waiver matches into
+// suppressed. Metrics don't attribute their contribution to individual
+// findings, so each suppressed finding removes an equal share of it.
+func applyWaivers(mr *MetricResult, waivers []Waiver, suppressed []SuppressedFinding) []SuppressedFinding {
+	if len(waivers) == 0 || len(mr.Evidence) == 0 {
+		return suppressed
+	}
+	share := mr.Contribution / float64(len(mr.Evidence))
+	kept := make([]EvidenceItem, 0, len(mr.Evidence))
+	for _, ev := range mr.Evidence {
+		waived := false
+		for _, w := range waivers {
+			if w.matches(mr.Key, ev) {
+				suppressed = append(suppressed, SuppressedFinding{Metric: mr.Key, Evidence: ev, Contribution: share, Waiver: w})
+				mr.Contribution -= share
+				waived = true
+				break
+			}
+		}
+		if !waived {
+			kept = append(kept, ev)
+		}
+	}
+	mr.Evidence = kept
+	if len(kept) == 0 {
+		mr.Contribution = 0
+		mr.Severity = SeverityInfo
+	}
+	return suppressed
+}
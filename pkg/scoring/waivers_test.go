@@ -0,0 +1,143 @@
+package scoring_test
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/toposcope/toposcope/pkg/graph"
+	"github.com/toposcope/toposcope/pkg/scoring"
+)
+
+// edgeMetric reports a fixed contribution for a fixed set of edges.
+type edgeMetric struct {
+	edges [][2]string
+}
+
+func (m edgeMetric) Key() string  { return "cross_package_deps" }
+func (m edgeMetric) Name() string { return "Cross-package dependencies" }
+
+func (m edgeMetric) Evaluate(_ *graph.Delta, _, _ *graph.Snapshot) scoring.MetricResult {
+	mr := scoring.MetricResult{Key: m.Key(), Name: m.Name(), Contribution: 2 * float64(len(m.edges)), Severity: scoring.SeverityMedium}
+	for _, e := range m.edges {
+		mr.Evidence = append(mr.Evidence, scoring.EvidenceItem{Type: scoring.EvidenceEdgeAdded, Summary: e[0] + " -> " + e[1], From: e[0], To: e[1]})
+	}
+	return mr
+}
+
+func waiverSnapshots(tags map[string][]string) (*graph.Snapshot, *graph.Snapshot) {
+	head := &graph.Snapshot{Nodes: map[string]*graph.Node{}}
+	for key, t := range tags {
+		head.Nodes[key] = &graph.Node{Key: key, Tags: t}
+	}
+	return &graph.Snapshot{Nodes: map[string]*graph.Node{}}, head
+}
+
+func TestEngineTagWaivers(t *testing.T) {
+	metric := edgeMetric{edges: [][2]string{
+		{"//app/a:lib", "//lib/x:x"},
+		{"//app/b:lib", "//lib/y:y"},
+		{"//app/c:lib", "//lib/z:z"},
+	}}
+	base, head := waiverSnapshots(map[string][]string{
+		"//app/a:lib": {"toposcope-ignore: cross_package_deps"},
+		"//app/b:lib": {"toposcope-ignore:fanout_increase"}, // other metric
+		"//app/c:lib": {"toposcope-ignored", "manual"},      // not a waiver tag
+		"//lib/z:z":   {"toposcope-ignore"},                 // all metrics
+	})
+
+	result, err := scoring.NewEngine(metric).Score(&graph.Delta{}, base, head)
+	if err != nil {
+		t.Fatalf("Score: %v", err)
+	}
+	if len(result.Suppressed) != 2 {
+		t.Fatalf("Suppressed = %+v, want the //app/a and //lib/z edges", result.Suppressed)
+	}
+	if got := result.Breakdown[0].Evidence; len(got) != 1 || got[0].From != "//app/b:lib" {
+		t.Errorf("remaining evidence = %+v, want only //app/b:lib", got)
+	}
+	if math.Abs(result.TotalScore-2) > 1e-9 {
+		t.Errorf("TotalScore = %v, want 2 after waiving two of three edges", result.TotalScore)
+	}
+	for _, sf := range result.Suppressed {
+		if sf.Contribution != 2 {
+			t.Errorf("suppressed %s contribution = %v, want 2", sf.Evidence.Summary, sf.Contribution)
+		}
+	}
+}
+
+func TestEngineConfiguredWaivers(t *testing.T) {
+	metric := edgeMetric{edges: [][2]string{
+		{"//app/a:lib", "//lib/x:x"},
+		{"//app/b/sub:lib", "//lib/y:y"},
+		{"//app/c:lib", "//lib/z:z"},
+	}}
+	base, head := waiverSnapshots(nil)
+	past := time.Now().Add(-time.Hour)
+	future := time.Now().Add(time.Hour)
+
+	engine := scoring.NewEngine(metric)
+	engine.SetWaivers([]scoring.Waiver{
+		{Target: "//app/b/...", Reason: "migration", Expires: &future},
+		{Target: "//app/c:lib", Expires: &past}, // expired
+		{Target: "//lib/x:*", Metric: "fanout_increase"},
+	})
+	result, err := engine.Score(&graph.Delta{}, base, head)
+	if err != nil {
+		t.Fatalf("Score: %v", err)
+	}
+	if len(result.Suppressed) != 1 || result.Suppressed[0].Waiver.Reason != "migration" {
+		t.Fatalf("Suppressed = %+v, want only the //app/b/... waiver", result.Suppressed)
+	}
+	if result.TotalScore != 4 {
+		t.Errorf("TotalScore = %v, want 4", result.TotalScore)
+	}
+}
+
+func TestEngineWaiverSuppressesWholeMetric(t *testing.T) {
+	metric := edgeMetric{edges: [][2]string{{"//app/a:lib", "//lib/x:x"}}}
+	base, head := waiverSnapshots(nil)
+	engine := scoring.NewEngine(metric)
+	engine.SetWaivers([]scoring.Waiver{{Target: "//..."}})
+
+	result, err := engine.Score(&graph.Delta{}, base, head)
+	if err != nil {
+		t.Fatalf("Score: %v", err)
+	}
+	mr := result.Breakdown[0]
+	if mr.Contribution != 0 || mr.Severity != scoring.SeverityInfo || len(mr.Evidence) != 0 {
+		t.Errorf("metric = %+v, want no contribution or evidence", mr)
+	}
+	if result.Grade != "A" {
+		t.Errorf("Grade = %s, want A", result.Grade)
+	}
+}
+
+func TestEngineTagWaiversFollowHead(t *testing.T) {
+	metric := edgeMetric{edges: [][2]string{
+		{"//app/a:lib", "//lib/x:x"}, // tag added by the change
+		{"//app/b:lib", "//lib/y:y"}, // tag removed by the change
+		{"//app/c:lib", "//lib/z:z"}, // target deleted by the change
+	}}
+	base := &graph.Snapshot{Nodes: map[string]*graph.Node{
+		"//app/a:lib": {Key: "//app/a:lib"},
+		"//app/b:lib": {Key: "//app/b:lib", Tags: []string{"toposcope-ignore"}},
+		"//app/c:lib": {Key: "//app/c:lib", Tags: []string{"toposcope-ignore"}},
+	}}
+	head := &graph.Snapshot{Nodes: map[string]*graph.Node{
+		"//app/a:lib": {Key: "//app/a:lib", Tags: []string{"toposcope-ignore"}},
+		"//app/b:lib": {Key: "//app/b:lib"},
+	}}
+
+	result, err := scoring.NewEngine(metric).Score(&graph.Delta{}, base, head)
+	if err != nil {
+		t.Fatalf("Score: %v", err)
+	}
+	waived := map[string]bool{}
+	for _, sf := range result.Suppressed {
+		waived[sf.Evidence.From] = true
+	}
+	if len(waived) != 2 || !waived["//app/a:lib"] || !waived["//app/c:lib"] {
+		t.Errorf("waived %v, want //app/a:lib (tagged in head) and //app/c:lib (deleted, tagged in base)", waived)
+	}
+}
@@ -10,7 +10,11 @@ import (
 )
 
 // CheckRunRenderer produces GitHub Check Run data from a ScoreResult.
-type CheckRunRenderer struct{}
+type CheckRunRenderer struct {
+	// Previous, if set, is the score of the PR's previous push; the summary
+	// then includes how the score and findings changed since.
+	Previous *scoring.ScoreResult
+}
 
 func (r *CheckRunRenderer) Render(w io.Writer, result *scoring.ScoreResult) error {
 	data := r.BuildCheckRunData(result)
@@ -23,7 +27,7 @@ func (r *CheckRunRenderer) Render(w io.Writer, result *scoring.ScoreResult) erro
 func (r *CheckRunRenderer) BuildCheckRunData(result *scoring.ScoreResult) CheckRunData {
 	conclusion := gradeToConclusion(result.Grade)
 	title := fmt.Sprintf("Toposcope: Grade %s — Score %.1f", result.Grade, result.TotalScore)
-	summary := buildMarkdownSummary(result)
+	summary := buildMarkdownSummary(result, r.Previous)
 
 	return CheckRunData{
 		Title:      title,
@@ -43,11 +47,28 @@ func gradeToConclusion(grade string) string {
 	}
 }
 
-func buildMarkdownSummary(result *scoring.ScoreResult) string {
+func buildMarkdownSummary(result, previous *scoring.ScoreResult) string {
 	var sb strings.Builder
 
 	sb.WriteString(fmt.Sprintf("## Toposcope: Grade %s — Score %.1f\n\n", result.Grade, result.TotalScore))
 
+	if result.Partial {
+		sb.WriteString(fmt.Sprintf("_Partial analysis: scored within extraction scope (%d root targets)._\n\n", len(result.Scope)))
+	}
+
+	if result.Normalization != "" {
+		sb.WriteString(fmt.Sprintf("Normalized score: **%.1f** (raw %.1f × size factor %.2f)\n\n",
+			result.NormalizedScore, result.TotalScore, result.SizeFactor))
+	}
+	if iv := result.Interval; iv != nil {
+		sb.WriteString(fmt.Sprintf("%.0f%% interval: %.1f–%.1f over %d resamples of the change\n\n",
+			iv.Level*100, iv.Low, iv.High, iv.Samples))
+	}
+
+	if previous != nil {
+		writePushComparison(&sb, ComparePushes(previous, result), result)
+	}
+
 	// Delta stats
 	sb.WriteString("### Delta Stats\n\n")
 	sb.WriteString("| Metric | Count |\n|--------|-------|\n")
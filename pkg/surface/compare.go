@@ -0,0 +1,113 @@
+package surface
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/toposcope/toposcope/pkg/scoring"
+)
+
+// Finding is one piece of evidence behind a metric, identified across
+// scores by its fingerprint.
+type Finding struct {
+	Metric string
+	scoring.EvidenceItem
+}
+
+func (f Finding) key() string {
+	if f.Fingerprint != "" {
+		return f.Fingerprint
+	}
+	return scoring.Fingerprint(f.Metric, f.EvidenceItem)
+}
+
+// PushComparison describes how a PR's score changed since its previous push.
+type PushComparison struct {
+	PreviousScore float64
+	PreviousGrade string
+	ScoreDelta    float64 // positive = worse
+	New           []Finding
+	Resolved      []Finding
+}
+
+// ComparePushes diffs the score of a PR's latest push against the previous
+// one. Findings are matched by fingerprint, so a finding whose wording or
+// value changed is not reported as new.
+func ComparePushes(previous, current *scoring.ScoreResult) PushComparison {
+	cmp := PushComparison{
+		PreviousScore: previous.TotalScore,
+		PreviousGrade: previous.Grade,
+		ScoreDelta:    current.TotalScore - previous.TotalScore,
+	}
+	before, after := findingSet(previous), findingSet(current)
+	for _, f := range findings(current) {
+		if _, ok := before[f.key()]; !ok {
+			cmp.New = append(cmp.New, f)
+		}
+	}
+	for _, f := range findings(previous) {
+		if _, ok := after[f.key()]; !ok {
+			cmp.Resolved = append(cmp.Resolved, f)
+		}
+	}
+	return cmp
+}
+
+func findings(result *scoring.ScoreResult) []Finding {
+	var out []Finding
+	for _, mr := range result.Breakdown {
+		for _, ev := range mr.Evidence {
+			out = append(out, Finding{Metric: mr.Key, EvidenceItem: ev})
+		}
+	}
+	return out
+}
+
+func findingSet(result *scoring.ScoreResult) map[string]struct{} {
+	set := make(map[string]struct{})
+	for _, f := range findings(result) {
+		set[f.key()] = struct{}{}
+	}
+	return set
+}
+
+// writePushComparison renders the "compared to previous push" section of a
+// PR summary.
+func writePushComparison(sb *strings.Builder, cmp PushComparison, current *scoring.ScoreResult) {
+	sb.WriteString("### Compared to previous push\n\n")
+
+	trend := "unchanged"
+	switch {
+	case cmp.ScoreDelta > 0:
+		trend = fmt.Sprintf(":arrow_up: %+.1f (worse)", cmp.ScoreDelta)
+	case cmp.ScoreDelta < 0:
+		trend = fmt.Sprintf(":arrow_down: %+.1f (better)", cmp.ScoreDelta)
+	}
+	sb.WriteString(fmt.Sprintf("Score %.1f → %.1f, %s", cmp.PreviousScore, current.TotalScore, trend))
+	if cmp.PreviousGrade != "" && cmp.PreviousGrade != current.Grade {
+		sb.WriteString(fmt.Sprintf("; grade %s → %s", cmp.PreviousGrade, current.Grade))
+	}
+	sb.WriteString("\n\n")
+
+	if len(cmp.New) == 0 && len(cmp.Resolved) == 0 {
+		sb.WriteString("No new or resolved findings.\n\n")
+		return
+	}
+	const maxListed = 5
+	list := func(title string, fs []Finding) {
+		if len(fs) == 0 {
+			return
+		}
+		sb.WriteString(fmt.Sprintf("**%s (%d)**\n", title, len(fs)))
+		for i, f := range fs {
+			if i == maxListed {
+				sb.WriteString(fmt.Sprintf("- _... and %d more_\n", len(fs)-maxListed))
+				break
+			}
+			sb.WriteString(fmt.Sprintf("- %s\n", f.Summary))
+		}
+		sb.WriteString("\n")
+	}
+	list("New", cmp.New)
+	list("Resolved", cmp.Resolved)
+}
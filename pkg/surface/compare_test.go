@@ -0,0 +1,63 @@
+package surface_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/toposcope/toposcope/pkg/scoring"
+	"github.com/toposcope/toposcope/pkg/surface"
+)
+
+func TestComparePushes(t *testing.T) {
+	previous := sampleResult()
+	current := sampleResult()
+	current.TotalScore = 10.5
+	current.Grade = "B"
+	// Drop one added edge, change the fanout wording, and add a new edge.
+	current.Breakdown[0].Evidence = []scoring.EvidenceItem{
+		current.Breakdown[0].Evidence[0],
+		{Type: scoring.EvidenceEdgeAdded, Summary: "//app/auth:handler -> //lib/db:conn", From: "//app/auth:handler", To: "//lib/db:conn"},
+	}
+	current.Breakdown[1].Evidence = []scoring.EvidenceItem{
+		{Type: scoring.EvidenceFanoutChange, Summary: "//app/auth:handler fanout 3 -> 9 (+6)", From: "//app/auth:handler", Value: 9},
+	}
+
+	cmp := surface.ComparePushes(previous, current)
+	if cmp.ScoreDelta != -3.5 || cmp.PreviousGrade != "C" {
+		t.Errorf("ScoreDelta = %v, PreviousGrade = %q", cmp.ScoreDelta, cmp.PreviousGrade)
+	}
+	if len(cmp.New) != 1 || cmp.New[0].To != "//lib/db:conn" {
+		t.Errorf("New = %+v, want only the //lib/db:conn edge", cmp.New)
+	}
+	if len(cmp.Resolved) != 1 || cmp.Resolved[0].To != "//lib/session:types" {
+		t.Errorf("Resolved = %+v, want only the //lib/session:types edge", cmp.Resolved)
+	}
+}
+
+func TestCheckRunSummaryComparesPreviousPush(t *testing.T) {
+	current := sampleResult()
+	if strings.Contains((&surface.CheckRunRenderer{}).BuildCheckRunData(current).Summary, "previous push") {
+		t.Error("summary without a previous push should not compare")
+	}
+
+	previous := sampleResult()
+	previous.TotalScore = 9.0
+	previous.Grade = "B"
+	previous.Breakdown[0].Evidence = previous.Breakdown[0].Evidence[:1]
+	summary := (&surface.CheckRunRenderer{Previous: previous}).BuildCheckRunData(current).Summary
+	for _, want := range []string{
+		"### Compared to previous push",
+		"Score 9.0 → 14.0",
+		"+5.0 (worse)",
+		"grade B → C",
+		"**New (1)**",
+		"//lib/session:types",
+	} {
+		if !strings.Contains(summary, want) {
+			t.Errorf("summary missing %q:\n%s", want, summary)
+		}
+	}
+	if strings.Contains(summary, "**Resolved") {
+		t.Errorf("summary lists resolved findings when there are none:\n%s", summary)
+	}
+}
@@ -0,0 +1,49 @@
+package surface
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/toposcope/toposcope/pkg/scoring"
+)
+
+// RenderPlanMarkdown writes a refactoring plan as a markdown report: a table
+// of steps with the estimated score after each, then what each step means.
+func RenderPlanMarkdown(w io.Writer, plan *scoring.RefactoringPlan) error {
+	var sb strings.Builder
+
+	sb.WriteString(fmt.Sprintf("## Toposcope refactoring plan: %s → %s\n\n", plan.InitialGrade, plan.FinalGrade))
+	if plan.BaseCommit != "" || plan.HeadCommit != "" {
+		sb.WriteString(fmt.Sprintf("`%s` → `%s`\n\n", shortSHA(plan.BaseCommit), shortSHA(plan.HeadCommit)))
+	}
+	if len(plan.Steps) == 0 {
+		sb.WriteString(fmt.Sprintf("No edge removal or target split lowers the score (%.1f, grade %s).\n", plan.InitialScore, plan.InitialGrade))
+		_, err := io.WriteString(w, sb.String())
+		return err
+	}
+
+	sb.WriteString(fmt.Sprintf("Score **%.1f** → **%.1f** in %d steps. Estimates assume every earlier step was applied.\n\n",
+		plan.InitialScore, plan.FinalScore, len(plan.Steps)))
+	sb.WriteString("| # | Step | Improvement | Score after | Grade after |\n")
+	sb.WriteString("|--:|------|------------:|------------:|:-----------:|\n")
+	for i, step := range plan.Steps {
+		sb.WriteString(fmt.Sprintf("| %d | %s | %.1f | %.1f | %s |\n",
+			i+1, step.Title, step.Improvement, step.ScoreAfter, step.GradeAfter))
+	}
+	sb.WriteString("\n")
+
+	for i, step := range plan.Steps {
+		sb.WriteString(fmt.Sprintf("### %d. %s\n\n%s\n", i+1, step.Title, step.Description))
+		if len(step.Dependents) > 0 {
+			sb.WriteString("\nDependents to move:\n\n")
+			for _, d := range step.Dependents {
+				sb.WriteString(fmt.Sprintf("- `%s`\n", d))
+			}
+		}
+		sb.WriteString("\n")
+	}
+
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
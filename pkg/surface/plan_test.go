@@ -0,0 +1,64 @@
+package surface_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/toposcope/toposcope/pkg/graph"
+	"github.com/toposcope/toposcope/pkg/scoring"
+	"github.com/toposcope/toposcope/pkg/surface"
+)
+
+func TestRenderPlanMarkdown(t *testing.T) {
+	plan := &scoring.RefactoringPlan{
+		BaseCommit:   "abc123f0000",
+		HeadCommit:   "def456a0000",
+		InitialScore: 14,
+		InitialGrade: "C",
+		FinalScore:   4,
+		FinalGrade:   "A",
+		Steps: []scoring.PlanStep{
+			{
+				Kind:        scoring.StepRemoveEdge,
+				Title:       "Remove the dependency //app/auth:handler -> //lib/session:store",
+				Edge:        &graph.Edge{From: "//app/auth:handler", To: "//lib/session:store"},
+				ScoreBefore: 14, ScoreAfter: 9, Improvement: 5, GradeAfter: "B",
+			},
+			{
+				Kind:        scoring.StepSplitTarget,
+				Title:       "Split //lib/core:core",
+				Target:      "//lib/core:core",
+				NewTarget:   "//lib/core:core_api",
+				Dependents:  []string{"//app/a:a", "//app/b:b"},
+				ScoreBefore: 9, ScoreAfter: 4, Improvement: 5, GradeAfter: "A",
+			},
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := surface.RenderPlanMarkdown(&buf, plan); err != nil {
+		t.Fatalf("RenderPlanMarkdown() error: %v", err)
+	}
+	out := buf.String()
+	for _, want := range []string{
+		"## Toposcope refactoring plan: C → A",
+		"`abc123f` → `def456a`",
+		"Score **14.0** → **4.0** in 2 steps.",
+		"| 1 | Remove the dependency //app/auth:handler -> //lib/session:store | 5.0 | 9.0 | B |",
+		"### 2. Split //lib/core:core",
+		"- `//app/b:b`",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("plan missing %q\n%s", want, out)
+		}
+	}
+
+	buf.Reset()
+	if err := surface.RenderPlanMarkdown(&buf, &scoring.RefactoringPlan{InitialScore: 2, InitialGrade: "A", FinalGrade: "A"}); err != nil {
+		t.Fatalf("RenderPlanMarkdown() error: %v", err)
+	}
+	if !strings.Contains(buf.String(), "No edge removal or target split lowers the score") {
+		t.Errorf("empty plan output:\n%s", buf.String())
+	}
+}
@@ -0,0 +1,125 @@
+package surface
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/toposcope/toposcope/pkg/scoring"
+)
+
+// StepSummaryRenderer writes a GitHub Actions job summary: a markdown report
+// with a per-metric table and the evidence behind each finding.
+type StepSummaryRenderer struct{}
+
+func (r *StepSummaryRenderer) Render(w io.Writer, result *scoring.ScoreResult) error {
+	var sb strings.Builder
+
+	sb.WriteString(fmt.Sprintf("## %s Toposcope: Grade %s — Score %.1f\n\n",
+		conclusionIcon(gradeToConclusion(result.Grade)), result.Grade, result.TotalScore))
+
+	if result.BaseCommit != "" || result.HeadCommit != "" {
+		sb.WriteString(fmt.Sprintf("`%s` → `%s`\n\n", shortSHA(result.BaseCommit), shortSHA(result.HeadCommit)))
+	}
+	if result.Partial {
+		sb.WriteString(fmt.Sprintf("_Partial analysis: scored within extraction scope (%d root targets)._\n\n", len(result.Scope)))
+	}
+	if result.Normalization != "" {
+		sb.WriteString(fmt.Sprintf("Normalized score: **%.1f** (raw %.1f × size factor %.2f)\n\n",
+			result.NormalizedScore, result.TotalScore, result.SizeFactor))
+	}
+	if iv := result.Interval; iv != nil {
+		sb.WriteString(fmt.Sprintf("%.0f%% interval: %.1f–%.1f over %d resamples of the change\n\n",
+			iv.Level*100, iv.Low, iv.High, iv.Samples))
+	}
+
+	sb.WriteString("| Added Nodes | Removed Nodes | Added Edges | Removed Edges |\n")
+	sb.WriteString("|------------:|--------------:|------------:|--------------:|\n")
+	sb.WriteString(fmt.Sprintf("| %d | %d | %d | %d |\n\n",
+		result.DeltaStats.AddedNodes, result.DeltaStats.RemovedNodes,
+		result.DeltaStats.AddedEdges, result.DeltaStats.RemovedEdges))
+
+	sb.WriteString("### Metrics\n\n")
+	sb.WriteString("| | Metric | Contribution | Severity | Evidence |\n")
+	sb.WriteString("|---|--------|-------------:|----------|---------:|\n")
+	for _, mr := range result.Breakdown {
+		sb.WriteString(fmt.Sprintf("| %s | %s | %+.1f | %s | %d |\n",
+			severityIcon(mr.Severity), mr.Name, mr.Contribution, severityLabel(mr.Severity), len(mr.Evidence)))
+	}
+	sb.WriteString("\n")
+
+	// Evidence is collapsed per metric so large changes stay readable.
+	const maxEvidence = 10
+	for _, mr := range result.Breakdown {
+		if len(mr.Evidence) == 0 {
+			continue
+		}
+		sb.WriteString(fmt.Sprintf("<details><summary>%s %s (%d)</summary>\n\n", severityIcon(mr.Severity), mr.Name, len(mr.Evidence)))
+		for i, ev := range mr.Evidence {
+			if i == maxEvidence {
+				sb.WriteString(fmt.Sprintf("- _... and %d more_\n", len(mr.Evidence)-maxEvidence))
+				break
+			}
+			sb.WriteString(fmt.Sprintf("- %s\n", ev.Summary))
+		}
+		sb.WriteString("\n</details>\n\n")
+	}
+
+	if len(result.Hotspots) > 0 {
+		sb.WriteString("### Hotspots\n\n")
+		sb.WriteString("| Target | Contribution | Reason |\n|--------|-------------:|--------|\n")
+		for _, h := range result.Hotspots {
+			sb.WriteString(fmt.Sprintf("| `%s` | %.1f | %s |\n", h.NodeKey, h.ScoreContribution, h.Reason))
+		}
+		sb.WriteString("\n")
+	}
+
+	if len(result.SuggestedActions) > 0 {
+		sb.WriteString("### Suggestions\n\n")
+		for _, sa := range result.SuggestedActions {
+			sb.WriteString(fmt.Sprintf("- **%s**: %s\n", sa.Title, sa.Description))
+		}
+		sb.WriteString("\n")
+	}
+
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// WriteGitHubStepSummary appends the job summary to $GITHUB_STEP_SUMMARY.
+// It returns false without error when not running inside GitHub Actions.
+func WriteGitHubStepSummary(result *scoring.ScoreResult) (bool, error) {
+	path := os.Getenv("GITHUB_STEP_SUMMARY")
+	if path == "" {
+		return false, nil
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return false, fmt.Errorf("opening step summary: %w", err)
+	}
+	defer f.Close()
+
+	if err := (&StepSummaryRenderer{}).Render(f, result); err != nil {
+		return false, fmt.Errorf("writing step summary: %w", err)
+	}
+	return true, nil
+}
+
+func conclusionIcon(conclusion string) string {
+	switch conclusion {
+	case "success":
+		return ":white_check_mark:"
+	case "neutral":
+		return ":warning:"
+	default:
+		return ":x:"
+	}
+}
+
+func shortSHA(sha string) string {
+	if len(sha) > 7 {
+		return sha[:7]
+	}
+	return sha
+}
@@ -0,0 +1,58 @@
+package surface_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/toposcope/toposcope/pkg/surface"
+)
+
+func TestStepSummaryRenderer(t *testing.T) {
+	var buf bytes.Buffer
+	if err := (&surface.StepSummaryRenderer{}).Render(&buf, sampleResult()); err != nil {
+		t.Fatalf("Render() error: %v", err)
+	}
+	out := buf.String()
+
+	for _, want := range []string{
+		"## :warning: Toposcope: Grade C — Score 14.0",
+		"`abc123f` → `def456a`",
+		"| :orange_circle: | Cross-package dependencies | +5.0 | MEDIUM | 2 |",
+		"| :blue_circle: | Cleanup credits | -1.0 | INFO | 0 |",
+		"<details><summary>:orange_circle: Fanout increase (1)</summary>",
+		"- //app/auth:handler fanout 3 -> 8 (+5)",
+		"| `//app/auth:handler` | 9.0 | Flagged by 2 metrics |",
+		"- **Consider splitting //app/auth:handler**",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("summary missing %q\n%s", want, out)
+		}
+	}
+}
+
+func TestWriteGitHubStepSummary(t *testing.T) {
+	t.Setenv("GITHUB_STEP_SUMMARY", "")
+	if ok, err := surface.WriteGitHubStepSummary(sampleResult()); ok || err != nil {
+		t.Fatalf("WriteGitHubStepSummary() outside Actions = %v, %v; want false, nil", ok, err)
+	}
+
+	path := filepath.Join(t.TempDir(), "summary.md")
+	if err := os.WriteFile(path, []byte("# Earlier step\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("GITHUB_STEP_SUMMARY", path)
+	if ok, err := surface.WriteGitHubStepSummary(sampleResult()); !ok || err != nil {
+		t.Fatalf("WriteGitHubStepSummary() = %v, %v; want true, nil", ok, err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(data), "# Earlier step\n") || !strings.Contains(string(data), "Grade C") {
+		t.Errorf("expected summary to be appended after existing content, got:\n%s", data)
+	}
+}
@@ -1,5 +1,6 @@
 // Package surface defines output rendering interfaces for Toposcope results.
-// Implementations handle different output targets: terminal, GitHub Check Run, JSON.
+// Implementations handle different output targets: terminal, GitHub Check Run,
+// GitHub Actions job summary, JSON.
 package surface
 
 import (
@@ -72,6 +72,19 @@ func (r *TerminalRenderer) Render(w io.Writer, result *scoring.ScoreResult) erro
 		bold(fmt.Sprintf("Toposcope: Grade %s — Score %.1f",
 			colored(result.Grade, gc), result.TotalScore)))
 
+	if result.Partial {
+		fmt.Fprintf(w, "%s\n\n", dim(fmt.Sprintf("Partial analysis: scored within extraction scope (%d root targets)", len(result.Scope))))
+	}
+
+	if result.Normalization != "" {
+		fmt.Fprintf(w, "Normalized score: %.1f (raw %.1f × size factor %.2f)\n\n",
+			result.NormalizedScore, result.TotalScore, result.SizeFactor)
+	}
+	if iv := result.Interval; iv != nil {
+		fmt.Fprintf(w, "%.0f%% interval: %.1f–%.1f over %d resamples of the change\n\n",
+			iv.Level*100, iv.Low, iv.High, iv.Samples)
+	}
+
 	// Stats
 	fmt.Fprintf(w, "Analyzed: %d added nodes / %d removed nodes / %d added edges / %d removed edges\n\n",
 		result.DeltaStats.AddedNodes, result.DeltaStats.RemovedNodes,
@@ -118,6 +131,10 @@ func (r *TerminalRenderer) Render(w io.Writer, result *scoring.ScoreResult) erro
 		fmt.Fprintln(w)
 	}
 
+	if n := len(result.Suppressed); n > 0 {
+		fmt.Fprintf(w, "%s\n\n", dim(fmt.Sprintf("%d findings suppressed by waivers (%+.1f)", n, suppressedContribution(result))))
+	}
+
 	// Hotspots
 	if len(result.Hotspots) > 0 {
 		fmt.Fprintln(w, "Hotspots:")
@@ -148,6 +165,15 @@ func (r *TerminalRenderer) Render(w io.Writer, result *scoring.ScoreResult) erro
 }
 
 // wrapText wraps a string at the given width, returning lines.
+// suppressedContribution sums the contributions waivers removed from the score.
+func suppressedContribution(result *scoring.ScoreResult) float64 {
+	var total float64
+	for _, sf := range result.Suppressed {
+		total += sf.Contribution
+	}
+	return total
+}
+
 func wrapText(s string, width int) []string {
 	words := strings.Fields(s)
 	if len(words) == 0 {
@@ -1,7 +1,7 @@
 "use client";
 
 import { useEffect, useState, useMemo, useCallback } from "react";
-import { useParams } from "next/navigation";
+import { useParams, useSearchParams } from "next/navigation";
 import { Search, ChevronRight, Map, Crosshair, Flame, Route } from "lucide-react";
 import { Card, CardContent } from "@/components/ui/card";
 import { DependencyGraph } from "@/components/graph/dependency-graph";
@@ -32,6 +32,8 @@ async function fetchJSON<T>(path: string): Promise<T> {
 
 export default function GraphExplorerPage() {
   const params = useParams<{ repoId: string }>();
+  // ?target= opens the Target Explorer on a target, as linked from the CLI.
+  const initialTarget = useSearchParams().get("target");
   const [tab, setTab] = useState<TabId>("packages");
   const [snapshotId, setSnapshotId] = useState<string | null>(null);
   const [snapInfo, setSnapInfo] = useState<SnapshotInfo | null>(null);
@@ -79,14 +81,14 @@ export default function GraphExplorerPage() {
       try {
         // Get scores for this repo to find the latest head_snapshot_id
         const scores = await fetchJSON<{ head_snapshot_id: string; commit_sha: string }[]>(
-          `/api/repos/${params.repoId}/scores`
+          `/api/v2/repos/${params.repoId}/scores`
         );
         if (scores.length > 0 && scores[0].head_snapshot_id) {
           const snapshotId = scores[0].head_snapshot_id;
           setSnapshotId(snapshotId);
           // Fetch snapshot metadata
           try {
-            const snap = await fetchJSON<SnapshotInfo>(`/api/snapshots/${snapshotId}`);
+            const snap = await fetchJSON<SnapshotInfo>(`/api/v2/snapshots/${snapshotId}`);
             setSnapInfo({
               id: snapshotId,
               commit_sha: snap.commit_sha ?? scores[0].commit_sha,
@@ -126,9 +128,10 @@ export default function GraphExplorerPage() {
         if (hideTests) qs.set("hide_tests", "true");
         if (hideExternal) qs.set("hide_external", "true");
         if (minEdgeWeight > 1) qs.set("min_edge_weight", String(minEdgeWeight));
+        qs.set("layout", "true");
         const qsStr = qs.toString();
         const data = await fetchJSON<{ nodes: Record<string, PackageNode>; edges: PackageEdge[]; truncated: boolean }>(
-          `/api/snapshots/${snapshotId}/packages${qsStr ? `?${qsStr}` : ""}`
+          `/api/v2/snapshots/${snapshotId}/packages${qsStr ? `?${qsStr}` : ""}`
         );
 
         let nodes = data.nodes || {};
@@ -196,7 +199,7 @@ export default function GraphExplorerPage() {
     if (!snapshotId) return;
     try {
       const data = await fetchJSON<{ nodes: Record<string, Node>; edges: Edge[] }>(
-        `/api/snapshots/${snapshotId}/subgraph?root=${encodeURIComponent(pkg)}&depth=1`
+        `/api/v2/snapshots/${snapshotId}/subgraph?root=${encodeURIComponent(pkg)}&depth=1`
       );
       setDrillNodes(data.nodes || {});
       setDrillEdges(data.edges || []);
@@ -220,7 +223,7 @@ export default function GraphExplorerPage() {
         direction,
       });
       const data = await fetchJSON<{ nodes: Record<string, Node>; edges: Edge[]; truncated: boolean }>(
-        `/api/snapshots/${snapshotId}/ego?${params}`
+        `/api/v2/snapshots/${snapshotId}/ego?${params}`
       );
       setEgoNodes(data.nodes || {});
       setEgoEdges(data.edges || []);
@@ -232,6 +235,14 @@ export default function GraphExplorerPage() {
     setEgoLoading(false);
   }, [snapshotId]);
 
+  useEffect(() => {
+    if (snapshotId && initialTarget) {
+      setTab("explorer");
+      setEgoSearch(initialTarget);
+      fetchEgoGraph(initialTarget, 2, "both");
+    }
+  }, [snapshotId, initialTarget, fetchEgoGraph]);
+
   // All target keys for search autocomplete (from package nodes -> build label patterns)
   const egoSearchResults = useMemo(() => {
     if (!egoSearch || egoSearch.length < 2) return [];
@@ -270,7 +281,7 @@ export default function GraphExplorerPage() {
     try {
       const params = new URLSearchParams({ from, to, max_paths: "10" });
       const data = await fetchJSON<PathResult>(
-        `/api/snapshots/${snapshotId}/path?${params}`
+        `/api/v2/snapshots/${snapshotId}/path?${params}`
       );
       setPathResult(data);
     } catch {
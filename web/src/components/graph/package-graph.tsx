@@ -95,7 +95,12 @@ export function PackageGraph({
       pkg,
       color: pkg.is_external ? "#94a3b8" : hashColor(key.split("/").slice(0, 3).join("/")),
       degree: degreeMap.get(key) || 0,
+      x: pkg.position?.x,
+      y: pkg.position?.y,
     }));
+    // The server lays out the graph when asked; only run the simulation
+    // ourselves when it didn't.
+    const preLaidOut = simNodes.length > 0 && simNodes.every((n) => n.pkg.position);
 
     const nodeMap = new Map(simNodes.map((n) => [n.id, n]));
 
@@ -234,7 +239,7 @@ export function PackageGraph({
       });
 
     // Tick
-    simulation.on("tick", () => {
+    const draw = () => {
       link
         .attr("x1", (d) => (d.source as SimNode).x!)
         .attr("y1", (d) => (d.source as SimNode).y!)
@@ -242,7 +247,12 @@ export function PackageGraph({
         .attr("y2", (d) => (d.target as SimNode).y!);
 
       nodeGroup.attr("transform", (d) => `translate(${d.x},${d.y})`);
-    });
+    };
+    simulation.on("tick", draw);
+    if (preLaidOut) {
+      simulation.stop();
+      draw();
+    }
 
     // Initial zoom to fit
     setTimeout(() => {
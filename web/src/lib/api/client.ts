@@ -1,4 +1,4 @@
-import type { Repository, ScoreResult, Snapshot, Subgraph, ScoreHistory, PackageGraph, EgoGraph, PathResult } from "@/lib/types";
+import type { Repository, ScoreResult, Snapshot, Subgraph, ScoreHistory, PackageGraph, CommunityResult, EgoGraph, PathResult } from "@/lib/types";
 
 export interface ToposcopeAPI {
   getRepos(): Promise<Repository[]>;
@@ -6,8 +6,10 @@ export interface ToposcopeAPI {
   getPRImpact(repoId: string, prNumber: number): Promise<ScoreResult>;
   getSnapshot(snapshotId: string): Promise<Snapshot>;
   getSubgraph(snapshotId: string, roots: string[], depth: number): Promise<Subgraph>;
+  getSample(snapshotId: string, opts?: { maxNodes?: number; seed?: number }): Promise<Subgraph>;
   getScoreHistory(repoId: string): Promise<ScoreHistory[]>;
   getPackages(snapshotId: string, opts?: { hideTests?: boolean; hideExternal?: boolean; minEdgeWeight?: number }): Promise<PackageGraph>;
+  getCommunities(snapshotId: string, opts?: { hideTests?: boolean; hideExternal?: boolean }): Promise<CommunityResult>;
   getEgoGraph(snapshotId: string, target: string, opts?: { depth?: number; direction?: "deps" | "rdeps" | "both" }): Promise<EgoGraph>;
   getPath(snapshotId: string, from: string, to: string, maxPaths?: number): Promise<PathResult>;
   getScore(repoId: string, scoreId: string): Promise<ScoreResult>;
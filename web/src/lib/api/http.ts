@@ -1,13 +1,13 @@
 import type { ToposcopeAPI } from "./client";
-import type { Repository, ScoreResult, Snapshot, Subgraph, ScoreHistory, PackageGraph, EgoGraph, PathResult } from "@/lib/types";
+import type { Repository, ScoreResult, Snapshot, Subgraph, ScoreHistory, PackageGraph, CommunityResult, EgoGraph, PathResult } from "@/lib/types";
 
 export class HttpAPI implements ToposcopeAPI {
-  constructor(private baseUrl: string) {}
+  constructor(private baseUrl: string, private token?: string) {}
 
   private async fetchJSON<T>(path: string): Promise<T> {
-    const res = await fetch(`${this.baseUrl}${path}`, {
-      headers: { "Content-Type": "application/json" },
-    });
+    const headers: Record<string, string> = { "Content-Type": "application/json" };
+    if (this.token) headers["Authorization"] = `Bearer ${this.token}`;
+    const res = await fetch(`${this.baseUrl}${path}`, { headers });
     if (!res.ok) {
       throw new Error(`API error: ${res.status} ${res.statusText}`);
     }
@@ -15,30 +15,49 @@ export class HttpAPI implements ToposcopeAPI {
   }
 
   async getRepos(): Promise<Repository[]> {
-    return this.fetchJSON("/api/repos");
+    return this.fetchJSON("/api/v2/repos");
   }
 
   async getScores(repoId: string): Promise<ScoreResult[]> {
-    return this.fetchJSON(`/api/repos/${repoId}/scores`);
+    return this.fetchJSON(`/api/v2/repos/${repoId}/scores`);
   }
 
   async getPRImpact(repoId: string, prNumber: number): Promise<ScoreResult> {
-    return this.fetchJSON(`/api/repos/${repoId}/prs/${prNumber}/impact`);
+    return this.fetchJSON(`/api/v2/repos/${repoId}/prs/${prNumber}/impact`);
   }
 
   async getSnapshot(snapshotId: string): Promise<Snapshot> {
-    return this.fetchJSON(`/api/snapshots/${snapshotId}`);
+    // Download large snapshots straight from object storage when the server
+    // can sign a URL; otherwise stream them through the API.
+    try {
+      const { url } = await this.fetchJSON<{ url: string }>(`/api/v2/snapshots/${snapshotId}/download-url`);
+      const res = await fetch(url);
+      if (res.ok) {
+        return (await res.json()) as Snapshot;
+      }
+    } catch {
+      // Fall back to the API below.
+    }
+    return this.fetchJSON(`/api/v2/snapshots/${snapshotId}`);
   }
 
   async getSubgraph(snapshotId: string, roots: string[], depth: number): Promise<Subgraph> {
     const params = new URLSearchParams();
     for (const r of roots) params.append("root", r);
     params.set("depth", String(depth));
-    return this.fetchJSON(`/api/snapshots/${snapshotId}/subgraph?${params}`);
+    return this.fetchJSON(`/api/v2/snapshots/${snapshotId}/subgraph?${params}`);
+  }
+
+  async getSample(snapshotId: string, opts?: { maxNodes?: number; seed?: number }): Promise<Subgraph> {
+    const params = new URLSearchParams();
+    if (opts?.maxNodes) params.set("max_nodes", String(opts.maxNodes));
+    if (opts?.seed) params.set("seed", String(opts.seed));
+    const qs = params.toString();
+    return this.fetchJSON(`/api/v2/snapshots/${snapshotId}/sample${qs ? `?${qs}` : ""}`);
   }
 
   async getScoreHistory(repoId: string): Promise<ScoreHistory[]> {
-    return this.fetchJSON(`/api/repos/${repoId}/history`);
+    return this.fetchJSON(`/api/v2/repos/${repoId}/history`);
   }
 
   async getPackages(snapshotId: string, opts?: { hideTests?: boolean; hideExternal?: boolean; minEdgeWeight?: number }): Promise<PackageGraph> {
@@ -47,7 +66,15 @@ export class HttpAPI implements ToposcopeAPI {
     if (opts?.hideExternal) params.set("hide_external", "true");
     if (opts?.minEdgeWeight) params.set("min_edge_weight", String(opts.minEdgeWeight));
     const qs = params.toString();
-    return this.fetchJSON(`/api/snapshots/${snapshotId}/packages${qs ? `?${qs}` : ""}`);
+    return this.fetchJSON(`/api/v2/snapshots/${snapshotId}/packages${qs ? `?${qs}` : ""}`);
+  }
+
+  async getCommunities(snapshotId: string, opts?: { hideTests?: boolean; hideExternal?: boolean }): Promise<CommunityResult> {
+    const params = new URLSearchParams();
+    if (opts?.hideTests) params.set("hide_tests", "true");
+    if (opts?.hideExternal) params.set("hide_external", "true");
+    const qs = params.toString();
+    return this.fetchJSON(`/api/v2/snapshots/${snapshotId}/communities${qs ? `?${qs}` : ""}`);
   }
 
   async getEgoGraph(snapshotId: string, target: string, opts?: { depth?: number; direction?: "deps" | "rdeps" | "both" }): Promise<EgoGraph> {
@@ -55,7 +82,7 @@ export class HttpAPI implements ToposcopeAPI {
     params.set("target", target);
     if (opts?.depth) params.set("depth", String(opts.depth));
     if (opts?.direction) params.set("direction", opts.direction);
-    return this.fetchJSON(`/api/snapshots/${snapshotId}/ego?${params}`);
+    return this.fetchJSON(`/api/v2/snapshots/${snapshotId}/ego?${params}`);
   }
 
   async getPath(snapshotId: string, from: string, to: string, maxPaths?: number): Promise<PathResult> {
@@ -63,10 +90,10 @@ export class HttpAPI implements ToposcopeAPI {
     params.set("from", from);
     params.set("to", to);
     if (maxPaths) params.set("max_paths", String(maxPaths));
-    return this.fetchJSON(`/api/snapshots/${snapshotId}/path?${params}`);
+    return this.fetchJSON(`/api/v2/snapshots/${snapshotId}/path?${params}`);
   }
 
   async getScore(repoId: string, scoreId: string): Promise<ScoreResult> {
-    return this.fetchJSON(`/api/repos/${repoId}/scores/${scoreId}`);
+    return this.fetchJSON(`/api/v2/repos/${repoId}/scores/${scoreId}`);
   }
 }
@@ -1,9 +1,11 @@
 import { HttpAPI } from "./http";
 
 const BASE_URL = process.env.NEXT_PUBLIC_API_BASE_URL ?? "http://localhost:7700";
+// Shared token for a `toposcope ui --token` server.
+const TOKEN = process.env.NEXT_PUBLIC_API_TOKEN;
 
 export class LocalAPI extends HttpAPI {
   constructor() {
-    super(BASE_URL);
+    super(BASE_URL, TOKEN);
   }
 }
@@ -1,5 +1,5 @@
 import type { ToposcopeAPI } from "./client";
-import type { Repository, ScoreResult, Snapshot, Subgraph, ScoreHistory, PackageGraph, EgoGraph, PathResult } from "@/lib/types";
+import type { Repository, ScoreResult, Snapshot, Subgraph, ScoreHistory, PackageGraph, CommunityResult, EgoGraph, PathResult } from "@/lib/types";
 import { mockRepos, mockScores, mockSnapshot, mockScoreHistory } from "./mock-data";
 
 export class MockAPI implements ToposcopeAPI {
@@ -76,11 +76,21 @@ export class MockAPI implements ToposcopeAPI {
     return mockScoreHistory[repoId] ?? [];
   }
 
+  async getSample(_snapshotId: string, _opts?: { maxNodes?: number; seed?: number }): Promise<Subgraph> {
+    await this.delay();
+    return { nodes: mockSnapshot.nodes, edges: mockSnapshot.edges };
+  }
+
   async getPackages(_snapshotId: string, _opts?: { hideTests?: boolean; hideExternal?: boolean; minEdgeWeight?: number }): Promise<PackageGraph> {
     await this.delay();
     return { nodes: {}, edges: [], truncated: false };
   }
 
+  async getCommunities(_snapshotId: string, _opts?: { hideTests?: boolean; hideExternal?: boolean }): Promise<CommunityResult> {
+    await this.delay();
+    return { communities: [], boundaries: [], modularity: 0, truncated: false };
+  }
+
   async getEgoGraph(_snapshotId: string, _target: string, _opts?: { depth?: number; direction?: "deps" | "rdeps" | "both" }): Promise<EgoGraph> {
     await this.delay();
     return { nodes: {}, edges: [], truncated: false };
@@ -1,11 +1,17 @@
 export interface Node {
   key: string;
   kind: string;
+  language?: string;
   package: string;
   tags: string[];
   visibility: string[];
   is_test: boolean;
   is_external: boolean;
+  repo?: string;
+  build_ms?: number;
+  test_ms?: number;
+  cache_spawns?: number;
+  cache_hits?: number;
 }
 
 export type EdgeType = "COMPILE" | "RUNTIME" | "TOOLCHAIN" | "DATA";
@@ -14,6 +20,9 @@ export interface Edge {
   from: string;
   to: string;
   type: EdgeType;
+  attr?: string;
+  build_file?: string;
+  build_line?: number;
 }
 
 export interface SnapshotStats {
@@ -21,6 +30,13 @@ export interface SnapshotStats {
   edge_count: number;
   package_count: number;
   extraction_ms: number;
+  skipped_packages?: string[];
+  phases?: PhaseTiming[];
+}
+
+export interface PhaseTiming {
+  name: string;
+  ms: number;
 }
 
 export interface Snapshot {
@@ -42,12 +58,26 @@ export interface DeltaStats {
   removed_edges: number;
 }
 
+export interface ChangeCount {
+  name: string;
+  added_nodes: number;
+  removed_nodes: number;
+  added_edges: number;
+  removed_edges: number;
+}
+
+export interface DeltaSummary {
+  packages: ChangeCount[];
+  boundaries: ChangeCount[];
+}
+
 export interface EvidenceItem {
   type: string;
   summary: string;
   from?: string;
   to?: string;
   value?: number;
+  fingerprint?: string;
 }
 
 export type Severity = "HIGH" | "MEDIUM" | "LOW" | "INFO";
@@ -58,6 +88,7 @@ export interface MetricResult {
   contribution: number;
   severity: Severity;
   evidence: EvidenceItem[];
+  config?: Record<string, unknown>;
 }
 
 export interface Hotspot {
@@ -84,10 +115,14 @@ export interface ScoreResult {
   hotspots: Hotspot[];
   suggested_actions: SuggestedAction[];
   delta_stats?: DeltaStats;
+  delta_summary?: DeltaSummary;
   base_snapshot_id: string;
   head_snapshot_id: string;
   delta_id: string;
   pr_number?: number;
+  commit_author?: string; // from GitHub, for installed repositories
+  commit_message?: string;
+  committed_at?: string;
   created_at?: string;
 }
 
@@ -104,6 +139,11 @@ export interface ScoreHistory {
   grade: string;
   count?: number;
   metrics: Record<string, number>;
+  score_id?: string; // granularity=commit only
+  pr_number?: number;
+  created_at?: string;
+  author?: string;
+  message?: string; // commit subject
 }
 
 export interface Subgraph {
@@ -115,8 +155,11 @@ export interface PackageNode {
   package: string;
   target_count: number;
   kinds: string[];
+  languages?: string[];
   has_tests: boolean;
   is_external: boolean;
+  repo?: string;
+  position?: { x: number; y: number };
 }
 
 export interface PackageEdge {
@@ -131,6 +174,22 @@ export interface PackageGraph {
   truncated: boolean;
 }
 
+export interface Community {
+  id: number;
+  packages: string[];
+  boundary: string;
+  purity: number;
+  internal_weight: number;
+  external_weight: number;
+}
+
+export interface CommunityResult {
+  communities: Community[];
+  boundaries: { boundary: string; packages: number; communities: number[] }[];
+  modularity: number;
+  truncated: boolean;
+}
+
 export interface EgoGraph {
   nodes: Record<string, Node>;
   edges: Edge[];